@@ -0,0 +1,456 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ==============================================================================
+// SESSION TOKENS AND ROLE ENFORCEMENT
+// ==============================================================================
+//
+// Login mints an opaque session token that is kept inside App. Every privileged
+// bound method resolves the acting user and role from that session instead of
+// trusting the user ID passed in from the frontend, and consults the policy
+// table below to decide whether the call is allowed.
+
+// appSession is the server-side record of the user currently logged in on this station.
+type appSession struct {
+	Token    string
+	UserID   int
+	Username string
+	Role     string
+	IssuedAt time.Time
+	// OfflineEventUID is set while the session's login exists only in the offline queue.
+	OfflineEventUID string
+	// LoginLogID is the log_entries row opened by this session's login.
+	LoginLogID int
+	// LastActivity is the last keyboard, mouse or touch input the frontend reported.
+	LastActivity time.Time
+	// IdleWarned is set once the idle-logout warning has been sent for the current idle spell.
+	IdleWarned bool
+	// IdleTimeout and IdleWarning are the idle-logout policy read when the session started
+	// (or when the policy settings were last saved); a zero timeout turns idle logout off.
+	IdleTimeout time.Duration
+	IdleWarning time.Duration
+}
+
+// methodAccessPolicy lists who may call a bound method.
+//   - roles may call the method for any user ID argument.
+//   - selfRoles may call the method only when the user ID argument is their own.
+type methodAccessPolicy struct {
+	roles     []string
+	selfRoles []string
+}
+
+var (
+	allRoles     = []string{"admin", "teacher", "student", "working_student"}
+	studentRoles = []string{"student", "working_student"}
+)
+
+// boundMethodAccessPolicies is the declarative role policy for every privileged bound method.
+// Methods not listed here are callable without a session (login, registration, password
+// recovery and station setup).
+var boundMethodAccessPolicies = map[string]methodAccessPolicy{
+	// Dashboards and profile
+	"GetAdminDashboard":          {roles: []string{"admin"}},
+	"GetWorkingStudentDashboard": {roles: []string{"working_student"}},
+	"GetStudentDashboard":        {selfRoles: studentRoles},
+	"UpdateUserPhoto":            {selfRoles: allRoles},
+	"SaveProfilePhoto":           {selfRoles: allRoles},
+	"DeleteProfilePhoto":         {selfRoles: allRoles},
+	"GetUserRecoveryCode":        {selfRoles: allRoles},
+	"GenerateUserRecoveryCode":   {selfRoles: allRoles},
+	"GetMethodAccessPolicies":    {roles: []string{"admin"}},
+	"GetSchemaVersion":           {roles: []string{"admin"}},
+	"GetOfflineReplayLog":        {roles: []string{"admin"}},
+	"GetClockStatus":             {roles: []string{"admin"}},
+	"SetLabMaintenancePIN":       {roles: []string{"admin"}},
+
+	// Two-factor authentication and recovery-code keys
+	"GetTwoFactorStatus":             {selfRoles: []string{"admin", "teacher"}},
+	"BeginTwoFactorEnrollment":       {selfRoles: []string{"admin", "teacher"}},
+	"ConfirmTwoFactorEnrollment":     {selfRoles: []string{"admin", "teacher"}},
+	"DisableTwoFactor":               {selfRoles: []string{"admin", "teacher"}},
+	"RegenerateTwoFactorBackupCodes": {selfRoles: []string{"admin", "teacher"}},
+	"GetTwoFactorPolicies":           {roles: []string{"admin"}},
+	"SetTwoFactorRequired":           {roles: []string{"admin"}},
+	"ResetUserTwoFactor":             {roles: []string{"admin"}},
+	"CheckRecoveryCodeKeys":          {roles: []string{"admin"}},
+	"RotateRecoveryCodeKey":          {roles: []string{"admin"}},
+
+	// Audit trail
+	"GetAuditEvents":       {roles: []string{"admin"}},
+	"ExportAuditEventsCSV": {roles: []string{"admin"}},
+	"ExportAuditEventsPDF": {roles: []string{"admin"}},
+
+	// Sessions and notifications
+	"Logout":                   {selfRoles: allRoles},
+	"TouchSession":             {selfRoles: allRoles},
+	"ReportActivity":           {selfRoles: allRoles},
+	"GetNotifications":         {selfRoles: allRoles},
+	"MarkNotificationRead":     {selfRoles: allRoles},
+	"MarkAllNotificationsRead": {selfRoles: allRoles},
+
+	// User management
+	"ResetPasswordByRole":           {selfRoles: []string{"admin"}},
+	"GetInactivityPolicySettings":   {roles: []string{"admin"}},
+	"SaveInactivityPolicySettings":  {roles: []string{"admin"}},
+	"GetLockedAccounts":             {roles: []string{"admin"}},
+	"UnlockAccount":                 {roles: []string{"admin"}},
+	"UnlockStation":                 {roles: []string{"admin"}},
+	"GetUsers":                      {roles: []string{"admin"}},
+	"GetUsersByType":                {roles: []string{"admin"}},
+	"SearchUsers":                   {roles: []string{"admin"}},
+	"CreateUser":                    {roles: []string{"admin"}},
+	"UpdateUser":                    {roles: []string{"admin"}, selfRoles: allRoles},
+	"DeleteUser":                    {roles: []string{"admin"}},
+	"DeleteExpiredDeactivatedUsers": {roles: []string{"admin"}},
+	"DeactivateTeacher":             {roles: []string{"admin"}},
+	"ArchiveUser":                   {roles: []string{"admin"}},
+	"UnarchiveUser":                 {roles: []string{"admin"}},
+	"GetArchivedUsers":              {roles: []string{"admin"}},
+	"GetUsersByActivityStatus":      {roles: []string{"admin"}},
+	"RunInactivityCheck":            {roles: []string{"admin"}},
+	"ReactivateUser":                {roles: []string{"admin"}},
+	"ArchiveStudent":                {roles: []string{"admin", "working_student"}},
+	"UnarchiveStudent":              {roles: []string{"admin", "working_student"}},
+	"GetArchivedStudents":           {roles: []string{"admin", "working_student"}},
+	"DeleteExpiredStudents":         {roles: []string{"admin", "working_student"}},
+	"GetActiveStudentsForArchiving": {roles: []string{"admin", "working_student"}},
+
+	// Registration
+	"GetPendingRegistrations": {roles: []string{"admin", "working_student"}},
+	"ProcessRegistration":     {selfRoles: []string{"admin", "working_student"}},
+	"GetRegistrationHistory":  {roles: []string{"admin", "working_student"}},
+
+	// Departments
+	"CreateDepartment":    {roles: []string{"admin"}},
+	"UpdateDepartment":    {roles: []string{"admin"}},
+	"ArchiveDepartment":   {roles: []string{"admin"}},
+	"UnarchiveDepartment": {roles: []string{"admin"}},
+	"DeleteDepartment":    {roles: []string{"admin"}},
+
+	// Login logs
+	"GetArchivedLogs":            {roles: []string{"admin"}},
+	"GetAllLogs":                 {roles: []string{"admin"}},
+	"GetStudentLoginLogs":        {roles: []string{"admin"}, selfRoles: allRoles},
+	"GetLogsRangeCount":          {roles: []string{"admin"}},
+	"ExportLogsCSVByRowRange":    {roles: []string{"admin"}},
+	"ExportLogsPDFByRowRange":    {roles: []string{"admin"}},
+	"ExportLogsDOCXByRowRange":   {roles: []string{"admin"}},
+	"ExportLogsCSVByCount":       {roles: []string{"admin"}},
+	"ExportLogsPDFByCount":       {roles: []string{"admin"}},
+	"ExportLogsDOCXByCount":      {roles: []string{"admin"}},
+	"ExportLogsCSVByRange":       {roles: []string{"admin"}},
+	"ExportLogsPDFByRange":       {roles: []string{"admin"}},
+	"ExportLogsDOCXByRange":      {roles: []string{"admin"}},
+	"GetArchivedLogsByDate":      {roles: []string{"admin"}},
+	"ArchiveLogsByDate":          {selfRoles: []string{"admin"}},
+	"ExportArchivedLogSheetCSV":  {roles: []string{"admin"}},
+	"ExportArchivedLogSheetPDF":  {roles: []string{"admin"}},
+	"ExportArchivedLogSheetDOCX": {roles: []string{"admin"}},
+	"ArchiveLogs":                {selfRoles: []string{"admin"}},
+
+	// Equipment feedback
+	"SetFeedbackAdminStatus":          {selfRoles: []string{"admin"}},
+	"GetFeedback":                     {roles: []string{"admin", "working_student"}},
+	"GetStudentFeedback":              {selfRoles: allRoles},
+	"SaveEquipmentFeedback":           {selfRoles: allRoles},
+	"GetPendingFeedback":              {roles: []string{"admin", "working_student"}},
+	"GetConfirmedFeedback":            {roles: []string{"admin", "working_student"}},
+	"GetRejectedFeedback":             {roles: []string{"admin", "working_student"}},
+	"ConfirmFeedback":                 {selfRoles: []string{"working_student"}},
+	"ForwardFeedbackToAdmin":          {selfRoles: []string{"working_student"}},
+	"ForwardMultipleFeedbackToAdmin":  {selfRoles: []string{"working_student"}},
+	"ConfirmAndForwardMultiple":       {selfRoles: []string{"working_student"}},
+	"GetFeedbackRangeCount":           {roles: []string{"admin"}},
+	"ExportFeedbackCSVByRowRange":     {roles: []string{"admin"}},
+	"ExportFeedbackPDFByRowRange":     {roles: []string{"admin"}},
+	"ExportFeedbackDOCXByRowRange":    {roles: []string{"admin"}},
+	"ExportFeedbackCSVByCount":        {roles: []string{"admin"}},
+	"ExportFeedbackPDFByCount":        {roles: []string{"admin"}},
+	"ExportFeedbackDOCXByCount":       {roles: []string{"admin"}},
+	"ExportFeedbackCSVByRange":        {roles: []string{"admin"}},
+	"ExportFeedbackPDFByRange":        {roles: []string{"admin"}},
+	"ExportFeedbackDOCXByRange":       {roles: []string{"admin"}},
+	"GetArchivedFeedbackByDate":       {roles: []string{"admin"}},
+	"ArchiveFeedbackByDate":           {selfRoles: []string{"admin"}},
+	"ExportArchivedFeedbackSheetCSV":  {roles: []string{"admin"}},
+	"ExportArchivedFeedbackSheetPDF":  {roles: []string{"admin"}},
+	"ExportArchivedFeedbackSheetDOCX": {roles: []string{"admin"}},
+	"ArchiveFeedback":                 {selfRoles: []string{"admin"}},
+
+	// Classes and class lists
+	"CreateSubject":                       {selfRoles: []string{"teacher"}},
+	"GetTeacherClassesByUserID":           {selfRoles: []string{"teacher"}},
+	"GetTeacherClasses":                   {roles: []string{"admin", "teacher"}},
+	"GetStudentClasses":                   {selfRoles: studentRoles},
+	"CreateClass":                         {selfRoles: []string{"teacher"}},
+	"UpdateClass":                         {roles: []string{"teacher"}},
+	"CloseClass":                          {roles: []string{"teacher"}},
+	"ReopenClass":                         {roles: []string{"teacher"}},
+	"DeleteClass":                         {selfRoles: []string{"teacher"}},
+	"GetClassStudents":                    {roles: []string{"teacher", "student", "working_student"}},
+	"GetClassByID":                        {roles: []string{"teacher", "student", "working_student"}},
+	"GetClassSchedule":                    {roles: []string{"teacher", "admin"}},
+	"SaveClassSchedule":                   {selfRoles: []string{"teacher"}},
+	"GetTeacherID":                        {selfRoles: []string{"teacher"}},
+	"GetAllRegisteredStudents":            {roles: []string{"admin", "teacher", "working_student"}},
+	"ArchiveClass":                        {roles: []string{"teacher"}},
+	"UnarchiveClass":                      {roles: []string{"teacher"}},
+	"GetArchivedClasses":                  {selfRoles: []string{"teacher"}},
+	"GetStudentArchivedClasses":           {selfRoles: studentRoles},
+	"GetAllStudentsForJoinClasses":        {roles: []string{"teacher"}},
+	"AddStudentToJoinClass":               {selfRoles: []string{"teacher"}},
+	"AddMultipleStudentsToJoinClass":      {selfRoles: []string{"teacher"}},
+	"RemoveStudentFromJoinClassByIDs":     {roles: []string{"teacher"}, selfRoles: studentRoles},
+	"LeaveClassByStudent":                 {selfRoles: studentRoles},
+	"GetClassesByJoinCode":                {roles: studentRoles},
+	"JoinClassByJoinCode":                 {selfRoles: studentRoles},
+	"ArchiveJoinedClassByStudent":         {selfRoles: studentRoles},
+	"RestoreArchivedJoinedClassByStudent": {selfRoles: studentRoles},
+	"ExportClasslistCSV":                  {roles: []string{"teacher"}},
+	"ExportClasslistPDF":                  {roles: []string{"teacher"}},
+	"ExportClasslistDOCX":                 {roles: []string{"teacher"}},
+
+	// Attendance
+	"OpenClassAttendance":                {roles: []string{"teacher"}},
+	"GetClassAttendance":                 {roles: []string{"teacher"}},
+	"UpdateAttendanceRecord":             {roles: []string{"teacher"}},
+	"UpdateSessionAttendanceRecord":      {selfRoles: []string{"teacher"}},
+	"GetAttendanceRecordHistory":         {roles: []string{"teacher", "admin"}},
+	"GetClassAttendanceSummary":          {roles: []string{"teacher", "admin"}},
+	"GetStudentAttendanceSummary":        {selfRoles: studentRoles},
+	"SaveClassAttendanceAlertThresholds": {selfRoles: []string{"teacher"}},
+	"GetClassTimeInLocationRule":         {roles: []string{"teacher", "admin"}},
+	"SaveClassTimeInLocationRule":        {selfRoles: []string{"teacher"}},
+	"ExportClassAttendanceSummaryCSV":    {roles: []string{"teacher", "admin"}},
+	"ExportClassAttendanceSummaryPDF":    {roles: []string{"teacher", "admin"}},
+	"GetSessionAttendance":               {selfRoles: []string{"teacher"}},
+	"ExportAttendanceCSVByDate":          {roles: []string{"teacher"}},
+	"ExportAttendancePDFByDate":          {roles: []string{"teacher"}},
+	"ExportAttendanceDOCXByDate":         {roles: []string{"teacher"}},
+	"ExportAttendanceCSVBySession":       {roles: []string{"teacher"}},
+	"ExportAttendancePDFBySession":       {roles: []string{"teacher"}},
+	"ExportAttendanceDOCXBySession":      {roles: []string{"teacher"}},
+	"ExportArchivedAttendanceCSVByDate":  {roles: []string{"teacher"}},
+	"ExportArchivedAttendancePDFByDate":  {roles: []string{"teacher"}},
+	"ExportArchivedAttendanceDOCXByDate": {roles: []string{"teacher"}},
+	"ArchiveAttendanceSheet":             {roles: []string{"teacher"}},
+	"ArchiveAttendanceSession":           {selfRoles: []string{"teacher"}},
+	"DeleteAttendanceSession":            {selfRoles: []string{"teacher"}},
+	"UnarchiveAttendanceSession":         {selfRoles: []string{"teacher"}},
+	"GetArchivedAttendanceSheets":        {selfRoles: []string{"teacher"}},
+	"GetActiveAttendanceSheets":          {selfRoles: []string{"teacher"}},
+	"CreateAttendanceSession":            {selfRoles: []string{"teacher"}},
+	"GetTeacherAttendanceSessions":       {selfRoles: []string{"teacher"}},
+	"SaveAttendanceSession":              {selfRoles: []string{"teacher"}},
+	"RenameAttendanceSession":            {selfRoles: []string{"teacher"}},
+	"PauseAttendanceSession":             {selfRoles: []string{"teacher"}},
+	"ResumeAttendanceSession":            {selfRoles: []string{"teacher"}},
+	"GetStudentOpenAttendanceSessions":   {selfRoles: studentRoles},
+	"StudentTimeIn":                      {selfRoles: studentRoles},
+	"StudentTimeOut":                     {selfRoles: studentRoles},
+	"GetAttendanceSessionCode":           {selfRoles: []string{"teacher"}},
+	"GetAttendanceCodeAttempts":          {selfRoles: []string{"teacher"}},
+	"GetStudentAttendanceHistory":        {selfRoles: studentRoles},
+	"SubmitExcuseRequest":                {selfRoles: studentRoles},
+	"CancelExcuseRequest":                {selfRoles: studentRoles},
+	"GetStudentExcuseRequests":           {selfRoles: studentRoles},
+	"GetClassExcuseRequests":             {roles: []string{"teacher", "admin"}},
+	"GetExcuseRequestDocument":           {roles: allRoles},
+	"ReviewExcuseRequest":                {selfRoles: []string{"teacher"}},
+	"GetClassFinalizationPolicy":         {roles: []string{"teacher", "admin"}},
+	"SaveClassFinalizationPolicy":        {selfRoles: []string{"teacher"}},
+	"GetAttendanceSessionFinalization":   {roles: []string{"teacher", "admin"}},
+	"FinalizeAttendanceSession":          {selfRoles: []string{"teacher"}},
+	"GetClassLatenessRule":               {roles: []string{"teacher", "admin"}},
+	"SaveClassLatenessRule":              {selfRoles: []string{"teacher"}},
+	"GetSessionLatenessRule":             {roles: []string{"teacher", "admin"}},
+	"SaveSessionLatenessRule":            {selfRoles: []string{"teacher"}},
+}
+
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// startSession mints a new session token for the given user, replacing any previous session.
+func (a *App) startSession(user *User) (string, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	idleTimeout, idleWarning := loadIdleLogoutPolicy()
+	a.sessionMu.Lock()
+	a.session = &appSession{
+		Token:        token,
+		UserID:       user.ID,
+		Username:     user.Name,
+		Role:         user.Role,
+		IssuedAt:     time.Now(),
+		LoginLogID:   user.LoginLogID,
+		LastActivity: a.now(),
+		IdleTimeout:  idleTimeout,
+		IdleWarning:  idleWarning,
+	}
+	a.sessionMu.Unlock()
+
+	return token, nil
+}
+
+// setSessionOfflineEvent links the session minted for token to its journaled offline login.
+func (a *App) setSessionOfflineEvent(token, eventUID string) {
+	a.sessionMu.Lock()
+	if a.session != nil && a.session.Token == token {
+		a.session.OfflineEventUID = eventUID
+	}
+	a.sessionMu.Unlock()
+}
+
+// clearSessionOfflineEvent marks the current session as online once its login has been
+// replayed, and links it to the login log the replay wrote so its heartbeat keeps that
+// log fresh.
+func (a *App) clearSessionOfflineEvent(eventUID string, loginLogID int) {
+	a.sessionMu.Lock()
+	if a.session != nil && a.session.OfflineEventUID == eventUID {
+		a.session.OfflineEventUID = ""
+		if loginLogID != 0 {
+			a.session.LoginLogID = loginLogID
+		}
+	}
+	a.sessionMu.Unlock()
+}
+
+// endSession discards the current session token.
+func (a *App) endSession() {
+	a.sessionMu.Lock()
+	a.session = nil
+	a.sessionMu.Unlock()
+}
+
+// currentSession returns a copy of the active session, or nil when nobody is logged in.
+func (a *App) currentSession() *appSession {
+	a.sessionMu.RLock()
+	defer a.sessionMu.RUnlock()
+	if a.session == nil {
+		return nil
+	}
+	session := *a.session
+	return &session
+}
+
+// ResumeSession re-binds the frontend to the backend session after a webview reload.
+// The token must match the one minted by Login in this process; tokens do not survive
+// an application restart, so remembered users are asked to log in again.
+func (a *App) ResumeSession(token string) error {
+	session := a.currentSession()
+	if session == nil || token == "" || subtle.ConstantTimeCompare([]byte(session.Token), []byte(token)) != 1 {
+		return fmt.Errorf("session expired - please log in again")
+	}
+	return nil
+}
+
+// requireRole authorizes a bound method that does not act on a specific user.
+func (a *App) requireRole(method string) (*appSession, error) {
+	return a.authorizeMethod(method, 0)
+}
+
+// requireActingUser authorizes a bound method whose user ID argument names the acting
+// or affected user. Roles in the policy's selfRoles must pass their own user ID.
+func (a *App) requireActingUser(method string, userID int) (*appSession, error) {
+	return a.authorizeMethod(method, userID)
+}
+
+// checkClassAccess limits teachers to their own classes and students to the classes they
+// are enrolled in; admins reach every class. Every class-scoped method checks it after
+// authorizing the role.
+func (a *App) checkClassAccess(session *appSession, classID int) error {
+	if session.Role == "admin" {
+		return nil
+	}
+	query := `SELECT COUNT(*) FROM classes WHERE class_id = ? AND teacher_id = ?`
+	if session.Role != "teacher" {
+		query = `
+			SELECT COUNT(*) FROM joined_classes
+			WHERE class_id = ? AND student_id = ? AND status IN ('join', 'added', 'active')`
+	}
+	var owned int
+	if err := a.db.QueryRow(query, classID, session.UserID).Scan(&owned); err != nil {
+		return err
+	}
+	if owned == 0 {
+		return fmt.Errorf("class not found or not authorized")
+	}
+	return nil
+}
+
+func (a *App) authorizeMethod(method string, userID int) (*appSession, error) {
+	policy, found := boundMethodAccessPolicies[method]
+	if !found {
+		log.Printf("ACCESS DENIED: %s has no access policy", method)
+		return nil, fmt.Errorf("access denied")
+	}
+
+	session := a.currentSession()
+	if session == nil {
+		log.Printf("ACCESS DENIED: %s called without an active session", method)
+		return nil, fmt.Errorf("not logged in - please log in again")
+	}
+
+	if containsRole(policy.roles, session.Role) {
+		return session, nil
+	}
+	if containsRole(policy.selfRoles, session.Role) && userID > 0 && userID == session.UserID {
+		return session, nil
+	}
+
+	log.Printf("ACCESS DENIED: %s called by user %d (%s) for user %d", method, session.UserID, session.Role, userID)
+	return nil, fmt.Errorf("you are not allowed to perform this action")
+}
+
+// MethodAccessPolicy describes which roles may call a bound method (for admin audit views).
+type MethodAccessPolicy struct {
+	Method    string   `json:"method"`
+	Roles     []string `json:"roles"`
+	SelfRoles []string `json:"self_roles"`
+}
+
+// GetMethodAccessPolicies returns the role policy table sorted by method name.
+func (a *App) GetMethodAccessPolicies() ([]MethodAccessPolicy, error) {
+	if _, err := a.requireRole("GetMethodAccessPolicies"); err != nil {
+		return nil, err
+	}
+
+	policies := make([]MethodAccessPolicy, 0, len(boundMethodAccessPolicies))
+	for method, policy := range boundMethodAccessPolicies {
+		policies = append(policies, MethodAccessPolicy{
+			Method:    method,
+			Roles:     append([]string{}, policy.roles...),
+			SelfRoles: append([]string{}, policy.selfRoles...),
+		})
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Method < policies[j].Method
+	})
+	return policies, nil
+}
@@ -0,0 +1,51 @@
+package backend
+
+import "testing"
+
+func TestTeachersOnlyReachTheirOwnClasses(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.seedUser("teacher", "T-0002", "Omar", "Tan")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT101", studentID)
+
+	e.loginAs("T-0002")
+	if err := e.app.UpdateClass(classID, "MWF 8:00", "Lab 2", "A", "1st", "2026-2027", true); err == nil {
+		t.Errorf("another teacher updated the class")
+	}
+	if err := e.app.CloseClass(classID); err == nil {
+		t.Errorf("another teacher closed the class")
+	}
+	if _, err := e.app.GetClassStudents(classID); err == nil {
+		t.Errorf("another teacher read the class list")
+	}
+	if _, err := e.app.GetClassByID(classID); err == nil {
+		t.Errorf("another teacher read the class")
+	}
+
+	e.loginAs("T-0001")
+	if students, err := e.app.GetClassStudents(classID); err != nil || len(students) != 1 {
+		t.Errorf("GetClassStudents = %d students, %v; want the class teacher to see 1", len(students), err)
+	}
+	if err := e.app.CloseClass(classID); err != nil {
+		t.Errorf("CloseClass by the class teacher: %v", err)
+	}
+	// Students only read the classes they are enrolled in.
+	otherClassID := e.seedClass(teacherID, "IT102")
+	e.loginAs("2024-00001")
+	if _, err := e.app.GetClassStudents(otherClassID); err == nil {
+		t.Errorf("a student read the roster of a class they are not in")
+	}
+	if _, err := e.app.GetClassByID(otherClassID); err == nil {
+		t.Errorf("a student read a class they are not in")
+	}
+	if _, err := e.app.GetClassByID(classID); err != nil {
+		t.Errorf("GetClassByID by an enrolled student: %v", err)
+	}
+
+	e.loginAs("T-0001")
+	// A user ID of 0 is not the caller's own.
+	if _, err := e.app.requireActingUser("DeleteClass", 0); err == nil {
+		t.Errorf("requireActingUser accepted user ID 0 for a self-only method")
+	}
+}
@@ -8,8 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"digital-logbook-wails-app/backend/storage"
+
 	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -25,11 +29,36 @@ type App struct {
 	screenLocked bool
 	computerLab  string
 	pcNumber     string
+	sessionMu    sync.RWMutex
+	session      *appSession
+	schemaReady  bool
+
+	// maintenancePINHash is the cached bcrypt hash of this lab's maintenance PIN.
+	maintenancePINHash string
+	// databaseConfigured is set once database settings have been saved or used here.
+	databaseConfigured bool
+
+	// passwordDenylistProblem is the last denylist error reported to admins ("" when none).
+	passwordDenylistProblem atomic.Value
+
+	// twoFactorChallenges holds logins waiting for a second factor, keyed by token.
+	twoFactorMu         sync.Mutex
+	twoFactorChallenges map[string]*twoFactorChallenge
+
+	offlineMu       sync.Mutex
+	offlineJournal  *sql.DB
+	offlineReplayMu sync.Mutex
+
+	clock Clock
 }
 
 // SetFeedbackAdminStatus updates the admin-facing workflow status for a feedback entry.
 // Valid statuses: "pending", "resolved".
 func (a *App) SetFeedbackAdminStatus(feedbackID int, adminUserID int, status string) error {
+	session, err := a.requireActingUser("SetFeedbackAdminStatus", adminUserID)
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -45,6 +74,15 @@ func (a *App) SetFeedbackAdminStatus(feedbackID int, adminUserID int, status str
 		return err
 	}
 
+	var previousStatus string
+	err = a.db.QueryRow(`SELECT COALESCE(admin_status, 'pending') FROM feedback WHERE id = ?`, feedbackID).Scan(&previousStatus)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("feedback not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read feedback: %w", err)
+	}
+
 	query := `
 		UPDATE feedback
 		SET admin_status = ?,
@@ -66,12 +104,14 @@ func (a *App) SetFeedbackAdminStatus(feedbackID int, adminUserID int, status str
 	}
 
 	log.Printf("SetFeedbackAdminStatus: feedback %d set to %s by admin %d", feedbackID, status, adminUserID)
+	a.audit(session, "set_feedback_admin_status", "feedback", feedbackID,
+		auditValues{"admin_status": previousStatus}, auditValues{"admin_status": status})
 	return nil
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return &App{clock: &serverClock{}}
 }
 
 // startup is called when the app starts
@@ -84,6 +124,8 @@ func (a *App) startup(ctx context.Context) {
 	a.screenLocked = appConfig.LockMode // Start locked if lock mode is on
 	a.computerLab = appConfig.ComputerLab
 	a.pcNumber = appConfig.PCNumber
+	a.databaseConfigured = appConfig.DatabaseConfigured
+	a.maintenancePINHash = a.loadCachedMaintenancePINHash(appConfig)
 
 	// Initialize database connection with retry
 	var db *sql.DB
@@ -108,42 +150,28 @@ func (a *App) startup(ctx context.Context) {
 	} else {
 		a.db = db
 		log.Println("Database connected successfully")
-		if err := a.ensureSessionHeartbeatTable(); err != nil {
-			log.Printf("Failed to ensure session heartbeat table: %v", err)
-		}
-		if err := a.ensureActivityTrackingColumns(); err != nil {
-			log.Printf("Failed to ensure activity tracking columns: %v", err)
-		}
-		if err := a.ensureAccountStatusConstraint(); err != nil {
-			log.Printf("Failed to ensure account status constraint: %v", err)
+		a.markDatabaseConfigured()
+		a.syncServerClock()
+		if err := a.runSchemaMigrations(); err != nil {
+			log.Printf("Failed to apply schema migrations: %v", err)
 		}
 		if err := a.closeStaleSessions(); err != nil {
 			log.Printf("Failed to close stale sessions on startup: %v", err)
 		}
+		a.refreshMaintenancePINCache()
+		a.passwordPolicy()
 		go a.startSessionCleanupLoop(ctx)
-		if err := a.ensureNotificationsTable(); err != nil {
-			log.Printf("Failed to ensure notifications table: %v", err)
-		}
-		if err := a.ensureUserRecoveryCodesTable(); err != nil {
-			log.Printf("Failed to ensure user recovery code table: %v", err)
-		}
-		if err := a.ensureJoinedClassesStatusVocabulary(); err != nil {
-			log.Printf("Failed to ensure joined_classes status vocabulary: %v", err)
-		}
-		if err := a.ensureFeedbackAdminResolvedAtColumn(); err != nil {
-			log.Printf("Failed to ensure feedback admin resolved timestamp column: %v", err)
-		}
-		if err := a.ensureFeedbackNoteColumns(); err != nil {
-			log.Printf("Failed to ensure feedback note columns: %v", err)
-		}
-		if err := a.ensureDepartmentArchiveColumn(); err != nil {
-			log.Printf("Failed to ensure department archive column: %v", err)
-		}
+		go a.startAttendanceSchedulerLoop(ctx)
 		if err := a.CleanOldNotifications(); err != nil {
 			log.Printf("Failed to clean old notifications: %v", err)
 		}
 	}
 
+	// Replays logins, time-ins and feedback journaled while the server was unreachable.
+	go a.startOfflineReplayLoop(ctx)
+	go a.startClockSyncLoop(ctx)
+	go a.startIdleLogoutLoop(ctx)
+
 	// If lock mode is on, force lock the screen on startup
 	// (using runtime API since Wails startup options alone aren't reliable)
 	if a.lockMode {
@@ -199,6 +227,9 @@ func (a *App) UnlockScreen() {
 		return
 	}
 	a.screenLocked = false
+	if a.ctx == nil {
+		return
+	}
 	wailsRuntime.WindowUnfullscreen(a.ctx)
 	wailsRuntime.WindowSetAlwaysOnTop(a.ctx, false)
 	wailsRuntime.WindowSetSize(a.ctx, 1000, 700)
@@ -213,12 +244,24 @@ func (a *App) LockScreen() {
 		return
 	}
 	a.screenLocked = true
+	if a.ctx == nil {
+		return
+	}
 	wailsRuntime.WindowSetAlwaysOnTop(a.ctx, true)
 	wailsRuntime.WindowMaximise(a.ctx)
 	wailsRuntime.WindowFullscreen(a.ctx)
 	log.Println("Screen locked - waiting for next user to login")
 }
 
+// emitEvent sends a Wails event to the frontend. It does nothing until startup has
+// handed the app its window context.
+func (a *App) emitEvent(name string, data ...interface{}) {
+	if a.ctx == nil {
+		return
+	}
+	wailsRuntime.EventsEmit(a.ctx, name, data...)
+}
+
 // IsLockMode returns whether lock mode is enabled (for frontend consumption)
 func (a *App) IsLockMode() bool {
 	return a.lockMode
@@ -230,15 +273,19 @@ type LockSettings struct {
 	ComputerLab  string `json:"computer_lab"`
 	PCNumber     string `json:"pc_number"`
 	StationLabel string `json:"station_label"`
+	// MaintenancePINSet reports whether this station's lab has a maintenance PIN.
+	MaintenancePINSet bool `json:"maintenance_pin_set"`
 }
 
 // DatabaseSetupSettings represents database configuration values editable from login settings.
 type DatabaseSetupSettings struct {
+	Driver       string `json:"driver"`
+	Path         string `json:"path"`
 	Host         string `json:"host"`
 	Port         string `json:"port"`
 	DBName       string `json:"dbname"`
 	Username     string `json:"username"`
-	Password     string `json:"password"`
+	PasswordSet  bool   `json:"password_set"`
 	SourcePath   string `json:"source_path"`
 	WritePath    string `json:"write_path"`
 	IsConfigured bool   `json:"is_configured"`
@@ -268,10 +315,11 @@ func (a *App) currentStationLabel() string {
 
 func (a *App) currentLockSettings() LockSettings {
 	return LockSettings{
-		LockMode:     a.lockMode,
-		ComputerLab:  strings.TrimSpace(a.computerLab),
-		PCNumber:     strings.TrimSpace(a.pcNumber),
-		StationLabel: a.currentStationLabel(),
+		LockMode:          a.lockMode,
+		ComputerLab:       strings.TrimSpace(a.computerLab),
+		PCNumber:          strings.TrimSpace(a.pcNumber),
+		StationLabel:      a.currentStationLabel(),
+		MaintenancePINSet: a.maintenancePINHash != "",
 	}
 }
 
@@ -289,44 +337,38 @@ func (a *App) GetLockSettings() LockSettings {
 }
 
 // SetLockMode updates lock mode at runtime and persists it for next launch.
-func (a *App) SetLockMode(enabled bool) error {
+// The change must be authorized by an admin or the lab's maintenance PIN.
+func (a *App) SetLockMode(auth StationAuthorization, enabled bool) error {
+	actor, err := a.authorizeStationChange(auth)
+	if err != nil {
+		return err
+	}
+
+	before := a.currentLockSettings()
+	if err := a.applyLockMode(enabled); err != nil {
+		return err
+	}
+	a.recordStationChange(actor, "update_lock_settings", lockSettingsAuditValues(before), lockSettingsAuditValues(a.currentLockSettings()))
+	return nil
+}
+
+func (a *App) applyLockMode(enabled bool) error {
 	previousLockMode := a.lockMode
 	previousScreenLocked := a.screenLocked
 
 	a.lockMode = enabled
+	a.screenLocked = enabled
+	a.applyLockWindowState(enabled)
 	if enabled {
-		a.screenLocked = true
-		wailsRuntime.WindowSetAlwaysOnTop(a.ctx, true)
-		wailsRuntime.WindowMaximise(a.ctx)
-		wailsRuntime.WindowFullscreen(a.ctx)
 		log.Println("Lock mode enabled via settings")
 	} else {
-		a.screenLocked = false
-		wailsRuntime.WindowUnfullscreen(a.ctx)
-		wailsRuntime.WindowSetAlwaysOnTop(a.ctx, false)
-		wailsRuntime.WindowSetSize(a.ctx, 1000, 700)
-		wailsRuntime.WindowCenter(a.ctx)
 		log.Println("Lock mode disabled via settings")
 	}
 
-	if err := SaveAppSettings(AppConfig{
-		LockMode:    enabled,
-		ComputerLab: strings.TrimSpace(a.computerLab),
-		PCNumber:    strings.TrimSpace(a.pcNumber),
-	}); err != nil {
+	if err := SaveAppSettings(a.currentAppConfig()); err != nil {
 		a.lockMode = previousLockMode
 		a.screenLocked = previousScreenLocked
-
-		if previousLockMode {
-			wailsRuntime.WindowSetAlwaysOnTop(a.ctx, true)
-			wailsRuntime.WindowMaximise(a.ctx)
-			wailsRuntime.WindowFullscreen(a.ctx)
-		} else {
-			wailsRuntime.WindowUnfullscreen(a.ctx)
-			wailsRuntime.WindowSetAlwaysOnTop(a.ctx, false)
-			wailsRuntime.WindowSetSize(a.ctx, 1000, 700)
-			wailsRuntime.WindowCenter(a.ctx)
-		}
+		a.applyLockWindowState(previousLockMode)
 
 		return fmt.Errorf("failed to save lock mode setting: %w", err)
 	}
@@ -334,6 +376,32 @@ func (a *App) SetLockMode(enabled bool) error {
 	return nil
 }
 
+// applyLockWindowState switches the window between the locked kiosk layout and a normal window.
+func (a *App) applyLockWindowState(locked bool) {
+	if a.ctx == nil {
+		return
+	}
+	if locked {
+		wailsRuntime.WindowSetAlwaysOnTop(a.ctx, true)
+		wailsRuntime.WindowMaximise(a.ctx)
+		wailsRuntime.WindowFullscreen(a.ctx)
+	} else {
+		wailsRuntime.WindowUnfullscreen(a.ctx)
+		wailsRuntime.WindowSetAlwaysOnTop(a.ctx, false)
+		wailsRuntime.WindowSetSize(a.ctx, 1000, 700)
+		wailsRuntime.WindowCenter(a.ctx)
+	}
+}
+
+func (a *App) currentAppConfig() AppConfig {
+	return AppConfig{
+		LockMode:           a.lockMode,
+		ComputerLab:        strings.TrimSpace(a.computerLab),
+		PCNumber:           strings.TrimSpace(a.pcNumber),
+		DatabaseConfigured: a.databaseConfigured,
+	}
+}
+
 func parseLockModeInput(input string) (bool, error) {
 	normalized := strings.ToLower(strings.TrimSpace(input))
 	if normalized == "" {
@@ -357,13 +425,13 @@ func parseLockModeInput(input string) (bool, error) {
 }
 
 // SetLockModeFromInput accepts values like "lockmode: true" or "false".
-func (a *App) SetLockModeFromInput(input string) (bool, error) {
+func (a *App) SetLockModeFromInput(auth StationAuthorization, input string) (bool, error) {
 	enabled, err := parseLockModeInput(input)
 	if err != nil {
 		return a.lockMode, err
 	}
 
-	if err := a.SetLockMode(enabled); err != nil {
+	if err := a.SetLockMode(auth, enabled); err != nil {
 		return a.lockMode, err
 	}
 
@@ -371,7 +439,13 @@ func (a *App) SetLockModeFromInput(input string) (bool, error) {
 }
 
 // SetLockSettingsFromInput updates lock mode and station identity in one operation.
-func (a *App) SetLockSettingsFromInput(lockInput, computerLab, pcNumber string) (LockSettings, error) {
+// The change must be authorized by an admin or the maintenance PIN of the current lab.
+func (a *App) SetLockSettingsFromInput(auth StationAuthorization, lockInput, computerLab, pcNumber string) (LockSettings, error) {
+	actor, err := a.authorizeStationChange(auth)
+	if err != nil {
+		return a.currentLockSettings(), err
+	}
+
 	enabled, err := parseLockModeInput(lockInput)
 	if err != nil {
 		return a.currentLockSettings(), err
@@ -387,21 +461,37 @@ func (a *App) SetLockSettingsFromInput(lockInput, computerLab, pcNumber string)
 		return a.currentLockSettings(), err
 	}
 
+	before := a.currentLockSettings()
 	previousLab := a.computerLab
 	previousPC := a.pcNumber
+	previousPINHash := a.maintenancePINHash
 	a.computerLab = sanitizedLab
 	a.pcNumber = sanitizedPC
+	if !strings.EqualFold(strings.TrimSpace(previousLab), sanitizedLab) {
+		// The cached PIN belongs to the old lab.
+		if hash, found := a.lookupMaintenancePINHash(sanitizedLab); found {
+			a.maintenancePINHash = hash
+		}
+	}
 
-	if err := a.SetLockMode(enabled); err != nil {
+	if err := a.applyLockMode(enabled); err != nil {
 		a.computerLab = previousLab
 		a.pcNumber = previousPC
+		a.maintenancePINHash = previousPINHash
 		return a.currentLockSettings(), err
 	}
 
-	return a.currentLockSettings(), nil
+	if a.maintenancePINHash != previousPINHash {
+		a.storeMaintenancePINHash()
+	}
+
+	after := a.currentLockSettings()
+	a.recordStationChange(actor, "update_lock_settings", lockSettingsAuditValues(before), lockSettingsAuditValues(after))
+	return after, nil
 }
 
 // GetDatabaseSetupSettings returns database config values and file path metadata for login-page setup.
+// It needs no login, so the password itself is never returned, only whether one is set.
 func (a *App) GetDatabaseSetupSettings() (DatabaseSetupSettings, error) {
 	config, sourcePath, configured, err := LoadDatabaseSettingsDraft()
 	if err != nil {
@@ -413,36 +503,73 @@ func (a *App) GetDatabaseSetupSettings() (DatabaseSetupSettings, error) {
 		writePath = ""
 	}
 
+	driver, err := storage.NormalizeDriver(config.Driver)
+	if err != nil {
+		driver = strings.TrimSpace(config.Driver)
+	}
+
 	return DatabaseSetupSettings{
+		Driver:       driver,
+		Path:         config.Path,
 		Host:         config.Host,
 		Port:         config.Port,
 		DBName:       config.DBName,
 		Username:     config.Username,
-		Password:     config.Password,
+		PasswordSet:  config.Password != "",
 		SourcePath:   sourcePath,
 		WritePath:    writePath,
 		IsConfigured: configured,
 	}, nil
 }
 
+// sameDatabaseServer reports whether b connects to the same server as a under the same
+// account, so the password saved for a may be kept for b.
+func sameDatabaseServer(a, b DBConfig) bool {
+	return strings.EqualFold(strings.TrimSpace(a.Host), strings.TrimSpace(b.Host)) &&
+		strings.TrimSpace(a.Port) == strings.TrimSpace(b.Port) &&
+		strings.TrimSpace(a.Username) == strings.TrimSpace(b.Username)
+}
+
 // SaveDatabaseSetupSettings persists database config.
 // In production it immediately validates by reconnecting; in development it saves without reconnect test.
-func (a *App) SaveDatabaseSetupSettings(host, port, dbname, username, password string) (DatabaseSetupSettings, error) {
+// The change must be authorized by an admin or the lab's maintenance PIN. An empty password
+// keeps the saved one, since the form is never given it, but only while the host, port
+// and username stay the same.
+func (a *App) SaveDatabaseSetupSettings(auth StationAuthorization, host, port, dbname, username, password string) (DatabaseSetupSettings, error) {
+	actor, err := a.authorizeStationChange(auth)
+	if err != nil {
+		return DatabaseSetupSettings{}, err
+	}
+
 	normalizedDBName := strings.TrimSpace(dbname)
 	if normalizedDBName == "" || !strings.EqualFold(normalizedDBName, fixedDatabaseName) {
 		normalizedDBName = fixedDatabaseName
 	}
 
-	savedPath, err := SaveDatabaseSettings(DBConfig{
-		Host:     host,
-		Port:     port,
-		DBName:   normalizedDBName,
-		Username: username,
-		Password: password,
-	})
+	// The login-page form only edits MySQL connection fields; keep driver/path as configured.
+	current, _, _, _ := LoadDatabaseSettingsDraft()
+
+	// TLS and key-file settings are only edited in config.ini and are kept as they are.
+	updated := current
+	updated.Host = host
+	updated.Port = port
+	updated.DBName = normalizedDBName
+	updated.Username = username
+	if password != "" {
+		updated.Password = password
+	} else if current.Password != "" && !sameDatabaseServer(current, updated) {
+		// The saved password must not follow the connection to another server.
+		return DatabaseSetupSettings{}, fmt.Errorf("re-enter the database password when changing the host, port or username")
+	}
+	savedPath, err := SaveDatabaseSettings(updated)
 	if err != nil {
 		return DatabaseSetupSettings{}, err
 	}
+	a.markDatabaseConfigured()
+
+	before := databaseSettingsAuditValues(current)
+	after := databaseSettingsAuditValues(DBConfig{Driver: current.Driver, Host: host, Port: port, DBName: normalizedDBName, Username: username})
+	after["password_changed"] = updated.Password != current.Password
 
 	settings, settingsErr := a.GetDatabaseSetupSettings()
 	if settingsErr != nil {
@@ -452,6 +579,7 @@ func (a *App) SaveDatabaseSetupSettings(host, port, dbname, username, password s
 
 	if GetRuntimeMode() == runtimeModeDevelopment {
 		log.Printf("Database settings saved in development mode at %s (reconnect test skipped)", savedPath)
+		a.recordStationChange(actor, "update_database_settings", before, after)
 		return settings, nil
 	}
 
@@ -463,6 +591,8 @@ func (a *App) SaveDatabaseSetupSettings(host, port, dbname, username, password s
 	}
 
 	reconnectErr := a.reconnectDB()
+	// Recorded after reconnecting so the event lands in the database now in use.
+	a.recordStationChange(actor, "update_database_settings", before, after)
 
 	if reconnectErr != nil {
 		return settings, fmt.Errorf("database settings were saved to %s, but connection test failed: %w", savedPath, reconnectErr)
@@ -491,7 +621,20 @@ type User struct {
 	PhotoURL       *string `json:"photo_url"` // Base64 data URL for frontend display
 	DepartmentCode *string `json:"department_code"`
 	Created        string  `json:"created"`
-	LoginLogID     int     `json:"login_log_id"` // Track the login session
+	LoginLogID     int     `json:"login_log_id"`            // Track the login session
+	SessionToken   string  `json:"session_token,omitempty"` // Opaque token minted by Login
+	Offline        bool    `json:"offline,omitempty"`       // Logged in from the offline credential cache
+	// Set instead of SessionToken when Login needs a second factor; pass the token to
+	// CompleteTwoFactorLogin.
+	TwoFactorRequired      bool   `json:"two_factor_required,omitempty"`
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required,omitempty"`
+	TwoFactorToken         string `json:"two_factor_token,omitempty"`
+	// Set when the password was chosen by an admin or is older than the maximum age;
+	// the user must change it before using the app.
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	PasswordExpired    bool `json:"password_expired,omitempty"`
+	// "local" or "ldap"; directory accounts change their password in the directory.
+	AuthSource string `json:"auth_source,omitempty"`
 	// Activity tracking fields (populated by GetUsersByActivityStatus)
 	LastLoginAt       *string `json:"last_login_at,omitempty"`   // ISO datetime of last login
 	LastLoginAgo      string  `json:"last_login_ago,omitempty"`  // Human-readable "2 months ago"
@@ -511,6 +654,9 @@ type LoginLog struct {
 	PCNumber     *string `json:"pc_number,omitempty"`
 	LoginTime    string  `json:"login_time"`
 	LogoutTime   *string `json:"logout_time,omitempty"`
+	// LogoutReason is "manual", "auto_logout" or "taken_over"; it is empty for sessions
+	// closed by the stale-heartbeat cleanup or an app shutdown.
+	LogoutReason *string `json:"logout_reason,omitempty"`
 }
 
 // Feedback represents equipment condition feedback from students
@@ -599,6 +745,9 @@ type AdminDashboard struct {
 
 // GetAdminDashboard returns admin dashboard statistics
 func (a *App) GetAdminDashboard() (AdminDashboard, error) {
+	if _, err := a.requireRole("GetAdminDashboard"); err != nil {
+		return AdminDashboard{}, err
+	}
 	var dashboard AdminDashboard
 
 	if err := a.checkDB(); err != nil {
@@ -821,32 +970,39 @@ type CourseClass struct {
 	CreatedAt            string  `json:"created_at"`
 	LatestAttendanceDate *string `json:"latest_attendance_date,omitempty"`
 	ClassStatus          string  `json:"class_status"`
+	// StructuredSchedule is only filled in by GetClassByID.
+	StructuredSchedule *ClassSchedule `json:"structured_schedule,omitempty"`
 }
 
 // Attendance represents an attendance record
 type Attendance struct {
-	ID             int     `json:"id"`
-	ClassID        int     `json:"class_id"`
-	SubjectCode    string  `json:"subject_code"`
-	SubjectName    string  `json:"subject_name"`
-	Section        string  `json:"section"`
-	Schedule       string  `json:"schedule"`
-	StudentUserID  int     `json:"student_user_id"`
-	StudentID      string  `json:"student_id"`
-	StudentCode    string  `json:"student_code"`
-	StudentName    string  `json:"student_name"`
-	FirstName      string  `json:"first_name"`
-	MiddleName     *string `json:"middle_name,omitempty"`
-	LastName       string  `json:"last_name"`
-	Date           string  `json:"date"`
-	AttendanceDate string  `json:"attendance_date"`
-	TimeIn         *string `json:"time_in,omitempty"`
-	Status         string  `json:"status"`
-	Remarks        *string `json:"remarks"`
-	RecordedBy     int     `json:"recorded_by"`
-	RecordedByName string  `json:"recorded_by_name"`
-	IsArchived     bool    `json:"is_archived"`
-	IsEditable     bool    `json:"is_editable"`
+	ID              int     `json:"id"`
+	ClassID         int     `json:"class_id"`
+	SubjectCode     string  `json:"subject_code"`
+	SubjectName     string  `json:"subject_name"`
+	Section         string  `json:"section"`
+	Schedule        string  `json:"schedule"`
+	StudentUserID   int     `json:"student_user_id"`
+	StudentID       string  `json:"student_id"`
+	StudentCode     string  `json:"student_code"`
+	StudentName     string  `json:"student_name"`
+	FirstName       string  `json:"first_name"`
+	MiddleName      *string `json:"middle_name,omitempty"`
+	LastName        string  `json:"last_name"`
+	Date            string  `json:"date"`
+	AttendanceDate  string  `json:"attendance_date"`
+	TimeIn          *string `json:"time_in,omitempty"`
+	TimeInStation   *string `json:"time_in_station,omitempty"`
+	TimeOut         *string `json:"time_out,omitempty"`
+	MinutesAttended *int    `json:"minutes_attended,omitempty"`
+	MinutesLate     *int    `json:"minutes_late,omitempty"`
+	Status          string  `json:"status"`
+	PresenceStatus  string  `json:"presence_status,omitempty"`
+	Remarks         *string `json:"remarks"`
+	RecordedBy      int     `json:"recorded_by"`
+	RecordedByName  string  `json:"recorded_by_name"`
+	IsArchived      bool    `json:"is_archived"`
+	IsEditable      bool    `json:"is_editable"`
 }
 
 // ==============================================================================
@@ -866,6 +1022,9 @@ type StudentDashboard struct {
 
 // GetStudentDashboard returns student dashboard data
 func (a *App) GetStudentDashboard(userID int) (StudentDashboard, error) {
+	if _, err := a.requireActingUser("GetStudentDashboard", userID); err != nil {
+		return StudentDashboard{}, err
+	}
 	var dashboard StudentDashboard
 
 	if err := a.checkDB(); err != nil {
@@ -875,9 +1034,7 @@ func (a *App) GetStudentDashboard(userID int) (StudentDashboard, error) {
 	if err := a.closeStaleSessions(); err != nil {
 		log.Printf("Failed to close stale sessions in student dashboard: %v", err)
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		log.Printf("Failed to ensure attendance sessions table in student dashboard: %v", err)
-	} else if err := a.closeExpiredAttendanceSessions(); err != nil {
+	if err := a.closeExpiredAttendanceSessions(); err != nil {
 		log.Printf("Failed to close expired attendance sessions in student dashboard: %v", err)
 	}
 
@@ -942,7 +1099,7 @@ func (a *App) GetStudentDashboard(userID int) (StudentDashboard, error) {
 		}
 
 		// Check if this is today's record
-		if att.Date == time.Now().Format("2006-01-02") && dashboard.TodayLog == nil {
+		if att.Date == a.today() && dashboard.TodayLog == nil {
 			dashboard.TodayLog = &att
 		}
 	}
@@ -1092,6 +1249,9 @@ func hasEquipmentIssue(equipment, monitor, keyboard, mouse string) bool {
 
 // GetWorkingStudentDashboard returns working student dashboard data
 func (a *App) GetWorkingStudentDashboard() (WorkingStudentDashboard, error) {
+	if _, err := a.requireRole("GetWorkingStudentDashboard"); err != nil {
+		return WorkingStudentDashboard{}, err
+	}
 	var dashboard WorkingStudentDashboard
 
 	if err := a.checkDB(); err != nil {
@@ -1188,6 +1348,9 @@ func (a *App) GetWorkingStudentDashboard() (WorkingStudentDashboard, error) {
 // UpdateUserPhoto updates a user's profile photo
 // Accepts a base64 data URL (e.g. "data:image/jpeg;base64,...") and stores it directly in the database.
 func (a *App) UpdateUserPhoto(userID int, userRole, photoURL string) error {
+	if _, err := a.requireActingUser("UpdateUserPhoto", userID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
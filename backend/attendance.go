@@ -29,6 +29,9 @@ func normalizeAttendanceRemark(status string, remarks sql.NullString) *string {
 	case "absent":
 		defaultRemark := "Absent"
 		return &defaultRemark
+	case "excused":
+		defaultRemark := "Excused"
+		return &defaultRemark
 	default:
 		return nil
 	}
@@ -67,6 +70,26 @@ func (a *App) buildAttendanceSessionOpenMessage(classID int, sessionName string)
 	return fmt.Sprintf("Attendance session opened for %s (%s). %s.", classLabel, sessionName, scheduleLabel)
 }
 
+// notifyAttendanceSessionOpened tells the students enrolled in a class that they can time in.
+func (a *App) notifyAttendanceSessionOpened(classID, sessionID int, sessionName string) {
+	message := a.buildAttendanceSessionOpenMessage(classID, sessionName)
+	studentRows, qErr := a.db.Query(
+		`SELECT student_id FROM joined_classes WHERE class_id = ? AND status IN ('join', 'added', 'active') AND (is_archived = 0 OR is_archived IS NULL)`, classID)
+	if qErr != nil || studentRows == nil {
+		return
+	}
+	defer studentRows.Close()
+	for studentRows.Next() {
+		var sid int
+		if studentRows.Scan(&sid) == nil {
+			a.createNotification(sid, "attendance",
+				"Attendance Session Open",
+				message,
+				"info", notifRef("attendance_session"), notifRefID(sessionID))
+		}
+	}
+}
+
 // ==============================================================================
 // ATTENDANCE MANAGEMENT
 // ==============================================================================
@@ -76,12 +99,19 @@ func (a *App) buildAttendanceSessionOpenMessage(classID int, sessionName string)
 // Rule: attendance is auto-created (default status='absent') for all active students.
 // Returns the attendance records for the given date.
 func (a *App) OpenClassAttendance(classID int, date string) ([]Attendance, error) {
+	session, err := a.requireRole("OpenClassAttendance")
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
 
 	// Validate date format
-	_, err := time.Parse("2006-01-02", date)
+	_, err = time.Parse("2006-01-02", date)
 	if err != nil {
 		return nil, fmt.Errorf("invalid date format: %w", err)
 	}
@@ -94,14 +124,12 @@ func (a *App) OpenClassAttendance(classID int, date string) ([]Attendance, error
 		return nil, fmt.Errorf("class not found")
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 
 	// For today: ensure an open attendance_sessions row exists so enrolled students
 	// see "Attendance Today" and the Time In button on their dashboard.
 	if date == today && classIsActive && !isArchived {
-		if err := a.ensureAttendanceSessionsTable(); err != nil {
-			log.Printf("Failed to ensure attendance sessions table in OpenClassAttendance: %v", err)
-		} else if err := a.closeExpiredAttendanceSessions(); err != nil {
+		if err := a.closeExpiredAttendanceSessions(); err != nil {
 			log.Printf("Failed to close expired sessions in OpenClassAttendance: %v", err)
 		} else {
 			var openSessionID int
@@ -116,6 +144,13 @@ func (a *App) OpenClassAttendance(classID int, date string) ([]Attendance, error
 					log.Printf("Failed to ensure attendance rows for existing session %d: %v", openSessionID, ensureErr)
 				}
 			case sql.ErrNoRows:
+				// A meeting scheduled for now is opened with its own duration and grace period.
+				if opened, schedErr := a.openDueClassMeetings(classID); schedErr != nil {
+					log.Printf("Failed to open scheduled meeting in OpenClassAttendance: %v", schedErr)
+				} else if opened > 0 {
+					break
+				}
+
 				// No open session: create one so students get Time In
 				var teacherID int
 				if errTeacher := a.db.QueryRow(`SELECT teacher_id FROM classes WHERE class_id = ?`, classID).Scan(&teacherID); errTeacher != nil {
@@ -125,9 +160,9 @@ func (a *App) OpenClassAttendance(classID int, date string) ([]Attendance, error
 				var newSessionID int
 				insertResult, insertErr := a.db.Exec(`
 					INSERT INTO attendance_sessions
-					(class_id, attendance_date, session_name, status, class_duration_minutes, grace_period_minutes, opened_at, created_by_user_id, created_at, updated_at)
-					VALUES (?, ?, ?, 'open', 90, 10, CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-				`, classID, date, sessionName, teacherID)
+					(class_id, attendance_date, session_name, status, class_duration_minutes, grace_period_minutes, opened_at, code_rotation_seconds, created_by_user_id, created_at, updated_at)
+					VALUES (?, ?, ?, 'open', 90, 10, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+				`, classID, date, sessionName, a.now(), loadTimeInCodeSeconds(), teacherID)
 				if insertErr != nil {
 					log.Printf("Failed to create open session when teacher opens attendance: %v", insertErr)
 				} else {
@@ -144,24 +179,7 @@ func (a *App) OpenClassAttendance(classID int, date string) ([]Attendance, error
 					log.Printf("Auto-created open attendance session %d for class %d on %s (students can time in)", newSessionID, classID, date)
 
 					// Notify enrolled students about the opened session
-					go func(cID, sID int, sName string) {
-						message := a.buildAttendanceSessionOpenMessage(cID, sName)
-						studentRows, qErr := a.db.Query(
-							`SELECT student_id FROM joined_classes WHERE class_id = ? AND status IN ('join', 'added', 'active') AND (is_archived = 0 OR is_archived IS NULL)`, cID)
-						if qErr != nil || studentRows == nil {
-							return
-						}
-						defer studentRows.Close()
-						for studentRows.Next() {
-							var sid int
-							if studentRows.Scan(&sid) == nil {
-								a.createNotification(sid, "attendance",
-									"Attendance Session Open",
-									message,
-									"info", notifRef("attendance_session"), notifRefID(sID))
-							}
-						}
-					}(classID, newSessionID, sessionName)
+					go a.notifyAttendanceSessionOpened(classID, newSessionID, sessionName)
 				}
 			}
 		}
@@ -174,14 +192,18 @@ func (a *App) OpenClassAttendance(classID int, date string) ([]Attendance, error
 // GetClassAttendance gets attendance records for a specific class on a specific date.
 // Adds is_editable field: true only if date == TODAY and class is not archived.
 func (a *App) GetClassAttendance(classID int, date string) ([]Attendance, error) {
+	session, err := a.requireRole("GetClassAttendance")
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
+	if err := a.checkClassAccess(session, classID); err != nil {
 		return nil, err
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 
 	query := `
 		SELECT 
@@ -195,7 +217,7 @@ func (a *App) GetClassAttendance(classID int, date string) ([]Attendance, error)
 			s.subject_code,
 			s.description as subject_name,
 			CASE
-				WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') THEN (
+				WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') OR a.time_in_at IS NOT NULL THEN (
 					COALESCE(
 						DATE_FORMAT(a.time_in_at, '%H:%i:%s'),
 						(
@@ -211,7 +233,8 @@ func (a *App) GetClassAttendance(classID int, date string) ([]Attendance, error)
 			END as time_in,
 			a.status,
 			a.remarks,
-			COALESCE(a.is_archived, 0) as is_archived
+			COALESCE(a.is_archived, 0) as is_archived,
+			a.time_in_station
 		FROM attendance a
 		JOIN students stu ON a.student_id = stu.id
 		JOIN classes c ON a.class_id = c.class_id
@@ -230,14 +253,14 @@ func (a *App) GetClassAttendance(classID int, date string) ([]Attendance, error)
 	var attendances []Attendance
 	for rows.Next() {
 		var att Attendance
-		var middleName, remarks, status, timeIn sql.NullString
+		var middleName, remarks, status, timeIn, timeInStation sql.NullString
 		var isArchived bool
 
 		err := rows.Scan(
 			&att.ClassID, &att.StudentUserID, &att.Date,
 			&att.StudentCode, &att.FirstName, &middleName, &att.LastName,
 			&att.SubjectCode, &att.SubjectName,
-			&timeIn, &status, &remarks, &isArchived,
+			&timeIn, &status, &remarks, &isArchived, &timeInStation,
 		)
 		if err != nil {
 			log.Printf("Failed to scan attendance row: %v", err)
@@ -254,6 +277,9 @@ func (a *App) GetClassAttendance(classID int, date string) ([]Attendance, error)
 		if timeIn.Valid {
 			att.TimeIn = &timeIn.String
 		}
+		if timeInStation.Valid {
+			att.TimeInStation = &timeInStation.String
+		}
 		att.IsArchived = isArchived
 		// CRITICAL: editable only if date is TODAY and not archived
 		att.IsEditable = (date == today) && !isArchived
@@ -264,20 +290,25 @@ func (a *App) GetClassAttendance(classID int, date string) ([]Attendance, error)
 	return attendances, nil
 }
 
-// UpdateAttendanceRecord updates a specific attendance record.
-// CRITICAL RULE: Only allows updates if attendance_date == TODAY.
-func (a *App) UpdateAttendanceRecord(classID, studentUserID int, date, status, remarks string) error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-
-	return fmt.Errorf("manual attendance editing is disabled. Attendance remarks are set automatically from Time In and session close")
-}
-
 func (a *App) GetSessionAttendance(sessionID int, teacherUserID int) ([]Attendance, error) {
+	session, err := a.requireActingUser("GetSessionAttendance", teacherUserID)
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
+	var classID int
+	err = a.db.QueryRow(`SELECT class_id FROM attendance_sessions WHERE session_id = ?`, sessionID).Scan(&classID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
 
 	query := `
 		SELECT 
@@ -291,18 +322,25 @@ func (a *App) GetSessionAttendance(sessionID int, teacherUserID int) ([]Attendan
 			c.subject_code,
 			s.description as subject_name,
 			CASE
-				WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') THEN (
+				WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') OR a.time_in_at IS NOT NULL THEN (
 					COALESCE(DATE_FORMAT(a.time_in_at, '%H:%i:%s'), DATE_FORMAT(a.updated_at, '%H:%i:%s'))
 				)
 				ELSE NULL
 			END as time_in,
 			a.status,
 			a.remarks,
-			COALESCE(a.is_archived, 0) as is_archived
+			COALESCE(a.is_archived, 0) as is_archived,
+			a.time_in_station,
+			DATE_FORMAT(a.time_out_at, '%H:%i:%s') as time_out,
+			a.minutes_attended,
+			a.present_since IS NOT NULL as still_present,
+			ses.class_duration_minutes,
+			a.minutes_late
 		FROM attendance a
 		JOIN students stu ON a.student_id = stu.id
 		JOIN classes c ON a.class_id = c.class_id
 		JOIN subjects s ON c.subject_code = s.subject_code
+		LEFT JOIN attendance_sessions ses ON ses.session_id = a.session_id
 		WHERE a.session_id = ?
 		ORDER BY stu.last_name, stu.first_name
 	`
@@ -313,17 +351,20 @@ func (a *App) GetSessionAttendance(sessionID int, teacherUserID int) ([]Attendan
 	}
 	defer rows.Close()
 
+	presencePolicy := loadMinimumPresencePolicy()
 	var attendances []Attendance
 	for rows.Next() {
 		var att Attendance
-		var middleName, remarks, status, timeIn sql.NullString
-		var isArchived bool
+		var middleName, remarks, status, timeIn, timeInStation, timeOut sql.NullString
+		var minutesAttended, classDuration, minutesLate sql.NullInt64
+		var isArchived, stillPresent bool
 
 		err := rows.Scan(
 			&att.ClassID, &att.StudentUserID, &att.Date,
 			&att.StudentCode, &att.FirstName, &middleName, &att.LastName,
 			&att.SubjectCode, &att.SubjectName,
-			&timeIn, &status, &remarks, &isArchived,
+			&timeIn, &status, &remarks, &isArchived, &timeInStation,
+			&timeOut, &minutesAttended, &stillPresent, &classDuration, &minutesLate,
 		)
 		if err != nil {
 			log.Printf("Failed to scan session attendance row: %v", err)
@@ -340,6 +381,11 @@ func (a *App) GetSessionAttendance(sessionID int, teacherUserID int) ([]Attendan
 		if timeIn.Valid {
 			att.TimeIn = &timeIn.String
 		}
+		if timeInStation.Valid {
+			att.TimeInStation = &timeInStation.String
+		}
+		applyAttendanceTimeOut(&att, timeOut, minutesAttended, classDuration, stillPresent, presencePolicy)
+		att.MinutesLate = minutesLateValue(minutesLate)
 		att.IsArchived = isArchived
 		att.IsEditable = false
 
@@ -436,16 +482,36 @@ func buildAttendanceExportRows(attendances []Attendance) [][]string {
 			timeInValue = strings.TrimSpace(*att.TimeIn)
 		}
 
+		timeOutValue := ""
+		if att.TimeOut != nil && strings.TrimSpace(*att.TimeOut) != "" {
+			timeOutValue = strings.TrimSpace(*att.TimeOut)
+		}
+
+		minutesValue := ""
+		if att.MinutesAttended != nil {
+			minutesValue = fmt.Sprintf("%d", *att.MinutesAttended)
+		}
+
+		// Minutes late only mean something for students who did not make the present tier.
+		minutesLateValue := ""
+		if att.MinutesLate != nil && !strings.EqualFold(strings.TrimSpace(att.Status), "present") {
+			minutesLateValue = fmt.Sprintf("%d", *att.MinutesLate)
+		}
+
 		remarksValue := ""
 		if att.Remarks != nil && strings.TrimSpace(*att.Remarks) != "" {
 			remarksValue = strings.TrimSpace(*att.Remarks)
 		}
 
+		// REMARKS stays last; change markers are appended to it.
 		exportRows = append(exportRows, []string{
 			fmt.Sprintf("%d", index+1),
 			att.StudentCode,
 			buildAttendanceExportName(att),
 			timeInValue,
+			timeOutValue,
+			minutesValue,
+			minutesLateValue,
 			remarksValue,
 		})
 	}
@@ -453,6 +519,24 @@ func buildAttendanceExportRows(attendances []Attendance) [][]string {
 	return exportRows
 }
 
+// buildAttendanceStatusFooter counts the sheet's records by status.
+func buildAttendanceStatusFooter(attendances []Attendance) []printableExportField {
+	counts := map[string]int{}
+	for _, att := range attendances {
+		counts[strings.ToLower(strings.TrimSpace(att.Status))]++
+	}
+	footer := []printableExportField{
+		{Label: "Present", Value: fmt.Sprintf("%d", counts["present"])},
+		{Label: "Late", Value: fmt.Sprintf("%d", counts["late"])},
+		{Label: "Absent", Value: fmt.Sprintf("%d", counts["absent"])},
+		{Label: "Excused", Value: fmt.Sprintf("%d", counts[attendanceStatusExcused])},
+	}
+	if counts[""] > 0 {
+		footer = append(footer, printableExportField{Label: "Unmarked", Value: fmt.Sprintf("%d", counts[""])})
+	}
+	return footer
+}
+
 func buildAttendancePrintableDocument(title string, classInfo attendanceExportClassInfo, date string, attendances []Attendance) printableExportDocument {
 	exportRows := buildAttendanceExportRows(attendances)
 
@@ -468,11 +552,12 @@ func buildAttendancePrintableDocument(title string, classInfo attendanceExportCl
 		},
 		// Match section/title text and column labels with the UI
 		TableNote:        "",
-		Headers:          []string{"NO.", "STUDENT ID", "STUDENT NAME", "TIME IN", "REMARKS"},
+		Headers:          []string{"NO.", "STUDENT ID", "STUDENT NAME", "TIME IN", "TIME OUT", "MINS", "MIN LATE", "REMARKS"},
 		Rows:             exportRows,
-		Footer:           nil,
-		ColumnWidths:     []float64{12, 28, 90, 24, 36},
-		ColumnAlignments: []string{"C", "L", "L", "C", "L"},
+		Footer:           buildAttendanceStatusFooter(attendances),
+		FooterInline:     true,
+		ColumnWidths:     []float64{12, 26, 50, 20, 20, 14, 16, 32},
+		ColumnAlignments: []string{"C", "L", "L", "C", "C", "C", "C", "L"},
 		Orientation:      "P",
 		GeneratedAt:      time.Now(),
 	}
@@ -485,11 +570,13 @@ func (a *App) queryAttendanceExportRecords(query string, args ...interface{}) ([
 	}
 	defer rows.Close()
 
+	presencePolicy := loadMinimumPresencePolicy()
 	attendances := make([]Attendance, 0)
 	for rows.Next() {
 		var att Attendance
-		var middleName, remarks, status, timeIn sql.NullString
-		var isArchived bool
+		var middleName, remarks, status, timeIn, timeOut sql.NullString
+		var minutesAttended, classDuration, minutesLate sql.NullInt64
+		var isArchived, stillPresent bool
 
 		err := rows.Scan(
 			&att.ClassID,
@@ -505,6 +592,11 @@ func (a *App) queryAttendanceExportRecords(query string, args ...interface{}) ([
 			&status,
 			&remarks,
 			&isArchived,
+			&timeOut,
+			&minutesAttended,
+			&stillPresent,
+			&classDuration,
+			&minutesLate,
 		)
 		if err != nil {
 			log.Printf("Failed to scan attendance export row: %v", err)
@@ -518,12 +610,21 @@ func (a *App) queryAttendanceExportRecords(query string, args ...interface{}) ([
 			att.Status = strings.TrimSpace(status.String)
 		}
 		att.Remarks = normalizeAttendanceRemark(att.Status, remarks)
+		if att.Remarks == nil && att.Status == "" {
+			// Left by a pending or review finalization policy; say so instead of a blank.
+			unmarked := "Unmarked"
+			att.Remarks = &unmarked
+		}
 		if timeIn.Valid {
 			trimmedTimeIn := strings.TrimSpace(timeIn.String)
 			if trimmedTimeIn != "" {
 				att.TimeIn = &trimmedTimeIn
 			}
 		}
+		if shortRemark := applyAttendanceTimeOut(&att, timeOut, minutesAttended, classDuration, stillPresent, presencePolicy); shortRemark != "" {
+			att.Remarks = &shortRemark
+		}
+		att.MinutesLate = minutesLateValue(minutesLate)
 		att.IsArchived = isArchived
 
 		attendances = append(attendances, att)
@@ -551,7 +652,7 @@ func (a *App) getAttendanceExportRecords(classID int, date string, sessionID int
 				c.subject_code,
 				s.description as subject_name,
 				CASE
-					WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') THEN (
+					WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') OR a.time_in_at IS NOT NULL THEN (
 						COALESCE(
 							DATE_FORMAT(a.time_in_at, '%H:%i:%s'),
 							DATE_FORMAT(a.updated_at, '%H:%i:%s')
@@ -561,11 +662,17 @@ func (a *App) getAttendanceExportRecords(classID int, date string, sessionID int
 				END as time_in,
 				a.status,
 				a.remarks,
-				COALESCE(a.is_archived, 0) as is_archived
+				COALESCE(a.is_archived, 0) as is_archived,
+				DATE_FORMAT(a.time_out_at, '%H:%i:%s') as time_out,
+				a.minutes_attended,
+				a.present_since IS NOT NULL as still_present,
+				ses.class_duration_minutes,
+				a.minutes_late
 			FROM attendance a
 			JOIN students stu ON a.student_id = stu.id
 			JOIN classes c ON a.class_id = c.class_id
 			JOIN subjects s ON c.subject_code = s.subject_code
+			LEFT JOIN attendance_sessions ses ON ses.session_id = a.session_id
 			WHERE a.session_id = ?
 				AND COALESCE(a.is_archived, 0) = ?
 			ORDER BY stu.last_name, stu.first_name
@@ -586,7 +693,7 @@ func (a *App) getAttendanceExportRecords(classID int, date string, sessionID int
 			c.subject_code,
 			s.description as subject_name,
 			CASE
-				WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') THEN (
+				WHEN LOWER(LTRIM(RTRIM(IFNULL(a.status, '')))) IN ('present', 'late') OR a.time_in_at IS NOT NULL THEN (
 					COALESCE(
 						DATE_FORMAT(a.time_in_at, '%H:%i:%s'),
 						(
@@ -602,11 +709,17 @@ func (a *App) getAttendanceExportRecords(classID int, date string, sessionID int
 			END as time_in,
 			a.status,
 			a.remarks,
-			COALESCE(a.is_archived, 0) as is_archived
+			COALESCE(a.is_archived, 0) as is_archived,
+			DATE_FORMAT(a.time_out_at, '%H:%i:%s') as time_out,
+			a.minutes_attended,
+			a.present_since IS NOT NULL as still_present,
+			ses.class_duration_minutes,
+			a.minutes_late
 		FROM attendance a
 		JOIN students stu ON a.student_id = stu.id
 		JOIN classes c ON a.class_id = c.class_id
 		JOIN subjects s ON c.subject_code = s.subject_code
+		LEFT JOIN attendance_sessions ses ON ses.session_id = a.session_id
 		WHERE a.class_id = ?
 			AND a.attendance_date = ?
 			AND COALESCE(a.is_archived, 0) = ?
@@ -616,10 +729,13 @@ func (a *App) getAttendanceExportRecords(classID int, date string, sessionID int
 	return a.queryAttendanceExportRecords(query, classID, date, archiveFlag)
 }
 
-func (a *App) exportAttendanceDocument(classID int, date string, sessionID int, archivedOnly bool, format string, savePath string) (string, error) {
+func (a *App) exportAttendanceDocument(session *appSession, classID int, date string, sessionID int, archivedOnly, markChanges bool, format string, savePath string) (string, error) {
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return "", err
+	}
 
 	if _, err := time.Parse("2006-01-02", date); err != nil {
 		return "", fmt.Errorf("invalid date format: %w", err)
@@ -636,6 +752,11 @@ func (a *App) exportAttendanceDocument(classID int, date string, sessionID int,
 		title = "Attendance Sheet (Archived)"
 	}
 	doc := buildAttendancePrintableDocument(title, classInfo, date, attendances)
+	if markChanges && sessionID > 0 {
+		if err := a.applyAttendanceChangeMarkers(&doc, sessionID, attendances); err != nil {
+			return "", err
+		}
+	}
 
 	filePrefix := "attendance"
 	if archivedOnly {
@@ -672,7 +793,11 @@ func (a *App) exportAttendanceDocument(classID int, date string, sessionID int,
 // ExportAttendanceCSVByDate exports attendance to CSV for a specific class and date.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportAttendanceCSVByDate(classID int, date string, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, 0, false, "csv", savePath)
+	session, err := a.requireRole("ExportAttendanceCSVByDate")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, 0, false, false, "csv", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -683,7 +808,11 @@ func (a *App) ExportAttendanceCSVByDate(classID int, date string, savePath strin
 // ExportAttendancePDFByDate exports attendance to PDF for a specific class and date.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportAttendancePDFByDate(classID int, date string, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, 0, false, "pdf", savePath)
+	session, err := a.requireRole("ExportAttendancePDFByDate")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, 0, false, false, "pdf", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -694,7 +823,11 @@ func (a *App) ExportAttendancePDFByDate(classID int, date string, savePath strin
 // ExportAttendanceDOCXByDate exports attendance to DOCX for a specific class and date.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportAttendanceDOCXByDate(classID int, date string, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, 0, false, "docx", savePath)
+	session, err := a.requireRole("ExportAttendanceDOCXByDate")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, 0, false, false, "docx", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -702,7 +835,11 @@ func (a *App) ExportAttendanceDOCXByDate(classID int, date string, savePath stri
 	return filename, nil
 }
 func (a *App) ExportAttendanceCSVBySession(classID int, date string, sessionID int, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, sessionID, false, "csv", savePath)
+	session, err := a.requireRole("ExportAttendanceCSVBySession")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, sessionID, false, false, "csv", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -710,8 +847,14 @@ func (a *App) ExportAttendanceCSVBySession(classID int, date string, sessionID i
 	return filename, nil
 }
 
-func (a *App) ExportAttendancePDFBySession(classID int, date string, sessionID int, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, sessionID, false, "pdf", savePath)
+// ExportAttendancePDFBySession exports one attendance session to PDF. When includeChanges is set,
+// rows that were changed by hand are marked and their change history is listed below the table.
+func (a *App) ExportAttendancePDFBySession(classID int, date string, sessionID int, includeChanges bool, savePath string) (string, error) {
+	session, err := a.requireRole("ExportAttendancePDFBySession")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, sessionID, false, includeChanges, "pdf", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -720,7 +863,11 @@ func (a *App) ExportAttendancePDFBySession(classID int, date string, sessionID i
 }
 
 func (a *App) ExportAttendanceDOCXBySession(classID int, date string, sessionID int, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, sessionID, false, "docx", savePath)
+	session, err := a.requireRole("ExportAttendanceDOCXBySession")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, sessionID, false, false, "docx", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -728,7 +875,11 @@ func (a *App) ExportAttendanceDOCXBySession(classID int, date string, sessionID
 	return filename, nil
 }
 func (a *App) ExportArchivedAttendanceCSVByDate(classID int, date string, sessionID int, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, sessionID, true, "csv", savePath)
+	session, err := a.requireRole("ExportArchivedAttendanceCSVByDate")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, sessionID, true, false, "csv", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -739,7 +890,11 @@ func (a *App) ExportArchivedAttendanceCSVByDate(classID int, date string, sessio
 // ExportArchivedAttendancePDFByDate exports only archived attendance records for a specific class/date/session.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedAttendancePDFByDate(classID int, date string, sessionID int, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, sessionID, true, "pdf", savePath)
+	session, err := a.requireRole("ExportArchivedAttendancePDFByDate")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, sessionID, true, false, "pdf", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -750,7 +905,11 @@ func (a *App) ExportArchivedAttendancePDFByDate(classID int, date string, sessio
 // ExportArchivedAttendanceDOCXByDate exports archived attendance records for a specific class/date/session to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedAttendanceDOCXByDate(classID int, date string, sessionID int, savePath string) (string, error) {
-	filename, err := a.exportAttendanceDocument(classID, date, sessionID, true, "docx", savePath)
+	session, err := a.requireRole("ExportArchivedAttendanceDOCXByDate")
+	if err != nil {
+		return "", err
+	}
+	filename, err := a.exportAttendanceDocument(session, classID, date, sessionID, true, false, "docx", savePath)
 	if err != nil {
 		return "", err
 	}
@@ -769,16 +928,19 @@ func (a *App) ExportArchivedAttendanceDOCXByDate(classID int, date string, sessi
 // - Today's attendance can be archived only if its session is closed.
 // - Future attendance cannot be archived.
 func (a *App) ArchiveAttendanceSheet(classID int, date string) error {
+	session, err := a.requireRole("ArchiveAttendanceSheet")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
 	}
 
 	// Enforce archive rules
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 	if date > today {
 		return fmt.Errorf("cannot archive future attendance")
 	}
@@ -829,17 +991,17 @@ func (a *App) ArchiveAttendanceSheet(classID int, date string) error {
 // ArchiveAttendanceSession marks attendance records for a specific session as archived.
 // This allows archiving one saved attendance row at a time even if there are other sessions on the same date.
 func (a *App) ArchiveAttendanceSession(sessionID int, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	session, err := a.requireActingUser("ArchiveAttendanceSession", teacherUserID)
+	if err != nil {
 		return err
 	}
-
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkDB(); err != nil {
+		return err
 	}
 
 	var attendanceDate string
 	var sessionStatus string
-	err := a.db.QueryRow(`
+	err = a.db.QueryRow(`
 		SELECT
 			DATE_FORMAT(s.attendance_date, '%Y-%m-%d') AS attendance_date,
 			s.status
@@ -854,7 +1016,7 @@ func (a *App) ArchiveAttendanceSession(sessionID int, teacherUserID int) error {
 		return err
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 	if attendanceDate > today {
 		return fmt.Errorf("cannot archive future attendance")
 	}
@@ -885,21 +1047,23 @@ func (a *App) ArchiveAttendanceSession(sessionID int, teacherUserID int) error {
 	}
 
 	log.Printf("Archived attendance session: session_id=%d, records=%d", sessionID, rowsAffected)
+	a.audit(session, "archive_attendance_session", "attendance_session", sessionID,
+		auditValues{"is_archived": false}, auditValues{"is_archived": true, "attendance_date": attendanceDate, "records": rowsAffected})
 	return nil
 }
 
 // DeleteAttendanceSession soft-deletes one session and hides its attendance rows.
 func (a *App) DeleteAttendanceSession(sessionID int, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	session, err := a.requireActingUser("DeleteAttendanceSession", teacherUserID)
+	if err != nil {
 		return err
 	}
-
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkDB(); err != nil {
+		return err
 	}
 
 	var classArchived int
-	err := a.db.QueryRow(`
+	err = a.db.QueryRow(`
 SELECT COALESCE(c.is_archived, 0)
 FROM attendance_sessions s
 JOIN classes c ON s.class_id = c.class_id
@@ -946,18 +1110,19 @@ WHERE session_id = ?
 		return fmt.Errorf("session not found or not authorized")
 	}
 	log.Printf("Soft-deleted attendance session: session_id=%d deleted_by=%d", sessionID, teacherUserID)
+	a.audit(session, "delete_attendance_session", "attendance_session", sessionID,
+		auditValues{"is_deleted": false}, auditValues{"is_deleted": true})
 	return nil
 }
 
 // UnarchiveAttendanceSession removes the archived flag from one specific attendance session.
 // This is used when there are multiple sessions on the same class/date and only one should be restored.
 func (a *App) UnarchiveAttendanceSession(sessionID int, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("UnarchiveAttendanceSession", teacherUserID); err != nil {
 		return err
 	}
-
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkDB(); err != nil {
+		return err
 	}
 
 	var classID int
@@ -1018,6 +1183,9 @@ type ArchivedAttendanceSheet struct {
 
 // GetArchivedAttendanceSheets gets all archived attendance sheets for a teacher
 func (a *App) GetArchivedAttendanceSheets(teacherUserID int) ([]ArchivedAttendanceSheet, error) {
+	if _, err := a.requireActingUser("GetArchivedAttendanceSheets", teacherUserID); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -1115,14 +1283,14 @@ type AttendanceSheetSummary struct {
 
 // GetActiveAttendanceSheets gets all active (not archived) attendance sheets for a teacher
 func (a *App) GetActiveAttendanceSheets(teacherUserID int) ([]AttendanceSheetSummary, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GetActiveAttendanceSheets", teacherUserID); err != nil {
 		return nil, err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return nil, fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkDB(); err != nil {
+		return nil, err
 	}
 
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 
 	query := `
 		SELECT
@@ -1217,89 +1385,21 @@ type AttendanceSession struct {
 	PresentCount         int     `json:"present_count"`
 	AbsentCount          int     `json:"absent_count"`
 	LateCount            int     `json:"late_count"`
+	// TimedIn is set in a student's open sessions when they are timed in and can time out.
+	TimedIn bool `json:"timed_in,omitempty"`
 }
 
-func (a *App) ensureAttendanceSessionsTable() error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-
-	_, err := a.db.Exec(`
-		CREATE TABLE IF NOT EXISTS attendance_sessions (
-			session_id INT AUTO_INCREMENT PRIMARY KEY,
-			class_id INT NOT NULL,
-			attendance_date DATE NOT NULL,
-			session_name VARCHAR(255) NOT NULL,
-			status VARCHAR(20) NOT NULL DEFAULT 'open',
-			is_archived TINYINT(1) NOT NULL DEFAULT 0,
-			class_duration_minutes INT NULL,
-			grace_period_minutes INT NULL,
-			opened_at DATETIME NULL,
-			paused_at DATETIME NULL,
-			closed_at DATETIME NULL,
-			created_by_user_id INT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			CONSTRAINT FK_attendance_sessions_class FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE,
-			CONSTRAINT FK_attendance_sessions_creator FOREIGN KEY (created_by_user_id) REFERENCES users(id)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	ensureSessionColumn := func(columnName, columnDef string) error {
-		var exists int
-		err := a.db.QueryRow(`
-			SELECT COUNT(*)
-			FROM INFORMATION_SCHEMA.COLUMNS
-			WHERE TABLE_SCHEMA = DATABASE()
-			  AND TABLE_NAME = 'attendance_sessions'
-			  AND COLUMN_NAME = ?
-		`, columnName).Scan(&exists)
-		if err != nil {
-			return err
-		}
-		if exists == 0 {
-			if _, err := a.db.Exec(fmt.Sprintf("ALTER TABLE attendance_sessions ADD COLUMN %s %s", columnName, columnDef)); err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-
-	if err := ensureSessionColumn("is_archived", "TINYINT(1) NOT NULL DEFAULT 0"); err != nil {
-		return err
-	}
-	if err := ensureSessionColumn("class_duration_minutes", "INT NULL"); err != nil {
-		return err
-	}
-	if err := ensureSessionColumn("grace_period_minutes", "INT NULL"); err != nil {
-		return err
-	}
-	if err := ensureSessionColumn("opened_at", "DATETIME NULL"); err != nil {
-		return err
-	}
-	if err := ensureSessionColumn("paused_at", "DATETIME NULL"); err != nil {
-		return err
-	}
-	if err := ensureSessionColumn("closed_at", "DATETIME NULL"); err != nil {
-		return err
-	}
-	if err := ensureSessionColumn("created_by_user_id", "INT NULL"); err != nil {
+func (a *App) ensureAttendanceRowsForSession(sessionID, classID int, date string) error {
+	if err := insertMissingAttendanceRows(a.db, sessionID, classID, date); err != nil {
 		return err
 	}
-
-	_, _ = a.db.Exec(`UPDATE attendance_sessions SET created_by_user_id = 1 WHERE created_by_user_id IS NULL`)
-
-	_, _ = a.db.Exec(`ALTER TABLE attendance ADD COLUMN IF NOT EXISTS session_id INT NULL`)
-	_, _ = a.db.Exec(`ALTER TABLE attendance ADD COLUMN IF NOT EXISTS time_in_at DATETIME NULL`)
-	_, _ = a.db.Exec(`CREATE INDEX IX_attendance_class_date_session_student ON attendance (class_id, attendance_date, session_id, student_id)`)
-
-	return nil
+	// Students on an approved leave start the session excused.
+	_, err := excuseAttendanceRows(a.db, a.now(), `a.session_id = ?`, sessionID)
+	return err
 }
 
-func (a *App) ensureAttendanceRowsForSession(sessionID, classID int, date string) error {
+// insertMissingAttendanceRows creates blank attendance rows for enrolled students who have none for the session.
+func insertMissingAttendanceRows(exec dbExecutor, sessionID, classID int, date string) error {
 	query := `
 		INSERT INTO attendance (class_id, student_id, attendance_date, session_id, status, remarks, is_archived, created_at)
 		SELECT
@@ -1327,51 +1427,63 @@ func (a *App) ensureAttendanceRowsForSession(sessionID, classID int, date string
 			)
 	`
 
-	_, err := a.db.Exec(query, date, sessionID, classID, date, sessionID)
+	_, err := exec.Exec(query, date, sessionID, classID, date, sessionID)
 	return err
 }
 
 func (a *App) closeExpiredAttendanceSessions() error {
-	_, normalizeErr := a.db.Exec(`
-		UPDATE attendance att
-		JOIN attendance_sessions s ON att.session_id = s.session_id
-		SET
-			att.status = 'absent',
-			att.remarks = 'Absent',
-			att.time_in_at = NULL,
-			att.updated_at = CURRENT_TIMESTAMP
-		WHERE s.status = 'open'
-			AND COALESCE(s.is_archived, 0) = 0
-			AND s.paused_at IS NULL
-			AND COALESCE(att.is_archived, 0) = 0
-			AND s.opened_at IS NOT NULL
-			AND COALESCE(NULLIF(s.class_duration_minutes, 0), 0) > 0
-			AND DATE_ADD(s.opened_at, INTERVAL COALESCE(NULLIF(s.class_duration_minutes, 0), 0) MINUTE) <= NOW()
-			AND LOWER(LTRIM(RTRIM(COALESCE(att.status, '')))) NOT IN ('present', 'late', 'seat-in', 'seat in', 'absent')
-	`)
-	if normalizeErr != nil {
-		return normalizeErr
+	now := a.now()
+	// Unmarked students are handled by each class's finalization policy once the
+	// sessions are closed.
+	defer a.finalizeDueAttendanceSessions()
+
+	expiredWhere := `
+		WHERE status = 'open'
+			AND COALESCE(is_archived, 0) = 0
+			AND paused_at IS NULL
+			AND opened_at IS NOT NULL
+			AND COALESCE(NULLIF(class_duration_minutes, 0), 0) > 0
+			AND DATE_ADD(opened_at, INTERVAL COALESCE(NULLIF(class_duration_minutes, 0), 0) MINUTE) <= ?
+	`
+	var closingSessionIDs []int
+	closingClasses := map[int]bool{}
+	sessionRows, err := a.db.Query(`SELECT session_id, class_id FROM attendance_sessions`+expiredWhere, now)
+	if err != nil {
+		return err
+	}
+	for sessionRows.Next() {
+		var sessionID, classID int
+		if sessionRows.Scan(&sessionID, &classID) == nil {
+			closingSessionIDs = append(closingSessionIDs, sessionID)
+			closingClasses[classID] = true
+		}
 	}
+	sessionRows.Close()
 
 	result, err := a.db.Exec(`
 		UPDATE attendance_sessions
 		SET
 			status = 'closed',
-			closed_at = COALESCE(closed_at, CURRENT_TIMESTAMP),
+			closed_at = COALESCE(closed_at, ?),
 			updated_at = CURRENT_TIMESTAMP
-		WHERE status = 'open'
-			AND COALESCE(is_archived, 0) = 0
-			AND paused_at IS NULL
-			AND opened_at IS NOT NULL
-			AND COALESCE(NULLIF(class_duration_minutes, 0), 0) > 0
-			AND DATE_ADD(opened_at, INTERVAL COALESCE(NULLIF(class_duration_minutes, 0), 0) MINUTE) <= NOW()
-	`)
+	`+expiredWhere, now, now)
 	if err != nil {
 		return err
 	}
 
 	if rowsAffected, rowsErr := result.RowsAffected(); rowsErr == nil && rowsAffected > 0 {
 		log.Printf("Auto-closed %d expired attendance session(s)", rowsAffected)
+		a.timeOutClosedSessions()
+		for _, sessionID := range closingSessionIDs {
+			if err := a.finalizeClosedAttendanceSession(sessionID); err != nil {
+				log.Printf("Failed to finalize attendance session %d: %v", sessionID, err)
+			}
+		}
+		for classID := range closingClasses {
+			if err := a.evaluateAttendanceAlerts(classID); err != nil {
+				log.Printf("Failed to evaluate attendance alerts for class %d: %v", classID, err)
+			}
+		}
 	}
 
 	return nil
@@ -1455,11 +1567,11 @@ func (a *App) getAttendanceSessionByID(sessionID int) (*AttendanceSession, error
 }
 
 func (a *App) CreateAttendanceSession(classID int, date, sessionName string, teacherUserID int, classDurationMinutes int, gracePeriodMinutes int) (*AttendanceSession, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("CreateAttendanceSession", teacherUserID); err != nil {
 		return nil, err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return nil, fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkDB(); err != nil {
+		return nil, err
 	}
 	if err := a.closeExpiredAttendanceSessions(); err != nil {
 		log.Printf("Warning: failed to close expired attendance sessions before create: %v", err)
@@ -1479,7 +1591,7 @@ func (a *App) CreateAttendanceSession(classID int, date, sessionName string, tea
 	}
 
 	if strings.TrimSpace(sessionName) == "" {
-		sessionName = fmt.Sprintf("Attendance %s %s", date, time.Now().Format("15:04:05"))
+		sessionName = fmt.Sprintf("Attendance %s %s", date, a.now().Format("15:04:05"))
 	}
 
 	var openSessionID int
@@ -1517,9 +1629,9 @@ func (a *App) CreateAttendanceSession(classID int, date, sessionName string, tea
 	var newSessionID int
 	insertResult, err := a.db.Exec(`
 		INSERT INTO attendance_sessions
-		(class_id, attendance_date, session_name, status, class_duration_minutes, grace_period_minutes, opened_at, created_by_user_id, created_at, updated_at)
-		VALUES (?, ?, ?, 'open', ?, ?, CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-	`, classID, date, sessionName, nullInt(classDurationMinutes), nullInt(gracePeriodMinutes), teacherUserID)
+		(class_id, attendance_date, session_name, status, class_duration_minutes, grace_period_minutes, opened_at, code_rotation_seconds, created_by_user_id, created_at, updated_at)
+		VALUES (?, ?, ?, 'open', ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, classID, date, sessionName, nullInt(classDurationMinutes), nullInt(gracePeriodMinutes), a.now(), loadTimeInCodeSeconds(), teacherUserID)
 	if err != nil {
 		return nil, err
 	}
@@ -1534,34 +1646,17 @@ func (a *App) CreateAttendanceSession(classID int, date, sessionName string, tea
 	}
 
 	// Notify enrolled students about the opened session
-	go func(cID, sID int, sName string) {
-		message := a.buildAttendanceSessionOpenMessage(cID, sName)
-		studentRows, qErr := a.db.Query(
-			`SELECT student_id FROM joined_classes WHERE class_id = ? AND status IN ('join', 'added', 'active') AND (is_archived = 0 OR is_archived IS NULL)`, cID)
-		if qErr != nil || studentRows == nil {
-			return
-		}
-		defer studentRows.Close()
-		for studentRows.Next() {
-			var sid int
-			if studentRows.Scan(&sid) == nil {
-				a.createNotification(sid, "attendance",
-					"Attendance Session Open",
-					message,
-					"info", notifRef("attendance_session"), notifRefID(sID))
-			}
-		}
-	}(classID, newSessionID, sessionName)
+	go a.notifyAttendanceSessionOpened(classID, newSessionID, sessionName)
 
 	return a.getAttendanceSessionByID(newSessionID)
 }
 
 func (a *App) GetTeacherAttendanceSessions(teacherUserID int) ([]AttendanceSession, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GetTeacherAttendanceSessions", teacherUserID); err != nil {
 		return nil, err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return nil, fmt.Errorf("failed to initialize attendance sessions table: %w", err)
+	if err := a.checkDB(); err != nil {
+		return nil, err
 	}
 	if err := a.closeExpiredAttendanceSessions(); err != nil {
 		log.Printf("Warning: failed to close expired attendance sessions before teacher fetch: %v", err)
@@ -1658,29 +1753,31 @@ func (a *App) GetTeacherAttendanceSessions(teacherUserID int) ([]AttendanceSessi
 }
 
 func (a *App) SaveAttendanceSession(sessionID int, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	session, err := a.requireActingUser("SaveAttendanceSession", teacherUserID)
+	if err != nil {
 		return err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
+	// Unmarked students are left to the class's finalization policy below.
 	_, normalizeErr := a.db.Exec(`
 		UPDATE attendance
 		SET
-			status = COALESCE(NULLIF(status, ''), 'absent'),
 			remarks = CASE
-				WHEN COALESCE(NULLIF(status, ''), 'absent') = 'present' THEN 'Present'
-				WHEN COALESCE(NULLIF(status, ''), 'absent') = 'late' THEN 'Late'
+				WHEN status = 'present' THEN 'Present'
+				WHEN status = 'late' THEN 'Late'
+				WHEN status = 'excused' THEN 'Excused'
 				ELSE 'Absent'
 			END,
 			time_in_at = CASE
-				WHEN COALESCE(NULLIF(status, ''), 'absent') IN ('present', 'late', 'seat-in', 'seat in')
+				WHEN status IN ('present', 'late', 'seat-in', 'seat in')
 					THEN COALESCE(time_in_at, updated_at)
 				ELSE NULL
 			END,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE session_id = ? AND COALESCE(is_archived, 0) = 0
+		WHERE session_id = ? AND COALESCE(is_archived, 0) = 0 AND NOT (`+unmarkedAttendanceCondition+`)
 	`, sessionID)
 	if normalizeErr != nil {
 		return normalizeErr
@@ -1692,10 +1789,10 @@ func (a *App) SaveAttendanceSession(sessionID int, teacherUserID int) error {
 		SET
 			s.status = 'closed',
 			s.paused_at = NULL,
-			s.closed_at = CURRENT_TIMESTAMP,
+			s.closed_at = ?,
 			s.updated_at = CURRENT_TIMESTAMP
 		WHERE s.session_id = ? AND c.teacher_id = ? AND COALESCE(s.is_archived, 0) = 0
-	`, sessionID, teacherUserID)
+	`, a.now(), sessionID, teacherUserID)
 	if err != nil {
 		return err
 	}
@@ -1704,6 +1801,12 @@ func (a *App) SaveAttendanceSession(sessionID int, teacherUserID int) error {
 	if rows == 0 {
 		return fmt.Errorf("session not found or not authorized")
 	}
+	a.audit(session, "close_attendance_session", "attendance_session", sessionID, nil, auditValues{"status": "closed"})
+	a.timeOutClosedSessions()
+	if err := a.finalizeClosedAttendanceSession(sessionID); err != nil {
+		log.Printf("Failed to finalize attendance session %d: %v", sessionID, err)
+	}
+	a.evaluateAttendanceAlertsForSession(sessionID)
 
 	// Notify enrolled students that the session has been closed by the teacher.
 	go func(sessID int) {
@@ -1745,10 +1848,10 @@ func (a *App) SaveAttendanceSession(sessionID int, teacherUserID int) error {
 }
 
 func (a *App) RenameAttendanceSession(sessionID int, sessionName string, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("RenameAttendanceSession", teacherUserID); err != nil {
 		return err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
@@ -1778,10 +1881,10 @@ func (a *App) RenameAttendanceSession(sessionID int, sessionName string, teacher
 }
 
 func (a *App) PauseAttendanceSession(sessionID int, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("PauseAttendanceSession", teacherUserID); err != nil {
 		return err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
@@ -1789,13 +1892,13 @@ func (a *App) PauseAttendanceSession(sessionID int, teacherUserID int) error {
 		UPDATE attendance_sessions s
 		JOIN classes c ON s.class_id = c.class_id
 		SET
-			s.paused_at = COALESCE(s.paused_at, CURRENT_TIMESTAMP),
+			s.paused_at = COALESCE(s.paused_at, ?),
 			s.updated_at = CURRENT_TIMESTAMP
 		WHERE s.session_id = ?
 			AND c.teacher_id = ?
 			AND s.status = 'open'
 			AND COALESCE(s.is_archived, 0) = 0
-	`, sessionID, teacherUserID)
+	`, a.now(), sessionID, teacherUserID)
 	if err != nil {
 		return err
 	}
@@ -1809,20 +1912,21 @@ func (a *App) PauseAttendanceSession(sessionID int, teacherUserID int) error {
 }
 
 func (a *App) ResumeAttendanceSession(sessionID int, teacherUserID int) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("ResumeAttendanceSession", teacherUserID); err != nil {
 		return err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
+	now := a.now()
 	result, err := a.db.Exec(`
 		UPDATE attendance_sessions s
 		JOIN classes c ON s.class_id = c.class_id
 		SET
 			s.opened_at = CASE
 				WHEN s.paused_at IS NULL THEN s.opened_at
-				ELSE DATE_ADD(COALESCE(s.opened_at, CURRENT_TIMESTAMP), INTERVAL TIMESTAMPDIFF(SECOND, s.paused_at, CURRENT_TIMESTAMP) SECOND)
+				ELSE DATE_ADD(COALESCE(s.opened_at, ?), INTERVAL TIMESTAMPDIFF(SECOND, s.paused_at, ?) SECOND)
 			END,
 			s.paused_at = NULL,
 			s.updated_at = CURRENT_TIMESTAMP
@@ -1830,7 +1934,7 @@ func (a *App) ResumeAttendanceSession(sessionID int, teacherUserID int) error {
 			AND c.teacher_id = ?
 			AND s.status = 'open'
 			AND COALESCE(s.is_archived, 0) = 0
-	`, sessionID, teacherUserID)
+	`, now, now, sessionID, teacherUserID)
 	if err != nil {
 		return err
 	}
@@ -1844,10 +1948,10 @@ func (a *App) ResumeAttendanceSession(sessionID int, teacherUserID int) error {
 }
 
 func (a *App) GetStudentOpenAttendanceSessions(studentUserID int) ([]AttendanceSession, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GetStudentOpenAttendanceSessions", studentUserID); err != nil {
 		return nil, err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
 	if err := a.closeExpiredAttendanceSessions(); err != nil {
@@ -1871,7 +1975,8 @@ func (a *App) GetStudentOpenAttendanceSessions(studentUserID int) ([]AttendanceS
 			COALESCE(c.edp_code, ''),
 			SUM(CASE WHEN a.status = 'present' THEN 1 ELSE 0 END) AS present_count,
 			SUM(CASE WHEN a.status = 'absent' THEN 1 ELSE 0 END) AS absent_count,
-			SUM(CASE WHEN a.status = 'late' THEN 1 ELSE 0 END) AS late_count
+			SUM(CASE WHEN a.status = 'late' THEN 1 ELSE 0 END) AS late_count,
+			MAX(CASE WHEN sa.present_since IS NOT NULL THEN 1 ELSE 0 END) AS timed_in
 		FROM attendance_sessions s
 		JOIN classes c ON s.class_id = c.class_id
 		JOIN joined_classes cl ON cl.class_id = c.class_id
@@ -1887,7 +1992,11 @@ func (a *App) GetStudentOpenAttendanceSessions(studentUserID int) ([]AttendanceS
 			AND (cl.is_archived = 0 OR cl.is_archived IS NULL)
 			AND s.status = 'open'
 			AND COALESCE(s.is_archived, 0) = 0
-			AND LOWER(LTRIM(RTRIM(COALESCE(sa.status, '')))) NOT IN ('present', 'late', 'seat-in', 'seat in')
+			AND (
+				LOWER(LTRIM(RTRIM(COALESCE(sa.status, '')))) NOT IN ('present', 'late', 'seat-in', 'seat in')
+				OR sa.present_since IS NOT NULL
+				OR sa.time_out_at IS NOT NULL
+			)
 		GROUP BY s.session_id, s.class_id, s.attendance_date, s.session_name, s.status, s.class_duration_minutes, s.grace_period_minutes,
 			s.opened_at, s.paused_at, s.closed_at, subj.subject_code, subj.description, c.edp_code
 		ORDER BY s.opened_at DESC, s.session_id DESC
@@ -1922,6 +2031,7 @@ func (a *App) GetStudentOpenAttendanceSessions(studentUserID int) ([]AttendanceS
 			&session.PresentCount,
 			&session.AbsentCount,
 			&session.LateCount,
+			&session.TimedIn,
 		)
 		if err != nil {
 			continue
@@ -1950,16 +2060,33 @@ func (a *App) GetStudentOpenAttendanceSessions(studentUserID int) ([]AttendanceS
 
 		result = append(result, session)
 	}
+	rows.Close()
+
+	// The countdown students see runs to the end of the present tier.
+	for i := range result {
+		rule, _, err := loadSessionLatenessRule(a.db, result[i].SessionID)
+		if err != nil || rule.Source == latenessSourceGrace {
+			continue
+		}
+		v := rule.presentMinutes()
+		result[i].GracePeriodMinutes = &v
+	}
 
 	return result, nil
 }
 
-func (a *App) StudentTimeIn(sessionID int, studentUserID int) error {
-	if err := a.checkDB(); err != nil {
+// StudentTimeIn records a student's time-in. code is the rotating code shown on the
+// teacher's screen (see attendance_codes.go).
+func (a *App) StudentTimeIn(sessionID int, studentUserID int, code string) error {
+	if _, err := a.requireActingUser("StudentTimeIn", studentUserID); err != nil {
 		return err
 	}
-	if err := a.ensureAttendanceSessionsTable(); err != nil {
-		return err
+	if normalizeAttendanceCode(code) == "" {
+		return fmt.Errorf("enter the time-in code shown on the teacher's screen")
+	}
+	if err := a.checkDB(); err != nil {
+		// Keep the tap with its original time; it is checked and applied when the server is back.
+		return a.queueOfflineTimeIn(err, sessionID, studentUserID, code)
 	}
 
 	if err := a.closeStaleSessions(); err != nil {
@@ -1968,19 +2095,19 @@ func (a *App) StudentTimeIn(sessionID int, studentUserID int) error {
 	if err := a.closeExpiredAttendanceSessions(); err != nil {
 		log.Printf("Failed to close expired attendance sessions before student time-in: %v", err)
 	}
+	now := a.now()
 
 	var classID int
 	var attendanceDate string
 	var status string
 	var classDuration sql.NullInt64
-	var gracePeriod sql.NullInt64
 	var openedAt sql.NullTime
 	var pausedAt sql.NullTime
 	err := a.db.QueryRow(`
-		SELECT s.class_id, DATE_FORMAT(s.attendance_date, '%Y-%m-%d'), s.status, s.class_duration_minutes, s.grace_period_minutes, s.opened_at, s.paused_at
+		SELECT s.class_id, DATE_FORMAT(s.attendance_date, '%Y-%m-%d'), s.status, s.class_duration_minutes, s.opened_at, s.paused_at
 		FROM attendance_sessions s
 		WHERE s.session_id = ?
-	`, sessionID).Scan(&classID, &attendanceDate, &status, &classDuration, &gracePeriod, &openedAt, &pausedAt)
+	`, sessionID).Scan(&classID, &attendanceDate, &status, &classDuration, &openedAt, &pausedAt)
 	if err != nil {
 		return fmt.Errorf("attendance session not found")
 	}
@@ -2001,6 +2128,10 @@ func (a *App) StudentTimeIn(sessionID int, studentUserID int) error {
 		return fmt.Errorf("student is not enrolled in this class")
 	}
 
+	if err := checkTimeInLocation(a.db, classID, a.computerLab); err != nil {
+		return err
+	}
+
 	var activeLogin int
 	err = a.db.QueryRow(`
 		SELECT 1
@@ -2010,14 +2141,18 @@ func (a *App) StudentTimeIn(sessionID int, studentUserID int) error {
 			AND EXISTS (
 				SELECT 1 FROM user_session_heartbeats sh
 				WHERE sh.user_id = le.user_id
-				AND sh.last_seen >= DATE_SUB(NOW(), INTERVAL ? SECOND)
+				AND sh.last_seen >= ?
 			)
 		ORDER BY le.login_time DESC
-	`, studentUserID, sessionHeartbeatTimeoutSeconds).Scan(&activeLogin)
+	`, studentUserID, now.Add(-time.Duration(sessionHeartbeatTimeoutSeconds)*time.Second)).Scan(&activeLogin)
 	if err != nil {
 		return fmt.Errorf("student must be logged in to time in")
 	}
 
+	if err := a.verifyTimeInCode(sessionID, studentUserID, code); err != nil {
+		return err
+	}
+
 	if err := a.ensureAttendanceRowsForSession(sessionID, classID, attendanceDate); err != nil {
 		return err
 	}
@@ -2037,37 +2172,62 @@ func (a *App) StudentTimeIn(sessionID int, studentUserID int) error {
 	if openedAt.Valid {
 		if attendanceWindowMinutes > 0 {
 			expiresAt := openedAt.Time.Add(time.Duration(attendanceWindowMinutes) * time.Minute)
-			if time.Now().After(expiresAt) {
+			if now.After(expiresAt) {
 				_, _ = a.db.Exec(`
 					UPDATE attendance_sessions
-					SET status = 'closed', closed_at = COALESCE(closed_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+					SET status = 'closed', closed_at = COALESCE(closed_at, ?), updated_at = CURRENT_TIMESTAMP
 					WHERE session_id = ? AND status = 'open'
-				`, sessionID)
+				`, now, sessionID)
+				a.timeOutClosedSessions()
+				if err := a.finalizeClosedAttendanceSession(sessionID); err != nil {
+					log.Printf("Failed to finalize attendance session %d: %v", sessionID, err)
+				}
 				return fmt.Errorf("attendance session class duration is over")
 			}
 		}
 	}
 
-	// Determine present vs late using the database clock so timezone and server
-	// consistency match. Late = time-in is after (opened_at + grace_period_minutes).
+	// Timing in again after a time-out starts a new presence segment; the status of the
+	// first time-in stands.
+	var timedInAt, presentSince sql.NullTime
+	_ = a.db.QueryRow(`
+		SELECT time_in_at, present_since FROM attendance
+		WHERE class_id = ? AND student_id = ? AND attendance_date = ? AND session_id = ? AND COALESCE(is_archived, 0) = 0
+	`, classID, studentUserID, attendanceDate, sessionID).Scan(&timedInAt, &presentSince)
+	if timedInAt.Valid {
+		if presentSince.Valid {
+			return nil
+		}
+		if _, err := a.db.Exec(`
+			UPDATE attendance
+			SET present_since = ?, time_out_at = NULL, time_out_source = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE class_id = ? AND student_id = ? AND attendance_date = ? AND session_id = ? AND COALESCE(is_archived, 0) = 0
+		`, now, classID, studentUserID, attendanceDate, sessionID); err != nil {
+			return fmt.Errorf("failed to time in again: %w", err)
+		}
+		return nil
+	}
+
+	// The status comes from the session's lateness tiers, measured on the app clock (which
+	// follows the database server). A time-in past every tier is still recorded.
+	rule, _, err := loadSessionLatenessRule(a.db, sessionID)
+	if err != nil {
+		return err
+	}
+	timeInStatus, minutesLate := rule.evaluate(openedAt, now)
 	result, err := a.db.Exec(`
-		UPDATE attendance a
-		INNER JOIN attendance_sessions s ON s.session_id = a.session_id
+		UPDATE attendance
 		SET
-			a.status = CASE
-				WHEN s.opened_at IS NULL THEN 'present'
-				WHEN NOW() <= DATE_ADD(s.opened_at, INTERVAL COALESCE(NULLIF(s.grace_period_minutes, 0), 0) MINUTE) THEN 'present'
-				ELSE 'late'
-			END,
-			a.remarks = CASE
-				WHEN s.opened_at IS NULL THEN 'Present'
-				WHEN NOW() <= DATE_ADD(s.opened_at, INTERVAL COALESCE(NULLIF(s.grace_period_minutes, 0), 0) MINUTE) THEN 'Present'
-				ELSE 'Late'
-			END,
-			a.time_in_at = COALESCE(a.time_in_at, CURRENT_TIMESTAMP),
-			a.updated_at = CURRENT_TIMESTAMP
-		WHERE a.class_id = ? AND a.student_id = ? AND a.attendance_date = ? AND a.session_id = ? AND COALESCE(a.is_archived, 0) = 0
-	`, classID, studentUserID, attendanceDate, sessionID)
+			status = ?,
+			remarks = ?,
+			minutes_late = ?,
+			time_in_station = CASE WHEN time_in_at IS NULL THEN ? ELSE time_in_station END,
+			present_since = ?,
+			time_in_at = COALESCE(time_in_at, ?),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE class_id = ? AND student_id = ? AND attendance_date = ? AND session_id = ? AND COALESCE(is_archived, 0) = 0
+	`, timeInStatus, *normalizeAttendanceRemark(timeInStatus, sql.NullString{}), minutesLate,
+		nullString(timeInStationLabel(a.currentStationLabel())), now, now, classID, studentUserID, attendanceDate, sessionID)
 	if err != nil {
 		return err
 	}
@@ -2088,7 +2248,7 @@ func (a *App) StudentTimeIn(sessionID int, studentUserID int) error {
 			return
 		}
 		tone := "success"
-		if resultStatus == "late" {
+		if resultStatus == "late" || resultStatus == "absent" {
 			tone = "warning"
 		}
 		title := "Attendance Recorded"
@@ -2187,6 +2347,9 @@ type AttendanceHistoryRecord struct {
 
 // GetStudentAttendanceHistory returns all attendance records for a student ordered by date descending.
 func (a *App) GetStudentAttendanceHistory(userID int) ([]AttendanceHistoryRecord, error) {
+	if _, err := a.requireActingUser("GetStudentAttendanceHistory", userID); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
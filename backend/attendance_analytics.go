@@ -0,0 +1,620 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// ATTENDANCE ANALYTICS
+// ==============================================================================
+//
+// Aggregates are computed from attendance rows that already have a status, limited to
+// the class term when one is set. Present and late both count as attended; excused
+// sessions are left out of rates and neither break nor extend an absence streak.
+
+const (
+	defaultAlertConsecutiveAbsences = 3
+	defaultAlertMinAttendanceRate   = 80
+	// The rate alert waits for a few sessions so one early absence does not flag a student.
+	attendanceRateAlertMinimumSessions = 3
+
+	attendanceAlertConsecutiveAbsences = "consecutive_absences"
+	attendanceAlertLowRate             = "low_attendance_rate"
+)
+
+// AttendanceAlertThresholds are the at-risk thresholds of a class. A zero value turns
+// that alert off.
+type AttendanceAlertThresholds struct {
+	ConsecutiveAbsences   int `json:"consecutive_absences"`
+	MinimumAttendanceRate int `json:"minimum_attendance_rate"`
+}
+
+// AttendanceTrendPoint is the attendance rate for one week (starting Monday) of the term.
+type AttendanceTrendPoint struct {
+	WeekStart      string  `json:"week_start"`
+	Sessions       int     `json:"sessions"`
+	Attended       int     `json:"attended"`
+	AttendanceRate float64 `json:"attendance_rate"`
+}
+
+// StudentAttendanceSummary aggregates one student's attendance in one class.
+type StudentAttendanceSummary struct {
+	ClassID             int                    `json:"class_id"`
+	SubjectCode         string                 `json:"subject_code"`
+	SubjectName         string                 `json:"subject_name"`
+	Section             string                 `json:"section"`
+	StudentUserID       int                    `json:"student_user_id"`
+	StudentCode         string                 `json:"student_code"`
+	StudentName         string                 `json:"student_name"`
+	TotalSessions       int                    `json:"total_sessions"`
+	PresentCount        int                    `json:"present_count"`
+	LateCount           int                    `json:"late_count"`
+	AbsentCount         int                    `json:"absent_count"`
+	ExcusedCount        int                    `json:"excused_count"`
+	AttendanceRate      float64                `json:"attendance_rate"`
+	ConsecutiveAbsences int                    `json:"consecutive_absences"`
+	Trend               []AttendanceTrendPoint `json:"trend"`
+	AtRisk              bool                   `json:"at_risk"`
+	RiskReasons         []string               `json:"risk_reasons"`
+}
+
+// ClassAttendanceSummary aggregates attendance for every student of a class.
+type ClassAttendanceSummary struct {
+	ClassID               int                        `json:"class_id"`
+	SubjectCode           string                     `json:"subject_code"`
+	SubjectName           string                     `json:"subject_name"`
+	Section               string                     `json:"section"`
+	TotalSessions         int                        `json:"total_sessions"`
+	AverageAttendanceRate float64                    `json:"average_attendance_rate"`
+	AtRiskCount           int                        `json:"at_risk_count"`
+	Thresholds            AttendanceAlertThresholds  `json:"thresholds"`
+	Trend                 []AttendanceTrendPoint     `json:"trend"`
+	Students              []StudentAttendanceSummary `json:"students"`
+}
+
+func attendanceRate(attended, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(attended)*1000/float64(total)) / 10
+}
+
+func attendanceWeekStart(date string) string {
+	parsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return parsed.AddDate(0, 0, -((int(parsed.Weekday()) + 6) % 7)).Format("2006-01-02")
+}
+
+// addAttendanceTrend adds one session to the weekly trend, which is kept in date order.
+func addAttendanceTrend(trend []AttendanceTrendPoint, date string, attended bool) []AttendanceTrendPoint {
+	week := attendanceWeekStart(date)
+	if n := len(trend); n == 0 || trend[n-1].WeekStart != week {
+		trend = append(trend, AttendanceTrendPoint{WeekStart: week})
+	}
+	index := len(trend) - 1
+	trend[index].Sessions++
+	if attended {
+		trend[index].Attended++
+	}
+	trend[index].AttendanceRate = attendanceRate(trend[index].Attended, trend[index].Sessions)
+	return trend
+}
+
+// applyAttendanceRisk flags summary against thresholds.
+func applyAttendanceRisk(summary *StudentAttendanceSummary, thresholds AttendanceAlertThresholds) {
+	summary.RiskReasons = []string{}
+	if thresholds.ConsecutiveAbsences > 0 && summary.ConsecutiveAbsences >= thresholds.ConsecutiveAbsences {
+		summary.RiskReasons = append(summary.RiskReasons,
+			fmt.Sprintf("%d consecutive absences", summary.ConsecutiveAbsences))
+	}
+	if thresholds.MinimumAttendanceRate > 0 && summary.TotalSessions >= attendanceRateAlertMinimumSessions &&
+		summary.AttendanceRate < float64(thresholds.MinimumAttendanceRate) {
+		summary.RiskReasons = append(summary.RiskReasons,
+			fmt.Sprintf("attendance rate %.1f%% is below %d%%", summary.AttendanceRate, thresholds.MinimumAttendanceRate))
+	}
+	summary.AtRisk = len(summary.RiskReasons) > 0
+}
+
+// loadAttendanceSummaries aggregates attendance per class and student, for one class
+// (classID > 0) or for one student across classes (studentUserID > 0).
+func (a *App) loadAttendanceSummaries(classID, studentUserID int) ([]StudentAttendanceSummary, error) {
+	filter, arg := "a.class_id = ?", classID
+	if studentUserID > 0 {
+		filter, arg = "a.student_id = ?", studentUserID
+	}
+
+	rows, err := a.db.Query(`
+		SELECT
+			a.class_id,
+			c.subject_code,
+			s.description,
+			IFNULL(c.section, ''),
+			a.student_id,
+			stu.student_id,
+			stu.first_name,
+			stu.middle_name,
+			stu.last_name,
+			DATE_FORMAT(a.attendance_date, '%Y-%m-%d'),
+			LOWER(LTRIM(RTRIM(a.status)))
+		FROM attendance a
+		JOIN attendance_sessions sess ON a.session_id = sess.session_id
+		JOIN classes c ON a.class_id = c.class_id
+		JOIN subjects s ON c.subject_code = s.subject_code
+		JOIN students stu ON a.student_id = stu.id
+		WHERE `+filter+`
+			AND a.status IS NOT NULL
+			AND (c.term_start_date IS NULL OR a.attendance_date >= c.term_start_date)
+			AND (c.term_end_date IS NULL OR a.attendance_date <= c.term_end_date)
+		ORDER BY a.class_id, stu.last_name, stu.first_name, a.student_id, a.attendance_date, sess.opened_at, a.session_id
+	`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attendance for summary: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []StudentAttendanceSummary{}
+	for rows.Next() {
+		var rowClassID, rowStudentID int
+		var subjectCode, subjectName, section, studentCode, firstName, lastName, date, status string
+		var middleName sql.NullString
+		if err := rows.Scan(&rowClassID, &subjectCode, &subjectName, &section, &rowStudentID, &studentCode,
+			&firstName, &middleName, &lastName, &date, &status); err != nil {
+			return nil, err
+		}
+
+		if n := len(summaries); n == 0 || summaries[n-1].ClassID != rowClassID || summaries[n-1].StudentUserID != rowStudentID {
+			summaries = append(summaries, StudentAttendanceSummary{
+				ClassID:       rowClassID,
+				SubjectCode:   subjectCode,
+				SubjectName:   subjectName,
+				Section:       section,
+				StudentUserID: rowStudentID,
+				StudentCode:   studentCode,
+				StudentName: buildAttendanceExportName(Attendance{
+					FirstName: firstName, MiddleName: scanNullString(middleName), LastName: lastName,
+				}),
+				Trend: []AttendanceTrendPoint{},
+			})
+		}
+		current := &summaries[len(summaries)-1]
+
+		current.TotalSessions++
+		attended := true
+		switch status {
+		case "excused":
+			current.ExcusedCount++
+			continue
+		case "late":
+			current.LateCount++
+		case "absent":
+			current.AbsentCount++
+			attended = false
+		default:
+			current.PresentCount++
+		}
+		if attended {
+			current.ConsecutiveAbsences = 0
+		} else {
+			current.ConsecutiveAbsences++
+		}
+		current.Trend = addAttendanceTrend(current.Trend, date, attended)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		summaries[i].AttendanceRate = attendanceRate(summaries[i].PresentCount+summaries[i].LateCount,
+			summaries[i].TotalSessions-summaries[i].ExcusedCount)
+	}
+	return summaries, nil
+}
+
+func (a *App) getAttendanceAlertThresholds(classID int) (AttendanceAlertThresholds, error) {
+	thresholds := AttendanceAlertThresholds{
+		ConsecutiveAbsences:   defaultAlertConsecutiveAbsences,
+		MinimumAttendanceRate: defaultAlertMinAttendanceRate,
+	}
+	var consecutive, rate sql.NullInt64
+	err := a.db.QueryRow(`
+		SELECT alert_consecutive_absences, alert_min_attendance_rate FROM classes WHERE class_id = ?
+	`, classID).Scan(&consecutive, &rate)
+	if err == sql.ErrNoRows {
+		return thresholds, fmt.Errorf("class not found")
+	}
+	if err != nil {
+		return thresholds, err
+	}
+	if consecutive.Valid {
+		thresholds.ConsecutiveAbsences = int(consecutive.Int64)
+	}
+	if rate.Valid {
+		thresholds.MinimumAttendanceRate = int(rate.Int64)
+	}
+	return thresholds, nil
+}
+
+func (a *App) buildClassAttendanceSummary(classID int) (*ClassAttendanceSummary, error) {
+	thresholds, err := a.getAttendanceAlertThresholds(classID)
+	if err != nil {
+		return nil, err
+	}
+	students, err := a.loadAttendanceSummaries(classID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ClassAttendanceSummary{
+		ClassID:    classID,
+		Thresholds: thresholds,
+		Trend:      []AttendanceTrendPoint{},
+		Students:   students,
+	}
+	var section sql.NullString
+	if err := a.db.QueryRow(`
+		SELECT c.subject_code, s.description, c.section
+		FROM classes c
+		JOIN subjects s ON c.subject_code = s.subject_code
+		WHERE c.class_id = ?
+	`, classID).Scan(&summary.SubjectCode, &summary.SubjectName, &section); err != nil {
+		return nil, fmt.Errorf("failed to load class for summary: %w", err)
+	}
+	summary.Section = section.String
+
+	sessionRows, err := a.db.Query(`
+		SELECT DATE_FORMAT(a.attendance_date, '%Y-%m-%d'),
+			SUM(CASE WHEN LOWER(a.status) IN ('absent', 'excused') THEN 0 ELSE 1 END),
+			SUM(CASE WHEN LOWER(a.status) = 'excused' THEN 0 ELSE 1 END)
+		FROM attendance a
+		JOIN attendance_sessions sess ON a.session_id = sess.session_id
+		JOIN classes c ON a.class_id = c.class_id
+		WHERE a.class_id = ?
+			AND a.status IS NOT NULL
+			AND (c.term_start_date IS NULL OR a.attendance_date >= c.term_start_date)
+			AND (c.term_end_date IS NULL OR a.attendance_date <= c.term_end_date)
+		GROUP BY a.session_id, a.attendance_date
+		ORDER BY a.attendance_date, a.session_id
+	`, classID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class sessions for summary: %w", err)
+	}
+	defer sessionRows.Close()
+	// The class trend's rate is over student-sessions; slots counts them per week.
+	var slots []int
+	for sessionRows.Next() {
+		var date string
+		var attended, total int
+		if err := sessionRows.Scan(&date, &attended, &total); err != nil {
+			return nil, err
+		}
+		summary.TotalSessions++
+		week := attendanceWeekStart(date)
+		if n := len(summary.Trend); n == 0 || summary.Trend[n-1].WeekStart != week {
+			summary.Trend = append(summary.Trend, AttendanceTrendPoint{WeekStart: week})
+			slots = append(slots, 0)
+		}
+		last := len(summary.Trend) - 1
+		summary.Trend[last].Sessions++
+		summary.Trend[last].Attended += attended
+		slots[last] += total
+		summary.Trend[last].AttendanceRate = attendanceRate(summary.Trend[last].Attended, slots[last])
+	}
+	if err := sessionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	var rateTotal float64
+	for i := range summary.Students {
+		applyAttendanceRisk(&summary.Students[i], thresholds)
+		rateTotal += summary.Students[i].AttendanceRate
+		if summary.Students[i].AtRisk {
+			summary.AtRiskCount++
+		}
+	}
+	if len(summary.Students) > 0 {
+		summary.AverageAttendanceRate = math.Round(rateTotal*10/float64(len(summary.Students))) / 10
+	}
+	return summary, nil
+}
+
+// GetClassAttendanceSummary returns per-student attendance aggregates for a class, with
+// students who meet the class's at-risk thresholds flagged.
+func (a *App) GetClassAttendanceSummary(classID int) (*ClassAttendanceSummary, error) {
+	session, err := a.requireRole("GetClassAttendanceSummary")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	return a.buildClassAttendanceSummary(classID)
+}
+
+// GetStudentAttendanceSummary returns the student's attendance aggregates for each class.
+func (a *App) GetStudentAttendanceSummary(userID int) ([]StudentAttendanceSummary, error) {
+	if _, err := a.requireActingUser("GetStudentAttendanceSummary", userID); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	summaries, err := a.loadAttendanceSummaries(0, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		thresholds, err := a.getAttendanceAlertThresholds(summaries[i].ClassID)
+		if err != nil {
+			return nil, err
+		}
+		applyAttendanceRisk(&summaries[i], thresholds)
+	}
+	return summaries, nil
+}
+
+// SaveClassAttendanceAlertThresholds sets the at-risk thresholds of a class owned by the
+// teacher and re-checks its students against them.
+func (a *App) SaveClassAttendanceAlertThresholds(classID int, teacherUserID int, thresholds AttendanceAlertThresholds) error {
+	session, err := a.requireActingUser("SaveClassAttendanceAlertThresholds", teacherUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	if thresholds.ConsecutiveAbsences < 0 || thresholds.ConsecutiveAbsences > 50 {
+		return fmt.Errorf("consecutive absences must be between 0 and 50")
+	}
+	if thresholds.MinimumAttendanceRate < 0 || thresholds.MinimumAttendanceRate > 100 {
+		return fmt.Errorf("minimum attendance rate must be between 0 and 100")
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
+
+	previous, err := a.getAttendanceAlertThresholds(classID)
+	if err != nil {
+		return err
+	}
+	if _, err := a.db.Exec(`
+		UPDATE classes
+		SET alert_consecutive_absences = ?, alert_min_attendance_rate = ?, updated_at = ?
+		WHERE class_id = ?
+	`, thresholds.ConsecutiveAbsences, thresholds.MinimumAttendanceRate, a.now(), classID); err != nil {
+		return fmt.Errorf("failed to save attendance alert thresholds: %w", err)
+	}
+	a.audit(session, "update_attendance_alert_thresholds", "class", classID,
+		auditValues{"consecutive_absences": previous.ConsecutiveAbsences, "minimum_attendance_rate": previous.MinimumAttendanceRate},
+		auditValues{"consecutive_absences": thresholds.ConsecutiveAbsences, "minimum_attendance_rate": thresholds.MinimumAttendanceRate})
+
+	if err := a.evaluateAttendanceAlerts(classID); err != nil {
+		log.Printf("Failed to evaluate attendance alerts for class %d: %v", classID, err)
+	}
+	return nil
+}
+
+// ==============================================================================
+// AT-RISK ALERTS
+// ==============================================================================
+
+// evaluateAttendanceAlerts raises an alert, notifying the teacher and the student, when
+// a student first meets one of the class's thresholds, and clears it once they no longer
+// do. Called whenever a session of the class closes or a record is changed.
+func (a *App) evaluateAttendanceAlerts(classID int) error {
+	summary, err := a.buildClassAttendanceSummary(classID)
+	if err != nil {
+		return err
+	}
+	var teacherID int
+	if err := a.db.QueryRow(`SELECT teacher_id FROM classes WHERE class_id = ?`, classID).Scan(&teacherID); err != nil {
+		return err
+	}
+
+	openAlerts := make(map[string]int)
+	rows, err := a.db.Query(`
+		SELECT id, student_id, alert_type FROM attendance_alerts
+		WHERE class_id = ? AND cleared_at IS NULL
+	`, classID)
+	if err != nil {
+		return fmt.Errorf("failed to load attendance alerts: %w", err)
+	}
+	for rows.Next() {
+		var id, studentID int
+		var alertType string
+		if err := rows.Scan(&id, &studentID, &alertType); err != nil {
+			rows.Close()
+			return err
+		}
+		openAlerts[fmt.Sprintf("%d:%s", studentID, alertType)] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := a.now()
+	classLabel := summary.SubjectCode
+	if summary.Section != "" {
+		classLabel += " (" + summary.Section + ")"
+	}
+	for _, student := range summary.Students {
+		conditions := []struct {
+			alertType   string
+			met         bool
+			detail      string
+			studentText string
+			teacherText string
+		}{
+			{
+				alertType:   attendanceAlertConsecutiveAbsences,
+				met:         summary.Thresholds.ConsecutiveAbsences > 0 && student.ConsecutiveAbsences >= summary.Thresholds.ConsecutiveAbsences,
+				detail:      fmt.Sprintf("%d consecutive absences", student.ConsecutiveAbsences),
+				studentText: fmt.Sprintf("You have been absent from %s for %d consecutive sessions.", classLabel, student.ConsecutiveAbsences),
+				teacherText: fmt.Sprintf("%s (%s) has missed %d consecutive sessions of %s.", student.StudentName, student.StudentCode, student.ConsecutiveAbsences, classLabel),
+			},
+			{
+				alertType: attendanceAlertLowRate,
+				met: summary.Thresholds.MinimumAttendanceRate > 0 && student.TotalSessions >= attendanceRateAlertMinimumSessions &&
+					student.AttendanceRate < float64(summary.Thresholds.MinimumAttendanceRate),
+				detail:      fmt.Sprintf("attendance rate %.1f%%", student.AttendanceRate),
+				studentText: fmt.Sprintf("Your attendance rate in %s is %.1f%%, below the required %d%%.", classLabel, student.AttendanceRate, summary.Thresholds.MinimumAttendanceRate),
+				teacherText: fmt.Sprintf("%s (%s) has an attendance rate of %.1f%% in %s, below %d%%.", student.StudentName, student.StudentCode, student.AttendanceRate, classLabel, summary.Thresholds.MinimumAttendanceRate),
+			},
+		}
+
+		for _, condition := range conditions {
+			key := fmt.Sprintf("%d:%s", student.StudentUserID, condition.alertType)
+			alertID, open := openAlerts[key]
+			switch {
+			case condition.met && !open:
+				if _, err := a.db.Exec(`
+					INSERT INTO attendance_alerts (class_id, student_id, alert_type, detail, raised_at)
+					VALUES (?, ?, ?, ?, ?)
+				`, classID, student.StudentUserID, condition.alertType, condition.detail, now); err != nil {
+					return fmt.Errorf("failed to record attendance alert: %w", err)
+				}
+				a.createNotification(student.StudentUserID, "attendance", "Attendance Alert", condition.studentText,
+					"warning", notifRef("class"), notifRefID(classID))
+				a.createNotification(teacherID, "attendance", "Student Attendance Alert", condition.teacherText,
+					"warning", notifRef("class"), notifRefID(classID))
+				log.Printf("Attendance alert raised: class=%d student=%d %s", classID, student.StudentUserID, condition.detail)
+			case !condition.met && open:
+				if _, err := a.db.Exec(`UPDATE attendance_alerts SET cleared_at = ? WHERE id = ?`, now, alertID); err != nil {
+					return fmt.Errorf("failed to clear attendance alert: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateAttendanceAlertsForSession re-checks the class a session belongs to.
+func (a *App) evaluateAttendanceAlertsForSession(sessionID int) {
+	var classID int
+	if err := a.db.QueryRow(`SELECT class_id FROM attendance_sessions WHERE session_id = ?`, sessionID).Scan(&classID); err != nil {
+		log.Printf("Failed to find class for attendance alerts: session_id=%d err=%v", sessionID, err)
+		return
+	}
+	if err := a.evaluateAttendanceAlerts(classID); err != nil {
+		log.Printf("Failed to evaluate attendance alerts for class %d: %v", classID, err)
+	}
+}
+
+// ==============================================================================
+// CLASS ATTENDANCE SUMMARY EXPORT
+// ==============================================================================
+
+func buildClassAttendanceSummaryDocument(classInfo attendanceExportClassInfo, summary *ClassAttendanceSummary) printableExportDocument {
+	rows := make([][]string, 0, len(summary.Students))
+	for index, student := range summary.Students {
+		status := "OK"
+		if student.AtRisk {
+			status = "AT RISK"
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", index+1),
+			student.StudentCode,
+			student.StudentName,
+			fmt.Sprintf("%d", student.PresentCount),
+			fmt.Sprintf("%d", student.LateCount),
+			fmt.Sprintf("%d", student.AbsentCount),
+			fmt.Sprintf("%d", student.ExcusedCount),
+			fmt.Sprintf("%.1f%%", student.AttendanceRate),
+			fmt.Sprintf("%d", student.ConsecutiveAbsences),
+			status,
+		})
+	}
+
+	thresholdParts := []string{}
+	if summary.Thresholds.ConsecutiveAbsences > 0 {
+		thresholdParts = append(thresholdParts, fmt.Sprintf("%d consecutive absences", summary.Thresholds.ConsecutiveAbsences))
+	}
+	if summary.Thresholds.MinimumAttendanceRate > 0 {
+		thresholdParts = append(thresholdParts, fmt.Sprintf("rate below %d%%", summary.Thresholds.MinimumAttendanceRate))
+	}
+	thresholds := "Off"
+	if len(thresholdParts) > 0 {
+		thresholds = strings.Join(thresholdParts, " or ")
+	}
+
+	return printableExportDocument{
+		Title:    "ATTENDANCE SUMMARY",
+		Subtitle: fmt.Sprintf("%s - %s", classInfo.SubjectCode, classInfo.SubjectName),
+		Details: []printableExportField{
+			{Label: "Instructor", Value: classInfo.TeacherName},
+			{Label: "Schedule", Value: classInfo.Schedule},
+			{Label: "Sessions", Value: fmt.Sprintf("%d", summary.TotalSessions)},
+			{Label: "At Risk When", Value: thresholds},
+		},
+		Headers: []string{"NO.", "STUDENT ID", "STUDENT NAME", "PRESENT", "LATE", "ABSENT", "EXCUSED", "RATE", "STREAK", "STATUS"},
+		Rows:    rows,
+		Footer: []printableExportField{
+			{Label: "Students", Value: fmt.Sprintf("%d", len(summary.Students))},
+			{Label: "Average Rate", Value: fmt.Sprintf("%.1f%%", summary.AverageAttendanceRate)},
+			{Label: "At Risk", Value: fmt.Sprintf("%d", summary.AtRiskCount)},
+		},
+		FooterInline:     true,
+		ColumnWidths:     []float64{10, 26, 44, 16, 14, 16, 16, 16, 16, 16},
+		ColumnAlignments: []string{"C", "L", "L", "C", "C", "C", "C", "C", "C", "C"},
+		Orientation:      "P",
+		GeneratedAt:      time.Now(),
+	}
+}
+
+func (a *App) exportClassAttendanceSummary(methodName string, classID int, format string, savePath string) (string, error) {
+	session, err := a.requireRole(methodName)
+	if err != nil {
+		return "", err
+	}
+	if err := a.checkDB(); err != nil {
+		return "", err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return "", err
+	}
+
+	summary, err := a.buildClassAttendanceSummary(classID)
+	if err != nil {
+		return "", err
+	}
+	doc := buildClassAttendanceSummaryDocument(a.getAttendanceExportClassInfo(classID), summary)
+
+	defaultName := fmt.Sprintf("attendance_summary_%d_%s.%s", classID, time.Now().Format("20060102_150405"), format)
+	filename := resolveExportPath(savePath, defaultName)
+	switch format {
+	case "csv":
+		err = writePrintableCSV(filename, doc)
+	case "pdf":
+		err = writePrintablePDF(filename, doc)
+	default:
+		err = fmt.Errorf("unsupported attendance summary format: %s", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Attendance summary exported to %s: class=%d, file=%s", strings.ToUpper(format), classID, filename)
+	return filename, nil
+}
+
+// ExportClassAttendanceSummaryCSV exports the class attendance summary sheet to CSV.
+// If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
+func (a *App) ExportClassAttendanceSummaryCSV(classID int, savePath string) (string, error) {
+	return a.exportClassAttendanceSummary("ExportClassAttendanceSummaryCSV", classID, "csv", savePath)
+}
+
+// ExportClassAttendanceSummaryPDF exports the class attendance summary sheet to PDF.
+// If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
+func (a *App) ExportClassAttendanceSummaryPDF(classID int, savePath string) (string, error) {
+	return a.exportClassAttendanceSummary("ExportClassAttendanceSummaryPDF", classID, "pdf", savePath)
+}
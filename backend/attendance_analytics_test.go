@@ -0,0 +1,126 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttendanceSummaryAndAtRiskAlerts(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	regularID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	absentID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT301", regularID, absentID)
+
+	alerts := func(title string, userID int) int {
+		return e.queryInt(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND title = ?`, userID, title)
+	}
+
+	var lastSessionID int
+	for i := 0; i < 3; i++ {
+		e.loginAs("T-0001")
+		session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+		if err != nil {
+			t.Fatalf("CreateAttendanceSession %d: %v", i+1, err)
+		}
+		lastSessionID = session.SessionID
+		e.loginAs("2024-00001")
+		if err := e.app.StudentTimeIn(session.SessionID, regularID, e.timeInCode(session.SessionID)); err != nil {
+			t.Fatalf("StudentTimeIn %d: %v", i+1, err)
+		}
+		e.loginAs("T-0001")
+		if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+			t.Fatalf("SaveAttendanceSession %d: %v", i+1, err)
+		}
+		if i < 2 && alerts("Attendance Alert", absentID) != 0 {
+			t.Fatalf("alert raised after %d absence(s)", i+1)
+		}
+		e.clock.Advance(24 * time.Hour)
+	}
+
+	if got := alerts("Attendance Alert", absentID); got != 2 {
+		t.Errorf("student alerts after three absences = %d, want consecutive and rate alerts", got)
+	}
+	if got := alerts("Student Attendance Alert", teacherID); got != 2 {
+		t.Errorf("teacher alerts after three absences = %d, want 2", got)
+	}
+	if got := alerts("Attendance Alert", regularID); got != 0 {
+		t.Errorf("student with full attendance got %d alert(s)", got)
+	}
+
+	e.loginAs("T-0001")
+	summary, err := e.app.GetClassAttendanceSummary(classID)
+	if err != nil {
+		t.Fatalf("GetClassAttendanceSummary: %v", err)
+	}
+	if summary.TotalSessions != 3 || len(summary.Students) != 2 || summary.AtRiskCount != 1 {
+		t.Fatalf("summary = %d sessions, %d students, %d at risk", summary.TotalSessions, len(summary.Students), summary.AtRiskCount)
+	}
+	if summary.AverageAttendanceRate != 50 {
+		t.Errorf("average rate = %v, want 50", summary.AverageAttendanceRate)
+	}
+	ben := summary.Students[1]
+	if ben.StudentUserID != absentID || ben.AbsentCount != 3 || ben.ConsecutiveAbsences != 3 || ben.AttendanceRate != 0 || !ben.AtRisk {
+		t.Errorf("absent student summary = %+v", ben)
+	}
+	if ana := summary.Students[0]; ana.PresentCount != 3 || ana.AttendanceRate != 100 || ana.AtRisk {
+		t.Errorf("regular student summary = %+v", ana)
+	}
+
+	// Correcting the latest absence breaks the streak; the low rate alert stays.
+	if err := e.app.UpdateSessionAttendanceRecord(lastSessionID, absentID, teacherID, "present", "", "Signed the paper sheet"); err != nil {
+		t.Fatalf("UpdateSessionAttendanceRecord: %v", err)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM attendance_alerts WHERE student_id = ? AND cleared_at IS NULL`, absentID); got != 1 {
+		t.Errorf("open alerts after the correction = %d, want only the rate alert", got)
+	}
+
+	e.loginAs("2024-00002")
+	own, err := e.app.GetStudentAttendanceSummary(absentID)
+	if err != nil {
+		t.Fatalf("GetStudentAttendanceSummary: %v", err)
+	}
+	if len(own) != 1 || own[0].ConsecutiveAbsences != 0 || own[0].AttendanceRate != 33.3 || !own[0].AtRisk {
+		t.Errorf("student's own summary = %+v", own)
+	}
+
+	e.loginAs("T-0001")
+	path := filepath.Join(t.TempDir(), "summary.csv")
+	if _, err := e.app.ExportClassAttendanceSummaryCSV(classID, path); err != nil {
+		t.Fatalf("ExportClassAttendanceSummaryCSV: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	if !strings.Contains(string(data), "AT RISK") || !strings.Contains(string(data), "2024-00002") {
+		t.Errorf("summary export is missing the at-risk student:\n%s", data)
+	}
+}
+
+func TestAttendanceAlertThresholdsCanBeTurnedOff(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT302", studentID)
+
+	e.loginAs("T-0001")
+	if err := e.app.SaveClassAttendanceAlertThresholds(classID, teacherID, AttendanceAlertThresholds{}); err != nil {
+		t.Fatalf("SaveClassAttendanceAlertThresholds: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+		if err != nil {
+			t.Fatalf("CreateAttendanceSession: %v", err)
+		}
+		if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+			t.Fatalf("SaveAttendanceSession: %v", err)
+		}
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM attendance_alerts`); got != 0 {
+		t.Errorf("alerts raised with thresholds off = %d", got)
+	}
+}
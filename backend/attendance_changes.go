@@ -0,0 +1,329 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==============================================================================
+// ATTENDANCE CHANGE HISTORY
+// ==============================================================================
+//
+// Teachers may override the status and remarks of an attendance row (for example after a
+// student disputes an absence), but never in place: every change writes an attendance_changes
+// row holding the previous and new values, the editing teacher and a mandatory reason.
+
+const attendanceChangeReasonMaxLength = 500
+
+// AttendanceChange is one manual change to an attendance row.
+type AttendanceChange struct {
+	ID              int     `json:"id"`
+	AttendanceID    int     `json:"attendance_id"`
+	SessionID       *int    `json:"session_id,omitempty"`
+	StudentUserID   int     `json:"student_user_id"`
+	ChangedByUserID *int    `json:"changed_by_user_id,omitempty"`
+	ChangedByName   string  `json:"changed_by_name"`
+	ChangedAt       string  `json:"changed_at"`
+	PreviousStatus  *string `json:"previous_status,omitempty"`
+	NewStatus       *string `json:"new_status,omitempty"`
+	PreviousRemarks *string `json:"previous_remarks,omitempty"`
+	NewRemarks      *string `json:"new_remarks,omitempty"`
+	Reason          string  `json:"reason"`
+}
+
+func normalizeAttendanceOverrideStatus(status string) (string, error) {
+	switch normalized := strings.ToLower(strings.TrimSpace(status)); normalized {
+	case "present", "late", "absent", "excused":
+		return normalized, nil
+	default:
+		return "", fmt.Errorf("invalid attendance status: %s", status)
+	}
+}
+
+func normalizeAttendanceChangeReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", fmt.Errorf("a reason is required to change attendance")
+	}
+	if utf8.RuneCountInString(reason) > attendanceChangeReasonMaxLength {
+		return "", fmt.Errorf("reason must be at most %d characters", attendanceChangeReasonMaxLength)
+	}
+	return reason, nil
+}
+
+// UpdateSessionAttendanceRecord changes one student's status and remarks in a session owned
+// by the teacher; empty remarks fall back to the status label. The previous values are kept
+// in the row's change history together with reason.
+func (a *App) UpdateSessionAttendanceRecord(sessionID, studentUserID, teacherUserID int, status, remarks, reason string) error {
+	session, err := a.requireActingUser("UpdateSessionAttendanceRecord", teacherUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	return a.overrideAttendanceRecord(session, sessionID, studentUserID, status, remarks, reason)
+}
+
+// UpdateAttendanceRecord changes one student's status and remarks on a class's attendance sheet
+// for a date. The date must resolve to a single attendance session; use
+// UpdateSessionAttendanceRecord when the class has several sessions that day.
+func (a *App) UpdateAttendanceRecord(classID, studentUserID int, date, status, remarks, reason string) error {
+	session, err := a.requireRole("UpdateAttendanceRecord")
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+
+	rows, err := a.db.Query(`
+		SELECT a.session_id
+		FROM attendance a
+		WHERE a.class_id = ? AND a.student_id = ? AND a.attendance_date = ?
+			AND a.session_id IS NOT NULL
+	`, classID, studentUserID, date)
+	if err != nil {
+		return err
+	}
+	var sessionIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	switch len(sessionIDs) {
+	case 0:
+		return fmt.Errorf("attendance record not found or not authorized")
+	case 1:
+		return a.overrideAttendanceRecord(session, sessionIDs[0], studentUserID, status, remarks, reason)
+	default:
+		return fmt.Errorf("class has %d attendance sessions on %s; choose a session to edit", len(sessionIDs), date)
+	}
+}
+
+func (a *App) overrideAttendanceRecord(session *appSession, sessionID, studentUserID int, status, remarks, reason string) error {
+	newStatus, err := normalizeAttendanceOverrideStatus(status)
+	if err != nil {
+		return err
+	}
+	reason, err = normalizeAttendanceChangeReason(reason)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var attendanceID int
+	var attendanceDate string
+	var previousStatus, previousRemarks sql.NullString
+	var recordArchived int
+	err = tx.QueryRow(`
+		SELECT a.id, DATE_FORMAT(a.attendance_date, '%Y-%m-%d'), a.status, a.remarks,
+			COALESCE(a.is_archived, 0) + COALESCE(s.is_archived, 0)
+		FROM attendance a
+		JOIN attendance_sessions s ON a.session_id = s.session_id
+		JOIN classes c ON s.class_id = c.class_id
+		WHERE a.session_id = ? AND a.student_id = ? AND c.teacher_id = ?
+	`, sessionID, studentUserID, session.UserID).Scan(&attendanceID, &attendanceDate, &previousStatus, &previousRemarks, &recordArchived)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("attendance record not found or not authorized")
+	}
+	if err != nil {
+		return err
+	}
+	if recordArchived != 0 {
+		return fmt.Errorf("cannot change archived attendance")
+	}
+	if attendanceDate > a.today() {
+		return fmt.Errorf("cannot change future attendance")
+	}
+	newRemarks := *normalizeAttendanceRemark(newStatus, sql.NullString{String: remarks, Valid: true})
+	if previousStatus.Valid && strings.ToLower(strings.TrimSpace(previousStatus.String)) == newStatus &&
+		previousRemarks.String == newRemarks {
+		return fmt.Errorf("attendance is already marked %s", newStatus)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE attendance
+		SET status = ?,
+			remarks = ?,
+			time_in_at = CASE WHEN ? IN ('absent', 'excused') THEN NULL ELSE time_in_at END,
+			updated_at = ?
+		WHERE id = ?
+	`, newStatus, newRemarks, newStatus, a.now(), attendanceID); err != nil {
+		return fmt.Errorf("failed to update attendance: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO attendance_changes
+			(attendance_id, session_id, student_id, changed_by_user_id, changed_by_name, changed_at,
+			 previous_status, new_status, previous_remarks, new_remarks, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, attendanceID, sessionID, studentUserID, session.UserID, session.Username, a.now(),
+		previousStatus, newStatus, previousRemarks, newRemarks, reason); err != nil {
+		return fmt.Errorf("failed to record attendance change: %w", err)
+	}
+
+	if err := a.recordAuditEvent(tx, session, "update_attendance_record", "attendance", attendanceID,
+		auditValues{"status": scanNullString(previousStatus), "remarks": scanNullString(previousRemarks)},
+		auditValues{"status": newStatus, "remarks": newRemarks, "reason": reason}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit attendance change: %w", err)
+	}
+
+	log.Printf("Attendance changed: session=%d, student=%d, %s -> %s by user %d",
+		sessionID, studentUserID, valueOrFallback(previousStatus), newStatus, session.UserID)
+	a.evaluateAttendanceAlertsForSession(sessionID)
+	return nil
+}
+
+// GetAttendanceRecordHistory returns the manual changes made to one student's attendance
+// in a session, oldest first. Teachers can only read the history of their own classes.
+func (a *App) GetAttendanceRecordHistory(sessionID, studentUserID int) ([]AttendanceChange, error) {
+	session, err := a.requireRole("GetAttendanceRecordHistory")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	if session.Role == "teacher" {
+		var owned int
+		err := a.db.QueryRow(`
+			SELECT COUNT(*)
+			FROM attendance_sessions s
+			JOIN classes c ON s.class_id = c.class_id
+			WHERE s.session_id = ? AND c.teacher_id = ?
+		`, sessionID, session.UserID).Scan(&owned)
+		if err != nil {
+			return nil, err
+		}
+		if owned == 0 {
+			return nil, fmt.Errorf("session not found or not authorized")
+		}
+	}
+
+	return a.queryAttendanceChanges(`ac.session_id = ? AND ac.student_id = ?`, sessionID, studentUserID)
+}
+
+func (a *App) queryAttendanceChanges(where string, args ...interface{}) ([]AttendanceChange, error) {
+	rows, err := a.db.Query(`
+		SELECT
+			ac.id,
+			ac.attendance_id,
+			ac.session_id,
+			ac.student_id,
+			ac.changed_by_user_id,
+			COALESCE(CONCAT(t.last_name, ', ', t.first_name), ac.changed_by_name) AS changed_by_name,
+			DATE_FORMAT(ac.changed_at, '%Y-%m-%d %H:%i:%s'),
+			ac.previous_status,
+			ac.new_status,
+			ac.previous_remarks,
+			ac.new_remarks,
+			ac.reason
+		FROM attendance_changes ac
+		LEFT JOIN teachers t ON ac.changed_by_user_id = t.id
+		WHERE `+where+`
+		ORDER BY ac.changed_at, ac.id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attendance changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []AttendanceChange{}
+	for rows.Next() {
+		var change AttendanceChange
+		var sessionID, changedBy sql.NullInt64
+		var previousStatus, newStatus, previousRemarks, newRemarks sql.NullString
+		if err := rows.Scan(&change.ID, &change.AttendanceID, &sessionID, &change.StudentUserID, &changedBy,
+			&change.ChangedByName, &change.ChangedAt, &previousStatus, &newStatus,
+			&previousRemarks, &newRemarks, &change.Reason); err != nil {
+			return nil, err
+		}
+		if sessionID.Valid {
+			id := int(sessionID.Int64)
+			change.SessionID = &id
+		}
+		if changedBy.Valid {
+			id := int(changedBy.Int64)
+			change.ChangedByUserID = &id
+		}
+		change.PreviousStatus = scanNullString(previousStatus)
+		change.NewStatus = scanNullString(newStatus)
+		change.PreviousRemarks = scanNullString(previousRemarks)
+		change.NewRemarks = scanNullString(newRemarks)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// applyAttendanceChangeMarkers flags rows of an exported session sheet that were changed by
+// hand and lists each change, with its reason, below the table.
+func (a *App) applyAttendanceChangeMarkers(doc *printableExportDocument, sessionID int, attendances []Attendance) error {
+	changes, err := a.queryAttendanceChanges(`ac.session_id = ?`, sessionID)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changed := make(map[int]bool, len(changes))
+	for _, change := range changes {
+		changed[change.StudentUserID] = true
+	}
+
+	studentCodes := make(map[int]string, len(attendances))
+	for index, att := range attendances {
+		studentCodes[att.StudentUserID] = att.StudentCode
+		if changed[att.StudentUserID] && index < len(doc.Rows) {
+			row := doc.Rows[index]
+			row[len(row)-1] = strings.TrimSpace(row[len(row)-1] + " *")
+		}
+	}
+
+	doc.TableNote = "* Changed manually. See the change history below."
+	for _, change := range changes {
+		doc.Footer = append(doc.Footer, printableExportField{
+			Label: studentCodes[change.StudentUserID],
+			Value: fmt.Sprintf("%s: %s to %s by %s - %s",
+				change.ChangedAt,
+				attendanceChangeStatusLabel(change.PreviousStatus),
+				attendanceChangeStatusLabel(change.NewStatus),
+				change.ChangedByName,
+				change.Reason),
+		})
+	}
+	return nil
+}
+
+func attendanceChangeStatusLabel(status *string) string {
+	if status == nil {
+		return "Not marked"
+	}
+	if label := normalizeAttendanceRemark(*status, sql.NullString{}); label != nil {
+		return *label
+	}
+	return *status
+}
@@ -0,0 +1,304 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// ROTATING TIME-IN CODES
+// ==============================================================================
+//
+// Every open attendance session shows a short code on the teacher's screen that changes
+// every time_in_code_seconds, as set on the PC that opened the session; the period is
+// stored with the session so every station rotates together. StudentTimeIn requires the current code, so a student has
+// to be in the room to time in and a friend logged in under their account elsewhere
+// cannot. The code is an HMAC of the session ID and the time window, keyed with a
+// per-session secret, so every station computes the same code without talking to the
+// teacher's PC. The code of the previous window is also accepted, for a student who was
+// typing as it changed.
+//
+// Wrong codes, and codes from windows that have already passed (usually a photo or a
+// message from someone in the room), are recorded in attendance_code_attempts for the
+// teacher. After maxTimeInCodeFailures wrong codes within timeInCodeFailureWindow the
+// student has to wait before trying again.
+
+const (
+	defaultTimeInCodeSeconds = 30
+	minTimeInCodeSeconds     = 10
+	maxTimeInCodeSeconds     = 600
+	timeInCodeDigits         = 6
+
+	// timeInCodeReplayLookback is how far back a code is recognized as replayed rather than wrong.
+	timeInCodeReplayLookback = 15 * time.Minute
+	maxTimeInCodeFailures    = 5
+	timeInCodeFailureWindow  = 10 * time.Minute
+
+	timeInCodeInvalid  = "invalid"
+	timeInCodeReplayed = "replayed"
+)
+
+// AttendanceSessionCode is the time-in code shown on the teacher's screen.
+type AttendanceSessionCode struct {
+	SessionID        int    `json:"session_id"`
+	Code             string `json:"code"`
+	RotationSeconds  int    `json:"rotation_seconds"`
+	SecondsRemaining int    `json:"seconds_remaining"`
+	ExpiresAt        string `json:"expires_at"`
+}
+
+// AttendanceCodeAttempt is a wrong or replayed time-in code entered by a student.
+type AttendanceCodeAttempt struct {
+	ID          int     `json:"id"`
+	StudentID   int     `json:"student_user_id"`
+	StudentCode string  `json:"student_code"`
+	FirstName   string  `json:"first_name"`
+	LastName    string  `json:"last_name"`
+	PCNumber    *string `json:"pc_number,omitempty"`
+	Code        string  `json:"code"`
+	Result      string  `json:"result"`
+	AttemptedAt string  `json:"attempted_at"`
+}
+
+// loadTimeInCodeSeconds reads [policy] time_in_code_seconds (or TIME_IN_CODE_SECONDS).
+// It is only read when a session opens; afterwards the session's own period is used.
+func loadTimeInCodeSeconds() int {
+	return loadPolicyInt("TIME_IN_CODE_SECONDS", "time_in_code_seconds", minTimeInCodeSeconds, maxTimeInCodeSeconds, defaultTimeInCodeSeconds)
+}
+
+// attendanceCodeSettings returns the session's code secret and rotation period. The
+// secret is created on first use, as is the period of a session opened before it was
+// stored with the session.
+func attendanceCodeSettings(exec dbExecutor, sessionID int) ([]byte, int, error) {
+	var secret sql.NullString
+	var rotation sql.NullInt64
+	load := func() error {
+		return exec.QueryRow(`
+			SELECT code_secret, code_rotation_seconds FROM attendance_sessions WHERE session_id = ?
+		`, sessionID).Scan(&secret, &rotation)
+	}
+	if err := load(); err != nil {
+		return nil, 0, fmt.Errorf("attendance session not found")
+	}
+	if !secret.Valid || secret.String == "" || !rotation.Valid {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, 0, fmt.Errorf("failed to generate time-in code secret: %w", err)
+		}
+		// Two stations may get here at once; whichever write lands first is kept.
+		if _, err := exec.Exec(`
+			UPDATE attendance_sessions
+			SET code_secret = COALESCE(code_secret, ?), code_rotation_seconds = COALESCE(code_rotation_seconds, ?)
+			WHERE session_id = ?
+		`, hex.EncodeToString(buf), loadTimeInCodeSeconds(), sessionID); err != nil {
+			return nil, 0, fmt.Errorf("failed to save time-in code secret: %w", err)
+		}
+		if err := load(); err != nil {
+			return nil, 0, fmt.Errorf("failed to load time-in code secret: %w", err)
+		}
+	}
+	seconds := int(rotation.Int64)
+	if seconds < minTimeInCodeSeconds || seconds > maxTimeInCodeSeconds {
+		seconds = defaultTimeInCodeSeconds
+	}
+	key, err := hex.DecodeString(secret.String)
+	return key, seconds, err
+}
+
+// attendanceCodeAt returns the code for a session and time window.
+func attendanceCodeAt(secret []byte, sessionID int, window int64) string {
+	var msg [16]byte
+	binary.BigEndian.PutUint64(msg[:8], uint64(sessionID))
+	binary.BigEndian.PutUint64(msg[8:], uint64(window))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < timeInCodeDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", timeInCodeDigits, value%modulus)
+}
+
+// attendanceCodeWindow returns the time window at t for a rotation period.
+func attendanceCodeWindow(t time.Time, rotationSeconds int) int64 {
+	return t.Unix() / int64(rotationSeconds)
+}
+
+// matchAttendanceCode checks a code entered at time at. It returns "" when the code is
+// current (or from the previous window), timeInCodeReplayed when it belongs to an older
+// window and timeInCodeInvalid otherwise.
+func matchAttendanceCode(secret []byte, sessionID int, code string, at time.Time, rotationSeconds int) string {
+	code = normalizeAttendanceCode(code)
+	if len(code) != timeInCodeDigits {
+		return timeInCodeInvalid
+	}
+	current := attendanceCodeWindow(at, rotationSeconds)
+	oldest := attendanceCodeWindow(at.Add(-timeInCodeReplayLookback), rotationSeconds)
+	for window := current; window >= oldest; window-- {
+		if subtle.ConstantTimeCompare([]byte(attendanceCodeAt(secret, sessionID, window)), []byte(code)) == 1 {
+			if window >= current-1 {
+				return ""
+			}
+			return timeInCodeReplayed
+		}
+	}
+	return timeInCodeInvalid
+}
+
+// normalizeAttendanceCode drops the spaces and dashes a student may type inside a code.
+func normalizeAttendanceCode(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+}
+
+// recordAttendanceCodeAttempt logs a wrong or replayed code for the teacher.
+func recordAttendanceCodeAttempt(exec dbExecutor, sessionID, studentUserID int, station, code, result string, at time.Time) {
+	code = normalizeAttendanceCode(code)
+	if len(code) > 20 {
+		code = code[:20]
+	}
+	if _, err := exec.Exec(`
+		INSERT INTO attendance_code_attempts (session_id, student_id, pc_number, code, result, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, sessionID, studentUserID, nullString(station), code, result, at); err != nil {
+		log.Printf("Failed to record time-in code attempt: session_id=%d student_id=%d err=%v", sessionID, studentUserID, err)
+	}
+}
+
+// verifyTimeInCode checks the code a student entered in StudentTimeIn and records a
+// wrong or replayed one.
+func (a *App) verifyTimeInCode(sessionID, studentUserID int, code string) error {
+	now := a.now()
+	var failures int
+	if err := a.db.QueryRow(`
+		SELECT COUNT(*) FROM attendance_code_attempts
+		WHERE session_id = ? AND student_id = ? AND attempted_at > ?
+	`, sessionID, studentUserID, now.Add(-timeInCodeFailureWindow)).Scan(&failures); err != nil {
+		return fmt.Errorf("failed to check time-in code attempts: %w", err)
+	}
+	if failures >= maxTimeInCodeFailures {
+		return fmt.Errorf("too many wrong time-in codes - wait a few minutes or ask your teacher")
+	}
+
+	secret, rotation, err := attendanceCodeSettings(a.db, sessionID)
+	if err != nil {
+		return err
+	}
+	result := matchAttendanceCode(secret, sessionID, code, now, rotation)
+	if result == "" {
+		return nil
+	}
+
+	recordAttendanceCodeAttempt(a.db, sessionID, studentUserID, a.currentStationLabel(), code, result, now)
+	log.Printf("Time-in code %s: session_id=%d student_id=%d station=%q", result, sessionID, studentUserID, a.currentStationLabel())
+	if result == timeInCodeReplayed {
+		return fmt.Errorf("that time-in code has expired - enter the code on the teacher's screen now")
+	}
+	return fmt.Errorf("wrong time-in code")
+}
+
+// GetAttendanceSessionCode returns the current time-in code of an open session for the
+// teacher's screen. The frontend fetches it again when SecondsRemaining runs out.
+func (a *App) GetAttendanceSessionCode(sessionID int, teacherUserID int) (*AttendanceSessionCode, error) {
+	if _, err := a.requireActingUser("GetAttendanceSessionCode", teacherUserID); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	var status string
+	err := a.db.QueryRow(`
+		SELECT s.status
+		FROM attendance_sessions s
+		JOIN classes c ON s.class_id = c.class_id
+		WHERE s.session_id = ? AND c.teacher_id = ?
+	`, sessionID, teacherUserID).Scan(&status)
+	if err != nil {
+		return nil, fmt.Errorf("session not found or not authorized")
+	}
+	if status != "open" {
+		return nil, fmt.Errorf("attendance session is closed")
+	}
+
+	secret, rotation, err := attendanceCodeSettings(a.db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	now := a.now()
+	window := attendanceCodeWindow(now, rotation)
+	expiresAt := time.Unix((window+1)*int64(rotation), 0).In(now.Location())
+	remaining := int(expiresAt.Sub(now).Seconds())
+	if remaining < 1 {
+		remaining = 1
+	}
+
+	return &AttendanceSessionCode{
+		SessionID:        sessionID,
+		Code:             attendanceCodeAt(secret, sessionID, window),
+		RotationSeconds:  rotation,
+		SecondsRemaining: remaining,
+		ExpiresAt:        formatTime(expiresAt),
+	}, nil
+}
+
+// GetAttendanceCodeAttempts lists the wrong and replayed time-in codes entered in a
+// session, newest first.
+func (a *App) GetAttendanceCodeAttempts(sessionID int, teacherUserID int) ([]AttendanceCodeAttempt, error) {
+	if _, err := a.requireActingUser("GetAttendanceCodeAttempts", teacherUserID); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.Query(`
+		SELECT
+			ca.id,
+			ca.student_id,
+			COALESCE(stu.student_id, ''),
+			COALESCE(stu.first_name, ''),
+			COALESCE(stu.last_name, ''),
+			ca.pc_number,
+			ca.code,
+			ca.result,
+			DATE_FORMAT(ca.attempted_at, '%Y-%m-%d %H:%i:%s')
+		FROM attendance_code_attempts ca
+		JOIN attendance_sessions s ON s.session_id = ca.session_id
+		JOIN classes c ON c.class_id = s.class_id
+		LEFT JOIN students stu ON stu.id = ca.student_id
+		WHERE ca.session_id = ? AND c.teacher_id = ?
+		ORDER BY ca.attempted_at DESC, ca.id DESC
+	`, sessionID, teacherUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time-in code attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []AttendanceCodeAttempt{}
+	for rows.Next() {
+		var attempt AttendanceCodeAttempt
+		var pcNumber sql.NullString
+		if err := rows.Scan(&attempt.ID, &attempt.StudentID, &attempt.StudentCode, &attempt.FirstName, &attempt.LastName,
+			&pcNumber, &attempt.Code, &attempt.Result, &attempt.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to load time-in code attempts: %w", err)
+		}
+		if pcNumber.Valid {
+			attempt.PCNumber = &pcNumber.String
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
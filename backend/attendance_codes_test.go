@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// timeInCode returns the session's current time-in code, as shown on the teacher's screen.
+func (e *testEnv) timeInCode(sessionID int) string {
+	e.t.Helper()
+	secret, rotation, err := attendanceCodeSettings(e.db, sessionID)
+	if err != nil {
+		e.t.Fatalf("attendanceCodeSettings: %v", err)
+	}
+	return attendanceCodeAt(secret, sessionID, attendanceCodeWindow(e.clock.Now(), rotation))
+}
+
+func TestStudentTimeInRequiresRotatingCode(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.seedUser("teacher", "T-0002", "Tom", "Bautista")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	carlID := e.seedUser("student", "2024-00003", "Carl", "Lim")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID, carlID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	shown, err := e.app.GetAttendanceSessionCode(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetAttendanceSessionCode: %v", err)
+	}
+	if len(shown.Code) != timeInCodeDigits || shown.Code != e.timeInCode(session.SessionID) || shown.SecondsRemaining > shown.RotationSeconds {
+		t.Fatalf("teacher's code = %+v", shown)
+	}
+	otherTeacherID := e.loginAs("T-0002").ID
+	if _, err := e.app.GetAttendanceSessionCode(session.SessionID, otherTeacherID); err == nil {
+		t.Errorf("another teacher was shown the session's code")
+	}
+
+	// A wrong code is refused and logged.
+	e.loginAs("2024-00001")
+	wrong := "000000"
+	if wrong == shown.Code {
+		wrong = "111111"
+	}
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, wrong); err == nil {
+		t.Fatalf("time-in with a wrong code was accepted")
+	}
+
+	// The code of the previous window still works while the student is typing.
+	e.clock.Advance(time.Duration(shown.RotationSeconds) * time.Second)
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, shown.Code); err != nil {
+		t.Fatalf("StudentTimeIn with the previous window's code: %v", err)
+	}
+
+	// An older code is a replay: refused and logged as such.
+	e.clock.Advance(time.Duration(2*shown.RotationSeconds) * time.Second)
+	e.loginAs("2024-00002")
+	if err := e.app.StudentTimeIn(session.SessionID, benID, shown.Code); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("replayed code: err = %v, want expired error", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got == "present" || got == "late" {
+		t.Errorf("replayed code timed the student in as %q", got)
+	}
+
+	// Repeated wrong codes lock the student out of the session for a while.
+	e.loginAs("2024-00003")
+	for i := 0; i < maxTimeInCodeFailures; i++ {
+		_ = e.app.StudentTimeIn(session.SessionID, carlID, "12345")
+	}
+	if err := e.app.StudentTimeIn(session.SessionID, carlID, e.timeInCode(session.SessionID)); err == nil || !strings.Contains(err.Error(), "too many") {
+		t.Fatalf("time-in after %d wrong codes: err = %v", maxTimeInCodeFailures, err)
+	}
+	e.clock.Advance(timeInCodeFailureWindow)
+	e.loginAs("2024-00003")
+	if err := e.app.StudentTimeIn(session.SessionID, carlID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn after the failure window: %v", err)
+	}
+
+	e.loginAs("T-0001")
+	attempts, err := e.app.GetAttendanceCodeAttempts(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetAttendanceCodeAttempts: %v", err)
+	}
+	results := map[string]int{}
+	for _, attempt := range attempts {
+		results[attempt.Result]++
+	}
+	if len(attempts) != maxTimeInCodeFailures+2 || results[timeInCodeReplayed] != 1 || attempts[len(attempts)-1].StudentID != anaID {
+		t.Errorf("logged attempts = %+v", attempts)
+	}
+}
+
+func TestCodeRotationIsFixedWhenTheSessionOpens(t *testing.T) {
+	t.Setenv("TIME_IN_CODE_SECONDS", "120")
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT101", anaID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	shown, err := e.app.GetAttendanceSessionCode(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetAttendanceSessionCode: %v", err)
+	}
+
+	// The student's PC is set to rotate every 10 seconds; the session's period still wins.
+	t.Setenv("TIME_IN_CODE_SECONDS", "10")
+	if again, err := e.app.GetAttendanceSessionCode(session.SessionID, teacherID); err != nil || again.RotationSeconds != 120 {
+		t.Fatalf("GetAttendanceSessionCode after a config change = %+v, %v; want the session's 120 seconds", again, err)
+	}
+	e.clock.Advance(time.Duration(shown.SecondsRemaining-1) * time.Second)
+	keepStationsAlive(e)
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, shown.Code); err != nil {
+		t.Fatalf("StudentTimeIn with the session's current code: %v", err)
+	}
+}
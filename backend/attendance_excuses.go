@@ -0,0 +1,566 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ==============================================================================
+// EXCUSE AND LEAVE REQUESTS
+// ==============================================================================
+//
+// A student asks to be excused from one attendance session, or from every session of a
+// class over a range of dates (a leave), with a reason and optionally a document such as
+// a medical certificate. The class teacher approves or denies the request. Approval
+// marks the covered attendance rows 'excused' if they are absent or not yet marked; rows
+// the student timed in to are left alone. Sessions opened later within an approved
+// range start with the student already excused. Every row changed this way gets an
+// attendance_changes entry, like a manual change.
+
+const (
+	excuseStatusPending   = "pending"
+	excuseStatusApproved  = "approved"
+	excuseStatusDenied    = "denied"
+	excuseStatusCancelled = "cancelled"
+
+	attendanceStatusExcused = "excused"
+
+	excuseReasonMaxLength     = 1000
+	excuseReviewNoteMaxLength = 500
+	excuseDocumentNameMaxLen  = 255
+	maxExcuseDocumentBytes    = 5 * 1024 * 1024
+	// A leave longer than this should go through the registrar, not a class teacher.
+	maxExcuseRangeDays = 31
+)
+
+var excuseDocumentTypes = []string{"data:image/", "data:application/pdf"}
+
+// ExcuseRequestInput is what a student submits. SessionID, when set, takes precedence
+// over the dates.
+type ExcuseRequestInput struct {
+	ClassID      int    `json:"class_id"`
+	SessionID    int    `json:"session_id"`
+	StartDate    string `json:"start_date"`
+	EndDate      string `json:"end_date"`
+	Reason       string `json:"reason"`
+	DocumentName string `json:"document_name"`
+	// DocumentData is a data URL (image or PDF), at most 5MB decoded.
+	DocumentData string `json:"document_data"`
+}
+
+// ExcuseRequest is a submitted excuse request. The document itself is fetched with
+// GetExcuseRequestDocument.
+type ExcuseRequest struct {
+	ID             int     `json:"id"`
+	StudentUserID  int     `json:"student_user_id"`
+	StudentCode    string  `json:"student_code"`
+	StudentName    string  `json:"student_name"`
+	ClassID        int     `json:"class_id"`
+	SubjectCode    string  `json:"subject_code"`
+	SubjectName    string  `json:"subject_name"`
+	SessionID      *int    `json:"session_id,omitempty"`
+	SessionName    *string `json:"session_name,omitempty"`
+	StartDate      string  `json:"start_date"`
+	EndDate        string  `json:"end_date"`
+	Reason         string  `json:"reason"`
+	DocumentName   *string `json:"document_name,omitempty"`
+	HasDocument    bool    `json:"has_document"`
+	Status         string  `json:"status"`
+	SubmittedAt    string  `json:"submitted_at"`
+	ReviewedByName *string `json:"reviewed_by_name,omitempty"`
+	ReviewedAt     *string `json:"reviewed_at,omitempty"`
+	ReviewNote     *string `json:"review_note,omitempty"`
+}
+
+func normalizeExcuseReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", fmt.Errorf("a reason is required")
+	}
+	if utf8.RuneCountInString(reason) > excuseReasonMaxLength {
+		return "", fmt.Errorf("reason must be at most %d characters", excuseReasonMaxLength)
+	}
+	return reason, nil
+}
+
+// normalizeExcuseDocument checks an attached document. An empty data URL means no document.
+func normalizeExcuseDocument(name, dataURL string) (sql.NullString, sql.NullString, error) {
+	dataURL = strings.TrimSpace(dataURL)
+	if dataURL == "" {
+		return sql.NullString{}, sql.NullString{}, nil
+	}
+	allowed := false
+	for _, prefix := range excuseDocumentTypes {
+		if strings.HasPrefix(dataURL, prefix) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return sql.NullString{}, sql.NullString{}, fmt.Errorf("the document must be an image or a PDF")
+	}
+	parts := strings.SplitN(dataURL, ",", 2)
+	if len(parts) != 2 || !strings.HasSuffix(parts[0], ";base64") {
+		return sql.NullString{}, sql.NullString{}, fmt.Errorf("invalid document data")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return sql.NullString{}, sql.NullString{}, fmt.Errorf("invalid document data: %w", err)
+	}
+	if len(decoded) > maxExcuseDocumentBytes {
+		return sql.NullString{}, sql.NullString{}, fmt.Errorf("document exceeds maximum size of 5MB")
+	}
+	name = SanitizeString(name, excuseDocumentNameMaxLen)
+	if name == "" {
+		name = "document"
+	}
+	return nullString(name), nullString(dataURL), nil
+}
+
+// SubmitExcuseRequest files an excuse request for one of the student's classes and
+// notifies the class teacher.
+func (a *App) SubmitExcuseRequest(studentUserID int, input ExcuseRequestInput) (*ExcuseRequest, error) {
+	session, err := a.requireActingUser("SubmitExcuseRequest", studentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := ValidatePositiveID(input.ClassID, "class ID"); err != nil {
+		return nil, err
+	}
+
+	var enrolled int
+	if err := a.db.QueryRow(`
+		SELECT COUNT(*) FROM joined_classes
+		WHERE class_id = ? AND student_id = ?
+			AND status IN ('join', 'added', 'active')
+			AND (is_archived = 0 OR is_archived IS NULL)
+	`, input.ClassID, studentUserID).Scan(&enrolled); err != nil {
+		return nil, err
+	}
+	if enrolled == 0 {
+		return nil, fmt.Errorf("you are not enrolled in this class")
+	}
+
+	var sessionID sql.NullInt64
+	startDate, endDate := strings.TrimSpace(input.StartDate), strings.TrimSpace(input.EndDate)
+	if input.SessionID > 0 {
+		var sessionClassID int
+		err := a.db.QueryRow(`
+			SELECT class_id, DATE_FORMAT(attendance_date, '%Y-%m-%d') FROM attendance_sessions
+			WHERE session_id = ? AND COALESCE(is_archived, 0) = 0
+		`, input.SessionID).Scan(&sessionClassID, &startDate)
+		if err == sql.ErrNoRows || (err == nil && sessionClassID != input.ClassID) {
+			return nil, fmt.Errorf("attendance session not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessionID = sql.NullInt64{Int64: int64(input.SessionID), Valid: true}
+		endDate = startDate
+	} else {
+		if endDate == "" {
+			endDate = startDate
+		}
+		start, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start date")
+		}
+		end, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end date")
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("end date is before start date")
+		}
+		if end.Sub(start) >= maxExcuseRangeDays*24*time.Hour {
+			return nil, fmt.Errorf("an excuse request can cover at most %d days", maxExcuseRangeDays)
+		}
+	}
+
+	reason, err := normalizeExcuseReason(input.Reason)
+	if err != nil {
+		return nil, err
+	}
+	documentName, documentData, err := normalizeExcuseDocument(input.DocumentName, input.DocumentData)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlapping int
+	if err := a.db.QueryRow(`
+		SELECT COUNT(*) FROM attendance_excuse_requests
+		WHERE student_id = ? AND class_id = ? AND status = ?
+			AND start_date <= ? AND end_date >= ?
+	`, studentUserID, input.ClassID, excuseStatusPending, endDate, startDate).Scan(&overlapping); err != nil {
+		return nil, err
+	}
+	if overlapping > 0 {
+		return nil, fmt.Errorf("you already have a pending excuse request for these dates")
+	}
+
+	result, err := a.db.Exec(`
+		INSERT INTO attendance_excuse_requests
+			(student_id, class_id, session_id, start_date, end_date, reason, document_name, document_data, status, submitted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, studentUserID, input.ClassID, sessionID, startDate, endDate, reason, documentName, documentData,
+		excuseStatusPending, a.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit excuse request: %w", err)
+	}
+	requestID64, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	requestID := int(requestID64)
+
+	a.audit(session, "submit_excuse_request", "excuse_request", requestID, nil,
+		auditValues{"class_id": input.ClassID, "session_id": input.SessionID, "start_date": startDate, "end_date": endDate})
+
+	request, err := a.getExcuseRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+	var teacherID int
+	if err := a.db.QueryRow(`SELECT teacher_id FROM classes WHERE class_id = ?`, input.ClassID).Scan(&teacherID); err == nil {
+		go a.createNotification(teacherID, "attendance", "Excuse Request",
+			fmt.Sprintf("%s asked to be excused from %s %s.", request.StudentName, request.SubjectCode, describeExcuseDates(request)),
+			"info", notifRef("excuse_request"), notifRefID(requestID))
+	}
+	return request, nil
+}
+
+// CancelExcuseRequest withdraws one of the student's pending requests.
+func (a *App) CancelExcuseRequest(requestID int, studentUserID int) error {
+	session, err := a.requireActingUser("CancelExcuseRequest", studentUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	result, err := a.db.Exec(`
+		UPDATE attendance_excuse_requests SET status = ?
+		WHERE id = ? AND student_id = ? AND status = ?
+	`, excuseStatusCancelled, requestID, studentUserID, excuseStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to cancel excuse request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("pending excuse request not found")
+	}
+	a.audit(session, "cancel_excuse_request", "excuse_request", requestID,
+		auditValues{"status": excuseStatusPending}, auditValues{"status": excuseStatusCancelled})
+	return nil
+}
+
+// GetStudentExcuseRequests returns the student's excuse requests, newest first.
+func (a *App) GetStudentExcuseRequests(studentUserID int) ([]ExcuseRequest, error) {
+	if _, err := a.requireActingUser("GetStudentExcuseRequests", studentUserID); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	return a.queryExcuseRequests(`r.student_id = ?`, studentUserID)
+}
+
+// GetClassExcuseRequests returns a class's excuse requests, newest first. status filters
+// them (pending, approved, denied or cancelled); empty returns all.
+func (a *App) GetClassExcuseRequests(classID int, status string) ([]ExcuseRequest, error) {
+	session, err := a.requireRole("GetClassExcuseRequests")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	switch status = strings.ToLower(strings.TrimSpace(status)); status {
+	case "":
+		return a.queryExcuseRequests(`r.class_id = ?`, classID)
+	case excuseStatusPending, excuseStatusApproved, excuseStatusDenied, excuseStatusCancelled:
+		return a.queryExcuseRequests(`r.class_id = ? AND r.status = ?`, classID, status)
+	default:
+		return nil, fmt.Errorf("invalid excuse request status: %s", status)
+	}
+}
+
+// GetExcuseRequestDocument returns the document attached to a request as a data URL, or
+// "" when there is none. Students can only read their own; teachers their classes'.
+func (a *App) GetExcuseRequestDocument(requestID int) (string, error) {
+	session, err := a.requireRole("GetExcuseRequestDocument")
+	if err != nil {
+		return "", err
+	}
+	if err := a.checkDB(); err != nil {
+		return "", err
+	}
+	var studentID, classID int
+	var document sql.NullString
+	err = a.db.QueryRow(`
+		SELECT student_id, class_id, document_data FROM attendance_excuse_requests WHERE id = ?
+	`, requestID).Scan(&studentID, &classID, &document)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("excuse request not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	if containsRole(studentRoles, session.Role) {
+		if studentID != session.UserID {
+			return "", fmt.Errorf("excuse request not found")
+		}
+	} else if err := a.checkClassAccess(session, classID); err != nil {
+		return "", err
+	}
+	return document.String, nil
+}
+
+// ReviewExcuseRequest approves or denies a pending request for one of the teacher's
+// classes. Approval excuses the covered attendance; either way the student is notified.
+func (a *App) ReviewExcuseRequest(requestID int, teacherUserID int, approve bool, note string) error {
+	session, err := a.requireActingUser("ReviewExcuseRequest", teacherUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > excuseReviewNoteMaxLength {
+		return fmt.Errorf("note must be at most %d characters", excuseReviewNoteMaxLength)
+	}
+	if !approve && note == "" {
+		return fmt.Errorf("a note is required to deny an excuse request")
+	}
+
+	request, err := a.getExcuseRequest(requestID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkClassAccess(session, request.ClassID); err != nil {
+		return err
+	}
+	if request.Status != excuseStatusPending {
+		return fmt.Errorf("excuse request is already %s", request.Status)
+	}
+
+	newStatus := excuseStatusDenied
+	if approve {
+		newStatus = excuseStatusApproved
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE attendance_excuse_requests
+		SET status = ?, reviewed_by_user_id = ?, reviewed_at = ?, review_note = ?
+		WHERE id = ? AND status = ?
+	`, newStatus, session.UserID, a.now(), nullString(note), requestID, excuseStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to review excuse request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("excuse request is no longer pending")
+	}
+
+	excused := 0
+	if approve {
+		if excused, err = excuseAttendanceRows(tx, a.now(), `r.id = ?`, requestID); err != nil {
+			return err
+		}
+	}
+
+	if err := a.recordAuditEvent(tx, session, "review_excuse_request", "excuse_request", requestID,
+		auditValues{"status": excuseStatusPending},
+		auditValues{"status": newStatus, "note": note, "excused_records": excused}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit excuse review: %w", err)
+	}
+
+	log.Printf("Excuse request %d %s by user %d (%d attendance record(s) excused)", requestID, newStatus, session.UserID, excused)
+	if excused > 0 {
+		if err := a.evaluateAttendanceAlerts(request.ClassID); err != nil {
+			log.Printf("Failed to evaluate attendance alerts for class %d: %v", request.ClassID, err)
+		}
+	}
+
+	title, tone := "Excuse Approved", "success"
+	message := fmt.Sprintf("Your excuse for %s %s was approved.", request.SubjectCode, describeExcuseDates(request))
+	if !approve {
+		title, tone = "Excuse Denied", "warning"
+		message = fmt.Sprintf("Your excuse for %s %s was denied.", request.SubjectCode, describeExcuseDates(request))
+	}
+	if note != "" {
+		message += " Note: " + note
+	}
+	go a.createNotification(request.StudentUserID, "attendance", title, message, tone, notifRef("excuse_request"), notifRefID(requestID))
+	return nil
+}
+
+// excuseAttendanceRows marks absent or unmarked attendance rows covered by approved
+// requests (alias r, matched by where) as excused, recording each change. It returns how
+// many rows it changed.
+func excuseAttendanceRows(exec dbExecutor, at time.Time, where string, args ...interface{}) (int, error) {
+	rows, err := exec.Query(`
+		SELECT a.id, a.session_id, a.student_id, a.status, a.remarks, r.id, r.reviewed_by_user_id, COALESCE(u.username, '')
+		FROM attendance a
+		JOIN attendance_excuse_requests r
+			ON r.student_id = a.student_id
+			AND r.class_id = a.class_id
+			AND (r.session_id = a.session_id
+				OR (r.session_id IS NULL AND a.attendance_date BETWEEN r.start_date AND r.end_date))
+		LEFT JOIN users u ON u.id = r.reviewed_by_user_id
+		WHERE r.status = 'approved'
+			AND a.session_id IS NOT NULL
+			AND COALESCE(a.is_archived, 0) = 0
+			AND LOWER(LTRIM(RTRIM(COALESCE(a.status, '')))) IN ('', 'absent')
+			AND `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load attendance to excuse: %w", err)
+	}
+
+	type excusedRow struct {
+		attendanceID, sessionID, studentID, requestID int
+		previousStatus, previousRemarks               sql.NullString
+		reviewerID                                    sql.NullInt64
+		reviewerName                                  string
+	}
+	var pending []excusedRow
+	for rows.Next() {
+		var row excusedRow
+		if err := rows.Scan(&row.attendanceID, &row.sessionID, &row.studentID, &row.previousStatus,
+			&row.previousRemarks, &row.requestID, &row.reviewerID, &row.reviewerName); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to load attendance to excuse: %w", err)
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+
+	remarks := *normalizeAttendanceRemark(attendanceStatusExcused, sql.NullString{})
+	excused := 0
+	for _, row := range pending {
+		if _, err := exec.Exec(`
+			UPDATE attendance
+			SET status = ?, remarks = ?, time_in_at = NULL, updated_at = ?
+			WHERE id = ?
+		`, attendanceStatusExcused, remarks, at, row.attendanceID); err != nil {
+			return excused, fmt.Errorf("failed to excuse attendance: %w", err)
+		}
+		if _, err := exec.Exec(`
+			INSERT INTO attendance_changes
+				(attendance_id, session_id, student_id, changed_by_user_id, changed_by_name, changed_at,
+				 previous_status, new_status, previous_remarks, new_remarks, reason)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, row.attendanceID, row.sessionID, row.studentID, row.reviewerID, row.reviewerName, at,
+			row.previousStatus, attendanceStatusExcused, row.previousRemarks, remarks,
+			fmt.Sprintf("Excuse request #%d approved", row.requestID)); err != nil {
+			return excused, fmt.Errorf("failed to record attendance change: %w", err)
+		}
+		excused++
+	}
+	return excused, nil
+}
+
+// describeExcuseDates is "on 2024-03-04" or "from 2024-03-04 to 2024-03-08".
+func describeExcuseDates(request *ExcuseRequest) string {
+	if request.StartDate == request.EndDate {
+		return "on " + request.StartDate
+	}
+	return fmt.Sprintf("from %s to %s", request.StartDate, request.EndDate)
+}
+
+func (a *App) getExcuseRequest(requestID int) (*ExcuseRequest, error) {
+	requests, err := a.queryExcuseRequests(`r.id = ?`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("excuse request not found")
+	}
+	return &requests[0], nil
+}
+
+func (a *App) queryExcuseRequests(where string, args ...interface{}) ([]ExcuseRequest, error) {
+	rows, err := a.db.Query(`
+		SELECT
+			r.id,
+			r.student_id,
+			stu.student_id,
+			stu.first_name,
+			stu.middle_name,
+			stu.last_name,
+			r.class_id,
+			c.subject_code,
+			subj.description,
+			r.session_id,
+			ses.session_name,
+			DATE_FORMAT(r.start_date, '%Y-%m-%d'),
+			DATE_FORMAT(r.end_date, '%Y-%m-%d'),
+			r.reason,
+			r.document_name,
+			r.document_data IS NOT NULL,
+			r.status,
+			DATE_FORMAT(r.submitted_at, '%Y-%m-%d %H:%i:%s'),
+			CONCAT(t.last_name, ', ', t.first_name),
+			DATE_FORMAT(r.reviewed_at, '%Y-%m-%d %H:%i:%s'),
+			r.review_note
+		FROM attendance_excuse_requests r
+		JOIN students stu ON r.student_id = stu.id
+		JOIN classes c ON r.class_id = c.class_id
+		JOIN subjects subj ON c.subject_code = subj.subject_code
+		LEFT JOIN attendance_sessions ses ON r.session_id = ses.session_id
+		LEFT JOIN teachers t ON r.reviewed_by_user_id = t.id
+		WHERE `+where+`
+		ORDER BY r.submitted_at DESC, r.id DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch excuse requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []ExcuseRequest{}
+	for rows.Next() {
+		var request ExcuseRequest
+		var firstName, lastName string
+		var middleName, sessionName, documentName, reviewedBy, reviewedAt, reviewNote sql.NullString
+		var sessionID sql.NullInt64
+		if err := rows.Scan(&request.ID, &request.StudentUserID, &request.StudentCode, &firstName, &middleName, &lastName,
+			&request.ClassID, &request.SubjectCode, &request.SubjectName, &sessionID, &sessionName,
+			&request.StartDate, &request.EndDate, &request.Reason, &documentName, &request.HasDocument,
+			&request.Status, &request.SubmittedAt, &reviewedBy, &reviewedAt, &reviewNote); err != nil {
+			return nil, err
+		}
+		request.StudentName = buildAttendanceExportName(Attendance{
+			FirstName: firstName, MiddleName: scanNullString(middleName), LastName: lastName,
+		})
+		if sessionID.Valid {
+			id := int(sessionID.Int64)
+			request.SessionID = &id
+		}
+		request.SessionName = scanNullString(sessionName)
+		request.DocumentName = scanNullString(documentName)
+		request.ReviewedByName = scanNullString(reviewedBy)
+		request.ReviewedAt = scanNullString(reviewedAt)
+		request.ReviewNote = scanNullString(reviewNote)
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
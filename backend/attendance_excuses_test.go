@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApprovedExcuseMarksSessionExcused(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.seedUser("teacher", "T-0002", "Tom", "Dela Cruz")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	outsiderID := e.seedUser("student", "2024-00003", "Carl", "Lim")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+
+	e.loginAs("2024-00003")
+	if _, err := e.app.SubmitExcuseRequest(outsiderID, ExcuseRequestInput{ClassID: classID, SessionID: session.SessionID, Reason: "Sick"}); err == nil {
+		t.Errorf("a student outside the class filed an excuse")
+	}
+
+	e.loginAs("2024-00001")
+	if _, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{ClassID: classID, SessionID: session.SessionID}); err == nil {
+		t.Errorf("filed an excuse without a reason")
+	}
+	if _, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{
+		ClassID: classID, SessionID: session.SessionID, Reason: "Fever", DocumentData: "data:text/plain;base64,aGk=",
+	}); err == nil {
+		t.Errorf("accepted a document that is not an image or PDF")
+	}
+	request, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{
+		ClassID: classID, SessionID: session.SessionID, Reason: "Fever",
+		DocumentName: "certificate.png", DocumentData: "data:image/png;base64,iVBORw0KGgo=",
+	})
+	if err != nil {
+		t.Fatalf("SubmitExcuseRequest: %v", err)
+	}
+	if request.Status != excuseStatusPending || request.StartDate != e.clock.Today() || !request.HasDocument {
+		t.Errorf("request = %+v, want a pending request for today with a document", request)
+	}
+	if _, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{ClassID: classID, SessionID: session.SessionID, Reason: "Fever"}); err == nil {
+		t.Errorf("filed a second pending excuse for the same session")
+	}
+
+	// Only the class teacher reviews it.
+	e.loginAs("T-0002")
+	if _, err := e.app.GetClassExcuseRequests(classID, ""); err == nil {
+		t.Errorf("another teacher listed the class's excuse requests")
+	}
+	if _, err := e.app.GetExcuseRequestDocument(request.ID); err == nil {
+		t.Errorf("another teacher read the excuse document")
+	}
+
+	e.loginAs("T-0001")
+	pending, err := e.app.GetClassExcuseRequests(classID, excuseStatusPending)
+	if err != nil || len(pending) != 1 || pending[0].StudentName != "Cruz, Ana" {
+		t.Fatalf("pending requests = %+v, %v; want Ana's request", pending, err)
+	}
+	if document, err := e.app.GetExcuseRequestDocument(request.ID); err != nil || document == "" {
+		t.Errorf("GetExcuseRequestDocument = %q, %v", document, err)
+	}
+	if err := e.app.ReviewExcuseRequest(request.ID, teacherID, true, ""); err != nil {
+		t.Fatalf("ReviewExcuseRequest: %v", err)
+	}
+	if err := e.app.ReviewExcuseRequest(request.ID, teacherID, false, "Changed my mind"); err == nil {
+		t.Errorf("reviewed the same request twice")
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != attendanceStatusExcused {
+		t.Errorf("Ana's status = %q, want excused", got)
+	}
+	if got := e.queryString(`SELECT reason FROM attendance_changes WHERE session_id = ? AND student_id = ?`, session.SessionID, anaID); got == "" {
+		t.Errorf("approving the excuse recorded no attendance change")
+	}
+
+	// Saving the session marks Ben absent but leaves Ana excused.
+	if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("SaveAttendanceSession: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != attendanceStatusExcused {
+		t.Errorf("Ana's status after saving = %q, want excused", got)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got != "absent" {
+		t.Errorf("Ben's status after saving = %q, want absent", got)
+	}
+
+	summary, err := e.app.GetClassAttendanceSummary(classID)
+	if err != nil {
+		t.Fatalf("GetClassAttendanceSummary: %v", err)
+	}
+	for _, student := range summary.Students {
+		if student.StudentUserID == anaID && (student.ExcusedCount != 1 || student.ConsecutiveAbsences != 0 || student.AbsentCount != 0) {
+			t.Errorf("Ana's summary = %+v, want one excused session and no absences", student)
+		}
+	}
+}
+
+func TestDeniedAndCancelledExcusesLeaveAttendance(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT101", anaID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("SaveAttendanceSession: %v", err)
+	}
+
+	e.loginAs("2024-00001")
+	request, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{ClassID: classID, SessionID: session.SessionID, Reason: "Overslept"})
+	if err != nil {
+		t.Fatalf("SubmitExcuseRequest: %v", err)
+	}
+
+	e.loginAs("T-0001")
+	if err := e.app.ReviewExcuseRequest(request.ID, teacherID, false, ""); err == nil {
+		t.Errorf("denied an excuse without a note")
+	}
+	if err := e.app.ReviewExcuseRequest(request.ID, teacherID, false, "Not a valid reason"); err != nil {
+		t.Fatalf("ReviewExcuseRequest: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != "absent" {
+		t.Errorf("status after denial = %q, want absent", got)
+	}
+
+	e.loginAs("2024-00001")
+	if err := e.app.CancelExcuseRequest(request.ID, anaID); err == nil {
+		t.Errorf("cancelled a request that was already denied")
+	}
+	second, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{ClassID: classID, SessionID: session.SessionID, Reason: "Family emergency"})
+	if err != nil {
+		t.Fatalf("SubmitExcuseRequest: %v", err)
+	}
+	if err := e.app.CancelExcuseRequest(second.ID, anaID); err != nil {
+		t.Fatalf("CancelExcuseRequest: %v", err)
+	}
+	requests, err := e.app.GetStudentExcuseRequests(anaID)
+	if err != nil || len(requests) != 2 || requests[0].Status != excuseStatusCancelled || requests[1].Status != excuseStatusDenied {
+		t.Errorf("student requests = %+v, %v; want one cancelled and one denied", requests, err)
+	}
+}
+
+func TestApprovedLeaveExcusesLaterSessions(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	start := e.clock.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	end := e.clock.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	e.loginAs("2024-00001")
+	if _, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{ClassID: classID, StartDate: end, EndDate: start, Reason: "Hospital"}); err == nil {
+		t.Errorf("accepted a range that ends before it starts")
+	}
+	if _, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{
+		ClassID: classID, StartDate: start, EndDate: e.clock.Now().AddDate(0, 0, 60).Format("2006-01-02"), Reason: "Hospital",
+	}); err == nil {
+		t.Errorf("accepted a leave longer than %d days", maxExcuseRangeDays)
+	}
+	request, err := e.app.SubmitExcuseRequest(anaID, ExcuseRequestInput{ClassID: classID, StartDate: start, EndDate: end, Reason: "Hospital"})
+	if err != nil {
+		t.Fatalf("SubmitExcuseRequest: %v", err)
+	}
+	e.loginAs("T-0001")
+	if err := e.app.ReviewExcuseRequest(request.ID, teacherID, true, "Get well soon"); err != nil {
+		t.Fatalf("ReviewExcuseRequest: %v", err)
+	}
+
+	// A session inside the leave starts with Ana excused.
+	e.clock.Advance(48 * time.Hour)
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != attendanceStatusExcused {
+		t.Errorf("Ana's status = %q, want excused", got)
+	}
+	if got := e.queryString(`SELECT COALESCE(status, '') FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, benID); got != "" {
+		t.Errorf("Ben's status = %q, want unmarked", got)
+	}
+
+	// Turning up anyway counts as present.
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != "present" {
+		t.Errorf("Ana's status after timing in = %q, want present", got)
+	}
+}
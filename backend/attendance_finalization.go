@@ -0,0 +1,499 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// SESSION FINALIZATION
+// ==============================================================================
+//
+// When a session closes, students nobody marked (no time-in, no manual status) are
+// handled by the class's unmarked policy:
+//   - absent:  they are marked absent at once (the default, and the old behaviour);
+//   - pending: they stay unmarked for unmarked_pending_hours, so late corrections and
+//     offline time-ins can still land, and are then marked absent;
+//   - review:  they stay unmarked until the teacher finalizes the session.
+// Finalizing produces a summary of the counts and of who was marked absent or is still
+// unmarked. It is stored with the session and sent to the teacher.
+
+const (
+	unmarkedPolicyAbsent  = "absent"
+	unmarkedPolicyPending = "pending"
+	unmarkedPolicyReview  = "review"
+
+	finalizationStatusFinalized = "finalized"
+
+	defaultUnmarkedPendingHours = 24
+	maxUnmarkedPendingHours     = 168
+	// Names listed in the teacher's notification; the stored summary keeps them all.
+	finalizationNotifyMaxNames = 10
+)
+
+// unmarkedAttendanceCondition matches attendance rows that nobody has marked.
+const unmarkedAttendanceCondition = `LOWER(LTRIM(RTRIM(COALESCE(status, '')))) NOT IN ('present', 'late', 'seat-in', 'seat in', 'absent', 'excused')`
+
+// AttendanceFinalizationPolicy is what a class does with unmarked students when one of
+// its sessions closes. PendingHours only applies to the pending policy.
+type AttendanceFinalizationPolicy struct {
+	UnmarkedPolicy string `json:"unmarked_policy"`
+	PendingHours   int    `json:"pending_hours"`
+}
+
+// AttendanceFinalizationSummary describes how a closed session was finalized. Status is
+// pending or review while unmarked students are still waiting, then finalized.
+type AttendanceFinalizationSummary struct {
+	SessionID     int      `json:"session_id"`
+	Policy        string   `json:"policy"`
+	Status        string   `json:"status"`
+	PresentCount  int      `json:"present_count"`
+	LateCount     int      `json:"late_count"`
+	AbsentCount   int      `json:"absent_count"`
+	ExcusedCount  int      `json:"excused_count"`
+	UnmarkedCount int      `json:"unmarked_count"`
+	MarkedAbsent  []string `json:"marked_absent"`
+	Unmarked      []string `json:"unmarked"`
+	DueAt         *string  `json:"due_at,omitempty"`
+	FinalizedAt   *string  `json:"finalized_at,omitempty"`
+	FinalizedBy   *string  `json:"finalized_by,omitempty"`
+}
+
+func getAttendanceFinalizationPolicy(exec dbExecutor, classID int) (AttendanceFinalizationPolicy, error) {
+	policy := AttendanceFinalizationPolicy{UnmarkedPolicy: unmarkedPolicyAbsent, PendingHours: defaultUnmarkedPendingHours}
+	err := exec.QueryRow(`
+		SELECT COALESCE(unmarked_policy, ''), COALESCE(unmarked_pending_hours, 0) FROM classes WHERE class_id = ?
+	`, classID).Scan(&policy.UnmarkedPolicy, &policy.PendingHours)
+	if err == sql.ErrNoRows {
+		return policy, fmt.Errorf("class not found")
+	}
+	if err != nil {
+		return policy, err
+	}
+	switch policy.UnmarkedPolicy {
+	case unmarkedPolicyPending, unmarkedPolicyReview:
+	default:
+		policy.UnmarkedPolicy = unmarkedPolicyAbsent
+	}
+	if policy.PendingHours <= 0 {
+		policy.PendingHours = defaultUnmarkedPendingHours
+	}
+	return policy, nil
+}
+
+// GetClassFinalizationPolicy returns what happens to unmarked students when a session of
+// the class closes.
+func (a *App) GetClassFinalizationPolicy(classID int) (*AttendanceFinalizationPolicy, error) {
+	session, err := a.requireRole("GetClassFinalizationPolicy")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	policy, err := getAttendanceFinalizationPolicy(a.db, classID)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SaveClassFinalizationPolicy sets the unmarked policy of a class owned by the teacher.
+// It applies to sessions that close afterwards.
+func (a *App) SaveClassFinalizationPolicy(classID int, teacherUserID int, policy AttendanceFinalizationPolicy) error {
+	session, err := a.requireActingUser("SaveClassFinalizationPolicy", teacherUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	policy.UnmarkedPolicy = strings.ToLower(strings.TrimSpace(policy.UnmarkedPolicy))
+	switch policy.UnmarkedPolicy {
+	case unmarkedPolicyAbsent, unmarkedPolicyReview:
+		// The hours only apply to the pending policy; keep a usable value for a later switch.
+		if policy.PendingHours < 1 || policy.PendingHours > maxUnmarkedPendingHours {
+			policy.PendingHours = defaultUnmarkedPendingHours
+		}
+	case unmarkedPolicyPending:
+		if policy.PendingHours < 1 || policy.PendingHours > maxUnmarkedPendingHours {
+			return fmt.Errorf("pending hours must be between 1 and %d", maxUnmarkedPendingHours)
+		}
+	default:
+		return fmt.Errorf("invalid unmarked policy: %s", policy.UnmarkedPolicy)
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
+
+	previous, err := getAttendanceFinalizationPolicy(a.db, classID)
+	if err != nil {
+		return err
+	}
+	if _, err := a.db.Exec(`
+		UPDATE classes
+		SET unmarked_policy = ?, unmarked_pending_hours = ?, updated_at = ?
+		WHERE class_id = ?
+	`, policy.UnmarkedPolicy, policy.PendingHours, a.now(), classID); err != nil {
+		return fmt.Errorf("failed to save finalization policy: %w", err)
+	}
+	a.audit(session, "update_finalization_policy", "class", classID,
+		auditValues{"unmarked_policy": previous.UnmarkedPolicy, "pending_hours": previous.PendingHours},
+		auditValues{"unmarked_policy": policy.UnmarkedPolicy, "pending_hours": policy.PendingHours})
+	return nil
+}
+
+// GetAttendanceSessionFinalization returns the finalization summary of a closed session,
+// or nil while the session is open.
+func (a *App) GetAttendanceSessionFinalization(sessionID int) (*AttendanceFinalizationSummary, error) {
+	session, err := a.requireRole("GetAttendanceSessionFinalization")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	var classID int
+	var stored sql.NullString
+	err = a.db.QueryRow(`
+		SELECT class_id, finalization_summary FROM attendance_sessions WHERE session_id = ?
+	`, sessionID).Scan(&classID, &stored)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	if !stored.Valid || stored.String == "" {
+		return nil, nil
+	}
+	var summary AttendanceFinalizationSummary
+	if err := json.Unmarshal([]byte(stored.String), &summary); err != nil {
+		return nil, fmt.Errorf("failed to read finalization summary: %w", err)
+	}
+	return &summary, nil
+}
+
+// FinalizeAttendanceSession marks the students still unmarked in one of the teacher's
+// closed sessions absent, ahead of the pending deadline or after reviewing them.
+func (a *App) FinalizeAttendanceSession(sessionID int, teacherUserID int) (*AttendanceFinalizationSummary, error) {
+	session, err := a.requireActingUser("FinalizeAttendanceSession", teacherUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	var status string
+	var finalizationStatus sql.NullString
+	err = a.db.QueryRow(`
+		SELECT s.status, s.finalization_status
+		FROM attendance_sessions s
+		JOIN classes c ON s.class_id = c.class_id
+		WHERE s.session_id = ? AND c.teacher_id = ? AND COALESCE(s.is_archived, 0) = 0
+	`, sessionID, teacherUserID).Scan(&status, &finalizationStatus)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found or not authorized")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != "closed" {
+		return nil, fmt.Errorf("close the session before finalizing it")
+	}
+	if finalizationStatus.String == finalizationStatusFinalized {
+		return nil, fmt.Errorf("session is already finalized")
+	}
+
+	summary, err := a.completeAttendanceFinalization(sessionID, session)
+	if err != nil {
+		return nil, err
+	}
+	a.audit(session, "finalize_attendance_session", "attendance_session", sessionID,
+		auditValues{"finalization_status": finalizationStatus.String},
+		auditValues{"finalization_status": finalizationStatusFinalized, "marked_absent": len(summary.MarkedAbsent)})
+	return summary, nil
+}
+
+// finalizeClosedAttendanceSession applies the class's unmarked policy to a session that
+// has just closed. Sessions already handled are left alone, so every close path can call it.
+func (a *App) finalizeClosedAttendanceSession(sessionID int) error {
+	var classID int
+	var closedAt sql.NullTime
+	err := a.db.QueryRow(`
+		SELECT class_id, closed_at FROM attendance_sessions
+		WHERE session_id = ? AND status = 'closed' AND finalization_status IS NULL
+	`, sessionID).Scan(&classID, &closedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	policy, err := getAttendanceFinalizationPolicy(a.db, classID)
+	if err != nil {
+		return err
+	}
+	if policy.UnmarkedPolicy == unmarkedPolicyAbsent {
+		_, err := a.completeAttendanceFinalization(sessionID, nil)
+		return err
+	}
+
+	var unmarked []string
+	if unmarked, err = loadUnmarkedStudentNames(a.db, sessionID); err != nil {
+		return err
+	}
+	if len(unmarked) == 0 {
+		_, err := a.completeAttendanceFinalization(sessionID, nil)
+		return err
+	}
+
+	var dueAt interface{}
+	if policy.UnmarkedPolicy == unmarkedPolicyPending {
+		closed := a.now()
+		if closedAt.Valid {
+			closed = closedAt.Time
+		}
+		dueAt = closed.Add(time.Duration(policy.PendingHours) * time.Hour)
+	}
+	summary, err := buildAttendanceFinalizationSummary(a.db, sessionID, policy.UnmarkedPolicy, policy.UnmarkedPolicy, nil, unmarked)
+	if err != nil {
+		return err
+	}
+	if due, ok := dueAt.(time.Time); ok {
+		formatted := formatTime(due)
+		summary.DueAt = &formatted
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	result, err := a.db.Exec(`
+		UPDATE attendance_sessions
+		SET finalization_status = ?, finalization_due_at = ?, finalization_summary = ?, updated_at = ?
+		WHERE session_id = ? AND finalization_status IS NULL
+	`, policy.UnmarkedPolicy, dueAt, string(data), a.now(), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to store finalization summary: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		a.notifyAttendanceFinalization(sessionID, summary)
+	}
+	return nil
+}
+
+// finalizeDueAttendanceSessions finalizes pending sessions whose grace period is over.
+func (a *App) finalizeDueAttendanceSessions() {
+	rows, err := a.db.Query(`
+		SELECT session_id FROM attendance_sessions
+		WHERE finalization_status = ? AND finalization_due_at <= ?
+	`, unmarkedPolicyPending, a.now())
+	if err != nil {
+		log.Printf("Failed to load pending attendance sessions: %v", err)
+		return
+	}
+	var due []int
+	for rows.Next() {
+		var sessionID int
+		if rows.Scan(&sessionID) == nil {
+			due = append(due, sessionID)
+		}
+	}
+	rows.Close()
+
+	for _, sessionID := range due {
+		if _, err := a.completeAttendanceFinalization(sessionID, nil); err != nil {
+			log.Printf("Failed to finalize attendance session %d: %v", sessionID, err)
+			continue
+		}
+		a.evaluateAttendanceAlertsForSession(sessionID)
+	}
+}
+
+// completeAttendanceFinalization marks the session's unmarked students absent and stores
+// the final summary. actor is nil when the close or the deadline did it.
+func (a *App) completeAttendanceFinalization(sessionID int, actor *appSession) (*AttendanceFinalizationSummary, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var classID int
+	var previousStatus sql.NullString
+	if err := tx.QueryRow(`
+		SELECT class_id, finalization_status FROM attendance_sessions WHERE session_id = ?
+	`, sessionID).Scan(&classID, &previousStatus); err != nil {
+		return nil, err
+	}
+	if previousStatus.String == finalizationStatusFinalized {
+		return nil, fmt.Errorf("session is already finalized")
+	}
+	policy, err := getAttendanceFinalizationPolicy(tx, classID)
+	if err != nil {
+		return nil, err
+	}
+	if previousStatus.Valid {
+		// Keep the policy the session closed under, even if the class changed it since.
+		policy.UnmarkedPolicy = previousStatus.String
+	}
+
+	markedAbsent, err := loadUnmarkedStudentNames(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	now := a.now()
+	if _, err := tx.Exec(`
+		UPDATE attendance
+		SET status = 'absent', remarks = 'Absent', time_in_at = NULL, updated_at = ?
+		WHERE session_id = ? AND COALESCE(is_archived, 0) = 0 AND `+unmarkedAttendanceCondition, now, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to mark unmarked students absent: %w", err)
+	}
+
+	summary, err := buildAttendanceFinalizationSummary(tx, sessionID, policy.UnmarkedPolicy, finalizationStatusFinalized, markedAbsent, nil)
+	if err != nil {
+		return nil, err
+	}
+	finalizedAt := formatTime(now)
+	summary.FinalizedAt = &finalizedAt
+	if actor != nil {
+		username := actor.Username
+		summary.FinalizedBy = &username
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec(`
+		UPDATE attendance_sessions
+		SET finalization_status = ?, finalized_at = ?, finalization_summary = ?, updated_at = ?
+		WHERE session_id = ? AND status = 'closed' AND COALESCE(finalization_status, '') <> ?
+	`, finalizationStatusFinalized, now, string(data), now, sessionID, finalizationStatusFinalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store finalization summary: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("session is not closed or is already finalized")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit session finalization: %w", err)
+	}
+
+	a.notifyAttendanceFinalization(sessionID, summary)
+	return summary, nil
+}
+
+// loadUnmarkedStudentNames lists the students nobody marked in the session, by name.
+func loadUnmarkedStudentNames(exec dbExecutor, sessionID int) ([]string, error) {
+	rows, err := exec.Query(`
+		SELECT stu.first_name, stu.middle_name, stu.last_name
+		FROM attendance a
+		JOIN students stu ON a.student_id = stu.id
+		WHERE a.session_id = ? AND COALESCE(a.is_archived, 0) = 0
+			AND LOWER(LTRIM(RTRIM(COALESCE(a.status, '')))) NOT IN ('present', 'late', 'seat-in', 'seat in', 'absent', 'excused')
+		ORDER BY stu.last_name, stu.first_name
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load unmarked students: %w", err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var firstName, lastName string
+		var middleName sql.NullString
+		if err := rows.Scan(&firstName, &middleName, &lastName); err != nil {
+			return nil, err
+		}
+		names = append(names, buildAttendanceExportName(Attendance{
+			FirstName: firstName, MiddleName: scanNullString(middleName), LastName: lastName,
+		}))
+	}
+	return names, rows.Err()
+}
+
+func buildAttendanceFinalizationSummary(exec dbExecutor, sessionID int, policy, status string, markedAbsent, unmarked []string) (*AttendanceFinalizationSummary, error) {
+	summary := &AttendanceFinalizationSummary{
+		SessionID:    sessionID,
+		Policy:       policy,
+		Status:       status,
+		MarkedAbsent: markedAbsent,
+		Unmarked:     unmarked,
+	}
+	if summary.MarkedAbsent == nil {
+		summary.MarkedAbsent = []string{}
+	}
+	if summary.Unmarked == nil {
+		summary.Unmarked = []string{}
+	}
+	err := exec.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN LOWER(COALESCE(status, '')) IN ('present', 'seat-in', 'seat in') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN LOWER(COALESCE(status, '')) = 'late' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN LOWER(COALESCE(status, '')) = 'absent' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN LOWER(COALESCE(status, '')) = 'excused' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN `+unmarkedAttendanceCondition+` THEN 1 ELSE 0 END), 0)
+		FROM attendance
+		WHERE session_id = ? AND COALESCE(is_archived, 0) = 0
+	`, sessionID).Scan(&summary.PresentCount, &summary.LateCount, &summary.AbsentCount, &summary.ExcusedCount, &summary.UnmarkedCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count session attendance: %w", err)
+	}
+	return summary, nil
+}
+
+// describeStudentNames lists up to finalizationNotifyMaxNames names.
+func describeStudentNames(names []string) string {
+	if len(names) <= finalizationNotifyMaxNames {
+		return strings.Join(names, "; ")
+	}
+	return fmt.Sprintf("%s; and %d more", strings.Join(names[:finalizationNotifyMaxNames], "; "), len(names)-finalizationNotifyMaxNames)
+}
+
+// notifyAttendanceFinalization sends the summary to the class teacher.
+func (a *App) notifyAttendanceFinalization(sessionID int, summary *AttendanceFinalizationSummary) {
+	var teacherID int
+	var sessionName, subjectCode string
+	if err := a.db.QueryRow(`
+		SELECT c.teacher_id, COALESCE(s.session_name, ''), COALESCE(c.subject_code, '')
+		FROM attendance_sessions s
+		JOIN classes c ON s.class_id = c.class_id
+		WHERE s.session_id = ?
+	`, sessionID).Scan(&teacherID, &sessionName, &subjectCode); err != nil {
+		log.Printf("Failed to load session for finalization notice: session_id=%d err=%v", sessionID, err)
+		return
+	}
+
+	message := fmt.Sprintf("%s %s: %d present, %d late, %d absent, %d excused.", subjectCode, sessionName,
+		summary.PresentCount, summary.LateCount, summary.AbsentCount, summary.ExcusedCount)
+	title, tone := "Attendance Finalized", "info"
+	switch summary.Status {
+	case unmarkedPolicyPending:
+		title, tone = "Attendance Pending", "warning"
+		due := "the deadline"
+		if summary.DueAt != nil {
+			due = *summary.DueAt
+		}
+		message += fmt.Sprintf(" %d unmarked student(s) will be marked absent at %s unless corrected: %s.",
+			len(summary.Unmarked), due, describeStudentNames(summary.Unmarked))
+	case unmarkedPolicyReview:
+		title, tone = "Attendance Needs Review", "warning"
+		message += fmt.Sprintf(" %d unmarked student(s) are waiting for your review: %s.",
+			len(summary.Unmarked), describeStudentNames(summary.Unmarked))
+	default:
+		if len(summary.MarkedAbsent) > 0 {
+			message += fmt.Sprintf(" Marked absent as unmarked: %s.", describeStudentNames(summary.MarkedAbsent))
+		}
+	}
+	a.createNotification(teacherID, "attendance", title, message, tone, notifRef("attendance_session"), notifRefID(sessionID))
+}
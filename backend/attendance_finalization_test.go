@@ -0,0 +1,169 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClosingSessionMarksUnmarkedAbsentByDefault(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn: %v", err)
+	}
+	e.loginAs("T-0001")
+	if summary, err := e.app.GetAttendanceSessionFinalization(session.SessionID); err != nil || summary != nil {
+		t.Errorf("summary of an open session = %+v, %v; want none", summary, err)
+	}
+	if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("SaveAttendanceSession: %v", err)
+	}
+
+	if got := attendanceStatus(e, session.SessionID, benID); got != "absent" {
+		t.Errorf("Ben's status = %q, want absent", got)
+	}
+	summary, err := e.app.GetAttendanceSessionFinalization(session.SessionID)
+	if err != nil || summary == nil {
+		t.Fatalf("GetAttendanceSessionFinalization = %+v, %v", summary, err)
+	}
+	if summary.Status != finalizationStatusFinalized || summary.PresentCount != 1 || summary.AbsentCount != 1 ||
+		len(summary.MarkedAbsent) != 1 || summary.MarkedAbsent[0] != "Santos, Ben" {
+		t.Errorf("summary = %+v, want finalized with Ben marked absent", summary)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND title = ?`, teacherID, "Attendance Finalized"); got != 1 {
+		t.Errorf("teacher finalization notices = %d, want 1", got)
+	}
+	if _, err := e.app.FinalizeAttendanceSession(session.SessionID, teacherID); err == nil {
+		t.Errorf("finalized a session twice")
+	}
+}
+
+func TestPendingPolicyMarksAbsentAfterDeadline(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	e.loginAs("T-0001")
+	if err := e.app.SaveClassFinalizationPolicy(classID, teacherID, AttendanceFinalizationPolicy{UnmarkedPolicy: "later"}); err == nil {
+		t.Errorf("saved an unknown policy")
+	}
+	if err := e.app.SaveClassFinalizationPolicy(classID, teacherID, AttendanceFinalizationPolicy{UnmarkedPolicy: unmarkedPolicyPending, PendingHours: 500}); err == nil {
+		t.Errorf("saved a pending policy longer than %d hours", maxUnmarkedPendingHours)
+	}
+	if err := e.app.SaveClassFinalizationPolicy(classID, teacherID, AttendanceFinalizationPolicy{UnmarkedPolicy: unmarkedPolicyPending, PendingHours: 2}); err != nil {
+		t.Fatalf("SaveClassFinalizationPolicy: %v", err)
+	}
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+
+	// The session runs out; Ana and Ben are left unmarked for two hours.
+	e.clock.Advance(61 * time.Minute)
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+	if got := e.queryString(`SELECT COALESCE(status, '') FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, benID); got != "" {
+		t.Errorf("Ben's status while pending = %q, want unmarked", got)
+	}
+	summary, err := e.app.GetAttendanceSessionFinalization(session.SessionID)
+	if err != nil || summary == nil || summary.Status != unmarkedPolicyPending || summary.UnmarkedCount != 2 || summary.DueAt == nil {
+		t.Fatalf("pending summary = %+v, %v; want two unmarked students and a deadline", summary, err)
+	}
+
+	// A correction lands before the deadline.
+	if err := e.app.UpdateSessionAttendanceRecord(session.SessionID, anaID, teacherID, "late", "", "Arrived late, forgot to time in"); err != nil {
+		t.Fatalf("UpdateSessionAttendanceRecord: %v", err)
+	}
+
+	e.clock.Advance(2 * time.Hour)
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got != "absent" {
+		t.Errorf("Ben's status after the deadline = %q, want absent", got)
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != "late" {
+		t.Errorf("Ana's status after the deadline = %q, want late", got)
+	}
+	summary, err = e.app.GetAttendanceSessionFinalization(session.SessionID)
+	if err != nil || summary.Status != finalizationStatusFinalized || len(summary.MarkedAbsent) != 1 || summary.LateCount != 1 {
+		t.Errorf("final summary = %+v, %v; want Ben marked absent and Ana late", summary, err)
+	}
+}
+
+func TestReviewPolicyWaitsForTeacher(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	e.loginAs("T-0001")
+	if err := e.app.SaveClassFinalizationPolicy(classID, teacherID, AttendanceFinalizationPolicy{UnmarkedPolicy: unmarkedPolicyReview, PendingHours: 500}); err != nil {
+		t.Fatalf("SaveClassFinalizationPolicy: %v", err)
+	}
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	if _, err := e.app.FinalizeAttendanceSession(session.SessionID, teacherID); err == nil {
+		t.Errorf("finalized an open session")
+	}
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn: %v", err)
+	}
+	e.loginAs("T-0001")
+	if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("SaveAttendanceSession: %v", err)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND title = ?`, teacherID, "Attendance Needs Review"); got != 1 {
+		t.Errorf("teacher review notices = %d, want 1", got)
+	}
+
+	// Exports say the student is unmarked instead of leaving a blank.
+	path := filepath.Join(t.TempDir(), "attendance.csv")
+	if _, err := e.app.ExportAttendanceCSVBySession(classID, e.clock.Today(), session.SessionID, path); err != nil {
+		t.Fatalf("ExportAttendanceCSVBySession: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "Unmarked") {
+		t.Errorf("export does not show the unmarked student:\n%s", data)
+	}
+
+	// Nothing happens on its own, however long it waits.
+	e.clock.Advance(200 * time.Hour)
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+	if got := e.queryString(`SELECT COALESCE(status, '') FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, benID); got != "" {
+		t.Errorf("Ben's status while in review = %q, want unmarked", got)
+	}
+
+	e.loginAs("T-0001")
+	summary, err := e.app.FinalizeAttendanceSession(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("FinalizeAttendanceSession: %v", err)
+	}
+	if summary.Policy != unmarkedPolicyReview || summary.FinalizedBy == nil || len(summary.MarkedAbsent) != 1 || summary.UnmarkedCount != 0 {
+		t.Errorf("summary = %+v, want Ben marked absent by the teacher", summary)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got != "absent" {
+		t.Errorf("Ben's status = %q, want absent", got)
+	}
+}
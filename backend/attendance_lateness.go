@@ -0,0 +1,486 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// LATENESS TIERS
+// ==============================================================================
+//
+// A lateness rule turns how long after a session opened a student timed in into a status.
+// It is an ordered list of tiers, each covering time-ins up to a number of minutes, plus
+// the status for anything after the last tier, e.g. present up to 15, late up to 30, then
+// absent. A time-in past every tier is still recorded, with its minutes late.
+//
+// A session uses its own rule if it has one, else its class's rule, else the old single
+// grace period (present up to grace_period_minutes, then late). Changing a rule recomputes
+// the time-ins it covers; rows a teacher changed by hand after the time-in are left alone.
+
+const (
+	maxLatenessTiers   = 5
+	maxLatenessMinutes = 600
+
+	latenessSourceSession = "session"
+	latenessSourceClass   = "class"
+	latenessSourceGrace   = "grace"
+
+	// Reason recorded on attendance_changes rows written by a recomputation.
+	latenessRecomputeReason = "Lateness rules changed"
+)
+
+// latenessSeverity orders the statuses a tier may give; later tiers may not be milder.
+var latenessSeverity = map[string]int{"present": 0, "late": 1, "absent": 2}
+
+// LatenessTier gives Status to time-ins up to UpToMinutes after the session opened.
+type LatenessTier struct {
+	UpToMinutes int    `json:"up_to_minutes"`
+	Status      string `json:"status"`
+}
+
+// LatenessRule is an ordered list of tiers and the status given after the last one.
+// Source says where the effective rule came from: session, class or grace.
+type LatenessRule struct {
+	Tiers       []LatenessTier `json:"tiers"`
+	AfterStatus string         `json:"after_status"`
+	Source      string         `json:"source,omitempty"`
+}
+
+// graceLatenessRule is the rule of a session without tiers: present within the grace
+// period, late after it.
+func graceLatenessRule(gracePeriodMinutes int) LatenessRule {
+	if gracePeriodMinutes < 0 {
+		gracePeriodMinutes = 0
+	}
+	return LatenessRule{
+		Tiers:       []LatenessTier{{UpToMinutes: gracePeriodMinutes, Status: "present"}},
+		AfterStatus: "late",
+		Source:      latenessSourceGrace,
+	}
+}
+
+// normalizeLatenessRule validates a rule and lowercases its statuses. An empty rule (no
+// tiers) is valid and means "no rule here".
+func normalizeLatenessRule(rule LatenessRule) (LatenessRule, error) {
+	normalized := LatenessRule{AfterStatus: strings.ToLower(strings.TrimSpace(rule.AfterStatus))}
+	if len(rule.Tiers) == 0 {
+		return normalized, nil
+	}
+	if len(rule.Tiers) > maxLatenessTiers {
+		return normalized, fmt.Errorf("a lateness rule can have at most %d tiers", maxLatenessTiers)
+	}
+	previousMinutes, previousSeverity := -1, 0
+	for i, tier := range rule.Tiers {
+		status := strings.ToLower(strings.TrimSpace(tier.Status))
+		severity, ok := latenessSeverity[status]
+		if !ok {
+			return normalized, fmt.Errorf("invalid lateness tier status: %s", tier.Status)
+		}
+		if tier.UpToMinutes < 0 || tier.UpToMinutes > maxLatenessMinutes {
+			return normalized, fmt.Errorf("tier minutes must be between 0 and %d", maxLatenessMinutes)
+		}
+		if tier.UpToMinutes <= previousMinutes {
+			return normalized, fmt.Errorf("tier %d must cover more minutes than the one before it", i+1)
+		}
+		if severity < previousSeverity {
+			return normalized, fmt.Errorf("tier %d cannot be milder than the one before it", i+1)
+		}
+		previousMinutes, previousSeverity = tier.UpToMinutes, severity
+		normalized.Tiers = append(normalized.Tiers, LatenessTier{UpToMinutes: tier.UpToMinutes, Status: status})
+	}
+	severity, ok := latenessSeverity[normalized.AfterStatus]
+	if !ok {
+		return normalized, fmt.Errorf("invalid status after the last tier: %s", rule.AfterStatus)
+	}
+	if severity < previousSeverity {
+		return normalized, fmt.Errorf("the status after the last tier cannot be milder than the last tier")
+	}
+	return normalized, nil
+}
+
+// parseLatenessRule reads a stored rule. Missing or unreadable rules come back empty.
+func parseLatenessRule(stored sql.NullString) LatenessRule {
+	if !stored.Valid || strings.TrimSpace(stored.String) == "" {
+		return LatenessRule{}
+	}
+	var rule LatenessRule
+	if err := json.Unmarshal([]byte(stored.String), &rule); err != nil {
+		log.Printf("Ignoring unreadable lateness rule: %v", err)
+		return LatenessRule{}
+	}
+	rule, err := normalizeLatenessRule(rule)
+	if err != nil {
+		log.Printf("Ignoring invalid lateness rule: %v", err)
+		return LatenessRule{}
+	}
+	return rule
+}
+
+// encodeLatenessRule is the stored form of a rule; an empty rule is stored as NULL.
+func encodeLatenessRule(rule LatenessRule) (interface{}, error) {
+	if len(rule.Tiers) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(LatenessRule{Tiers: rule.Tiers, AfterStatus: rule.AfterStatus})
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// evaluate returns the status and the whole minutes late of a time-in at `at`. Without
+// an opening time every time-in is present and has no minutes late.
+func (r LatenessRule) evaluate(openedAt sql.NullTime, at time.Time) (string, sql.NullInt64) {
+	if !openedAt.Valid {
+		return "present", sql.NullInt64{}
+	}
+	elapsed := at.Sub(openedAt.Time)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	minutesLate := sql.NullInt64{Int64: int64(elapsed / time.Minute), Valid: true}
+	for _, tier := range r.Tiers {
+		if elapsed <= time.Duration(tier.UpToMinutes)*time.Minute {
+			return tier.Status, minutesLate
+		}
+	}
+	return r.AfterStatus, minutesLate
+}
+
+// presentMinutes is how long after opening a time-in still counts as present.
+func (r LatenessRule) presentMinutes() int {
+	minutes := 0
+	for _, tier := range r.Tiers {
+		if tier.Status == "present" {
+			minutes = tier.UpToMinutes
+		}
+	}
+	return minutes
+}
+
+func minutesLateValue(minutesLate sql.NullInt64) *int {
+	if !minutesLate.Valid {
+		return nil
+	}
+	v := int(minutesLate.Int64)
+	return &v
+}
+
+// loadSessionLatenessRule returns the effective rule of a session and its opening time.
+func loadSessionLatenessRule(exec dbExecutor, sessionID int) (LatenessRule, sql.NullTime, error) {
+	var sessionRule, classRule sql.NullString
+	var gracePeriod sql.NullInt64
+	var openedAt sql.NullTime
+	err := exec.QueryRow(`
+		SELECT s.lateness_tiers, c.lateness_tiers, s.grace_period_minutes, s.opened_at
+		FROM attendance_sessions s
+		JOIN classes c ON s.class_id = c.class_id
+		WHERE s.session_id = ?
+	`, sessionID).Scan(&sessionRule, &classRule, &gracePeriod, &openedAt)
+	if err == sql.ErrNoRows {
+		return LatenessRule{}, openedAt, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return LatenessRule{}, openedAt, err
+	}
+	if rule := parseLatenessRule(sessionRule); len(rule.Tiers) > 0 {
+		rule.Source = latenessSourceSession
+		return rule, openedAt, nil
+	}
+	if rule := parseLatenessRule(classRule); len(rule.Tiers) > 0 {
+		rule.Source = latenessSourceClass
+		return rule, openedAt, nil
+	}
+	return graceLatenessRule(int(gracePeriod.Int64)), openedAt, nil
+}
+
+// GetClassLatenessRule returns the lateness rule set on a class. It has no tiers when the
+// class uses each session's grace period.
+func (a *App) GetClassLatenessRule(classID int) (*LatenessRule, error) {
+	session, err := a.requireRole("GetClassLatenessRule")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	var stored sql.NullString
+	err = a.db.QueryRow(`SELECT lateness_tiers FROM classes WHERE class_id = ?`, classID).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("class not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	rule := parseLatenessRule(stored)
+	if len(rule.Tiers) > 0 {
+		rule.Source = latenessSourceClass
+	} else {
+		rule.Source = latenessSourceGrace
+	}
+	return &rule, nil
+}
+
+// SaveClassLatenessRule sets the lateness rule of a class owned by the teacher; a rule
+// without tiers goes back to each session's grace period. Open sessions of the class are
+// recomputed, and closed ones too when recomputePast is set. Sessions with their own rule
+// are not affected. It returns how many attendance statuses changed.
+func (a *App) SaveClassLatenessRule(classID int, teacherUserID int, rule LatenessRule, recomputePast bool) (int, error) {
+	session, err := a.requireActingUser("SaveClassLatenessRule", teacherUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.checkDB(); err != nil {
+		return 0, err
+	}
+	rule, err = normalizeLatenessRule(rule)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return 0, err
+	}
+	stored, err := encodeLatenessRule(rule)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullString
+	if err := tx.QueryRow(`SELECT lateness_tiers FROM classes WHERE class_id = ?`, classID).Scan(&previous); err != nil {
+		return 0, fmt.Errorf("class not found")
+	}
+	if _, err := tx.Exec(`
+		UPDATE classes SET lateness_tiers = ?, updated_at = ? WHERE class_id = ?
+	`, stored, a.now(), classID); err != nil {
+		return 0, fmt.Errorf("failed to save lateness rule: %w", err)
+	}
+
+	query := `
+		SELECT session_id FROM attendance_sessions
+		WHERE class_id = ? AND COALESCE(is_archived, 0) = 0 AND lateness_tiers IS NULL`
+	if !recomputePast {
+		query += ` AND status <> 'closed'`
+	}
+	rows, err := tx.Query(query, classID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load sessions to recompute: %w", err)
+	}
+	var sessionIDs []int
+	for rows.Next() {
+		var sessionID int
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+
+	changed := 0
+	for _, sessionID := range sessionIDs {
+		n, err := a.recomputeSessionLateness(tx, session, sessionID)
+		if err != nil {
+			return changed, err
+		}
+		changed += n
+	}
+
+	if err := a.recordAuditEvent(tx, session, "update_lateness_rule", "class", classID,
+		auditValues{"lateness_tiers": scanNullString(previous)},
+		auditValues{"lateness_tiers": stored, "recompute_past": recomputePast, "changed_records": changed}); err != nil {
+		return changed, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit lateness rule: %w", err)
+	}
+
+	if changed > 0 {
+		if err := a.evaluateAttendanceAlerts(classID); err != nil {
+			log.Printf("Failed to evaluate attendance alerts for class %d: %v", classID, err)
+		}
+	}
+	return changed, nil
+}
+
+// GetSessionLatenessRule returns the rule a session uses and where it comes from.
+func (a *App) GetSessionLatenessRule(sessionID int) (*LatenessRule, error) {
+	session, err := a.requireRole("GetSessionLatenessRule")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	var classID int
+	err = a.db.QueryRow(`SELECT class_id FROM attendance_sessions WHERE session_id = ?`, sessionID).Scan(&classID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	rule, _, err := loadSessionLatenessRule(a.db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SaveSessionLatenessRule gives one of the teacher's sessions its own lateness rule; a rule
+// without tiers goes back to the class's rule. The session's time-ins are recomputed, and
+// the number of attendance statuses that changed is returned.
+func (a *App) SaveSessionLatenessRule(sessionID int, teacherUserID int, rule LatenessRule) (int, error) {
+	session, err := a.requireActingUser("SaveSessionLatenessRule", teacherUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.checkDB(); err != nil {
+		return 0, err
+	}
+	rule, err = normalizeLatenessRule(rule)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := encodeLatenessRule(rule)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var classID int
+	var previous sql.NullString
+	err = tx.QueryRow(`
+		SELECT s.class_id, s.lateness_tiers
+		FROM attendance_sessions s
+		JOIN classes c ON s.class_id = c.class_id
+		WHERE s.session_id = ? AND c.teacher_id = ? AND COALESCE(s.is_archived, 0) = 0
+	`, sessionID, teacherUserID).Scan(&classID, &previous)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("session not found or not authorized")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE attendance_sessions SET lateness_tiers = ?, updated_at = ? WHERE session_id = ?
+	`, stored, a.now(), sessionID); err != nil {
+		return 0, fmt.Errorf("failed to save lateness rule: %w", err)
+	}
+	changed, err := a.recomputeSessionLateness(tx, session, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.recordAuditEvent(tx, session, "update_lateness_rule", "attendance_session", sessionID,
+		auditValues{"lateness_tiers": scanNullString(previous)},
+		auditValues{"lateness_tiers": stored, "changed_records": changed}); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit lateness rule: %w", err)
+	}
+
+	if changed > 0 {
+		a.evaluateAttendanceAlertsForSession(sessionID)
+	}
+	return changed, nil
+}
+
+// recomputeSessionLateness re-applies the session's effective rule to its recorded
+// time-ins, refreshing minutes late and recording each status it changes. Rows changed
+// by hand after the time-in keep their status. It returns how many statuses changed.
+func (a *App) recomputeSessionLateness(exec dbExecutor, actor *appSession, sessionID int) (int, error) {
+	rule, openedAt, err := loadSessionLatenessRule(exec, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := exec.Query(`
+		SELECT a.id, a.student_id, a.status, a.remarks, a.time_in_at, a.minutes_late
+		FROM attendance a
+		WHERE a.session_id = ? AND a.time_in_at IS NOT NULL AND COALESCE(a.is_archived, 0) = 0
+			AND LOWER(LTRIM(RTRIM(COALESCE(a.status, '')))) IN ('present', 'late', 'absent')
+			AND NOT EXISTS (
+				SELECT 1 FROM attendance_changes ch
+				WHERE ch.attendance_id = a.id AND ch.changed_at >= a.time_in_at AND ch.reason <> ?
+			)
+	`, sessionID, latenessRecomputeReason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load time-ins to recompute: %w", err)
+	}
+
+	type timedInRow struct {
+		attendanceID, studentID         int
+		previousStatus, previousRemarks sql.NullString
+		timeInAt                        time.Time
+		minutesLate                     sql.NullInt64
+	}
+	var timedIn []timedInRow
+	for rows.Next() {
+		var row timedInRow
+		if err := rows.Scan(&row.attendanceID, &row.studentID, &row.previousStatus, &row.previousRemarks,
+			&row.timeInAt, &row.minutesLate); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to load time-ins to recompute: %w", err)
+		}
+		timedIn = append(timedIn, row)
+	}
+	rows.Close()
+
+	changedBy, changedByName := sql.NullInt64{}, ""
+	if actor != nil {
+		changedBy, changedByName = sql.NullInt64{Int64: int64(actor.UserID), Valid: true}, actor.Username
+	}
+	changed := 0
+	for _, row := range timedIn {
+		status, minutesLate := rule.evaluate(openedAt, row.timeInAt)
+		previous := strings.ToLower(strings.TrimSpace(row.previousStatus.String))
+		if previous == status {
+			if minutesLate != row.minutesLate {
+				if _, err := exec.Exec(`UPDATE attendance SET minutes_late = ? WHERE id = ?`, minutesLate, row.attendanceID); err != nil {
+					return changed, fmt.Errorf("failed to update minutes late: %w", err)
+				}
+			}
+			continue
+		}
+		remarks := *normalizeAttendanceRemark(status, sql.NullString{})
+		if _, err := exec.Exec(`
+			UPDATE attendance
+			SET status = ?, remarks = ?, minutes_late = ?, updated_at = ?
+			WHERE id = ?
+		`, status, remarks, minutesLate, a.now(), row.attendanceID); err != nil {
+			return changed, fmt.Errorf("failed to recompute attendance: %w", err)
+		}
+		if _, err := exec.Exec(`
+			INSERT INTO attendance_changes
+				(attendance_id, session_id, student_id, changed_by_user_id, changed_by_name, changed_at,
+				 previous_status, new_status, previous_remarks, new_remarks, reason)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, row.attendanceID, sessionID, row.studentID, changedBy, changedByName, a.now(),
+			row.previousStatus, status, row.previousRemarks, remarks, latenessRecomputeReason); err != nil {
+			return changed, fmt.Errorf("failed to record attendance change: %w", err)
+		}
+		changed++
+	}
+	return changed, nil
+}
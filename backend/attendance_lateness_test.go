@@ -0,0 +1,192 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// departmentLatenessRule is present within 15 minutes, late within 30, absent after.
+var departmentLatenessRule = LatenessRule{
+	Tiers:       []LatenessTier{{UpToMinutes: 15, Status: "present"}, {UpToMinutes: 30, Status: "late"}},
+	AfterStatus: "absent",
+}
+
+// timeInAfter moves the clock on by d and times the student in.
+func timeInAfter(e *testEnv, d time.Duration, sessionID int, username string) {
+	e.t.Helper()
+	e.clock.Advance(d)
+	keepStationsAlive(e)
+	user := e.loginAs(username)
+	if err := e.app.StudentTimeIn(sessionID, user.ID, e.timeInCode(sessionID)); err != nil {
+		e.t.Fatalf("StudentTimeIn %s: %v", username, err)
+	}
+}
+
+func TestLatenessTiersDecideTimeInStatus(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	carlID := e.seedUser("student", "2024-00003", "Carl", "Lim")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID, carlID)
+
+	e.loginAs("T-0001")
+	for _, rule := range []LatenessRule{
+		{Tiers: []LatenessTier{{UpToMinutes: 30, Status: "late"}, {UpToMinutes: 15, Status: "present"}}, AfterStatus: "absent"},
+		{Tiers: []LatenessTier{{UpToMinutes: 15, Status: "late"}, {UpToMinutes: 30, Status: "present"}}, AfterStatus: "absent"},
+		{Tiers: []LatenessTier{{UpToMinutes: 15, Status: "present"}}, AfterStatus: "excused"},
+	} {
+		if _, err := e.app.SaveClassLatenessRule(classID, teacherID, rule, false); err == nil {
+			t.Errorf("saved an invalid lateness rule %+v", rule)
+		}
+	}
+	if _, err := e.app.SaveClassLatenessRule(classID, teacherID, departmentLatenessRule, false); err != nil {
+		t.Fatalf("SaveClassLatenessRule: %v", err)
+	}
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 90, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	rule, err := e.app.GetSessionLatenessRule(session.SessionID)
+	if err != nil || rule.Source != latenessSourceClass || len(rule.Tiers) != 2 {
+		t.Fatalf("GetSessionLatenessRule = %+v, %v; want the class's two tiers", rule, err)
+	}
+	e.loginAs("2024-00001")
+	open, err := e.app.GetStudentOpenAttendanceSessions(anaID)
+	if err != nil || len(open) != 1 || open[0].GracePeriodMinutes == nil || *open[0].GracePeriodMinutes != 15 {
+		t.Errorf("open sessions = %+v, %v; want a 15 minute present window", open, err)
+	}
+
+	timeInAfter(e, 12*time.Minute, session.SessionID, "2024-00001")
+	timeInAfter(e, 10*time.Minute, session.SessionID, "2024-00002")
+	timeInAfter(e, 20*time.Minute, session.SessionID, "2024-00003")
+
+	for _, want := range []struct {
+		studentID   int
+		status      string
+		minutesLate int
+	}{{anaID, "present", 12}, {benID, "late", 22}, {carlID, "absent", 42}} {
+		if got := attendanceStatus(e, session.SessionID, want.studentID); got != want.status {
+			t.Errorf("student %d status = %q, want %q", want.studentID, got, want.status)
+		}
+		if got := e.queryInt(`SELECT COALESCE(minutes_late, -1) FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, want.studentID); got != want.minutesLate {
+			t.Errorf("student %d minutes late = %d, want %d", want.studentID, got, want.minutesLate)
+		}
+	}
+	// Past every tier the time-in is still on record.
+	if got := e.queryInt(`SELECT COUNT(*) FROM attendance WHERE session_id = ? AND student_id = ? AND time_in_at IS NOT NULL`, session.SessionID, carlID); got != 1 {
+		t.Errorf("Carl's time-in was not recorded")
+	}
+}
+
+func TestChangingLatenessRulesRecomputesTimeIns(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	// With the session's 10 minute grace period, Ana is present and Ben late.
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	timeInAfter(e, 5*time.Minute, session.SessionID, "2024-00001")
+	timeInAfter(e, 15*time.Minute, session.SessionID, "2024-00002")
+	e.loginAs("T-0001")
+	if err := e.app.UpdateSessionAttendanceRecord(session.SessionID, anaID, teacherID, "late", "", "Left for half the class"); err != nil {
+		t.Fatalf("UpdateSessionAttendanceRecord: %v", err)
+	}
+	if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("SaveAttendanceSession: %v", err)
+	}
+
+	// Closed sessions keep their statuses unless the teacher asks to recompute them.
+	wider := LatenessRule{Tiers: []LatenessTier{{UpToMinutes: 25, Status: "present"}}, AfterStatus: "late"}
+	if changed, err := e.app.SaveClassLatenessRule(classID, teacherID, wider, false); err != nil || changed != 0 {
+		t.Fatalf("SaveClassLatenessRule = %d, %v; want no changes", changed, err)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got != "late" {
+		t.Errorf("Ben's status = %q, want late", got)
+	}
+	if changed, err := e.app.SaveClassLatenessRule(classID, teacherID, wider, true); err != nil || changed != 1 {
+		t.Fatalf("SaveClassLatenessRule = %d, %v; want Ben's status changed", changed, err)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got != "present" {
+		t.Errorf("Ben's status after recomputing = %q, want present", got)
+	}
+	// Ana's status was set by hand and stays.
+	if got := attendanceStatus(e, session.SessionID, anaID); got != "late" {
+		t.Errorf("Ana's status after recomputing = %q, want late", got)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM attendance_changes WHERE session_id = ? AND student_id = ? AND reason = ?`, session.SessionID, benID, latenessRecomputeReason); got != 1 {
+		t.Errorf("recorded %d lateness changes for Ben, want 1", got)
+	}
+
+	// A rule on the session itself overrides the class's.
+	strict := LatenessRule{Tiers: []LatenessTier{{UpToMinutes: 5, Status: "present"}}, AfterStatus: "absent"}
+	if changed, err := e.app.SaveSessionLatenessRule(session.SessionID, teacherID, strict); err != nil || changed != 1 {
+		t.Fatalf("SaveSessionLatenessRule = %d, %v; want Ben's status changed", changed, err)
+	}
+	if got := attendanceStatus(e, session.SessionID, benID); got != "absent" {
+		t.Errorf("Ben's status under the session rule = %q, want absent", got)
+	}
+	if rule, err := e.app.GetSessionLatenessRule(session.SessionID); err != nil || rule.Source != latenessSourceSession {
+		t.Errorf("GetSessionLatenessRule = %+v, %v; want the session's own rule", rule, err)
+	}
+	if changed, err := e.app.SaveClassLatenessRule(classID, teacherID, LatenessRule{}, true); err != nil || changed != 0 {
+		t.Errorf("class rule change touched a session with its own rule: %d, %v", changed, err)
+	}
+}
+
+func TestExportShowsMinutesLate(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	e.loginAs("T-0001")
+	if _, err := e.app.SaveClassLatenessRule(classID, teacherID, departmentLatenessRule, false); err != nil {
+		t.Fatalf("SaveClassLatenessRule: %v", err)
+	}
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 90, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	timeInAfter(e, 3*time.Minute, session.SessionID, "2024-00001")
+	timeInAfter(e, 24*time.Minute, session.SessionID, "2024-00002")
+
+	e.loginAs("T-0001")
+	records, err := e.app.GetSessionAttendance(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetSessionAttendance: %v", err)
+	}
+	for _, record := range records {
+		if record.StudentUserID == benID && (record.MinutesLate == nil || *record.MinutesLate != 27) {
+			t.Errorf("Ben's minutes late = %v, want 27", record.MinutesLate)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "attendance.csv")
+	if _, err := e.app.ExportAttendanceCSVBySession(classID, e.clock.Today(), session.SessionID, path); err != nil {
+		t.Fatalf("ExportAttendanceCSVBySession: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var benLine string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.Contains(line, "Santos, Ben") {
+			benLine = line
+		}
+	}
+	if !strings.Contains(string(data), "MIN LATE") || !strings.Contains(benLine, ",27,") {
+		t.Errorf("export does not show Ben 27 minutes late:\n%s", data)
+	}
+}
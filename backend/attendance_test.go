@@ -0,0 +1,217 @@
+package backend
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func attendanceStatus(e *testEnv, sessionID, studentID int) string {
+	e.t.Helper()
+	return e.queryString(`SELECT status FROM attendance WHERE session_id = ? AND student_id = ?`, sessionID, studentID)
+}
+
+func TestAttendanceSessionTimeInAndExpiry(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	onTimeID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	lateID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	absentID := e.seedUser("student", "2024-00003", "Carl", "Lim")
+	classID := e.seedClass(teacherID, "IT101", onTimeID, lateID, absentID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	if session.Status != "open" {
+		t.Fatalf("new session status = %q, want open", session.Status)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM attendance WHERE session_id = ?`, session.SessionID); got != 3 {
+		t.Fatalf("attendance rows = %d, want one per enrolled student", got)
+	}
+
+	if _, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "Second", teacherID, 60, 10); err == nil {
+		t.Fatalf("second open session for the same class was allowed")
+	}
+
+	e.clock.Advance(5 * time.Minute)
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, onTimeID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn within grace: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, onTimeID); got != "present" {
+		t.Errorf("time-in within grace period = %q, want present", got)
+	}
+
+	e.clock.Advance(10 * time.Minute)
+	e.loginAs("2024-00002")
+	if err := e.app.StudentTimeIn(session.SessionID, lateID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn after grace: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, lateID); got != "late" {
+		t.Errorf("time-in after grace period = %q, want late", got)
+	}
+
+	// Nothing expires before the class duration is over.
+	e.clock.Advance(30 * time.Minute)
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+	if got := e.queryString(`SELECT status FROM attendance_sessions WHERE session_id = ?`, session.SessionID); got != "open" {
+		t.Fatalf("session status before expiry = %q, want open", got)
+	}
+
+	e.clock.Advance(16 * time.Minute)
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+	if got := e.queryString(`SELECT status FROM attendance_sessions WHERE session_id = ?`, session.SessionID); got != "closed" {
+		t.Fatalf("session status after expiry = %q, want closed", got)
+	}
+	if got := attendanceStatus(e, session.SessionID, absentID); got != "absent" {
+		t.Errorf("student without time-in = %q, want absent", got)
+	}
+	if got := attendanceStatus(e, session.SessionID, onTimeID); got != "present" {
+		t.Errorf("expiry changed a present student to %q", got)
+	}
+	if got := attendanceStatus(e, session.SessionID, lateID); got != "late" {
+		t.Errorf("expiry changed a late student to %q", got)
+	}
+
+	e.loginAs("2024-00003")
+	err = e.app.StudentTimeIn(session.SessionID, absentID, e.timeInCode(session.SessionID))
+	if err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("time-in on closed session: err = %v, want closed error", err)
+	}
+}
+
+func TestAttendanceSessionPauseBlocksTimeIn(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT102", studentID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "Lab", teacherID, 90, 15)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	if err := e.app.PauseAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("PauseAttendanceSession: %v", err)
+	}
+
+	e.clock.Advance(2 * time.Minute)
+	e.loginAs("2024-00001")
+	err = e.app.StudentTimeIn(session.SessionID, studentID, e.timeInCode(session.SessionID))
+	if err == nil || !strings.Contains(err.Error(), "paused") {
+		t.Fatalf("time-in on paused session: err = %v, want paused error", err)
+	}
+
+	e.loginAs("T-0001")
+	if err := e.app.ResumeAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("ResumeAttendanceSession: %v", err)
+	}
+
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, studentID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn after resume: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, studentID); got != "present" {
+		t.Errorf("time-in after resume = %q, want present", got)
+	}
+}
+
+func TestStudentTimeInRequiresEnrollmentAndOwnSession(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	enrolledID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	outsiderID := e.seedUser("student", "2024-00009", "Dan", "Uy")
+	classID := e.seedClass(teacherID, "IT103", enrolledID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "Lecture", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+
+	e.loginAs("2024-00009")
+	if err := e.app.StudentTimeIn(session.SessionID, outsiderID, e.timeInCode(session.SessionID)); err == nil {
+		t.Fatalf("student outside the class was able to time in")
+	}
+	if err := e.app.StudentTimeIn(session.SessionID, enrolledID, e.timeInCode(session.SessionID)); err == nil {
+		t.Fatalf("student was able to time in on behalf of another student")
+	}
+}
+
+func TestAttendanceOverrideKeepsHistory(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	otherTeacherID := e.seedUser("teacher", "T-0002", "Omar", "Tan")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT104", studentID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "Lecture", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	e.clock.Advance(61 * time.Minute)
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+
+	err = e.app.UpdateSessionAttendanceRecord(session.SessionID, studentID, teacherID, "present", "", "  ")
+	if err == nil || !strings.Contains(err.Error(), "reason") {
+		t.Fatalf("change without a reason: err = %v, want reason error", err)
+	}
+	if err := e.app.UpdateSessionAttendanceRecord(session.SessionID, studentID, teacherID, "present", "", "Was in the lab; PC would not boot"); err != nil {
+		t.Fatalf("UpdateSessionAttendanceRecord: %v", err)
+	}
+	e.clock.Advance(24 * time.Hour)
+	e.loginAs("T-0001")
+	if err := e.app.UpdateAttendanceRecord(classID, studentID, session.AttendanceDate, "late", "Came in during the quiz", "Arrived after the grace period"); err != nil {
+		t.Fatalf("UpdateAttendanceRecord: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, studentID); got != "late" {
+		t.Fatalf("status after overrides = %q, want late", got)
+	}
+
+	history, err := e.app.GetAttendanceRecordHistory(session.SessionID, studentID)
+	if err != nil {
+		t.Fatalf("GetAttendanceRecordHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history has %d change(s), want 2", len(history))
+	}
+	first := history[0]
+	if first.PreviousStatus == nil || *first.PreviousStatus != "absent" || first.NewStatus == nil || *first.NewStatus != "present" {
+		t.Errorf("first change = %v -> %v, want absent -> present", first.PreviousStatus, first.NewStatus)
+	}
+	if first.ChangedByUserID == nil || *first.ChangedByUserID != teacherID || first.ChangedByName != "Reyes, Tess" {
+		t.Errorf("first change by %v/%s, want the class teacher", first.ChangedByUserID, first.ChangedByName)
+	}
+	if first.Reason != "Was in the lab; PC would not boot" {
+		t.Errorf("first change reason = %q", first.Reason)
+	}
+	if history[1].PreviousStatus == nil || *history[1].PreviousStatus != "present" {
+		t.Errorf("second change previous status = %v, want present", history[1].PreviousStatus)
+	}
+	if history[1].NewRemarks == nil || *history[1].NewRemarks != "Came in during the quiz" || history[1].Reason != "Arrived after the grace period" {
+		t.Errorf("second change remarks/reason = %v/%q, want the teacher's remarks and reason kept apart", history[1].NewRemarks, history[1].Reason)
+	}
+
+	path := filepath.Join(t.TempDir(), "attendance.pdf")
+	if _, err := e.app.ExportAttendancePDFBySession(classID, session.AttendanceDate, session.SessionID, true, path); err != nil {
+		t.Fatalf("ExportAttendancePDFBySession with changes: %v", err)
+	}
+
+	e.loginAs("T-0002")
+	if err := e.app.UpdateSessionAttendanceRecord(session.SessionID, studentID, otherTeacherID, "absent", "", "Not my class"); err == nil {
+		t.Fatalf("another teacher was able to change the attendance")
+	}
+	if _, err := e.app.GetAttendanceRecordHistory(session.SessionID, studentID); err == nil {
+		t.Fatalf("another teacher was able to read the change history")
+	}
+}
@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// ATTENDANCE TIME-OUT
+// ==============================================================================
+//
+// A time-in starts a presence segment (attendance.present_since). The segment ends with
+// a time-out, which adds its length to minutes_attended:
+//   - student: the student clicks Time Out;
+//   - logout: the student logs out (or is logged out) with no other station still open;
+//   - stale: the student's station stops sending heartbeats, timed out at the last one;
+//   - session_closed: the session closes while the student is still timed in.
+// A segment never runs past the session's class duration or its close. Timing in again
+// after a time-out starts a new segment and keeps the original status.
+//
+// With [policy] attendance_min_presence_percent set, a present or late student who
+// attended less than that share of the class duration is reported as partial (or
+// absent, per attendance_short_presence_status) in exports. The stored status is not
+// changed, so the teacher can still see how the student first timed in.
+
+const (
+	timeOutSourceStudent       = "student"
+	timeOutSourceLogout        = "logout"
+	timeOutSourceStale         = "stale"
+	timeOutSourceSessionClosed = "session_closed"
+
+	attendanceStatusPartial = "partial"
+	attendanceStatusAbsent  = "absent"
+)
+
+var shortPresenceStatuses = []string{attendanceStatusPartial, attendanceStatusAbsent}
+
+// minimumPresencePolicy is the minimum-presence rule applied to exports.
+type minimumPresencePolicy struct {
+	percent     int
+	shortStatus string
+}
+
+// loadMinimumPresencePolicy reads [policy] attendance_min_presence_percent (0 turns the
+// rule off) and attendance_short_presence_status, or their ATTENDANCE_* env overrides.
+func loadMinimumPresencePolicy() minimumPresencePolicy {
+	return minimumPresencePolicy{
+		percent: loadPolicyInt("ATTENDANCE_MIN_PRESENCE_PERCENT", "attendance_min_presence_percent", 0, 100, 0),
+		shortStatus: loadPolicyChoice("ATTENDANCE_SHORT_PRESENCE_STATUS", "attendance_short_presence_status",
+			shortPresenceStatuses, attendanceStatusPartial),
+	}
+}
+
+// shortStatusFor returns the status a present or late record is reported as, or "" when
+// the record meets the rule (or the rule does not apply to it).
+func (p minimumPresencePolicy) shortStatusFor(status string, minutesAttended, classDuration sql.NullInt64, stillPresent bool) string {
+	normalized := strings.ToLower(strings.TrimSpace(status))
+	if p.percent <= 0 || stillPresent || !minutesAttended.Valid || classDuration.Int64 <= 0 {
+		return ""
+	}
+	if normalized != "present" && normalized != "late" {
+		return ""
+	}
+	required := (classDuration.Int64*int64(p.percent) + 99) / 100
+	if minutesAttended.Int64 >= required {
+		return ""
+	}
+	return p.shortStatus
+}
+
+// applyAttendanceTimeOut fills the time-out fields of a record and applies the
+// minimum-presence rule to its reported status. It returns the remark an export shows
+// for a downgraded record, or "" when the record meets the rule.
+func applyAttendanceTimeOut(att *Attendance, timeOut sql.NullString, minutesAttended, classDuration sql.NullInt64, stillPresent bool, policy minimumPresencePolicy) string {
+	if timeOut.Valid && !stillPresent {
+		att.TimeOut = &timeOut.String
+	}
+	if minutesAttended.Valid {
+		minutes := int(minutesAttended.Int64)
+		att.MinutesAttended = &minutes
+	}
+	short := policy.shortStatusFor(att.Status, minutesAttended, classDuration, stillPresent)
+	if short == "" {
+		return ""
+	}
+	att.PresenceStatus = short
+	return fmt.Sprintf("%s (%d of %d min)", strings.ToUpper(short[:1])+short[1:], minutesAttended.Int64, classDuration.Int64)
+}
+
+// timeOutAttendance ends the open presence segments matched by where (over attendance a
+// joined to attendance_sessions s) at the given time, and returns how many it ended.
+func (a *App) timeOutAttendance(exec dbExecutor, where string, args []interface{}, at time.Time, source string) (int, error) {
+	rows, err := exec.Query(`
+		SELECT a.id, a.present_since, s.opened_at, COALESCE(s.class_duration_minutes, 0), s.closed_at
+		FROM attendance a
+		JOIN attendance_sessions s ON s.session_id = a.session_id
+		WHERE a.present_since IS NOT NULL AND COALESCE(a.is_archived, 0) = 0 AND `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load open attendance: %w", err)
+	}
+
+	type openSegment struct {
+		id       int64
+		since    time.Time
+		endLimit time.Time
+	}
+	var segments []openSegment
+	for rows.Next() {
+		var segment openSegment
+		var openedAt, closedAt sql.NullTime
+		var classDuration int
+		if err := rows.Scan(&segment.id, &segment.since, &openedAt, &classDuration, &closedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to load open attendance: %w", err)
+		}
+		segment.endLimit = at
+		if closedAt.Valid && closedAt.Time.Before(segment.endLimit) {
+			segment.endLimit = closedAt.Time
+		}
+		if openedAt.Valid && classDuration > 0 {
+			if classEnd := openedAt.Time.Add(time.Duration(classDuration) * time.Minute); classEnd.Before(segment.endLimit) {
+				segment.endLimit = classEnd
+			}
+		}
+		segments = append(segments, segment)
+	}
+	rows.Close()
+
+	ended := 0
+	for _, segment := range segments {
+		end := segment.endLimit
+		if end.Before(segment.since) {
+			end = segment.since
+		}
+		minutes := int(end.Sub(segment.since).Round(time.Minute) / time.Minute)
+		result, err := exec.Exec(`
+			UPDATE attendance
+			SET minutes_attended = COALESCE(minutes_attended, 0) + ?,
+				time_out_at = ?,
+				time_out_source = ?,
+				present_since = NULL,
+				updated_at = ?
+			WHERE id = ? AND present_since IS NOT NULL
+		`, minutes, end, source, a.now(), segment.id)
+		if err != nil {
+			return ended, fmt.Errorf("failed to record time-out: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			ended++
+		}
+	}
+	return ended, nil
+}
+
+// timeOutStudentFromOpenSessions ends the student's presence in every open session, as
+// of at. Used when their login ends.
+func (a *App) timeOutStudentFromOpenSessions(studentUserID int, at time.Time, source string) {
+	ended, err := a.timeOutAttendance(a.db, `a.student_id = ? AND s.status = 'open'`, []interface{}{studentUserID}, at, source)
+	if err != nil {
+		log.Printf("Failed to time out student %d: %v", studentUserID, err)
+		return
+	}
+	if ended > 0 {
+		log.Printf("Timed out student %d from %d open attendance session(s) (%s)", studentUserID, ended, source)
+	}
+}
+
+// timeOutClosedSessions ends presence segments still open in sessions that have closed,
+// at each session's close.
+func (a *App) timeOutClosedSessions() {
+	if _, err := a.timeOutAttendance(a.db, `s.status = 'closed'`, nil, a.now(), timeOutSourceSessionClosed); err != nil {
+		log.Printf("Failed to time out students from closed sessions: %v", err)
+	}
+}
+
+// StudentTimeOut ends the student's presence in an open session, e.g. when they leave
+// class early. They can time in again later with a new code.
+func (a *App) StudentTimeOut(sessionID int, studentUserID int) error {
+	if _, err := a.requireActingUser("StudentTimeOut", studentUserID); err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	if err := ValidatePositiveID(sessionID, "session ID"); err != nil {
+		return err
+	}
+
+	ended, err := a.timeOutAttendance(a.db, `a.session_id = ? AND a.student_id = ? AND s.status = 'open'`,
+		[]interface{}{sessionID, studentUserID}, a.now(), timeOutSourceStudent)
+	if err != nil {
+		return err
+	}
+	if ended == 0 {
+		return fmt.Errorf("you are not timed in to this session")
+	}
+	return nil
+}
+
+// timeOutStaleStudents ends the presence of users whose station stopped sending
+// heartbeats before cutoff, at their last heartbeat.
+func (a *App) timeOutStaleStudents(cutoff time.Time) {
+	rows, err := a.db.Query(`
+		SELECT sh.user_id, sh.last_seen
+		FROM user_session_heartbeats sh
+		WHERE sh.last_seen < ?
+			AND EXISTS (SELECT 1 FROM log_entries le WHERE le.user_id = sh.user_id AND le.logout_time IS NULL)
+	`, cutoff)
+	if err != nil {
+		log.Printf("Failed to load stale sessions for time-out: %v", err)
+		return
+	}
+	lastSeen := map[int]time.Time{}
+	for rows.Next() {
+		var userID int
+		var seen time.Time
+		if err := rows.Scan(&userID, &seen); err != nil {
+			log.Printf("Failed to load stale sessions for time-out: %v", err)
+			break
+		}
+		lastSeen[userID] = seen
+	}
+	rows.Close()
+
+	for userID, seen := range lastSeen {
+		a.timeOutStudentFromOpenSessions(userID, seen, timeOutSourceStale)
+	}
+}
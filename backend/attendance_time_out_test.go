@@ -0,0 +1,195 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keepStationsAlive stands in for the heartbeats every logged-in station sends while the
+// test clock jumps ahead.
+func keepStationsAlive(e *testEnv) {
+	e.t.Helper()
+	e.exec(`UPDATE user_session_heartbeats SET last_seen = ?`, e.clock.Now())
+}
+
+func TestStudentTimeOutTracksMinutesAttended(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	carlID := e.seedUser("student", "2024-00003", "Carl", "Lim")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID, carlID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	for _, username := range []string{"2024-00001", "2024-00002", "2024-00003"} {
+		user := e.loginAs(username)
+		if err := e.app.StudentTimeIn(session.SessionID, user.ID, e.timeInCode(session.SessionID)); err != nil {
+			t.Fatalf("StudentTimeIn %s: %v", username, err)
+		}
+	}
+
+	// Ana leaves after 5 minutes and comes back 10 minutes later, still present.
+	e.clock.Advance(5 * time.Minute)
+	keepStationsAlive(e)
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeOut(session.SessionID, anaID); err != nil {
+		t.Fatalf("StudentTimeOut: %v", err)
+	}
+	if err := e.app.StudentTimeOut(session.SessionID, anaID); err == nil {
+		t.Errorf("timed out twice without timing in again")
+	}
+	e.clock.Advance(10 * time.Minute)
+	keepStationsAlive(e)
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn after time-out: %v", err)
+	}
+	if got := attendanceStatus(e, session.SessionID, anaID); got != "present" {
+		t.Errorf("status after timing in again = %q, want present", got)
+	}
+	if open, err := e.app.GetStudentOpenAttendanceSessions(anaID); err != nil || len(open) != 1 || !open[0].TimedIn {
+		t.Errorf("open sessions while timed in = %+v, %v; want the session, marked timed in", open, err)
+	}
+
+	// Ben's login ends, which times him out.
+	e.loginAs("2024-00002")
+	if err := e.app.Logout(benID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if got := e.queryString(`SELECT time_out_source FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, benID); got != timeOutSourceLogout {
+		t.Errorf("Ben's time-out source = %q, want logout", got)
+	}
+
+	// The session closes at its class duration; Ana and Carl are timed out at the end.
+	e.clock.Advance(time.Hour)
+	keepStationsAlive(e)
+	e.loginAs("T-0001")
+	if err := e.app.closeExpiredAttendanceSessions(); err != nil {
+		t.Fatalf("closeExpiredAttendanceSessions: %v", err)
+	}
+	records, err := e.app.GetSessionAttendance(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetSessionAttendance: %v", err)
+	}
+	minutes := map[int]int{}
+	for _, record := range records {
+		if record.MinutesAttended == nil || record.TimeOut == nil {
+			t.Fatalf("record %+v has no time-out", record)
+		}
+		minutes[record.StudentUserID] = *record.MinutesAttended
+	}
+	if minutes[anaID] != 50 || minutes[benID] != 15 || minutes[carlID] != 60 {
+		t.Errorf("minutes attended = %v, want Ana 50, Ben 15, Carl 60", minutes)
+	}
+	if got := e.queryString(`SELECT time_out_source FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, carlID); got != timeOutSourceSessionClosed {
+		t.Errorf("Carl's time-out source = %q, want session_closed", got)
+	}
+}
+
+func TestStaleStationTimesOutAtLastHeartbeat(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT101", anaID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 90, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn: %v", err)
+	}
+	e.clock.Advance(20 * time.Minute)
+	if err := e.app.TouchSession(anaID); err != nil {
+		t.Fatalf("TouchSession: %v", err)
+	}
+
+	// The station goes silent; Ana is timed out at her last heartbeat, not when it is noticed.
+	e.clock.Advance(time.Duration(sessionHeartbeatTimeoutSeconds+60) * time.Second)
+	if err := e.app.closeStaleSessions(); err != nil {
+		t.Fatalf("closeStaleSessions: %v", err)
+	}
+	if got := e.queryInt(`SELECT minutes_attended FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, anaID); got != 20 {
+		t.Errorf("minutes attended = %d, want 20", got)
+	}
+	if got := e.queryString(`SELECT time_out_source FROM attendance WHERE session_id = ? AND student_id = ?`, session.SessionID, anaID); got != timeOutSourceStale {
+		t.Errorf("time-out source = %q, want stale", got)
+	}
+}
+
+func TestMinimumPresenceRuleDowngradesExports(t *testing.T) {
+	t.Setenv("ATTENDANCE_MIN_PRESENCE_PERCENT", "75")
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+
+	e.loginAs("T-0001")
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+	for _, username := range []string{"2024-00001", "2024-00002"} {
+		user := e.loginAs(username)
+		if err := e.app.StudentTimeIn(session.SessionID, user.ID, e.timeInCode(session.SessionID)); err != nil {
+			t.Fatalf("StudentTimeIn %s: %v", username, err)
+		}
+	}
+	e.clock.Advance(30 * time.Minute)
+	keepStationsAlive(e)
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeOut(session.SessionID, anaID); err != nil {
+		t.Fatalf("StudentTimeOut: %v", err)
+	}
+	e.clock.Advance(20 * time.Minute)
+	keepStationsAlive(e)
+	e.loginAs("T-0001")
+	if err := e.app.SaveAttendanceSession(session.SessionID, teacherID); err != nil {
+		t.Fatalf("SaveAttendanceSession: %v", err)
+	}
+
+	records, err := e.app.GetSessionAttendance(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetSessionAttendance: %v", err)
+	}
+	for _, record := range records {
+		want := ""
+		if record.StudentUserID == anaID {
+			want = attendanceStatusPartial
+		}
+		if record.Status != "present" || record.PresenceStatus != want {
+			t.Errorf("student %d: status %q, presence %q, want present and %q", record.StudentUserID, record.Status, record.PresenceStatus, want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "attendance.csv")
+	if _, err := e.app.ExportAttendanceCSVBySession(classID, e.clock.Today(), session.SessionID, path); err != nil {
+		t.Fatalf("ExportAttendanceCSVBySession: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	if !strings.Contains(string(data), "TIME OUT") || !strings.Contains(string(data), "Partial (30 of 60 min)") {
+		t.Errorf("export is missing the time-out columns or the partial remark:\n%s", data)
+	}
+
+	// The short status can be absent instead.
+	t.Setenv("ATTENDANCE_SHORT_PRESENCE_STATUS", "absent")
+	if _, err := e.app.ExportAttendanceCSVBySession(classID, e.clock.Today(), session.SessionID, path); err != nil {
+		t.Fatalf("ExportAttendanceCSVBySession: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "Absent (30 of 60 min)") {
+		t.Errorf("export is missing the absent remark:\n%s", data)
+	}
+}
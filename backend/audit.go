@@ -0,0 +1,308 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// AUDIT TRAIL
+// ==============================================================================
+//
+// Administrative and teacher actions are recorded in audit_events with the acting
+// user, role and station taken from the server-side session, plus the before/after
+// values of whatever changed. The table is append-only: the app never updates or
+// deletes audit rows, and nothing here exposes a way to do so.
+
+const (
+	auditDefaultLimit = 200
+	auditMaxLimit     = 5000
+)
+
+// AuditEvent is one recorded action.
+type AuditEvent struct {
+	ID          int     `json:"id"`
+	OccurredAt  string  `json:"occurred_at"`
+	ActorUserID *int    `json:"actor_user_id,omitempty"`
+	ActorName   string  `json:"actor_name"`
+	ActorRole   string  `json:"actor_role"`
+	Station     string  `json:"station"`
+	Action      string  `json:"action"`
+	EntityType  string  `json:"entity_type"`
+	EntityID    string  `json:"entity_id"`
+	Before      *string `json:"before,omitempty"`
+	After       *string `json:"after,omitempty"`
+}
+
+// AuditEventFilter narrows GetAuditEvents and the audit exports. Zero values match everything;
+// dates are YYYY-MM-DD and inclusive.
+type AuditEventFilter struct {
+	ActorUserID int    `json:"actor_user_id"`
+	EntityType  string `json:"entity_type"`
+	EntityID    string `json:"entity_id"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Limit       int    `json:"limit"`
+}
+
+// auditValues is the before/after snapshot stored with an event.
+type auditValues map[string]interface{}
+
+// recordAuditEvent appends an audit row using exec, so callers inside a transaction
+// commit the audit entry together with the change it describes.
+func (a *App) recordAuditEvent(exec dbExecutor, session *appSession, action, entityType string, entityID interface{}, before, after auditValues) error {
+	var actorID sql.NullInt64
+	var actorName, actorRole string
+	if session != nil {
+		actorID = sql.NullInt64{Int64: int64(session.UserID), Valid: true}
+		actorName = session.Username
+		actorRole = session.Role
+	}
+
+	beforeJSON, err := encodeAuditValues(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := encodeAuditValues(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO audit_events
+			(occurred_at, actor_user_id, actor_name, actor_role, station, action, entity_type, entity_id, before_value, after_value)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.now(), actorID, actorName, actorRole, a.currentStationLabel(), action, entityType,
+		fmt.Sprint(entityID), beforeJSON, afterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event %s: %w", action, err)
+	}
+	return nil
+}
+
+// audit records an action that has already been committed. A failure is logged rather
+// than returned, since the action itself has succeeded.
+func (a *App) audit(session *appSession, action, entityType string, entityID interface{}, before, after auditValues) {
+	if a.db == nil {
+		return
+	}
+	if err := a.recordAuditEvent(a.db, session, action, entityType, entityID, before, after); err != nil {
+		log.Printf("AUDIT ERROR: %v", err)
+	}
+}
+
+func encodeAuditValues(values auditValues) (sql.NullString, error) {
+	if values == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode audit values: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// ==============================================================================
+// AUDIT QUERIES AND EXPORTS
+// ==============================================================================
+
+// GetAuditEvents returns recorded actions matching filter, newest first.
+func (a *App) GetAuditEvents(filter AuditEventFilter) ([]AuditEvent, error) {
+	if _, err := a.requireRole("GetAuditEvents"); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	return a.queryAuditEvents(filter)
+}
+
+func (a *App) queryAuditEvents(filter AuditEventFilter) ([]AuditEvent, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+
+	if filter.ActorUserID > 0 {
+		where = append(where, "actor_user_id = ?")
+		args = append(args, filter.ActorUserID)
+	}
+	if entityType := strings.TrimSpace(filter.EntityType); entityType != "" {
+		where = append(where, "entity_type = ?")
+		args = append(args, entityType)
+	}
+	if entityID := strings.TrimSpace(filter.EntityID); entityID != "" {
+		where = append(where, "entity_id = ?")
+		args = append(args, entityID)
+	}
+	if start := strings.TrimSpace(filter.StartDate); start != "" {
+		startDate, err := time.ParseInLocation("2006-01-02", start, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start date: %s", start)
+		}
+		where = append(where, "occurred_at >= ?")
+		args = append(args, startDate)
+	}
+	if end := strings.TrimSpace(filter.EndDate); end != "" {
+		endDate, err := time.ParseInLocation("2006-01-02", end, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end date: %s", end)
+		}
+		where = append(where, "occurred_at < ?")
+		args = append(args, endDate.AddDate(0, 0, 1))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = auditDefaultLimit
+	}
+	if limit > auditMaxLimit {
+		limit = auditMaxLimit
+	}
+	args = append(args, limit)
+
+	rows, err := a.db.Query(`
+		SELECT id, DATE_FORMAT(occurred_at, '%Y-%m-%d %H:%i:%s'), actor_user_id, actor_name, actor_role,
+		       station, action, entity_type, entity_id, before_value, after_value
+		FROM audit_events
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var ev AuditEvent
+		var actorID sql.NullInt64
+		var before, after sql.NullString
+		if err := rows.Scan(&ev.ID, &ev.OccurredAt, &actorID, &ev.ActorName, &ev.ActorRole,
+			&ev.Station, &ev.Action, &ev.EntityType, &ev.EntityID, &before, &after); err != nil {
+			return nil, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			ev.ActorUserID = &id
+		}
+		ev.Before = scanNullString(before)
+		ev.After = scanNullString(after)
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+func describeAuditFilter(filter AuditEventFilter) string {
+	var parts []string
+	switch {
+	case filter.StartDate != "" && filter.EndDate != "":
+		parts = append(parts, fmt.Sprintf("Date Range: %s to %s", filter.StartDate, filter.EndDate))
+	case filter.StartDate != "":
+		parts = append(parts, fmt.Sprintf("From %s", filter.StartDate))
+	case filter.EndDate != "":
+		parts = append(parts, fmt.Sprintf("Until %s", filter.EndDate))
+	}
+	if filter.ActorUserID > 0 {
+		parts = append(parts, "Actor ID: "+strconv.Itoa(filter.ActorUserID))
+	}
+	if filter.EntityType != "" {
+		entity := filter.EntityType
+		if filter.EntityID != "" {
+			entity += " " + filter.EntityID
+		}
+		parts = append(parts, "Entity: "+entity)
+	}
+	if len(parts) == 0 {
+		return "All recorded actions"
+	}
+	return strings.Join(parts, " | ")
+}
+
+func buildAuditExportDocument(filter AuditEventFilter, events []AuditEvent) printableExportDocument {
+	rows := make([][]string, 0, len(events))
+	for _, ev := range events {
+		rows = append(rows, []string{
+			ev.OccurredAt,
+			ev.ActorName,
+			ev.ActorRole,
+			ev.Station,
+			ev.Action,
+			strings.TrimSpace(ev.EntityType + " " + ev.EntityID),
+			auditValueText(ev.Before),
+			auditValueText(ev.After),
+		})
+	}
+
+	return printableExportDocument{
+		Title:            "Audit Trail",
+		Subtitle:         describeAuditFilter(filter),
+		Headers:          []string{"Time", "Actor", "Role", "Station", "Action", "Entity", "Before", "After"},
+		Rows:             rows,
+		ColumnWidths:     []float64{34, 28, 24, 24, 36, 34, 48, 49},
+		ColumnAlignments: []string{"L", "L", "L", "L", "L", "L", "L", "L"},
+		Orientation:      "L",
+		GeneratedAt:      time.Now(),
+	}
+}
+
+func auditValueText(values *string) string {
+	if values == nil {
+		return ""
+	}
+	return *values
+}
+
+// ExportAuditEventsCSV exports audit events matching filter to CSV.
+// If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
+func (a *App) ExportAuditEventsCSV(filter AuditEventFilter, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportAuditEventsCSV"); err != nil {
+		return "", err
+	}
+	if err := a.checkDB(); err != nil {
+		return "", err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = auditMaxLimit
+	}
+	events, err := a.queryAuditEvents(filter)
+	if err != nil {
+		return "", err
+	}
+
+	defaultName := fmt.Sprintf("audit_trail_%s.csv", time.Now().Format("20060102_150405"))
+	filename := resolveExportPath(savePath, defaultName)
+	if err := writePrintableCSV(filename, buildAuditExportDocument(filter, events)); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// ExportAuditEventsPDF exports audit events matching filter to PDF.
+// If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
+func (a *App) ExportAuditEventsPDF(filter AuditEventFilter, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportAuditEventsPDF"); err != nil {
+		return "", err
+	}
+	if err := a.checkDB(); err != nil {
+		return "", err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = auditMaxLimit
+	}
+	events, err := a.queryAuditEvents(filter)
+	if err != nil {
+		return "", err
+	}
+
+	defaultName := fmt.Sprintf("audit_trail_%s.pdf", time.Now().Format("20060102_150405"))
+	filename := resolveExportPath(savePath, defaultName)
+	if err := writePrintablePDF(filename, buildAuditExportDocument(filter, events)); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
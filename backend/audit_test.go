@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminActionsAreAudited(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	e.loginAs("A-0001")
+
+	if err := e.app.CreateDepartment("CCS", "Computer Studies", ""); err != nil {
+		t.Fatalf("CreateDepartment: %v", err)
+	}
+	if err := e.app.UpdateDepartment("CCS", "CCS", "College of Computer Studies", "", false, false); err != nil {
+		t.Fatalf("UpdateDepartment: %v", err)
+	}
+	if err := e.app.DeleteDepartment("CCS"); err != nil {
+		t.Fatalf("DeleteDepartment: %v", err)
+	}
+	e.clock.Advance(24 * time.Hour)
+	if err := e.app.ResetPasswordByRole(adminID, studentID, "N3w!Passw0rd"); err != nil {
+		t.Fatalf("ResetPasswordByRole: %v", err)
+	}
+
+	events, err := e.app.GetAuditEvents(AuditEventFilter{EntityType: "department", EntityID: "CCS"})
+	if err != nil {
+		t.Fatalf("GetAuditEvents: %v", err)
+	}
+	var actions []string
+	for _, ev := range events {
+		actions = append(actions, ev.Action)
+	}
+	if got := strings.Join(actions, ","); got != "delete_department,update_department,create_department" {
+		t.Fatalf("department audit actions = %s", got)
+	}
+	update := events[1]
+	if update.ActorUserID == nil || *update.ActorUserID != adminID || update.ActorRole != "admin" || update.ActorName != "A-0001" {
+		t.Errorf("update event actor = %v/%s/%s, want the logged-in admin", update.ActorUserID, update.ActorName, update.ActorRole)
+	}
+	if update.Before == nil || !strings.Contains(*update.Before, `"department_name":"Computer Studies"`) ||
+		update.After == nil || !strings.Contains(*update.After, `"department_name":"College of Computer Studies"`) {
+		t.Errorf("update event values = %v -> %v", update.Before, update.After)
+	}
+
+	today, err := e.app.GetAuditEvents(AuditEventFilter{ActorUserID: adminID, StartDate: e.clock.Today(), EndDate: e.clock.Today()})
+	if err != nil {
+		t.Fatalf("GetAuditEvents by date: %v", err)
+	}
+	if len(today) != 1 || today[0].Action != "reset_password" || today[0].EntityID != strconv.Itoa(studentID) {
+		t.Fatalf("events for %s = %+v, want only the password reset", e.clock.Today(), today)
+	}
+	if today[0].After == nil || strings.Contains(*today[0].After, "N3w!Passw0rd") {
+		t.Errorf("password reset event = %v, want details without the password", today[0].After)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.csv")
+	if _, err := e.app.ExportAuditEventsCSV(AuditEventFilter{}, path); err != nil {
+		t.Fatalf("ExportAuditEventsCSV: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	if !strings.Contains(string(data), "delete_department") || !strings.Contains(string(data), "reset_password") {
+		t.Errorf("CSV export is missing audit rows:\n%s", data)
+	}
+	if _, err := e.app.ExportAuditEventsPDF(AuditEventFilter{}, filepath.Join(t.TempDir(), "audit.pdf")); err != nil {
+		t.Fatalf("ExportAuditEventsPDF: %v", err)
+	}
+}
+
+func TestAuditEventsRequireAdmin(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.loginAs("T-0001")
+
+	if _, err := e.app.GetAuditEvents(AuditEventFilter{}); err == nil {
+		t.Fatalf("a teacher was able to read the audit trail")
+	}
+}
@@ -2,6 +2,7 @@ package backend
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -16,43 +17,72 @@ import (
 
 // Login authenticates a user
 func (a *App) Login(username, password string) (*User, error) {
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
 	if err := a.checkDB(); err != nil {
 		log.Printf("LOGIN ERROR: Database not connected")
+		// Students who logged in on this PC recently can still log in from the offline cache.
+		if user, offlineErr := a.offlineLogin(username, password); offlineErr != errNoOfflineCredential {
+			return user, offlineErr
+		}
 		return nil, fmt.Errorf("database connection failed - please check database configuration")
 	}
 
-	if err := ValidateUsername(username); err != nil {
+	// Use configured station identity instead of machine hostname.
+	stationLabel := a.currentStationLabel()
+
+	if err := a.checkLoginThrottle(username, stationLabel); err != nil {
+		log.Printf("LOGIN ERROR: Attempt for user '%s' on '%s' throttled: %v", username, stationLabel, err)
 		return nil, err
 	}
-	if err := ValidatePassword(password); err != nil {
+
+	// Check the password with the local account or the school directory. A first
+	// directory login creates or links the local account.
+	identity, err := a.authenticateCredentials(username, password)
+	if errors.Is(err, errInvalidCredentials) {
+		log.Printf("LOGIN ERROR: Invalid credentials for user '%s'", username)
+		a.recordLoginFailure(username, stationLabel)
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	if err != nil {
+		log.Printf("LOGIN ERROR: Authentication failed for user '%s': %v", username, err)
 		return nil, err
 	}
+	if identity.Source == authSourceLDAP {
+		if err := a.provisionDirectoryUser(username, identity); err != nil {
+			return nil, err
+		}
+	}
 
 	var user User
 	var accountStatus string
 	var createdAt time.Time
 	var storedPassword string
+	var mustChangePassword bool
+	var passwordChangedAt sql.NullTime
 
-	query := `SELECT id, username, password, user_type, account_status, created_at FROM users WHERE username = ?`
-	err := a.db.QueryRow(query, username).Scan(&user.ID, &user.Name, &storedPassword, &user.Role, &accountStatus, &createdAt)
+	query := `SELECT id, username, password, user_type, account_status, created_at, must_change_password, password_changed_at, auth_source FROM users WHERE username = ?`
+	err = a.db.QueryRow(query, username).Scan(&user.ID, &user.Name, &storedPassword, &user.Role, &accountStatus, &createdAt,
+		&mustChangePassword, &passwordChangedAt, &user.AuthSource)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Printf("LOGIN ERROR: User '%s' not found", username)
+			a.recordLoginFailure(username, stationLabel)
 			return nil, fmt.Errorf("invalid credentials")
 		}
 		log.Printf("LOGIN ERROR: Database query failed for user '%s': %v", username, err)
 		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password)); err != nil {
-		log.Printf("LOGIN ERROR: Password verification failed for user '%s'", username)
-		return nil, fmt.Errorf("invalid credentials")
-	}
-
 	// Enforce 4-year validity for student accounts (including working students)
 	if user.Role == "student" || user.Role == "working_student" {
 		expiryDate := createdAt.AddDate(4, 0, 0)
-		if time.Now().After(expiryDate) {
+		if a.now().After(expiryDate) {
 			if err := a.deleteUserByID(user.ID); err != nil {
 				log.Printf("Failed to auto-delete expired student account %d: %v", user.ID, err)
 				return nil, fmt.Errorf("student account has expired and could not be removed automatically. Please contact your administrator.")
@@ -77,24 +107,42 @@ func (a *App) Login(username, password string) (*User, error) {
 	// Do not expose password hash or plaintext to the frontend
 	user.Password = ""
 
+	// The frontend keeps the user on the change-password form while either flag is set.
+	// Directory passwords follow the directory's own policy.
+	if user.AuthSource == authSourceLocal {
+		user.PasswordExpired = a.passwordExpired(passwordChangedAt, createdAt)
+		user.MustChangePassword = mustChangePassword || user.PasswordExpired
+	}
+
 	// Load role-specific profile
 	if err := a.loadUserProfile(&user); err != nil {
 		log.Printf("Failed to load user profile: %v", err)
 	}
 
-	// Use configured station identity instead of machine hostname.
-	stationLabel := a.currentStationLabel()
+	if err := a.checkConcurrentLogin(&user, stationLabel); err != nil {
+		return nil, err
+	}
 
-	// End any still-open sessions for this user (e.g. previous PC shutdown without logout).
+	// Admins and teachers with two-factor authentication finish in CompleteTwoFactorLogin.
+	if challenge, err := a.startTwoFactorChallenge(&user, username, storedPassword, stationLabel); err != nil {
+		return nil, err
+	} else if challenge != nil {
+		return challenge, nil
+	}
+
+	return a.completeLogin(user, username, storedPassword, stationLabel)
+}
+
+// completeLogin records the login and starts the session for a user whose credentials
+// have been verified.
+func (a *App) completeLogin(user User, username, storedPassword, stationLabel string) (*User, error) {
+	a.clearLoginFailures(username, stationLabel)
+
+	// End still-open sessions for this user (e.g. previous PC shutdown without logout).
 	// Otherwise the old row stays logout_time NULL forever because the new login refreshes
 	// the same user heartbeat and closeStaleSessions will not touch the orphaned row.
-	if _, err := a.db.Exec(`
-		UPDATE log_entries
-		SET logout_time = NOW()
-		WHERE user_id = ? AND logout_time IS NULL
-	`, user.ID); err != nil {
-		log.Printf("Failed to close prior open login logs for user %d: %v", user.ID, err)
-	}
+	// Sessions on stations that are still active follow the concurrent-login policy.
+	a.closePriorLogins(&user, stationLabel)
 
 	// Create login log entry
 	logID, err := a.createLoginLog(user.ID, stationLabel)
@@ -105,10 +153,19 @@ func (a *App) Login(username, password string) (*User, error) {
 		log.Printf("Login logged - ID: %d, User: %s (ID: %d), Role: %s, PC: %s", logID, username, user.ID, user.Role, stationLabel)
 	}
 
+	token, err := a.startSession(&user)
+	if err != nil {
+		log.Printf("LOGIN ERROR: Failed to start session for user '%s': %v", username, err)
+		return nil, fmt.Errorf("failed to start session - please try again")
+	}
+	user.SessionToken = token
+
 	if err := a.TouchSession(user.ID); err != nil {
 		log.Printf("Failed to initialize session heartbeat for user %d: %v", user.ID, err)
 	}
 
+	a.cacheOfflineCredential(username, user, storedPassword)
+
 	// Attendance is now session-based (teacher opens session, student taps Time In).
 
 	log.Printf("User login successful: %s (role: %s, pc: %s)", username, user.Role, stationLabel)
@@ -117,7 +174,26 @@ func (a *App) Login(username, password string) (*User, error) {
 
 // Logout logs a user out and records logout time
 func (a *App) Logout(userID int) error {
+	session, err := a.requireActingUser("Logout", userID)
+	if err != nil {
+		return err
+	}
+	return a.logout(session, logoutReasonManual)
+}
+
+// logout closes the session's open login log with the given logout_reason and ends
+// the in-memory session.
+func (a *App) logout(session *appSession, reason string) error {
+	userID := session.UserID
+	if session.OfflineEventUID != "" {
+		a.touchOfflineSession(session.OfflineEventUID)
+	}
 	if err := a.checkDB(); err != nil {
+		if session.OfflineEventUID != "" {
+			// The offline login is replayed later with this moment as its logout time.
+			a.endSession()
+			return nil
+		}
 		return err
 	}
 
@@ -130,22 +206,23 @@ func (a *App) Logout(userID int) error {
 		SELECT id
 		FROM log_entries
 		WHERE user_id = ? AND logout_time IS NULL
-		ORDER BY login_time DESC
+		ORDER BY CASE WHEN id = ? THEN 0 ELSE 1 END, login_time DESC
 		LIMIT 1
-	`, userID).Scan(&latestOpenLogID)
+	`, userID, session.LoginLogID).Scan(&latestOpenLogID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Printf("No active login log found to update for user %d", userID)
 			if hbErr := a.clearSessionHeartbeat(userID); hbErr != nil {
 				log.Printf("Failed to clear session heartbeat for user %d: %v", userID, hbErr)
 			}
+			a.endSession()
 			return nil
 		}
 		log.Printf("Failed to find active login log for user %d: %v", userID, err)
 		return err
 	}
 
-	result, err := a.db.Exec(`UPDATE log_entries SET logout_time = NOW() WHERE id = ?`, latestOpenLogID)
+	result, err := a.db.Exec(`UPDATE log_entries SET logout_time = ?, logout_reason = ? WHERE id = ?`, a.now(), reason, latestOpenLogID)
 	if err != nil {
 		log.Printf("Failed to log logout for user %d: %v", userID, err)
 		return err
@@ -157,17 +234,27 @@ func (a *App) Logout(userID int) error {
 	} else if rowsAffected == 0 {
 		log.Printf("No active login log found to update for user %d", userID)
 	} else {
-		log.Printf("User logout successful: user_id=%d (rows affected: %d)", userID, rowsAffected)
+		log.Printf("User logout successful: user_id=%d, reason=%s (rows affected: %d)", userID, reason, rowsAffected)
+	}
+
+	// A student still logged in at another station stays timed in.
+	var openLogins int
+	if err := a.db.QueryRow(`SELECT COUNT(*) FROM log_entries WHERE user_id = ? AND logout_time IS NULL`, userID).Scan(&openLogins); err != nil {
+		log.Printf("Failed to count open logins for user %d: %v", userID, err)
+	} else if openLogins == 0 {
+		a.timeOutStudentFromOpenSessions(userID, a.now(), timeOutSourceLogout)
 	}
 
 	if err := a.clearSessionHeartbeat(userID); err != nil {
 		log.Printf("Failed to clear session heartbeat for user %d: %v", userID, err)
 	}
 
+	a.endSession()
 	return nil
 }
 
-// ChangePassword updates a user's password
+// ChangePassword updates a user's password. Checking the current password counts as a
+// login attempt for throttling.
 func (a *App) ChangePassword(username, oldPassword, newPassword string) error {
 	if err := a.checkDB(); err != nil {
 		return err
@@ -178,32 +265,41 @@ func (a *App) ChangePassword(username, oldPassword, newPassword string) error {
 	if err := ValidatePassword(oldPassword); err != nil {
 		return err
 	}
-	if err := ValidateStrongPassword(newPassword); err != nil {
+	if err := ValidatePassword(newPassword); err != nil {
 		return err
 	}
 
-	var storedPassword string
-	if err := a.db.QueryRow(`SELECT password FROM users WHERE username = ?`, username).Scan(&storedPassword); err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("user not found")
-		}
+	// Checking the current password is a login attempt: it is throttled the same way, and
+	// an unknown username fails exactly like a wrong password.
+	stationLabel := a.currentStationLabel()
+	if err := a.checkLoginThrottle(username, stationLabel); err != nil {
+		log.Printf("ChangePassword: attempt for user '%s' on '%s' throttled: %v", username, stationLabel, err)
 		return err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(oldPassword)); err != nil {
+	var userID int
+	var storedPassword string
+	err := a.db.QueryRow(`SELECT id, password FROM users WHERE username = ?`, username).Scan(&userID, &storedPassword)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows || bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(oldPassword)) != nil {
 		log.Printf("ChangePassword: current password verification failed for user %s", username)
-		return fmt.Errorf("current password is incorrect")
+		a.recordLoginFailure(username, stationLabel)
+		return fmt.Errorf("invalid username or password")
+	}
+	a.clearLoginFailures(username, stationLabel)
+	if err := a.requireLocalPassword(userID); err != nil {
+		return err
 	}
 
-	// Hash new password before saving
-	hashedNew, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("failed to hash new password: %w", err)
+	if err := a.validateNewPassword(userID, newPassword); err != nil {
+		return err
 	}
 
-	if _, err := a.db.Exec(`UPDATE users SET password = ? WHERE username = ?`, string(hashedNew), username); err != nil {
+	if err := a.setUserPassword(userID, newPassword, false); err != nil {
 		log.Printf("Failed to update password for %s: %v", username, err)
-		return fmt.Errorf("failed to update password: %w", err)
+		return err
 	}
 
 	log.Printf("Password changed successfully for user: %s", username)
@@ -216,6 +312,10 @@ func (a *App) ChangePassword(username, oldPassword, newPassword string) error {
 // - working_student can reset student passwords only
 // - admin passwords are excluded from in-app reset and require manual recovery
 func (a *App) ResetPasswordByRole(requesterUserID, targetUserID int, newPassword string) error {
+	session, err := a.requireActingUser("ResetPasswordByRole", requesterUserID)
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -263,21 +363,21 @@ func (a *App) ResetPasswordByRole(requesterUserID, targetUserID int, newPassword
 	if !isAllowed {
 		return fmt.Errorf("you are not allowed to reset this account type")
 	}
+	if err := a.requireLocalPassword(targetUserID); err != nil {
+		return err
+	}
 
 	isTemporaryIDPassword := trimmedPassword == strings.TrimSpace(targetUsername)
-	if !isTemporaryIDPassword {
-		if err := ValidateStrongPassword(trimmedPassword); err != nil {
-			return err
-		}
+	validate := a.validateNewPassword
+	if isTemporaryIDPassword {
+		validate = a.validateTemporaryIDPassword
 	}
-
-	// Hash new password before saving
-	hashedNew, err := bcrypt.GenerateFromPassword([]byte(trimmedPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("failed to hash new password: %w", err)
+	if err := validate(targetUserID, trimmedPassword); err != nil {
+		return err
 	}
 
-	if _, err := a.db.Exec(`UPDATE users SET password = ? WHERE id = ?`, string(hashedNew), targetUserID); err != nil {
+	// The user has to replace an admin-chosen password at their next login.
+	if err := a.setUserPassword(targetUserID, trimmedPassword, true); err != nil {
 		log.Printf("Failed password reset requester=%d target=%d: %v", requesterUserID, targetUserID, err)
 		return fmt.Errorf("failed to reset password: %w", err)
 	}
@@ -287,6 +387,8 @@ func (a *App) ResetPasswordByRole(requesterUserID, targetUserID int, newPassword
 	} else {
 		log.Printf("Password reset successful requester=%d (%s) target=%d (%s)", requesterUserID, requesterRole, targetUserID, targetRole)
 	}
+	a.audit(session, "reset_password", "user", targetUserID, nil,
+		auditValues{"username": targetUsername, "role": targetRole, "temporary_id_password": isTemporaryIDPassword})
 	return nil
 }
 
@@ -348,9 +450,10 @@ func (a *App) loadUserProfile(user *User) error {
 
 // createLoginLog creates a login log entry and returns the log ID
 func (a *App) createLoginLog(userID int, pcNumber string) (int, error) {
+	now := a.now()
 	result, err := a.db.Exec(
-		`INSERT INTO log_entries (user_id, pc_number, login_time) VALUES (?, ?, NOW())`,
-		userID, pcNumber,
+		`INSERT INTO log_entries (user_id, pc_number, login_time, last_seen_at) VALUES (?, ?, ?, ?)`,
+		userID, pcNumber, now, now,
 	)
 	if err != nil {
 		return 0, err
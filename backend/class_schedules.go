@@ -0,0 +1,492 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// RECURRING CLASS SCHEDULES
+// ==============================================================================
+//
+// A class's structured schedule is a set of weekly meetings plus an optional term
+// range and holiday list. classes.schedule is kept as a readable summary of the
+// meetings so existing screens and exports keep working.
+
+const (
+	attendanceSchedulerIntervalSeconds = 60
+	defaultMeetingGracePeriodMinutes   = 10
+)
+
+var weekdayAbbreviations = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// ClassMeeting is one weekly meeting of a class. Weekday follows time.Weekday (0 = Sunday)
+// and times are 24-hour HH:MM.
+type ClassMeeting struct {
+	ID                 int     `json:"id"`
+	Weekday            int     `json:"weekday"`
+	StartTime          string  `json:"start_time"`
+	EndTime            string  `json:"end_time"`
+	Room               *string `json:"room,omitempty"`
+	GracePeriodMinutes int     `json:"grace_period_minutes"`
+}
+
+// ClassHoliday is a date on which no attendance session is opened for the class.
+type ClassHoliday struct {
+	Date        string  `json:"date"`
+	Description *string `json:"description,omitempty"`
+}
+
+// ClassSchedule is the structured, recurring schedule of a class.
+type ClassSchedule struct {
+	ClassID       int            `json:"class_id"`
+	Meetings      []ClassMeeting `json:"meetings"`
+	TermStartDate *string        `json:"term_start_date,omitempty"`
+	TermEndDate   *string        `json:"term_end_date,omitempty"`
+	Holidays      []ClassHoliday `json:"holidays"`
+}
+
+func parseMeetingClock(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid meeting time %q: use HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func normalizeClassSchedule(schedule ClassSchedule) (ClassSchedule, error) {
+	normalized := ClassSchedule{ClassID: schedule.ClassID, Meetings: []ClassMeeting{}, Holidays: []ClassHoliday{}}
+
+	for _, meeting := range schedule.Meetings {
+		if meeting.Weekday < 0 || meeting.Weekday > 6 {
+			return normalized, fmt.Errorf("invalid weekday %d: use 0 (Sunday) to 6 (Saturday)", meeting.Weekday)
+		}
+		start, err := parseMeetingClock(meeting.StartTime)
+		if err != nil {
+			return normalized, err
+		}
+		end, err := parseMeetingClock(meeting.EndTime)
+		if err != nil {
+			return normalized, err
+		}
+		if end <= start {
+			return normalized, fmt.Errorf("meeting on %s must end after it starts", weekdayAbbreviations[meeting.Weekday])
+		}
+		if meeting.GracePeriodMinutes < 0 || time.Duration(meeting.GracePeriodMinutes)*time.Minute >= end-start {
+			return normalized, fmt.Errorf("grace period must be shorter than the meeting")
+		}
+		var room *string
+		if meeting.Room != nil && strings.TrimSpace(*meeting.Room) != "" {
+			trimmed := strings.TrimSpace(*meeting.Room)
+			room = &trimmed
+		}
+		normalized.Meetings = append(normalized.Meetings, ClassMeeting{
+			Weekday:            meeting.Weekday,
+			StartTime:          formatMeetingClock(start),
+			EndTime:            formatMeetingClock(end),
+			Room:               room,
+			GracePeriodMinutes: meeting.GracePeriodMinutes,
+		})
+	}
+	sort.SliceStable(normalized.Meetings, func(i, j int) bool {
+		if normalized.Meetings[i].Weekday != normalized.Meetings[j].Weekday {
+			return normalized.Meetings[i].Weekday < normalized.Meetings[j].Weekday
+		}
+		return normalized.Meetings[i].StartTime < normalized.Meetings[j].StartTime
+	})
+
+	for _, field := range []struct {
+		value *string
+		dest  **string
+	}{{schedule.TermStartDate, &normalized.TermStartDate}, {schedule.TermEndDate, &normalized.TermEndDate}} {
+		if field.value == nil || strings.TrimSpace(*field.value) == "" {
+			continue
+		}
+		date := strings.TrimSpace(*field.value)
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return normalized, fmt.Errorf("invalid term date: %s", date)
+		}
+		*field.dest = &date
+	}
+	if normalized.TermStartDate != nil && normalized.TermEndDate != nil && *normalized.TermEndDate < *normalized.TermStartDate {
+		return normalized, fmt.Errorf("term end date is before the term start date")
+	}
+
+	seen := make(map[string]bool)
+	for _, holiday := range schedule.Holidays {
+		date := strings.TrimSpace(holiday.Date)
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return normalized, fmt.Errorf("invalid holiday date: %s", holiday.Date)
+		}
+		if seen[date] {
+			continue
+		}
+		seen[date] = true
+		var description *string
+		if holiday.Description != nil && strings.TrimSpace(*holiday.Description) != "" {
+			trimmed := strings.TrimSpace(*holiday.Description)
+			description = &trimmed
+		}
+		normalized.Holidays = append(normalized.Holidays, ClassHoliday{Date: date, Description: description})
+	}
+	sort.Slice(normalized.Holidays, func(i, j int) bool { return normalized.Holidays[i].Date < normalized.Holidays[j].Date })
+
+	return normalized, nil
+}
+
+func formatMeetingClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+// summarizeClassMeetings renders meetings the way schedules were typed by hand,
+// e.g. "Mon/Wed 08:00-09:30, Fri 13:00-16:00".
+func summarizeClassMeetings(meetings []ClassMeeting) string {
+	var order []string
+	days := make(map[string][]string)
+	for _, meeting := range meetings {
+		slot := meeting.StartTime + "-" + meeting.EndTime
+		if _, ok := days[slot]; !ok {
+			order = append(order, slot)
+		}
+		days[slot] = append(days[slot], weekdayAbbreviations[meeting.Weekday])
+	}
+
+	parts := make([]string, 0, len(order))
+	for _, slot := range order {
+		parts = append(parts, strings.Join(days[slot], "/")+" "+slot)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (a *App) loadClassSchedule(classID int) (*ClassSchedule, error) {
+	schedule := &ClassSchedule{ClassID: classID, Meetings: []ClassMeeting{}, Holidays: []ClassHoliday{}}
+
+	var termStart, termEnd sql.NullString
+	err := a.db.QueryRow(`
+		SELECT DATE_FORMAT(term_start_date, '%Y-%m-%d'), DATE_FORMAT(term_end_date, '%Y-%m-%d')
+		FROM classes
+		WHERE class_id = ?
+	`, classID).Scan(&termStart, &termEnd)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("class not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	schedule.TermStartDate = scanNullString(termStart)
+	schedule.TermEndDate = scanNullString(termEnd)
+
+	rows, err := a.db.Query(`
+		SELECT id, weekday, start_time, end_time, room, grace_period_minutes
+		FROM class_meetings
+		WHERE class_id = ?
+		ORDER BY weekday, start_time
+	`, classID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class meetings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var meeting ClassMeeting
+		var room sql.NullString
+		if err := rows.Scan(&meeting.ID, &meeting.Weekday, &meeting.StartTime, &meeting.EndTime, &room, &meeting.GracePeriodMinutes); err != nil {
+			return nil, err
+		}
+		meeting.Room = scanNullString(room)
+		schedule.Meetings = append(schedule.Meetings, meeting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	holidayRows, err := a.db.Query(`
+		SELECT DATE_FORMAT(holiday_date, '%Y-%m-%d'), description
+		FROM class_holidays
+		WHERE class_id = ?
+		ORDER BY holiday_date
+	`, classID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class holidays: %w", err)
+	}
+	defer holidayRows.Close()
+	for holidayRows.Next() {
+		var holiday ClassHoliday
+		var description sql.NullString
+		if err := holidayRows.Scan(&holiday.Date, &description); err != nil {
+			return nil, err
+		}
+		holiday.Description = scanNullString(description)
+		schedule.Holidays = append(schedule.Holidays, holiday)
+	}
+	return schedule, holidayRows.Err()
+}
+
+// GetClassSchedule returns the structured schedule of a class.
+func (a *App) GetClassSchedule(classID int) (*ClassSchedule, error) {
+	session, err := a.requireRole("GetClassSchedule")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	return a.loadClassSchedule(classID)
+}
+
+// SaveClassSchedule replaces the meetings, term dates and holidays of a class owned by
+// the teacher. When meetings are given, classes.schedule is rewritten as their summary.
+func (a *App) SaveClassSchedule(classID int, teacherUserID int, schedule ClassSchedule) error {
+	session, err := a.requireActingUser("SaveClassSchedule", teacherUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+
+	normalized, err := normalizeClassSchedule(schedule)
+	if err != nil {
+		return err
+	}
+	normalized.ClassID = classID
+
+	var owned int
+	if err := a.db.QueryRow(`SELECT COUNT(*) FROM classes WHERE class_id = ? AND teacher_id = ?`, classID, teacherUserID).Scan(&owned); err != nil {
+		return err
+	}
+	if owned == 0 {
+		return fmt.Errorf("class not found or not authorized")
+	}
+	previous, err := a.loadClassSchedule(classID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var termStart, termEnd interface{}
+	if normalized.TermStartDate != nil {
+		termStart = *normalized.TermStartDate
+	}
+	if normalized.TermEndDate != nil {
+		termEnd = *normalized.TermEndDate
+	}
+	if _, err := tx.Exec(`
+		UPDATE classes
+		SET term_start_date = ?, term_end_date = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE class_id = ?
+	`, termStart, termEnd, classID); err != nil {
+		return fmt.Errorf("failed to update term dates: %w", err)
+	}
+	if len(normalized.Meetings) > 0 {
+		if _, err := tx.Exec(`UPDATE classes SET schedule = ? WHERE class_id = ?`, summarizeClassMeetings(normalized.Meetings), classID); err != nil {
+			return fmt.Errorf("failed to update schedule summary: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM class_meetings WHERE class_id = ?`, classID); err != nil {
+		return fmt.Errorf("failed to clear class meetings: %w", err)
+	}
+	for _, meeting := range normalized.Meetings {
+		var room interface{}
+		if meeting.Room != nil {
+			room = *meeting.Room
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO class_meetings (class_id, weekday, start_time, end_time, room, grace_period_minutes)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, classID, meeting.Weekday, meeting.StartTime, meeting.EndTime, room, meeting.GracePeriodMinutes); err != nil {
+			return fmt.Errorf("failed to save class meeting: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM class_holidays WHERE class_id = ?`, classID); err != nil {
+		return fmt.Errorf("failed to clear class holidays: %w", err)
+	}
+	for _, holiday := range normalized.Holidays {
+		var description interface{}
+		if holiday.Description != nil {
+			description = *holiday.Description
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO class_holidays (class_id, holiday_date, description)
+			VALUES (?, ?, ?)
+		`, classID, holiday.Date, description); err != nil {
+			return fmt.Errorf("failed to save class holiday: %w", err)
+		}
+	}
+
+	if err := a.recordAuditEvent(tx, session, "update_class_schedule", "class", classID,
+		classScheduleAuditValues(previous), classScheduleAuditValues(&normalized)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit class schedule: %w", err)
+	}
+
+	log.Printf("Class schedule saved: class_id=%d, meetings=%d, holidays=%d", classID, len(normalized.Meetings), len(normalized.Holidays))
+	return nil
+}
+
+func classScheduleAuditValues(schedule *ClassSchedule) auditValues {
+	holidays := make([]string, 0, len(schedule.Holidays))
+	for _, holiday := range schedule.Holidays {
+		holidays = append(holidays, holiday.Date)
+	}
+	return auditValues{
+		"meetings":        summarizeClassMeetings(schedule.Meetings),
+		"term_start_date": schedule.TermStartDate,
+		"term_end_date":   schedule.TermEndDate,
+		"holidays":        holidays,
+	}
+}
+
+// ==============================================================================
+// SCHEDULED ATTENDANCE SESSIONS
+// ==============================================================================
+//
+// Every running station opens attendance sessions for meetings that are under way and
+// closes sessions whose scheduled duration is over. A scheduled session is opened as of
+// the meeting's start time, so lateness is measured from the schedule even when no
+// station was running at the exact start.
+
+func (a *App) startAttendanceSchedulerLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(attendanceSchedulerIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if a.checkDB() != nil {
+				continue
+			}
+			if err := a.runAttendanceScheduler(); err != nil {
+				log.Printf("Background attendance scheduler failed: %v", err)
+			}
+		}
+	}
+}
+
+// runAttendanceScheduler closes expired sessions and opens sessions for due meetings.
+func (a *App) runAttendanceScheduler() error {
+	if err := a.closeExpiredAttendanceSessions(); err != nil {
+		return fmt.Errorf("failed to close expired attendance sessions: %w", err)
+	}
+	_, err := a.openDueClassMeetings(0)
+	return err
+}
+
+// openDueClassMeetings opens an attendance session for every meeting in progress that
+// does not have one yet, limited to classID when it is non-zero. It returns the number
+// of sessions opened.
+func (a *App) openDueClassMeetings(classID int) (int, error) {
+	now := a.now()
+	today := now.Format("2006-01-02")
+
+	query := `
+		SELECT m.id, m.class_id, m.start_time, m.end_time, m.grace_period_minutes, c.teacher_id
+		FROM class_meetings m
+		JOIN classes c ON m.class_id = c.class_id
+		WHERE m.weekday = ?
+			AND c.is_active = 1
+			AND COALESCE(c.is_archived, 0) = 0
+			AND (c.term_start_date IS NULL OR c.term_start_date <= ?)
+			AND (c.term_end_date IS NULL OR c.term_end_date >= ?)
+			AND NOT EXISTS (
+				SELECT 1 FROM class_holidays h
+				WHERE h.class_id = m.class_id AND h.holiday_date = ?
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM attendance_sessions s
+				WHERE s.class_id = m.class_id
+					AND s.attendance_date = ?
+					AND (s.meeting_id = m.id OR (s.status = 'open' AND COALESCE(s.is_archived, 0) = 0))
+			)
+	`
+	args := []interface{}{int(now.Weekday()), today, today, today, today}
+	if classID > 0 {
+		query += ` AND m.class_id = ?`
+		args = append(args, classID)
+	}
+
+	type dueMeeting struct {
+		id, classID, gracePeriod, teacherID int
+		start, end                          time.Time
+	}
+	rows, err := a.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find scheduled meetings: %w", err)
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var due []dueMeeting
+	for rows.Next() {
+		var meeting dueMeeting
+		var startClock, endClock string
+		if err := rows.Scan(&meeting.id, &meeting.classID, &startClock, &endClock, &meeting.gracePeriod, &meeting.teacherID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		start, startErr := parseMeetingClock(startClock)
+		end, endErr := parseMeetingClock(endClock)
+		if startErr != nil || endErr != nil {
+			log.Printf("Skipping class meeting %d with invalid times %q-%q", meeting.id, startClock, endClock)
+			continue
+		}
+		meeting.start = midnight.Add(start)
+		meeting.end = midnight.Add(end)
+		if now.Before(meeting.start) || !now.Before(meeting.end) {
+			continue
+		}
+		due = append(due, meeting)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	opened := 0
+	for _, meeting := range due {
+		sessionName := fmt.Sprintf("Attendance %s %s", today, meeting.start.Format("15:04"))
+		result, err := a.db.Exec(`
+			INSERT INTO attendance_sessions
+			(class_id, attendance_date, session_name, status, class_duration_minutes, grace_period_minutes, opened_at, code_rotation_seconds, meeting_id, created_by_user_id, created_at, updated_at)
+			VALUES (?, ?, ?, 'open', ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		`, meeting.classID, today, sessionName, int(meeting.end.Sub(meeting.start).Minutes()), meeting.gracePeriod,
+			meeting.start, loadTimeInCodeSeconds(), meeting.id, meeting.teacherID)
+		if err != nil {
+			// Another station may have opened the same meeting first; the unique index rejects the duplicate.
+			log.Printf("Scheduled attendance session not opened for class %d meeting %d: %v", meeting.classID, meeting.id, err)
+			continue
+		}
+		sessionID64, err := result.LastInsertId()
+		if err != nil {
+			log.Printf("Failed to fetch scheduled session id: %v", err)
+			continue
+		}
+		sessionID := int(sessionID64)
+		if err := a.ensureAttendanceRowsForSession(sessionID, meeting.classID, today); err != nil {
+			log.Printf("Failed to prepare attendance rows for scheduled session %d: %v", sessionID, err)
+			continue
+		}
+
+		opened++
+		log.Printf("Opened scheduled attendance session %d for class %d (%s-%s)", sessionID, meeting.classID,
+			meeting.start.Format("15:04"), meeting.end.Format("15:04"))
+		go a.notifyAttendanceSessionOpened(meeting.classID, sessionID, sessionName)
+	}
+	return opened, nil
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// advanceToNext moves the clock forward to the next hh:mm.
+func (e *testEnv) advanceToNext(hour, minute int) {
+	now := e.clock.Now()
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	e.clock.Advance(next.Sub(now))
+}
+
+func scheduledSessionCount(e *testEnv, classID int) int {
+	e.t.Helper()
+	return e.queryInt(`SELECT COUNT(*) FROM attendance_sessions WHERE class_id = ? AND meeting_id IS NOT NULL`, classID)
+}
+
+func TestScheduledMeetingsOpenAndCloseSessions(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	classID := e.seedClass(teacherID, "IT201", studentID)
+
+	e.advanceToNext(7, 0)
+	today := e.clock.Now()
+	nextWeek := today.AddDate(0, 0, 7).Format("2006-01-02")
+	termEnd := today.AddDate(0, 0, 20).Format("2006-01-02")
+	holidayNote := "Foundation day"
+
+	e.loginAs("T-0001")
+	err := e.app.SaveClassSchedule(classID, teacherID, ClassSchedule{
+		Meetings:    []ClassMeeting{{Weekday: int(today.Weekday()), StartTime: "8:00", EndTime: "09:30", GracePeriodMinutes: 15}},
+		TermEndDate: &termEnd,
+		Holidays:    []ClassHoliday{{Date: nextWeek, Description: &holidayNote}},
+	})
+	if err != nil {
+		t.Fatalf("SaveClassSchedule: %v", err)
+	}
+	class, err := e.app.GetClassByID(classID)
+	if err != nil {
+		t.Fatalf("GetClassByID: %v", err)
+	}
+	if class.Schedule == nil || *class.Schedule != weekdayAbbreviations[today.Weekday()]+" 08:00-09:30" {
+		t.Errorf("schedule summary = %v", class.Schedule)
+	}
+	if class.StructuredSchedule == nil || len(class.StructuredSchedule.Meetings) != 1 || len(class.StructuredSchedule.Holidays) != 1 {
+		t.Fatalf("structured schedule = %+v", class.StructuredSchedule)
+	}
+
+	if err := e.app.runAttendanceScheduler(); err != nil {
+		t.Fatalf("runAttendanceScheduler before the meeting: %v", err)
+	}
+	if got := scheduledSessionCount(e, classID); got != 0 {
+		t.Fatalf("sessions before the meeting = %d, want 0", got)
+	}
+
+	// The first station to run after the start opens the session as of 08:00.
+	e.clock.Advance(80 * time.Minute)
+	if err := e.app.runAttendanceScheduler(); err != nil {
+		t.Fatalf("runAttendanceScheduler during the meeting: %v", err)
+	}
+	if err := e.app.runAttendanceScheduler(); err != nil {
+		t.Fatalf("second runAttendanceScheduler: %v", err)
+	}
+	if got := scheduledSessionCount(e, classID); got != 1 {
+		t.Fatalf("sessions during the meeting = %d, want exactly 1", got)
+	}
+	sessionID := e.queryInt(`SELECT session_id FROM attendance_sessions WHERE class_id = ? AND meeting_id IS NOT NULL`, classID)
+	if got := e.queryString(`SELECT DATE_FORMAT(opened_at, '%H:%i') FROM attendance_sessions WHERE session_id = ?`, sessionID); got != "08:00" {
+		t.Errorf("scheduled session opened at %s, want 08:00", got)
+	}
+	if got := e.queryInt(`SELECT class_duration_minutes FROM attendance_sessions WHERE session_id = ?`, sessionID); got != 90 {
+		t.Errorf("scheduled session duration = %d, want 90", got)
+	}
+
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(sessionID, studentID, e.timeInCode(sessionID)); err != nil {
+		t.Fatalf("StudentTimeIn: %v", err)
+	}
+	if got := attendanceStatus(e, sessionID, studentID); got != "late" {
+		t.Errorf("time-in 20 minutes after the scheduled start = %q, want late", got)
+	}
+
+	e.clock.Advance(71 * time.Minute)
+	if err := e.app.runAttendanceScheduler(); err != nil {
+		t.Fatalf("runAttendanceScheduler after the meeting: %v", err)
+	}
+	if got := e.queryString(`SELECT status FROM attendance_sessions WHERE session_id = ?`, sessionID); got != "closed" {
+		t.Errorf("session after the scheduled end = %q, want closed", got)
+	}
+
+	// The holiday next week and the week after the term end are skipped.
+	for _, week := range []struct {
+		days int
+		want int
+	}{{7, 1}, {14, 2}, {21, 2}} {
+		meetingTime := time.Date(today.Year(), today.Month(), today.Day()+week.days, 8, 10, 0, 0, today.Location())
+		e.clock.Advance(meetingTime.Sub(e.clock.Now()))
+		if err := e.app.runAttendanceScheduler(); err != nil {
+			t.Fatalf("runAttendanceScheduler %d days later: %v", week.days, err)
+		}
+		if got := scheduledSessionCount(e, classID); got != week.want {
+			t.Errorf("scheduled sessions %d days later = %d, want %d", week.days, got, week.want)
+		}
+	}
+}
+
+func TestSaveClassScheduleValidates(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	otherID := e.seedUser("teacher", "T-0002", "Omar", "Tan")
+	classID := e.seedClass(teacherID, "IT202")
+
+	e.loginAs("T-0001")
+	err := e.app.SaveClassSchedule(classID, teacherID, ClassSchedule{
+		Meetings: []ClassMeeting{{Weekday: 1, StartTime: "10:00", EndTime: "09:00"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "end after") {
+		t.Fatalf("meeting ending before it starts: err = %v", err)
+	}
+
+	e.loginAs("T-0002")
+	err = e.app.SaveClassSchedule(classID, otherID, ClassSchedule{
+		Meetings: []ClassMeeting{{Weekday: 1, StartTime: "08:00", EndTime: "09:00"}},
+	})
+	if err == nil {
+		t.Fatalf("a teacher was able to change another teacher's class schedule")
+	}
+}
@@ -55,6 +55,9 @@ func (a *App) generateUniqueJoinCode() (string, error) {
 // CreateSubject creates a new subject (or updates if exists)
 // Note: Teacher assignment is now handled at the class level, not subject level
 func (a *App) CreateSubject(code, name string, teacherUserID int, description string) error {
+	if _, err := a.requireActingUser("CreateSubject", teacherUserID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -80,6 +83,9 @@ func (a *App) CreateSubject(code, name string, teacherUserID int, description st
 
 // GetTeacherClassesByUserID returns all classes for a teacher given their user ID
 func (a *App) GetTeacherClassesByUserID(userID int) ([]CourseClass, error) {
+	if _, err := a.requireActingUser("GetTeacherClassesByUserID", userID); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -96,6 +102,9 @@ func (a *App) GetTeacherClassesByUserID(userID int) ([]CourseClass, error) {
 
 // GetTeacherClasses returns all classes for a specific teacher
 func (a *App) GetTeacherClasses(teacherID int) ([]CourseClass, error) {
+	if _, err := a.requireRole("GetTeacherClasses"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -180,10 +189,10 @@ func (a *App) GetTeacherClasses(teacherID int) ([]CourseClass, error) {
 
 // GetStudentClasses returns all classes a student is enrolled in
 func (a *App) GetStudentClasses(studentUserID int) ([]CourseClass, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GetStudentClasses", studentUserID); err != nil {
 		return nil, err
 	}
-	if err := a.ensureStudentArchivedClassesTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
 
@@ -284,6 +293,9 @@ func (a *App) GetStudentClasses(studentUserID int) ([]CourseClass, error) {
 // treat a class as duplicate when the full offering metadata matches.
 // If an exact offering match exists and is archived/inactive, it is reactivated.
 func (a *App) CreateClass(subjectCode string, teacherUserID int, edpCode, schedule, room, section, semester, schoolYear, descriptiveTitle string, createdBy int) (int, error) {
+	if _, err := a.requireActingUser("CreateClass", teacherUserID); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -445,16 +457,23 @@ func (a *App) CreateClass(subjectCode string, teacherUserID int, edpCode, schedu
 
 // UpdateClass updates a class
 func (a *App) UpdateClass(classID int, schedule, room, section, semester, schoolYear string, isActive bool) error {
+	session, err := a.requireRole("UpdateClass")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
 
 	query := `
 		UPDATE classes 
 		SET schedule = ?, room = ?, section = ?, semester = ?, school_year = ?, is_active = ?
 		WHERE class_id = ?
 	`
-	_, err := a.db.Exec(
+	_, err = a.db.Exec(
 		query,
 		nullString(schedule), nullString(room),
 		nullString(section),
@@ -474,13 +493,20 @@ func (a *App) UpdateClass(classID int, schedule, room, section, semester, school
 // A closed class can no longer accept new enrollments or attendance.
 // Students remain enrolled but no new attendance records are created.
 func (a *App) CloseClass(classID int) error {
+	session, err := a.requireRole("CloseClass")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
 
 	// Check class exists and is active
 	var isActive bool
-	err := a.db.QueryRow(`SELECT is_active FROM classes WHERE class_id = ?`, classID).Scan(&isActive)
+	err = a.db.QueryRow(`SELECT is_active FROM classes WHERE class_id = ?`, classID).Scan(&isActive)
 	if err != nil {
 		return fmt.Errorf("class not found")
 	}
@@ -501,14 +527,21 @@ func (a *App) CloseClass(classID int) error {
 
 // ReopenClass reopens a previously closed class (CLOSED -> ACTIVE)
 func (a *App) ReopenClass(classID int) error {
+	session, err := a.requireRole("ReopenClass")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
 
 	// Check class exists and is not archived
 	var isActive bool
 	var isArchived bool
-	err := a.db.QueryRow(`SELECT is_active, COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&isActive, &isArchived)
+	err = a.db.QueryRow(`SELECT is_active, COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&isActive, &isArchived)
 	if err != nil {
 		return fmt.Errorf("class not found")
 	}
@@ -533,6 +566,10 @@ func (a *App) ReopenClass(classID int) error {
 // DeleteClass permanently deletes a class owned by the teacher.
 // Active classes can be deleted only when there are no enrolled students.
 func (a *App) DeleteClass(classID int, teacherUserID int) error {
+	session, err := a.requireActingUser("DeleteClass", teacherUserID)
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -589,6 +626,8 @@ func (a *App) DeleteClass(classID int, teacherUserID int) error {
 		return fmt.Errorf("class not found or not authorized")
 	}
 	log.Printf("Deleted class permanently: class_id=%d teacher_id=%d deleted_by=%d", classID, teacherID, teacherUserID)
+	a.audit(session, "delete_class", "class", classID,
+		auditValues{"is_active": isActive, "enrolled_count": enrolledCount}, nil)
 	return nil
 }
 
@@ -609,9 +648,16 @@ func (a *App) computeClassStatus(isActive bool, isArchived bool) string {
 
 // GetClassStudents returns students enrolled in a specific class
 func (a *App) GetClassStudents(classID int) ([]ClasslistEntry, error) {
+	session, err := a.requireRole("GetClassStudents")
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
 
 	query := `
 		SELECT 
@@ -677,9 +723,16 @@ func (a *App) GetClassStudents(classID int) ([]ClasslistEntry, error) {
 
 // GetClassByID returns a class by ID (including archived classes)
 func (a *App) GetClassByID(classID int) (*CourseClass, error) {
+	session, err := a.requireRole("GetClassByID")
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
 
 	query := `
 		SELECT c.class_id, c.subject_code, s.description as subject_name, c.descriptive_title, c.edp_code, c.join_code,
@@ -704,7 +757,7 @@ func (a *App) GetClassByID(classID int) (*CourseClass, error) {
 	var teacherName sql.NullString
 	var createdBy sql.NullInt64
 
-	err := a.db.QueryRow(query, classID).Scan(
+	err = a.db.QueryRow(query, classID).Scan(
 		&class.ClassID, &class.SubjectCode, &subjectName, &descriptiveTitle, &edpCode, &joinCode,
 		&class.TeacherUserID, &teacherName,
 		&schedule, &room, &semester, &schoolYear,
@@ -751,6 +804,13 @@ func (a *App) GetClassByID(classID int) (*CourseClass, error) {
 		class.CreatedByUserID = &createdByInt
 	}
 
+	structured, err := a.loadClassSchedule(class.ClassID)
+	if err != nil {
+		log.Printf("Failed to load structured schedule for class %d: %v", class.ClassID, err)
+	} else {
+		class.StructuredSchedule = structured
+	}
+
 	log.Printf("Retrieved class: ID=%d, Subject=%s, Active=%t, Archived=%t", class.ClassID, class.SubjectCode, class.IsActive, class.IsArchived)
 	return &class, nil
 }
@@ -761,6 +821,9 @@ func (a *App) GetClassByID(classID int) (*CourseClass, error) {
 
 // GetTeacherID returns the teacher user_id for a given user ID (now just returns the user_id since there's no separate id)
 func (a *App) GetTeacherID(userID int) (int, error) {
+	if _, err := a.requireActingUser("GetTeacherID", userID); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -780,6 +843,9 @@ func (a *App) GetTeacherID(userID int) (int, error) {
 // NOTE: This endpoint is safe to expose to working students because it deliberately omits
 // sensitive identifiers such as email, contact number, and internal student ID.
 func (a *App) GetAllRegisteredStudents() ([]ClassStudent, error) {
+	if _, err := a.requireRole("GetAllRegisteredStudents"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -16,13 +16,20 @@ import (
 // The class remains restorable with its original active/closed state.
 // When archiving a class, also archive all attendance records and enrollments.
 func (a *App) ArchiveClass(classID int) error {
+	session, err := a.requireRole("ArchiveClass")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
 
 	// Check class status
 	var isArchived bool
-	err := a.db.QueryRow(`SELECT COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&isArchived)
+	err = a.db.QueryRow(`SELECT COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&isArchived)
 	if err != nil {
 		return fmt.Errorf("class not found")
 	}
@@ -44,7 +51,7 @@ func (a *App) ArchiveClass(classID int) error {
 	}
 
 	// Also archive attendance records for this class (ONLY past attendance, not today or future)
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 	_, err = a.db.Exec(
 		`UPDATE attendance SET is_archived = 1, updated_at = CURRENT_TIMESTAMP WHERE class_id = ? AND attendance_date < ?`,
 		classID, today,
@@ -63,17 +70,25 @@ func (a *App) ArchiveClass(classID int) error {
 	}
 
 	log.Printf("Archived class and related records: class_id=%d", classID)
+	a.audit(session, "archive_class", "class", classID, auditValues{"is_archived": false}, auditValues{"is_archived": true})
 	return nil
 }
 
 // UnarchiveClass removes the archived flag from a class
 func (a *App) UnarchiveClass(classID int) error {
+	session, err := a.requireRole("UnarchiveClass")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
 
 	var classArchived bool
-	err := a.db.QueryRow(`SELECT COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&classArchived)
+	err = a.db.QueryRow(`SELECT COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&classArchived)
 	if err != nil {
 		return fmt.Errorf("class not found")
 	}
@@ -120,6 +135,9 @@ func (a *App) UnarchiveClass(classID int) error {
 
 // GetArchivedClasses returns all archived classes for a teacher
 func (a *App) GetArchivedClasses(teacherUserID int) ([]CourseClass, error) {
+	if _, err := a.requireActingUser("GetArchivedClasses", teacherUserID); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -217,10 +235,10 @@ func (a *App) GetArchivedClasses(teacherUserID int) ([]CourseClass, error) {
 
 // GetStudentArchivedClasses returns all archived classes a student was enrolled in
 func (a *App) GetStudentArchivedClasses(studentUserID int) ([]CourseClass, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GetStudentArchivedClasses", studentUserID); err != nil {
 		return nil, err
 	}
-	if err := a.ensureStudentArchivedClassesTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
 
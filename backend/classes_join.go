@@ -110,9 +110,16 @@ func (a *App) notifyStudentOfTeacherClassMembershipChange(studentUserID int, cla
 
 // GetAllStudentsForJoinClasses returns all students with their class membership status for a specific class.
 func (a *App) GetAllStudentsForJoinClasses(classID int) ([]ClassStudent, error) {
+	session, err := a.requireRole("GetAllStudentsForJoinClasses")
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
 
 	query := `
 		SELECT 
@@ -154,10 +161,22 @@ func (a *App) GetAllStudentsForJoinClasses(classID int) ([]ClassStudent, error)
 // Rule: Class must be ACTIVE. When student joins and today's attendance exists,
 // auto-insert an attendance record for the student with status='absent'.
 func (a *App) AddStudentToJoinClass(studentID int, classID int, addedBy int) error {
+	session, err := a.requireActingUser("AddStudentToJoinClass", addedBy)
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-	if err := a.ensureJoinedClassesStatusVocabulary(); err != nil {
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
+	return a.addStudentToJoinClass(studentID, classID, addedBy)
+}
+
+// addStudentToJoinClass is the unchecked implementation shared by teacher adds and student self-joins.
+func (a *App) addStudentToJoinClass(studentID int, classID int, addedBy int) error {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
@@ -192,22 +211,18 @@ func (a *App) AddStudentToJoinClass(studentID int, classID int, addedBy int) err
 	}
 
 	// Auto-sync attendance for today if an open attendance session exists.
-	today := time.Now().Format("2006-01-02")
+	today := a.today()
 	openSessionID := 0
-	if sessionTableErr := a.ensureAttendanceSessionsTable(); sessionTableErr != nil {
-		log.Printf("Warning: Failed to initialize attendance sessions while syncing late joiner attendance: %v", sessionTableErr)
-	} else {
-		sessionErr := a.db.QueryRow(
-			`SELECT session_id
-			 FROM attendance_sessions
-			 WHERE class_id = ? AND attendance_date = ? AND status = 'open' AND COALESCE(is_archived, 0) = 0
-			 ORDER BY session_id DESC
-			 LIMIT 1`,
-			classID, today,
-		).Scan(&openSessionID)
-		if sessionErr != nil && sessionErr != sql.ErrNoRows {
-			log.Printf("Warning: Failed to query open attendance session for class %d on %s: %v", classID, today, sessionErr)
-		}
+	sessionErr := a.db.QueryRow(
+		`SELECT session_id
+		 FROM attendance_sessions
+		 WHERE class_id = ? AND attendance_date = ? AND status = 'open' AND COALESCE(is_archived, 0) = 0
+		 ORDER BY session_id DESC
+		 LIMIT 1`,
+		classID, today,
+	).Scan(&openSessionID)
+	if sessionErr != nil && sessionErr != sql.ErrNoRows {
+		log.Printf("Warning: Failed to query open attendance session for class %d on %s: %v", classID, today, sessionErr)
 	}
 
 	if openSessionID > 0 {
@@ -229,17 +244,21 @@ func (a *App) AddStudentToJoinClass(studentID int, classID int, addedBy int) err
 // If students were previously in the class and archived, it will reactivate and unarchive their rows.
 // Rule: Class must be ACTIVE.
 func (a *App) AddMultipleStudentsToJoinClass(studentIDs []int, classID int, addedBy int) error {
+	session, err := a.requireActingUser("AddMultipleStudentsToJoinClass", addedBy)
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-	if err := a.ensureJoinedClassesStatusVocabulary(); err != nil {
+	if err := a.checkClassAccess(session, classID); err != nil {
 		return err
 	}
 
 	// Check class is active (not closed or archived)
 	var isActive bool
 	var isArchived bool
-	err := a.db.QueryRow(`SELECT is_active, COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&isActive, &isArchived)
+	err = a.db.QueryRow(`SELECT is_active, COALESCE(is_archived, 0) FROM classes WHERE class_id = ?`, classID).Scan(&isActive, &isArchived)
 	if err != nil {
 		return fmt.Errorf("class not found")
 	}
@@ -278,27 +297,23 @@ func (a *App) AddMultipleStudentsToJoinClass(studentIDs []int, classID int, adde
 		return err
 	}
 
-	today := time.Now().Format("2006-01-02")
-	if sessionTableErr := a.ensureAttendanceSessionsTable(); sessionTableErr != nil {
-		log.Printf("Warning: Failed to initialize attendance sessions while syncing added students: %v", sessionTableErr)
-	} else {
-		var openSessionID int
-		sessionErr := a.db.QueryRow(
-			`SELECT session_id
-			 FROM attendance_sessions
-			 WHERE class_id = ? AND attendance_date = ? AND status = 'open' AND COALESCE(is_archived, 0) = 0
-			 ORDER BY session_id DESC
-			 LIMIT 1`,
-			classID, today,
-		).Scan(&openSessionID)
-
-		if sessionErr == nil && openSessionID > 0 {
-			if syncErr := a.ensureAttendanceRowsForSession(openSessionID, classID, today); syncErr != nil {
-				log.Printf("Warning: Failed to sync attendance rows for open session %d after bulk add: %v", openSessionID, syncErr)
-			}
-		} else if sessionErr != nil && sessionErr != sql.ErrNoRows {
-			log.Printf("Warning: Failed to query open attendance session for bulk add sync: class=%d date=%s err=%v", classID, today, sessionErr)
+	today := a.today()
+	var openSessionID int
+	sessionErr := a.db.QueryRow(
+		`SELECT session_id
+		 FROM attendance_sessions
+		 WHERE class_id = ? AND attendance_date = ? AND status = 'open' AND COALESCE(is_archived, 0) = 0
+		 ORDER BY session_id DESC
+		 LIMIT 1`,
+		classID, today,
+	).Scan(&openSessionID)
+
+	if sessionErr == nil && openSessionID > 0 {
+		if syncErr := a.ensureAttendanceRowsForSession(openSessionID, classID, today); syncErr != nil {
+			log.Printf("Warning: Failed to sync attendance rows for open session %d after bulk add: %v", openSessionID, syncErr)
 		}
+	} else if sessionErr != nil && sessionErr != sql.ErrNoRows {
+		log.Printf("Warning: Failed to query open attendance session for bulk add sync: class=%d date=%s err=%v", classID, today, sessionErr)
 	}
 
 	log.Printf("Added %d students in class %d", len(studentIDs), classID)
@@ -310,11 +325,31 @@ func (a *App) AddMultipleStudentsToJoinClass(studentIDs []int, classID int, adde
 
 // RemoveStudentFromJoinClassByIDs marks a class membership as removed when initiated by the teacher.
 func (a *App) RemoveStudentFromJoinClassByIDs(studentID int, classID int) error {
-	return a.updateJoinClassMembershipStatusByIDs(studentID, classID, joinClassStatusRemoved)
+	session, err := a.requireActingUser("RemoveStudentFromJoinClassByIDs", studentID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
+	if err := a.updateJoinClassMembershipStatusByIDs(studentID, classID, joinClassStatusRemoved); err != nil {
+		return err
+	}
+	if session.Role == "teacher" {
+		a.audit(session, "remove_student_from_class", "class", classID,
+			auditValues{"student_user_id": studentID, "status": "enrolled"}, auditValues{"student_user_id": studentID, "status": joinClassStatusRemoved})
+	}
+	return nil
 }
 
 // LeaveClassByStudent marks a class membership as left when initiated by the student.
 func (a *App) LeaveClassByStudent(studentID int, classID int) error {
+	if _, err := a.requireActingUser("LeaveClassByStudent", studentID); err != nil {
+		return err
+	}
 	return a.updateJoinClassMembershipStatusByIDs(studentID, classID, joinClassStatusLeft)
 }
 
@@ -322,9 +357,6 @@ func (a *App) updateJoinClassMembershipStatusByIDs(studentID int, classID int, n
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-	if err := a.ensureJoinedClassesStatusVocabulary(); err != nil {
-		return err
-	}
 	if nextStatus != joinClassStatusLeft && nextStatus != joinClassStatusRemoved {
 		return fmt.Errorf("invalid join-class status update: %s", nextStatus)
 	}
@@ -361,6 +393,9 @@ func (a *App) updateJoinClassMembershipStatusByIDs(studentID int, classID int, n
 
 // GetClassesByJoinCode returns all active classes matching a provided JOIN code.
 func (a *App) GetClassesByJoinCode(joinCode string) ([]CourseClass, error) {
+	if _, err := a.requireRole("GetClassesByJoinCode"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -462,10 +497,10 @@ func (a *App) GetClassesByJoinCode(joinCode string) ([]CourseClass, error) {
 
 // JoinClassByJoinCode joins a student in a class by generated JOIN code.
 func (a *App) JoinClassByJoinCode(studentUserID int, joinCode string) (int, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("JoinClassByJoinCode", studentUserID); err != nil {
 		return 0, err
 	}
-	if err := a.ensureStudentArchivedClassesTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
 
@@ -505,7 +540,7 @@ func (a *App) JoinClassByJoinCode(studentUserID int, joinCode string) (int, erro
 
 	// Join the first available class
 	classID := classes[0].ClassID
-	err = a.AddStudentToJoinClass(studentUserID, classID, studentUserID)
+	err = a.addStudentToJoinClass(studentUserID, classID, studentUserID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to join class: %v", err)
 	}
@@ -600,87 +635,16 @@ func (a *App) resolveDepartmentCode(value string) (string, error) {
 	return strings.TrimSpace(code.String), nil
 }
 
-func (a *App) ensureJoinedClassesStatusVocabulary() error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-
-	_, _ = a.db.Exec(`
-		ALTER TABLE joined_classes
-		MODIFY COLUMN status VARCHAR(20) DEFAULT 'added'
-	`)
-
-	rows, err := a.db.Query(`
-		SELECT tc.CONSTRAINT_NAME
-		FROM information_schema.TABLE_CONSTRAINTS tc
-		WHERE tc.TABLE_SCHEMA = DATABASE()
-		  AND tc.TABLE_NAME = 'joined_classes'
-		  AND tc.CONSTRAINT_TYPE = 'CHECK'
-	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var constraintName string
-			if scanErr := rows.Scan(&constraintName); scanErr != nil {
-				continue
-			}
-			safeName := strings.ReplaceAll(constraintName, "`", "")
-			if safeName == "" {
-				continue
-			}
-			_, dropErr := a.db.Exec(fmt.Sprintf("ALTER TABLE joined_classes DROP CHECK `%s`", safeName))
-			if dropErr != nil {
-				_, _ = a.db.Exec(fmt.Sprintf("ALTER TABLE joined_classes DROP CONSTRAINT `%s`", safeName))
-			}
-		}
-	}
-
-	_, _ = a.db.Exec(`
-		ALTER TABLE joined_classes
-		ADD CONSTRAINT chk_joined_classes_status
-		CHECK (status IN ('join', 'left', 'added', 'removed'))
-	`)
-
-	return nil
-}
-
 // ==============================================================================
 // JOIN CLASS ARCHIVING
 // ==============================================================================
 
-func (a *App) ensureStudentArchivedClassesTable() error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-	if err := a.ensureJoinedClassesStatusVocabulary(); err != nil {
-		return err
-	}
-
-	createQuery := `
-		CREATE TABLE IF NOT EXISTS student_archived_classes (
-			student_id INT NOT NULL,
-			class_id INT NOT NULL,
-			archived_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			CONSTRAINT PK_student_archived_classes PRIMARY KEY (student_id, class_id),
-			CONSTRAINT FK_student_archived_classes_student FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
-			CONSTRAINT FK_student_archived_classes_class FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE
-		)
-	`
-
-	if _, err := a.db.Exec(createQuery); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // ArchiveJoinedClassByStudent archives a student's joined class in My Classes.
 func (a *App) ArchiveJoinedClassByStudent(studentUserID int, classID int) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("ArchiveJoinedClassByStudent", studentUserID); err != nil {
 		return err
 	}
-	if err := a.ensureStudentArchivedClassesTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
@@ -727,10 +691,10 @@ func (a *App) ArchiveJoinedClassByStudent(studentUserID int, classID int) error
 
 // RestoreArchivedJoinedClassByStudent restores a student's archived joined class.
 func (a *App) RestoreArchivedJoinedClassByStudent(studentUserID int, classID int) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("RestoreArchivedJoinedClassByStudent", studentUserID); err != nil {
 		return err
 	}
-	if err := a.ensureStudentArchivedClassesTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
@@ -765,10 +729,13 @@ func (a *App) RestoreArchivedJoinedClassByStudent(studentUserID int, classID int
 // buildClasslistPrintableDocument prepares a printableExportDocument and metadata
 // for all enrolled students in a class. It is used by the different export
 // formats (CSV, PDF, DOCX) so the export logic lives in one place.
-func (a *App) buildClasslistPrintableDocument(classID int) (printableExportDocument, string, int, error) {
+func (a *App) buildClasslistPrintableDocument(session *appSession, classID int) (printableExportDocument, string, int, error) {
 	if err := a.checkDB(); err != nil {
 		return printableExportDocument{}, "", 0, err
 	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return printableExportDocument{}, "", 0, err
+	}
 
 	var subjectCode string
 	var subjectName, schedule, room, teacherName, semester, schoolYear, edpCode, joinCode sql.NullString
@@ -945,7 +912,11 @@ func classlistCSVTextCell(value string) string {
 // ExportClasslistCSV exports the classlist (enrolled students) for a class to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportClasslistCSV(classID int, savePath string) (string, error) {
-	doc, subjectCode, studentCount, err := a.buildClasslistPrintableDocument(classID)
+	session, err := a.requireRole("ExportClasslistCSV")
+	if err != nil {
+		return "", err
+	}
+	doc, subjectCode, studentCount, err := a.buildClasslistPrintableDocument(session, classID)
 	if err != nil {
 		return "", err
 	}
@@ -967,7 +938,11 @@ func (a *App) ExportClasslistCSV(classID int, savePath string) (string, error) {
 // ExportClasslistPDF exports the classlist (enrolled students) for a class to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportClasslistPDF(classID int, savePath string) (string, error) {
-	doc, subjectCode, studentCount, err := a.buildClasslistPrintableDocument(classID)
+	session, err := a.requireRole("ExportClasslistPDF")
+	if err != nil {
+		return "", err
+	}
+	doc, subjectCode, studentCount, err := a.buildClasslistPrintableDocument(session, classID)
 	if err != nil {
 		return "", err
 	}
@@ -984,7 +959,11 @@ func (a *App) ExportClasslistPDF(classID int, savePath string) (string, error) {
 // ExportClasslistDOCX exports the classlist for a class to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportClasslistDOCX(classID int, savePath string) (string, error) {
-	doc, subjectCode, studentCount, err := a.buildClasslistPrintableDocument(classID)
+	session, err := a.requireRole("ExportClasslistDOCX")
+	if err != nil {
+		return "", err
+	}
+	doc, subjectCode, studentCount, err := a.buildClasslistPrintableDocument(session, classID)
 	if err != nil {
 		return "", err
 	}
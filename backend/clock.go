@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"digital-logbook-wails-app/backend/storage"
+)
+
+// ==============================================================================
+// CLOCK
+// ==============================================================================
+//
+// Every time-dependent decision (lateness, session expiry, stale logins, student
+// account expiry and inactivity) reads the current time from App.now() and passes
+// it to SQL as a parameter instead of mixing Go's time.Now() with the server's
+// NOW(). By default the clock follows the database server: the offset between this
+// PC and the server is measured on connect and applied to the local clock, so a PC
+// with a wrong clock still marks students the way the server would. Tests swap in
+// a fake clock.
+
+const (
+	clockDriftWarningSeconds   = 60
+	clockResyncIntervalMinutes = 15
+)
+
+// Clock is a source of the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// serverClock tracks the database server's time as an offset from the local clock.
+type serverClock struct {
+	mu     sync.RWMutex
+	offset time.Duration
+	synced bool
+}
+
+func (c *serverClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Now().Add(c.offset)
+}
+
+func (c *serverClock) state() (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offset, c.synced
+}
+
+func (c *serverClock) set(offset time.Duration) {
+	c.mu.Lock()
+	c.offset = offset
+	c.synced = true
+	c.mu.Unlock()
+}
+
+// ClockStatus compares this PC's clock with the database server's.
+type ClockStatus struct {
+	ServerTime   string `json:"server_time"`
+	LocalTime    string `json:"local_time"`
+	DriftSeconds int    `json:"drift_seconds"`
+	DriftWarning bool   `json:"drift_warning"`
+	Synced       bool   `json:"synced"`
+}
+
+// now returns the current time from the app clock.
+func (a *App) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+	return a.clock.Now()
+}
+
+// today returns the app clock's date as YYYY-MM-DD.
+func (a *App) today() string {
+	return a.now().Format("2006-01-02")
+}
+
+// measureServerClockOffset returns how far the database server's clock is ahead of this PC's.
+// An embedded SQLite database runs on this PC, so its offset is always zero.
+func measureServerClockOffset(db *sql.DB, driver string) (time.Duration, error) {
+	if driver == storage.DriverSQLite {
+		return 0, nil
+	}
+
+	before := time.Now()
+	var serverNow time.Time
+	if err := db.QueryRow(`SELECT NOW(3)`).Scan(&serverNow); err != nil {
+		return 0, fmt.Errorf("failed to read server time: %w", err)
+	}
+	after := time.Now()
+
+	// Assume the server read its clock halfway through the round trip.
+	local := before.Add(after.Sub(before) / 2)
+	return serverNow.Sub(local), nil
+}
+
+// syncServerClock re-measures the PC/server offset and warns when it is larger than
+// clockDriftWarningSeconds. It does nothing when a non-server clock is installed.
+func (a *App) syncServerClock() {
+	clock, ok := a.clock.(*serverClock)
+	if !ok || a.db == nil {
+		return
+	}
+
+	offset, err := measureServerClockOffset(a.db, a.dbDriver())
+	if err != nil {
+		log.Printf("Failed to sync with database server clock: %v", err)
+		return
+	}
+	clock.set(offset)
+
+	if offset.Abs() > time.Duration(clockDriftWarningSeconds)*time.Second {
+		log.Printf("WARNING: this PC's clock is %s off from the database server (threshold %ds). Attendance and session times follow the server clock; fix the PC clock or time zone.",
+			offset.Round(time.Second), clockDriftWarningSeconds)
+	}
+}
+
+func (a *App) startClockSyncLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(clockResyncIntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if a.checkDB() == nil {
+				a.syncServerClock()
+			}
+		}
+	}
+}
+
+// GetClockStatus reports the drift between this PC and the database server.
+func (a *App) GetClockStatus() (ClockStatus, error) {
+	if _, err := a.requireRole("GetClockStatus"); err != nil {
+		return ClockStatus{}, err
+	}
+	if err := a.checkDB(); err != nil {
+		return ClockStatus{}, err
+	}
+	a.syncServerClock()
+
+	status := ClockStatus{
+		ServerTime: formatTime(a.now()),
+		LocalTime:  formatTime(time.Now()),
+		Synced:     true,
+	}
+	if clock, ok := a.clock.(*serverClock); ok {
+		offset, synced := clock.state()
+		status.DriftSeconds = int(offset.Round(time.Second) / time.Second)
+		status.DriftWarning = offset.Abs() > time.Duration(clockDriftWarningSeconds)*time.Second
+		status.Synced = synced
+	}
+	return status, nil
+}
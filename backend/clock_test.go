@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStudentAccountExpiryFollowsAppClock(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	e.clock.Advance(4*365*24*time.Hour - 24*time.Hour)
+	e.loginAs("2024-00001")
+
+	e.clock.Advance(3 * 24 * time.Hour)
+	_, err := e.app.Login("2024-00001", testPassword)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("login after four years: err = %v, want expired error", err)
+	}
+}
+
+func TestInactivityCheckFollowsAppClock(t *testing.T) {
+	e := newTestEnv(t)
+	t.Setenv("INACTIVITY_DEACTIVATION_DAYS", "30")
+	t.Setenv("DEACTIVATED_DELETION_DAYS", "60")
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	e.loginAs("2024-00001")
+
+	e.clock.Advance(29 * 24 * time.Hour)
+	e.loginAs("A-0001")
+	counts, err := e.app.RunInactivityCheck()
+	if err != nil {
+		t.Fatalf("RunInactivityCheck: %v", err)
+	}
+	if counts["deactivated"] != 0 {
+		t.Fatalf("deactivated %d account(s) before the inactivity window", counts["deactivated"])
+	}
+
+	e.clock.Advance(2 * 24 * time.Hour)
+	e.loginAs("A-0001")
+	if _, err := e.app.RunInactivityCheck(); err != nil {
+		t.Fatalf("RunInactivityCheck: %v", err)
+	}
+	if got := e.queryString(`SELECT account_status FROM users WHERE id = ?`, studentID); got != "deactivated" {
+		t.Fatalf("account status after inactivity window = %q, want deactivated", got)
+	}
+
+	e.clock.Advance(61 * 24 * time.Hour)
+	e.loginAs("A-0001")
+	if _, err := e.app.RunInactivityCheck(); err != nil {
+		t.Fatalf("RunInactivityCheck: %v", err)
+	}
+	if got := e.queryString(`SELECT account_status FROM users WHERE id = ?`, studentID); got != "deleted" {
+		t.Errorf("account status after deletion window = %q, want deleted", got)
+	}
+}
+
+func TestClockStatusWithoutServerClock(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.loginAs("A-0001")
+
+	status, err := e.app.GetClockStatus()
+	if err != nil {
+		t.Fatalf("GetClockStatus: %v", err)
+	}
+	if status.ServerTime != formatTime(e.clock.Now()) {
+		t.Errorf("server time = %s, want the app clock %s", status.ServerTime, formatTime(e.clock.Now()))
+	}
+	if status.DriftWarning {
+		t.Errorf("drift warning raised without a server clock")
+	}
+}
@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// CONCURRENT LOGINS
+// ==============================================================================
+//
+// A user is active on another station when that station still has an open login log
+// for them and that log's heartbeat is fresh. What happens when they log in again
+// elsewhere is set per role with [policy] concurrent_login_<role>:
+//   - allow: both stations stay logged in.
+//   - deny: the new login is refused until the other station logs out or goes silent.
+//   - takeover (default): the other station's login log is closed with logout_reason
+//     'taken_over'; that station notices on its next heartbeat, ends its session and
+//     locks its screen.
+// Refused and taken-over logins are flagged to admins, since they usually mean someone
+// else is using the account.
+
+const (
+	concurrentLoginAllow    = "allow"
+	concurrentLoginDeny     = "deny"
+	concurrentLoginTakeOver = "takeover"
+
+	logoutReasonTakenOver = "taken_over"
+
+	// sessionTakenOverEvent carries the user ID of a session ended by a login elsewhere.
+	sessionTakenOverEvent = "session:taken-over"
+)
+
+var concurrentLoginChoices = []string{concurrentLoginAllow, concurrentLoginDeny, concurrentLoginTakeOver}
+
+// activeStationLogin is an open login log on another station that is still heartbeating.
+type activeStationLogin struct {
+	logID        int
+	stationLabel string
+}
+
+// loadConcurrentLoginPolicy returns the concurrent-login policy for a role, read from
+// [policy] concurrent_login_<role> (or CONCURRENT_LOGIN_<ROLE>).
+func loadConcurrentLoginPolicy(role string) string {
+	return loadPolicyChoice("CONCURRENT_LOGIN_"+strings.ToUpper(role), "concurrent_login_"+role,
+		concurrentLoginChoices, concurrentLoginTakeOver)
+}
+
+// activeLoginsElsewhere lists the user's open login logs on stations other than
+// stationLabel whose own heartbeat is fresh.
+func (a *App) activeLoginsElsewhere(userID int, stationLabel string) ([]activeStationLogin, error) {
+	cutoff := a.now().Add(-time.Duration(sessionHeartbeatTimeoutSeconds) * time.Second)
+	rows, err := a.db.Query(`
+		SELECT le.id, COALESCE(le.pc_number, '')
+		FROM log_entries le
+		WHERE le.user_id = ? AND le.logout_time IS NULL
+			AND COALESCE(le.pc_number, '') <> ?
+			AND le.last_seen_at >= ?
+		ORDER BY le.login_time
+	`, userID, stationLabel, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check other stations: %w", err)
+	}
+	defer rows.Close()
+
+	var logins []activeStationLogin
+	for rows.Next() {
+		var login activeStationLogin
+		if err := rows.Scan(&login.logID, &login.stationLabel); err != nil {
+			return nil, fmt.Errorf("failed to check other stations: %w", err)
+		}
+		logins = append(logins, login)
+	}
+	return logins, rows.Err()
+}
+
+// checkConcurrentLogin refuses a login under the deny policy while the user is active on
+// another station. It runs before the second factor so a refused login is not asked for one.
+func (a *App) checkConcurrentLogin(user *User, stationLabel string) error {
+	if loadConcurrentLoginPolicy(user.Role) != concurrentLoginDeny {
+		return nil
+	}
+	active, err := a.activeLoginsElsewhere(user.ID, stationLabel)
+	if err != nil {
+		return err
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	other := stationOrUnknown(active[0].stationLabel)
+	log.Printf("LOGIN ERROR: User '%s' refused on '%s' - still active on '%s'", user.Name, stationLabel, other)
+	a.flagConcurrentLogin(fmt.Sprintf("A login to %s on %s was refused because the account is still active on %s.",
+		user.Name, stationOrUnknown(stationLabel), other))
+	return fmt.Errorf("this account is already logged in on %s - log out there first", other)
+}
+
+// closePriorLogins closes the user's other open login logs before a new one is created.
+// Logs on silent stations, including this one, are closed as before; logs on active
+// stations are kept under the allow policy and taken over otherwise.
+func (a *App) closePriorLogins(user *User, stationLabel string) {
+	policy := loadConcurrentLoginPolicy(user.Role)
+	active, err := a.activeLoginsElsewhere(user.ID, stationLabel)
+	if err != nil {
+		log.Printf("Failed to check other stations for user %d: %v", user.ID, err)
+	}
+
+	keep := map[int]bool{}
+	if policy == concurrentLoginAllow {
+		for _, login := range active {
+			keep[login.logID] = true
+		}
+	}
+
+	rows, err := a.db.Query(`SELECT id FROM log_entries WHERE user_id = ? AND logout_time IS NULL`, user.ID)
+	if err != nil {
+		log.Printf("Failed to close prior open login logs for user %d: %v", user.ID, err)
+		return
+	}
+	var openIDs []int
+	for rows.Next() {
+		var id int
+		if rows.Scan(&id) == nil && !keep[id] {
+			openIDs = append(openIDs, id)
+		}
+	}
+	rows.Close()
+
+	takenOver := map[int]bool{}
+	if policy != concurrentLoginAllow {
+		for _, login := range active {
+			takenOver[login.logID] = true
+		}
+	}
+
+	now := a.now()
+	for _, id := range openIDs {
+		var reason interface{}
+		if takenOver[id] {
+			reason = logoutReasonTakenOver
+		}
+		if _, err := a.db.Exec(`UPDATE log_entries SET logout_time = ?, logout_reason = ? WHERE id = ?`, now, reason, id); err != nil {
+			log.Printf("Failed to close prior open login log %d for user %d: %v", id, user.ID, err)
+		}
+	}
+
+	if len(takenOver) > 0 {
+		other := stationOrUnknown(active[0].stationLabel)
+		log.Printf("User '%s' logged in on '%s' and took over the session on '%s'", user.Name, stationLabel, other)
+		a.flagConcurrentLogin(fmt.Sprintf("%s logged in on %s while still active on %s; the session on %s was ended.",
+			user.Name, stationOrUnknown(stationLabel), other, other))
+		go a.createNotification(user.ID, "security", "Session Ended Elsewhere",
+			fmt.Sprintf("Your session on %s was ended because your account logged in on %s. If that was not you, change your password.",
+				other, stationOrUnknown(stationLabel)),
+			"warning", notifRef("concurrent_login"), nil)
+	}
+}
+
+// flagConcurrentLogin tells admins about a refused or taken-over login.
+func (a *App) flagConcurrentLogin(message string) {
+	go a.createNotificationForRole("admin", "security", "Concurrent Login", message, "warning", notifRef("concurrent_login"), nil)
+}
+
+// checkSessionTakenOver ends this station's session once its login log has been closed
+// by a takeover from another station. It is called from the heartbeat.
+func (a *App) checkSessionTakenOver(session *appSession) {
+	if session.LoginLogID == 0 {
+		return
+	}
+	var reason string
+	if err := a.db.QueryRow(`
+		SELECT COALESCE(logout_reason, '') FROM log_entries WHERE id = ? AND logout_time IS NOT NULL
+	`, session.LoginLogID).Scan(&reason); err != nil || reason != logoutReasonTakenOver {
+		return
+	}
+	if current := a.currentSession(); current == nil || current.Token != session.Token {
+		return
+	}
+
+	log.Printf("Session of user %d on %s was taken over by a login on another station", session.UserID, a.currentStationLabel())
+	a.endSession()
+	a.LockScreen()
+	a.emitEvent(sessionTakenOverEvent, session.UserID)
+}
+
+func stationOrUnknown(stationLabel string) string {
+	return stringOrDefault(stationLabel, "an unnamed station")
+}
@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+// otherStation returns a second App on the same database, standing in for another PC.
+func (e *testEnv) otherStation(pcNumber string) *App {
+	e.t.Helper()
+	station := NewApp()
+	station.db = e.db
+	station.clock = e.clock
+	station.computerLab = "Lab 1"
+	station.pcNumber = pcNumber
+	return station
+}
+
+func TestConcurrentLoginPolicies(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "5"
+	pc12 := e.otherStation("12")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	t.Setenv("CONCURRENT_LOGIN_STUDENT", "deny")
+	t.Setenv("CONCURRENT_LOGIN_TEACHER", "allow")
+
+	// deny: refused while PC 12 heartbeats, accepted once it has gone silent.
+	if _, err := pc12.Login("2024-00001", testPassword); err != nil {
+		t.Fatalf("Login on PC 12: %v", err)
+	}
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil {
+		t.Fatalf("a second station logged in under the deny policy")
+	}
+	e.clock.Advance(time.Duration(sessionHeartbeatTimeoutSeconds+10) * time.Second)
+	if _, err := e.app.Login("2024-00001", testPassword); err != nil {
+		t.Fatalf("Login after PC 12 went silent: %v", err)
+	}
+	if err := e.app.Logout(studentID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	// takeover: PC 12's log is closed and PC 12 ends its session on its next heartbeat.
+	t.Setenv("CONCURRENT_LOGIN_STUDENT", "takeover")
+	first, err := pc12.Login("2024-00001", testPassword)
+	if err != nil {
+		t.Fatalf("Login on PC 12: %v", err)
+	}
+	if _, err := e.app.Login("2024-00001", testPassword); err != nil {
+		t.Fatalf("takeover login: %v", err)
+	}
+	if reason := e.queryString(`SELECT logout_reason FROM log_entries WHERE id = ?`, first.LoginLogID); reason != logoutReasonTakenOver {
+		t.Errorf("PC 12 logout_reason = %q, want %q", reason, logoutReasonTakenOver)
+	}
+	if err := pc12.TouchSession(studentID); err != nil {
+		t.Fatalf("TouchSession on PC 12: %v", err)
+	}
+	if pc12.currentSession() != nil {
+		t.Errorf("PC 12 kept its session after the takeover")
+	}
+	if e.app.currentSession() == nil {
+		t.Errorf("the taking-over station lost its session")
+	}
+
+	// allow: both stations stay logged in, and logging out of one leaves the other open.
+	if _, err := pc12.Login("T-0001", testPassword); err != nil {
+		t.Fatalf("teacher Login on PC 12: %v", err)
+	}
+	here, err := e.app.Login("T-0001", testPassword)
+	if err != nil {
+		t.Fatalf("teacher Login on PC 5: %v", err)
+	}
+	if n := openLoginLogs(e, teacherID); n != 2 {
+		t.Fatalf("open teacher logs = %d, want 2", n)
+	}
+	if err := e.app.Logout(teacherID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if e.queryString(`SELECT COALESCE(logout_time, '') FROM log_entries WHERE id = ?`, here.LoginLogID) == "" || openLoginLogs(e, teacherID) != 1 {
+		t.Errorf("Logout on PC 5 did not close exactly its own log")
+	}
+	if err := pc12.TouchSession(teacherID); err != nil || pc12.currentSession() == nil {
+		t.Errorf("PC 12 lost its teacher session: %v", err)
+	}
+}
+
+func TestSilentStationIsNotKeptAliveByAnother(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "5"
+	pc12, pc20 := e.otherStation("12"), e.otherStation("20")
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	t.Setenv("CONCURRENT_LOGIN_TEACHER", "allow")
+
+	silent, err := pc12.Login("T-0001", testPassword)
+	if err != nil {
+		t.Fatalf("Login on PC 12: %v", err)
+	}
+	if _, err := pc20.Login("T-0001", testPassword); err != nil {
+		t.Fatalf("Login on PC 20: %v", err)
+	}
+	// Only PC 20 keeps heartbeating.
+	e.clock.Advance(time.Duration(sessionHeartbeatTimeoutSeconds+10) * time.Second)
+	if err := pc20.TouchSession(teacherID); err != nil {
+		t.Fatalf("TouchSession on PC 20: %v", err)
+	}
+
+	active, err := e.app.activeLoginsElsewhere(teacherID, e.app.currentStationLabel())
+	if err != nil {
+		t.Fatalf("activeLoginsElsewhere: %v", err)
+	}
+	if len(active) != 1 || active[0].logID == silent.LoginLogID {
+		t.Errorf("active logins = %+v, want only PC 20's", active)
+	}
+}
+
+func TestReplayedOfflineLoginCanBeTakenOver(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "5"
+	pc12 := e.otherStation("12")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	t.Setenv("CONCURRENT_LOGIN_STUDENT", "takeover")
+
+	// A login made while the server was down, replayed while the student is still on PC 5.
+	uid, err := e.app.queueOfflineEvent(nil, offlineEventLogin, studentID, offlineLoginPayload{Username: "2024-00001"})
+	if err != nil {
+		t.Fatalf("queueOfflineEvent: %v", err)
+	}
+	token, err := e.app.startSession(&User{ID: studentID, Name: "Ana Cruz", Role: "student"})
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	e.app.setSessionOfflineEvent(token, uid)
+	e.clock.Advance(time.Duration(sessionHeartbeatTimeoutSeconds+10) * time.Second)
+	e.app.replayOfflineQueue()
+
+	session := e.app.currentSession()
+	if session == nil || session.OfflineEventUID != "" || session.LoginLogID == 0 {
+		t.Fatalf("session after replay = %+v, want it online and linked to its login log", session)
+	}
+	if err := e.app.TouchSession(studentID); err != nil {
+		t.Fatalf("TouchSession: %v", err)
+	}
+	if active, err := pc12.activeLoginsElsewhere(studentID, pc12.currentStationLabel()); err != nil || len(active) != 1 {
+		t.Fatalf("active logins seen from PC 12 = %+v, %v; want the replayed login", active, err)
+	}
+
+	if _, err := pc12.Login("2024-00001", testPassword); err != nil {
+		t.Fatalf("Login on PC 12: %v", err)
+	}
+	if err := e.app.TouchSession(studentID); err != nil {
+		t.Fatalf("TouchSession after the takeover: %v", err)
+	}
+	if e.app.currentSession() != nil {
+		t.Errorf("PC 5 kept the replayed session after the takeover")
+	}
+}
@@ -12,16 +12,35 @@ import (
 	"strconv"
 	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	"digital-logbook-wails-app/backend/storage"
 )
 
-// DBConfig holds database configuration
+// DBConfig holds database configuration.
+// Driver is "mysql" (default) or "sqlite"; Path is only used by sqlite.
+// Password is always the plaintext password; config.ini may hold it encrypted.
 type DBConfig struct {
+	Driver   string `json:"driver"`
 	Host     string
 	Port     string `json:"port"`
 	DBName   string
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Path     string `json:"path"`
+
+	// MySQL TLS settings. Relative file paths resolve next to config.ini.
+	TLS               bool   `json:"tls"`
+	TLSCAFile         string `json:"tls_ca_file"`
+	TLSCertFile       string `json:"tls_cert_file"`
+	TLSKeyFile        string `json:"tls_key_file"`
+	TLSServerName     string `json:"tls_server_name"`
+	TLSSkipNameVerify bool   `json:"tls_skip_name_verify"`
+	// PasswordKeyFile overrides where the machine key that seals the password is kept.
+	PasswordKeyFile string `json:"key_file"`
+
+	// sourcePath is the config.ini the settings were read from or are written to.
+	sourcePath string
+	// passwordSealed reports whether config.ini held the password encrypted.
+	passwordSealed bool
 }
 
 // AppConfig holds application-level configuration (lock mode, etc.)
@@ -29,12 +48,21 @@ type AppConfig struct {
 	LockMode    bool   `json:"lock_mode"`
 	ComputerLab string `json:"computer_lab"`
 	PCNumber    string `json:"pc_number"`
+	// DatabaseConfigured is set once database settings have been saved or used on this
+	// station; from then on station settings always need a credential.
+	DatabaseConfigured bool `json:"database_configured,omitempty"`
+
+	// legacyPINHash is a maintenance PIN hash older versions cached in this file. It is
+	// moved to the station journal and never written back.
+	legacyPINHash string
 }
 
 type appConfigFile struct {
-	LockMode    *bool  `json:"lock_mode"`
-	ComputerLab string `json:"computer_lab"`
-	PCNumber    string `json:"pc_number"`
+	LockMode           *bool  `json:"lock_mode"`
+	ComputerLab        string `json:"computer_lab"`
+	PCNumber           string `json:"pc_number"`
+	MaintenancePINHash string `json:"maintenance_pin_hash"`
+	DatabaseConfigured bool   `json:"database_configured"`
 }
 
 const (
@@ -114,6 +142,112 @@ func LoadDeactivatedDeletionDays() int {
 	return defaultDeactivatedDeletionDays
 }
 
+// loadPolicyInt reads an integer [policy] setting that has no dedicated loader.
+//
+// Precedence:
+//  1. envName environment variable
+//  2. [policy] iniKey in config.ini
+//  3. fallback
+func loadPolicyInt(envName, iniKey string, minValue, maxValue, fallback int) int {
+	if value, provided, err := parsePolicyIntValue(os.Getenv(envName), minValue, maxValue); provided {
+		if err != nil {
+			log.Printf("Invalid %s: %v (using fallback settings)", envName, err)
+		} else {
+			return value
+		}
+	}
+
+	for _, configPath := range getConfigINIPaths() {
+		value, found, err := parsePolicyIntFromConfigINI(configPath, iniKey, minValue, maxValue)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			log.Printf("Unable to parse %s from %s: %v", iniKey, configPath, err)
+			continue
+		}
+
+		if found {
+			return value
+		}
+	}
+
+	return fallback
+}
+
+// loadPolicyPath reads a file path [policy] setting with the same precedence as
+// loadPolicyInt. A relative path in config.ini is resolved against the directory that
+// holds that config.ini. An empty result means the setting is not configured.
+func loadPolicyPath(envName, iniKey string) string {
+	if value := strings.Trim(strings.TrimSpace(os.Getenv(envName)), `"'`); value != "" {
+		return value
+	}
+
+	for _, configPath := range getConfigINIPaths() {
+		raw, _, found, err := parsePolicyValueFromConfigINI(configPath, iniKey)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			log.Printf("Unable to parse %s from %s: %v", iniKey, configPath, err)
+			continue
+		}
+
+		value := strings.Trim(strings.TrimSpace(raw), `"'`)
+		if !found || value == "" {
+			continue
+		}
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(filepath.Dir(configPath), value)
+		}
+		return value
+	}
+
+	return ""
+}
+
+// loadPolicyChoice reads a [policy] setting that must be one of choices, with the same
+// precedence as loadPolicyInt. Values are compared case-insensitively; an unknown value
+// is logged and skipped.
+func loadPolicyChoice(envName, iniKey string, choices []string, fallback string) string {
+	match := func(raw string) (string, bool) {
+		value := strings.ToLower(strings.Trim(strings.TrimSpace(raw), `"'`))
+		for _, choice := range choices {
+			if value == choice {
+				return choice, true
+			}
+		}
+		return "", false
+	}
+
+	if raw := strings.TrimSpace(os.Getenv(envName)); raw != "" {
+		if value, ok := match(raw); ok {
+			return value
+		}
+		log.Printf("Invalid %s: %q is not one of %s (using fallback settings)", envName, raw, strings.Join(choices, ", "))
+	}
+
+	for _, configPath := range getConfigINIPaths() {
+		raw, line, found, err := parsePolicyValueFromConfigINI(configPath, iniKey)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			log.Printf("Unable to parse %s from %s: %v", iniKey, configPath, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if value, ok := match(raw); ok {
+			return value
+		}
+		log.Printf("Invalid %s at %s:%d: %q is not one of %s", iniKey, configPath, line, raw, strings.Join(choices, ", "))
+	}
+
+	return fallback
+}
+
 // LoadConfiguredPolicyThresholds returns policy values from config.ini only
 // (without env-var overrides). Missing keys use built-in defaults.
 func LoadConfiguredPolicyThresholds() (int, int) {
@@ -448,6 +582,132 @@ func parseDeactivatedDeletionDaysValue(raw string) (int, bool, error) {
 	return days, true, nil
 }
 
+func parsePolicyIntFromConfigINI(configPath, key string, minValue, maxValue int) (int, bool, error) {
+	raw, lineNumber, found, err := parsePolicyValueFromConfigINI(configPath, key)
+	if err != nil || !found {
+		return 0, false, err
+	}
+
+	parsed, _, err := parsePolicyIntValue(raw, minValue, maxValue)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s on line %d: %w", key, lineNumber, err)
+	}
+
+	return parsed, true, nil
+}
+
+// parsePolicyValueFromConfigINI returns the raw value of a [policy] key and its line number.
+func parsePolicyValueFromConfigINI(configPath, key string) (string, int, bool, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer file.Close()
+
+	inPolicySection := false
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if section, ok := parseINISection(line); ok {
+			inPolicySection = strings.ToLower(section) == "policy"
+			continue
+		}
+
+		if !inPolicySection {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			return "", 0, false, fmt.Errorf("invalid policy key/value on line %d", lineNumber)
+		}
+
+		if strings.ToLower(strings.TrimSpace(name)) != key {
+			continue
+		}
+
+		return value, lineNumber, true, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", 0, false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return "", 0, false, nil
+}
+
+// parseINISectionFromConfigINI returns the key/value pairs of one config.ini section with
+// lower-cased keys. found is false when the file has no such section.
+func parseINISectionFromConfigINI(configPath, section string) (map[string]string, bool, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	found := false
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if name, ok := parseINISection(line); ok {
+			inSection = strings.EqualFold(name, section)
+			found = found || inSection
+			continue
+		}
+
+		if !inSection {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, false, fmt.Errorf("invalid %s key/value on line %d", section, lineNumber)
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return values, found, nil
+}
+
+func parsePolicyIntValue(raw string, minValue, maxValue int) (int, bool, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return 0, false, nil
+	}
+
+	value = strings.Trim(value, `"'`)
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, true, fmt.Errorf("must be an integer")
+	}
+
+	if parsed < minValue || parsed > maxValue {
+		return 0, true, fmt.Errorf("must be between %d and %d", minValue, maxValue)
+	}
+
+	return parsed, true, nil
+}
+
 func getUserAppConfigPath() (string, error) {
 	if getRuntimeMode() == runtimeModeDevelopment {
 		cwd, err := os.Getwd()
@@ -533,9 +793,11 @@ func LoadAppSettings() AppConfig {
 	}
 
 	config := AppConfig{
-		LockMode:    false,
-		ComputerLab: fileConfig.ComputerLab,
-		PCNumber:    fileConfig.PCNumber,
+		LockMode:           false,
+		ComputerLab:        fileConfig.ComputerLab,
+		PCNumber:           fileConfig.PCNumber,
+		DatabaseConfigured: fileConfig.DatabaseConfigured,
+		legacyPINHash:      strings.TrimSpace(fileConfig.MaintenancePINHash),
 	}
 
 	if fileConfig.LockMode != nil {
@@ -694,21 +956,8 @@ func parseDatabaseConfigINI(configPath string) (DBConfig, error) {
 			return DBConfig{}, fmt.Errorf("invalid key/value on line %d", lineNumber)
 		}
 
-		key = strings.ToLower(strings.TrimSpace(key))
-		value = strings.TrimSpace(value)
-		value = strings.Trim(value, `"'`)
-
-		switch key {
-		case "host":
-			config.Host = value
-		case "port":
-			config.Port = value
-		case "dbname":
-			config.DBName = value
-		case "username":
-			config.Username = value
-		case "password":
-			config.Password = value
+		if err := config.applyINIKey(key, value); err != nil {
+			return DBConfig{}, fmt.Errorf("%w on line %d", err, lineNumber)
 		}
 	}
 
@@ -720,6 +969,11 @@ func parseDatabaseConfigINI(configPath string) (DBConfig, error) {
 		return DBConfig{}, fmt.Errorf("missing [database] section")
 	}
 
+	config.sourcePath = configPath
+	if err := config.revealPassword(); err != nil {
+		return DBConfig{}, err
+	}
+
 	if err := validateDatabaseConfig(config); err != nil {
 		return DBConfig{}, err
 	}
@@ -765,21 +1019,8 @@ func parseDatabaseConfigINIOptional(configPath string) (DBConfig, bool, error) {
 			return DBConfig{}, false, fmt.Errorf("invalid key/value on line %d", lineNumber)
 		}
 
-		key = strings.ToLower(strings.TrimSpace(key))
-		value = strings.TrimSpace(value)
-		value = strings.Trim(value, `"'`)
-
-		switch key {
-		case "host":
-			config.Host = value
-		case "port":
-			config.Port = value
-		case "dbname":
-			config.DBName = value
-		case "username":
-			config.Username = value
-		case "password":
-			config.Password = value
+		if err := config.applyINIKey(key, value); err != nil {
+			return DBConfig{}, false, fmt.Errorf("%w on line %d", err, lineNumber)
 		}
 	}
 
@@ -787,10 +1028,26 @@ func parseDatabaseConfigINIOptional(configPath string) (DBConfig, bool, error) {
 		return DBConfig{}, false, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	config.sourcePath = configPath
+	if err := config.revealPassword(); err != nil {
+		// The settings form then asks for the password again instead of failing to load.
+		log.Printf("Database password in %s cannot be decrypted: %v", configPath, err)
+		config.Password = ""
+	}
+
 	return config, foundDatabaseSection, nil
 }
 
 func validateDatabaseConfig(config DBConfig) error {
+	driver, err := storage.NormalizeDriver(config.Driver)
+	if err != nil {
+		return fmt.Errorf("database.driver: %w", err)
+	}
+	if driver == storage.DriverSQLite {
+		// path= is optional; resolveSQLitePath supplies a default file.
+		return nil
+	}
+
 	missing := make([]string, 0, 5)
 	if strings.TrimSpace(config.Host) == "" {
 		missing = append(missing, "database.host")
@@ -876,37 +1133,117 @@ func LoadDatabaseSettingsDraft() (DBConfig, string, bool, error) {
 	return defaultConfig, "", false, nil
 }
 
-func renderConfigINIContent(config DBConfig, inactivityDays, deletionDays int) string {
+func renderConfigINIContent(config DBConfig, storedPassword string, inactivityDays, deletionDays int) string {
+	return fmt.Sprintf(
+		"%s\n"+
+			"[policy]\n"+
+			"inactivity_deactivation_days=%d\n"+
+			"deactivated_deletion_days=%d\n",
+		renderDatabaseINISection(config, storedPassword),
+		inactivityDays,
+		deletionDays,
+	)
+}
+
+// renderDatabaseINISection renders [database] with storedPassword (normally the sealed
+// form of config.Password) as the password value. TLS and key-file settings are only
+// written when they are set.
+func renderDatabaseINISection(config DBConfig, storedPassword string) string {
+	driver, err := storage.NormalizeDriver(config.Driver)
+	if err != nil {
+		driver = storage.DriverMySQL
+	}
+	sqlitePath := ""
+	if driver == storage.DriverSQLite {
+		sqlitePath = fmt.Sprintf("path=%s\n", config.Path)
+	}
+
+	var optional strings.Builder
+	if config.PasswordKeyFile != "" {
+		fmt.Fprintf(&optional, "key_file=%s\n", config.PasswordKeyFile)
+	}
+	if config.TLS {
+		optional.WriteString("tls=true\n")
+		for _, setting := range [][2]string{
+			{"tls_ca_file", config.TLSCAFile},
+			{"tls_cert_file", config.TLSCertFile},
+			{"tls_key_file", config.TLSKeyFile},
+			{"tls_server_name", config.TLSServerName},
+		} {
+			if setting[1] != "" {
+				fmt.Fprintf(&optional, "%s=%s\n", setting[0], setting[1])
+			}
+		}
+		if config.TLSSkipNameVerify {
+			optional.WriteString("tls_verify_server_name=false\n")
+		}
+	}
+
 	return fmt.Sprintf(
 		"[database]\n"+
+			"driver=%s\n"+
+			"%s"+
 			"host=%s\n"+
 			"port=%s\n"+
 			"dbname=%s\n"+
 			"username=%s\n"+
-			"password=%s\n\n"+
-			"[policy]\n"+
-			"inactivity_deactivation_days=%d\n"+
-			"deactivated_deletion_days=%d\n",
+			"password=%s\n"+
+			"%s",
+		driver,
+		sqlitePath,
 		config.Host,
 		config.Port,
 		config.DBName,
 		config.Username,
-		config.Password,
-		inactivityDays,
-		deletionDays,
+		storedPassword,
+		optional.String(),
 	)
 }
 
+// replaceINISection swaps one section of an INI file for body (which includes the
+// section header), keeping every other section and comment. The section is added at
+// the top when the file does not have it.
+func replaceINISection(content, section, body string) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	bodyLines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+
+	result := make([]string, 0, len(lines)+len(bodyLines))
+	replaced := false
+	skipping := false
+	for _, line := range lines {
+		if name, ok := parseINISection(line); ok {
+			skipping = strings.EqualFold(name, section)
+			if skipping {
+				if !replaced {
+					result = append(result, bodyLines...)
+					result = append(result, "")
+					replaced = true
+				}
+				continue
+			}
+		}
+		if !skipping {
+			result = append(result, line)
+		}
+	}
+
+	if !replaced {
+		result = append(append(bodyLines, ""), result...)
+	}
+	return strings.Join(result, "\n")
+}
+
 // SaveDatabaseSettings persists database settings to the active config.ini write target.
 // Development writes to project config.ini; production writes to user config directory.
 func SaveDatabaseSettings(config DBConfig) (string, error) {
-	toSave := DBConfig{
-		Host:     strings.TrimSpace(config.Host),
-		Port:     strings.TrimSpace(config.Port),
-		DBName:   strings.TrimSpace(config.DBName),
-		Username: strings.TrimSpace(config.Username),
-		Password: strings.TrimSpace(config.Password),
-	}
+	toSave := config
+	toSave.Driver = strings.TrimSpace(config.Driver)
+	toSave.Path = strings.TrimSpace(config.Path)
+	toSave.Host = strings.TrimSpace(config.Host)
+	toSave.Port = strings.TrimSpace(config.Port)
+	toSave.DBName = strings.TrimSpace(config.DBName)
+	toSave.Username = strings.TrimSpace(config.Username)
+	toSave.Password = strings.TrimSpace(config.Password)
 
 	if err := validateDatabaseConfig(toSave); err != nil {
 		return "", err
@@ -916,20 +1253,60 @@ func SaveDatabaseSettings(config DBConfig) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	toSave.sourcePath = writePath
 
 	if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
 		return "", fmt.Errorf("failed to create config directory: %w", err)
 	}
+	if err := writeDatabaseSettings(writePath, toSave); err != nil {
+		return "", err
+	}
+
+	log.Printf("Database settings saved to %s", writePath)
+	return writePath, nil
+}
+
+// writeDatabaseSettings writes config's [database] section to path with the password
+// sealed, keeping every other section of an existing file.
+func writeDatabaseSettings(path string, config DBConfig) error {
+	storedPassword := config.Password
+	if config.Password != "" {
+		sealed, err := sealDatabasePassword(config)
+		if err != nil {
+			return err
+		}
+		storedPassword = sealed
+	}
 
 	inactivityDays, deletionDays := LoadConfiguredPolicyThresholds()
-	content := renderConfigINIContent(toSave, inactivityDays, deletionDays)
+	content := renderConfigINIContent(config, storedPassword, inactivityDays, deletionDays)
+	if existing, err := os.ReadFile(path); err == nil {
+		// Keep [policy], [ldap] and any other settings; only [database] is rewritten.
+		content = replaceINISection(string(existing), "database", renderDatabaseINISection(config, storedPassword))
+	}
 
-	if err := os.WriteFile(writePath, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("failed to write database settings: %w", err)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write database settings: %w", err)
 	}
+	return nil
+}
 
-	log.Printf("Database settings saved to %s", writePath)
-	return writePath, nil
+// resolveSQLitePath returns the database file for driver=sqlite. Relative paths are
+// taken from the app settings directory (project root in development, %APPDATA% when installed).
+func resolveSQLitePath(config DBConfig) (string, error) {
+	path := strings.TrimSpace(config.Path)
+	if path == "" {
+		path = storage.DefaultSQLiteFile
+	}
+	if path == ":memory:" || strings.HasPrefix(path, "file:") || filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	settingsPath, err := getUserAppConfigPath()
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve sqlite database path: %w", err)
+	}
+	return filepath.Join(filepath.Dir(settingsPath), path), nil
 }
 
 // InitDatabase initializes and returns a database connection
@@ -939,23 +1316,37 @@ func InitDatabase() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to load database settings: %w", err)
 	}
 
+	driver, err := storage.NormalizeDriver(config.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if driver == storage.DriverSQLite {
+		return initSQLiteDatabase(config)
+	}
+
 	log.Printf("Attempting to connect to database:")
 	log.Printf("   Host: %s", config.Host)
 	log.Printf("   Port: %s", config.Port)
 	log.Printf("   Database: %s", config.DBName)
 	log.Printf("   Username: %s", config.Username)
 
+	tlsConfigName := ""
+	if config.TLS {
+		tlsConfig, err := buildMySQLTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := storage.RegisterMySQLTLS(tlsConfig); err != nil {
+			return nil, fmt.Errorf("failed to register database TLS settings: %w", err)
+		}
+		tlsConfigName = storage.MySQLTLSConfigName
+		log.Printf("   TLS: on (server name %s)", tlsConfig.ServerName)
+	}
+
 	// MySQL DSN format for local XAMPP/MySQL usage.
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&loc=Local&multiStatements=true",
-		config.Username,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-	)
+	dsn := storage.MySQLDSN(config.Username, config.Password, config.Host, config.Port, config.DBName, tlsConfigName)
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := storage.OpenMySQL(dsn)
 	if err != nil {
 		log.Printf("Failed to open database: %v", err)
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -973,6 +1364,29 @@ func InitDatabase() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	log.Println("Database connection established successfully")
+	protectDatabasePassword(config)
+	return db, nil
+}
+
+// initSQLiteDatabase opens the embedded database file used for single-PC installs and demos.
+func initSQLiteDatabase(config DBConfig) (*sql.DB, error) {
+	path, err := resolveSQLitePath(config)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Opening embedded SQLite database: %s", path)
+
+	db, err := storage.OpenSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+
 	log.Println("Database connection established successfully")
 	return db, nil
 }
@@ -0,0 +1,356 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ==============================================================================
+// DATABASE CONNECTION SECURITY
+// ==============================================================================
+//
+// The MySQL password in config.ini is stored sealed with AES-GCM so a copy of
+// config.ini alone does not give access to the database. The key comes from one of:
+//   - the DB_CONFIG_PASSPHRASE environment variable, when set (the same value can be
+//     given to every lab PC so one sealed config.ini can be shared), or
+//   - a machine key file, created with random contents the first time a password is
+//     sealed on the PC (key_file= in [database], or database.key next to the app settings).
+// A sealed value looks like enc:<source>:<salt>:<nonce+ciphertext> and records which
+// source sealed it. Plaintext passwords still load, and are sealed in place after the
+// first successful connection of an installed app.
+//
+// With tls=true the MySQL connection uses TLS, verifying the server certificate against
+// tls_ca_file (or the system roots) and, unless tls_verify_server_name=false, the host
+// name (or tls_server_name). tls_cert_file and tls_key_file add a client certificate.
+
+const (
+	sealedDatabasePasswordPrefix = "enc:"
+	databaseKeySourcePassphrase  = "passphrase"
+	databaseKeySourceKeyFile     = "keyfile"
+	databasePassphraseEnv        = "DB_CONFIG_PASSPHRASE"
+	defaultDatabaseKeyFile       = "database.key"
+	databaseKeySaltSize          = 16
+)
+
+// applyINIKey sets the [database] setting key from config.ini.
+func (c *DBConfig) applyINIKey(key, value string) error {
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+	value = strings.Trim(value, `"'`)
+
+	parseBool := func() (bool, error) {
+		if value == "" {
+			return false, nil
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("database.%s must be true or false", key)
+		}
+		return parsed, nil
+	}
+
+	var err error
+	switch key {
+	case "driver":
+		c.Driver = value
+	case "path":
+		c.Path = value
+	case "host":
+		c.Host = value
+	case "port":
+		c.Port = value
+	case "dbname":
+		c.DBName = value
+	case "username":
+		c.Username = value
+	case "password":
+		c.Password = value
+	case "key_file":
+		c.PasswordKeyFile = value
+	case "tls":
+		c.TLS, err = parseBool()
+	case "tls_ca_file":
+		c.TLSCAFile = value
+	case "tls_cert_file":
+		c.TLSCertFile = value
+	case "tls_key_file":
+		c.TLSKeyFile = value
+	case "tls_server_name":
+		c.TLSServerName = value
+	case "tls_verify_server_name":
+		var verify bool
+		verify, err = parseBool()
+		c.TLSSkipNameVerify = value != "" && !verify
+	}
+	return err
+}
+
+// resolvePath resolves a file setting relative to the config.ini it came from.
+func (c DBConfig) resolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) || c.sourcePath == "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.sourcePath), path)
+}
+
+// revealPassword replaces a sealed password read from config.ini with its plaintext.
+func (c *DBConfig) revealPassword() error {
+	if !strings.HasPrefix(c.Password, sealedDatabasePasswordPrefix) {
+		return nil
+	}
+	plain, err := openDatabasePassword(*c, c.Password)
+	if err != nil {
+		return fmt.Errorf("database.password: %w", err)
+	}
+	c.Password = plain
+	c.passwordSealed = true
+	return nil
+}
+
+// sealDatabasePassword encrypts config.Password for config.ini, with the passphrase
+// when one is set and the machine key file otherwise.
+func sealDatabasePassword(config DBConfig) (string, error) {
+	source := databaseKeySourceKeyFile
+	if strings.TrimSpace(os.Getenv(databasePassphraseEnv)) != "" {
+		source = databaseKeySourcePassphrase
+	}
+
+	salt := make([]byte, databaseKeySaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate database password salt: %w", err)
+	}
+	key, err := databasePasswordKey(config, source, salt, true)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newDatabasePasswordCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate database password nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(config.Password), nil)
+	return fmt.Sprintf("%s%s:%s:%s", sealedDatabasePasswordPrefix, source,
+		base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// openDatabasePassword decrypts a value written by sealDatabasePassword.
+func openDatabasePassword(config DBConfig, value string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(value, sealedDatabasePasswordPrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("encrypted password is malformed")
+	}
+	salt, saltErr := base64.StdEncoding.DecodeString(parts[1])
+	sealed, sealedErr := base64.StdEncoding.DecodeString(parts[2])
+	if saltErr != nil || sealedErr != nil {
+		return "", fmt.Errorf("encrypted password is malformed")
+	}
+
+	key, err := databasePasswordKey(config, parts[0], salt, false)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newDatabasePasswordCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) <= gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted password is malformed")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		if parts[0] == databaseKeySourcePassphrase {
+			return "", fmt.Errorf("cannot decrypt the password - check %s", databasePassphraseEnv)
+		}
+		return "", fmt.Errorf("cannot decrypt the password - this PC's key file does not match; re-enter the database settings")
+	}
+	return string(plain), nil
+}
+
+func newDatabasePasswordCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database password cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database password cipher mode: %w", err)
+	}
+	return gcm, nil
+}
+
+// databasePasswordKey derives the AES key for a sealed password. create lets the
+// key-file source create a missing key file.
+func databasePasswordKey(config DBConfig, source string, salt []byte, create bool) ([]byte, error) {
+	switch source {
+	case databaseKeySourcePassphrase:
+		passphrase := strings.TrimSpace(os.Getenv(databasePassphraseEnv))
+		if passphrase == "" {
+			return nil, fmt.Errorf("the password was encrypted with a passphrase but %s is not set", databasePassphraseEnv)
+		}
+		key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive database password key: %w", err)
+		}
+		return key, nil
+	case databaseKeySourceKeyFile:
+		path, err := databaseKeyFilePath(config)
+		if err != nil {
+			return nil, err
+		}
+		machineKey, err := loadDatabaseKeyFile(path, create)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(append(append([]byte("database-password|"), salt...), machineKey...))
+		return sum[:], nil
+	default:
+		return nil, fmt.Errorf("encrypted password uses an unknown key source %q", source)
+	}
+}
+
+// databaseKeyFilePath returns key_file= from [database], or database.key next to the
+// app settings file.
+func databaseKeyFilePath(config DBConfig) (string, error) {
+	if config.PasswordKeyFile != "" {
+		return config.resolvePath(config.PasswordKeyFile), nil
+	}
+	settingsPath, err := getUserAppConfigPath()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate the database key file: %w", err)
+	}
+	return filepath.Join(filepath.Dir(settingsPath), defaultDatabaseKeyFile), nil
+}
+
+// loadDatabaseKeyFile reads the machine key, creating it with random contents when it
+// is missing and create is set.
+func loadDatabaseKeyFile(path string, create bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return nil, fmt.Errorf("database key file %s is empty", path)
+		}
+		return []byte(key), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read database key file: %w", err)
+	}
+	if !create {
+		return nil, fmt.Errorf("the password was encrypted on another PC (no key file at %s) - re-enter the database settings", path)
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate database key: %w", err)
+	}
+	key := hex.EncodeToString(random)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create database key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write database key file: %w", err)
+	}
+	log.Printf("Created database key file %s", path)
+	return []byte(key), nil
+}
+
+// protectDatabasePassword seals a plaintext password in the config.ini it was read
+// from. Development config files are left alone; failures are only logged because the
+// connection itself already works.
+func protectDatabasePassword(config DBConfig) {
+	if config.passwordSealed || config.Password == "" || config.sourcePath == "" || getRuntimeMode() == runtimeModeDevelopment {
+		return
+	}
+	if err := writeDatabaseSettings(config.sourcePath, config); err != nil {
+		log.Printf("Could not encrypt the database password in %s: %v", config.sourcePath, err)
+		return
+	}
+	log.Printf("Database password in %s is now stored encrypted", config.sourcePath)
+}
+
+// buildMySQLTLSConfig turns the tls_* settings into the config registered with the
+// MySQL driver.
+func buildMySQLTLSConfig(config DBConfig) (*tls.Config, error) {
+	serverName := config.TLSServerName
+	if serverName == "" {
+		serverName = config.Host
+	}
+	tlsConfig := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+
+	if config.TLSCAFile != "" {
+		pem, err := os.ReadFile(config.resolvePath(config.TLSCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read database.tls_ca_file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("database.tls_ca_file has no PEM certificates")
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return nil, fmt.Errorf("database.tls_cert_file and database.tls_key_file must be set together")
+	}
+	if config.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.resolvePath(config.TLSCertFile), config.resolvePath(config.TLSKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.TLSSkipNameVerify {
+		// Still check the chain against the CA; only the host name check is skipped.
+		roots := tlsConfig.RootCAs
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificateChain(rawCerts, roots)
+		}
+	} else if net.ParseIP(serverName) != nil && config.TLSServerName == "" {
+		log.Printf("Database TLS: connecting by IP address %s; the server certificate must list it", serverName)
+	}
+	return tlsConfig, nil
+}
+
+// verifyCertificateChain checks a server's certificate chain against roots (the system
+// roots when nil) without checking its host name.
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("database server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("invalid database server certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatabasePasswordIsStoredEncrypted(t *testing.T) {
+	newTestEnv(t)
+	settings := DBConfig{Driver: "mysql", Host: "db.school.edu", Port: "3306", DBName: "logbookdb", Username: "logbook", Password: "db-secret"}
+
+	path, err := SaveDatabaseSettings(settings)
+	if err != nil {
+		t.Fatalf("SaveDatabaseSettings: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "db-secret") || !strings.Contains(string(content), "password=enc:keyfile:") {
+		t.Fatalf("config.ini does not hold a sealed password:\n%s", content)
+	}
+	loaded, err := LoadDatabaseSettings()
+	if err != nil || loaded.Password != "db-secret" {
+		t.Fatalf("LoadDatabaseSettings = %q, %v", loaded.Password, err)
+	}
+
+	// A copy of config.ini without this PC's key file cannot be used.
+	keyPath, _ := databaseKeyFilePath(loaded)
+	if err := os.Remove(keyPath); err != nil {
+		t.Fatalf("remove key file: %v", err)
+	}
+	if _, err := LoadDatabaseSettings(); err == nil || !strings.Contains(err.Error(), "key file") {
+		t.Errorf("LoadDatabaseSettings without the key file: %v", err)
+	}
+
+	// A passphrase-sealed password needs the same passphrase to load.
+	t.Setenv(databasePassphraseEnv, "lab-wide passphrase")
+	if _, err := SaveDatabaseSettings(settings); err != nil {
+		t.Fatalf("SaveDatabaseSettings with passphrase: %v", err)
+	}
+	if loaded, err := LoadDatabaseSettings(); err != nil || loaded.Password != "db-secret" {
+		t.Fatalf("LoadDatabaseSettings with passphrase = %q, %v", loaded.Password, err)
+	}
+	t.Setenv(databasePassphraseEnv, "")
+	if _, err := LoadDatabaseSettings(); err == nil || !strings.Contains(err.Error(), databasePassphraseEnv) {
+		t.Errorf("LoadDatabaseSettings without the passphrase: %v", err)
+	}
+}
+
+func TestDatabaseSetupSettingsDoNotRevealThePassword(t *testing.T) {
+	e := newTestEnv(t)
+	settings := DBConfig{Driver: "mysql", Host: "db.school.edu", Port: "3306", DBName: "logbookdb", Username: "logbook", Password: "db-secret"}
+	if _, err := SaveDatabaseSettings(settings); err != nil {
+		t.Fatalf("SaveDatabaseSettings: %v", err)
+	}
+
+	// The login page asks for these before anyone has logged in.
+	setup, err := e.app.GetDatabaseSetupSettings()
+	if err != nil {
+		t.Fatalf("GetDatabaseSetupSettings: %v", err)
+	}
+	data, _ := json.Marshal(setup)
+	if strings.Contains(string(data), "db-secret") || !setup.PasswordSet {
+		t.Errorf("setup settings = %s; want password_set and no password", data)
+	}
+
+	// A blank password keeps the saved one only for the same server and account.
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.loginAs("A-0001")
+	for _, change := range [][3]string{
+		{"db.attacker.example", "3306", "logbook"},
+		{"db.school.edu", "3307", "logbook"},
+		{"db.school.edu", "3306", "root"},
+	} {
+		if _, err := e.app.SaveDatabaseSetupSettings(StationAuthorization{}, change[0], change[1], "logbookdb", change[2], ""); err == nil {
+			t.Errorf("saved %v without re-entering the password", change)
+		}
+	}
+	if loaded, err := LoadDatabaseSettings(); err != nil || loaded.Host != "db.school.edu" || loaded.Password != "db-secret" {
+		t.Errorf("database settings after the refused changes = %+v, %v", loaded, err)
+	}
+}
+
+func TestDatabaseTLSSettings(t *testing.T) {
+	newTestEnv(t)
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	writeTestCA(t, caPath)
+
+	settings := DBConfig{Driver: "mysql", Host: "db.school.edu", Port: "3306", DBName: "logbookdb", Username: "logbook", Password: "db-secret",
+		TLS: true, TLSCAFile: caPath, TLSSkipNameVerify: true}
+	if _, err := SaveDatabaseSettings(settings); err != nil {
+		t.Fatalf("SaveDatabaseSettings: %v", err)
+	}
+	loaded, err := LoadDatabaseSettings()
+	if err != nil || !loaded.TLS || loaded.TLSCAFile != caPath || !loaded.TLSSkipNameVerify {
+		t.Fatalf("LoadDatabaseSettings = %+v, %v", loaded, err)
+	}
+
+	tlsConfig, err := buildMySQLTLSConfig(loaded)
+	if err != nil {
+		t.Fatalf("buildMySQLTLSConfig: %v", err)
+	}
+	if tlsConfig.ServerName != "db.school.edu" || tlsConfig.RootCAs == nil || tlsConfig.VerifyPeerCertificate == nil {
+		t.Errorf("TLS config = server %q, roots %v", tlsConfig.ServerName, tlsConfig.RootCAs != nil)
+	}
+
+	if err := os.WriteFile(caPath, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	if _, err := buildMySQLTLSConfig(loaded); err == nil {
+		t.Errorf("buildMySQLTLSConfig accepted a CA file without certificates")
+	}
+	if _, err := buildMySQLTLSConfig(DBConfig{Host: "db", TLS: true, TLSCertFile: "client.pem"}); err == nil {
+		t.Errorf("buildMySQLTLSConfig accepted a client certificate without a key")
+	}
+}
+
+func writeTestCA(t *testing.T, path string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Logbook Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+}
@@ -22,41 +22,11 @@ type Department struct {
 	UpdatedAt      string `json:"updated_at"`
 }
 
-// ensureDepartmentArchiveColumn ensures departments.is_archived exists.
-func (a *App) ensureDepartmentArchiveColumn() error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-
-	var exists int
-	err := a.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM INFORMATION_SCHEMA.COLUMNS
-		WHERE TABLE_SCHEMA = DATABASE()
-		  AND TABLE_NAME = 'departments'
-		  AND COLUMN_NAME = 'is_archived'
-	`).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check is_archived column in departments: %w", err)
-	}
-
-	if exists == 0 {
-		if _, err := a.db.Exec(`ALTER TABLE departments ADD COLUMN is_archived TINYINT(1) NOT NULL DEFAULT 0`); err != nil {
-			return fmt.Errorf("failed to ensure is_archived column in departments: %w", err)
-		}
-	}
-
-	return nil
-}
-
 // GetDepartments returns all departments
 func (a *App) GetDepartments() ([]Department, error) {
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
-	if err := a.ensureDepartmentArchiveColumn(); err != nil {
-		return nil, err
-	}
 
 	rows, err := a.db.Query(`
 		SELECT department_code, department_name, is_active, COALESCE(is_archived, 0), created_at, updated_at
@@ -87,6 +57,10 @@ func (a *App) GetDepartments() ([]Department, error) {
 
 // CreateDepartment creates a new department
 func (a *App) CreateDepartment(departmentCode, departmentName, description string) error {
+	session, err := a.requireRole("CreateDepartment")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -99,7 +73,7 @@ func (a *App) CreateDepartment(departmentCode, departmentName, description strin
 	}
 
 	var duplicateNameCode string
-	err := a.db.QueryRow(
+	err = a.db.QueryRow(
 		`SELECT department_code FROM departments WHERE UPPER(TRIM(department_name)) = UPPER(?) LIMIT 1`,
 		departmentName,
 	).Scan(&duplicateNameCode)
@@ -119,15 +93,18 @@ func (a *App) CreateDepartment(departmentCode, departmentName, description strin
 	}
 
 	log.Printf("Department created successfully: %s", departmentCode)
+	a.audit(session, "create_department", "department", departmentCode, nil,
+		auditValues{"department_code": departmentCode, "department_name": departmentName})
 	return nil
 }
 
 // UpdateDepartment updates an existing department
 func (a *App) UpdateDepartment(oldDepartmentCode, departmentCode, departmentName, description string, isActive bool, isArchived bool) error {
-	if err := a.checkDB(); err != nil {
+	session, err := a.requireRole("UpdateDepartment")
+	if err != nil {
 		return err
 	}
-	if err := a.ensureDepartmentArchiveColumn(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 	_ = description
@@ -139,7 +116,7 @@ func (a *App) UpdateDepartment(oldDepartmentCode, departmentCode, departmentName
 	}
 
 	var duplicateNameCode string
-	err := a.db.QueryRow(
+	err = a.db.QueryRow(
 		`SELECT department_code FROM departments WHERE UPPER(TRIM(department_name)) = UPPER(?) AND department_code <> ? LIMIT 1`,
 		departmentName,
 		oldDepartmentCode,
@@ -155,6 +132,18 @@ func (a *App) UpdateDepartment(oldDepartmentCode, departmentCode, departmentName
 		return fmt.Errorf("archived departments cannot be active. unarchive first")
 	}
 
+	var previous Department
+	err = a.db.QueryRow(
+		`SELECT department_code, department_name, is_active, COALESCE(is_archived, 0) FROM departments WHERE department_code = ?`,
+		oldDepartmentCode,
+	).Scan(&previous.DepartmentCode, &previous.DepartmentName, &previous.IsActive, &previous.IsArchived)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("department not found or already deleted")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read department: %w", err)
+	}
+
 	result, err := a.db.Exec(
 		`UPDATE departments SET department_code = ?, department_name = ?, is_active = ?, is_archived = ? WHERE department_code = ?`,
 		departmentCode, departmentName, isActive, isArchived, oldDepartmentCode,
@@ -169,22 +158,26 @@ func (a *App) UpdateDepartment(oldDepartmentCode, departmentCode, departmentName
 	}
 
 	log.Printf("Department updated successfully: %s -> %s", oldDepartmentCode, departmentCode)
+	a.audit(session, "update_department", "department", departmentCode,
+		auditValues{"department_code": previous.DepartmentCode, "department_name": previous.DepartmentName, "is_active": previous.IsActive, "is_archived": previous.IsArchived},
+		auditValues{"department_code": departmentCode, "department_name": departmentName, "is_active": isActive, "is_archived": isArchived})
 	return nil
 }
 
 // ArchiveDepartment marks a department as archived.
 
 func (a *App) ArchiveDepartment(departmentCode string) error {
-	if err := a.checkDB(); err != nil {
+	session, err := a.requireRole("ArchiveDepartment")
+	if err != nil {
 		return err
 	}
-	if err := a.ensureDepartmentArchiveColumn(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
 	var isActive bool
 	var isArchived bool
-	err := a.db.QueryRow(`SELECT is_active, COALESCE(is_archived, 0) FROM departments WHERE department_code = ?`, departmentCode).Scan(&isActive, &isArchived)
+	err = a.db.QueryRow(`SELECT is_active, COALESCE(is_archived, 0) FROM departments WHERE department_code = ?`, departmentCode).Scan(&isActive, &isArchived)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("department not found")
@@ -206,21 +199,24 @@ func (a *App) ArchiveDepartment(departmentCode string) error {
 	}
 
 	log.Printf("Department archived successfully: %s", departmentCode)
+	a.audit(session, "archive_department", "department", departmentCode,
+		auditValues{"is_archived": false}, auditValues{"is_archived": true})
 	return nil
 }
 
 // UnarchiveDepartment clears a department archive flag.
 // Keeps the current active/inactive state; activation is a separate step.
 func (a *App) UnarchiveDepartment(departmentCode string) error {
-	if err := a.checkDB(); err != nil {
+	session, err := a.requireRole("UnarchiveDepartment")
+	if err != nil {
 		return err
 	}
-	if err := a.ensureDepartmentArchiveColumn(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
 	var isArchived bool
-	err := a.db.QueryRow(`SELECT COALESCE(is_archived, 0) FROM departments WHERE department_code = ?`, departmentCode).Scan(&isArchived)
+	err = a.db.QueryRow(`SELECT COALESCE(is_archived, 0) FROM departments WHERE department_code = ?`, departmentCode).Scan(&isArchived)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("department not found")
@@ -239,11 +235,17 @@ func (a *App) UnarchiveDepartment(departmentCode string) error {
 	}
 
 	log.Printf("Department unarchived successfully: %s", departmentCode)
+	a.audit(session, "unarchive_department", "department", departmentCode,
+		auditValues{"is_archived": true}, auditValues{"is_archived": false})
 	return nil
 }
 
 // DeleteDepartment permanently deletes a department.
 func (a *App) DeleteDepartment(departmentCode string) error {
+	session, err := a.requireRole("DeleteDepartment")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -265,13 +267,14 @@ func (a *App) DeleteDepartment(departmentCode string) error {
 		}
 	}()
 
-	var exists int
+	var departmentName string
+	var isActive, isArchived bool
 	err = tx.QueryRow(`
-		SELECT 1
+		SELECT department_name, is_active, COALESCE(is_archived, 0)
 		FROM departments
 		WHERE department_code = ?
 		LIMIT 1
-	`, departmentCode).Scan(&exists)
+	`, departmentCode).Scan(&departmentName, &isActive, &isArchived)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("department not found")
 	}
@@ -314,6 +317,11 @@ func (a *App) DeleteDepartment(departmentCode string) error {
 		return fmt.Errorf("department not found")
 	}
 
+	if err := a.recordAuditEvent(tx, session, "delete_department", "department", departmentCode,
+		auditValues{"department_code": departmentCode, "department_name": departmentName, "is_active": isActive, "is_archived": isArchived}, nil); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
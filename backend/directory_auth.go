@@ -0,0 +1,479 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ==============================================================================
+// IDENTITY SOURCES
+// ==============================================================================
+//
+// Login asks each configured authenticator in turn whether it accepts a username and
+// password. The local authenticator checks users.password; the LDAP authenticator binds
+// to the school directory configured in the [ldap] section of config.ini. The first
+// successful directory login provisions the users/teachers (or admins) rows, or links an
+// existing account with the same username, and the account's role comes from the
+// directory groups it belongs to. Directory accounts keep an unusable local password, so
+// their password is only ever checked by the directory.
+
+const (
+	authSourceLocal = "local"
+	authSourceLDAP  = "ldap"
+
+	defaultLDAPUserFilter   = "(uid=%s)"
+	defaultLDAPTimeout      = 10 * time.Second
+	maxLDAPTimeoutSeconds   = 120
+	defaultLDAPGroupAttr    = "memberOf"
+	defaultLDAPFirstAttr    = "givenName"
+	defaultLDAPLastAttr     = "sn"
+	defaultLDAPEmailAttr    = "mail"
+	defaultLDAPEmployeeAttr = "employeeNumber"
+)
+
+// errInvalidCredentials is returned by an authenticator that does not accept the
+// username and password.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// authenticator checks a username and password against one identity source.
+type authenticator interface {
+	// accepts reports whether this source should check the given local account.
+	accepts(account localAccount) bool
+	// authenticate returns errInvalidCredentials when the source rejects the login.
+	authenticate(username, password string) (*authIdentity, error)
+}
+
+// localAccount is what Login knows about a username before checking the password.
+type localAccount struct {
+	found      bool
+	authSource string
+	role       string
+}
+
+// authIdentity is a verified login. Directory logins also carry the profile used to
+// provision the local account.
+type authIdentity struct {
+	Source     string
+	ExternalID string
+	Role       string
+	FirstName  string
+	LastName   string
+	Email      string
+	EmployeeID string
+}
+
+// LDAPConfig is the [ldap] section of config.ini.
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	AdminGroups        []string
+	TeacherGroups      []string
+	GroupAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	EmailAttribute     string
+	EmployeeIDAttr     string
+	Timeout            time.Duration
+}
+
+// loadLDAPConfig reads the [ldap] section from the first config.ini that has one. ok is
+// false when directory login is not configured or is turned off with enabled=false.
+func loadLDAPConfig() (LDAPConfig, bool) {
+	for _, configPath := range getConfigINIPaths() {
+		values, found, err := parseINISectionFromConfigINI(configPath, "ldap")
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Unable to parse [ldap] from %s: %v", configPath, err)
+			}
+			continue
+		}
+		if !found {
+			continue
+		}
+		if enabled, err := strconv.ParseBool(stringOrDefault(values["enabled"], "true")); err != nil || !enabled {
+			return LDAPConfig{}, false
+		}
+
+		config := LDAPConfig{
+			URL:                values["url"],
+			StartTLS:           parseINIBool(values["start_tls"]),
+			InsecureSkipVerify: parseINIBool(values["insecure_skip_verify"]),
+			BindDN:             values["bind_dn"],
+			BindPassword:       values["bind_password"],
+			BaseDN:             values["base_dn"],
+			UserFilter:         stringOrDefault(values["user_filter"], defaultLDAPUserFilter),
+			AdminGroups:        splitLDAPGroups(values["admin_groups"]),
+			TeacherGroups:      splitLDAPGroups(values["teacher_groups"]),
+			GroupAttribute:     stringOrDefault(values["group_attribute"], defaultLDAPGroupAttr),
+			FirstNameAttribute: stringOrDefault(values["first_name_attribute"], defaultLDAPFirstAttr),
+			LastNameAttribute:  stringOrDefault(values["last_name_attribute"], defaultLDAPLastAttr),
+			EmailAttribute:     stringOrDefault(values["email_attribute"], defaultLDAPEmailAttr),
+			EmployeeIDAttr:     stringOrDefault(values["employee_id_attribute"], defaultLDAPEmployeeAttr),
+			Timeout:            defaultLDAPTimeout,
+		}
+		if seconds, _, err := parsePolicyIntValue(values["timeout_seconds"], 1, maxLDAPTimeoutSeconds); err == nil && seconds > 0 {
+			config.Timeout = time.Duration(seconds) * time.Second
+		}
+		if config.URL == "" || config.BaseDN == "" {
+			log.Printf("Ignoring [ldap] in %s: url and base_dn are required", configPath)
+			return LDAPConfig{}, false
+		}
+		if !strings.Contains(config.UserFilter, "%s") {
+			log.Printf("Ignoring [ldap] in %s: user_filter must contain %%s for the username", configPath)
+			return LDAPConfig{}, false
+		}
+		return config, true
+	}
+	return LDAPConfig{}, false
+}
+
+func stringOrDefault(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
+
+func parseINIBool(raw string) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(raw))
+	return err == nil && value
+}
+
+// splitLDAPGroups splits a ;-separated list of group DNs (DNs themselves contain commas).
+func splitLDAPGroups(raw string) []string {
+	var groups []string
+	for _, group := range strings.Split(raw, ";") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// authenticators returns the identity sources in the order Login tries them.
+func (a *App) authenticators() []authenticator {
+	sources := []authenticator{localAuthenticator{db: a.db}}
+	if config, ok := loadLDAPConfig(); ok {
+		sources = append(sources, ldapAuthenticator{config: config})
+	}
+	return sources
+}
+
+// authenticateCredentials checks username and password against every identity source that
+// accepts the account. It returns errInvalidCredentials when none of them does.
+func (a *App) authenticateCredentials(username, password string) (*authIdentity, error) {
+	account := localAccount{}
+	err := a.db.QueryRow(`SELECT auth_source, user_type FROM users WHERE username = ?`, username).
+		Scan(&account.authSource, &account.role)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	account.found = err == nil
+
+	for _, source := range a.authenticators() {
+		if !source.accepts(account) {
+			continue
+		}
+		identity, err := source.authenticate(username, password)
+		if errors.Is(err, errInvalidCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return nil, errInvalidCredentials
+}
+
+// ==============================================================================
+// LOCAL AUTHENTICATOR
+// ==============================================================================
+
+type localAuthenticator struct {
+	db *sql.DB
+}
+
+func (localAuthenticator) accepts(account localAccount) bool {
+	return account.found && account.authSource == authSourceLocal
+}
+
+func (l localAuthenticator) authenticate(username, password string) (*authIdentity, error) {
+	var storedPassword string
+	err := l.db.QueryRow(`SELECT password FROM users WHERE username = ? AND auth_source = ?`, username, authSourceLocal).Scan(&storedPassword)
+	if err == sql.ErrNoRows {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password)); err != nil {
+		log.Printf("LOGIN ERROR: Password verification failed for user '%s'", username)
+		return nil, errInvalidCredentials
+	}
+	return &authIdentity{Source: authSourceLocal}, nil
+}
+
+// ==============================================================================
+// LDAP AUTHENTICATOR
+// ==============================================================================
+
+type ldapAuthenticator struct {
+	config LDAPConfig
+}
+
+// accepts lets the directory check new usernames, directory accounts, and local admin
+// and teacher accounts it may link. Student accounts always stay local.
+func (ldapAuthenticator) accepts(account localAccount) bool {
+	return !account.found || account.authSource == authSourceLDAP ||
+		account.role == "admin" || account.role == "teacher"
+}
+
+func (l ldapAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.config.InsecureSkipVerify}
+	conn, err := ldap.DialURL(l.config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: l.config.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(l.config.Timeout)
+
+	if l.config.StartTLS {
+		if parsed, err := url.Parse(l.config.URL); err == nil {
+			tlsConfig.ServerName = parsed.Hostname()
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (l ldapAuthenticator) authenticate(username, password string) (*authIdentity, error) {
+	conn, err := l.dial()
+	if err != nil {
+		log.Printf("LDAP ERROR: cannot reach %s: %v", l.config.URL, err)
+		return nil, fmt.Errorf("the school directory cannot be reached - please try again later")
+	}
+	defer conn.Close()
+
+	if l.config.BindDN != "" {
+		if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+			log.Printf("LDAP ERROR: service bind as %s failed: %v", l.config.BindDN, err)
+			return nil, fmt.Errorf("the school directory rejected the app's service account - contact your administrator")
+		}
+	}
+
+	attributes := []string{l.config.GroupAttribute, l.config.FirstNameAttribute, l.config.LastNameAttribute,
+		l.config.EmailAttribute, l.config.EmployeeIDAttr}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(l.config.Timeout/time.Second), false,
+		strings.ReplaceAll(l.config.UserFilter, "%s", ldap.EscapeFilter(username)), attributes, nil,
+	))
+	if err != nil {
+		log.Printf("LDAP ERROR: search for '%s' failed: %v", username, err)
+		return nil, fmt.Errorf("the school directory search failed - please try again later")
+	}
+	if len(result.Entries) != 1 {
+		log.Printf("LDAP: '%s' matched %d directory entries", username, len(result.Entries))
+		return nil, errInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			log.Printf("LOGIN ERROR: Directory password verification failed for user '%s'", username)
+			return nil, errInvalidCredentials
+		}
+		log.Printf("LDAP ERROR: bind as %s failed: %v", entry.DN, err)
+		return nil, fmt.Errorf("the school directory could not verify your password - please try again later")
+	}
+
+	role := l.mapRole(entry.GetAttributeValues(l.config.GroupAttribute))
+	if role == "" {
+		log.Printf("LOGIN ERROR: Directory user '%s' is not in an admin or teacher group", username)
+		return nil, fmt.Errorf("your directory account is not in a group that may use this app")
+	}
+
+	return &authIdentity{
+		Source:     authSourceLDAP,
+		ExternalID: entry.DN,
+		Role:       role,
+		FirstName:  entry.GetAttributeValue(l.config.FirstNameAttribute),
+		LastName:   entry.GetAttributeValue(l.config.LastNameAttribute),
+		Email:      entry.GetAttributeValue(l.config.EmailAttribute),
+		EmployeeID: entry.GetAttributeValue(l.config.EmployeeIDAttr),
+	}, nil
+}
+
+// mapRole returns "admin" or "teacher" for the first configured group the user is in,
+// checking admin groups first.
+func (l ldapAuthenticator) mapRole(groups []string) string {
+	for _, mapping := range []struct {
+		role   string
+		groups []string
+	}{
+		{"admin", l.config.AdminGroups},
+		{"teacher", l.config.TeacherGroups},
+	} {
+		for _, want := range mapping.groups {
+			for _, group := range groups {
+				if strings.EqualFold(strings.TrimSpace(group), want) {
+					return mapping.role
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ==============================================================================
+// JUST-IN-TIME PROVISIONING
+// ==============================================================================
+
+// unusablePasswordHash returns a bcrypt hash of a random secret, stored for directory
+// accounts so users.password can never be used to log in.
+func unusablePasswordHash() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate placeholder password: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(buf)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash placeholder password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// provisionDirectoryUser makes sure a local account exists for a directory login. A new
+// username gets users and role profile rows; an existing local admin or teacher with the
+// same username and role is linked to the directory entry.
+func (a *App) provisionDirectoryUser(username string, identity *authIdentity) error {
+	var userID int
+	var role, source string
+	var externalID sql.NullString
+	err := a.db.QueryRow(`
+		SELECT id, user_type, auth_source, external_id FROM users WHERE username = ?
+	`, username).Scan(&userID, &role, &source, &externalID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err == nil {
+		if role != identity.Role {
+			log.Printf("LOGIN ERROR: Directory role %s of '%s' does not match account role %s", identity.Role, username, role)
+			return fmt.Errorf("your directory groups do not match this account's role - contact an administrator")
+		}
+		if source == authSourceLDAP && externalID.String == identity.ExternalID {
+			return nil
+		}
+		return a.linkDirectoryUser(userID, username, role, source, identity)
+	}
+
+	placeholder, err := unusablePasswordHash()
+	if err != nil {
+		return err
+	}
+	employeeID := stringOrDefault(identity.EmployeeID, username)
+	firstName := stringOrDefault(identity.FirstName, username)
+	lastName := stringOrDefault(identity.LastName, username)
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO users (username, password, user_type, account_status, auth_source, external_id)
+		VALUES (?, ?, ?, 'active', ?, ?)
+	`, username, placeholder, identity.Role, authSourceLDAP, identity.ExternalID)
+	if err != nil {
+		return fmt.Errorf("failed to create directory account: %w", err)
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to fetch created user id: %w", err)
+	}
+	if err := a.insertRoleSpecificProfile(tx, newID, identity.Role, firstName, "", lastName, employeeID, "", identity.Email, "", ""); err != nil {
+		return err
+	}
+	if err := a.recordAuditEvent(tx, nil, "provision_directory_user", "user", newID, nil, auditValues{
+		"username": username, "role": identity.Role, "external_id": identity.ExternalID,
+		"first_name": firstName, "last_name": lastName, "email": identity.Email,
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit directory account: %w", err)
+	}
+
+	log.Printf("Provisioned %s account %s (ID: %d) from directory entry %s", identity.Role, username, newID, identity.ExternalID)
+	return nil
+}
+
+func (a *App) linkDirectoryUser(userID int, username, role, source string, identity *authIdentity) error {
+	placeholder, err := unusablePasswordHash()
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET auth_source = ?, external_id = ?, password = ?, must_change_password = 0, updated_at = ?
+		WHERE id = ?
+	`, authSourceLDAP, identity.ExternalID, placeholder, a.now(), userID); err != nil {
+		return fmt.Errorf("failed to link directory account: %w", err)
+	}
+	if err := a.recordAuditEvent(tx, nil, "link_directory_user", "user", userID,
+		auditValues{"auth_source": source},
+		auditValues{"auth_source": authSourceLDAP, "external_id": identity.ExternalID, "username": username, "role": role},
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit directory link: %w", err)
+	}
+
+	log.Printf("Linked %s account %s (ID: %d) to directory entry %s", role, username, userID, identity.ExternalID)
+	return nil
+}
+
+// requireLocalPassword refuses password changes for accounts whose password is managed
+// by the directory.
+func (a *App) requireLocalPassword(userID int) error {
+	var source string
+	if err := a.db.QueryRow(`SELECT auth_source FROM users WHERE id = ?`, userID).Scan(&source); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to check account: %w", err)
+	}
+	if source != authSourceLocal {
+		return fmt.Errorf("this account's password is managed by the school directory")
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package backend
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapStandIn is a minimal in-process LDAP server: it answers simple binds and
+// single-user searches for (uid=...) filters, which is all the LDAP authenticator uses.
+type ldapStandIn struct {
+	listener net.Listener
+	users    map[string]ldapStandInUser // by uid
+}
+
+type ldapStandInUser struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+const (
+	standInBaseDN     = "ou=people,dc=school,dc=edu"
+	standInServiceDN  = "cn=logbook,dc=school,dc=edu"
+	standInServicePW  = "service-secret"
+	standInFacultyDN  = "cn=faculty,ou=groups,dc=school,dc=edu"
+	standInITAdminsDN = "cn=it-admins,ou=groups,dc=school,dc=edu"
+)
+
+func newLDAPStandIn(t *testing.T) *ldapStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &ldapStandIn{listener: listener, users: map[string]ldapStandInUser{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStandIn) addUser(uid, password string, groups []string, attrs map[string]string) {
+	entry := ldapStandInUser{
+		dn:       fmt.Sprintf("uid=%s,%s", uid, standInBaseDN),
+		password: password,
+		attrs:    map[string][]string{"memberOf": groups},
+	}
+	for name, value := range attrs {
+		entry.attrs[name] = []string{value}
+	}
+	s.users[uid] = entry
+}
+
+func (s *ldapStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			code := int64(ldap.LDAPResultInvalidCredentials)
+			if password != "" && (dn == standInServiceDN && password == standInServicePW || s.passwordFor(dn) == password) {
+				code = ldap.LDAPResultSuccess
+			}
+			conn.Write(ldapStandInResult(messageID, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for uid, user := range s.users {
+				if strings.EqualFold(filter, fmt.Sprintf("(uid=%s)", uid)) {
+					conn.Write(ldapStandInEntry(messageID, user).Bytes())
+				}
+			}
+			conn.Write(ldapStandInResult(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *ldapStandIn) passwordFor(dn string) string {
+	for _, user := range s.users {
+		if strings.EqualFold(user.dn, dn) {
+			return user.password
+		}
+	}
+	return ""
+}
+
+func ldapStandInEnvelope(messageID int64, op *ber.Packet) *ber.Packet {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, ""))
+	envelope.AppendChild(op)
+	return envelope
+}
+
+func ldapStandInResult(messageID int64, tag ber.Tag, code int64) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return ldapStandInEnvelope(messageID, op)
+}
+
+func ldapStandInEntry(messageID int64, user ldapStandInUser) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, user.dn, ""))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for name, values := range user.attrs {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return ldapStandInEnvelope(messageID, op)
+}
+
+// writeLDAPConfig points the app's config.ini at the stand-in server.
+func (s *ldapStandIn) writeLDAPConfig(t *testing.T) {
+	t.Helper()
+	configPath, err := getUserConfigINIPath()
+	if err != nil {
+		t.Fatalf("config path: %v", err)
+	}
+	content := fmt.Sprintf("[ldap]\nurl=ldap://%s\nbind_dn=%s\nbind_password=%s\nbase_dn=%s\n"+
+		"admin_groups=%s\nteacher_groups=%s\n",
+		s.listener.Addr(), standInServiceDN, standInServicePW, standInBaseDN, standInITAdminsDN, standInFacultyDN)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write config.ini: %v", err)
+	}
+}
+
+func TestDirectoryLoginProvisionsAndLinksAccounts(t *testing.T) {
+	e := newTestEnv(t)
+	directory := newLDAPStandIn(t)
+	directory.writeLDAPConfig(t)
+	directory.addUser("jdoe", "Dir-Passw0rd", []string{standInFacultyDN},
+		map[string]string{"givenName": "Jane", "sn": "Doe", "mail": "jdoe@school.edu", "employeeNumber": "T-7001"})
+	directory.addUser("T-0001", "Dir-Passw0rd", []string{standInFacultyDN}, nil)
+	directory.addUser("visitor", "Dir-Passw0rd", []string{"cn=guests,ou=groups,dc=school,dc=edu"}, nil)
+	directory.addUser("2024-00001", "Dir-Passw0rd", []string{standInFacultyDN}, nil)
+	e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	// A new directory user gets a teacher account on first login.
+	if _, err := e.app.Login("jdoe", "wrong-password"); err == nil {
+		t.Fatalf("a wrong directory password was accepted")
+	}
+	user, err := e.app.Login("jdoe", "Dir-Passw0rd")
+	if err != nil {
+		t.Fatalf("Login jdoe: %v", err)
+	}
+	if user.Role != "teacher" || user.AuthSource != authSourceLDAP || user.EmployeeID == nil || *user.EmployeeID != "T-7001" {
+		t.Fatalf("provisioned user = %+v", user)
+	}
+	if email := e.queryString(`SELECT email FROM teachers WHERE id = ?`, user.ID); email != "jdoe@school.edu" {
+		t.Errorf("teacher email = %q", email)
+	}
+	if err := e.app.Logout(user.ID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if again, err := e.app.Login("jdoe", "Dir-Passw0rd"); err != nil || again.ID != user.ID {
+		t.Fatalf("second directory login = %+v, %v", again, err)
+	}
+	if err := e.app.ChangePassword("jdoe", "Dir-Passw0rd", "N3w-Passw0rd!"); err == nil {
+		t.Errorf("a directory account changed its password locally")
+	}
+
+	// An existing local teacher is linked, after which the local password stops working.
+	linked, err := e.app.Login("T-0001", "Dir-Passw0rd")
+	if err != nil {
+		t.Fatalf("Login T-0001 with the directory password: %v", err)
+	}
+	if linked.AuthSource != authSourceLDAP {
+		t.Fatalf("T-0001 was not linked: %+v", linked)
+	}
+	if _, err := e.app.Login("T-0001", testPassword); err == nil {
+		t.Errorf("the local password still works after linking")
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM audit_events WHERE action IN ('provision_directory_user', 'link_directory_user')`); n != 2 {
+		t.Errorf("directory audit events = %d, want 2", n)
+	}
+
+	// Users outside the mapped groups and local student accounts are not let in.
+	if _, err := e.app.Login("visitor", "Dir-Passw0rd"); err == nil {
+		t.Errorf("a directory user outside the mapped groups logged in")
+	}
+	if _, err := e.app.Login("2024-00001", "Dir-Passw0rd"); err == nil {
+		t.Errorf("a student account was taken over by a directory entry")
+	}
+	if user := e.loginAs("2024-00001"); user.AuthSource != authSourceLocal {
+		t.Errorf("student auth source = %q", user.AuthSource)
+	}
+}
+
+func TestSaveDatabaseSettingsKeepsOtherSections(t *testing.T) {
+	newTestEnv(t)
+	directory := newLDAPStandIn(t)
+	directory.writeLDAPConfig(t)
+
+	path, err := SaveDatabaseSettings(DBConfig{Driver: "mysql", Host: "db.school.edu", Port: "3306", DBName: "logbookdb", Username: "logbook", Password: "db-secret"})
+	if err != nil {
+		t.Fatalf("SaveDatabaseSettings: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "host=db.school.edu") {
+		t.Errorf("config.ini has no database settings:\n%s", content)
+	}
+	if config, ok := loadLDAPConfig(); !ok || config.BindDN != standInServiceDN || len(config.TeacherGroups) != 1 {
+		t.Errorf("[ldap] after saving database settings = %+v, %v", config, ok)
+	}
+}
@@ -13,77 +13,11 @@ import (
 // FEEDBACK MANAGEMENT
 // ==============================================================================
 
-func (a *App) ensureFeedbackAdminResolvedAtColumn() error {
-	if a.db == nil {
-		return fmt.Errorf("database not initialized")
-	}
-
-	var exists int
-	err := a.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM INFORMATION_SCHEMA.COLUMNS
-		WHERE TABLE_SCHEMA = DATABASE()
-		  AND TABLE_NAME = 'feedback'
-		  AND COLUMN_NAME = 'admin_resolved_at'
-	`).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check feedback.admin_resolved_at column: %w", err)
-	}
-
-	if exists == 0 {
-		if _, err := a.db.Exec(`ALTER TABLE feedback ADD COLUMN admin_resolved_at DATETIME NULL`); err != nil {
-			return fmt.Errorf("failed to ensure feedback.admin_resolved_at column: %w", err)
-		}
-	}
-
-	return nil
-}
-
-func (a *App) ensureFeedbackNoteColumns() error {
-	if a.db == nil {
-		return fmt.Errorf("database not initialized")
-	}
-
-	hasColumn := func(columnName string) (bool, error) {
-		var exists int
-		err := a.db.QueryRow(`
-			SELECT COUNT(*)
-			FROM INFORMATION_SCHEMA.COLUMNS
-			WHERE TABLE_SCHEMA = DATABASE()
-			  AND TABLE_NAME = 'feedback'
-			  AND COLUMN_NAME = ?
-		`, columnName).Scan(&exists)
-		if err != nil {
-			return false, err
-		}
-		return exists > 0, nil
-	}
-
-	hasAdditionalComments, err := hasColumn("additional_comments")
-	if err != nil {
-		return fmt.Errorf("failed to inspect feedback.additional_comments: %w", err)
-	}
-	if !hasAdditionalComments {
-		if _, err := a.db.Exec(`ALTER TABLE feedback ADD COLUMN additional_comments LONGTEXT NULL`); err != nil {
-			return fmt.Errorf("failed to add feedback.additional_comments column: %w", err)
-		}
-	}
-
-	hasForwardNotes, err := hasColumn("forward_notes")
-	if err != nil {
-		return fmt.Errorf("failed to inspect feedback.forward_notes: %w", err)
-	}
-	if !hasForwardNotes {
-		if _, err := a.db.Exec(`ALTER TABLE feedback ADD COLUMN forward_notes LONGTEXT NULL`); err != nil {
-			return fmt.Errorf("failed to add feedback.forward_notes column: %w", err)
-		}
-	}
-
-	return nil
-}
-
 // GetFeedback returns all forwarded feedback for admin.
 func (a *App) GetFeedback() ([]Feedback, error) {
+	if _, err := a.requireRole("GetFeedback"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -209,6 +143,9 @@ func (a *App) GetFeedback() ([]Feedback, error) {
 
 // GetStudentFeedback returns feedback history for a specific student
 func (a *App) GetStudentFeedback(studentID int) ([]Feedback, error) {
+	if _, err := a.requireActingUser("GetStudentFeedback", studentID); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -336,9 +273,11 @@ func (a *App) resolveFeedbackReporterName(userID int, fallbackName string) strin
 // SaveEquipmentFeedback saves equipment feedback from the current user.
 // optionalPCNumber: if non-empty, the report is for that PC (e.g. "PC-12"); otherwise the configured app station is used.
 func (a *App) SaveEquipmentFeedback(userID int, userName, computerStatus, computerIssue, mouseStatus, mouseIssue, keyboardStatus, keyboardIssue, monitorStatus, monitorIssue, additionalComments, optionalPCNumber string) error {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("SaveEquipmentFeedback", userID); err != nil {
 		return err
 	}
+	// A report made while the server is unreachable is queued with its submission time.
+	dbErr := a.checkDB()
 	if err := ValidatePositiveID(userID, "user ID"); err != nil {
 		return err
 	}
@@ -440,14 +379,26 @@ func (a *App) SaveEquipmentFeedback(userID int, userName, computerStatus, comput
 	// - Always starts as "pending" when feedback is first created.
 	adminStatus := "pending"
 
-	query := `INSERT INTO feedback (reported_by_user_id, pc_number,
-			  equipment_condition, monitor_condition, keyboard_condition, mouse_condition, 
-			  additional_comments, status, admin_status, date_submitted) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())`
+	entry := equipmentFeedbackEntry{
+		ReporterName:       reporterName,
+		PCNumber:           pcNumber,
+		EquipmentCondition: equipmentCondition,
+		MonitorCondition:   monitorCondition,
+		KeyboardCondition:  keyboardCondition,
+		MouseCondition:     mouseCondition,
+		Comments:           combinedComments,
+		Status:             status,
+		AdminStatus:        adminStatus,
+	}
 
-	_, err := a.db.Exec(query, userID, pcNumber,
-		equipmentCondition, monitorCondition, keyboardCondition, mouseCondition, nullString(combinedComments), status, adminStatus)
+	if dbErr != nil {
+		if _, err := a.queueOfflineEvent(dbErr, offlineEventFeedback, userID, entry); err != nil {
+			return err
+		}
+		return nil
+	}
 
+	_, err := insertEquipmentFeedback(a.db, userID, entry, sql.NullTime{})
 	if err != nil {
 		log.Printf("Failed to save equipment feedback: %v", err)
 		return fmt.Errorf("failed to save feedback: %w", err)
@@ -464,8 +415,40 @@ func (a *App) SaveEquipmentFeedback(userID int, userName, computerStatus, comput
 	return nil
 }
 
+// equipmentFeedbackEntry is a prepared feedback row, shared by SaveEquipmentFeedback and the offline queue.
+type equipmentFeedbackEntry struct {
+	ReporterName       string `json:"reporter_name"`
+	PCNumber           string `json:"pc_number"`
+	EquipmentCondition string `json:"equipment_condition"`
+	MonitorCondition   string `json:"monitor_condition"`
+	KeyboardCondition  string `json:"keyboard_condition"`
+	MouseCondition     string `json:"mouse_condition"`
+	Comments           string `json:"comments"`
+	Status             string `json:"status"`
+	AdminStatus        string `json:"admin_status"`
+}
+
+// insertEquipmentFeedback stores a feedback row. submittedAt defaults to NOW() when not set.
+func insertEquipmentFeedback(exec dbExecutor, userID int, entry equipmentFeedbackEntry, submittedAt sql.NullTime) (int64, error) {
+	query := `INSERT INTO feedback (reported_by_user_id, pc_number,
+			  equipment_condition, monitor_condition, keyboard_condition, mouse_condition, 
+			  additional_comments, status, admin_status, date_submitted) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, NOW()))`
+
+	result, err := exec.Exec(query, userID, entry.PCNumber,
+		entry.EquipmentCondition, entry.MonitorCondition, entry.KeyboardCondition, entry.MouseCondition,
+		nullString(entry.Comments), entry.Status, entry.AdminStatus, submittedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
 // GetPendingFeedback returns all pending feedback for working students to review
 func (a *App) GetPendingFeedback() ([]Feedback, error) {
+	if _, err := a.requireRole("GetPendingFeedback"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -545,6 +528,9 @@ func (a *App) GetPendingFeedback() ([]Feedback, error) {
 
 // GetConfirmedFeedback returns feedback that working student has confirmed (issue is true), ready to forward to admin
 func (a *App) GetConfirmedFeedback() ([]Feedback, error) {
+	if _, err := a.requireRole("GetConfirmedFeedback"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -634,6 +620,9 @@ func (a *App) GetConfirmedFeedback() ([]Feedback, error) {
 
 // GetRejectedFeedback returns feedback that working student has rejected.
 func (a *App) GetRejectedFeedback() ([]Feedback, error) {
+	if _, err := a.requireRole("GetRejectedFeedback"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -723,6 +712,9 @@ func (a *App) GetRejectedFeedback() ([]Feedback, error) {
 
 // ConfirmFeedback sets working-student verification: issue confirmed (true) or rejected (not true). Only confirmed can be forwarded.
 func (a *App) ConfirmFeedback(feedbackID int, workingStudentID int, confirmed bool, notes string) error {
+	if _, err := a.requireActingUser("ConfirmFeedback", workingStudentID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -759,6 +751,9 @@ func (a *App) ConfirmFeedback(feedbackID int, workingStudentID int, confirmed bo
 
 // ForwardFeedbackToAdmin forwards feedback from working student to admin. Only feedback with status 'confirmed' can be forwarded.
 func (a *App) ForwardFeedbackToAdmin(feedbackID int, workingStudentID int, notes string) error {
+	if _, err := a.requireActingUser("ForwardFeedbackToAdmin", workingStudentID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -799,6 +794,9 @@ func (a *App) ForwardFeedbackToAdmin(feedbackID int, workingStudentID int, notes
 
 // ForwardMultipleFeedbackToAdmin forwards multiple confirmed feedback items from working student to admin in batch
 func (a *App) ForwardMultipleFeedbackToAdmin(feedbackIDs []int, workingStudentID int, notes string) (int, error) {
+	if _, err := a.requireActingUser("ForwardMultipleFeedbackToAdmin", workingStudentID); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -858,6 +856,9 @@ func (a *App) ForwardMultipleFeedbackToAdmin(feedbackIDs []int, workingStudentID
 // ConfirmAndForwardMultiple confirms and forwards multiple pending feedback items to admin in one step.
 // Use this so working students can batch "confirm & forward" (e.g. no-issue logs) without doing each one by one.
 func (a *App) ConfirmAndForwardMultiple(feedbackIDs []int, workingStudentID int, notes string) (int, error) {
+	if _, err := a.requireActingUser("ConfirmAndForwardMultiple", workingStudentID); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -917,6 +918,9 @@ func (a *App) ConfirmAndForwardMultiple(feedbackIDs []int, workingStudentID int,
 
 // GetFeedbackRangeCount returns the count of forwarded feedback within a date range.
 func (a *App) GetFeedbackRangeCount(startDate, endDate string) (int, error) {
+	if _, err := a.requireRole("GetFeedbackRangeCount"); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -1501,6 +1505,9 @@ func (a *App) getFeedbackByRowRange(fromRow, toRow int) ([]Feedback, error) {
 
 // ExportFeedbackCSVByRowRange exports forwarded feedback within a row range to CSV.
 func (a *App) ExportFeedbackCSVByRowRange(fromRow, toRow int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackCSVByRowRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1521,6 +1528,9 @@ func (a *App) ExportFeedbackCSVByRowRange(fromRow, toRow int, savePath string) (
 
 // ExportFeedbackPDFByRowRange exports forwarded feedback within a row range to PDF.
 func (a *App) ExportFeedbackPDFByRowRange(fromRow, toRow int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackPDFByRowRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1540,6 +1550,9 @@ func (a *App) ExportFeedbackPDFByRowRange(fromRow, toRow int, savePath string) (
 
 // ExportFeedbackDOCXByRowRange exports forwarded feedback within a row range to DOCX.
 func (a *App) ExportFeedbackDOCXByRowRange(fromRow, toRow int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackDOCXByRowRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1566,6 +1579,9 @@ func (a *App) ExportFeedbackDOCXByRowRange(fromRow, toRow int, savePath string)
 // ExportFeedbackCSVByCount exports the latest forwarded feedback to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportFeedbackCSVByCount(count int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackCSVByCount"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1587,6 +1603,9 @@ func (a *App) ExportFeedbackCSVByCount(count int, savePath string) (string, erro
 // ExportFeedbackPDFByCount exports the latest forwarded feedback to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportFeedbackPDFByCount(count int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackPDFByCount"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1607,6 +1626,9 @@ func (a *App) ExportFeedbackPDFByCount(count int, savePath string) (string, erro
 // ExportFeedbackDOCXByCount exports the latest forwarded feedback to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportFeedbackDOCXByCount(count int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackDOCXByCount"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1650,6 +1672,9 @@ func buildArchivedFeedbackExportDocument(date string, feedbacks []Feedback) prin
 // ExportFeedbackCSVByRange exports feedback within a date range to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportFeedbackCSVByRange(startDate, endDate string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackCSVByRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1671,6 +1696,9 @@ func (a *App) ExportFeedbackCSVByRange(startDate, endDate string, savePath strin
 // ExportFeedbackPDFByRange exports feedback within a date range to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportFeedbackPDFByRange(startDate, endDate string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackPDFByRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1691,6 +1719,9 @@ func (a *App) ExportFeedbackPDFByRange(startDate, endDate string, savePath strin
 // ExportFeedbackDOCXByRange exports feedback within a date range to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportFeedbackDOCXByRange(startDate, endDate string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportFeedbackDOCXByRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -1720,6 +1751,9 @@ func (a *App) ExportFeedbackDOCXByRange(startDate, endDate string, savePath stri
 
 // GetArchivedFeedbackByDate returns all archived feedback for a specific date
 func (a *App) GetArchivedFeedbackByDate(date string) ([]Feedback, error) {
+	if _, err := a.requireRole("GetArchivedFeedbackByDate"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -1829,6 +1863,10 @@ func (a *App) GetArchivedFeedbackByDate(date string) ([]Feedback, error) {
 
 // ArchiveFeedbackByDate archives all feedback for a specific date
 func (a *App) ArchiveFeedbackByDate(date string, adminUserID int) (int, error) {
+	session, err := a.requireActingUser("ArchiveFeedbackByDate", adminUserID)
+	if err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -1851,12 +1889,16 @@ func (a *App) ArchiveFeedbackByDate(date string, adminUserID int) (int, error) {
 	}
 
 	log.Printf("%d feedback archived for date %s by admin %d", rowsAffected, date, adminUserID)
+	a.audit(session, "archive_feedback_by_date", "feedback", date, nil, auditValues{"archived_count": rowsAffected})
 	return int(rowsAffected), nil
 }
 
 // ExportArchivedFeedbackSheetCSV exports archived feedback for a specific date to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedFeedbackSheetCSV(date string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportArchivedFeedbackSheetCSV"); err != nil {
+		return "", err
+	}
 	feedbacks, err := a.GetArchivedFeedbackByDate(date)
 	if err != nil {
 		return "", err
@@ -1880,6 +1922,9 @@ func (a *App) ExportArchivedFeedbackSheetCSV(date string, savePath string) (stri
 // ExportArchivedFeedbackSheetPDF exports archived feedback for a specific date to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedFeedbackSheetPDF(date string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportArchivedFeedbackSheetPDF"); err != nil {
+		return "", err
+	}
 	feedbacks, err := a.GetArchivedFeedbackByDate(date)
 	if err != nil {
 		return "", err
@@ -1901,6 +1946,9 @@ func (a *App) ExportArchivedFeedbackSheetPDF(date string, savePath string) (stri
 // ExportArchivedFeedbackSheetDOCX exports archived feedback for a specific date to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedFeedbackSheetDOCX(date string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportArchivedFeedbackSheetDOCX"); err != nil {
+		return "", err
+	}
 	feedbacks, err := a.GetArchivedFeedbackByDate(date)
 	if err != nil {
 		return "", err
@@ -1927,6 +1975,10 @@ func (a *App) ExportArchivedFeedbackSheetDOCX(date string, savePath string) (str
 
 // ArchiveFeedback archives selected feedback by their IDs
 func (a *App) ArchiveFeedback(feedbackIDs []int, adminUserID int) (int, error) {
+	session, err := a.requireActingUser("ArchiveFeedback", adminUserID)
+	if err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -1972,5 +2024,6 @@ func (a *App) ArchiveFeedback(feedbackIDs []int, adminUserID int) (int, error) {
 	}
 
 	log.Printf("%d feedback items archived by admin %d", rowsAffected, adminUserID)
+	a.audit(session, "archive_feedback", "feedback", "", nil, auditValues{"feedback_ids": feedbackIDs, "archived_count": rowsAffected})
 	return int(rowsAffected), nil
 }
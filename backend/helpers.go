@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"digital-logbook-wails-app/backend/storage"
 )
 
 // checkDB validates database connection and attempts reconnection if needed
@@ -27,6 +29,11 @@ func (a *App) checkDB() error {
 	return nil
 }
 
+// dbDriver reports which storage backend the current connection uses ("mysql" or "sqlite").
+func (a *App) dbDriver() string {
+	return storage.DriverOf(a.db)
+}
+
 // reconnectDB attempts to re-establish the database connection
 func (a *App) reconnectDB() error {
 	db, err := InitDatabase()
@@ -36,9 +43,23 @@ func (a *App) reconnectDB() error {
 	}
 	a.db = db
 	log.Println("Database reconnected successfully")
+	a.markDatabaseConfigured()
+	a.syncServerClock()
+	if !a.schemaReady {
+		if err := a.runSchemaMigrations(); err != nil {
+			log.Printf("Failed to apply schema migrations after reconnect: %v", err)
+		}
+	}
 	return nil
 }
 
+// dbExecutor is satisfied by both *sql.DB and *sql.Tx.
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // nullString converts empty string to sql.NullString
 func nullString(s string) sql.NullString {
 	if s == "" {
@@ -0,0 +1,147 @@
+package backend
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ==============================================================================
+// IDLE AUTO-LOGOUT
+// ==============================================================================
+//
+// In lock mode a station logs its user out after idle_logout_minutes without any
+// keyboard, mouse or touch input, so the next person at the PC cannot time in under a
+// session someone walked away from. The frontend reports input with ReportActivity
+// (the heartbeat only proves the window is open, not that anyone is using it). A
+// warning event is sent idle_logout_warning_seconds before the logout, and the login
+// log of an idle logout is closed with logout_reason 'auto_logout'.
+
+const (
+	defaultIdleLogoutMinutes        = 10
+	maxIdleLogoutMinutes            = 240
+	defaultIdleLogoutWarningSeconds = 60
+	maxIdleLogoutWarningSeconds     = 600
+	idleCheckIntervalSeconds        = 5
+
+	logoutReasonManual = "manual"
+	logoutReasonAuto   = "auto_logout"
+
+	// idleWarningEvent carries an IdleLogoutWarning; autoLogoutEvent carries the user ID.
+	idleWarningEvent = "session:idle-warning"
+	autoLogoutEvent  = "session:auto-logout"
+)
+
+// IdleLogoutWarning is the payload of the idle-warning event.
+type IdleLogoutWarning struct {
+	UserID           int `json:"user_id"`
+	SecondsRemaining int `json:"seconds_remaining"`
+}
+
+// loadIdleLogoutPolicy reads the [policy] idle_logout_minutes and
+// idle_logout_warning_seconds settings (or IDLE_LOGOUT_MINUTES and
+// IDLE_LOGOUT_WARNING_SECONDS). A timeout of 0 turns idle logout off.
+func loadIdleLogoutPolicy() (timeout, warning time.Duration) {
+	minutes := loadPolicyInt("IDLE_LOGOUT_MINUTES", "idle_logout_minutes", 0, maxIdleLogoutMinutes, defaultIdleLogoutMinutes)
+	seconds := loadPolicyInt("IDLE_LOGOUT_WARNING_SECONDS", "idle_logout_warning_seconds", 0, maxIdleLogoutWarningSeconds, defaultIdleLogoutWarningSeconds)
+	timeout = time.Duration(minutes) * time.Minute
+	warning = time.Duration(seconds) * time.Second
+	if warning > timeout {
+		warning = timeout
+	}
+	return timeout, warning
+}
+
+// reloadIdleLogoutPolicy re-reads the idle-logout policy into the current session after
+// the policy settings were saved.
+func (a *App) reloadIdleLogoutPolicy() {
+	timeout, warning := loadIdleLogoutPolicy()
+	a.sessionMu.Lock()
+	if a.session != nil {
+		a.session.IdleTimeout = timeout
+		a.session.IdleWarning = warning
+	}
+	a.sessionMu.Unlock()
+}
+
+// ReportActivity records that the logged-in user is using the station. The frontend
+// calls it, throttled, on keyboard, mouse and touch input.
+func (a *App) ReportActivity(userID int) error {
+	session, err := a.requireActingUser("ReportActivity", userID)
+	if err != nil {
+		return err
+	}
+
+	a.sessionMu.Lock()
+	if a.session != nil && a.session.Token == session.Token {
+		a.session.LastActivity = a.now()
+		a.session.IdleWarned = false
+	}
+	a.sessionMu.Unlock()
+	return nil
+}
+
+func (a *App) startIdleLogoutLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(idleCheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.checkIdleSession()
+		}
+	}
+}
+
+// checkIdleSession warns or logs out the current user once they have been idle long
+// enough. It does nothing outside lock mode or when idle logout is turned off. The policy
+// comes from the session, so the poll does not read config.ini.
+func (a *App) checkIdleSession() {
+	if !a.lockMode {
+		return
+	}
+
+	now := a.now()
+	a.sessionMu.Lock()
+	if a.session == nil || a.session.IdleTimeout == 0 {
+		a.sessionMu.Unlock()
+		return
+	}
+	timeout, warning := a.session.IdleTimeout, a.session.IdleWarning
+	idle := now.Sub(a.session.LastActivity)
+	session := *a.session
+	sendWarning := warning > 0 && idle >= timeout-warning && idle < timeout && !a.session.IdleWarned
+	if sendWarning {
+		a.session.IdleWarned = true
+	}
+	a.sessionMu.Unlock()
+
+	if idle >= timeout {
+		a.autoLogout(&session, idle)
+		return
+	}
+	if sendWarning {
+		a.emitEvent(idleWarningEvent, IdleLogoutWarning{
+			UserID:           session.UserID,
+			SecondsRemaining: int((timeout - idle).Seconds()),
+		})
+	}
+}
+
+// autoLogout logs out an idle session and locks the screen for the next user.
+func (a *App) autoLogout(session *appSession, idle time.Duration) {
+	if current := a.currentSession(); current == nil || current.Token != session.Token {
+		return
+	}
+
+	log.Printf("Idle logout: user %d (%s) inactive for %s on %s", session.UserID, session.Username, idle.Round(time.Second), a.currentStationLabel())
+	if err := a.logout(session, logoutReasonAuto); err != nil {
+		log.Printf("Failed to record idle logout for user %d: %v", session.UserID, err)
+		// Still end the session: leaving it open is what idle logout exists to prevent.
+		a.endSession()
+	}
+	a.LockScreen()
+	a.emitEvent(autoLogoutEvent, session.UserID)
+}
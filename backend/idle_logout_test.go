@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+// idleFor advances the clock in heartbeat-sized steps, the way an open but unattended
+// station keeps heartbeating, running the idle check after each step.
+func idleFor(e *testEnv, userID int, d time.Duration) {
+	e.t.Helper()
+	for step := 30 * time.Second; d > 0; d -= step {
+		if d < step {
+			step = d
+		}
+		e.clock.Advance(step)
+		if e.app.currentSession() != nil {
+			if err := e.app.TouchSession(userID); err != nil {
+				e.t.Fatalf("TouchSession: %v", err)
+			}
+		}
+		e.app.checkIdleSession()
+	}
+}
+
+func TestIdleStationWarnsThenLogsOut(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.lockMode = true
+	t.Setenv("IDLE_LOGOUT_MINUTES", "5")
+	t.Setenv("IDLE_LOGOUT_WARNING_SECONDS", "60")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	// Input resets the timer, including a warning that was already sent.
+	manual := e.loginAs("2024-00001")
+	idleFor(e, studentID, 4*time.Minute+30*time.Second)
+	if session := e.app.currentSession(); session == nil || !session.IdleWarned {
+		t.Fatalf("no idle warning before the logout: %+v", session)
+	}
+	if err := e.app.ReportActivity(studentID); err != nil {
+		t.Fatalf("ReportActivity: %v", err)
+	}
+	idleFor(e, studentID, 3*time.Minute)
+	if session := e.app.currentSession(); session == nil || session.IdleWarned {
+		t.Fatalf("session after renewed activity = %+v", session)
+	}
+	if err := e.app.Logout(studentID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	idle := e.loginAs("2024-00001")
+	idleFor(e, studentID, 5*time.Minute)
+	if e.app.currentSession() != nil || !e.app.screenLocked {
+		t.Fatalf("idle station was not logged out and locked")
+	}
+	if err := e.app.ReportActivity(studentID); err == nil {
+		t.Errorf("the idle session was still usable")
+	}
+
+	for id, want := range map[int]string{manual.LoginLogID: logoutReasonManual, idle.LoginLogID: logoutReasonAuto} {
+		if got := e.queryString(`SELECT logout_reason FROM log_entries WHERE id = ? AND logout_time IS NOT NULL`, id); got != want {
+			t.Errorf("log %d logout_reason = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestIdlePolicyIsReadWhenTheSessionStarts(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.lockMode = true
+	t.Setenv("IDLE_LOGOUT_MINUTES", "0")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	// Changing the setting mid-session does not affect the session that is already open.
+	e.loginAs("2024-00001")
+	t.Setenv("IDLE_LOGOUT_MINUTES", "5")
+	idleFor(e, studentID, 6*time.Minute)
+	if e.app.currentSession() == nil {
+		t.Fatalf("logged out under a policy read after the session started")
+	}
+
+	// Saving the policy settings applies it.
+	e.app.reloadIdleLogoutPolicy()
+	idleFor(e, studentID, 5*time.Minute)
+	if e.app.currentSession() != nil {
+		t.Errorf("idle session was not logged out after the policy was reloaded")
+	}
+}
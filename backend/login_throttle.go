@@ -0,0 +1,328 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// LOGIN THROTTLING
+// ==============================================================================
+//
+// Failed logins are counted per username and per station label in login_attempts.
+// Once a counter reaches the backoff threshold each further attempt must wait twice as
+// long as the one before; at the lockout threshold the username or station is refused
+// for a fixed time. Counters are cleared by a successful login, by an admin, or once the
+// last failure is older than the lockout duration.
+
+const (
+	loginScopeUsername = "username"
+	loginScopeStation  = "station"
+
+	defaultLoginBackoffAfterFailures = 3
+	defaultLoginLockoutAfterFailures = 10
+	defaultLoginLockoutMinutes       = 15
+	maxLoginBackoff                  = 5 * time.Minute
+)
+
+// LoginLockout is a username or station that is currently refused after failed logins.
+type LoginLockout struct {
+	Scope        string `json:"scope"`
+	Subject      string `json:"subject"`
+	FailureCount int    `json:"failure_count"`
+	LastFailedAt string `json:"last_failed_at"`
+	LockedUntil  string `json:"locked_until"`
+}
+
+type loginThrottlePolicy struct {
+	backoffAfter    int
+	lockoutAfter    int
+	lockoutDuration time.Duration
+}
+
+// loadLoginThrottlePolicy reads the [policy] login_backoff_after_failures,
+// login_lockout_after_failures and login_lockout_minutes settings (or their
+// LOGIN_* environment overrides).
+func loadLoginThrottlePolicy() loginThrottlePolicy {
+	policy := loginThrottlePolicy{
+		backoffAfter: loadPolicyInt("LOGIN_BACKOFF_AFTER_FAILURES", "login_backoff_after_failures", 1, 100, defaultLoginBackoffAfterFailures),
+		lockoutAfter: loadPolicyInt("LOGIN_LOCKOUT_AFTER_FAILURES", "login_lockout_after_failures", 1, 100, defaultLoginLockoutAfterFailures),
+		lockoutDuration: time.Duration(loadPolicyInt("LOGIN_LOCKOUT_MINUTES", "login_lockout_minutes", 1, 1440,
+			defaultLoginLockoutMinutes)) * time.Minute,
+	}
+	if policy.backoffAfter > policy.lockoutAfter {
+		policy.backoffAfter = policy.lockoutAfter
+	}
+	return policy
+}
+
+// backoff returns how long to wait after the given number of consecutive failures.
+func (p loginThrottlePolicy) backoff(failures int) time.Duration {
+	if failures < p.backoffAfter {
+		return 0
+	}
+	shift := failures - p.backoffAfter + 1
+	if shift > 16 {
+		return maxLoginBackoff
+	}
+	wait := time.Second << uint(shift)
+	if wait > maxLoginBackoff {
+		return maxLoginBackoff
+	}
+	return wait
+}
+
+// loginThrottleSubjects lists the counters an attempt is checked against. An empty username
+// (a maintenance PIN attempt) only counts against the station.
+func loginThrottleSubjects(username, stationLabel string) [][2]string {
+	var subjects [][2]string
+	if name := strings.ToLower(strings.TrimSpace(username)); name != "" {
+		subjects = append(subjects, [2]string{loginScopeUsername, name})
+	}
+	if station := strings.TrimSpace(stationLabel); station != "" {
+		subjects = append(subjects, [2]string{loginScopeStation, station})
+	}
+	return subjects
+}
+
+type loginAttemptState struct {
+	failureCount int
+	lastFailedAt time.Time
+	lockedUntil  sql.NullTime
+}
+
+func (a *App) loadLoginAttemptState(exec dbExecutor, scope, subject string) (*loginAttemptState, error) {
+	var state loginAttemptState
+	err := exec.QueryRow(`
+		SELECT failure_count, last_failed_at, locked_until
+		FROM login_attempts
+		WHERE scope = ? AND subject = ?
+	`, scope, subject).Scan(&state.failureCount, &state.lastFailedAt, &state.lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// checkLoginThrottle refuses a login attempt while its username or station is locked
+// out or still waiting out its backoff. It runs before the password is checked.
+func (a *App) checkLoginThrottle(username, stationLabel string) error {
+	return a.checkLoginAttempts(a.db, username, stationLabel)
+}
+
+// checkLoginAttempts applies the throttle to the login_attempts counters in exec, which
+// is the server database or, for offline logins, the station's journal.
+func (a *App) checkLoginAttempts(exec dbExecutor, username, stationLabel string) error {
+	policy := loadLoginThrottlePolicy()
+	now := a.now()
+
+	for _, subject := range loginThrottleSubjects(username, stationLabel) {
+		state, err := a.loadLoginAttemptState(exec, subject[0], subject[1])
+		if err != nil {
+			// Never block logins because the counters cannot be read.
+			log.Printf("Failed to read login attempts for %s %q: %v", subject[0], subject[1], err)
+			continue
+		}
+		if state == nil {
+			continue
+		}
+
+		if state.lockedUntil.Valid && state.lockedUntil.Time.After(now) {
+			minutes := int(state.lockedUntil.Time.Sub(now).Round(time.Minute) / time.Minute)
+			if minutes < 1 {
+				minutes = 1
+			}
+			if subject[0] == loginScopeStation {
+				return fmt.Errorf("too many failed login attempts on this PC - try again in %d minute(s) or contact administrator", minutes)
+			}
+			return fmt.Errorf("account temporarily locked after too many failed login attempts - try again in %d minute(s) or contact administrator", minutes)
+		}
+
+		if retryAt := state.lastFailedAt.Add(policy.backoff(state.failureCount)); retryAt.After(now) {
+			seconds := int(retryAt.Sub(now).Round(time.Second) / time.Second)
+			if seconds < 1 {
+				seconds = 1
+			}
+			return fmt.Errorf("too many failed login attempts - please wait %d second(s) before trying again", seconds)
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt against the username and the station and
+// locks whichever reaches the lockout threshold. Admins are notified of each new lockout.
+func (a *App) recordLoginFailure(username, stationLabel string) {
+	policy := loadLoginThrottlePolicy()
+	now := a.now()
+
+	for _, subject := range loginThrottleSubjects(username, stationLabel) {
+		scope, key := subject[0], subject[1]
+		locked, failures, err := a.incrementLoginFailures(a.db, policy, now, scope, key)
+		if err != nil {
+			log.Printf("Failed to record login failure for %s %q: %v", scope, key, err)
+			continue
+		}
+		if !locked {
+			continue
+		}
+
+		log.Printf("LOGIN LOCKOUT: %s %q locked for %s after %d failed attempts", scope, key, policy.lockoutDuration, failures)
+		title := "Account Locked"
+		msg := fmt.Sprintf("Account %s was locked for %d minute(s) after %d failed login attempts.",
+			key, int(policy.lockoutDuration/time.Minute), failures)
+		if scope == loginScopeStation {
+			title = "Station Locked"
+			msg = fmt.Sprintf("%s was locked for %d minute(s) after %d failed login attempts.",
+				key, int(policy.lockoutDuration/time.Minute), failures)
+		}
+		go a.createNotificationForRole("admin", "security", title, msg, "warning", notifRef("login_lockout"), nil)
+	}
+}
+
+// incrementLoginFailures adds one failure to a counter in db and reports whether this
+// failure locked it.
+func (a *App) incrementLoginFailures(db *sql.DB, policy loginThrottlePolicy, now time.Time, scope, subject string) (bool, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	state, err := a.loadLoginAttemptState(tx, scope, subject)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// A counter starts over once it has been quiet for a full lockout duration.
+	failures := 1
+	if state != nil {
+		quietSince := state.lastFailedAt
+		if state.lockedUntil.Valid && state.lockedUntil.Time.After(quietSince) {
+			quietSince = state.lockedUntil.Time
+		}
+		if now.Sub(quietSince) <= policy.lockoutDuration {
+			failures = state.failureCount + 1
+		}
+	}
+
+	var lockedUntil interface{}
+	locked := failures >= policy.lockoutAfter
+	if locked {
+		lockedUntil = now.Add(policy.lockoutDuration)
+	}
+
+	if state == nil {
+		_, err = tx.Exec(`
+			INSERT INTO login_attempts (scope, subject, failure_count, last_failed_at, locked_until)
+			VALUES (?, ?, ?, ?, ?)
+		`, scope, subject, failures, now, lockedUntil)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE login_attempts
+			SET failure_count = ?, last_failed_at = ?, locked_until = ?
+			WHERE scope = ? AND subject = ?
+		`, failures, now, lockedUntil, scope, subject)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+	return locked && failures == policy.lockoutAfter, failures, nil
+}
+
+// clearLoginFailures resets the counters after a successful login.
+func (a *App) clearLoginFailures(username, stationLabel string) {
+	clearLoginAttempts(a.db, username, stationLabel)
+}
+
+func clearLoginAttempts(exec dbExecutor, username, stationLabel string) {
+	for _, subject := range loginThrottleSubjects(username, stationLabel) {
+		if _, err := exec.Exec(`DELETE FROM login_attempts WHERE scope = ? AND subject = ?`, subject[0], subject[1]); err != nil {
+			log.Printf("Failed to clear login attempts for %s %q: %v", subject[0], subject[1], err)
+		}
+	}
+}
+
+// GetLockedAccounts returns the usernames and stations that are currently locked out.
+func (a *App) GetLockedAccounts() ([]LoginLockout, error) {
+	if _, err := a.requireRole("GetLockedAccounts"); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.Query(`
+		SELECT scope, subject, failure_count, last_failed_at, locked_until
+		FROM login_attempts
+		WHERE locked_until IS NOT NULL AND locked_until > ?
+		ORDER BY locked_until DESC, scope, subject
+	`, a.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch locked accounts: %w", err)
+	}
+	defer rows.Close()
+
+	lockouts := []LoginLockout{}
+	for rows.Next() {
+		var lockout LoginLockout
+		var lastFailedAt, lockedUntil time.Time
+		if err := rows.Scan(&lockout.Scope, &lockout.Subject, &lockout.FailureCount, &lastFailedAt, &lockedUntil); err != nil {
+			return nil, err
+		}
+		lockout.LastFailedAt = formatTime(lastFailedAt)
+		lockout.LockedUntil = formatTime(lockedUntil)
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, rows.Err()
+}
+
+// UnlockAccount clears the failed login counter of a username so it can log in again at once.
+func (a *App) UnlockAccount(username string) error {
+	return a.unlockLoginSubject("UnlockAccount", loginScopeUsername, strings.ToLower(strings.TrimSpace(username)))
+}
+
+// UnlockStation clears the failed login counter of a station label.
+func (a *App) UnlockStation(stationLabel string) error {
+	return a.unlockLoginSubject("UnlockStation", loginScopeStation, strings.TrimSpace(stationLabel))
+}
+
+func (a *App) unlockLoginSubject(method, scope, subject string) error {
+	session, err := a.requireRole(method)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	if subject == "" {
+		return fmt.Errorf("%s is required", scope)
+	}
+
+	state, err := a.loadLoginAttemptState(a.db, scope, subject)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("no failed login attempts recorded for %s %s", scope, subject)
+	}
+	if _, err := a.db.Exec(`DELETE FROM login_attempts WHERE scope = ? AND subject = ?`, scope, subject); err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", scope, err)
+	}
+
+	before := auditValues{"failure_count": state.failureCount}
+	if state.lockedUntil.Valid {
+		before["locked_until"] = formatTime(state.lockedUntil.Time)
+	}
+	a.audit(session, "unlock_"+scope, "login_"+scope, subject, before, nil)
+	log.Printf("Login %s %q unlocked by user %d", scope, subject, session.UserID)
+	return nil
+}
@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailedLoginsBackOffThenLockOut(t *testing.T) {
+	t.Setenv("LOGIN_BACKOFF_AFTER_FAILURES", "2")
+	t.Setenv("LOGIN_LOCKOUT_AFTER_FAILURES", "4")
+	t.Setenv("LOGIN_LOCKOUT_MINUTES", "10")
+	e := newTestEnv(t)
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "07"
+
+	fail := func() {
+		t.Helper()
+		if _, err := e.app.Login("2024-00001", "wrong-password"); err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("wrong password: err = %v, want invalid credentials", err)
+		}
+	}
+
+	fail()
+	fail()
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil || !strings.Contains(err.Error(), "wait") {
+		t.Fatalf("login during backoff: err = %v, want a wait error", err)
+	}
+	e.clock.Advance(2 * time.Second)
+	fail()
+	e.clock.Advance(4 * time.Second)
+	fail()
+
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("login after lockout: err = %v, want a lockout error", err)
+	}
+	e.clock.Advance(5 * time.Minute)
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("login 5 minutes into lockout: err = %v, want a lockout error", err)
+	}
+
+	// The admin is on another PC; this one is locked too.
+	e.app.pcNumber = "01"
+	e.loginAs("A-0001")
+	locked, err := e.app.GetLockedAccounts()
+	if err != nil {
+		t.Fatalf("GetLockedAccounts: %v", err)
+	}
+	var subjects []string
+	for _, lockout := range locked {
+		subjects = append(subjects, lockout.Scope+":"+lockout.Subject)
+		if lockout.FailureCount != 4 {
+			t.Errorf("%s %s failure count = %d, want 4", lockout.Scope, lockout.Subject, lockout.FailureCount)
+		}
+	}
+	if got := strings.Join(subjects, ","); !strings.Contains(got, "username:2024-00001") || !strings.Contains(got, "station:Lab 1 - PC 07") {
+		t.Fatalf("locked = %s, want the username and the station", got)
+	}
+
+	if err := e.app.UnlockAccount("2024-00001"); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM audit_events WHERE action = 'unlock_username' AND entity_id = '2024-00001'`); n != 1 {
+		t.Errorf("unlock audit events = %d, want 1", n)
+	}
+
+	e.app.pcNumber = "07"
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil || !strings.Contains(err.Error(), "this PC") {
+		t.Fatalf("login on the locked station: err = %v, want a station lockout error", err)
+	}
+	e.app.pcNumber = "01"
+	e.loginAs("A-0001")
+	if err := e.app.UnlockStation("Lab 1 - PC 07"); err != nil {
+		t.Fatalf("UnlockStation: %v", err)
+	}
+	e.app.pcNumber = "07"
+	e.loginAs("2024-00001")
+	if n := e.queryInt(`SELECT COUNT(*) FROM login_attempts`); n != 0 {
+		t.Errorf("login_attempts rows after a successful login = %d, want 0", n)
+	}
+}
+
+func TestLoginFailuresAreForgottenAfterQuietPeriod(t *testing.T) {
+	t.Setenv("LOGIN_BACKOFF_AFTER_FAILURES", "5")
+	t.Setenv("LOGIN_LOCKOUT_AFTER_FAILURES", "3")
+	t.Setenv("LOGIN_LOCKOUT_MINUTES", "10")
+	e := newTestEnv(t)
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	// Unknown usernames are counted too, so lockouts do not reveal which accounts exist.
+	for i := 0; i < 2; i++ {
+		e.app.Login("2024-99999", "wrong-password")
+		e.app.Login("2024-00001", "wrong-password")
+		e.clock.Advance(time.Minute)
+	}
+	if n := e.queryInt(`SELECT failure_count FROM login_attempts WHERE scope = 'username' AND subject = '2024-99999'`); n != 2 {
+		t.Errorf("unknown username failure count = %d, want 2", n)
+	}
+
+	e.clock.Advance(11 * time.Minute)
+	e.app.Login("2024-00001", "wrong-password")
+	if n := e.queryInt(`SELECT failure_count FROM login_attempts WHERE scope = 'username' AND subject = '2024-00001'`); n != 1 {
+		t.Fatalf("failure count after a quiet period = %d, want 1", n)
+	}
+	e.loginAs("2024-00001")
+}
+
+func TestLockedAccountsRequireAdmin(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.loginAs("T-0001")
+
+	if _, err := e.app.GetLockedAccounts(); err == nil {
+		t.Fatalf("a teacher was able to list locked accounts")
+	}
+	if err := e.app.UnlockAccount("2024-00001"); err == nil {
+		t.Fatalf("a teacher was able to unlock an account")
+	}
+}
+
+func TestChangePasswordIsThrottledLikeLogin(t *testing.T) {
+	t.Setenv("LOGIN_BACKOFF_AFTER_FAILURES", "5")
+	t.Setenv("LOGIN_LOCKOUT_AFTER_FAILURES", "3")
+	t.Setenv("LOGIN_LOCKOUT_MINUTES", "10")
+	e := newTestEnv(t)
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	unknown := e.app.ChangePassword("2024-99999", "wrong-password", "Fresh-Start-1")
+	wrong := e.app.ChangePassword("2024-00001", "wrong-password", "Fresh-Start-1")
+	if unknown == nil || wrong == nil || unknown.Error() != wrong.Error() {
+		t.Fatalf("unknown user err = %v, wrong password err = %v; want the same error", unknown, wrong)
+	}
+	e.app.ChangePassword("2024-00001", "wrong-password", "Fresh-Start-1")
+	e.app.ChangePassword("2024-00001", "wrong-password", "Fresh-Start-1")
+	if err := e.app.ChangePassword("2024-00001", testPassword, "Fresh-Start-1"); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("change password after lockout: err = %v, want a lockout error", err)
+	}
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("login after change password lockout: err = %v, want a lockout error", err)
+	}
+}
+
+func TestOfflineLoginsAreThrottledOnTheStation(t *testing.T) {
+	t.Setenv("LOGIN_LOCKOUT_AFTER_FAILURES", "3")
+	e := newTestEnv(t)
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "07"
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	passwordHash := e.queryString(`SELECT password FROM users WHERE id = ?`, studentID)
+
+	// The server is down; the student logged in on this PC before.
+	e.app.db = nil
+	defer func() { e.app.db = e.db }()
+	e.app.cacheOfflineCredential("2024-00001", User{ID: studentID, Name: "2024-00001", Role: "student"}, passwordHash)
+
+	for i := 0; i < 3; i++ {
+		e.clock.Advance(10 * time.Second)
+		if _, err := e.app.Login("2024-00001", "Wrong-Password-1"); err == nil {
+			t.Fatalf("offline login with a wrong password succeeded")
+		}
+	}
+	if _, err := e.app.Login("2024-00001", testPassword); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("offline login during the lockout = %v, want a lockout error", err)
+	}
+
+	e.clock.Advance(time.Duration(defaultLoginLockoutMinutes+1) * time.Minute)
+	user, err := e.app.Login("2024-00001", testPassword)
+	if err != nil || !user.Offline {
+		t.Fatalf("offline login after the lockout = %+v, %v", user, err)
+	}
+}
@@ -79,6 +79,9 @@ WHERE le.logout_time IS NULL
 
 // GetArchivedLogs returns all archived logs grouped by archive date
 func (a *App) GetArchivedLogs() ([]LoginLog, error) {
+	if _, err := a.requireRole("GetArchivedLogs"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -91,6 +94,7 @@ func (a *App) GetArchivedLogs() ([]LoginLog, error) {
 			ll.pc_number, 
 			ll.login_time, 
 			ll.logout_time,
+			ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -134,9 +138,10 @@ func (a *App) GetArchivedLogs() ([]LoginLog, error) {
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber)
+		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber)
 		if err != nil {
 			log.Printf("Error scanning archived log row: %v", err)
 			continue
@@ -150,6 +155,9 @@ func (a *App) GetArchivedLogs() ([]LoginLog, error) {
 			formattedLogoutTime := logoutTime.Time.Format("2006-01-02 3:04 PM")
 			logEntry.LogoutTime = &formattedLogoutTime
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -165,6 +173,9 @@ func (a *App) GetArchivedLogs() ([]LoginLog, error) {
 
 // GetAllLogs returns all login logs.
 func (a *App) GetAllLogs() ([]LoginLog, error) {
+	if _, err := a.requireRole("GetAllLogs"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -189,6 +200,7 @@ func (a *App) GetAllLogs() ([]LoginLog, error) {
 			ll.pc_number, 
 			ll.login_time, 
 			ll.logout_time,
+			ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -230,9 +242,10 @@ func (a *App) GetAllLogs() ([]LoginLog, error) {
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber)
+		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber)
 		if err != nil {
 			log.Printf("Error scanning login log row in GetAllLogs: %v", err)
 			continue
@@ -246,6 +259,9 @@ func (a *App) GetAllLogs() ([]LoginLog, error) {
 			formattedLogoutTime := logoutTime.Time.Format("2006-01-02 15:04:05")
 			logEntry.LogoutTime = &formattedLogoutTime
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -261,6 +277,9 @@ func (a *App) GetAllLogs() ([]LoginLog, error) {
 
 // GetStudentLoginLogs returns login logs for a specific student (limited to 100 most recent)
 func (a *App) GetStudentLoginLogs(userID int) ([]LoginLog, error) {
+	if _, err := a.requireActingUser("GetStudentLoginLogs", userID); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -287,6 +306,7 @@ func (a *App) GetStudentLoginLogs(userID int) ([]LoginLog, error) {
 			ll.pc_number, 
 			ll.login_time, 
 			ll.logout_time,
+			ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -329,9 +349,10 @@ func (a *App) GetStudentLoginLogs(userID int) ([]LoginLog, error) {
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber)
+		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber)
 		if err != nil {
 			log.Printf("Error scanning login log row: %v", err)
 			continue
@@ -345,6 +366,9 @@ func (a *App) GetStudentLoginLogs(userID int) ([]LoginLog, error) {
 			formattedLogoutTime := logoutTime.Time.Format("2006-01-02 15:04:05")
 			logEntry.LogoutTime = &formattedLogoutTime
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -364,6 +388,9 @@ func (a *App) GetStudentLoginLogs(userID int) ([]LoginLog, error) {
 
 // GetLogsRangeCount returns the count of log entries within a date range.
 func (a *App) GetLogsRangeCount(startDate, endDate string) (int, error) {
+	if _, err := a.requireRole("GetLogsRangeCount"); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -385,7 +412,7 @@ func (a *App) getLogsByDateRange(startDate, endDate string) ([]LoginLog, error)
 		SELECT
 			ll.id, ll.user_id,
 			COALESCE(u.user_type, 'unknown') as user_type,
-			ll.pc_number, ll.login_time, ll.logout_time,
+			ll.pc_number, ll.login_time, ll.logout_time, ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -422,9 +449,10 @@ func (a *App) getLogsByDateRange(startDate, endDate string) ([]LoginLog, error)
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		if err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber); err != nil {
+		if err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber); err != nil {
 			continue
 		}
 		logEntry.LoginTime = loginTime.Format("2006-01-02 15:04:05")
@@ -435,6 +463,9 @@ func (a *App) getLogsByDateRange(startDate, endDate string) ([]LoginLog, error)
 			s := logoutTime.Time.Format("2006-01-02 3:04 PM")
 			logEntry.LogoutTime = &s
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -489,6 +520,20 @@ func formatLogExportDateTime(value string) string {
 	return parsed.Format("2006-01-02 3:04 PM")
 }
 
+// logoutReasonNote marks logouts the user did not make themselves in log exports.
+func logoutReasonNote(reason *string) string {
+	if reason == nil {
+		return ""
+	}
+	switch *reason {
+	case logoutReasonAuto:
+		return " (idle)"
+	case logoutReasonTakenOver:
+		return " (taken over)"
+	}
+	return ""
+}
+
 func buildActiveLogExportRows(logs []LoginLog) [][]string {
 	rows := make([][]string, 0, len(logs))
 	for _, entry := range logs {
@@ -500,6 +545,7 @@ func buildActiveLogExportRows(logs []LoginLog) [][]string {
 		logout := ""
 		if entry.LogoutTime != nil && strings.TrimSpace(*entry.LogoutTime) != "" {
 			logout = formatLogExportDateTime(*entry.LogoutTime)
+			logout += logoutReasonNote(entry.LogoutReason)
 		}
 
 		rows = append(rows, []string{
@@ -525,6 +571,7 @@ func buildArchivedLogExportRows(logs []LoginLog) [][]string {
 		logout := ""
 		if entry.LogoutTime != nil && strings.TrimSpace(*entry.LogoutTime) != "" {
 			logout = formatLogExportDateTime(*entry.LogoutTime)
+			logout += logoutReasonNote(entry.LogoutReason)
 		}
 
 		rows = append(rows, []string{
@@ -614,7 +661,7 @@ func (a *App) getLogsByCount(count int) ([]LoginLog, error) {
 		SELECT
 			ll.id, ll.user_id,
 			COALESCE(u.user_type, 'unknown') as user_type,
-			ll.pc_number, ll.login_time, ll.logout_time,
+			ll.pc_number, ll.login_time, ll.logout_time, ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -651,9 +698,10 @@ func (a *App) getLogsByCount(count int) ([]LoginLog, error) {
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		if err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber); err != nil {
+		if err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber); err != nil {
 			continue
 		}
 		logEntry.LoginTime = loginTime.Format("2006-01-02 3:04 PM")
@@ -664,6 +712,9 @@ func (a *App) getLogsByCount(count int) ([]LoginLog, error) {
 			s := logoutTime.Time.Format("2006-01-02 3:04 PM")
 			logEntry.LogoutTime = &s
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -690,7 +741,7 @@ func (a *App) getLogsByRowRange(fromRow, toRow int) ([]LoginLog, error) {
 		SELECT
 			ll.id, ll.user_id,
 			COALESCE(u.user_type, 'unknown') as user_type,
-			ll.pc_number, ll.login_time, ll.logout_time,
+			ll.pc_number, ll.login_time, ll.logout_time, ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -727,9 +778,10 @@ func (a *App) getLogsByRowRange(fromRow, toRow int) ([]LoginLog, error) {
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		if err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber); err != nil {
+		if err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber); err != nil {
 			continue
 		}
 		logEntry.LoginTime = loginTime.Format("2006-01-02 15:04:05")
@@ -740,6 +792,9 @@ func (a *App) getLogsByRowRange(fromRow, toRow int) ([]LoginLog, error) {
 			s := logoutTime.Time.Format("2006-01-02 15:04:05")
 			logEntry.LogoutTime = &s
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -752,6 +807,9 @@ func (a *App) getLogsByRowRange(fromRow, toRow int) ([]LoginLog, error) {
 
 // ExportLogsCSVByRowRange exports log entries within a row range to CSV.
 func (a *App) ExportLogsCSVByRowRange(fromRow, toRow int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsCSVByRowRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -772,6 +830,9 @@ func (a *App) ExportLogsCSVByRowRange(fromRow, toRow int, savePath string) (stri
 
 // ExportLogsPDFByRowRange exports log entries within a row range to PDF.
 func (a *App) ExportLogsPDFByRowRange(fromRow, toRow int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsPDFByRowRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -791,6 +852,9 @@ func (a *App) ExportLogsPDFByRowRange(fromRow, toRow int, savePath string) (stri
 
 // ExportLogsDOCXByRowRange exports log entries within a row range to DOCX.
 func (a *App) ExportLogsDOCXByRowRange(fromRow, toRow int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsDOCXByRowRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -817,6 +881,9 @@ func (a *App) ExportLogsDOCXByRowRange(fromRow, toRow int, savePath string) (str
 // ExportLogsCSVByCount exports the latest log entries to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportLogsCSVByCount(count int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsCSVByCount"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -838,6 +905,9 @@ func (a *App) ExportLogsCSVByCount(count int, savePath string) (string, error) {
 // ExportLogsPDFByCount exports the latest log entries to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportLogsPDFByCount(count int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsPDFByCount"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -858,6 +928,9 @@ func (a *App) ExportLogsPDFByCount(count int, savePath string) (string, error) {
 // ExportLogsDOCXByCount exports the latest log entries to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportLogsDOCXByCount(count int, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsDOCXByCount"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -901,6 +974,9 @@ func buildArchivedLogExportDocument(date string, logs []LoginLog) printableExpor
 // ExportLogsCSVByRange exports log entries within a date range to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportLogsCSVByRange(startDate, endDate string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsCSVByRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -922,6 +998,9 @@ func (a *App) ExportLogsCSVByRange(startDate, endDate string, savePath string) (
 // ExportLogsPDFByRange exports log entries within a date range to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportLogsPDFByRange(startDate, endDate string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsPDFByRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -942,6 +1021,9 @@ func (a *App) ExportLogsPDFByRange(startDate, endDate string, savePath string) (
 // ExportLogsDOCXByRange exports log entries within a date range to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportLogsDOCXByRange(startDate, endDate string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportLogsDOCXByRange"); err != nil {
+		return "", err
+	}
 	if err := a.checkDB(); err != nil {
 		return "", err
 	}
@@ -971,6 +1053,9 @@ func (a *App) ExportLogsDOCXByRange(startDate, endDate string, savePath string)
 
 // GetArchivedLogsByDate returns all archived login logs for a specific date
 func (a *App) GetArchivedLogsByDate(date string) ([]LoginLog, error) {
+	if _, err := a.requireRole("GetArchivedLogsByDate"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -983,6 +1068,7 @@ func (a *App) GetArchivedLogsByDate(date string) ([]LoginLog, error) {
 			ll.pc_number, 
 			ll.login_time, 
 			ll.logout_time,
+			ll.logout_reason,
 			COALESCE(
 				CASE WHEN s.last_name IS NOT NULL AND s.first_name IS NOT NULL
 					THEN CONCAT(s.last_name, ', ', s.first_name,
@@ -1026,9 +1112,10 @@ func (a *App) GetArchivedLogsByDate(date string) ([]LoginLog, error) {
 		var pcNumber sql.NullString
 		var loginTime time.Time
 		var logoutTime sql.NullTime
+		var logoutReason sql.NullString
 		var userIDNumber sql.NullString
 
-		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logEntry.UserName, &userIDNumber)
+		err := rows.Scan(&logEntry.ID, &logEntry.UserID, &logEntry.UserType, &pcNumber, &loginTime, &logoutTime, &logoutReason, &logEntry.UserName, &userIDNumber)
 		if err != nil {
 			log.Printf("Error scanning archived login log row: %v", err)
 			continue
@@ -1042,6 +1129,9 @@ func (a *App) GetArchivedLogsByDate(date string) ([]LoginLog, error) {
 			formattedLogoutTime := logoutTime.Time.Format("2006-01-02 15:04:05")
 			logEntry.LogoutTime = &formattedLogoutTime
 		}
+		if logoutReason.Valid {
+			logEntry.LogoutReason = &logoutReason.String
+		}
 		if userIDNumber.Valid {
 			logEntry.UserIDNumber = userIDNumber.String
 		} else {
@@ -1057,6 +1147,10 @@ func (a *App) GetArchivedLogsByDate(date string) ([]LoginLog, error) {
 
 // ArchiveLogsByDate archives all login logs for a specific date
 func (a *App) ArchiveLogsByDate(date string, adminUserID int) (int, error) {
+	session, err := a.requireActingUser("ArchiveLogsByDate", adminUserID)
+	if err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -1079,12 +1173,16 @@ func (a *App) ArchiveLogsByDate(date string, adminUserID int) (int, error) {
 	}
 
 	log.Printf("%d log entries archived for date %s by admin %d", rowsAffected, date, adminUserID)
+	a.audit(session, "archive_logs_by_date", "log_entries", date, nil, auditValues{"archived_count": rowsAffected})
 	return int(rowsAffected), nil
 }
 
 // ExportArchivedLogSheetCSV exports archived logs for a specific date to CSV.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedLogSheetCSV(date string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportArchivedLogSheetCSV"); err != nil {
+		return "", err
+	}
 	logs, err := a.GetArchivedLogsByDate(date)
 	if err != nil {
 		return "", err
@@ -1108,6 +1206,9 @@ func (a *App) ExportArchivedLogSheetCSV(date string, savePath string) (string, e
 // ExportArchivedLogSheetPDF exports archived logs for a specific date to PDF.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedLogSheetPDF(date string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportArchivedLogSheetPDF"); err != nil {
+		return "", err
+	}
 	logs, err := a.GetArchivedLogsByDate(date)
 	if err != nil {
 		return "", err
@@ -1129,6 +1230,9 @@ func (a *App) ExportArchivedLogSheetPDF(date string, savePath string) (string, e
 // ExportArchivedLogSheetDOCX exports archived logs for a specific date to DOCX.
 // If savePath is non-empty, the file is saved there; otherwise it is saved to the user's Downloads folder.
 func (a *App) ExportArchivedLogSheetDOCX(date string, savePath string) (string, error) {
+	if _, err := a.requireRole("ExportArchivedLogSheetDOCX"); err != nil {
+		return "", err
+	}
 	logs, err := a.GetArchivedLogsByDate(date)
 	if err != nil {
 		return "", err
@@ -1155,6 +1259,10 @@ func (a *App) ExportArchivedLogSheetDOCX(date string, savePath string) (string,
 
 // ArchiveLogs archives selected login logs by their IDs
 func (a *App) ArchiveLogs(logIDs []int, adminUserID int) (int, error) {
+	session, err := a.requireActingUser("ArchiveLogs", adminUserID)
+	if err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -1200,5 +1308,6 @@ func (a *App) ArchiveLogs(logIDs []int, adminUserID int) (int, error) {
 	}
 
 	log.Printf("%d login logs archived by admin %d", rowsAffected, adminUserID)
+	a.audit(session, "archive_logs", "log_entries", "", nil, auditValues{"log_ids": logIDs, "archived_count": rowsAffected})
 	return int(rowsAffected), nil
 }
@@ -0,0 +1,544 @@
+// Package migrations applies versioned schema changes to the logbook database.
+//
+// SQL migrations are embedded in the binary as <dialect>/NNNN_name.up.sql and
+// NNNN_name.down.sql files. Migrations that need procedural logic (for example
+// catching up databases created before this package existed) are registered as
+// Go functions by the caller. Applied versions are recorded in schema_migrations,
+// and on MySQL a database-level lock keeps several lab PCs booting at once from
+// racing on the same ALTER TABLE.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var migrationFiles embed.FS
+
+// Dialect names match the migration directories and the storage driver names.
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+)
+
+const defaultLockName = "digital_logbook_schema_migrations"
+const defaultLockTimeout = 60 * time.Second
+
+// Executor is the subset of *sql.DB, *sql.Conn and *sql.Tx used by Go migrations.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Migration is a single numbered schema change.
+// Either UpSQL or Up must be set; Down/DownSQL are optional and make the migration reversible.
+type Migration struct {
+	Version int
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      func(ctx context.Context, db Executor) error
+	Down    func(ctx context.Context, db Executor) error
+}
+
+// Checksum identifies the SQL body of a migration so edits to applied files can be detected.
+func (m Migration) Checksum() string {
+	if m.UpSQL == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.UpSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+// AppliedMigration is a row of schema_migrations.
+type AppliedMigration struct {
+	Version     int    `json:"version"`
+	Name        string `json:"name"`
+	Checksum    string `json:"checksum"`
+	AppliedAt   string `json:"applied_at"`
+	AppliedBy   string `json:"applied_by"`
+	ExecutionMS int    `json:"execution_ms"`
+}
+
+// PendingMigration names a migration that has not been applied yet.
+type PendingMigration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// Report summarizes the schema state for the admin "schema version" view.
+type Report struct {
+	CurrentVersion int                `json:"current_version"`
+	LatestVersion  int                `json:"latest_version"`
+	Applied        []AppliedMigration `json:"applied"`
+	Pending        []PendingMigration `json:"pending"`
+	ModifiedFiles  []int              `json:"modified_files"`
+}
+
+// Load returns the embedded SQL migrations for a dialect, sorted by version.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations embedded for dialect %q: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.%s.sql", fileName, direction)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s has an invalid version number", fileName)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join(dialect, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.UpSQL = string(body)
+		} else {
+			m.DownSQL = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return nil, fmt.Errorf("migration %04d_%s has a down file but no up file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sortMigrations(list)
+	return list, nil
+}
+
+func sortMigrations(list []Migration) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+}
+
+// Runner applies migrations to a database.
+type Runner struct {
+	DB         *sql.DB
+	Dialect    string
+	Migrations []Migration
+	// AppliedBy is recorded with each applied migration (station label or host name).
+	AppliedBy string
+	// BaselineTable, when it already exists in a database without schema_migrations,
+	// marks migration 1 as applied instead of running it (pre-migration installs).
+	BaselineTable string
+	LockName      string
+	LockTimeout   time.Duration
+}
+
+// NewRunner builds a runner over the embedded migrations for a dialect plus any extra
+// Go migrations supplied by the caller.
+func NewRunner(db *sql.DB, dialect string, extra ...Migration) (*Runner, error) {
+	list, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]string, len(list))
+	for _, m := range list {
+		seen[m.Version] = m.Name
+	}
+	for _, m := range extra {
+		if existing, dup := seen[m.Version]; dup {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", m.Version, existing, m.Name)
+		}
+		if m.Up == nil && m.UpSQL == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up step", m.Version, m.Name)
+		}
+		seen[m.Version] = m.Name
+		list = append(list, m)
+	}
+	sortMigrations(list)
+
+	return &Runner{
+		DB:          db,
+		Dialect:     dialect,
+		Migrations:  list,
+		LockName:    defaultLockName,
+		LockTimeout: defaultLockTimeout,
+	}, nil
+}
+
+// LatestVersion returns the highest known migration version.
+func (r *Runner) LatestVersion() int {
+	if len(r.Migrations) == 0 {
+		return 0
+	}
+	return r.Migrations[len(r.Migrations)-1].Version
+}
+
+// Up applies every pending migration in order and returns the versions it applied.
+func (r *Runner) Up(ctx context.Context) ([]int, error) {
+	conn, release, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := r.ensureVersionTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	if err := r.baselineIfNeeded(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := r.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []int
+	for _, m := range r.Migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		started := time.Now()
+		if err := r.apply(ctx, conn, m, true); err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		elapsed := time.Since(started)
+
+		if _, err := conn.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum, applied_by, execution_ms) VALUES (?, ?, ?, ?, ?)`,
+			m.Version, m.Name, m.Checksum(), r.AppliedBy, int(elapsed.Milliseconds()),
+		); err != nil {
+			return done, fmt.Errorf("migration %04d_%s applied but could not be recorded: %w", m.Version, m.Name, err)
+		}
+
+		log.Printf("Applied schema migration %04d_%s in %s", m.Version, m.Name, elapsed.Round(time.Millisecond))
+		done = append(done, m.Version)
+	}
+
+	return done, nil
+}
+
+// Down reverts applied migrations above targetVersion, newest first.
+func (r *Runner) Down(ctx context.Context, targetVersion int) ([]int, error) {
+	conn, release, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := r.ensureVersionTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := r.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []int
+	for i := len(r.Migrations) - 1; i >= 0; i-- {
+		m := r.Migrations[i]
+		if m.Version <= targetVersion {
+			break
+		}
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil && m.DownSQL == "" {
+			return reverted, fmt.Errorf("migration %04d_%s is not reversible", m.Version, m.Name)
+		}
+
+		if err := r.apply(ctx, conn, m, false); err != nil {
+			return reverted, fmt.Errorf("reverting migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+			return reverted, fmt.Errorf("migration %04d_%s reverted but could not be unrecorded: %w", m.Version, m.Name, err)
+		}
+
+		log.Printf("Reverted schema migration %04d_%s", m.Version, m.Name)
+		reverted = append(reverted, m.Version)
+	}
+
+	return reverted, nil
+}
+
+// Status reports applied and pending migrations without changing anything.
+func (r *Runner) Status(ctx context.Context) (Report, error) {
+	report := Report{
+		LatestVersion: r.LatestVersion(),
+		Applied:       []AppliedMigration{},
+		Pending:       []PendingMigration{},
+		ModifiedFiles: []int{},
+	}
+
+	if err := r.ensureVersionTable(ctx, r.DB); err != nil {
+		return report, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT version, name, checksum, applied_at, COALESCE(applied_by, ''), execution_ms
+		FROM schema_migrations
+		ORDER BY version
+	`)
+	if err != nil {
+		return report, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	known := make(map[int]Migration, len(r.Migrations))
+	for _, m := range r.Migrations {
+		known[m.Version] = m
+	}
+
+	applied := make(map[int]struct{})
+	for rows.Next() {
+		var entry AppliedMigration
+		var appliedAt time.Time
+		if err := rows.Scan(&entry.Version, &entry.Name, &entry.Checksum, &appliedAt, &entry.AppliedBy, &entry.ExecutionMS); err != nil {
+			return report, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		entry.AppliedAt = appliedAt.Format("2006-01-02 15:04:05")
+		report.Applied = append(report.Applied, entry)
+		applied[entry.Version] = struct{}{}
+		if entry.Version > report.CurrentVersion {
+			report.CurrentVersion = entry.Version
+		}
+
+		if m, ok := known[entry.Version]; ok && entry.Checksum != "" && m.Checksum() != "" && m.Checksum() != entry.Checksum {
+			report.ModifiedFiles = append(report.ModifiedFiles, entry.Version)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, m := range r.Migrations {
+		if _, ok := applied[m.Version]; !ok {
+			report.Pending = append(report.Pending, PendingMigration{Version: m.Version, Name: m.Name})
+		}
+	}
+
+	return report, nil
+}
+
+func (r *Runner) apply(ctx context.Context, conn Executor, m Migration, up bool) error {
+	body, fn := m.UpSQL, m.Up
+	if !up {
+		body, fn = m.DownSQL, m.Down
+	}
+
+	if fn != nil {
+		return fn(ctx, conn)
+	}
+
+	for _, stmt := range SplitStatements(body) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\nstatement: %s", err, truncateStatement(stmt))
+		}
+	}
+	return nil
+}
+
+func (r *Runner) ensureVersionTable(ctx context.Context, db Executor) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL DEFAULT '',
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			applied_by VARCHAR(100) NULL,
+			execution_ms INT NOT NULL DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to ensure schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// baselineIfNeeded records migration 1 as applied when the database predates this package:
+// schema_migrations is empty but the baseline table already exists.
+func (r *Runner) baselineIfNeeded(ctx context.Context, conn Executor) error {
+	if r.BaselineTable == "" || len(r.Migrations) == 0 || r.Migrations[0].Version != 1 {
+		return nil
+	}
+
+	var recorded int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to inspect schema_migrations: %w", err)
+	}
+	if recorded > 0 {
+		return nil
+	}
+
+	exists, err := r.tableExists(ctx, conn, r.BaselineTable)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	first := r.Migrations[0]
+	if _, err := conn.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, checksum, applied_by, execution_ms) VALUES (?, ?, ?, ?, 0)`,
+		first.Version, first.Name, first.Checksum(), "baseline",
+	); err != nil {
+		return fmt.Errorf("failed to record baseline migration: %w", err)
+	}
+	log.Printf("Existing database detected - recorded %04d_%s as baseline", first.Version, first.Name)
+	return nil
+}
+
+func (r *Runner) tableExists(ctx context.Context, conn Executor, table string) (bool, error) {
+	var count int
+	if r.Dialect == DialectSQLite {
+		err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count)
+		if err != nil {
+			return false, fmt.Errorf("failed to check for table %s: %w", table, err)
+		}
+		return count > 0, nil
+	}
+
+	err := conn.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = DATABASE()
+		  AND TABLE_NAME = ?
+	`, table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for table %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+func (r *Runner) appliedVersions(ctx context.Context, conn Executor) (map[int]struct{}, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]struct{})
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = struct{}{}
+	}
+	return applied, rows.Err()
+}
+
+// lock takes a dedicated connection and holds the named migration lock on it, so that only
+// one station migrates at a time. The returned release func unlocks and returns the connection.
+// A SQLite file belongs to a single PC, so there the connection alone is enough.
+func (r *Runner) lock(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open migration connection: %w", err)
+	}
+	if r.Dialect == DialectSQLite {
+		return conn, func() { conn.Close() }, nil
+	}
+
+	timeoutSeconds := int(r.LockTimeout / time.Second)
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = int(defaultLockTimeout / time.Second)
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, r.LockName, timeoutSeconds).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to acquire schema migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("timed out after %ds waiting for another station to finish schema migrations", timeoutSeconds)
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, r.LockName); err != nil {
+			log.Printf("Failed to release schema migration lock: %v", err)
+		}
+		conn.Close()
+	}
+	return conn, release, nil
+}
+
+// SplitStatements splits a migration file into individual statements.
+// Statements end with a semicolon at the end of a line; "--" comment lines are dropped.
+func SplitStatements(body string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSpace(current.String())
+			stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+			if stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+func truncateStatement(stmt string) string {
+	stmt = strings.Join(strings.Fields(stmt), " ")
+	if len(stmt) > 200 {
+		return stmt[:197] + "..."
+	}
+	return stmt
+}
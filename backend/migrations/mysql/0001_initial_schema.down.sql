@@ -0,0 +1,23 @@
+-- Reverts migration 0001 by dropping every table it created.
+SET FOREIGN_KEY_CHECKS=0;
+
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS registration_approvals;
+DROP TABLE IF EXISTS feedback;
+DROP TABLE IF EXISTS user_session_heartbeats;
+DROP TABLE IF EXISTS log_entries;
+DROP TABLE IF EXISTS attendance;
+DROP TABLE IF EXISTS attendance_sessions;
+DROP TABLE IF EXISTS student_archived_classes;
+DROP TABLE IF EXISTS joined_classes;
+DROP TABLE IF EXISTS classes;
+DROP TABLE IF EXISTS subjects;
+DROP TABLE IF EXISTS students;
+DROP TABLE IF EXISTS teachers;
+DROP TABLE IF EXISTS admins;
+DROP TABLE IF EXISTS profile_photos;
+DROP TABLE IF EXISTS departments;
+DROP TABLE IF EXISTS users;
+
+SET FOREIGN_KEY_CHECKS=1;
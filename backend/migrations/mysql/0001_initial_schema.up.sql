@@ -1,31 +1,9 @@
--- MySQL schema
+-- Migration 0001: initial schema (formerly database/database_schema.sql).
+-- Runs against the database named in config.ini; create it first with
+-- database/create_database.sql.
 SET NAMES utf8mb4;
 SET FOREIGN_KEY_CHECKS=0;
 
-CREATE DATABASE IF NOT EXISTS logbookdb
-    CHARACTER SET utf8mb4
-    COLLATE utf8mb4_unicode_ci;
-USE logbookdb;
-
-DROP TABLE IF EXISTS notifications;
-DROP TABLE IF EXISTS user_recovery_codes;
-DROP TABLE IF EXISTS registration_approvals;
-DROP TABLE IF EXISTS feedback;
-DROP TABLE IF EXISTS user_session_heartbeats;
-DROP TABLE IF EXISTS log_entries;
-DROP TABLE IF EXISTS attendance;
-DROP TABLE IF EXISTS attendance_sessions;
-DROP TABLE IF EXISTS student_archived_classes;
-DROP TABLE IF EXISTS joined_classes;
-DROP TABLE IF EXISTS classes;
-DROP TABLE IF EXISTS subjects;
-DROP TABLE IF EXISTS students;
-DROP TABLE IF EXISTS teachers;
-DROP TABLE IF EXISTS admins;
-DROP TABLE IF EXISTS profile_photos;
-DROP TABLE IF EXISTS departments;
-DROP TABLE IF EXISTS users;
-
 CREATE TABLE users (
     id INT AUTO_INCREMENT PRIMARY KEY,
     username VARCHAR(50) NOT NULL UNIQUE,
@@ -277,6 +255,3 @@ CREATE INDEX idx_teachers_teacher_id ON teachers(teacher_id);
 CREATE INDEX idx_students_student_id ON students(student_id);
 
 SET FOREIGN_KEY_CHECKS=1;
-
-
-
@@ -0,0 +1,2 @@
+-- Reverts migration 0003.
+DROP TABLE IF EXISTS offline_replay_log;
@@ -0,0 +1,2 @@
+-- Reverts migration 0004.
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,19 @@
+-- Migration 0004: append-only audit trail of administrative and teacher actions.
+-- Actor details are copied into each row (no foreign key), so events keep naming
+-- who acted even after that account is deleted.
+CREATE TABLE audit_events (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    occurred_at DATETIME NOT NULL DEFAULT NOW(),
+    actor_user_id INT NULL,
+    actor_name VARCHAR(150) NOT NULL DEFAULT '',
+    actor_role VARCHAR(30) NOT NULL DEFAULT '',
+    station VARCHAR(100) NOT NULL DEFAULT '',
+    action VARCHAR(60) NOT NULL,
+    entity_type VARCHAR(40) NOT NULL,
+    entity_id VARCHAR(100) NOT NULL DEFAULT '',
+    before_value LONGTEXT NULL,
+    after_value LONGTEXT NULL
+);
+CREATE INDEX idx_audit_events_occurred ON audit_events(occurred_at);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_user_id, occurred_at);
+CREATE INDEX idx_audit_events_entity ON audit_events(entity_type, entity_id, occurred_at);
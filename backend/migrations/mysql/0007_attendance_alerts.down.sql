@@ -0,0 +1,4 @@
+-- Reverts migration 0007.
+DROP TABLE IF EXISTS attendance_alerts;
+ALTER TABLE classes DROP COLUMN alert_min_attendance_rate;
+ALTER TABLE classes DROP COLUMN alert_consecutive_absences;
@@ -0,0 +1,18 @@
+-- Migration 0007: per-class at-risk attendance thresholds and the alerts raised from them.
+-- NULL thresholds use the built-in defaults; 0 turns that alert off for the class.
+-- An alert stays open until the student no longer meets its condition, so each
+-- crossing of a threshold notifies the teacher and student once.
+ALTER TABLE classes ADD COLUMN alert_consecutive_absences INT NULL;
+ALTER TABLE classes ADD COLUMN alert_min_attendance_rate INT NULL;
+CREATE TABLE attendance_alerts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    class_id INT NOT NULL,
+    student_id INT NOT NULL,
+    alert_type VARCHAR(30) NOT NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    raised_at DATETIME NOT NULL DEFAULT NOW(),
+    cleared_at DATETIME NULL,
+    FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_attendance_alerts_open ON attendance_alerts(class_id, student_id, alert_type, cleared_at);
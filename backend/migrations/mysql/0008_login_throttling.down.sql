@@ -0,0 +1,2 @@
+-- Reverts migration 0008.
+DROP TABLE IF EXISTS login_attempts;
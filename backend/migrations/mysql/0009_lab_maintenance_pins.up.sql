@@ -0,0 +1,10 @@
+-- Migration 0009: per-lab maintenance PINs that authorize station configuration
+-- (lock mode, station identity, database connection) from the login page.
+-- Only a bcrypt hash of each PIN is stored.
+CREATE TABLE lab_maintenance_pins (
+    computer_lab VARCHAR(100) NOT NULL PRIMARY KEY,
+    pin_hash VARCHAR(255) NOT NULL,
+    updated_by_user_id INT NULL,
+    updated_at DATETIME NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (updated_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
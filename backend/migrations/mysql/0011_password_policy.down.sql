@@ -0,0 +1,4 @@
+-- Reverts migration 0011.
+DROP TABLE IF EXISTS password_history;
+ALTER TABLE users DROP COLUMN password_changed_at;
+ALTER TABLE users DROP COLUMN must_change_password;
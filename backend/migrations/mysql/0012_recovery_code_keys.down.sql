@@ -0,0 +1,4 @@
+-- Reverts migration 0012.
+ALTER TABLE user_totp DROP COLUMN secret_key_id;
+ALTER TABLE user_recovery_codes DROP COLUMN code_key_id;
+DROP TABLE IF EXISTS recovery_code_keys;
@@ -0,0 +1,16 @@
+-- Migration 0012: versioned keys for encrypted recovery codes and authenticator secrets.
+-- Each ciphertext records the key it was sealed with; NULL means the legacy key derived
+-- from RECOVERY_CODE_SECRET or the database settings. Key material is wrapped with
+-- RECOVERY_CODE_SECRET when that is set. Rotation retires the active key and re-encrypts
+-- every row with a new one; retired keys are kept so old backups stay readable.
+CREATE TABLE recovery_code_keys (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    key_material TEXT NOT NULL,
+    is_wrapped TINYINT(1) NOT NULL DEFAULT 0,
+    created_by_user_id INT NULL,
+    created_at DATETIME NOT NULL DEFAULT NOW(),
+    retired_at DATETIME NULL,
+    FOREIGN KEY (created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+ALTER TABLE user_recovery_codes ADD COLUMN code_key_id INT NULL;
+ALTER TABLE user_totp ADD COLUMN secret_key_id INT NULL;
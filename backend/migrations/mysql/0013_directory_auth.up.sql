@@ -0,0 +1,6 @@
+-- Migration 0013: accounts authenticated by a directory (LDAP) server.
+-- auth_source is 'local' for accounts checked against users.password and 'ldap' for
+-- accounts provisioned or linked on their first directory login; external_id keeps the
+-- directory DN. The password column of a directory account holds an unusable hash.
+ALTER TABLE users ADD COLUMN auth_source VARCHAR(20) NOT NULL DEFAULT 'local';
+ALTER TABLE users ADD COLUMN external_id VARCHAR(255) NULL;
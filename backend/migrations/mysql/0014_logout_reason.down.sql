@@ -0,0 +1,2 @@
+-- Reverts migration 0014.
+ALTER TABLE log_entries DROP COLUMN logout_reason;
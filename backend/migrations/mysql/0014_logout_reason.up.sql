@@ -0,0 +1,5 @@
+-- Migration 0014: why a login session ended.
+-- logout_reason is 'manual' when the user logged out and 'auto_logout' when a lock-mode
+-- station logged an idle user out. Rows closed by the stale-heartbeat cleanup or an app
+-- shutdown, and all rows written before this migration, keep NULL.
+ALTER TABLE log_entries ADD COLUMN logout_reason VARCHAR(20) NULL;
@@ -0,0 +1,3 @@
+-- Reverts migration 0015.
+DROP TABLE IF EXISTS attendance_code_attempts;
+ALTER TABLE attendance_sessions DROP COLUMN code_secret;
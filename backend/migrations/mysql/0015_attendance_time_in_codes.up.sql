@@ -0,0 +1,17 @@
+-- Migration 0015: rotating time-in codes for attendance sessions.
+-- code_secret is the per-session HMAC key behind the code shown on the teacher's screen;
+-- it is created the first time the code is needed. Wrong and replayed codes entered by
+-- students are kept in attendance_code_attempts for the teacher to review.
+ALTER TABLE attendance_sessions ADD COLUMN code_secret VARCHAR(64) NULL;
+CREATE TABLE attendance_code_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    session_id INT NOT NULL,
+    student_id INT NOT NULL,
+    pc_number VARCHAR(50) NULL,
+    code VARCHAR(20) NOT NULL DEFAULT '',
+    result VARCHAR(20) NOT NULL,
+    attempted_at DATETIME NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (session_id) REFERENCES attendance_sessions(session_id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_attendance_code_attempts_session ON attendance_code_attempts(session_id, student_id, attempted_at);
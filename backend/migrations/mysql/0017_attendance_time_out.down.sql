@@ -0,0 +1,5 @@
+-- Reverts migration 0017.
+ALTER TABLE attendance DROP COLUMN minutes_attended;
+ALTER TABLE attendance DROP COLUMN time_out_source;
+ALTER TABLE attendance DROP COLUMN time_out_at;
+ALTER TABLE attendance DROP COLUMN present_since;
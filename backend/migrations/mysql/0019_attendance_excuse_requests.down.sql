@@ -0,0 +1,2 @@
+-- Reverts migration 0019.
+DROP TABLE IF EXISTS attendance_excuse_requests;
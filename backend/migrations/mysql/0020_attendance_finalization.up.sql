@@ -0,0 +1,12 @@
+-- Migration 0020: finalization of unmarked students when a session closes.
+-- unmarked_policy decides what happens to students with no status when a session of the
+-- class closes: 'absent' marks them absent, 'pending' leaves them unmarked for
+-- unmarked_pending_hours and then marks them absent, 'review' leaves them for the teacher.
+-- finalization_status is 'finalized', 'pending' or 'review'; finalization_summary keeps
+-- the JSON counts and names produced when the session was finalized.
+ALTER TABLE classes ADD COLUMN unmarked_policy VARCHAR(20) NOT NULL DEFAULT 'absent';
+ALTER TABLE classes ADD COLUMN unmarked_pending_hours INT NOT NULL DEFAULT 24;
+ALTER TABLE attendance_sessions ADD COLUMN finalization_status VARCHAR(20) NULL;
+ALTER TABLE attendance_sessions ADD COLUMN finalization_due_at DATETIME NULL;
+ALTER TABLE attendance_sessions ADD COLUMN finalized_at DATETIME NULL;
+ALTER TABLE attendance_sessions ADD COLUMN finalization_summary TEXT NULL;
@@ -0,0 +1,9 @@
+-- Migration 0021: multi-tier lateness rules.
+-- lateness_tiers holds a JSON rule: an ordered list of {up_to_minutes, status} tiers and
+-- the status given after the last one. The class column is the default for its sessions;
+-- the session column overrides it for one session. NULL falls back to the session's
+-- grace_period_minutes (present, then late).
+-- minutes_late is how many minutes after the session opened the student timed in.
+ALTER TABLE classes ADD COLUMN lateness_tiers TEXT NULL;
+ALTER TABLE attendance_sessions ADD COLUMN lateness_tiers TEXT NULL;
+ALTER TABLE attendance ADD COLUMN minutes_late INT NULL;
@@ -0,0 +1,2 @@
+-- Reverts migration 0022.
+ALTER TABLE attendance_sessions DROP COLUMN code_rotation_seconds;
@@ -0,0 +1,5 @@
+-- Migration 0022: time-in code rotation period per session.
+-- code_rotation_seconds is time_in_code_seconds on the PC that opened the session. Every
+-- station, and the offline replay, use it so their codes agree. NULL (sessions opened
+-- before this migration) is filled in on first use.
+ALTER TABLE attendance_sessions ADD COLUMN code_rotation_seconds INT NULL;
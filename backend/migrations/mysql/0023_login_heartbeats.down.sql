@@ -0,0 +1,2 @@
+-- Reverts migration 0023.
+ALTER TABLE log_entries DROP COLUMN last_seen_at;
@@ -0,0 +1,4 @@
+-- Migration 0023: per-station login heartbeats.
+-- last_seen_at is the last heartbeat from the station that opened the login log, so a
+-- user logged in on several stations shows which of them is still alive.
+ALTER TABLE log_entries ADD COLUMN last_seen_at DATETIME NULL;
@@ -0,0 +1 @@
+ALTER TABLE attendance_sessions DROP COLUMN paused_at;
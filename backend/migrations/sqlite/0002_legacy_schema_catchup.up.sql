@@ -0,0 +1,3 @@
+-- Migration 0002: on MySQL this version upgrades databases created before migrations
+-- existed. A SQLite file always starts from 0001, so only the column 0001 lacks is added.
+ALTER TABLE attendance_sessions ADD COLUMN paused_at DATETIME NULL;
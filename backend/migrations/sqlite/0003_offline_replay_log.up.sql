@@ -0,0 +1,17 @@
+-- Migration 0003: server-side record of events replayed from station offline queues.
+-- event_uid is minted on the station when the event is journaled, so replaying the
+-- same event twice (e.g. after a crash mid-sync) hits the primary key and is skipped.
+CREATE TABLE offline_replay_log (
+    event_uid VARCHAR(64) NOT NULL PRIMARY KEY,
+    event_type VARCHAR(30) NOT NULL,
+    user_id INT NULL,
+    station VARCHAR(100) NOT NULL,
+    occurred_at DATETIME NOT NULL,
+    replayed_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    outcome VARCHAR(20) NOT NULL
+        CHECK (outcome IN ('applied', 'conflict')),
+    detail VARCHAR(500) NULL,
+    result_id INT NULL,
+    CONSTRAINT FK_offline_replay_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX idx_offline_replay_outcome ON offline_replay_log(outcome, replayed_at);
@@ -0,0 +1,2 @@
+-- Reverts migration 0005.
+DROP TABLE IF EXISTS attendance_changes;
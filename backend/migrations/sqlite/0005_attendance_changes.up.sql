@@ -0,0 +1,21 @@
+-- Migration 0005: version history for manual attendance changes.
+-- Each row keeps the status and remarks an attendance record had before a teacher
+-- changed it, who made the change, and the reason they gave.
+CREATE TABLE attendance_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    attendance_id INT NOT NULL,
+    session_id INT NULL,
+    student_id INT NOT NULL,
+    changed_by_user_id INT NULL,
+    changed_by_name VARCHAR(150) NOT NULL DEFAULT '',
+    changed_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    previous_status VARCHAR(20) NULL,
+    new_status VARCHAR(20) NULL,
+    previous_remarks LONGTEXT NULL,
+    new_remarks LONGTEXT NULL,
+    reason VARCHAR(500) NOT NULL,
+    FOREIGN KEY (attendance_id) REFERENCES attendance(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX idx_attendance_changes_record ON attendance_changes(attendance_id, changed_at);
+CREATE INDEX idx_attendance_changes_session ON attendance_changes(session_id, student_id);
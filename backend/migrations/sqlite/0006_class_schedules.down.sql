@@ -0,0 +1,7 @@
+-- Reverts migration 0006.
+DROP INDEX IF EXISTS uq_attendance_sessions_meeting;
+ALTER TABLE attendance_sessions DROP COLUMN meeting_id;
+DROP TABLE IF EXISTS class_holidays;
+DROP TABLE IF EXISTS class_meetings;
+ALTER TABLE classes DROP COLUMN term_end_date;
+ALTER TABLE classes DROP COLUMN term_start_date;
@@ -0,0 +1,27 @@
+-- Migration 0006: structured recurring class schedules.
+-- class_meetings holds one row per weekly meeting (weekday 0 = Sunday). Attendance
+-- sessions opened by the scheduler keep the meeting they were opened for, and the
+-- unique index stops two stations from opening the same meeting twice.
+ALTER TABLE classes ADD COLUMN term_start_date DATE NULL;
+ALTER TABLE classes ADD COLUMN term_end_date DATE NULL;
+CREATE TABLE class_meetings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    class_id INT NOT NULL,
+    weekday TINYINT NOT NULL,
+    start_time VARCHAR(5) NOT NULL,
+    end_time VARCHAR(5) NOT NULL,
+    room VARCHAR(50) NULL,
+    grace_period_minutes INT NOT NULL DEFAULT 10,
+    FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE
+);
+CREATE INDEX idx_class_meetings_weekday ON class_meetings(weekday, class_id);
+CREATE TABLE class_holidays (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    class_id INT NOT NULL,
+    holiday_date DATE NOT NULL,
+    description VARCHAR(255) NULL,
+    UNIQUE (class_id, holiday_date),
+    FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE
+);
+ALTER TABLE attendance_sessions ADD COLUMN meeting_id INT NULL;
+CREATE UNIQUE INDEX uq_attendance_sessions_meeting ON attendance_sessions(class_id, attendance_date, meeting_id);
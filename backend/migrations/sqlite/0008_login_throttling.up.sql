@@ -0,0 +1,12 @@
+-- Migration 0008: failed login counters, kept per username and per station label so
+-- a lockout survives restarting the app. A row is removed on the next successful login
+-- or when an admin unlocks it.
+CREATE TABLE login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope VARCHAR(20) NOT NULL,
+    subject VARCHAR(150) NOT NULL,
+    failure_count INT NOT NULL DEFAULT 0,
+    last_failed_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    locked_until DATETIME NULL,
+    UNIQUE (scope, subject)
+);
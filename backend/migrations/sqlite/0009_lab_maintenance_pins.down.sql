@@ -0,0 +1,2 @@
+-- Reverts migration 0009.
+DROP TABLE IF EXISTS lab_maintenance_pins;
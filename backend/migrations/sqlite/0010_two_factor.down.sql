@@ -0,0 +1,4 @@
+-- Reverts migration 0010.
+DROP TABLE IF EXISTS two_factor_policies;
+DROP TABLE IF EXISTS user_totp_backup_codes;
+DROP TABLE IF EXISTS user_totp;
@@ -0,0 +1,29 @@
+-- Migration 0010: TOTP two-factor authentication for admin and teacher accounts.
+-- The shared secret is stored encrypted with the recovery-code key; backup codes are
+-- stored as SHA-256 hashes like recovery codes and can each be used once.
+-- A secret stays disabled until the user confirms it with a first code.
+CREATE TABLE user_totp (
+    user_id INT PRIMARY KEY,
+    secret_ciphertext TEXT NOT NULL,
+    is_enabled TINYINT(1) NOT NULL DEFAULT 0,
+    last_used_step BIGINT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    enabled_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE user_totp_backup_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    used_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_user_totp_backup_codes_user ON user_totp_backup_codes(user_id, used_at);
+CREATE TABLE two_factor_policies (
+    role VARCHAR(30) PRIMARY KEY,
+    is_required TINYINT(1) NOT NULL DEFAULT 0,
+    updated_by_user_id INT NULL,
+    updated_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    FOREIGN KEY (updated_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
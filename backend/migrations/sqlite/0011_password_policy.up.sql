@@ -0,0 +1,15 @@
+-- Migration 0011: password policy state.
+-- must_change_password is set when an admin chooses a user's password (new accounts and
+-- resets) and cleared when the user picks their own. password_changed_at drives password
+-- expiry; NULL means the password has not changed since the account was created.
+-- password_history keeps the hashes of replaced passwords so they cannot be reused.
+ALTER TABLE users ADD COLUMN must_change_password TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN password_changed_at DATETIME NULL;
+CREATE TABLE password_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_password_history_user ON password_history(user_id, id);
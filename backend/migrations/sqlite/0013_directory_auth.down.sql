@@ -0,0 +1,3 @@
+-- Reverts migration 0013.
+ALTER TABLE users DROP COLUMN external_id;
+ALTER TABLE users DROP COLUMN auth_source;
@@ -0,0 +1,4 @@
+-- Reverts migration 0016.
+ALTER TABLE attendance DROP COLUMN time_in_station;
+ALTER TABLE classes DROP COLUMN time_in_allowed_labs;
+ALTER TABLE classes DROP COLUMN time_in_lab_restricted;
@@ -0,0 +1,7 @@
+-- Migration 0016: location-bound time-in.
+-- When time_in_lab_restricted is set, students can only time in from stations whose
+-- computer lab matches the class room or one of time_in_allowed_labs (';'-separated).
+-- time_in_station records the station label each time-in came from.
+ALTER TABLE classes ADD COLUMN time_in_lab_restricted TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE classes ADD COLUMN time_in_allowed_labs VARCHAR(255) NULL;
+ALTER TABLE attendance ADD COLUMN time_in_station VARCHAR(100) NULL;
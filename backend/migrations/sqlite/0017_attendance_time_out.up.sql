@@ -0,0 +1,8 @@
+-- Migration 0017: attendance time-out and minutes attended.
+-- present_since is the start of the student's current presence segment (NULL when not
+-- timed in). A time-out adds the segment to minutes_attended and records when and why
+-- it ended: student, logout, stale or session_closed.
+ALTER TABLE attendance ADD COLUMN present_since DATETIME NULL;
+ALTER TABLE attendance ADD COLUMN time_out_at DATETIME NULL;
+ALTER TABLE attendance ADD COLUMN time_out_source VARCHAR(20) NULL;
+ALTER TABLE attendance ADD COLUMN minutes_attended INT NULL;
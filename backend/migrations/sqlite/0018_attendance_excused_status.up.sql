@@ -0,0 +1,39 @@
+-- Migration 0018: 'excused' attendance status.
+-- SQLite cannot change a CHECK constraint in place, so the attendance table is rebuilt
+-- with the wider one. Foreign keys are off while the old table is dropped so that
+-- attendance_changes rows are not cascaded away.
+PRAGMA foreign_keys = OFF;
+CREATE TABLE attendance_rebuild (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    class_id INT NOT NULL,
+    student_id INT NOT NULL,
+    attendance_date DATE NOT NULL,
+    session_id INT NULL,
+    status VARCHAR(20) NULL DEFAULT NULL CHECK (status IN ('present', 'absent', 'late', 'excused') OR status IS NULL),
+    time_in_at DATETIME NULL,
+    remarks LONGTEXT,
+    is_archived TINYINT(1) DEFAULT 0,
+    created_at DATETIME DEFAULT (datetime('now','localtime')),
+    updated_at DATETIME DEFAULT (datetime('now','localtime')),
+    time_in_station VARCHAR(100) NULL,
+    present_since DATETIME NULL,
+    time_out_at DATETIME NULL,
+    time_out_source VARCHAR(20) NULL,
+    minutes_attended INT NULL,
+    UNIQUE (class_id, student_id, attendance_date, session_id),
+    FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+);
+INSERT INTO attendance_rebuild (
+    id, class_id, student_id, attendance_date, session_id, status, time_in_at, remarks, is_archived,
+    created_at, updated_at, time_in_station, present_since, time_out_at, time_out_source, minutes_attended
+)
+SELECT
+    id, class_id, student_id, attendance_date, session_id, status, time_in_at, remarks, is_archived,
+    created_at, updated_at, time_in_station, present_since, time_out_at, time_out_source, minutes_attended
+FROM attendance;
+DROP TABLE attendance;
+ALTER TABLE attendance_rebuild RENAME TO attendance;
+CREATE INDEX idx_attendance_date ON attendance(attendance_date);
+CREATE INDEX idx_attendance_class_date_session_student ON attendance(class_id, attendance_date, session_id, student_id);
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,28 @@
+-- Migration 0019: excuse and leave requests.
+-- A student asks to be excused from one attendance session (session_id) or from every
+-- session of a class between start_date and end_date. status is pending, approved,
+-- denied or cancelled. The optional document is stored as a data URL, like profile
+-- photos. Approving a request marks the covered absent or unmarked rows 'excused',
+-- including rows of sessions opened later within the dates.
+CREATE TABLE attendance_excuse_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    student_id INT NOT NULL,
+    class_id INT NOT NULL,
+    session_id INT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    reason VARCHAR(1000) NOT NULL,
+    document_name VARCHAR(255) NULL,
+    document_data LONGTEXT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    submitted_at DATETIME NOT NULL DEFAULT (datetime('now','localtime')),
+    reviewed_by_user_id INT NULL,
+    reviewed_at DATETIME NULL,
+    review_note VARCHAR(500) NULL,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+    FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE,
+    FOREIGN KEY (session_id) REFERENCES attendance_sessions(session_id) ON DELETE SET NULL,
+    FOREIGN KEY (reviewed_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX idx_excuse_requests_class_status ON attendance_excuse_requests(class_id, status);
+CREATE INDEX idx_excuse_requests_student ON attendance_excuse_requests(student_id, submitted_at);
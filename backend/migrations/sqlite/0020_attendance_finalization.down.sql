@@ -0,0 +1,7 @@
+-- Reverts migration 0020.
+ALTER TABLE attendance_sessions DROP COLUMN finalization_summary;
+ALTER TABLE attendance_sessions DROP COLUMN finalized_at;
+ALTER TABLE attendance_sessions DROP COLUMN finalization_due_at;
+ALTER TABLE attendance_sessions DROP COLUMN finalization_status;
+ALTER TABLE classes DROP COLUMN unmarked_pending_hours;
+ALTER TABLE classes DROP COLUMN unmarked_policy;
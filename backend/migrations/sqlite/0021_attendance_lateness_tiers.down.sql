@@ -0,0 +1,4 @@
+-- Reverts migration 0021.
+ALTER TABLE attendance DROP COLUMN minutes_late;
+ALTER TABLE attendance_sessions DROP COLUMN lateness_tiers;
+ALTER TABLE classes DROP COLUMN lateness_tiers;
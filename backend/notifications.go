@@ -31,40 +31,11 @@ type NotificationSummary struct {
 	UnreadCount   int            `json:"unread_count"`
 }
 
-// ensureNotificationsTable creates the notifications table if it does not exist
-func (a *App) ensureNotificationsTable() error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-
-	query := `
-		CREATE TABLE IF NOT EXISTS notifications (
-			id              INT AUTO_INCREMENT PRIMARY KEY,
-			user_id         INT NOT NULL,
-			category        VARCHAR(50) NOT NULL,
-			title           VARCHAR(200) NOT NULL,
-			message         VARCHAR(500) NOT NULL,
-			tone            VARCHAR(20) NOT NULL DEFAULT 'info',
-			is_read         TINYINT(1) NOT NULL DEFAULT 0,
-			reference_type  VARCHAR(50) NULL,
-			reference_id    INT NULL,
-			created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			read_at         DATETIME NULL,
-			CONSTRAINT FK_notifications_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)
-	`
-
-	if _, err := a.db.Exec(query); err != nil {
-		return err
-	}
-	_, _ = a.db.Exec(`CREATE INDEX idx_notifications_user_unread ON notifications(user_id, is_read)`)
-	_, _ = a.db.Exec(`CREATE INDEX idx_notifications_user_created ON notifications(user_id, created_at)`)
-	_, _ = a.db.Exec(`CREATE INDEX idx_notifications_category ON notifications(category)`)
-	return nil
-}
-
 // GetNotifications returns recent notifications for a user plus the unread count
 func (a *App) GetNotifications(userID int, limit int) (NotificationSummary, error) {
+	if _, err := a.requireActingUser("GetNotifications", userID); err != nil {
+		return NotificationSummary{}, err
+	}
 	var summary NotificationSummary
 	if err := a.checkDB(); err != nil {
 		return summary, err
@@ -138,6 +109,9 @@ func (a *App) GetNotifications(userID int, limit int) (NotificationSummary, erro
 
 // MarkNotificationRead marks a single notification as read
 func (a *App) MarkNotificationRead(notificationID int, userID int) error {
+	if _, err := a.requireActingUser("MarkNotificationRead", userID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -150,6 +124,9 @@ func (a *App) MarkNotificationRead(notificationID int, userID int) error {
 
 // MarkAllNotificationsRead marks all unread notifications as read for a user
 func (a *App) MarkAllNotificationsRead(userID int) error {
+	if _, err := a.requireActingUser("MarkAllNotificationsRead", userID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
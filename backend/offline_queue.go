@@ -0,0 +1,847 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"digital-logbook-wails-app/backend/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ==============================================================================
+// OFFLINE QUEUE
+// ==============================================================================
+//
+// When the lab server cannot be reached, logins, student time-ins and equipment
+// feedback are written to a small journal on this PC (offline-queue.db next to the
+// app settings) together with the time they happened. A background loop replays the
+// journal in order once the connection is back.
+//
+// Every event carries a station-minted UID that is inserted into offline_replay_log in
+// the same transaction as the event's effect, so an interrupted sync never applies an
+// event twice. Events that no longer fit the server state (session closed in the
+// meantime, account deactivated, ...) are recorded as conflicts and reported to admins.
+
+const (
+	offlineQueueFile             = "offline-queue.db"
+	offlineReplayIntervalSeconds = 30
+	offlineCredentialMaxAgeDays  = 14
+	offlineJournalRetentionDays  = 30
+)
+
+// Offline event types stored in the journal and in offline_replay_log.event_type.
+const (
+	offlineEventLogin    = "login"
+	offlineEventTimeIn   = "time_in"
+	offlineEventFeedback = "feedback"
+)
+
+var errNoOfflineCredential = errors.New("no cached credential for offline login")
+
+const offlineJournalSchema = `
+CREATE TABLE IF NOT EXISTS offline_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_uid TEXT NOT NULL UNIQUE,
+	event_type TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	station TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	occurred_at DATETIME NOT NULL,
+	last_seen_at DATETIME NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NULL,
+	replayed_at DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_offline_events_status ON offline_events(status, id);
+CREATE TABLE IF NOT EXISTS offline_credentials (
+	username TEXT NOT NULL PRIMARY KEY COLLATE NOCASE,
+	user_id INTEGER NOT NULL,
+	password_hash TEXT NOT NULL,
+	profile TEXT NOT NULL,
+	cached_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS login_attempts (
+	scope TEXT NOT NULL,
+	subject TEXT NOT NULL COLLATE NOCASE,
+	failure_count INTEGER NOT NULL,
+	last_failed_at DATETIME NOT NULL,
+	locked_until DATETIME NULL,
+	PRIMARY KEY (scope, subject)
+);
+CREATE TABLE IF NOT EXISTS station_pin (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	pin_hash TEXT NOT NULL,
+	cached_at DATETIME NOT NULL
+);
+`
+
+// offlineEvent is one journaled event awaiting replay.
+type offlineEvent struct {
+	ID         int
+	UID        string
+	Type       string
+	UserID     int
+	Station    string
+	Payload    string
+	OccurredAt time.Time
+	LastSeenAt sql.NullTime
+}
+
+// offlineTimeInPayload is the journaled form of a StudentTimeIn call.
+type offlineTimeInPayload struct {
+	SessionID   int    `json:"session_id"`
+	Code        string `json:"code"`
+	ComputerLab string `json:"computer_lab,omitempty"`
+}
+
+// offlineLoginPayload is the journaled form of a Login call.
+type offlineLoginPayload struct {
+	Username string `json:"username"`
+}
+
+// offlineReplayResult is the outcome of applying one event to the server.
+type offlineReplayResult struct {
+	ResultID sql.NullInt64
+	Conflict bool
+	Detail   string
+}
+
+// OfflineQueueStatus summarizes this station's offline journal.
+type OfflineQueueStatus struct {
+	Pending   int    `json:"pending"`
+	Conflicts int    `json:"conflicts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// OfflineReplayEntry is one event replayed from a station's offline queue.
+type OfflineReplayEntry struct {
+	EventUID   string  `json:"event_uid"`
+	EventType  string  `json:"event_type"`
+	UserID     *int    `json:"user_id,omitempty"`
+	UserName   string  `json:"user_name"`
+	Station    string  `json:"station"`
+	OccurredAt string  `json:"occurred_at"`
+	ReplayedAt string  `json:"replayed_at"`
+	Outcome    string  `json:"outcome"`
+	Detail     *string `json:"detail,omitempty"`
+}
+
+func offlineQueuePath() (string, error) {
+	settingsPath, err := getUserAppConfigPath()
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve offline queue path: %w", err)
+	}
+	return filepath.Join(filepath.Dir(settingsPath), offlineQueueFile), nil
+}
+
+// offlineJournalDB opens the station journal on first use.
+// When create is false and no journal exists yet, it returns nil without creating one.
+func (a *App) offlineJournalDB(create bool) (*sql.DB, error) {
+	a.offlineMu.Lock()
+	defer a.offlineMu.Unlock()
+
+	if a.offlineJournal != nil {
+		return a.offlineJournal, nil
+	}
+
+	path, err := offlineQueuePath()
+	if err != nil {
+		return nil, err
+	}
+	if !create {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	db, err := storage.OpenSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open offline queue: %w", err)
+	}
+	if _, err := db.Exec(offlineJournalSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare offline queue: %w", err)
+	}
+
+	a.offlineJournal = db
+	return db, nil
+}
+
+// queueOfflineEvent journals an event that could not reach the server and returns its UID.
+// cause is the connection error; it is returned as-is when the journal cannot be written,
+// so callers fail exactly as they did before the queue existed.
+func (a *App) queueOfflineEvent(cause error, eventType string, userID int, payload interface{}) (string, error) {
+	journal, err := a.offlineJournalDB(true)
+	if err != nil {
+		log.Printf("Offline queue unavailable: %v", err)
+		return "", cause
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode offline event: %w", err)
+	}
+	uid, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = journal.Exec(`
+		INSERT INTO offline_events (event_uid, event_type, user_id, station, payload, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, uid, eventType, userID, a.currentStationLabel(), string(data), a.now())
+	if err != nil {
+		log.Printf("Failed to journal offline %s event for user %d: %v", eventType, userID, err)
+		return "", cause
+	}
+
+	log.Printf("Server unreachable - queued offline %s event %s for user %d", eventType, uid, userID)
+	return uid, nil
+}
+
+// recordOfflineLoginFailure counts a wrong offline password against the username and
+// the station in the journal. Admins cannot be notified offline, so lockouts are logged.
+func (a *App) recordOfflineLoginFailure(journal *sql.DB, username, stationLabel string) {
+	policy := loadLoginThrottlePolicy()
+	now := a.now()
+	for _, subject := range loginThrottleSubjects(username, stationLabel) {
+		locked, failures, err := a.incrementLoginFailures(journal, policy, now, subject[0], subject[1])
+		if err != nil {
+			log.Printf("Failed to record offline login failure for %s %q: %v", subject[0], subject[1], err)
+			continue
+		}
+		if locked {
+			log.Printf("OFFLINE LOGIN LOCKOUT: %s %q locked for %s after %d failed attempts", subject[0], subject[1], policy.lockoutDuration, failures)
+		}
+	}
+}
+
+// touchOfflineSession records activity for a session whose login is still only in the journal,
+// so the replayed log_entries row gets a sensible logout time.
+func (a *App) touchOfflineSession(eventUID string) {
+	journal, err := a.offlineJournalDB(false)
+	if err != nil || journal == nil {
+		return
+	}
+	if _, err := journal.Exec(`UPDATE offline_events SET last_seen_at = ? WHERE event_uid = ?`, a.now(), eventUID); err != nil {
+		log.Printf("Failed to record offline session activity: %v", err)
+	}
+}
+
+// ==============================================================================
+// OFFLINE LOGIN
+// ==============================================================================
+
+// cacheOfflineCredential keeps the password hash and profile of a student who just logged in
+// online, so the same student can still log in on this PC while the server is down.
+// Staff accounts are never cached on lab stations.
+func (a *App) cacheOfflineCredential(username string, user User, passwordHash string) {
+	if !containsRole(studentRoles, user.Role) || a.dbDriver() == storage.DriverSQLite {
+		return
+	}
+	journal, err := a.offlineJournalDB(true)
+	if err != nil {
+		log.Printf("Failed to cache offline credential for user %d: %v", user.ID, err)
+		return
+	}
+
+	user.Password = ""
+	user.SessionToken = ""
+	user.PhotoURL = nil
+	user.LoginLogID = 0
+	profile, err := json.Marshal(user)
+	if err != nil {
+		log.Printf("Failed to encode offline profile for user %d: %v", user.ID, err)
+		return
+	}
+
+	_, err = journal.Exec(`
+		INSERT INTO offline_credentials (username, user_id, password_hash, profile, cached_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET
+			user_id = excluded.user_id,
+			password_hash = excluded.password_hash,
+			profile = excluded.profile,
+			cached_at = excluded.cached_at
+	`, username, user.ID, passwordHash, string(profile), a.now())
+	if err != nil {
+		log.Printf("Failed to cache offline credential for user %d: %v", user.ID, err)
+	}
+}
+
+// offlineLogin authenticates against the credential cache while the server is unreachable.
+// It returns errNoOfflineCredential when the user cannot be served offline at all. Wrong
+// passwords are counted in the journal's own login_attempts, under the same throttle
+// policy as online logins, since the server's counters cannot be reached.
+func (a *App) offlineLogin(username, password string) (*User, error) {
+	journal, err := a.offlineJournalDB(false)
+	if err != nil || journal == nil {
+		return nil, errNoOfflineCredential
+	}
+	stationLabel := a.currentStationLabel()
+	if err := a.checkLoginAttempts(journal, username, stationLabel); err != nil {
+		log.Printf("OFFLINE LOGIN ERROR: Attempt for user '%s' on '%s' throttled: %v", username, stationLabel, err)
+		return nil, err
+	}
+
+	var passwordHash, profile string
+	var cachedAt time.Time
+	err = journal.QueryRow(`
+		SELECT password_hash, profile, cached_at FROM offline_credentials WHERE username = ?
+	`, username).Scan(&passwordHash, &profile, &cachedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("OFFLINE LOGIN ERROR: credential lookup failed for user '%s': %v", username, err)
+		}
+		return nil, errNoOfflineCredential
+	}
+	if a.now().Sub(cachedAt) > time.Duration(offlineCredentialMaxAgeDays)*24*time.Hour {
+		log.Printf("OFFLINE LOGIN ERROR: cached credential for user '%s' is older than %d days", username, offlineCredentialMaxAgeDays)
+		return nil, errNoOfflineCredential
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+		log.Printf("OFFLINE LOGIN ERROR: Password verification failed for user '%s'", username)
+		a.recordOfflineLoginFailure(journal, username, stationLabel)
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	clearLoginAttempts(journal, username, stationLabel)
+
+	var user User
+	if err := json.Unmarshal([]byte(profile), &user); err != nil {
+		log.Printf("OFFLINE LOGIN ERROR: cached profile for user '%s' is unreadable: %v", username, err)
+		return nil, errNoOfflineCredential
+	}
+
+	eventUID, err := a.queueOfflineEvent(errNoOfflineCredential, offlineEventLogin, user.ID, offlineLoginPayload{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.startSession(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session - please try again")
+	}
+	a.setSessionOfflineEvent(token, eventUID)
+	user.SessionToken = token
+	user.Offline = true
+
+	log.Printf("Offline login successful: %s (role: %s, pc: %s)", username, user.Role, a.currentStationLabel())
+	return &user, nil
+}
+
+// ==============================================================================
+// OFFLINE TIME-IN
+// ==============================================================================
+
+// queueOfflineTimeIn journals a student time-in. Repeated taps for the same session
+// while offline are folded into the first one so its timestamp is the one that counts.
+// The time-in code cannot be checked until the server is back; a tap with a different
+// code is kept as its own event so a mistyped first code does not lose the time-in.
+func (a *App) queueOfflineTimeIn(cause error, sessionID, studentUserID int, code string) error {
+	if err := ValidatePositiveID(sessionID, "session ID"); err != nil {
+		return err
+	}
+
+	payload := offlineTimeInPayload{SessionID: sessionID, Code: normalizeAttendanceCode(code), ComputerLab: a.computerLab}
+	if journal, err := a.offlineJournalDB(false); err == nil && journal != nil {
+		data, _ := json.Marshal(payload)
+		var queued int
+		if journal.QueryRow(`
+			SELECT 1 FROM offline_events
+			WHERE event_type = ? AND user_id = ? AND payload = ? AND status = 'pending'
+			LIMIT 1
+		`, offlineEventTimeIn, studentUserID, string(data)).Scan(&queued) == nil {
+			return nil
+		}
+	}
+
+	_, err := a.queueOfflineEvent(cause, offlineEventTimeIn, studentUserID, payload)
+	return err
+}
+
+// ==============================================================================
+// REPLAY
+// ==============================================================================
+
+func (a *App) startOfflineReplayLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(offlineReplayIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	a.replayOfflineQueue()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.replayOfflineQueue()
+		}
+	}
+}
+
+// replayOfflineQueue applies pending journal events in the order they were recorded.
+// It stops at the first event that fails for a reason other than a conflict, so a
+// time-in is never applied ahead of an earlier event that is still waiting.
+func (a *App) replayOfflineQueue() {
+	a.offlineReplayMu.Lock()
+	defer a.offlineReplayMu.Unlock()
+
+	journal, err := a.offlineJournalDB(false)
+	if err != nil || journal == nil {
+		return
+	}
+
+	events, err := loadPendingOfflineEvents(journal)
+	if err != nil {
+		log.Printf("Failed to read offline queue: %v", err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+	if err := a.checkDB(); err != nil {
+		return
+	}
+
+	applied, conflicts := 0, 0
+	for _, ev := range events {
+		result, err := a.replayOfflineEvent(ev)
+		if err != nil {
+			log.Printf("Offline replay of %s event %s failed, will retry: %v", ev.Type, ev.UID, err)
+			_, _ = journal.Exec(`UPDATE offline_events SET attempts = attempts + 1, last_error = ? WHERE id = ?`, err.Error(), ev.ID)
+			break
+		}
+
+		status := "replayed"
+		if result.Conflict {
+			status = "conflict"
+			conflicts++
+		} else {
+			applied++
+		}
+		if _, err := journal.Exec(`
+			UPDATE offline_events
+			SET status = ?, attempts = attempts + 1, last_error = ?, replayed_at = ?
+			WHERE id = ?
+		`, status, nullString(result.Detail), a.now(), ev.ID); err != nil {
+			// The server already holds the event UID, so the next pass just marks it done.
+			log.Printf("Failed to mark offline event %s as %s: %v", ev.UID, status, err)
+		}
+
+		if ev.Type == offlineEventLogin && !result.Conflict {
+			a.clearSessionOfflineEvent(ev.UID, int(result.ResultID.Int64))
+		}
+		a.notifyOfflineReplay(ev, result)
+	}
+
+	if applied > 0 || conflicts > 0 {
+		log.Printf("Offline queue replayed: %d applied, %d conflict(s)", applied, conflicts)
+	}
+
+	cutoff := a.now().AddDate(0, 0, -offlineJournalRetentionDays)
+	_, _ = journal.Exec(`DELETE FROM offline_events WHERE status <> 'pending' AND replayed_at < ?`, cutoff)
+}
+
+func loadPendingOfflineEvents(journal *sql.DB) ([]offlineEvent, error) {
+	rows, err := journal.Query(`
+		SELECT id, event_uid, event_type, user_id, station, payload, occurred_at, last_seen_at
+		FROM offline_events
+		WHERE status = 'pending'
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []offlineEvent
+	for rows.Next() {
+		var ev offlineEvent
+		if err := rows.Scan(&ev.ID, &ev.UID, &ev.Type, &ev.UserID, &ev.Station, &ev.Payload, &ev.OccurredAt, &ev.LastSeenAt); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// replayOfflineEvent applies one event and records its UID in a single transaction.
+// An event whose UID is already on the server is reported with its recorded outcome.
+func (a *App) replayOfflineEvent(ev offlineEvent) (offlineReplayResult, error) {
+	var result offlineReplayResult
+
+	var outcome string
+	var detail sql.NullString
+	err := a.db.QueryRow(`
+		SELECT outcome, detail, result_id FROM offline_replay_log WHERE event_uid = ?
+	`, ev.UID).Scan(&outcome, &detail, &result.ResultID)
+	if err == nil {
+		result.Conflict = outcome == "conflict"
+		result.Detail = detail.String
+		return result, nil
+	}
+	if err != sql.ErrNoRows {
+		return result, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	switch ev.Type {
+	case offlineEventLogin:
+		result, err = a.replayOfflineLogin(tx, ev)
+	case offlineEventTimeIn:
+		result, err = a.replayOfflineTimeIn(tx, ev)
+	case offlineEventFeedback:
+		result, err = replayOfflineFeedback(tx, ev)
+	default:
+		result = offlineReplayResult{Conflict: true, Detail: fmt.Sprintf("unknown offline event type %q", ev.Type)}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	outcome = "applied"
+	if result.Conflict {
+		outcome = "conflict"
+	}
+	_, err = tx.Exec(`
+		INSERT INTO offline_replay_log (event_uid, event_type, user_id, station, occurred_at, outcome, detail, result_id)
+		VALUES (?, ?, (SELECT id FROM users WHERE id = ?), ?, ?, ?, ?, ?)
+	`, ev.UID, ev.Type, ev.UserID, ev.Station, ev.OccurredAt, outcome, nullString(truncateString(result.Detail, 500)), result.ResultID)
+	if err != nil {
+		return result, fmt.Errorf("failed to record offline replay: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// replayOfflineLogin writes the login_entries row for an offline login. The logout time is the
+// last activity seen offline, unless the user is still logged in on this PC right now, in
+// which case the row is left open with a fresh heartbeat.
+func (a *App) replayOfflineLogin(tx *sql.Tx, ev offlineEvent) (offlineReplayResult, error) {
+	var accountStatus string
+	err := tx.QueryRow(`SELECT account_status FROM users WHERE id = ?`, ev.UserID).Scan(&accountStatus)
+	if err == sql.ErrNoRows {
+		return offlineReplayResult{Conflict: true, Detail: "account no longer exists"}, nil
+	}
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	if accountStatus != "active" {
+		return offlineReplayResult{Conflict: true, Detail: fmt.Sprintf("account is %s on the server", accountStatus)}, nil
+	}
+
+	lastSeen := ev.OccurredAt
+	if ev.LastSeenAt.Valid && ev.LastSeenAt.Time.After(lastSeen) {
+		lastSeen = ev.LastSeenAt.Time
+	}
+	logoutTime := sql.NullTime{Time: lastSeen, Valid: true}
+	if session := a.currentSession(); session != nil && session.OfflineEventUID == ev.UID {
+		// The session is live, so its login log heartbeats from now on.
+		lastSeen, logoutTime = a.now(), sql.NullTime{}
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO log_entries (user_id, pc_number, login_time, logout_time, last_seen_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, ev.UserID, ev.Station, ev.OccurredAt, logoutTime, lastSeen)
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	logID, err := res.LastInsertId()
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	return offlineReplayResult{ResultID: sql.NullInt64{Int64: logID, Valid: true}}, nil
+}
+
+// replayOfflineTimeIn re-runs the StudentTimeIn checks against the moment the student tapped
+// Time In, and marks them present or late based on that moment rather than the sync time.
+func (a *App) replayOfflineTimeIn(tx *sql.Tx, ev offlineEvent) (offlineReplayResult, error) {
+	var payload offlineTimeInPayload
+	if err := json.Unmarshal([]byte(ev.Payload), &payload); err != nil {
+		return offlineReplayResult{Conflict: true, Detail: "unreadable time-in event"}, nil
+	}
+
+	var classID int
+	var attendanceDate, sessionStatus string
+	var classDuration sql.NullInt64
+	var openedAt, pausedAt, closedAt sql.NullTime
+	err := tx.QueryRow(`
+		SELECT class_id, DATE_FORMAT(attendance_date, '%Y-%m-%d'), status, class_duration_minutes, opened_at, paused_at, closed_at
+		FROM attendance_sessions
+		WHERE session_id = ?
+	`, payload.SessionID).Scan(&classID, &attendanceDate, &sessionStatus, &classDuration, &openedAt, &pausedAt, &closedAt)
+	if err == sql.ErrNoRows {
+		return offlineReplayResult{Conflict: true, Detail: fmt.Sprintf("attendance session %d no longer exists", payload.SessionID)}, nil
+	}
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+
+	at := ev.OccurredAt
+	conflict := ""
+	switch {
+	case openedAt.Valid && at.Before(openedAt.Time):
+		conflict = "time-in was recorded before the session opened"
+	case closedAt.Valid && !at.Before(closedAt.Time):
+		conflict = fmt.Sprintf("session was closed at %s, before the time-in", formatTime(closedAt.Time))
+	case sessionStatus != "open" && !closedAt.Valid:
+		conflict = "attendance session is closed"
+	case pausedAt.Valid && !at.Before(pausedAt.Time):
+		conflict = "attendance session was paused at the time of the time-in"
+	case openedAt.Valid && classDuration.Int64 > 0 && at.After(openedAt.Time.Add(time.Duration(classDuration.Int64)*time.Minute)):
+		conflict = "class duration was over at the time of the time-in"
+	}
+	if conflict != "" {
+		return offlineReplayResult{Conflict: true, Detail: conflict}, nil
+	}
+
+	var enrolled int
+	err = tx.QueryRow(`
+		SELECT 1 FROM joined_classes
+		WHERE class_id = ? AND student_id = ? AND status IN ('join', 'added', 'active') AND (is_archived = 0 OR is_archived IS NULL)
+	`, classID, ev.UserID).Scan(&enrolled)
+	if err == sql.ErrNoRows {
+		return offlineReplayResult{Conflict: true, Detail: "student is no longer enrolled in this class"}, nil
+	}
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+
+	if err := checkTimeInLocation(tx, classID, payload.ComputerLab); err != nil {
+		return offlineReplayResult{Conflict: true, Detail: err.Error()}, nil
+	}
+
+	// The code is checked against the window the student tapped in, not the sync time.
+	secret, rotation, err := attendanceCodeSettings(tx, payload.SessionID)
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	if codeResult := matchAttendanceCode(secret, payload.SessionID, payload.Code, at, rotation); codeResult != "" {
+		recordAttendanceCodeAttempt(tx, payload.SessionID, ev.UserID, ev.Station, payload.Code, codeResult, at)
+		return offlineReplayResult{Conflict: true, Detail: fmt.Sprintf("time-in code was %s", codeResult)}, nil
+	}
+
+	if err := insertMissingAttendanceRows(tx, payload.SessionID, classID, attendanceDate); err != nil {
+		return offlineReplayResult{}, err
+	}
+
+	var attendanceID int64
+	var currentStatus sql.NullString
+	var timeInAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, status, time_in_at FROM attendance
+		WHERE class_id = ? AND student_id = ? AND attendance_date = ? AND session_id = ? AND COALESCE(is_archived, 0) = 0
+	`, classID, ev.UserID, attendanceDate, payload.SessionID).Scan(&attendanceID, &currentStatus, &timeInAt)
+	if err == sql.ErrNoRows {
+		return offlineReplayResult{Conflict: true, Detail: "attendance record not found"}, nil
+	}
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+
+	result := offlineReplayResult{ResultID: sql.NullInt64{Int64: attendanceID, Valid: true}}
+	if timeInAt.Valid {
+		result.Detail = "student had already timed in"
+		return result, nil
+	}
+	previous := strings.ToLower(strings.TrimSpace(currentStatus.String))
+	switch previous {
+	case "":
+	case "absent", "excused":
+		result.Detail = fmt.Sprintf("replaced the %s mark given while the station was offline", previous)
+	default:
+		return offlineReplayResult{Conflict: true, Detail: fmt.Sprintf("attendance was already marked %s", previous)}, nil
+	}
+
+	rule, _, err := loadSessionLatenessRule(tx, payload.SessionID)
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	status, minutesLate := rule.evaluate(openedAt, at)
+	remarks := *normalizeAttendanceRemark(status, sql.NullString{})
+
+	_, err = tx.Exec(`
+		UPDATE attendance
+		SET status = ?, remarks = ?, minutes_late = ?, time_in_at = ?, time_in_station = ?, present_since = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND time_in_at IS NULL
+	`, status, remarks, minutesLate, at, nullString(timeInStationLabel(ev.Station)), at, attendanceID)
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	// A session that closed while the station was offline ends the presence at its close.
+	if sessionStatus == "closed" && closedAt.Valid {
+		if _, err := a.timeOutAttendance(tx, `a.id = ?`, []interface{}{attendanceID}, closedAt.Time, timeOutSourceSessionClosed); err != nil {
+			return offlineReplayResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// replayOfflineFeedback inserts a queued feedback report with its original submission time.
+func replayOfflineFeedback(tx *sql.Tx, ev offlineEvent) (offlineReplayResult, error) {
+	var entry equipmentFeedbackEntry
+	if err := json.Unmarshal([]byte(ev.Payload), &entry); err != nil {
+		return offlineReplayResult{Conflict: true, Detail: "unreadable feedback event"}, nil
+	}
+
+	var exists int
+	err := tx.QueryRow(`SELECT 1 FROM users WHERE id = ?`, ev.UserID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return offlineReplayResult{Conflict: true, Detail: "reporting account no longer exists"}, nil
+	}
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+
+	feedbackID, err := insertEquipmentFeedback(tx, ev.UserID, entry, sql.NullTime{Time: ev.OccurredAt, Valid: true})
+	if err != nil {
+		return offlineReplayResult{}, err
+	}
+	return offlineReplayResult{ResultID: sql.NullInt64{Int64: feedbackID, Valid: true}}, nil
+}
+
+// notifyOfflineReplay sends the notifications the live call would have sent, plus a conflict
+// report to admins and the affected user.
+func (a *App) notifyOfflineReplay(ev offlineEvent, result offlineReplayResult) {
+	label := strings.ReplaceAll(ev.Type, "_", "-")
+	if result.Conflict {
+		msg := fmt.Sprintf("Offline %s from %s at %s could not be applied: %s.", label, ev.Station, formatTime(ev.OccurredAt), result.Detail)
+		go a.createNotificationForRole("admin", "offline_sync", "Offline Sync Conflict", msg, "warning", notifRef("offline_event"), nil)
+		if ev.Type != offlineEventLogin {
+			go a.createNotification(ev.UserID, "offline_sync", "Offline Record Not Saved",
+				fmt.Sprintf("Your %s from %s at %s could not be saved: %s.", label, ev.Station, formatTime(ev.OccurredAt), result.Detail),
+				"warning", notifRef("offline_event"), nil)
+		}
+		return
+	}
+
+	switch ev.Type {
+	case offlineEventTimeIn:
+		if !result.ResultID.Valid {
+			return
+		}
+		go func(studentID int, attendanceID int64, occurredAt time.Time) {
+			var status string
+			var sessionID int
+			if err := a.db.QueryRow(`SELECT COALESCE(status, ''), COALESCE(session_id, 0) FROM attendance WHERE id = ?`, attendanceID).Scan(&status, &sessionID); err != nil || status == "" {
+				return
+			}
+			tone := "success"
+			if status == "late" {
+				tone = "warning"
+			}
+			label := strings.ToUpper(status[:1]) + status[1:]
+			msg := fmt.Sprintf("Your offline time-in at %s was synced as %s.", formatTime(occurredAt), label)
+			a.createNotification(studentID, "attendance", "Attendance Recorded", msg, tone, notifRef("attendance_session"), notifRefID(sessionID))
+		}(ev.UserID, result.ResultID.Int64, ev.OccurredAt)
+	case offlineEventFeedback:
+		var entry equipmentFeedbackEntry
+		if json.Unmarshal([]byte(ev.Payload), &entry) != nil {
+			return
+		}
+		go a.createNotificationForRole("working_student", "feedback",
+			"New Equipment Feedback",
+			fmt.Sprintf("%s submitted feedback for %s.", entry.ReporterName, entry.PCNumber),
+			"info", notifRef("feedback"), nil)
+	}
+}
+
+// ==============================================================================
+// STATUS
+// ==============================================================================
+
+// GetOfflineQueueStatus reports how many events this station still has to sync.
+// It reads only the local journal, so the login screen can show it while offline.
+func (a *App) GetOfflineQueueStatus() (OfflineQueueStatus, error) {
+	var status OfflineQueueStatus
+	journal, err := a.offlineJournalDB(false)
+	if err != nil {
+		return status, err
+	}
+	if journal == nil {
+		return status, nil
+	}
+
+	_ = journal.QueryRow(`SELECT COUNT(*) FROM offline_events WHERE status = 'pending'`).Scan(&status.Pending)
+	_ = journal.QueryRow(`SELECT COUNT(*) FROM offline_events WHERE status = 'conflict'`).Scan(&status.Conflicts)
+	var lastError sql.NullString
+	_ = journal.QueryRow(`
+		SELECT last_error FROM offline_events
+		WHERE status = 'pending' AND last_error IS NOT NULL
+		ORDER BY id LIMIT 1
+	`).Scan(&lastError)
+	status.LastError = lastError.String
+	return status, nil
+}
+
+// GetOfflineReplayLog lists events replayed from station offline queues, newest first.
+// outcome may be "applied", "conflict" or empty for both.
+func (a *App) GetOfflineReplayLog(outcome string, limit int) ([]OfflineReplayEntry, error) {
+	if _, err := a.requireRole("GetOfflineReplayLog"); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	outcome = strings.ToLower(strings.TrimSpace(outcome))
+	if outcome != "" && outcome != "applied" && outcome != "conflict" {
+		return nil, fmt.Errorf("invalid outcome: %s", outcome)
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := a.db.Query(`
+		SELECT r.event_uid, r.event_type, r.user_id, COALESCE(u.username, ''), r.station,
+		       DATE_FORMAT(r.occurred_at, '%Y-%m-%d %H:%i:%s'),
+		       DATE_FORMAT(r.replayed_at, '%Y-%m-%d %H:%i:%s'),
+		       r.outcome, r.detail
+		FROM offline_replay_log r
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE (? = '' OR r.outcome = ?)
+		ORDER BY r.replayed_at DESC, r.occurred_at DESC
+		LIMIT ?
+	`, outcome, outcome, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offline replay log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []OfflineReplayEntry{}
+	for rows.Next() {
+		var entry OfflineReplayEntry
+		var userID sql.NullInt64
+		var detail sql.NullString
+		if err := rows.Scan(&entry.EventUID, &entry.EventType, &userID, &entry.UserName, &entry.Station,
+			&entry.OccurredAt, &entry.ReplayedAt, &entry.Outcome, &detail); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			entry.UserID = &id
+		}
+		entry.Detail = scanNullString(detail)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
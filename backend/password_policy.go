@@ -0,0 +1,302 @@
+package backend
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ==============================================================================
+// PASSWORD POLICY
+// ==============================================================================
+//
+// On top of ValidateStrongPassword's composition rules, new passwords must meet the
+// configured minimum length, must not appear in the common-password denylist file and
+// must not repeat one of the user's recent passwords. Passwords chosen by an admin
+// (new accounts and resets) are flagged so the user has to replace them at the next
+// login, and passwords older than the configured maximum age are treated the same way.
+// An admin may set the account ID as that temporary password; only the composition
+// rules are waived for it.
+
+const (
+	defaultPasswordMinLength    = 8
+	maxPasswordMinLength        = 64
+	defaultPasswordHistoryDepth = 5
+	maxPasswordHistoryDepth     = 24
+	defaultPasswordMaxAgeDays   = 0
+	maxPasswordMaxAgeDays       = 3650
+)
+
+type passwordPolicy struct {
+	minLength    int
+	historyDepth int
+	maxAgeDays   int
+	denylistPath string
+	// denylist holds the lowercased entries of the denylist file; nil when none is set.
+	denylist map[string]struct{}
+}
+
+// loadPasswordPolicy reads the [policy] password_min_length, password_history_depth,
+// password_max_age_days and password_denylist_file settings (or their PASSWORD_*
+// environment overrides). A history depth or maximum age of 0 turns that check off.
+// A denylist file that cannot be read is returned as an error alongside the rest of
+// the policy, so callers can report it instead of silently skipping the check.
+func loadPasswordPolicy() (passwordPolicy, error) {
+	policy := passwordPolicy{
+		minLength:    loadPolicyInt("PASSWORD_MIN_LENGTH", "password_min_length", 8, maxPasswordMinLength, defaultPasswordMinLength),
+		historyDepth: loadPolicyInt("PASSWORD_HISTORY_DEPTH", "password_history_depth", 0, maxPasswordHistoryDepth, defaultPasswordHistoryDepth),
+		maxAgeDays:   loadPolicyInt("PASSWORD_MAX_AGE_DAYS", "password_max_age_days", 0, maxPasswordMaxAgeDays, defaultPasswordMaxAgeDays),
+		denylistPath: loadPolicyPath("PASSWORD_DENYLIST_FILE", "password_denylist_file"),
+	}
+	if policy.denylistPath == "" {
+		return policy, nil
+	}
+	denylist, err := loadPasswordDenylist(policy.denylistPath)
+	if err != nil {
+		return policy, fmt.Errorf("password denylist %s could not be read: %w", policy.denylistPath, err)
+	}
+	policy.denylist = denylist
+	return policy, nil
+}
+
+// passwordPolicy loads the password policy and reports an unreadable denylist file to
+// the admins, once per path and error, so a typo in the setting does not go unnoticed.
+func (a *App) passwordPolicy() passwordPolicy {
+	policy, err := loadPasswordPolicy()
+	if err == nil {
+		a.passwordDenylistProblem.Store("")
+		return policy
+	}
+	if previous, _ := a.passwordDenylistProblem.Swap(err.Error()).(string); previous != err.Error() {
+		log.Printf("Password policy: %v; common passwords are not being rejected", err)
+		a.createNotificationForRole("admin", "security", "Password Denylist Unreadable",
+			fmt.Sprintf("The password denylist file %s could not be read, so common passwords are not being rejected. Check password_denylist_file in config.ini.", policy.denylistPath),
+			"warning", notifRef("password_policy"), nil)
+	}
+	return policy
+}
+
+// validate checks a new password against everything except the user's history.
+func (p passwordPolicy) validate(password string) error {
+	if err := ValidateStrongPassword(password); err != nil {
+		return err
+	}
+	return p.validateTemporary(password)
+}
+
+// validateTemporary checks the rules that also hold for a temporary account-ID password:
+// the minimum length and the denylist.
+func (p passwordPolicy) validateTemporary(password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	if len(password) < p.minLength {
+		return fmt.Errorf("password must be at least %d characters long", p.minLength)
+	}
+	if _, listed := p.denylist[strings.ToLower(password)]; listed {
+		return fmt.Errorf("this password is too common or has appeared in a data breach - choose a different one")
+	}
+	return nil
+}
+
+// passwordDenylistCache keeps the last denylist file read, reloaded when its size or
+// modification time changes, so validations do not re-read the whole file.
+var passwordDenylistCache struct {
+	sync.Mutex
+	path    string
+	size    int64
+	modTime time.Time
+	entries map[string]struct{}
+}
+
+// loadPasswordDenylist returns the lowercased entries of the denylist file, one password
+// per line. Blank lines and lines starting with # are skipped.
+func loadPasswordDenylist(path string) (map[string]struct{}, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	cache := &passwordDenylistCache
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.entries != nil && cache.path == path && cache.size == info.Size() && cache.modTime.Equal(info.ModTime()) {
+		return cache.entries, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	cache.path, cache.size, cache.modTime, cache.entries = path, info.Size(), info.ModTime(), entries
+	return entries, nil
+}
+
+// validateNewPassword checks a password a user is about to set. userID is 0 for an
+// account that does not exist yet, in which case there is no history to compare.
+func (a *App) validateNewPassword(userID int, password string) error {
+	policy := a.passwordPolicy()
+	if err := policy.validate(password); err != nil {
+		return err
+	}
+	return a.checkPasswordHistory(policy, userID, password)
+}
+
+// validateTemporaryIDPassword checks an account ID an admin sets as the password. The
+// user has to replace it at the first login, so only the composition rules are waived.
+func (a *App) validateTemporaryIDPassword(userID int, password string) error {
+	policy := a.passwordPolicy()
+	if err := policy.validateTemporary(password); err != nil {
+		return err
+	}
+	return a.checkPasswordHistory(policy, userID, password)
+}
+
+// checkPasswordHistory refuses the user's current password and their recent ones.
+func (a *App) checkPasswordHistory(policy passwordPolicy, userID int, password string) error {
+	if userID <= 0 || policy.historyDepth == 0 {
+		return nil
+	}
+
+	hashes, err := a.recentPasswordHashes(userID, policy.historyDepth)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return fmt.Errorf("password was used recently - choose one you have not used in your last %d passwords", policy.historyDepth)
+		}
+	}
+	return nil
+}
+
+// recentPasswordHashes returns the current password hash followed by up to depth-1
+// replaced ones, newest first.
+func (a *App) recentPasswordHashes(userID, depth int) ([]string, error) {
+	var current string
+	if err := a.db.QueryRow(`SELECT password FROM users WHERE id = ?`, userID).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to load password history: %w", err)
+	}
+	hashes := []string{current}
+	if depth <= 1 {
+		return hashes, nil
+	}
+
+	rows, err := a.db.Query(`
+		SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+	`, userID, depth-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load password history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to load password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// setUserPassword hashes and stores a new password, moving the old hash into the
+// password history. mustChange is true when someone other than the user chose it.
+func (a *App) setUserPassword(userID int, password string, mustChange bool) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	if err := tx.QueryRow(`SELECT password FROM users WHERE id = ?`, userID).Scan(&previous); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to load current password: %w", err)
+	}
+
+	now := a.now()
+	if _, err := tx.Exec(`
+		INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, ?, ?)
+	`, userID, previous, now); err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE users SET password = ?, must_change_password = ?, password_changed_at = ?, updated_at = ?
+		WHERE id = ?
+	`, string(hashed), mustChange, now, now, userID); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := prunePasswordHistory(tx, userID, maxPasswordHistoryDepth); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password change: %w", err)
+	}
+	return nil
+}
+
+// prunePasswordHistory keeps only the newest keep history rows of a user.
+func prunePasswordHistory(exec dbExecutor, userID, keep int) error {
+	var oldestKeptID int
+	err := exec.QueryRow(`
+		SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+	`, userID, keep-1).Scan(&oldestKeptID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+	if _, err := exec.Exec(`DELETE FROM password_history WHERE user_id = ? AND id < ?`, userID, oldestKeptID); err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+	return nil
+}
+
+// passwordExpired reports whether a password last set at changedAt (or, if it was never
+// changed, when the account was created) is older than the configured maximum age.
+func (a *App) passwordExpired(changedAt sql.NullTime, createdAt time.Time) bool {
+	maxAgeDays := a.passwordPolicy().maxAgeDays
+	if maxAgeDays == 0 {
+		return false
+	}
+	setAt := createdAt
+	if changedAt.Valid {
+		setAt = changedAt.Time
+	}
+	return a.now().After(setAt.AddDate(0, 0, maxAgeDays))
+}
@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminChosenPasswordMustBeChangedAndNotReused(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.seedDepartment("CCS")
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(denylist, []byte("# common passwords\nSpring-2024!a\n"), 0644); err != nil {
+		t.Fatalf("write denylist: %v", err)
+	}
+	t.Setenv("PASSWORD_DENYLIST_FILE", denylist)
+	t.Setenv("PASSWORD_MIN_LENGTH", "10")
+
+	e.loginAs("A-0001")
+	if err := e.app.CreateUser("Sh0rt!pw", "", "Ana", "", "Cruz", "student", "", "2024-00001", "ana@example.com", "09171234567", "CCS"); err == nil {
+		t.Fatalf("a password below the minimum length was accepted")
+	}
+	if err := e.app.CreateUser("2024-00001", "", "Ana", "", "Cruz", "student", "", "2024-00001", "ana@example.com", "09171234567", "CCS"); err != nil {
+		t.Fatalf("CreateUser with the temporary ID password: %v", err)
+	}
+
+	user, err := e.app.Login("2024-00001", "2024-00001")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !user.MustChangePassword || user.PasswordExpired {
+		t.Fatalf("Login = %+v, want a forced password change", user)
+	}
+
+	if err := e.app.ChangePassword("2024-00001", "2024-00001", "SPRING-2024!a"); err == nil || !strings.Contains(err.Error(), "common") {
+		t.Fatalf("a denylisted password was accepted: %v", err)
+	}
+	if err := e.app.ChangePassword("2024-00001", "2024-00001", "Fresh-Start-1"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := e.app.ChangePassword("2024-00001", "Fresh-Start-1", "Second-Pass-2"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := e.app.ChangePassword("2024-00001", "Second-Pass-2", "Fresh-Start-1"); err == nil {
+		t.Fatalf("a recent password was reused")
+	}
+
+	user, err = e.app.Login("2024-00001", "Second-Pass-2")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if user.MustChangePassword {
+		t.Fatalf("the flag stayed set after the user chose a password")
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM password_history WHERE user_id = ?`, user.ID); n != 2 {
+		t.Errorf("password history rows = %d, want 2", n)
+	}
+}
+
+func TestPasswordExpiresAfterMaxAge(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+
+	if user := e.loginAs("T-0001"); user.MustChangePassword {
+		t.Fatalf("a password expired with no maximum age configured")
+	}
+
+	t.Setenv("PASSWORD_MAX_AGE_DAYS", "30")
+	e.clock.Advance(31 * 24 * time.Hour)
+	user := e.loginAs("T-0001")
+	if !user.MustChangePassword || !user.PasswordExpired {
+		t.Fatalf("Login = %+v, want an expired password", user)
+	}
+	if err := e.app.ChangePassword("T-0001", testPassword, testPassword); err == nil {
+		t.Fatalf("the expired password was set again")
+	}
+	if err := e.app.ChangePassword("T-0001", testPassword, "N3w-Passw0rd"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if user, err := e.app.Login("T-0001", "N3w-Passw0rd"); err != nil || user.MustChangePassword {
+		t.Fatalf("Login after the change = %+v, %v", user, err)
+	}
+}
+
+func TestUnreadableDenylistIsReportedToAdmins(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	t.Setenv("PASSWORD_DENYLIST_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+
+	if _, err := loadPasswordPolicy(); err == nil {
+		t.Fatalf("a missing denylist file was not reported")
+	}
+	// Reported once, however often the policy is loaded.
+	e.app.passwordPolicy()
+	e.app.passwordPolicy()
+	if got := e.queryInt(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND title = ?`, adminID, "Password Denylist Unreadable"); got != 1 {
+		t.Errorf("admin denylist notices = %d, want 1", got)
+	}
+}
+
+func TestTemporaryIDPasswordsFollowTheLengthDenylistAndHistory(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.seedDepartment("CCS")
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(denylist, []byte("2024-00002\n"), 0644); err != nil {
+		t.Fatalf("write denylist: %v", err)
+	}
+	t.Setenv("PASSWORD_DENYLIST_FILE", denylist)
+	t.Setenv("PASSWORD_MIN_LENGTH", "10")
+
+	e.loginAs("A-0001")
+	if err := e.app.CreateUser("T-01", "", "Tess", "", "Reyes", "teacher", "T-01", "", "tess@example.com", "09171234567", "CCS"); err == nil || !strings.Contains(err.Error(), "at least 10") {
+		t.Errorf("an account ID below the minimum length was accepted as the password: %v", err)
+	}
+	if err := e.app.CreateUser("2024-00002", "", "Ben", "", "Lim", "student", "", "2024-00002", "ben@example.com", "09171234568", "CCS"); err == nil || !strings.Contains(err.Error(), "common") {
+		t.Errorf("a denylisted account ID was accepted as the password: %v", err)
+	}
+	if err := e.app.CreateUser("2024-00001", "", "Ana", "", "Cruz", "student", "", "2024-00001", "ana@example.com", "09171234567", "CCS"); err != nil {
+		t.Fatalf("CreateUser with the temporary ID password: %v", err)
+	}
+	studentID := e.queryInt(`SELECT id FROM users WHERE username = '2024-00001'`)
+
+	// Admin resets are checked against the history, the account ID included.
+	if err := e.app.ResetPasswordByRole(adminID, studentID, "2024-00001"); err == nil {
+		t.Errorf("the account ID was reset while it is still the current password")
+	}
+	if err := e.app.ResetPasswordByRole(adminID, studentID, "Reset-Pass-1"); err != nil {
+		t.Fatalf("ResetPasswordByRole: %v", err)
+	}
+	if err := e.app.ResetPasswordByRole(adminID, studentID, "Reset-Pass-1"); err == nil {
+		t.Errorf("an admin reset reused a recent password")
+	}
+}
@@ -12,8 +12,6 @@ import (
 	"log"
 	"os"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -26,47 +24,6 @@ type sqlExecer interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
-func (a *App) ensureUserRecoveryCodesTable() error {
-	if err := a.checkDB(); err != nil {
-		return err
-	}
-
-	query := `
-		CREATE TABLE IF NOT EXISTS user_recovery_codes (
-			user_id INT PRIMARY KEY,
-			code_hash CHAR(64) NOT NULL,
-			code_ciphertext TEXT NULL,
-			created_at DATETIME NOT NULL DEFAULT NOW(),
-			rotated_at DATETIME NULL,
-			updated_at DATETIME NOT NULL DEFAULT NOW(),
-			CONSTRAINT fk_urc_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)
-	`
-
-	if _, err := a.db.Exec(query); err != nil {
-		return fmt.Errorf("failed to ensure user_recovery_codes table: %w", err)
-	}
-
-	var codeCipherExists int
-	if err := a.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM INFORMATION_SCHEMA.COLUMNS
-		WHERE TABLE_SCHEMA = DATABASE()
-		  AND TABLE_NAME = 'user_recovery_codes'
-		  AND COLUMN_NAME = 'code_ciphertext'
-	`).Scan(&codeCipherExists); err != nil {
-		return fmt.Errorf("failed to inspect user_recovery_codes.code_ciphertext column: %w", err)
-	}
-
-	if codeCipherExists == 0 {
-		if _, err := a.db.Exec(`ALTER TABLE user_recovery_codes ADD COLUMN code_ciphertext TEXT NULL AFTER code_hash`); err != nil {
-			return fmt.Errorf("failed to add user_recovery_codes.code_ciphertext column: %w", err)
-		}
-	}
-
-	return nil
-}
-
 func hashRecoveryCode(code string) string {
 	sum := sha256.Sum256([]byte(strings.TrimSpace(strings.ToUpper(code))))
 	return hex.EncodeToString(sum[:])
@@ -99,6 +56,9 @@ func generateRecoveryCode() (string, error) {
 	return string(buf), nil
 }
 
+// recoveryCodeEncryptionKey derives the legacy key used before versioned keys existed
+// (ciphertexts with a NULL key ID). Without RECOVERY_CODE_SECRET it depends on the
+// database settings, which is why new ciphertexts use the keys in recovery_code_keys.
 func recoveryCodeEncryptionKey() ([]byte, error) {
 	secret := strings.TrimSpace(os.Getenv("RECOVERY_CODE_SECRET"))
 	if secret == "" {
@@ -120,12 +80,7 @@ func recoveryCodeEncryptionKey() ([]byte, error) {
 	return key, nil
 }
 
-func encryptRecoveryCode(code string) (string, error) {
-	key, err := recoveryCodeEncryptionKey()
-	if err != nil {
-		return "", err
-	}
-
+func sealRecoveryPayload(key []byte, plainText string) (string, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize recovery-code cipher: %w", err)
@@ -141,17 +96,12 @@ func encryptRecoveryCode(code string) (string, error) {
 		return "", fmt.Errorf("failed to generate recovery-code nonce: %w", err)
 	}
 
-	cipherText := gcm.Seal(nil, nonce, []byte(code), nil)
+	cipherText := gcm.Seal(nil, nonce, []byte(plainText), nil)
 	payload := append(nonce, cipherText...)
 	return base64.StdEncoding.EncodeToString(payload), nil
 }
 
-func decryptRecoveryCode(cipherText string) (string, error) {
-	key, err := recoveryCodeEncryptionKey()
-	if err != nil {
-		return "", err
-	}
-
+func openRecoveryPayload(key []byte, cipherText string) (string, error) {
 	rawPayload, err := base64.StdEncoding.DecodeString(strings.TrimSpace(cipherText))
 	if err != nil {
 		return "", fmt.Errorf("invalid encrypted recovery code")
@@ -182,16 +132,17 @@ func decryptRecoveryCode(cipherText string) (string, error) {
 	return string(plainText), nil
 }
 
-func upsertRecoveryCodeHash(exec sqlExecer, userID int, codeHash, codeCiphertext string) error {
+func upsertRecoveryCodeHash(exec sqlExecer, userID int, codeHash, codeCiphertext string, keyID int) error {
 	_, err := exec.Exec(`
-		INSERT INTO user_recovery_codes (user_id, code_hash, code_ciphertext, created_at, updated_at)
-		VALUES (?, ?, ?, NOW(), NOW())
+		INSERT INTO user_recovery_codes (user_id, code_hash, code_ciphertext, code_key_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW())
 		ON DUPLICATE KEY UPDATE
 			code_hash = VALUES(code_hash),
 			code_ciphertext = VALUES(code_ciphertext),
+			code_key_id = VALUES(code_key_id),
 			rotated_at = NOW(),
 			updated_at = NOW()
-	`, userID, codeHash, codeCiphertext)
+	`, userID, codeHash, codeCiphertext, keyID)
 	if err != nil {
 		return fmt.Errorf("failed to store recovery code: %w", err)
 	}
@@ -199,7 +150,7 @@ func upsertRecoveryCodeHash(exec sqlExecer, userID int, codeHash, codeCiphertext
 	return nil
 }
 
-func issueRecoveryCode(exec sqlExecer, userID int) (string, error) {
+func issueRecoveryCode(exec dbExecutor, userID int) (string, error) {
 	if userID <= 0 {
 		return "", fmt.Errorf("invalid user ID")
 	}
@@ -209,12 +160,12 @@ func issueRecoveryCode(exec sqlExecer, userID int) (string, error) {
 		return "", err
 	}
 
-	encryptedCode, err := encryptRecoveryCode(code)
+	encryptedCode, keyID, err := encryptRecoverySecret(exec, code)
 	if err != nil {
 		return "", fmt.Errorf("failed to protect recovery code: %w", err)
 	}
 
-	if err := upsertRecoveryCodeHash(exec, userID, hashRecoveryCode(code), encryptedCode); err != nil {
+	if err := upsertRecoveryCodeHash(exec, userID, hashRecoveryCode(code), encryptedCode, keyID); err != nil {
 		return "", err
 	}
 
@@ -223,7 +174,8 @@ func issueRecoveryCode(exec sqlExecer, userID int) (string, error) {
 
 func (a *App) getStoredRecoveryCode(userID int) (string, error) {
 	var storedEncrypted sql.NullString
-	err := a.db.QueryRow(`SELECT code_ciphertext FROM user_recovery_codes WHERE user_id = ?`, userID).Scan(&storedEncrypted)
+	var keyID sql.NullInt64
+	err := a.db.QueryRow(`SELECT code_ciphertext, code_key_id FROM user_recovery_codes WHERE user_id = ?`, userID).Scan(&storedEncrypted, &keyID)
 	if err == sql.ErrNoRows {
 		return "", fmt.Errorf("no recovery code is available for this account yet")
 	}
@@ -235,16 +187,17 @@ func (a *App) getStoredRecoveryCode(userID int) (string, error) {
 		return "", fmt.Errorf("recovery code cannot be displayed for this account yet; generate a new one")
 	}
 
-	decrypted, err := decryptRecoveryCode(storedEncrypted.String)
+	decrypted, err := decryptRecoverySecret(a.db, storedEncrypted.String, keyID)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode recovery code")
+		log.Printf("Failed to decrypt recovery code of user %d: %v", userID, err)
+		return "", fmt.Errorf("failed to decode recovery code; generate a new one")
 	}
 
 	return decrypted, nil
 }
 
 func (a *App) issueRecoveryCodeForUser(userID int) (string, error) {
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return "", err
 	}
 
@@ -314,9 +267,6 @@ func (a *App) VerifyPasswordResetIdentifier(identifier string) error {
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
-		return err
-	}
 
 	requesterUserID, _, err := a.resolveRecoveryResetUserByIdentifier(identifier)
 	if err != nil {
@@ -340,9 +290,6 @@ func (a *App) VerifyRecoveryCodeForIdentifier(identifier, recoveryCode string) e
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
-		return err
-	}
 
 	requesterUserID, _, err := a.resolveRecoveryResetUserByIdentifier(identifier)
 	if err != nil {
@@ -357,10 +304,7 @@ func (a *App) ResetPasswordWithIdentifierRecoveryCode(identifier, recoveryCode,
 	if err := a.checkDB(); err != nil {
 		return err
 	}
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
-		return err
-	}
-	if err := ValidateStrongPassword(newPassword); err != nil {
+	if err := ValidatePassword(newPassword); err != nil {
 		return err
 	}
 
@@ -373,12 +317,15 @@ func (a *App) ResetPasswordWithIdentifierRecoveryCode(identifier, recoveryCode,
 		return err
 	}
 
-	newPasswordHashBytes, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("failed to process password")
+	if err := a.requireLocalPassword(requesterUserID); err != nil {
+		return err
+	}
+	if err := a.validateNewPassword(requesterUserID, newPassword); err != nil {
+		return err
 	}
 
-	if _, err := a.db.Exec(`UPDATE users SET password = ?, updated_at = NOW() WHERE id = ?`, string(newPasswordHashBytes), requesterUserID); err != nil {
+	if err := a.setUserPassword(requesterUserID, newPassword, false); err != nil {
+		log.Printf("Failed recovery password reset for user %d: %v", requesterUserID, err)
 		return fmt.Errorf("failed to update password")
 	}
 
@@ -394,13 +341,13 @@ func (a *App) ResetPasswordWithIdentifierRecoveryCode(identifier, recoveryCode,
 
 // GetUserRecoveryCode returns the currently assigned recovery code for the user.
 func (a *App) GetUserRecoveryCode(userID int) (string, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GetUserRecoveryCode", userID); err != nil {
 		return "", err
 	}
-	if err := ValidatePositiveID(userID, "user ID"); err != nil {
+	if err := a.checkDB(); err != nil {
 		return "", err
 	}
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
+	if err := ValidatePositiveID(userID, "user ID"); err != nil {
 		return "", err
 	}
 
@@ -414,13 +361,13 @@ func (a *App) GetUserRecoveryCode(userID int) (string, error) {
 
 // GenerateUserRecoveryCode rotates and returns a new recovery code for the user.
 func (a *App) GenerateUserRecoveryCode(userID int) (string, error) {
-	if err := a.checkDB(); err != nil {
+	if _, err := a.requireActingUser("GenerateUserRecoveryCode", userID); err != nil {
 		return "", err
 	}
-	if err := ValidatePositiveID(userID, "user ID"); err != nil {
+	if err := a.checkDB(); err != nil {
 		return "", err
 	}
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
+	if err := ValidatePositiveID(userID, "user ID"); err != nil {
 		return "", err
 	}
 
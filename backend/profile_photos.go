@@ -21,6 +21,9 @@ const (
 // SaveProfilePhoto saves a base64 data URL to the profile_photos table.
 // photoDataURL should be in format: "data:image/jpeg;base64,/9j/4AAQ..."
 func (a *App) SaveProfilePhoto(userID int, photoDataURL string) error {
+	if _, err := a.requireActingUser("SaveProfilePhoto", userID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -64,6 +67,9 @@ func (a *App) SaveProfilePhoto(userID int, photoDataURL string) error {
 
 // DeleteProfilePhoto removes a user's profile photo from the database.
 func (a *App) DeleteProfilePhoto(userID int) error {
+	if _, err := a.requireActingUser("DeleteProfilePhoto", userID); err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
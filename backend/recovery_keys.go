@@ -0,0 +1,383 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// ==============================================================================
+// RECOVERY-CODE KEY STORE
+// ==============================================================================
+//
+// Recovery codes and authenticator secrets are sealed with random AES keys kept in
+// recovery_code_keys, and each ciphertext stores the ID of its key. The newest key that
+// is not retired encrypts new values; older keys stay readable until an admin rotates,
+// which re-encrypts every row with a fresh key. Key material is always wrapped with
+// RECOVERY_CODE_SECRET, or with the secret in recovery_code_key_file, so a copy of the
+// database alone does not reveal the codes; without either no key is stored. Ciphertexts
+// from before the key store have no key ID and use the legacy key from
+// recoveryCodeEncryptionKey.
+
+const recoveryKeySize = 32
+
+// recoverySecretColumns lists every encrypted column and the column holding its key ID.
+var recoverySecretColumns = []struct {
+	kind, table, cipherColumn, keyColumn string
+}{
+	{"recovery_code", "user_recovery_codes", "code_ciphertext", "code_key_id"},
+	{"authenticator_secret", "user_totp", "secret_ciphertext", "secret_key_id"},
+}
+
+type dbRowsQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// RecoveryKeyProblem is an encrypted value that cannot be decrypted with its key.
+type RecoveryKeyProblem struct {
+	Kind     string `json:"kind"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	KeyID    int    `json:"key_id"` // 0 for the legacy key
+	Error    string `json:"error"`
+}
+
+// RecoveryKeyHealth reports the state of the key store and every encrypted value.
+type RecoveryKeyHealth struct {
+	ActiveKeyID       int                  `json:"active_key_id"`
+	KeyCount          int                  `json:"key_count"`
+	WrappedWithSecret bool                 `json:"wrapped_with_secret"`
+	Checked           int                  `json:"checked"`
+	LegacyRows        int                  `json:"legacy_rows"`
+	Problems          []RecoveryKeyProblem `json:"problems"`
+}
+
+// RecoveryKeyRotation is the result of RotateRecoveryCodeKey.
+type RecoveryKeyRotation struct {
+	KeyID         int                  `json:"key_id"`
+	Reencrypted   int                  `json:"reencrypted"`
+	Undecryptable []RecoveryKeyProblem `json:"undecryptable"`
+}
+
+// recoveryKeyWrappingKey returns the key that wraps stored key material. It comes from
+// RECOVERY_CODE_SECRET, or else from the secret kept in recovery_code_key_file
+// (RECOVERY_CODE_KEY_FILE), which every PC needs a copy of.
+func recoveryKeyWrappingKey() ([]byte, error) {
+	secret := strings.TrimSpace(os.Getenv("RECOVERY_CODE_SECRET"))
+	if secret == "" {
+		path := loadPolicyPath("RECOVERY_CODE_KEY_FILE", "recovery_code_key_file")
+		if path == "" {
+			return nil, fmt.Errorf("RECOVERY_CODE_SECRET or recovery_code_key_file must be set to protect recovery codes")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recovery-code key file: %w", err)
+		}
+		if secret = strings.TrimSpace(string(data)); secret == "" {
+			return nil, fmt.Errorf("recovery-code key file %s is empty", path)
+		}
+	}
+	sum := sha256.Sum256([]byte("recovery-code-keys|" + secret))
+	return sum[:], nil
+}
+
+// createRecoveryKey stores a new random key and returns its ID. createdBy is nil when
+// the key is created automatically for the first encryption.
+func createRecoveryKey(exec dbExecutor, createdBy interface{}) (int, []byte, error) {
+	key := make([]byte, recoveryKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return 0, nil, fmt.Errorf("failed to generate recovery-code key: %w", err)
+	}
+
+	// A key stored next to the values it protects would protect nothing, so refuse instead.
+	wrappingKey, err := recoveryKeyWrappingKey()
+	if err != nil {
+		return 0, nil, err
+	}
+	material, err := sealRecoveryPayload(wrappingKey, base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result, err := exec.Exec(`
+		INSERT INTO recovery_code_keys (key_material, is_wrapped, created_by_user_id) VALUES (?, ?, ?)
+	`, material, true, createdBy)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to store recovery-code key: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read recovery-code key ID: %w", err)
+	}
+	return int(id), key, nil
+}
+
+func unwrapRecoveryKey(id int, material string, wrapped bool) ([]byte, error) {
+	if wrapped {
+		wrappingKey, err := recoveryKeyWrappingKey()
+		if err != nil {
+			return nil, fmt.Errorf("recovery-code key %d is wrapped: %w", id, err)
+		}
+		opened, err := openRecoveryPayload(wrappingKey, material)
+		if err != nil {
+			return nil, fmt.Errorf("recovery-code key %d cannot be unwrapped with the current recovery-code secret", id)
+		}
+		material = opened
+	}
+	key, err := base64.StdEncoding.DecodeString(material)
+	if err != nil || len(key) != recoveryKeySize {
+		return nil, fmt.Errorf("recovery-code key %d is corrupt", id)
+	}
+	return key, nil
+}
+
+// activeRecoveryKey returns the key for new ciphertexts, creating the first one if needed.
+func activeRecoveryKey(exec dbExecutor) (int, []byte, error) {
+	var id int
+	var material string
+	var wrapped bool
+	err := exec.QueryRow(`
+		SELECT id, key_material, is_wrapped FROM recovery_code_keys
+		WHERE retired_at IS NULL ORDER BY id DESC LIMIT 1
+	`).Scan(&id, &material, &wrapped)
+	if err == sql.ErrNoRows {
+		return createRecoveryKey(exec, nil)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to load recovery-code key: %w", err)
+	}
+	key, err := unwrapRecoveryKey(id, material, wrapped)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, key, nil
+}
+
+// loadRecoveryKey returns the key with the given ID, or the legacy key for a NULL ID.
+func loadRecoveryKey(exec dbExecutor, keyID sql.NullInt64) ([]byte, error) {
+	if !keyID.Valid {
+		return recoveryCodeEncryptionKey()
+	}
+
+	var material string
+	var wrapped bool
+	err := exec.QueryRow(`SELECT key_material, is_wrapped FROM recovery_code_keys WHERE id = ?`, keyID.Int64).Scan(&material, &wrapped)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("recovery-code key %d does not exist", keyID.Int64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recovery-code key: %w", err)
+	}
+	return unwrapRecoveryKey(int(keyID.Int64), material, wrapped)
+}
+
+// encryptRecoverySecret seals a recovery code or authenticator secret with the active
+// key and returns the ciphertext with the key's ID.
+func encryptRecoverySecret(exec dbExecutor, plainText string) (string, int, error) {
+	keyID, key, err := activeRecoveryKey(exec)
+	if err != nil {
+		return "", 0, err
+	}
+	cipherText, err := sealRecoveryPayload(key, plainText)
+	if err != nil {
+		return "", 0, err
+	}
+	return cipherText, keyID, nil
+}
+
+// decryptRecoverySecret opens a ciphertext sealed with the key keyID.
+func decryptRecoverySecret(exec dbExecutor, cipherText string, keyID sql.NullInt64) (string, error) {
+	key, err := loadRecoveryKey(exec, keyID)
+	if err != nil {
+		return "", err
+	}
+	return openRecoveryPayload(key, cipherText)
+}
+
+type recoverySecretRow struct {
+	column     int
+	userID     int
+	username   string
+	cipherText string
+	keyID      sql.NullInt64
+}
+
+func loadRecoverySecretRows(query dbRowsQuerier) ([]recoverySecretRow, error) {
+	var secrets []recoverySecretRow
+	for i, col := range recoverySecretColumns {
+		rows, err := query.Query(fmt.Sprintf(`
+			SELECT s.user_id, u.username, s.%s, s.%s
+			FROM %s s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.%s IS NOT NULL AND s.%s <> ''
+			ORDER BY s.user_id
+		`, col.cipherColumn, col.keyColumn, col.table, col.cipherColumn, col.cipherColumn))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s rows: %w", col.kind, err)
+		}
+		for rows.Next() {
+			row := recoverySecretRow{column: i}
+			if err := rows.Scan(&row.userID, &row.username, &row.cipherText, &row.keyID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read %s row: %w", col.kind, err)
+			}
+			secrets = append(secrets, row)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s rows: %w", col.kind, err)
+		}
+	}
+	return secrets, nil
+}
+
+// decryptRecoverySecretRows decrypts every row, caching keys by ID. failed marks the
+// rows that cannot be decrypted, which are also described in problems.
+func decryptRecoverySecretRows(exec dbExecutor, secrets []recoverySecretRow) (plainTexts []string, failed []bool, problems []RecoveryKeyProblem) {
+	keys := make(map[sql.NullInt64][]byte)
+	keyErrors := make(map[sql.NullInt64]error)
+	plainTexts = make([]string, len(secrets))
+	failed = make([]bool, len(secrets))
+	problems = []RecoveryKeyProblem{}
+
+	for i, row := range secrets {
+		key, found := keys[row.keyID]
+		keyErr := keyErrors[row.keyID]
+		if !found && keyErr == nil {
+			key, keyErr = loadRecoveryKey(exec, row.keyID)
+			keys[row.keyID], keyErrors[row.keyID] = key, keyErr
+		}
+
+		err := keyErr
+		if err == nil {
+			plainTexts[i], err = openRecoveryPayload(key, row.cipherText)
+		}
+		if err != nil {
+			problems = append(problems, RecoveryKeyProblem{
+				Kind:     recoverySecretColumns[row.column].kind,
+				UserID:   row.userID,
+				Username: row.username,
+				KeyID:    int(row.keyID.Int64),
+				Error:    err.Error(),
+			})
+			plainTexts[i], failed[i] = "", true
+		}
+	}
+	return plainTexts, failed, problems
+}
+
+// CheckRecoveryCodeKeys decrypts every stored recovery code and authenticator secret and
+// reports the ones that can no longer be read, for example after RECOVERY_CODE_SECRET or
+// the database password behind the legacy key changed.
+func (a *App) CheckRecoveryCodeKeys() (*RecoveryKeyHealth, error) {
+	if _, err := a.requireRole("CheckRecoveryCodeKeys"); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	health := &RecoveryKeyHealth{}
+	if err := a.db.QueryRow(`SELECT COUNT(*) FROM recovery_code_keys`).Scan(&health.KeyCount); err != nil {
+		return nil, fmt.Errorf("failed to count recovery-code keys: %w", err)
+	}
+	var active sql.NullInt64
+	var wrapped sql.NullBool
+	err := a.db.QueryRow(`
+		SELECT id, is_wrapped FROM recovery_code_keys WHERE retired_at IS NULL ORDER BY id DESC LIMIT 1
+	`).Scan(&active, &wrapped)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load recovery-code key: %w", err)
+	}
+	health.ActiveKeyID = int(active.Int64)
+	health.WrappedWithSecret = wrapped.Bool
+
+	secrets, err := loadRecoverySecretRows(a.db)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range secrets {
+		if !row.keyID.Valid {
+			health.LegacyRows++
+		}
+	}
+	health.Checked = len(secrets)
+	_, _, health.Problems = decryptRecoverySecretRows(a.db, secrets)
+
+	if len(health.Problems) > 0 {
+		log.Printf("Recovery-code key check: %d of %d encrypted values cannot be decrypted", len(health.Problems), health.Checked)
+	}
+	return health, nil
+}
+
+// RotateRecoveryCodeKey creates a new key, re-encrypts every recovery code and
+// authenticator secret with it and retires the previous keys, all in one transaction.
+// Values that cannot be decrypted are left as they are and listed in the result; their
+// owners need a new recovery code or authenticator.
+func (a *App) RotateRecoveryCodeKey() (*RecoveryKeyRotation, error) {
+	session, err := a.requireRole("RotateRecoveryCodeKey")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	secrets, err := loadRecoverySecretRows(tx)
+	if err != nil {
+		return nil, err
+	}
+	plainTexts, failed, problems := decryptRecoverySecretRows(tx, secrets)
+
+	keyID, key, err := createRecoveryKey(tx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &RecoveryKeyRotation{KeyID: keyID, Undecryptable: problems}
+	for i, row := range secrets {
+		if failed[i] {
+			continue
+		}
+		sealed, err := sealRecoveryPayload(key, plainTexts[i])
+		if err != nil {
+			return nil, err
+		}
+		col := recoverySecretColumns[row.column]
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ?, %s = ? WHERE user_id = ?`,
+			col.table, col.cipherColumn, col.keyColumn), sealed, keyID, row.userID); err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt %s of user %d: %w", col.kind, row.userID, err)
+		}
+		rotation.Reencrypted++
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE recovery_code_keys SET retired_at = ? WHERE id <> ? AND retired_at IS NULL
+	`, a.now(), keyID); err != nil {
+		return nil, fmt.Errorf("failed to retire old recovery-code keys: %w", err)
+	}
+	if err := a.recordAuditEvent(tx, session, "rotate_recovery_code_key", "recovery_code_key", keyID, nil, auditValues{
+		"reencrypted": rotation.Reencrypted, "undecryptable": len(rotation.Undecryptable),
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit recovery-code key rotation: %w", err)
+	}
+
+	log.Printf("Recovery-code key rotated to %d by user %d: %d re-encrypted, %d undecryptable",
+		keyID, session.UserID, rotation.Reencrypted, len(rotation.Undecryptable))
+	return rotation, nil
+}
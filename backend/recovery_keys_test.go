@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateRecoveryCodeKeyReencryptsAndReportsBrokenRows(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	legacyID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	brokenID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+
+	// A code stored before the key store existed uses the legacy key and no key ID.
+	legacyKey, err := recoveryCodeEncryptionKey()
+	if err != nil {
+		t.Fatalf("recoveryCodeEncryptionKey: %v", err)
+	}
+	legacyCipher, _ := sealRecoveryPayload(legacyKey, "ABCDE23456")
+	e.exec(`INSERT INTO user_recovery_codes (user_id, code_hash, code_ciphertext) VALUES (?, ?, ?)`,
+		legacyID, hashRecoveryCode("ABCDE23456"), legacyCipher)
+	// This one was sealed with a key nobody has any more.
+	lostKey := make([]byte, recoveryKeySize)
+	rand.Read(lostKey)
+	lostCipher, _ := sealRecoveryPayload(lostKey, "ZZZZZ23456")
+	e.exec(`INSERT INTO user_recovery_codes (user_id, code_hash, code_ciphertext) VALUES (?, ?, ?)`,
+		brokenID, hashRecoveryCode("ZZZZZ23456"), lostCipher)
+
+	e.loginAs("A-0001")
+	enrollment, err := e.app.BeginTwoFactorEnrollment(adminID)
+	if err != nil {
+		t.Fatalf("BeginTwoFactorEnrollment: %v", err)
+	}
+	firstKeyID := e.queryInt(`SELECT secret_key_id FROM user_totp WHERE user_id = ?`, adminID)
+
+	health, err := e.app.CheckRecoveryCodeKeys()
+	if err != nil {
+		t.Fatalf("CheckRecoveryCodeKeys: %v", err)
+	}
+	if health.Checked != 3 || health.LegacyRows != 2 || health.ActiveKeyID != firstKeyID || !health.WrappedWithSecret {
+		t.Fatalf("health = %+v", health)
+	}
+	if len(health.Problems) != 1 || health.Problems[0].UserID != brokenID || health.Problems[0].Kind != "recovery_code" {
+		t.Fatalf("problems = %+v, want only the lost-key row", health.Problems)
+	}
+
+	rotation, err := e.app.RotateRecoveryCodeKey()
+	if err != nil {
+		t.Fatalf("RotateRecoveryCodeKey: %v", err)
+	}
+	if rotation.KeyID == firstKeyID || rotation.Reencrypted != 2 || len(rotation.Undecryptable) != 1 {
+		t.Fatalf("rotation = %+v", rotation)
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM recovery_code_keys WHERE retired_at IS NULL`); n != 1 {
+		t.Errorf("active keys after rotation = %d, want 1", n)
+	}
+	if n := e.queryInt(`SELECT code_key_id FROM user_recovery_codes WHERE user_id = ?`, legacyID); n != rotation.KeyID {
+		t.Errorf("legacy row key = %d, want %d", n, rotation.KeyID)
+	}
+
+	// The re-encrypted authenticator secret still verifies codes.
+	if err := e.app.ConfirmTwoFactorEnrollment(adminID, e.totpNow(enrollment.Secret)); err != nil {
+		t.Fatalf("ConfirmTwoFactorEnrollment after rotation: %v", err)
+	}
+	e.app.Logout(adminID)
+
+	// The migrated legacy code is still readable with the new key.
+	e.loginAs("2024-00001")
+	code, err := e.app.GetUserRecoveryCode(legacyID)
+	if err != nil || code != formatRecoveryCode("ABCDE23456") {
+		t.Fatalf("GetUserRecoveryCode = %q, %v", code, err)
+	}
+}
+
+func TestRecoveryKeysAreNeverStoredUnwrapped(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	t.Setenv("RECOVERY_CODE_SECRET", "")
+
+	e.loginAs("A-0001")
+	if _, err := e.app.BeginTwoFactorEnrollment(adminID); err == nil {
+		t.Fatalf("enrollment without a recovery-code secret was accepted")
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM recovery_code_keys`); n != 0 {
+		t.Fatalf("stored %d recovery-code key(s) without a secret", n)
+	}
+
+	// A key file shared by the lab PCs works in place of the environment variable.
+	keyFile := filepath.Join(t.TempDir(), "recovery.key")
+	if err := os.WriteFile(keyFile, []byte("lab-wide recovery secret\n"), 0600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	t.Setenv("RECOVERY_CODE_KEY_FILE", keyFile)
+	if _, err := e.app.BeginTwoFactorEnrollment(adminID); err != nil {
+		t.Fatalf("BeginTwoFactorEnrollment with a key file: %v", err)
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM recovery_code_keys WHERE is_wrapped = 0`); n != 0 {
+		t.Errorf("%d recovery-code key(s) stored unwrapped", n)
+	}
+}
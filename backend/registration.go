@@ -68,12 +68,9 @@ func (a *App) SubmitRegistration(req RegistrationRequest) (RegistrationSubmissio
 	if err := a.checkDB(); err != nil {
 		return RegistrationSubmissionResult{}, err
 	}
-	if err := a.ensureUserRecoveryCodesTable(); err != nil {
-		return RegistrationSubmissionResult{}, err
-	}
 
 	// Validation
-	if err := validateRegistration(req); err != nil {
+	if err := validateRegistration(req, a.passwordPolicy()); err != nil {
 		return RegistrationSubmissionResult{}, err
 	}
 
@@ -254,6 +251,9 @@ func (a *App) SubmitRegistration(req RegistrationRequest) (RegistrationSubmissio
 
 // GetPendingRegistrations returns all registrations awaiting approval
 func (a *App) GetPendingRegistrations() ([]PendingRegistration, error) {
+	if _, err := a.requireRole("GetPendingRegistrations"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -310,6 +310,10 @@ func (a *App) GetPendingRegistrations() ([]PendingRegistration, error) {
 
 // ProcessRegistration approves or rejects a student registration
 func (a *App) ProcessRegistration(req ApprovalRequest) error {
+	session, err := a.requireActingUser("ProcessRegistration", req.ApprovedBy)
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -375,6 +379,15 @@ func (a *App) ProcessRegistration(req ApprovalRequest) error {
 		return fmt.Errorf("failed to update approval record: %v", err)
 	}
 
+	after := auditValues{"account_status": newStatus, "approval_status": approvalStatus}
+	if req.Action == "reject" {
+		after["rejection_reason"] = req.RejectionReason
+	}
+	if err := a.recordAuditEvent(tx, session, "process_registration", "user", req.UserID,
+		auditValues{"account_status": "pending", "approval_status": "pending"}, after); err != nil {
+		return err
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %v", err)
@@ -400,6 +413,9 @@ func (a *App) ProcessRegistration(req ApprovalRequest) error {
 
 // GetRegistrationHistory returns processed registration records with name and status only (no email, user ID, or student ID).
 func (a *App) GetRegistrationHistory() ([]RegistrationHistoryEntry, error) {
+	if _, err := a.requireRole("GetRegistrationHistory"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -452,7 +468,7 @@ func (a *App) GetRegistrationHistory() ([]RegistrationHistoryEntry, error) {
 // VALIDATION HELPERS
 // ==============================================================================
 
-func validateRegistration(req RegistrationRequest) error {
+func validateRegistration(req RegistrationRequest, policy passwordPolicy) error {
 	// Student ID validation (format: YYYY-NNNNN or WS-YYYY-NNN)
 	if err := ValidateStudentID(req.StudentID); err != nil {
 		return err
@@ -494,7 +510,7 @@ func validateRegistration(req RegistrationRequest) error {
 	}
 
 	// Password validation (strong policy)
-	if err := ValidateStrongPassword(req.Password); err != nil {
+	if err := policy.validate(req.Password); err != nil {
 		return err
 	}
 	if req.Password != req.ConfirmPassword {
@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+func testRegistration(studentID, email string) RegistrationRequest {
+	return RegistrationRequest{
+		StudentID:       studentID,
+		DepartmentCode:  "CCS",
+		LastName:        "Garcia",
+		FirstName:       "Rosa",
+		ContactNumber:   "09171234567",
+		Email:           email,
+		Password:        testPassword,
+		ConfirmPassword: testPassword,
+	}
+}
+
+func TestRegistrationApproval(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedDepartment("CCS")
+	approverID := e.seedUser("working_student", "2023-00100", "Wes", "Tan")
+
+	result, err := e.app.SubmitRegistration(testRegistration("2024-10001", "rosa@example.com"))
+	if err != nil {
+		t.Fatalf("SubmitRegistration: %v", err)
+	}
+	if result.RecoveryCode == "" {
+		t.Errorf("SubmitRegistration returned no recovery code")
+	}
+
+	if _, err := e.app.Login("2024-10001", testPassword); err == nil || !strings.Contains(err.Error(), "pending") {
+		t.Fatalf("login before approval: err = %v, want pending error", err)
+	}
+	if _, err := e.app.SubmitRegistration(testRegistration("2024-10001", "rosa@example.com")); err == nil {
+		t.Fatalf("duplicate pending registration was accepted")
+	}
+
+	e.loginAs("2023-00100")
+	pending, err := e.app.GetPendingRegistrations()
+	if err != nil {
+		t.Fatalf("GetPendingRegistrations: %v", err)
+	}
+	if len(pending) != 1 || pending[0].StudentID != "2024-10001" {
+		t.Fatalf("pending registrations = %+v, want the new student", pending)
+	}
+
+	err = e.app.ProcessRegistration(ApprovalRequest{UserID: pending[0].UserID, ApprovedBy: approverID, Action: "approve"})
+	if err != nil {
+		t.Fatalf("ProcessRegistration approve: %v", err)
+	}
+	if got := e.queryString(`SELECT status FROM registration_approvals WHERE user_id = ?`, pending[0].UserID); got != "approved" {
+		t.Errorf("approval record status = %q, want approved", got)
+	}
+
+	user := e.loginAs("2024-10001")
+	if user.Role != "student" {
+		t.Errorf("approved user role = %q, want student", user.Role)
+	}
+}
+
+func TestRegistrationRejectionAllowsResubmission(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedDepartment("CCS")
+	approverID := e.seedUser("working_student", "2023-00100", "Wes", "Tan")
+
+	if _, err := e.app.SubmitRegistration(testRegistration("2024-10002", "rosa@example.com")); err != nil {
+		t.Fatalf("SubmitRegistration: %v", err)
+	}
+	userID := e.queryInt(`SELECT id FROM users WHERE username = ?`, "2024-10002")
+
+	e.loginAs("2023-00100")
+	if err := e.app.ProcessRegistration(ApprovalRequest{UserID: userID, ApprovedBy: approverID, Action: "reject"}); err == nil {
+		t.Fatalf("rejection without a reason was accepted")
+	}
+	err := e.app.ProcessRegistration(ApprovalRequest{UserID: userID, ApprovedBy: approverID, Action: "reject", RejectionReason: "Blurry ID"})
+	if err != nil {
+		t.Fatalf("ProcessRegistration reject: %v", err)
+	}
+
+	if _, err := e.app.Login("2024-10002", testPassword); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("login after rejection: err = %v, want rejected error", err)
+	}
+
+	if _, err := e.app.SubmitRegistration(testRegistration("2024-10002", "rosa@example.com")); err != nil {
+		t.Fatalf("re-registration after rejection: %v", err)
+	}
+	if got := e.queryString(`SELECT account_status FROM users WHERE id = ?`, userID); got != "pending" {
+		t.Errorf("re-registered account status = %q, want pending", got)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM registration_approvals WHERE user_id = ? AND status = 'pending'`, userID); got != 1 {
+		t.Errorf("pending approval records after re-registration = %d, want 1", got)
+	}
+}
+
+func TestProcessRegistrationRequiresApproverRole(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedDepartment("CCS")
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	if _, err := e.app.SubmitRegistration(testRegistration("2024-10003", "rosa@example.com")); err != nil {
+		t.Fatalf("SubmitRegistration: %v", err)
+	}
+	userID := e.queryInt(`SELECT id FROM users WHERE username = ?`, "2024-10003")
+
+	e.loginAs("2024-00001")
+	if err := e.app.ProcessRegistration(ApprovalRequest{UserID: userID, ApprovedBy: studentID, Action: "approve"}); err == nil {
+		t.Fatalf("a regular student was able to approve a registration")
+	}
+	if got := e.queryString(`SELECT account_status FROM users WHERE id = ?`, userID); got != "pending" {
+		t.Errorf("account status = %q, want pending", got)
+	}
+}
@@ -0,0 +1,371 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"digital-logbook-wails-app/backend/migrations"
+)
+
+// ==============================================================================
+// SCHEMA MIGRATIONS
+// ==============================================================================
+
+// SchemaVersionReport is returned to the admin settings page.
+type SchemaVersionReport = migrations.Report
+
+// goMigrations are migrations that need procedural checks and therefore live in Go
+// instead of an embedded .sql file. New tables and columns should normally be added
+// as the next numbered file under migrations/<dialect>/.
+func goMigrations(dialect string) []migrations.Migration {
+	if dialect != migrations.DialectMySQL {
+		return nil
+	}
+	return []migrations.Migration{
+		{
+			Version: 2,
+			Name:    "legacy_schema_catchup",
+			Up:      migrateLegacySchemaCatchup,
+		},
+		{
+			// The CHECK constraint's name depends on the server, so it is looked up.
+			// SQLite rebuilds the table in migrations/sqlite/0018 instead.
+			Version: 18,
+			Name:    "attendance_excused_status",
+			Up:      migrateAttendanceExcusedStatus,
+			Down:    revertAttendanceExcusedStatus,
+		},
+	}
+}
+
+func (a *App) newMigrationRunner() (*migrations.Runner, error) {
+	dialect := a.dbDriver()
+	runner, err := migrations.NewRunner(a.db, dialect, goMigrations(dialect)...)
+	if err != nil {
+		return nil, err
+	}
+	runner.AppliedBy = a.currentStationLabel()
+	runner.BaselineTable = "users"
+	return runner, nil
+}
+
+// runSchemaMigrations brings the connected database up to the latest schema version.
+// Called at startup, or on the first successful reconnect if the database was down then.
+func (a *App) runSchemaMigrations() error {
+	if a.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	runner, err := a.newMigrationRunner()
+	if err != nil {
+		return err
+	}
+
+	applied, err := runner.Up(context.Background())
+	if err != nil {
+		return err
+	}
+	a.schemaReady = true
+	if len(applied) == 0 {
+		log.Printf("Database schema is up to date (version %d)", runner.LatestVersion())
+	}
+
+	report, err := runner.Status(context.Background())
+	if err == nil && len(report.ModifiedFiles) > 0 {
+		log.Printf("Warning: applied schema migrations %v were edited after being applied", report.ModifiedFiles)
+	}
+	return nil
+}
+
+// GetSchemaVersion reports the applied and pending schema migrations.
+func (a *App) GetSchemaVersion() (SchemaVersionReport, error) {
+	if _, err := a.requireRole("GetSchemaVersion"); err != nil {
+		return SchemaVersionReport{}, err
+	}
+	if err := a.checkDB(); err != nil {
+		return SchemaVersionReport{}, err
+	}
+
+	runner, err := a.newMigrationRunner()
+	if err != nil {
+		return SchemaVersionReport{}, err
+	}
+
+	report, err := runner.Status(context.Background())
+	if err != nil {
+		return SchemaVersionReport{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return report, nil
+}
+
+// migrateLegacySchemaCatchup replaces the old ensure* startup checks. Databases created
+// from an older database_schema.sql are missing some of the tables and columns that the
+// app used to add lazily; fresh installs already have them, so every step is idempotent.
+func migrateLegacySchemaCatchup(ctx context.Context, db migrations.Executor) error {
+	hasColumn := func(table, column string) (bool, error) {
+		var exists int
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM INFORMATION_SCHEMA.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE()
+			  AND TABLE_NAME = ?
+			  AND COLUMN_NAME = ?
+		`, table, column).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("failed to check %s.%s column: %w", table, column, err)
+		}
+		return exists > 0, nil
+	}
+
+	addColumnIfMissing := func(table, column, definition string) error {
+		exists, err := hasColumn(table, column)
+		if err != nil || exists {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+			return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+		}
+		return nil
+	}
+
+	hasIndexOn := func(table, firstColumn string, columnCount int) (bool, error) {
+		var exists int
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM (
+				SELECT INDEX_NAME
+				FROM INFORMATION_SCHEMA.STATISTICS
+				WHERE TABLE_SCHEMA = DATABASE()
+				  AND TABLE_NAME = ?
+				GROUP BY INDEX_NAME
+				HAVING MAX(CASE WHEN SEQ_IN_INDEX = 1 THEN COLUMN_NAME END) = ?
+				   AND COUNT(*) = ?
+			) matching
+		`, table, firstColumn, columnCount).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("failed to inspect indexes on %s: %w", table, err)
+		}
+		return exists > 0, nil
+	}
+
+	createTables := []string{
+		`CREATE TABLE IF NOT EXISTS user_session_heartbeats (
+			user_id INT NOT NULL PRIMARY KEY,
+			last_seen DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			CONSTRAINT FK_user_session_heartbeats_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id              INT AUTO_INCREMENT PRIMARY KEY,
+			user_id         INT NOT NULL,
+			category        VARCHAR(50) NOT NULL,
+			title           VARCHAR(200) NOT NULL,
+			message         VARCHAR(500) NOT NULL,
+			tone            VARCHAR(20) NOT NULL DEFAULT 'info',
+			is_read         TINYINT(1) NOT NULL DEFAULT 0,
+			reference_type  VARCHAR(50) NULL,
+			reference_id    INT NULL,
+			created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			read_at         DATETIME NULL,
+			CONSTRAINT FK_notifications_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_recovery_codes (
+			user_id INT PRIMARY KEY,
+			code_hash CHAR(64) NOT NULL,
+			code_ciphertext TEXT NULL,
+			created_at DATETIME NOT NULL DEFAULT NOW(),
+			rotated_at DATETIME NULL,
+			updated_at DATETIME NOT NULL DEFAULT NOW(),
+			CONSTRAINT fk_urc_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS student_archived_classes (
+			student_id INT NOT NULL,
+			class_id INT NOT NULL,
+			archived_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			CONSTRAINT PK_student_archived_classes PRIMARY KEY (student_id, class_id),
+			CONSTRAINT FK_student_archived_classes_student FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+			CONSTRAINT FK_student_archived_classes_class FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS attendance_sessions (
+			session_id INT AUTO_INCREMENT PRIMARY KEY,
+			class_id INT NOT NULL,
+			attendance_date DATE NOT NULL,
+			session_name VARCHAR(255) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			is_archived TINYINT(1) NOT NULL DEFAULT 0,
+			class_duration_minutes INT NULL,
+			grace_period_minutes INT NULL,
+			opened_at DATETIME NULL,
+			paused_at DATETIME NULL,
+			closed_at DATETIME NULL,
+			created_by_user_id INT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			CONSTRAINT FK_attendance_sessions_class FOREIGN KEY (class_id) REFERENCES classes(class_id) ON DELETE CASCADE,
+			CONSTRAINT FK_attendance_sessions_creator FOREIGN KEY (created_by_user_id) REFERENCES users(id)
+		)`,
+	}
+	for _, stmt := range createTables {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"users", "archived_at", "DATETIME NULL"},
+		{"users", "deactivated_at", "DATETIME NULL"},
+		{"users", "deleted_at", "DATETIME NULL"},
+		{"departments", "is_archived", "TINYINT(1) NOT NULL DEFAULT 0"},
+		{"feedback", "admin_resolved_at", "DATETIME NULL"},
+		{"feedback", "additional_comments", "LONGTEXT NULL"},
+		{"feedback", "forward_notes", "LONGTEXT NULL"},
+		{"user_recovery_codes", "code_ciphertext", "TEXT NULL AFTER code_hash"},
+		{"attendance_sessions", "is_archived", "TINYINT(1) NOT NULL DEFAULT 0"},
+		{"attendance_sessions", "class_duration_minutes", "INT NULL"},
+		{"attendance_sessions", "grace_period_minutes", "INT NULL"},
+		{"attendance_sessions", "opened_at", "DATETIME NULL"},
+		{"attendance_sessions", "paused_at", "DATETIME NULL"},
+		{"attendance_sessions", "closed_at", "DATETIME NULL"},
+		{"attendance_sessions", "created_by_user_id", "INT NULL"},
+		{"attendance", "session_id", "INT NULL"},
+		{"attendance", "time_in_at", "DATETIME NULL"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE attendance_sessions SET created_by_user_id = 1 WHERE created_by_user_id IS NULL`); err != nil {
+		return fmt.Errorf("failed to backfill attendance_sessions.created_by_user_id: %w", err)
+	}
+
+	indexes := []struct {
+		table, name, columns, first string
+		count                       int
+	}{
+		{"notifications", "idx_notifications_user_unread", "user_id, is_read", "user_id", 2},
+		{"notifications", "idx_notifications_category", "category", "category", 1},
+		{"attendance", "idx_attendance_class_date_session_student", "class_id, attendance_date, session_id, student_id", "class_id", 4},
+	}
+	for _, idx := range indexes {
+		exists, err := hasIndexOn(idx.table, idx.first, idx.count)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX %s ON %s(%s)", idx.name, idx.table, idx.columns)); err != nil {
+			return fmt.Errorf("failed to create index %s: %w", idx.name, err)
+		}
+	}
+
+	// joined_classes.status once allowed a narrower vocabulary; replace whatever
+	// CHECK constraints exist with the current one.
+	if _, err := db.ExecContext(ctx, `ALTER TABLE joined_classes MODIFY COLUMN status VARCHAR(20) DEFAULT 'added'`); err != nil {
+		return fmt.Errorf("failed to widen joined_classes.status: %w", err)
+	}
+	if err := dropCheckConstraints(ctx, db, "joined_classes"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE joined_classes
+		ADD CONSTRAINT chk_joined_classes_status
+		CHECK (status IN ('join', 'left', 'added', 'removed'))
+	`); err != nil {
+		return fmt.Errorf("failed to add joined_classes status constraint: %w", err)
+	}
+
+	// Account lifecycle fields introduced after the first release.
+	if _, err := db.ExecContext(ctx, `
+		UPDATE users
+		SET archived_at = updated_at
+		WHERE account_status = 'archived'
+		  AND archived_at IS NULL
+	`); err != nil {
+		return fmt.Errorf("failed to backfill users.archived_at: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		UPDATE users
+		SET account_status = 'deleted', updated_at = NOW()
+		WHERE deleted_at IS NOT NULL
+		  AND account_status <> 'deleted'
+	`); err != nil {
+		return fmt.Errorf("failed to reconcile deleted accounts: %w", err)
+	}
+
+	return nil
+}
+
+// dropCheckConstraints drops every CHECK constraint on table. MySQL names them
+// <table>_chk_N and MariaDB after the column, so they are looked up by table.
+func dropCheckConstraints(ctx context.Context, db migrations.Executor, table string) error {
+	var checkNames []string
+	rows, err := db.QueryContext(ctx, `
+		SELECT CONSTRAINT_NAME
+		FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS
+		WHERE TABLE_SCHEMA = DATABASE()
+		  AND TABLE_NAME = ?
+		  AND CONSTRAINT_TYPE = 'CHECK'
+	`, table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s constraints: %w", table, err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		if safeName := strings.ReplaceAll(name, "`", ""); safeName != "" {
+			checkNames = append(checkNames, safeName)
+		}
+	}
+	rows.Close()
+	for _, name := range checkNames {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP CHECK `%s`", table, name)); err != nil {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT `%s`", table, name)); err != nil {
+				return fmt.Errorf("failed to drop %s constraint %s: %w", table, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateAttendanceExcusedStatus widens attendance.status to allow 'excused'.
+func migrateAttendanceExcusedStatus(ctx context.Context, db migrations.Executor) error {
+	return replaceAttendanceStatusCheck(ctx, db, "'present', 'absent', 'late', 'excused'")
+}
+
+// revertAttendanceExcusedStatus turns excused rows back into absences and restores the
+// original constraint.
+func revertAttendanceExcusedStatus(ctx context.Context, db migrations.Executor) error {
+	if _, err := db.ExecContext(ctx, `UPDATE attendance SET status = 'absent', remarks = 'Absent' WHERE status = 'excused'`); err != nil {
+		return fmt.Errorf("failed to revert excused attendance: %w", err)
+	}
+	return replaceAttendanceStatusCheck(ctx, db, "'present', 'absent', 'late'")
+}
+
+func replaceAttendanceStatusCheck(ctx context.Context, db migrations.Executor, statuses string) error {
+	if _, err := db.ExecContext(ctx, `ALTER TABLE attendance MODIFY COLUMN status VARCHAR(20) NULL DEFAULT NULL`); err != nil {
+		return fmt.Errorf("failed to widen attendance.status: %w", err)
+	}
+	if err := dropCheckConstraints(ctx, db, "attendance"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`
+		ALTER TABLE attendance
+		ADD CONSTRAINT chk_attendance_status
+		CHECK (status IN (%s) OR status IS NULL)
+	`, statuses)); err != nil {
+		return fmt.Errorf("failed to add attendance status constraint: %w", err)
+	}
+	return nil
+}
@@ -26,51 +26,57 @@ func (a *App) startSessionCleanupLoop(ctx context.Context) {
 	}
 }
 
-func (a *App) ensureSessionHeartbeatTable() error {
-	if err := a.checkDB(); err != nil {
+func (a *App) TouchSession(userID int) error {
+	session, err := a.requireActingUser("TouchSession", userID)
+	if err != nil {
 		return err
 	}
-
-	query := `
-		CREATE TABLE IF NOT EXISTS user_session_heartbeats (
-			user_id INT NOT NULL PRIMARY KEY,
-			last_seen DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			CONSTRAINT FK_user_session_heartbeats_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)
-	`
-
-	_, err := a.db.Exec(query)
-	return err
-}
-
-func (a *App) TouchSession(userID int) error {
-	if err := a.ensureSessionHeartbeatTable(); err != nil {
+	if session.OfflineEventUID != "" {
+		a.touchOfflineSession(session.OfflineEventUID)
+	}
+	if err := a.checkDB(); err != nil {
+		if session.OfflineEventUID != "" {
+			return nil
+		}
 		return err
 	}
 
 	query := `
 		INSERT INTO user_session_heartbeats (user_id, last_seen, created_at, updated_at)
-		VALUES (?, NOW(), NOW(), NOW())
+		VALUES (?, ?, ?, ?)
 		ON DUPLICATE KEY UPDATE
-			last_seen = NOW(),
-			updated_at = NOW()
+			last_seen = VALUES(last_seen),
+			updated_at = VALUES(updated_at)
 	`
 
-	_, err := a.db.Exec(query, userID)
+	now := a.now()
+	_, err = a.db.Exec(query, userID, now, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to update session heartbeat: %w", err)
 	}
+	// The login log's own heartbeat tells this station apart from others under the same user.
+	if session.LoginLogID != 0 {
+		if _, err := a.db.Exec(`
+			UPDATE log_entries SET last_seen_at = ? WHERE id = ? AND logout_time IS NULL
+		`, now, session.LoginLogID); err != nil {
+			return fmt.Errorf("failed to update login heartbeat: %w", err)
+		}
+	}
+	a.checkSessionTakenOver(session)
 	return nil
 }
 
 func (a *App) clearSessionHeartbeat(userID int) error {
-	if err := a.ensureSessionHeartbeatTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
-	_, err := a.db.Exec(`DELETE FROM user_session_heartbeats WHERE user_id = ?`, userID)
+	// Another station may still be logged in under the allow concurrent-login policy.
+	_, err := a.db.Exec(`
+		DELETE FROM user_session_heartbeats
+		WHERE user_id = ?
+			AND NOT EXISTS (SELECT 1 FROM log_entries WHERE user_id = ? AND logout_time IS NULL)
+	`, userID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to clear session heartbeat: %w", err)
 	}
@@ -78,22 +84,24 @@ func (a *App) clearSessionHeartbeat(userID int) error {
 }
 
 func (a *App) closeStaleSessions() error {
-	if err := a.ensureSessionHeartbeatTable(); err != nil {
+	if err := a.checkDB(); err != nil {
 		return err
 	}
 
+	// Each login log is closed on its own heartbeat, so a silent station is closed even
+	// while the same user heartbeats from another one. Logs from before per-login
+	// heartbeats fall back to their login time.
 	query := `
-		UPDATE log_entries le
-		LEFT JOIN user_session_heartbeats sh ON sh.user_id = le.user_id
-		SET le.logout_time = COALESCE(sh.last_seen, NOW())
-		WHERE le.logout_time IS NULL
-			AND (
-				(sh.user_id IS NULL AND le.login_time < DATE_SUB(NOW(), INTERVAL ? SECOND))
-				OR (sh.user_id IS NOT NULL AND sh.last_seen < DATE_SUB(NOW(), INTERVAL ? SECOND))
-			)
+		UPDATE log_entries
+		SET logout_time = COALESCE(last_seen_at, ?)
+		WHERE logout_time IS NULL
+			AND COALESCE(last_seen_at, login_time) < ?
 	`
 
-	result, err := a.db.Exec(query, sessionHeartbeatTimeoutSeconds, sessionHeartbeatTimeoutSeconds)
+	now := a.now()
+	cutoff := now.Add(-time.Duration(sessionHeartbeatTimeoutSeconds) * time.Second)
+	a.timeOutStaleStudents(cutoff)
+	result, err := a.db.Exec(query, now, cutoff)
 	if err != nil {
 		return fmt.Errorf("failed to close stale sessions: %w", err)
 	}
@@ -103,10 +111,12 @@ func (a *App) closeStaleSessions() error {
 		log.Printf("Closed %d stale session(s)", rowsAffected)
 	}
 
-	_, _ = a.db.Exec(`
+	if _, err := a.db.Exec(`
 		DELETE FROM user_session_heartbeats
-		WHERE last_seen < DATE_SUB(NOW(), INTERVAL ? SECOND)
-	`, sessionHeartbeatTimeoutSeconds)
+		WHERE last_seen < ?
+	`, cutoff); err != nil {
+		return fmt.Errorf("failed to remove stale session heartbeats: %w", err)
+	}
 
 	return nil
 }
@@ -120,10 +130,10 @@ func (a *App) CloseSessionsForCurrentHost() error {
 
 	result, err := a.db.Exec(`
 		UPDATE log_entries
-		SET logout_time = NOW()
+		SET logout_time = ?
 		WHERE logout_time IS NULL
 			AND pc_number = ?
-	`, stationLabel)
+	`, a.now(), stationLabel)
 	if err != nil {
 		return err
 	}
@@ -133,13 +143,15 @@ func (a *App) CloseSessionsForCurrentHost() error {
 		log.Printf("Closed %d active session(s) for station %s", rowsAffected, stationLabel)
 	}
 
-	_, _ = a.db.Exec(`
-		DELETE sh
-		FROM user_session_heartbeats sh
+	if _, err := a.db.Exec(`
+		DELETE FROM user_session_heartbeats
 		WHERE NOT EXISTS (
-			SELECT 1 FROM log_entries le WHERE le.user_id = sh.user_id AND le.logout_time IS NULL
+			SELECT 1 FROM log_entries le
+			WHERE le.user_id = user_session_heartbeats.user_id AND le.logout_time IS NULL
 		)
-	`)
+	`); err != nil {
+		return fmt.Errorf("failed to remove session heartbeats: %w", err)
+	}
 
 	return nil
 }
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func openLoginLogs(e *testEnv, userID int) int {
+	e.t.Helper()
+	return e.queryInt(`SELECT COUNT(*) FROM log_entries WHERE user_id = ? AND logout_time IS NULL`, userID)
+}
+
+func TestLoginHeartbeatAndStaleSessionCleanup(t *testing.T) {
+	e := newTestEnv(t)
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	user := e.loginAs("2024-00001")
+	if user.LoginLogID == 0 || user.SessionToken == "" {
+		t.Fatalf("login returned log ID %d and token %q", user.LoginLogID, user.SessionToken)
+	}
+	if got := openLoginLogs(e, studentID); got != 1 {
+		t.Fatalf("open login logs after login = %d, want 1", got)
+	}
+
+	// Regular heartbeats keep the login open well past the timeout.
+	for i := 0; i < 5; i++ {
+		e.clock.Advance(60 * time.Second)
+		if err := e.app.TouchSession(studentID); err != nil {
+			t.Fatalf("TouchSession: %v", err)
+		}
+		if err := e.app.closeStaleSessions(); err != nil {
+			t.Fatalf("closeStaleSessions: %v", err)
+		}
+	}
+	if got := openLoginLogs(e, studentID); got != 1 {
+		t.Fatalf("heartbeating login was closed")
+	}
+	lastSeen := e.clock.Now()
+
+	// The PC goes silent; once the timeout passes the login is closed at the last heartbeat.
+	e.clock.Advance(time.Duration(sessionHeartbeatTimeoutSeconds-10) * time.Second)
+	if err := e.app.closeStaleSessions(); err != nil {
+		t.Fatalf("closeStaleSessions: %v", err)
+	}
+	if got := openLoginLogs(e, studentID); got != 1 {
+		t.Fatalf("login closed before the heartbeat timeout")
+	}
+
+	e.clock.Advance(20 * time.Second)
+	if err := e.app.closeStaleSessions(); err != nil {
+		t.Fatalf("closeStaleSessions: %v", err)
+	}
+	if got := openLoginLogs(e, studentID); got != 0 {
+		t.Fatalf("stale login was not closed")
+	}
+	logout := e.queryString(`SELECT DATE_FORMAT(logout_time, '%Y-%m-%d %H:%i:%s') FROM log_entries WHERE id = ?`, user.LoginLogID)
+	if want := formatTime(lastSeen); logout != want {
+		t.Errorf("logout_time = %s, want last heartbeat %s", logout, want)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM user_session_heartbeats WHERE user_id = ?`, studentID); got != 0 {
+		t.Errorf("stale heartbeat row was not removed")
+	}
+}
+
+func TestLoginWithoutHeartbeatIsClosedAfterTimeout(t *testing.T) {
+	e := newTestEnv(t)
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	e.loginAs("2024-00001")
+	e.exec(`DELETE FROM user_session_heartbeats WHERE user_id = ?`, studentID)
+
+	e.clock.Advance(time.Duration(sessionHeartbeatTimeoutSeconds+1) * time.Second)
+	if err := e.app.closeStaleSessions(); err != nil {
+		t.Fatalf("closeStaleSessions: %v", err)
+	}
+	if got := openLoginLogs(e, studentID); got != 0 {
+		t.Fatalf("login without any heartbeat was not closed")
+	}
+}
+
+func TestLoginClosesPreviousOpenLogAndLogoutEndsSession(t *testing.T) {
+	e := newTestEnv(t)
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	first := e.loginAs("2024-00001")
+	e.clock.Advance(time.Minute)
+	second := e.loginAs("2024-00001")
+	if first.LoginLogID == second.LoginLogID {
+		t.Fatalf("second login reused log %d", first.LoginLogID)
+	}
+	if got := openLoginLogs(e, studentID); got != 1 {
+		t.Fatalf("open login logs after re-login = %d, want 1", got)
+	}
+
+	e.clock.Advance(time.Minute)
+	if err := e.app.Logout(studentID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if got := openLoginLogs(e, studentID); got != 0 {
+		t.Errorf("open login logs after logout = %d, want 0", got)
+	}
+	if err := e.app.TouchSession(studentID); err == nil {
+		t.Errorf("TouchSession succeeded after logout")
+	}
+}
+
+func TestStaleStationIsClosedWhileAnotherHeartbeats(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "5"
+	pc12 := e.otherStation("12")
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	t.Setenv("CONCURRENT_LOGIN_TEACHER", "allow")
+
+	silent, err := pc12.Login("T-0001", testPassword)
+	if err != nil {
+		t.Fatalf("Login on PC 12: %v", err)
+	}
+	loginAt := e.clock.Now()
+	here, err := e.app.Login("T-0001", testPassword)
+	if err != nil {
+		t.Fatalf("Login on PC 5: %v", err)
+	}
+
+	// Only PC 5 keeps heartbeating.
+	for i := 0; i < 4; i++ {
+		e.clock.Advance(60 * time.Second)
+		if err := e.app.TouchSession(teacherID); err != nil {
+			t.Fatalf("TouchSession: %v", err)
+		}
+		if err := e.app.closeStaleSessions(); err != nil {
+			t.Fatalf("closeStaleSessions: %v", err)
+		}
+	}
+
+	logout := e.queryString(`SELECT COALESCE(DATE_FORMAT(logout_time, '%Y-%m-%d %H:%i:%s'), '') FROM log_entries WHERE id = ?`, silent.LoginLogID)
+	if want := formatTime(loginAt); logout != want {
+		t.Errorf("PC 12 logout_time = %q, want its last heartbeat %s", logout, want)
+	}
+	if e.queryString(`SELECT COALESCE(logout_time, '') FROM log_entries WHERE id = ?`, here.LoginLogID) != "" {
+		t.Errorf("the heartbeating station's login was closed")
+	}
+}
+
+func TestClosingTheHostRemovesOrphanedHeartbeats(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "5"
+	studentID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	e.loginAs("2024-00001")
+	if err := e.app.TouchSession(studentID); err != nil {
+		t.Fatalf("TouchSession: %v", err)
+	}
+
+	if err := e.app.CloseSessionsForCurrentHost(); err != nil {
+		t.Fatalf("CloseSessionsForCurrentHost: %v", err)
+	}
+	if got := openLoginLogs(e, studentID); got != 0 {
+		t.Errorf("open login logs = %d, want 0", got)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM user_session_heartbeats WHERE user_id = ?`, studentID); got != 0 {
+		t.Errorf("heartbeat rows = %d, want the orphaned one removed", got)
+	}
+}
@@ -0,0 +1,408 @@
+package backend
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ==============================================================================
+// STATION CONFIGURATION AUTHORIZATION
+// ==============================================================================
+//
+// Lock mode, the station identity and the database connection can be changed from the
+// login page, so the backend checks who is asking: a logged-in admin, an admin username
+// and password, or the maintenance PIN of the station's lab. Lab PINs live in
+// lab_maintenance_pins; the station keeps a copy of its lab's hash in its offline journal
+// so the PIN still works when the database is unreachable. Wrong PINs are counted in the
+// journal's login_attempts too, so the station locks itself out under the login throttle
+// policy even while offline. Only a station that has never had database settings can be
+// set up without a credential. Every change is written to the audit trail with the
+// station label and time.
+
+const (
+	minMaintenancePINLength = 8
+	maxMaintenancePINLength = 16
+)
+
+// StationAuthorization carries the credential entered with a station settings change.
+// Either the admin username and password or the maintenance PIN is needed, unless an
+// admin is already logged in on this station. Admins with two-factor authentication also
+// give an authenticator or backup code in AdminCode.
+type StationAuthorization struct {
+	AdminUsername  string `json:"admin_username"`
+	AdminPassword  string `json:"admin_password"`
+	AdminCode      string `json:"admin_code"`
+	MaintenancePIN string `json:"maintenance_pin"`
+}
+
+// stationChangeActor is who authorized a station settings change and how.
+type stationChangeActor struct {
+	session *appSession
+	method  string
+}
+
+// authorizeStationChange checks the credential for a station settings change.
+func (a *App) authorizeStationChange(auth StationAuthorization) (*stationChangeActor, error) {
+	if session := a.currentSession(); session != nil && session.Role == "admin" {
+		return &stationChangeActor{session: session, method: "admin_session"}, nil
+	}
+
+	stationLabel := a.currentStationLabel()
+
+	if username := strings.TrimSpace(auth.AdminUsername); username != "" {
+		session, err := a.verifyStationAdmin(username, auth.AdminPassword, auth.AdminCode, stationLabel)
+		if err != nil {
+			return nil, err
+		}
+		return &stationChangeActor{session: session, method: "admin_password"}, nil
+	}
+
+	if pin := strings.TrimSpace(auth.MaintenancePIN); pin != "" {
+		if err := a.verifyMaintenancePIN(pin, stationLabel); err != nil {
+			return nil, err
+		}
+		return &stationChangeActor{method: "maintenance_pin"}, nil
+	}
+
+	// A freshly installed station has no database and no PIN to check against yet.
+	if a.maintenancePINHash == "" && a.db == nil && a.stationNeverConfigured() {
+		return &stationChangeActor{method: "initial_setup"}, nil
+	}
+
+	log.Printf("STATION SETTINGS DENIED: no credential given on '%s'", stationLabel)
+	return nil, fmt.Errorf("admin username and password or the lab maintenance PIN is required to change station settings")
+}
+
+// verifyStationAdmin checks an admin's password and, when the admin has two-factor
+// authentication or it is required for admins, their second factor, as Login would.
+func (a *App) verifyStationAdmin(username, password, code, stationLabel string) (*appSession, error) {
+	if err := a.checkDB(); err != nil {
+		return nil, fmt.Errorf("database is not connected - use the lab maintenance PIN instead")
+	}
+	if err := a.checkLoginThrottle(username, stationLabel); err != nil {
+		return nil, err
+	}
+
+	var userID int
+	var storedUsername, storedPassword, role, status string
+	err := a.db.QueryRow(`
+		SELECT id, username, password, user_type, account_status FROM users WHERE username = ?
+	`, username).Scan(&userID, &storedUsername, &storedPassword, &role, &status)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == sql.ErrNoRows || bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password)) != nil ||
+		role != "admin" || status != "active" {
+		log.Printf("STATION SETTINGS DENIED: invalid admin credentials for '%s' on '%s'", username, stationLabel)
+		a.recordLoginFailure(username, stationLabel)
+		return nil, fmt.Errorf("invalid admin credentials")
+	}
+
+	enabled, err := a.isTwoFactorEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		required, err := a.isTwoFactorRequired(role)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			return nil, fmt.Errorf("two-factor authentication is required for admins - log in once to set it up, or use the lab maintenance PIN")
+		}
+	} else {
+		if strings.TrimSpace(code) == "" {
+			return nil, fmt.Errorf("enter the verification code from the admin's authenticator app")
+		}
+		if err := a.verifySecondFactor(a.db, userID, code); err != nil {
+			log.Printf("STATION SETTINGS DENIED: wrong verification code for '%s' on '%s'", username, stationLabel)
+			a.recordLoginFailure(username, stationLabel)
+			return nil, fmt.Errorf("invalid verification code")
+		}
+	}
+
+	a.clearLoginFailures(username, stationLabel)
+	return &appSession{UserID: userID, Username: storedUsername, Role: role}, nil
+}
+
+// verifyMaintenancePIN checks pin against the current lab's PIN, refreshing the cached
+// hash from the database when it is reachable. Failures count against the station in the
+// station journal and, when online, in login_attempts.
+func (a *App) verifyMaintenancePIN(pin, stationLabel string) error {
+	journal, err := a.offlineJournalDB(true)
+	if err != nil {
+		log.Printf("Station journal unavailable for a maintenance PIN on '%s': %v", stationLabel, err)
+		return fmt.Errorf("the maintenance PIN cannot be checked on this PC - ask an admin to log in")
+	}
+	if err := a.checkLoginAttempts(journal, "", stationLabel); err != nil {
+		return err
+	}
+	online := a.checkDB() == nil
+	if online {
+		if err := a.checkLoginThrottle("", stationLabel); err != nil {
+			return err
+		}
+		a.refreshMaintenancePINCache()
+	}
+
+	if a.maintenancePINHash == "" {
+		return fmt.Errorf("no maintenance PIN is set for this lab - ask an admin to log in")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(a.maintenancePINHash), []byte(pin)) != nil {
+		log.Printf("STATION SETTINGS DENIED: wrong maintenance PIN on '%s'", stationLabel)
+		a.recordOfflineLoginFailure(journal, "", stationLabel)
+		if online {
+			a.recordLoginFailure("", stationLabel)
+		}
+		return fmt.Errorf("invalid maintenance PIN")
+	}
+	return nil
+}
+
+// lookupMaintenancePINHash returns the PIN hash stored for a lab. found is false when the
+// database cannot be read, in which case the cached hash should be left as it is.
+func (a *App) lookupMaintenancePINHash(computerLab string) (string, bool) {
+	computerLab = strings.TrimSpace(computerLab)
+	if computerLab == "" {
+		return "", true
+	}
+	if a.db == nil {
+		return "", false
+	}
+
+	var hash string
+	err := a.db.QueryRow(`
+		SELECT pin_hash FROM lab_maintenance_pins WHERE LOWER(computer_lab) = LOWER(?)
+	`, computerLab).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", true
+	}
+	if err != nil {
+		log.Printf("Failed to read maintenance PIN for lab '%s': %v", computerLab, err)
+		return "", false
+	}
+	return hash, true
+}
+
+// refreshMaintenancePINCache copies the current lab's PIN hash into the station journal.
+func (a *App) refreshMaintenancePINCache() {
+	hash, found := a.lookupMaintenancePINHash(a.computerLab)
+	if !found || hash == a.maintenancePINHash {
+		return
+	}
+	a.maintenancePINHash = hash
+	a.storeMaintenancePINHash()
+}
+
+// storeMaintenancePINHash writes the current PIN hash to the station journal.
+func (a *App) storeMaintenancePINHash() {
+	journal, err := a.offlineJournalDB(true)
+	if err == nil {
+		err = saveStationPINHash(journal, a.maintenancePINHash, a.now())
+	}
+	if err != nil {
+		log.Printf("Failed to cache maintenance PIN: %v", err)
+	}
+}
+
+// loadCachedMaintenancePINHash returns the PIN hash kept in the station journal. A hash an
+// older version left in the app settings is moved into the journal and removed from there.
+func (a *App) loadCachedMaintenancePINHash(appConfig AppConfig) string {
+	journal, err := a.offlineJournalDB(appConfig.legacyPINHash != "")
+	if err != nil {
+		log.Printf("Failed to open the station journal for the maintenance PIN: %v", err)
+		return appConfig.legacyPINHash
+	}
+	hash := ""
+	if journal != nil {
+		hash = loadStationPINHash(journal)
+	}
+	if appConfig.legacyPINHash == "" {
+		return hash
+	}
+	if hash == "" {
+		hash = appConfig.legacyPINHash
+		if err := saveStationPINHash(journal, hash, a.now()); err != nil {
+			log.Printf("Failed to move the maintenance PIN out of the app settings: %v", err)
+			return hash
+		}
+	}
+	if err := SaveAppSettings(appConfig); err != nil {
+		log.Printf("Failed to remove the maintenance PIN from the app settings: %v", err)
+	}
+	return hash
+}
+
+// loadStationPINHash reads the PIN hash kept in the station journal ("" when none).
+func loadStationPINHash(journal *sql.DB) string {
+	var hash string
+	err := journal.QueryRow(`SELECT pin_hash FROM station_pin WHERE id = 1`).Scan(&hash)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to read the cached maintenance PIN: %v", err)
+	}
+	return hash
+}
+
+// saveStationPINHash replaces the PIN hash kept in the station journal. An empty hash
+// removes it.
+func saveStationPINHash(journal *sql.DB, hash string, now time.Time) error {
+	if hash == "" {
+		if _, err := journal.Exec(`DELETE FROM station_pin`); err != nil {
+			return fmt.Errorf("failed to remove the cached maintenance PIN: %w", err)
+		}
+		return nil
+	}
+	_, err := journal.Exec(`
+		INSERT INTO station_pin (id, pin_hash, cached_at) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET pin_hash = excluded.pin_hash, cached_at = excluded.cached_at
+	`, hash, now)
+	if err != nil {
+		return fmt.Errorf("failed to cache the maintenance PIN: %w", err)
+	}
+	return nil
+}
+
+// stationNeverConfigured reports whether database settings have never been saved on this
+// station: config.ini has none, the app settings carry no marker and there is no station
+// journal.
+func (a *App) stationNeverConfigured() bool {
+	if a.databaseConfigured {
+		return false
+	}
+	if _, _, configured, err := LoadDatabaseSettingsDraft(); err != nil || configured {
+		return false
+	}
+	path, err := offlineQueuePath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// markDatabaseConfigured records that this station has had database settings, which ends
+// the credential-free initial setup for good, and creates the station journal.
+func (a *App) markDatabaseConfigured() {
+	if _, err := a.offlineJournalDB(true); err != nil {
+		log.Printf("Failed to create the station journal: %v", err)
+	}
+	if a.databaseConfigured {
+		return
+	}
+	a.databaseConfigured = true
+	if err := SaveAppSettings(a.currentAppConfig()); err != nil {
+		log.Printf("Failed to record that this station's database is configured: %v", err)
+	}
+}
+
+// SetLabMaintenancePIN sets the maintenance PIN of a computer lab. An empty PIN removes it,
+// after which only admin credentials can change that lab's station settings.
+func (a *App) SetLabMaintenancePIN(computerLab, pin string) error {
+	session, err := a.requireRole("SetLabMaintenancePIN")
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+
+	computerLab, err = sanitizeComputerLab(computerLab)
+	if err != nil {
+		return err
+	}
+	if computerLab == "" {
+		return fmt.Errorf("computer lab is required")
+	}
+
+	pin = strings.TrimSpace(pin)
+	if pin == "" {
+		if _, err := a.db.Exec(`DELETE FROM lab_maintenance_pins WHERE LOWER(computer_lab) = LOWER(?)`, computerLab); err != nil {
+			return fmt.Errorf("failed to remove maintenance PIN: %w", err)
+		}
+		a.audit(session, "remove_maintenance_pin", "computer_lab", computerLab, nil, nil)
+		a.refreshMaintenancePINCache()
+		return nil
+	}
+
+	if len(pin) < minMaintenancePINLength || len(pin) > maxMaintenancePINLength {
+		return fmt.Errorf("maintenance PIN must be %d to %d digits", minMaintenancePINLength, maxMaintenancePINLength)
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("maintenance PIN must contain digits only")
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash maintenance PIN: %w", err)
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM lab_maintenance_pins WHERE LOWER(computer_lab) = LOWER(?)`, computerLab); err != nil {
+		return fmt.Errorf("failed to replace maintenance PIN: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO lab_maintenance_pins (computer_lab, pin_hash, updated_by_user_id, updated_at)
+		VALUES (?, ?, ?, ?)
+	`, computerLab, string(hash), session.UserID, a.now()); err != nil {
+		return fmt.Errorf("failed to save maintenance PIN: %w", err)
+	}
+	if err := a.recordAuditEvent(tx, session, "set_maintenance_pin", "computer_lab", computerLab, nil, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit maintenance PIN: %w", err)
+	}
+
+	a.refreshMaintenancePINCache()
+	log.Printf("Maintenance PIN for lab '%s' set by user %d", computerLab, session.UserID)
+	return nil
+}
+
+// recordStationChange writes a station settings change to the audit trail. When the
+// database is unreachable the change is only logged locally.
+func (a *App) recordStationChange(actor *stationChangeActor, action string, before, after auditValues) {
+	stationLabel := a.currentStationLabel()
+	log.Printf("Station settings changed on '%s' at %s (%s, authorized by %s)",
+		stationLabel, formatTime(a.now()), action, actor.method)
+
+	if a.db == nil {
+		return
+	}
+	if after == nil {
+		after = auditValues{}
+	}
+	after["authorized_by"] = actor.method
+	a.audit(actor.session, action, "station", stationLabel, before, after)
+}
+
+func lockSettingsAuditValues(settings LockSettings) auditValues {
+	return auditValues{
+		"lock_mode":    settings.LockMode,
+		"computer_lab": settings.ComputerLab,
+		"pc_number":    settings.PCNumber,
+	}
+}
+
+func databaseSettingsAuditValues(config DBConfig) auditValues {
+	return auditValues{
+		"driver":   config.Driver,
+		"host":     config.Host,
+		"port":     config.Port,
+		"dbname":   config.DBName,
+		"username": config.Username,
+	}
+}
@@ -0,0 +1,220 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStationSettingsRequireAdminOrMaintenancePIN(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	e.app.lockMode = true
+
+	if _, err := e.app.SetLockSettingsFromInput(StationAuthorization{}, "lockmode: false", "Lab 1", "07"); err == nil {
+		t.Fatalf("lock mode was turned off without a credential")
+	}
+	student := StationAuthorization{AdminUsername: "2024-00001", AdminPassword: testPassword}
+	if _, err := e.app.SetLockSettingsFromInput(student, "lockmode: false", "Lab 1", "07"); err == nil {
+		t.Fatalf("lock mode was turned off with a student's credentials")
+	}
+	if !e.app.lockMode || e.app.computerLab != "" {
+		t.Fatalf("a rejected change was applied: lock=%v lab=%q", e.app.lockMode, e.app.computerLab)
+	}
+
+	admin := StationAuthorization{AdminUsername: "A-0001", AdminPassword: testPassword}
+	settings, err := e.app.SetLockSettingsFromInput(admin, "lockmode: true", "Lab 1", "07")
+	if err != nil {
+		t.Fatalf("SetLockSettingsFromInput as admin: %v", err)
+	}
+	if settings.StationLabel != "Lab 1 - PC 07" || settings.MaintenancePINSet {
+		t.Fatalf("settings = %+v", settings)
+	}
+	after := e.queryString(`SELECT after_value FROM audit_events WHERE action = 'update_lock_settings' AND station = 'Lab 1 - PC 07'`)
+	if !strings.Contains(after, `"authorized_by":"admin_password"`) || !strings.Contains(after, `"computer_lab":"Lab 1"`) {
+		t.Errorf("lock settings audit = %s", after)
+	}
+
+	e.loginAs("A-0001")
+	if err := e.app.SetLabMaintenancePIN("Lab 1", "12ab5678"); err == nil {
+		t.Errorf("a non-numeric PIN was accepted")
+	}
+	if err := e.app.SetLabMaintenancePIN("Lab 1", "4821"); err == nil {
+		t.Errorf("a 4 digit PIN was accepted")
+	}
+	if err := e.app.SetLabMaintenancePIN("lab 1", "48213957"); err != nil {
+		t.Fatalf("SetLabMaintenancePIN: %v", err)
+	}
+	if err := e.app.Logout(adminID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if !e.app.GetLockSettings().MaintenancePINSet {
+		t.Fatalf("the lab's PIN was not cached on the station")
+	}
+
+	if err := e.app.SetLockMode(StationAuthorization{MaintenancePIN: "00000000"}, false); err == nil || !e.app.lockMode {
+		t.Fatalf("a wrong PIN turned lock mode off: err=%v", err)
+	}
+	if err := e.app.SetLockMode(StationAuthorization{MaintenancePIN: "48213957"}, false); err != nil || e.app.lockMode {
+		t.Fatalf("SetLockMode with the PIN: err=%v lock=%v", err, e.app.lockMode)
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM audit_events WHERE action = 'update_lock_settings' AND after_value LIKE '%maintenance_pin%'`); n != 1 {
+		t.Errorf("PIN-authorized audit events = %d, want 1", n)
+	}
+	if n := e.queryInt(`SELECT failure_count FROM login_attempts WHERE scope = 'station' AND subject = 'Lab 1 - PC 07'`); n != 1 {
+		t.Errorf("station failures after a wrong PIN = %d, want 1", n)
+	}
+
+	// The cached PIN still works while the database is unreachable.
+	e.app.db = nil
+	err = e.app.SetLockMode(StationAuthorization{MaintenancePIN: "48213957"}, true)
+	e.app.db = e.db
+	if err != nil || !e.app.lockMode {
+		t.Fatalf("SetLockMode with the cached PIN: err=%v lock=%v", err, e.app.lockMode)
+	}
+	if reloaded := LoadAppSettings(); !reloaded.LockMode {
+		t.Errorf("saved app settings = %+v, want lock mode on", reloaded)
+	}
+
+	// The hash and the wrong PIN are kept in the station journal, not in the app settings.
+	settingsPath, _ := getUserAppConfigPath()
+	if data, err := os.ReadFile(settingsPath); err != nil || strings.Contains(string(data), "pin_hash") {
+		t.Errorf("app settings = %s, %v; want no PIN hash", data, err)
+	}
+	journal, err := e.app.offlineJournalDB(false)
+	if err != nil || journal == nil {
+		t.Fatalf("station journal: %v", err)
+	}
+	if hash := loadStationPINHash(journal); hash == "" || hash != e.app.maintenancePINHash {
+		t.Errorf("journal PIN hash = %q, want the lab's hash", hash)
+	}
+	var failures int
+	if err := journal.QueryRow(`SELECT failure_count FROM login_attempts WHERE scope = 'station' AND subject = 'Lab 1 - PC 07'`).Scan(&failures); err != nil || failures != 1 {
+		t.Errorf("journal station failures = %d, %v; want 1", failures, err)
+	}
+}
+
+func TestWrongPINsLockTheStationWhileOffline(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	t.Setenv("LOGIN_LOCKOUT_AFTER_FAILURES", "3")
+	e.app.computerLab, e.app.pcNumber = "Lab 1", "07"
+	e.loginAs("A-0001")
+	if err := e.app.SetLabMaintenancePIN("Lab 1", "48213957"); err != nil {
+		t.Fatalf("SetLabMaintenancePIN: %v", err)
+	}
+	e.app.endSession()
+
+	e.app.db = nil
+	defer func() { e.app.db = e.db }()
+	for i := 0; i < 3; i++ {
+		e.clock.Advance(10 * time.Second)
+		if err := e.app.SetLockMode(StationAuthorization{MaintenancePIN: "00000000"}, true); err == nil {
+			t.Fatalf("a wrong PIN was accepted")
+		}
+	}
+	if err := e.app.SetLockMode(StationAuthorization{MaintenancePIN: "48213957"}, true); err == nil {
+		t.Fatalf("the right PIN was accepted during the offline lockout")
+	}
+	e.clock.Advance(time.Duration(defaultLoginLockoutMinutes+1) * time.Minute)
+	if err := e.app.SetLockMode(StationAuthorization{MaintenancePIN: "48213957"}, true); err != nil {
+		t.Fatalf("SetLockMode after the lockout: %v", err)
+	}
+}
+
+func TestMaintenancePINHashMovesOutOfAppSettings(t *testing.T) {
+	e := newTestEnv(t)
+	settingsPath, _ := getUserAppConfigPath()
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	legacy := `{"lock_mode": true, "computer_lab": "Lab 1", "pc_number": "07", "maintenance_pin_hash": "$2a$10$legacy"}`
+	if err := os.WriteFile(settingsPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if hash := e.app.loadCachedMaintenancePINHash(LoadAppSettings()); hash != "$2a$10$legacy" {
+		t.Errorf("cached hash = %q, want the one from the app settings", hash)
+	}
+	if journal, err := e.app.offlineJournalDB(false); err != nil || journal == nil || loadStationPINHash(journal) != "$2a$10$legacy" {
+		t.Errorf("the hash was not moved into the station journal: %v", err)
+	}
+	if data, _ := os.ReadFile(settingsPath); strings.Contains(string(data), "pin_hash") {
+		t.Errorf("app settings still hold the PIN hash: %s", data)
+	}
+	if reloaded := LoadAppSettings(); !reloaded.LockMode || reloaded.PCNumber != "07" {
+		t.Errorf("app settings = %+v, want the station settings kept", reloaded)
+	}
+}
+
+func TestInitialSetupEndsOnceTheDatabaseIsConfigured(t *testing.T) {
+	e := newTestEnv(t)
+	e.app.db = nil
+	defer func() { e.app.db = e.db }()
+
+	if err := e.app.SetLockMode(StationAuthorization{}, true); err != nil {
+		t.Fatalf("initial setup on a fresh station: %v", err)
+	}
+
+	// What a successful connection or a saved database setting records.
+	e.app.markDatabaseConfigured()
+	if !LoadAppSettings().DatabaseConfigured {
+		t.Errorf("the app settings do not record that the database was configured")
+	}
+	if err := e.app.SetLockMode(StationAuthorization{}, false); err == nil {
+		t.Fatalf("station settings changed without a credential after the database was configured")
+	}
+
+	// Losing the app settings marker does not reopen setup while the journal exists.
+	e.app.databaseConfigured = false
+	if err := e.app.SetLockMode(StationAuthorization{}, false); err == nil {
+		t.Fatalf("station settings changed without a credential while the station journal exists")
+	}
+
+	// Nor does deleting the journal while config.ini holds database settings.
+	journalPath, _ := offlineQueuePath()
+	e.app.offlineJournal.Close()
+	e.app.offlineJournal = nil
+	if err := os.Remove(journalPath); err != nil {
+		t.Fatalf("remove journal: %v", err)
+	}
+	if _, err := SaveDatabaseSettings(DBConfig{Driver: "mysql", Host: "db.school.edu", Port: "3306", DBName: "logbookdb", Username: "logbook", Password: "db-secret"}); err != nil {
+		t.Fatalf("SaveDatabaseSettings: %v", err)
+	}
+	if err := e.app.SetLockMode(StationAuthorization{}, false); err == nil || !e.app.lockMode {
+		t.Fatalf("station settings changed without a credential while config.ini holds database settings: %v", err)
+	}
+}
+
+func TestStationAdminWithTwoFactorNeedsACode(t *testing.T) {
+	e := newTestEnv(t)
+	adminID := e.seedUser("admin", "A-0001", "Ada", "Admin")
+	e.loginAs("A-0001")
+	enrollment, err := e.app.BeginTwoFactorEnrollment(adminID)
+	if err != nil {
+		t.Fatalf("BeginTwoFactorEnrollment: %v", err)
+	}
+	if err := e.app.ConfirmTwoFactorEnrollment(adminID, e.totpNow(enrollment.Secret)); err != nil {
+		t.Fatalf("ConfirmTwoFactorEnrollment: %v", err)
+	}
+	if err := e.app.Logout(adminID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	admin := StationAuthorization{AdminUsername: "A-0001", AdminPassword: testPassword}
+	if err := e.app.SetLockMode(admin, true); err == nil || e.app.lockMode {
+		t.Fatalf("lock mode was changed with the password alone: err=%v", err)
+	}
+	admin.AdminCode = "000000"
+	if err := e.app.SetLockMode(admin, true); err == nil || e.app.lockMode {
+		t.Fatalf("lock mode was changed with a wrong code: err=%v", err)
+	}
+	e.clock.Advance(time.Duration(totpPeriod) * time.Second)
+	admin.AdminCode = e.totpNow(enrollment.Secret)
+	if err := e.app.SetLockMode(admin, true); err != nil || !e.app.lockMode {
+		t.Fatalf("SetLockMode with the admin's code: err=%v lock=%v", err, e.app.lockMode)
+	}
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteDriverName = "logbook-sqlite"
+
+func init() {
+	registerSQLiteFunctions()
+
+	// Borrow the registered modernc driver instance; it carries the functions above.
+	probe, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(fmt.Sprintf("storage: sqlite driver unavailable: %v", err))
+	}
+	base := probe.Driver()
+	probe.Close()
+
+	sql.Register(sqliteDriverName, &sqliteDriver{base: base})
+}
+
+// sqliteDriver wraps the modernc SQLite driver, translating MySQL-dialect statements
+// and normalizing time values at the boundary.
+type sqliteDriver struct {
+	base driver.Driver
+}
+
+func (d *sqliteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{base: conn}, nil
+}
+
+type sqliteConn struct {
+	base driver.Conn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	translated := Translate(query)
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.base.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, translated)
+	} else {
+		stmt, err = c.base.Prepare(translated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStmt{base: stmt}, nil
+}
+
+func (c *sqliteConn) Close() error {
+	return c.base.Close()
+}
+
+func (c *sqliteConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.base.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.base.Begin()
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.base.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, Translate(query), normalizeArgs(args))
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.base.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, Translate(query), normalizeArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteRows{base: rows}, nil
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.base.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqliteConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.base.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+type sqliteStmt struct {
+	base driver.Stmt
+}
+
+func (s *sqliteStmt) Close() error  { return s.base.Close() }
+func (s *sqliteStmt) NumInput() int { return s.base.NumInput() }
+
+func (s *sqliteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *sqliteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.base.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, normalizeArgs(args))
+	}
+	return s.base.Exec(namedToValues(normalizeArgs(args)))
+}
+
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.base.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, normalizeArgs(args))
+	} else {
+		rows, err = s.base.Query(namedToValues(normalizeArgs(args)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteRows{base: rows}, nil
+}
+
+// sqliteRows re-anchors zone-less DATETIME text (which the base driver parses as UTC)
+// to local time, matching the MySQL driver's loc=Local behaviour.
+type sqliteRows struct {
+	base driver.Rows
+}
+
+func (r *sqliteRows) Columns() []string { return r.base.Columns() }
+func (r *sqliteRows) Close() error      { return r.base.Close() }
+
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	if err := r.base.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if t, ok := v.(time.Time); ok && t.Location() == time.UTC {
+			dest[i] = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+		}
+	}
+	return nil
+}
+
+// normalizeArgs stores time values as local wall-clock text, the same shape NOW() produces,
+// so comparisons and DATE() work on plain strings.
+func normalizeArgs(args []driver.NamedValue) []driver.NamedValue {
+	out := args
+	copied := false
+	for i, arg := range args {
+		t, ok := arg.Value.(time.Time)
+		if !ok {
+			continue
+		}
+		if !copied {
+			out = append([]driver.NamedValue(nil), args...)
+			copied = true
+		}
+		out[i].Value = t.In(time.Local).Format(sqliteTimeLayout)
+	}
+	return out
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
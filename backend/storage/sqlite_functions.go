@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+const (
+	sqliteTimeLayout = "2006-01-02 15:04:05"
+	sqliteDateLayout = "2006-01-02"
+)
+
+// Now is the clock used by the SQLite NOW()/CURDATE() functions.
+var Now = time.Now
+
+func registerSQLiteFunctions() {
+	sqlite.MustRegisterScalarFunction("NOW", 0, func(_ *sqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return Now().Format(sqliteTimeLayout), nil
+	})
+	sqlite.MustRegisterScalarFunction("CURDATE", 0, func(_ *sqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return Now().Format(sqliteDateLayout), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("DATE_FORMAT", 2, sqliteDateFormat)
+	sqlite.MustRegisterDeterministicScalarFunction("DATE_ADD", 3, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return sqliteDateShift(args, 1)
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("DATE_SUB", 3, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return sqliteDateShift(args, -1)
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("TIMESTAMPDIFF", 3, sqliteTimestampDiff)
+	sqlite.MustRegisterDeterministicScalarFunction("DATEDIFF", 2, sqliteDateDiff)
+}
+
+// parseSQLiteTime accepts the text layouts written by this package, the base driver and MySQL dumps.
+func parseSQLiteTime(v driver.Value) (time.Time, bool, bool) {
+	var s string
+	switch value := v.(type) {
+	case nil:
+		return time.Time{}, false, false
+	case time.Time:
+		return value.In(time.Local), false, true
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	default:
+		return time.Time{}, false, false
+	}
+
+	s = strings.TrimSpace(s)
+	if len(s) == len(sqliteDateLayout) {
+		t, err := time.ParseInLocation(sqliteDateLayout, s, time.Local)
+		return t, true, err == nil
+	}
+
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04",
+	} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, false, true
+		}
+	}
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999-07:00",
+		"2006-01-02T15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999 -0700 MST",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.In(time.Local), false, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+func sqliteInt(v driver.Value) (int64, bool) {
+	switch value := v.(type) {
+	case int64:
+		return value, true
+	case float64:
+		return int64(value), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return int64(n), err == nil
+	case []byte:
+		n, err := strconv.ParseFloat(strings.TrimSpace(string(value)), 64)
+		return int64(n), err == nil
+	}
+	return 0, false
+}
+
+func sqliteUnit(v driver.Value) string {
+	switch value := v.(type) {
+	case string:
+		return strings.ToUpper(strings.TrimSpace(value))
+	case []byte:
+		return strings.ToUpper(strings.TrimSpace(string(value)))
+	}
+	return ""
+}
+
+func sqliteDateShift(args []driver.Value, sign int64) (driver.Value, error) {
+	t, dateOnly, ok := parseSQLiteTime(args[0])
+	if !ok {
+		return nil, nil
+	}
+	n, ok := sqliteInt(args[1])
+	if !ok {
+		return nil, nil
+	}
+	n *= sign
+
+	switch sqliteUnit(args[2]) {
+	case "SECOND":
+		t = t.Add(time.Duration(n) * time.Second)
+		dateOnly = false
+	case "MINUTE":
+		t = t.Add(time.Duration(n) * time.Minute)
+		dateOnly = false
+	case "HOUR":
+		t = t.Add(time.Duration(n) * time.Hour)
+		dateOnly = false
+	case "DAY":
+		t = t.AddDate(0, 0, int(n))
+	case "WEEK":
+		t = t.AddDate(0, 0, int(n)*7)
+	case "MONTH":
+		t = t.AddDate(0, int(n), 0)
+	case "YEAR":
+		t = t.AddDate(int(n), 0, 0)
+	default:
+		return nil, fmt.Errorf("unsupported interval unit %v", args[2])
+	}
+
+	if dateOnly {
+		return t.Format(sqliteDateLayout), nil
+	}
+	return t.Format(sqliteTimeLayout), nil
+}
+
+func sqliteTimestampDiff(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	from, _, okFrom := parseSQLiteTime(args[1])
+	to, _, okTo := parseSQLiteTime(args[2])
+	if !okFrom || !okTo {
+		return nil, nil
+	}
+
+	d := to.Sub(from)
+	switch sqliteUnit(args[0]) {
+	case "SECOND":
+		return int64(d / time.Second), nil
+	case "MINUTE":
+		return int64(d / time.Minute), nil
+	case "HOUR":
+		return int64(d / time.Hour), nil
+	case "DAY":
+		return int64(d / (24 * time.Hour)), nil
+	case "WEEK":
+		return int64(d / (7 * 24 * time.Hour)), nil
+	case "MONTH", "YEAR":
+		months := int64(to.Year()-from.Year())*12 + int64(to.Month()-from.Month())
+		if months > 0 && to.AddDate(0, -int(months), 0).Before(from) {
+			months--
+		} else if months < 0 && to.AddDate(0, -int(months), 0).After(from) {
+			months++
+		}
+		if sqliteUnit(args[0]) == "YEAR" {
+			return months / 12, nil
+		}
+		return months, nil
+	default:
+		return nil, fmt.Errorf("unsupported TIMESTAMPDIFF unit %v", args[0])
+	}
+}
+
+func sqliteDateDiff(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	a, _, okA := parseSQLiteTime(args[0])
+	b, _, okB := parseSQLiteTime(args[1])
+	if !okA || !okB {
+		return nil, nil
+	}
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int64(dayA.Sub(dayB) / (24 * time.Hour)), nil
+}
+
+// sqliteDateFormat implements the MySQL DATE_FORMAT specifiers used by the app.
+func sqliteDateFormat(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	t, _, ok := parseSQLiteTime(args[0])
+	if !ok {
+		return nil, nil
+	}
+	format, ok := args[1].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			b.WriteString(t.Format("2006"))
+		case 'y':
+			b.WriteString(t.Format("06"))
+		case 'm':
+			b.WriteString(t.Format("01"))
+		case 'c':
+			b.WriteString(strconv.Itoa(int(t.Month())))
+		case 'M':
+			b.WriteString(t.Format("January"))
+		case 'b':
+			b.WriteString(t.Format("Jan"))
+		case 'd':
+			b.WriteString(t.Format("02"))
+		case 'e':
+			b.WriteString(strconv.Itoa(t.Day()))
+		case 'H':
+			b.WriteString(t.Format("15"))
+		case 'k':
+			b.WriteString(strconv.Itoa(t.Hour()))
+		case 'h', 'I':
+			b.WriteString(t.Format("03"))
+		case 'l':
+			b.WriteString(t.Format("3"))
+		case 'i':
+			b.WriteString(t.Format("04"))
+		case 's', 'S':
+			b.WriteString(t.Format("05"))
+		case 'f':
+			b.WriteString(fmt.Sprintf("%06d", t.Nanosecond()/1000))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'W':
+			b.WriteString(t.Format("Monday"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'j':
+			b.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'r':
+			b.WriteString(t.Format("03:04:05 PM"))
+		default:
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,131 @@
+// Package storage opens the logbook database for the configured backend.
+//
+// The application's queries are written in MySQL's dialect. MySQL connections
+// use them as-is. SQLite connections go through a thin database/sql driver that
+// translates each statement and registers MySQL-compatible functions, so the same
+// code can run on an embedded database file for small labs, demos and tests.
+//
+// The translator supports this subset of MySQL, and nothing else:
+//   - COLLATE utf8mb4_*, CHARACTER SET and ON UPDATE CURRENT_TIMESTAMP are dropped;
+//     DEFAULT CURRENT_TIMESTAMP/NOW() and CURRENT_TIMESTAMP become local-time defaults
+//     and NOW().
+//   - INSERT ... ON DUPLICATE KEY UPDATE col = VALUES(col) becomes ON CONFLICT DO UPDATE
+//     with excluded.col.
+//   - UPDATE t a [INNER] JOIN s b ON cond SET a.x = ... WHERE ... becomes UPDATE ... FROM.
+//   - DATE_ADD/DATE_SUB(x, INTERVAL n unit), CAST(x AS DATE), CONVERT(x USING cs) and
+//     TIMESTAMPDIFF(unit, a, b) are rewritten for the functions below.
+//   - NOW(), CURDATE(), DATE_FORMAT, DATE_ADD, DATE_SUB, TIMESTAMPDIFF and DATEDIFF are
+//     registered as SQLite functions.
+//
+// Anything outside the subset reaches SQLite unchanged and fails there, e.g. UPDATE ...
+// LEFT JOIN, multi-table DELETE (DELETE a FROM t a ...) and ALTER TABLE ... MODIFY
+// COLUMN. Write such statements in SQL both databases accept (correlated subqueries,
+// NOT EXISTS) rather than branching on the driver; schema changes that differ go in the
+// per-driver migration files. translate_test.go runs every supported form on SQLite.
+package storage
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Supported values for the driver= key in config.ini.
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
+
+// DefaultSQLiteFile is the database file name used when driver=sqlite has no path=.
+const DefaultSQLiteFile = "logbook.db"
+
+// NormalizeDriver maps a config.ini driver value to a supported driver name.
+// An empty value means MySQL so existing config files keep working.
+func NormalizeDriver(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "mysql", "mariadb":
+		return DriverMySQL, nil
+	case "sqlite", "sqlite3":
+		return DriverSQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q (use mysql or sqlite)", name)
+	}
+}
+
+// MySQLTLSConfigName is the name the app's TLS settings are registered under with the
+// MySQL driver.
+const MySQLTLSConfigName = "logbook"
+
+// MySQLDSN builds the go-sql-driver DSN used by the app. tlsConfig is empty for a
+// plaintext connection or the name of a config registered with RegisterMySQLTLS.
+func MySQLDSN(username, password, host, port, dbname, tlsConfig string) string {
+	config := mysql.NewConfig()
+	config.User = username
+	config.Passwd = password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(host, port)
+	config.DBName = dbname
+	config.ParseTime = true
+	config.Loc = time.Local
+	config.MultiStatements = true
+	config.Params = map[string]string{"charset": "utf8mb4"}
+	config.TLSConfig = tlsConfig
+	return config.FormatDSN()
+}
+
+// RegisterMySQLTLS registers (or replaces) the TLS settings used by DSNs that name
+// MySQLTLSConfigName.
+func RegisterMySQLTLS(config *tls.Config) error {
+	return mysql.RegisterTLSConfig(MySQLTLSConfigName, config)
+}
+
+// SQLiteDSN builds the DSN for a database file, enabling foreign keys, WAL and a busy timeout
+// so background loops and the UI can share the file. Transactions take the write lock when
+// they begin: a deferred transaction that reads first cannot wait for the lock later, and
+// fails at once with SQLITE_BUSY if another connection wrote in between.
+func SQLiteDSN(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_txlock", "immediate")
+	return path + "?" + params.Encode()
+}
+
+// OpenMySQL opens a MySQL connection pool.
+func OpenMySQL(dsn string) (*sql.DB, error) {
+	return sql.Open(DriverMySQL, dsn)
+}
+
+// OpenSQLite opens (creating if needed) a SQLite database file through the translating driver.
+func OpenSQLite(path string) (*sql.DB, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("sqlite database path is empty")
+	}
+	if path != ":memory:" && !strings.HasPrefix(path, "file:") {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create sqlite database directory: %w", err)
+		}
+	}
+	return sql.Open(sqliteDriverName, SQLiteDSN(path))
+}
+
+// DriverOf reports which backend a pool was opened with.
+func DriverOf(db *sql.DB) string {
+	if db == nil {
+		return DriverMySQL
+	}
+	if _, ok := db.Driver().(*sqliteDriver); ok {
+		return DriverSQLite
+	}
+	return DriverMySQL
+}
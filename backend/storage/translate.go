@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var translationCache sync.Map
+
+var (
+	collateClause        = regexp.MustCompile(`(?i)\s+COLLATE\s+utf8mb4_\w+`)
+	characterSetClause   = regexp.MustCompile(`(?i)\s+CHARACTER\s+SET\s+\w+`)
+	onUpdateTimestamp    = regexp.MustCompile(`(?i)\s+ON\s+UPDATE\s+CURRENT_TIMESTAMP(\(\))?`)
+	defaultTimestamp     = regexp.MustCompile(`(?i)\bDEFAULT\s+(CURRENT_TIMESTAMP(\(\))?|NOW\(\))`)
+	currentTimestamp     = regexp.MustCompile(`(?i)\bCURRENT_TIMESTAMP\b(\(\))?`)
+	onDuplicateKeyUpdate = regexp.MustCompile(`(?i)\bON\s+DUPLICATE\s+KEY\s+UPDATE\b`)
+	valuesCall           = regexp.MustCompile(`(?i)\bVALUES\s*\(\s*([A-Za-z_][A-Za-z0-9_]*)\s*\)`)
+	intervalArg          = regexp.MustCompile(`(?is)^\s*INTERVAL\s+(.+)\s+(SECOND|MINUTE|HOUR|DAY|WEEK|MONTH|YEAR)\s*$`)
+	castAsDate           = regexp.MustCompile(`(?is)^(.+)\s+AS\s+DATE\s*$`)
+	convertUsing         = regexp.MustCompile(`(?is)^(.+)\s+USING\s+\w+\s*$`)
+	updateJoinHead       = regexp.MustCompile(`(?is)^\s*UPDATE\s+([A-Za-z_][A-Za-z0-9_]*)\s+(?:AS\s+)?([A-Za-z_][A-Za-z0-9_]*)\s+(?:INNER\s+)?JOIN\s+`)
+	qualifiedColumn      = regexp.MustCompile(`^\s*[A-Za-z_][A-Za-z0-9_]*\.`)
+)
+
+// Translate rewrites a statement written for MySQL into equivalent SQLite SQL.
+// Results are cached because the app issues the same few hundred statements repeatedly.
+func Translate(query string) string {
+	if cached, ok := translationCache.Load(query); ok {
+		return cached.(string)
+	}
+	translated := translate(query)
+	translationCache.Store(query, translated)
+	return translated
+}
+
+func translate(query string) string {
+	q := mapCode(query, func(code string) string {
+		code = collateClause.ReplaceAllString(code, "")
+		code = characterSetClause.ReplaceAllString(code, "")
+		code = onUpdateTimestamp.ReplaceAllString(code, "")
+		code = defaultTimestamp.ReplaceAllString(code, "DEFAULT (datetime('now','localtime'))")
+		code = currentTimestamp.ReplaceAllString(code, "NOW()")
+		return code
+	})
+
+	q = rewriteCalls(q, "CONVERT", func(args []string) (string, bool) {
+		if len(args) != 1 {
+			return "", false
+		}
+		m := convertUsing.FindStringSubmatch(args[0])
+		if m == nil {
+			return "", false
+		}
+		return "(" + m[1] + ")", true
+	})
+	q = rewriteCalls(q, "CAST", func(args []string) (string, bool) {
+		if len(args) != 1 {
+			return "", false
+		}
+		m := castAsDate.FindStringSubmatch(args[0])
+		if m == nil {
+			return "", false
+		}
+		return "DATE(" + m[1] + ")", true
+	})
+	for _, name := range []string{"DATE_ADD", "DATE_SUB"} {
+		fn := name
+		q = rewriteCalls(q, fn, func(args []string) (string, bool) {
+			if len(args) != 2 {
+				return "", false
+			}
+			m := intervalArg.FindStringSubmatch(args[1])
+			if m == nil {
+				return "", false
+			}
+			return fn + "(" + args[0] + ", " + m[1] + ", '" + strings.ToUpper(m[2]) + "')", true
+		})
+	}
+	q = rewriteCalls(q, "TIMESTAMPDIFF", func(args []string) (string, bool) {
+		if len(args) != 3 {
+			return "", false
+		}
+		unit := strings.TrimSpace(args[0])
+		if strings.HasPrefix(unit, "'") {
+			return "", false
+		}
+		return "TIMESTAMPDIFF('" + strings.ToUpper(unit) + "', " + args[1] + ", " + args[2] + ")", true
+	})
+
+	if loc := findCode(q, onDuplicateKeyUpdate); loc != nil {
+		head := q[:loc[0]]
+		tail := mapCode(q[loc[1]:], func(code string) string {
+			return valuesCall.ReplaceAllString(code, "excluded.$1")
+		})
+		q = head + "ON CONFLICT DO UPDATE SET" + tail
+	}
+
+	if updateJoinHead.MatchString(q) {
+		if rewritten, ok := rewriteUpdateJoin(q); ok {
+			q = rewritten
+		}
+	}
+
+	return q
+}
+
+// rewriteUpdateJoin turns MySQL's multi-table
+//
+//	UPDATE t a [INNER] JOIN src s ON cond SET a.x = ... WHERE filter
+//
+// into SQLite's UPDATE ... FROM form. LEFT JOINs have no direct equivalent and are left alone.
+func rewriteUpdateJoin(q string) (string, bool) {
+	head := updateJoinHead.FindStringSubmatchIndex(q)
+	table := q[head[2]:head[3]]
+	alias := q[head[4]:head[5]]
+	rest := q[head[1]:]
+
+	onAt := indexTopLevelKeyword(rest, "ON")
+	if onAt < 0 {
+		return "", false
+	}
+	source := strings.TrimSpace(rest[:onAt])
+	rest = rest[onAt+len("ON"):]
+
+	setAt := indexTopLevelKeyword(rest, "SET")
+	if setAt < 0 {
+		return "", false
+	}
+	joinCond := strings.TrimSpace(rest[:setAt])
+	rest = rest[setAt+len("SET"):]
+
+	where := ""
+	assignments := rest
+	if whereAt := indexTopLevelKeyword(rest, "WHERE"); whereAt >= 0 {
+		assignments = rest[:whereAt]
+		where = strings.TrimSpace(rest[whereAt+len("WHERE"):])
+	}
+
+	parts := splitTopLevel(assignments, ',')
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			return "", false
+		}
+		lhs := qualifiedColumn.ReplaceAllString(part[:eq], "")
+		parts[i] = strings.TrimSpace(lhs) + " = " + strings.TrimSpace(part[eq+1:])
+	}
+
+	var b strings.Builder
+	b.WriteString("UPDATE ")
+	b.WriteString(table)
+	b.WriteString(" AS ")
+	b.WriteString(alias)
+	b.WriteString("\nSET ")
+	b.WriteString(strings.Join(parts, ",\n\t"))
+	b.WriteString("\nFROM ")
+	b.WriteString(source)
+	b.WriteString("\nWHERE (")
+	b.WriteString(joinCond)
+	b.WriteString(")")
+	if where != "" {
+		b.WriteString(" AND (")
+		b.WriteString(where)
+		b.WriteString(")")
+	}
+	return b.String(), true
+}
+
+// mapCode applies fn to the parts of q that are outside string literals and quoted identifiers.
+func mapCode(q string, fn func(string) string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(q); i++ {
+		if q[i] != '\'' && q[i] != '"' && q[i] != '`' {
+			continue
+		}
+		end := skipQuoted(q, i)
+		b.WriteString(fn(q[start:i]))
+		b.WriteString(q[i:end])
+		start = end
+		i = end - 1
+	}
+	b.WriteString(fn(q[start:]))
+	return b.String()
+}
+
+// findCode returns the first match of re outside quoted text.
+func findCode(q string, re *regexp.Regexp) []int {
+	offset := 0
+	for offset < len(q) {
+		next := len(q)
+		for _, quote := range []byte{'\'', '"', '`'} {
+			if at := strings.IndexByte(q[offset:], quote); at >= 0 && offset+at < next {
+				next = offset + at
+			}
+		}
+		if loc := re.FindStringIndex(q[offset:next]); loc != nil {
+			return []int{offset + loc[0], offset + loc[1]}
+		}
+		if next == len(q) {
+			return nil
+		}
+		offset = skipQuoted(q, next)
+	}
+	return nil
+}
+
+// skipQuoted returns the index just past the quoted token starting at q[i].
+func skipQuoted(q string, i int) int {
+	quote := q[i]
+	for j := i + 1; j < len(q); j++ {
+		if q[j] == '\\' && quote == '\'' {
+			j++
+			continue
+		}
+		if q[j] == quote {
+			if j+1 < len(q) && q[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(q)
+}
+
+// rewriteCalls replaces every call to name(...) for which fn returns ok, innermost first.
+func rewriteCalls(q, name string, fn func(args []string) (string, bool)) string {
+	var b strings.Builder
+	i := 0
+	for i < len(q) {
+		c := q[i]
+		if c == '\'' || c == '"' || c == '`' {
+			end := skipQuoted(q, i)
+			b.WriteString(q[i:end])
+			i = end
+			continue
+		}
+		if !matchesIdentifierAt(q, i, name) {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+
+		open := i + len(name)
+		for open < len(q) && q[open] == ' ' {
+			open++
+		}
+		if open >= len(q) || q[open] != '(' {
+			b.WriteString(q[i : i+len(name)])
+			i += len(name)
+			continue
+		}
+		closeAt := matchingParen(q, open)
+		if closeAt < 0 {
+			b.WriteString(q[i:])
+			break
+		}
+
+		inner := rewriteCalls(q[open+1:closeAt], name, fn)
+		if replacement, ok := fn(splitTopLevel(inner, ',')); ok {
+			b.WriteString(replacement)
+		} else {
+			b.WriteString(q[i:open+1] + inner + ")")
+		}
+		i = closeAt + 1
+	}
+	return b.String()
+}
+
+func matchesIdentifierAt(q string, i int, name string) bool {
+	if i+len(name) > len(q) || !strings.EqualFold(q[i:i+len(name)], name) {
+		return false
+	}
+	if i > 0 && isIdentifierByte(q[i-1]) {
+		return false
+	}
+	if end := i + len(name); end < len(q) && isIdentifierByte(q[end]) {
+		return false
+	}
+	return true
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '.' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func matchingParen(q string, open int) int {
+	depth := 0
+	for i := open; i < len(q); i++ {
+		switch q[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(q, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on sep where it is not nested in parentheses or quotes.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(s, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// indexTopLevelKeyword finds keyword as a whole word outside parentheses and quotes.
+func indexTopLevelKeyword(s, keyword string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(s, i) - 1
+			continue
+		case '(':
+			depth++
+			continue
+		case ')':
+			depth--
+			continue
+		}
+		if depth == 0 && matchesIdentifierAt(s, i, keyword) {
+			return i
+		}
+	}
+	return -1
+}
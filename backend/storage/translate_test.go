@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "translate.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestSupportedMySQLRunsOnSQLite runs one statement of every supported form on SQLite.
+func TestSupportedMySQLRunsOnSQLite(t *testing.T) {
+	fixed := time.Date(2026, 3, 9, 10, 30, 0, 0, time.Local)
+	Now = func() time.Time { return fixed }
+	t.Cleanup(func() { Now = time.Now })
+	db := openTestSQLite(t)
+
+	mustExec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("%s\n%v", query, err)
+		}
+	}
+	queryString := func(query string, args ...interface{}) string {
+		t.Helper()
+		var value sql.NullString
+		if err := db.QueryRow(query, args...).Scan(&value); err != nil {
+			t.Fatalf("%s\n%v", query, err)
+		}
+		return value.String
+	}
+
+	mustExec(`
+		CREATE TABLE heartbeats (
+			user_id INT NOT NULL PRIMARY KEY,
+			name VARCHAR(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NULL,
+			last_seen DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT NOW() ON UPDATE CURRENT_TIMESTAMP
+		)`)
+	mustExec(`CREATE TABLE logins (id INT NOT NULL PRIMARY KEY, user_id INT NOT NULL, logout_time DATETIME NULL)`)
+
+	upsert := `
+		INSERT INTO heartbeats (user_id, name, last_seen) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE name = VALUES(name), last_seen = VALUES(last_seen)`
+	mustExec(upsert, 1, "first", fixed.Add(-time.Hour))
+	mustExec(upsert, 1, "second", fixed.Add(-10*time.Minute))
+	if got := queryString(`SELECT name FROM heartbeats WHERE user_id = 1`); got != "second" {
+		t.Errorf("upserted name = %q, want second", got)
+	}
+	// Column defaults use SQLite's own clock, not Now.
+	if got := queryString(`SELECT DATE_FORMAT(created_at, '%Y-%m-%d') FROM heartbeats WHERE user_id = 1`); got == "" {
+		t.Errorf("created_at has no default")
+	}
+
+	if got := queryString(`SELECT DATE_FORMAT(DATE_SUB(NOW(), INTERVAL 90 MINUTE), '%Y-%m-%d %H:%i:%s')`); got != "2026-03-09 09:00:00" {
+		t.Errorf("DATE_SUB = %q", got)
+	}
+	if got := queryString(`SELECT DATE_FORMAT(DATE_ADD(CURDATE(), INTERVAL 1 DAY), '%Y-%m-%d')`); got != "2026-03-10" {
+		t.Errorf("DATE_ADD = %q", got)
+	}
+	if got := queryString(`SELECT CAST(NOW() AS DATE)`); !strings.HasPrefix(got, "2026-03-09") {
+		t.Errorf("CAST AS DATE = %q", got)
+	}
+	if got := queryString(`SELECT CONVERT(name USING utf8mb4) FROM heartbeats WHERE user_id = 1`); got != "second" {
+		t.Errorf("CONVERT USING = %q", got)
+	}
+	if got := queryString(`SELECT TIMESTAMPDIFF(MINUTE, last_seen, NOW()) FROM heartbeats WHERE user_id = 1`); got != "10" {
+		t.Errorf("TIMESTAMPDIFF = %q", got)
+	}
+	if got := queryString(`SELECT DATEDIFF(CURDATE(), '2026-03-01')`); got != "8" {
+		t.Errorf("DATEDIFF = %q", got)
+	}
+
+	mustExec(`INSERT INTO logins (id, user_id) VALUES (1, 1), (2, 2)`)
+	mustExec(`
+		UPDATE logins le
+		JOIN heartbeats sh ON sh.user_id = le.user_id
+		SET le.logout_time = sh.last_seen
+		WHERE le.logout_time IS NULL`)
+	if got := queryString(`SELECT DATE_FORMAT(logout_time, '%H:%i') FROM logins WHERE id = 1`); got != "10:20" {
+		t.Errorf("UPDATE ... JOIN logout_time = %q, want 10:20", got)
+	}
+	if got := queryString(`SELECT COALESCE(logout_time, '') FROM logins WHERE id = 2`); got != "" {
+		t.Errorf("UPDATE ... JOIN touched a row without a match: %q", got)
+	}
+}
+
+// TestUnsupportedMySQLIsLeftAlone documents forms outside the subset: they reach SQLite
+// unchanged instead of being half-translated.
+func TestUnsupportedMySQLIsLeftAlone(t *testing.T) {
+	for _, query := range []string{
+		"UPDATE logins le LEFT JOIN heartbeats sh ON sh.user_id = le.user_id SET le.logout_time = NULL",
+		"DELETE sh FROM heartbeats sh WHERE sh.user_id = 1",
+		"ALTER TABLE logins MODIFY COLUMN logout_time DATETIME NULL",
+	} {
+		if got := Translate(query); got != query {
+			t.Errorf("Translate(%q) = %q, want it unchanged", query, got)
+		}
+	}
+	db := openTestSQLite(t)
+	if _, err := db.Exec(`CREATE TABLE heartbeats (user_id INT NOT NULL PRIMARY KEY)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if _, err := db.Exec(`DELETE sh FROM heartbeats sh WHERE sh.user_id = 1`); err == nil {
+		t.Errorf("a multi-table DELETE ran on SQLite; document it as supported instead")
+	}
+}
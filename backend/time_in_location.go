@@ -0,0 +1,182 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ==============================================================================
+// LOCATION-BOUND TIME-IN
+// ==============================================================================
+//
+// A class can require students to time in from its own computer lab. With the rule on,
+// StudentTimeIn only accepts stations whose configured computer lab matches the class
+// room or one of the class's extra allowed labs (compared without case or extra
+// spaces). Stations with no computer lab set are refused. The station label of every
+// time-in is kept on the attendance row so the teacher can see where it came from.
+
+const (
+	maxTimeInAllowedLabs  = 10
+	timeInAllowedLabsSep  = ";"
+	maxTimeInStationLabel = 100
+)
+
+// TimeInLocationRule is a class's time-in location rule. Room is the class room, which
+// is always allowed when the rule is on.
+type TimeInLocationRule struct {
+	Restricted  bool     `json:"restricted"`
+	Room        string   `json:"room"`
+	AllowedLabs []string `json:"allowed_labs"`
+}
+
+// allows reports whether a station in computerLab may time in under the rule.
+func (r TimeInLocationRule) allows(computerLab string) bool {
+	if !r.Restricted {
+		return true
+	}
+	lab := normalizeLabName(computerLab)
+	if lab == "" {
+		return false
+	}
+	for _, allowed := range append([]string{r.Room}, r.AllowedLabs...) {
+		if normalizeLabName(allowed) == lab {
+			return true
+		}
+	}
+	return false
+}
+
+// describe lists the labs the rule accepts, for error messages.
+func (r TimeInLocationRule) describe() string {
+	labs := []string{}
+	for _, lab := range append([]string{r.Room}, r.AllowedLabs...) {
+		if strings.TrimSpace(lab) != "" {
+			labs = append(labs, strings.TrimSpace(lab))
+		}
+	}
+	if len(labs) == 0 {
+		return "the class's lab"
+	}
+	return strings.Join(labs, ", ")
+}
+
+func normalizeLabName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// getTimeInLocationRule loads a class's time-in location rule.
+func getTimeInLocationRule(exec dbExecutor, classID int) (TimeInLocationRule, error) {
+	var rule TimeInLocationRule
+	var room, allowedLabs sql.NullString
+	err := exec.QueryRow(`
+		SELECT COALESCE(time_in_lab_restricted, 0), room, time_in_allowed_labs FROM classes WHERE class_id = ?
+	`, classID).Scan(&rule.Restricted, &room, &allowedLabs)
+	if err == sql.ErrNoRows {
+		return rule, fmt.Errorf("class not found")
+	}
+	if err != nil {
+		return rule, err
+	}
+	rule.Room = strings.TrimSpace(room.String)
+	rule.AllowedLabs = []string{}
+	for _, lab := range strings.Split(allowedLabs.String, timeInAllowedLabsSep) {
+		if lab = strings.TrimSpace(lab); lab != "" {
+			rule.AllowedLabs = append(rule.AllowedLabs, lab)
+		}
+	}
+	return rule, nil
+}
+
+// checkTimeInLocation refuses a time-in from a station outside the class's labs.
+func checkTimeInLocation(exec dbExecutor, classID int, computerLab string) error {
+	rule, err := getTimeInLocationRule(exec, classID)
+	if err != nil {
+		return err
+	}
+	if rule.allows(computerLab) {
+		return nil
+	}
+	if strings.TrimSpace(computerLab) == "" {
+		return fmt.Errorf("this class only accepts time-in from %s, and this PC has no computer lab set", rule.describe())
+	}
+	return fmt.Errorf("this class only accepts time-in from %s, not %s", rule.describe(), strings.TrimSpace(computerLab))
+}
+
+// GetClassTimeInLocationRule returns the time-in location rule of a class.
+func (a *App) GetClassTimeInLocationRule(classID int) (*TimeInLocationRule, error) {
+	session, err := a.requireRole("GetClassTimeInLocationRule")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return nil, err
+	}
+	rule, err := getTimeInLocationRule(a.db, classID)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SaveClassTimeInLocationRule turns the lab restriction of a class owned by the teacher
+// on or off and sets the labs allowed besides the class room.
+func (a *App) SaveClassTimeInLocationRule(classID int, teacherUserID int, rule TimeInLocationRule) error {
+	session, err := a.requireActingUser("SaveClassTimeInLocationRule", teacherUserID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	if err := a.checkClassAccess(session, classID); err != nil {
+		return err
+	}
+
+	var labs []string
+	seen := map[string]bool{}
+	for _, lab := range rule.AllowedLabs {
+		sanitized, err := sanitizeComputerLab(lab)
+		if err != nil {
+			return err
+		}
+		sanitized = strings.ReplaceAll(sanitized, timeInAllowedLabsSep, " ")
+		key := normalizeLabName(sanitized)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		labs = append(labs, strings.TrimSpace(sanitized))
+	}
+	if len(labs) > maxTimeInAllowedLabs {
+		return fmt.Errorf("at most %d extra labs can be allowed", maxTimeInAllowedLabs)
+	}
+
+	previous, err := getTimeInLocationRule(a.db, classID)
+	if err != nil {
+		return err
+	}
+	if rule.Restricted && previous.Room == "" && len(labs) == 0 {
+		return fmt.Errorf("set the class room or list at least one lab before restricting time-in")
+	}
+
+	if _, err := a.db.Exec(`
+		UPDATE classes
+		SET time_in_lab_restricted = ?, time_in_allowed_labs = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE class_id = ?
+	`, rule.Restricted, nullString(strings.Join(labs, timeInAllowedLabsSep)), classID); err != nil {
+		return fmt.Errorf("failed to save time-in location rule: %w", err)
+	}
+	a.audit(session, "update_time_in_location_rule", "class", classID,
+		auditValues{"restricted": previous.Restricted, "allowed_labs": strings.Join(previous.AllowedLabs, timeInAllowedLabsSep)},
+		auditValues{"restricted": rule.Restricted, "allowed_labs": strings.Join(labs, timeInAllowedLabsSep)})
+	return nil
+}
+
+// timeInStationLabel trims a station label to fit attendance.time_in_station.
+func timeInStationLabel(label string) string {
+	return truncateString(strings.TrimSpace(label), maxTimeInStationLabel)
+}
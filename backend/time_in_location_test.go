@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTimeInRestrictedToClassLab(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	anaID := e.seedUser("student", "2024-00001", "Ana", "Cruz")
+	benID := e.seedUser("student", "2024-00002", "Ben", "Santos")
+	classID := e.seedClass(teacherID, "IT101", anaID, benID)
+	e.exec(`UPDATE classes SET room = ? WHERE class_id = ?`, "Lab 1", classID)
+
+	e.loginAs("T-0001")
+	if err := e.app.SaveClassTimeInLocationRule(classID, teacherID, TimeInLocationRule{Restricted: true, AllowedLabs: []string{" annex lab ", "Annex Lab"}}); err != nil {
+		t.Fatalf("SaveClassTimeInLocationRule: %v", err)
+	}
+	rule, err := e.app.GetClassTimeInLocationRule(classID)
+	if err != nil || !rule.Restricted || rule.Room != "Lab 1" || len(rule.AllowedLabs) != 1 || rule.AllowedLabs[0] != "annex lab" {
+		t.Fatalf("GetClassTimeInLocationRule = %+v, %v", rule, err)
+	}
+	session, err := e.app.CreateAttendanceSession(classID, e.clock.Today(), "", teacherID, 60, 10)
+	if err != nil {
+		t.Fatalf("CreateAttendanceSession: %v", err)
+	}
+
+	// The library station is refused before the code is even checked.
+	e.app.computerLab, e.app.pcNumber = "Library", "3"
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err == nil || !strings.Contains(err.Error(), "Lab 1") {
+		t.Fatalf("time-in from the library: err = %v", err)
+	}
+	if got := e.queryInt(`SELECT COUNT(*) FROM attendance_code_attempts WHERE session_id = ?`, session.SessionID); got != 0 {
+		t.Errorf("refused station logged %d code attempts", got)
+	}
+
+	// The class room matches regardless of case and spacing.
+	e.app.computerLab, e.app.pcNumber = "lab  1", "5"
+	e.loginAs("2024-00001")
+	if err := e.app.StudentTimeIn(session.SessionID, anaID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn from the class room: %v", err)
+	}
+	e.app.computerLab, e.app.pcNumber = "Annex Lab", "2"
+	e.loginAs("2024-00002")
+	if err := e.app.StudentTimeIn(session.SessionID, benID, e.timeInCode(session.SessionID)); err != nil {
+		t.Fatalf("StudentTimeIn from an allowed lab: %v", err)
+	}
+
+	e.loginAs("T-0001")
+	records, err := e.app.GetSessionAttendance(session.SessionID, teacherID)
+	if err != nil {
+		t.Fatalf("GetSessionAttendance: %v", err)
+	}
+	stations := map[int]string{}
+	for _, record := range records {
+		if record.TimeInStation != nil {
+			stations[record.StudentUserID] = *record.TimeInStation
+		}
+	}
+	if stations[anaID] != "lab  1 - PC 5" || stations[benID] != "Annex Lab - PC 2" {
+		t.Errorf("time-in stations = %v", stations)
+	}
+
+	// A restriction needs somewhere to time in from.
+	e.exec(`UPDATE classes SET room = NULL WHERE class_id = ?`, classID)
+	if err := e.app.SaveClassTimeInLocationRule(classID, teacherID, TimeInLocationRule{Restricted: true}); err == nil {
+		t.Errorf("restricted a class with no room and no allowed labs")
+	}
+}
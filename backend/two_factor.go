@@ -0,0 +1,768 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// TWO-FACTOR AUTHENTICATION
+// ==============================================================================
+//
+// Admins and teachers can add an RFC 6238 TOTP authenticator to their account. Codes are
+// checked locally against the stored secret, so no network service is involved. Each
+// enrollment also issues one-time backup codes in the same format and hashing as
+// recovery codes. Admins can make two-factor authentication mandatory per role; users of
+// such a role without an authenticator enroll during their next login.
+
+const (
+	totpIssuer       = "Digital Logbook"
+	totpPeriod       = 30
+	totpDigits       = 6
+	totpSkewSteps    = 1
+	totpSecretLength = 20
+
+	twoFactorBackupCodeCount   = 10
+	twoFactorChallengeTTL      = 5 * time.Minute
+	twoFactorChallengeAttempts = 5
+)
+
+// twoFactorRoles are the roles that can use two-factor authentication.
+var twoFactorRoles = []string{"admin", "teacher"}
+
+// TwoFactorEnrollment is what a user needs to add the account to an authenticator app.
+// ProvisioningURI is the otpauth:// URI to show as a QR code. BackupCodes are shown once.
+type TwoFactorEnrollment struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri"`
+	BackupCodes     []string `json:"backup_codes"`
+}
+
+// TwoFactorStatus describes a user's two-factor setup.
+type TwoFactorStatus struct {
+	Enabled              bool `json:"enabled"`
+	Required             bool `json:"required"`
+	BackupCodesRemaining int  `json:"backup_codes_remaining"`
+}
+
+// TwoFactorPolicy says whether two-factor authentication is mandatory for a role.
+type TwoFactorPolicy struct {
+	Role      string  `json:"role"`
+	Required  bool    `json:"required"`
+	UpdatedAt *string `json:"updated_at,omitempty"`
+}
+
+// twoFactorChallenge is a login whose password has been verified and that is waiting
+// for a second factor.
+type twoFactorChallenge struct {
+	user           User
+	username       string
+	storedPassword string
+	stationLabel   string
+	enrolling      bool
+	expiresAt      time.Time
+	attempts       int
+}
+
+// ==============================================================================
+// TOTP (RFC 6238)
+// ==============================================================================
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func generateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate authenticator secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// totpCodeAt returns the code for a time step (RFC 4226 HOTP over the step counter).
+func totpCodeAt(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulus)
+}
+
+// matchTOTPCode checks code against the steps around now and returns the matching step.
+// Steps at or before lastStep were already used and are refused.
+func matchTOTPCode(secret []byte, code string, now time.Time, lastStep int64) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for skew := int64(-totpSkewSteps); skew <= totpSkewSteps; skew++ {
+		step := current + skew
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCodeAt(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpProvisioningURI(username, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(totpIssuer+":"+username) + "?" + query.Encode()
+}
+
+// normalizeTOTPCode strips spaces and reports whether the input looks like an authenticator code.
+func normalizeTOTPCode(code string) (string, bool) {
+	normalized := strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(normalized) != totpDigits {
+		return normalized, false
+	}
+	for _, r := range normalized {
+		if r < '0' || r > '9' {
+			return normalized, false
+		}
+	}
+	return normalized, true
+}
+
+// ==============================================================================
+// STORAGE
+// ==============================================================================
+
+func (a *App) isTwoFactorRequired(role string) (bool, error) {
+	if !containsRole(twoFactorRoles, role) {
+		return false, nil
+	}
+	var required int
+	err := a.db.QueryRow(`SELECT is_required FROM two_factor_policies WHERE role = ?`, role).Scan(&required)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read two-factor policy: %w", err)
+	}
+	return required != 0, nil
+}
+
+func (a *App) isTwoFactorEnabled(userID int) (bool, error) {
+	var enabled int
+	err := a.db.QueryRow(`SELECT is_enabled FROM user_totp WHERE user_id = ?`, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read two-factor status: %w", err)
+	}
+	return enabled != 0, nil
+}
+
+// issueTwoFactorBackupCodes replaces a user's backup codes and returns the new ones.
+func issueTwoFactorBackupCodes(exec dbExecutor, userID int, now time.Time) ([]string, error) {
+	if _, err := exec.Exec(`DELETE FROM user_totp_backup_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, fmt.Errorf("failed to replace backup codes: %w", err)
+	}
+
+	codes := make([]string, 0, twoFactorBackupCodeCount)
+	for i := 0; i < twoFactorBackupCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := exec.Exec(`
+			INSERT INTO user_totp_backup_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)
+		`, userID, hashRecoveryCode(code), now); err != nil {
+			return nil, fmt.Errorf("failed to store backup codes: %w", err)
+		}
+		codes = append(codes, formatRecoveryCode(code))
+	}
+	return codes, nil
+}
+
+// beginTwoFactorEnrollment stores a new, not yet confirmed secret and fresh backup codes.
+func (a *App) beginTwoFactorEnrollment(userID int, username string) (*TwoFactorEnrollment, error) {
+	enabled, err := a.isTwoFactorEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled - disable it first to enroll a new device")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, keyID, err := encryptRecoverySecret(a.db, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to protect authenticator secret: %w", err)
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return nil, fmt.Errorf("failed to replace authenticator secret: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_totp (user_id, secret_ciphertext, secret_key_id, is_enabled, created_at) VALUES (?, ?, ?, 0, ?)
+	`, userID, ciphertext, keyID, a.now()); err != nil {
+		return nil, fmt.Errorf("failed to store authenticator secret: %w", err)
+	}
+	backupCodes, err := issueTwoFactorBackupCodes(tx, userID, a.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit two-factor enrollment: %w", err)
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(username, secret),
+		BackupCodes:     backupCodes,
+	}, nil
+}
+
+// verifyTOTPCode checks an authenticator code for the user and marks its time step used.
+// pending selects the unconfirmed secret of an enrollment instead of the enabled one.
+func (a *App) verifyTOTPCode(exec dbExecutor, userID int, code string, pending bool) error {
+	code, ok := normalizeTOTPCode(code)
+	if !ok {
+		return fmt.Errorf("enter the %d-digit code from your authenticator app", totpDigits)
+	}
+
+	wantEnabled := 1
+	if pending {
+		wantEnabled = 0
+	}
+	var ciphertext string
+	var keyID, lastStep sql.NullInt64
+	err := exec.QueryRow(`
+		SELECT secret_ciphertext, secret_key_id, last_used_step FROM user_totp WHERE user_id = ? AND is_enabled = ?
+	`, userID, wantEnabled).Scan(&ciphertext, &keyID, &lastStep)
+	if err == sql.ErrNoRows {
+		if pending {
+			return fmt.Errorf("no two-factor enrollment is in progress")
+		}
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read authenticator secret: %w", err)
+	}
+
+	encoded, err := decryptRecoverySecret(exec, ciphertext, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to read authenticator secret: %w", err)
+	}
+	secret, err := totpEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("stored authenticator secret is invalid")
+	}
+
+	step, matched := matchTOTPCode(secret, code, a.now(), lastStep.Int64)
+	if !matched {
+		return fmt.Errorf("invalid verification code")
+	}
+	if _, err := exec.Exec(`UPDATE user_totp SET last_used_step = ? WHERE user_id = ?`, step, userID); err != nil {
+		return fmt.Errorf("failed to record verification code use: %w", err)
+	}
+	return nil
+}
+
+// verifySecondFactor accepts either an authenticator code or an unused backup code.
+func (a *App) verifySecondFactor(exec dbExecutor, userID int, code string) error {
+	if _, ok := normalizeTOTPCode(code); ok {
+		return a.verifyTOTPCode(exec, userID, code, false)
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return fmt.Errorf("invalid verification code")
+	}
+	result, err := exec.Exec(`
+		UPDATE user_totp_backup_codes
+		SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, a.now(), userID, hashRecoveryCode(normalized))
+	if err != nil {
+		return fmt.Errorf("failed to verify backup code: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("invalid verification code")
+	}
+	log.Printf("Two-factor backup code used by user %d", userID)
+	return nil
+}
+
+func (a *App) removeTwoFactor(exec dbExecutor, userID int) error {
+	if _, err := exec.Exec(`DELETE FROM user_totp_backup_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to remove backup codes: %w", err)
+	}
+	if _, err := exec.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to remove authenticator: %w", err)
+	}
+	return nil
+}
+
+// ==============================================================================
+// LOGIN CHALLENGE
+// ==============================================================================
+
+// startTwoFactorChallenge returns a placeholder user carrying a challenge token when the
+// user must enter (or set up) a second factor, or nil when the login can complete now.
+func (a *App) startTwoFactorChallenge(user *User, username, storedPassword, stationLabel string) (*User, error) {
+	if !containsRole(twoFactorRoles, user.Role) {
+		return nil, nil
+	}
+
+	enabled, err := a.isTwoFactorEnabled(user.ID)
+	if err != nil {
+		log.Printf("LOGIN ERROR: %v", err)
+		return nil, fmt.Errorf("unable to verify two-factor settings - please try again")
+	}
+	enrolling := false
+	if !enabled {
+		required, err := a.isTwoFactorRequired(user.Role)
+		if err != nil {
+			log.Printf("LOGIN ERROR: %v", err)
+			return nil, fmt.Errorf("unable to verify two-factor settings - please try again")
+		}
+		if !required {
+			return nil, nil
+		}
+		enrolling = true
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	a.twoFactorMu.Lock()
+	if a.twoFactorChallenges == nil {
+		a.twoFactorChallenges = make(map[string]*twoFactorChallenge)
+	}
+	now := a.now()
+	for key, pending := range a.twoFactorChallenges {
+		if !now.Before(pending.expiresAt) {
+			delete(a.twoFactorChallenges, key)
+		}
+	}
+	a.twoFactorChallenges[token] = &twoFactorChallenge{
+		user:           *user,
+		username:       username,
+		storedPassword: storedPassword,
+		stationLabel:   stationLabel,
+		enrolling:      enrolling,
+		expiresAt:      now.Add(twoFactorChallengeTTL),
+	}
+	a.twoFactorMu.Unlock()
+
+	log.Printf("Login for %s waiting for two-factor verification (setup: %v)", username, enrolling)
+	return &User{
+		ID:                     user.ID,
+		Name:                   user.Name,
+		Role:                   user.Role,
+		TwoFactorRequired:      true,
+		TwoFactorSetupRequired: enrolling,
+		TwoFactorToken:         token,
+	}, nil
+}
+
+func (a *App) lookupTwoFactorChallenge(token string) (*twoFactorChallenge, error) {
+	a.twoFactorMu.Lock()
+	defer a.twoFactorMu.Unlock()
+
+	challenge, found := a.twoFactorChallenges[token]
+	if !found || token == "" {
+		return nil, fmt.Errorf("login expired - please log in again")
+	}
+	if !a.now().Before(challenge.expiresAt) {
+		delete(a.twoFactorChallenges, token)
+		return nil, fmt.Errorf("login expired - please log in again")
+	}
+	copied := *challenge
+	return &copied, nil
+}
+
+// failTwoFactorChallenge counts a wrong code and drops the challenge after too many.
+func (a *App) failTwoFactorChallenge(token string, challenge *twoFactorChallenge) {
+	a.recordLoginFailure(challenge.username, challenge.stationLabel)
+
+	a.twoFactorMu.Lock()
+	defer a.twoFactorMu.Unlock()
+	if pending, found := a.twoFactorChallenges[token]; found {
+		pending.attempts++
+		if pending.attempts >= twoFactorChallengeAttempts {
+			delete(a.twoFactorChallenges, token)
+		}
+	}
+}
+
+// GetTwoFactorLoginEnrollment starts authenticator setup for a login whose role requires
+// two-factor authentication but whose account has none yet.
+func (a *App) GetTwoFactorLoginEnrollment(token string) (*TwoFactorEnrollment, error) {
+	challenge, err := a.lookupTwoFactorChallenge(token)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.enrolling {
+		return nil, fmt.Errorf("two-factor authentication is already set up for this account")
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	return a.beginTwoFactorEnrollment(challenge.user.ID, challenge.username)
+}
+
+// CompleteTwoFactorLogin finishes a login that Login answered with a two-factor token.
+// code is an authenticator code or, for an enrolled account, one of its backup codes.
+func (a *App) CompleteTwoFactorLogin(token, code string) (*User, error) {
+	challenge, err := a.lookupTwoFactorChallenge(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	if err := a.checkLoginThrottle(challenge.username, challenge.stationLabel); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID := challenge.user.ID
+	if challenge.enrolling {
+		err = a.verifyTOTPCode(tx, userID, code, true)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE user_totp SET is_enabled = 1, enabled_at = ? WHERE user_id = ?`, a.now(), userID)
+		}
+	} else {
+		err = a.verifySecondFactor(tx, userID, code)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("LOGIN ERROR: Two-factor verification failed for user '%s': %v", challenge.username, err)
+		a.failTwoFactorChallenge(token, challenge)
+		return nil, err
+	}
+	if challenge.enrolling {
+		session := &appSession{UserID: userID, Username: challenge.user.Name, Role: challenge.user.Role}
+		if err := a.recordAuditEvent(tx, session, "enable_two_factor", "user", userID, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit two-factor verification: %w", err)
+	}
+
+	a.twoFactorMu.Lock()
+	delete(a.twoFactorChallenges, token)
+	a.twoFactorMu.Unlock()
+
+	return a.completeLogin(challenge.user, challenge.username, challenge.storedPassword, challenge.stationLabel)
+}
+
+// ==============================================================================
+// SELF-SERVICE
+// ==============================================================================
+
+// GetTwoFactorStatus reports whether the user has an authenticator and whether their
+// role requires one.
+func (a *App) GetTwoFactorStatus(userID int) (*TwoFactorStatus, error) {
+	session, err := a.requireActingUser("GetTwoFactorStatus", userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{}
+	if status.Enabled, err = a.isTwoFactorEnabled(userID); err != nil {
+		return nil, err
+	}
+	if status.Required, err = a.isTwoFactorRequired(session.Role); err != nil {
+		return nil, err
+	}
+	if status.Enabled {
+		if err := a.db.QueryRow(`
+			SELECT COUNT(*) FROM user_totp_backup_codes WHERE user_id = ? AND used_at IS NULL
+		`, userID).Scan(&status.BackupCodesRemaining); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginTwoFactorEnrollment creates a new authenticator secret and backup codes. Two-factor
+// authentication is turned on once ConfirmTwoFactorEnrollment receives a valid code.
+func (a *App) BeginTwoFactorEnrollment(userID int) (*TwoFactorEnrollment, error) {
+	session, err := a.requireActingUser("BeginTwoFactorEnrollment", userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+	return a.beginTwoFactorEnrollment(userID, session.Username)
+}
+
+// ConfirmTwoFactorEnrollment turns on two-factor authentication after checking a code
+// from the newly enrolled authenticator.
+func (a *App) ConfirmTwoFactorEnrollment(userID int, code string) error {
+	session, err := a.requireActingUser("ConfirmTwoFactorEnrollment", userID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := a.verifyTOTPCode(tx, userID, code, true); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE user_totp SET is_enabled = 1, enabled_at = ? WHERE user_id = ?`, a.now(), userID); err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	if err := a.recordAuditEvent(tx, session, "enable_two_factor", "user", userID, nil, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor enrollment: %w", err)
+	}
+
+	log.Printf("Two-factor authentication enabled for user %d", userID)
+	return nil
+}
+
+// DisableTwoFactor removes the user's authenticator and backup codes. A current code is
+// required, and users whose role requires two-factor authentication cannot turn it off.
+func (a *App) DisableTwoFactor(userID int, code string) error {
+	session, err := a.requireActingUser("DisableTwoFactor", userID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+
+	required, err := a.isTwoFactorRequired(session.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return fmt.Errorf("two-factor authentication is required for %s accounts", session.Role)
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := a.verifySecondFactor(tx, userID, code); err != nil {
+		return err
+	}
+	if err := a.removeTwoFactor(tx, userID); err != nil {
+		return err
+	}
+	if err := a.recordAuditEvent(tx, session, "disable_two_factor", "user", userID, nil, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor change: %w", err)
+	}
+
+	log.Printf("Two-factor authentication disabled for user %d", userID)
+	return nil
+}
+
+// RegenerateTwoFactorBackupCodes replaces the user's backup codes after checking a current code.
+func (a *App) RegenerateTwoFactorBackupCodes(userID int, code string) ([]string, error) {
+	session, err := a.requireActingUser("RegenerateTwoFactorBackupCodes", userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := a.verifyTOTPCode(tx, userID, code, false); err != nil {
+		return nil, err
+	}
+	codes, err := issueTwoFactorBackupCodes(tx, userID, a.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := a.recordAuditEvent(tx, session, "regenerate_two_factor_backup_codes", "user", userID, nil, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit backup codes: %w", err)
+	}
+	return codes, nil
+}
+
+// ==============================================================================
+// ADMINISTRATION
+// ==============================================================================
+
+// GetTwoFactorPolicies returns whether two-factor authentication is mandatory for each
+// role that can use it.
+func (a *App) GetTwoFactorPolicies() ([]TwoFactorPolicy, error) {
+	if _, err := a.requireRole("GetTwoFactorPolicies"); err != nil {
+		return nil, err
+	}
+	if err := a.checkDB(); err != nil {
+		return nil, err
+	}
+
+	policies := make([]TwoFactorPolicy, 0, len(twoFactorRoles))
+	for _, role := range twoFactorRoles {
+		policy := TwoFactorPolicy{Role: role}
+		var required int
+		var updatedAt sql.NullString
+		err := a.db.QueryRow(`
+			SELECT is_required, DATE_FORMAT(updated_at, '%Y-%m-%d %H:%i:%s')
+			FROM two_factor_policies WHERE role = ?
+		`, role).Scan(&required, &updatedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to read two-factor policy: %w", err)
+		}
+		policy.Required = required != 0
+		policy.UpdatedAt = scanNullString(updatedAt)
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// SetTwoFactorRequired makes two-factor authentication mandatory (or optional) for a role.
+// Users of that role without an authenticator are asked to enroll at their next login.
+func (a *App) SetTwoFactorRequired(role string, required bool) error {
+	session, err := a.requireRole("SetTwoFactorRequired")
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !containsRole(twoFactorRoles, role) {
+		return fmt.Errorf("two-factor authentication is only available for admin and teacher accounts")
+	}
+	previous, err := a.isTwoFactorRequired(role)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO two_factor_policies (role, is_required, updated_by_user_id, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			is_required = VALUES(is_required),
+			updated_by_user_id = VALUES(updated_by_user_id),
+			updated_at = VALUES(updated_at)
+	`, role, required, session.UserID, a.now()); err != nil {
+		return fmt.Errorf("failed to save two-factor policy: %w", err)
+	}
+	if err := a.recordAuditEvent(tx, session, "update_two_factor_policy", "two_factor_policy", role,
+		auditValues{"required": previous}, auditValues{"required": required}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor policy: %w", err)
+	}
+
+	log.Printf("Two-factor authentication for %s accounts set to required=%v by user %d", role, required, session.UserID)
+	return nil
+}
+
+// ResetUserTwoFactor removes another user's authenticator, for example after a lost phone.
+// If their role requires two-factor authentication they enroll again at their next login.
+func (a *App) ResetUserTwoFactor(userID int) error {
+	session, err := a.requireRole("ResetUserTwoFactor")
+	if err != nil {
+		return err
+	}
+	if err := a.checkDB(); err != nil {
+		return err
+	}
+	if err := ValidatePositiveID(userID, "user ID"); err != nil {
+		return err
+	}
+
+	enabled, err := a.isTwoFactorEnabled(userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := a.removeTwoFactor(tx, userID); err != nil {
+		return err
+	}
+	if err := a.recordAuditEvent(tx, session, "reset_two_factor", "user", userID,
+		auditValues{"enabled": enabled}, auditValues{"enabled": false}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor reset: %w", err)
+	}
+
+	go a.createNotification(userID, "security",
+		"Two-Factor Authentication Reset",
+		"An administrator removed the authenticator from your account. Set it up again from your profile.",
+		"warning", notifRef("two_factor"), nil)
+	return nil
+}
@@ -0,0 +1,141 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		if got := totpCodeAt(secret, unix/totpPeriod); got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+// totpNow returns the authenticator code for an enrollment secret at the test clock's time.
+func (e *testEnv) totpNow(secret string) string {
+	e.t.Helper()
+	raw, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		e.t.Fatalf("decode secret: %v", err)
+	}
+	return totpCodeAt(raw, e.clock.Now().Unix()/totpPeriod)
+}
+
+func TestTeacherLoginWithTwoFactor(t *testing.T) {
+	e := newTestEnv(t)
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.loginAs("T-0001")
+
+	enrollment, err := e.app.BeginTwoFactorEnrollment(teacherID)
+	if err != nil {
+		t.Fatalf("BeginTwoFactorEnrollment: %v", err)
+	}
+	if len(enrollment.BackupCodes) != twoFactorBackupCodeCount || enrollment.ProvisioningURI == "" {
+		t.Fatalf("enrollment = %+v", enrollment)
+	}
+	if err := e.app.ConfirmTwoFactorEnrollment(teacherID, "000000"); err == nil {
+		t.Fatalf("enrollment was confirmed with a wrong code")
+	}
+	if err := e.app.ConfirmTwoFactorEnrollment(teacherID, e.totpNow(enrollment.Secret)); err != nil {
+		t.Fatalf("ConfirmTwoFactorEnrollment: %v", err)
+	}
+	if err := e.app.Logout(teacherID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	pending, err := e.app.Login("T-0001", testPassword)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !pending.TwoFactorRequired || pending.TwoFactorToken == "" || pending.SessionToken != "" {
+		t.Fatalf("Login = %+v, want a two-factor challenge without a session", pending)
+	}
+	if _, err := e.app.GetTwoFactorStatus(teacherID); err == nil {
+		t.Fatalf("a bound method worked before the second factor was entered")
+	}
+
+	// The code used to confirm enrollment cannot be replayed.
+	if _, err := e.app.CompleteTwoFactorLogin(pending.TwoFactorToken, e.totpNow(enrollment.Secret)); err == nil {
+		t.Fatalf("a used authenticator code was accepted again")
+	}
+	e.clock.Advance(totpPeriod * time.Second)
+	user, err := e.app.CompleteTwoFactorLogin(pending.TwoFactorToken, e.totpNow(enrollment.Secret))
+	if err != nil {
+		t.Fatalf("CompleteTwoFactorLogin: %v", err)
+	}
+	if user.SessionToken == "" || user.ID != teacherID {
+		t.Fatalf("CompleteTwoFactorLogin = %+v, want a session", user)
+	}
+	status, err := e.app.GetTwoFactorStatus(teacherID)
+	if err != nil || !status.Enabled || status.BackupCodesRemaining != twoFactorBackupCodeCount {
+		t.Fatalf("GetTwoFactorStatus = %+v, %v", status, err)
+	}
+
+	// A backup code works once.
+	e.app.Logout(teacherID)
+	pending, _ = e.app.Login("T-0001", testPassword)
+	if _, err := e.app.CompleteTwoFactorLogin(pending.TwoFactorToken, enrollment.BackupCodes[0]); err != nil {
+		t.Fatalf("CompleteTwoFactorLogin with a backup code: %v", err)
+	}
+	e.app.Logout(teacherID)
+	pending, _ = e.app.Login("T-0001", testPassword)
+	if _, err := e.app.CompleteTwoFactorLogin(pending.TwoFactorToken, enrollment.BackupCodes[0]); err == nil {
+		t.Fatalf("a used backup code was accepted again")
+	}
+	if _, err := e.app.CompleteTwoFactorLogin("not-a-token", enrollment.BackupCodes[1]); err == nil {
+		t.Fatalf("an unknown login token was accepted")
+	}
+}
+
+func TestRequiredTwoFactorEnrollsAtLogin(t *testing.T) {
+	e := newTestEnv(t)
+	e.seedUser("admin", "A-0001", "Ada", "Admin")
+	teacherID := e.seedUser("teacher", "T-0001", "Tess", "Reyes")
+	e.seedUser("student", "2024-00001", "Ana", "Cruz")
+
+	e.loginAs("A-0001")
+	if err := e.app.SetTwoFactorRequired("student", true); err == nil {
+		t.Errorf("two-factor authentication was required for students")
+	}
+	if err := e.app.SetTwoFactorRequired("teacher", true); err != nil {
+		t.Fatalf("SetTwoFactorRequired: %v", err)
+	}
+
+	if user := e.loginAs("2024-00001"); user.TwoFactorRequired {
+		t.Fatalf("a student login asked for a second factor")
+	}
+
+	pending, err := e.app.Login("T-0001", testPassword)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !pending.TwoFactorSetupRequired {
+		t.Fatalf("Login = %+v, want setup required", pending)
+	}
+	enrollment, err := e.app.GetTwoFactorLoginEnrollment(pending.TwoFactorToken)
+	if err != nil {
+		t.Fatalf("GetTwoFactorLoginEnrollment: %v", err)
+	}
+	if _, err := e.app.CompleteTwoFactorLogin(pending.TwoFactorToken, enrollment.BackupCodes[0]); err == nil {
+		t.Fatalf("a backup code confirmed a new enrollment")
+	}
+	if _, err := e.app.CompleteTwoFactorLogin(pending.TwoFactorToken, e.totpNow(enrollment.Secret)); err != nil {
+		t.Fatalf("CompleteTwoFactorLogin: %v", err)
+	}
+
+	e.clock.Advance(totpPeriod * time.Second)
+	if err := e.app.DisableTwoFactor(teacherID, e.totpNow(enrollment.Secret)); err == nil {
+		t.Fatalf("a teacher turned off required two-factor authentication")
+	}
+	if n := e.queryInt(`SELECT COUNT(*) FROM audit_events WHERE action = 'enable_two_factor' AND entity_id = ?`, teacherID); n != 1 {
+		t.Errorf("enable_two_factor audit events = %d, want 1", n)
+	}
+}
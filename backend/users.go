@@ -37,16 +37,29 @@ func buildInactivityPolicySettings() InactivityPolicySettings {
 
 // GetInactivityPolicySettings returns policy thresholds for admin settings UI.
 func (a *App) GetInactivityPolicySettings() (InactivityPolicySettings, error) {
+	if _, err := a.requireRole("GetInactivityPolicySettings"); err != nil {
+		return InactivityPolicySettings{}, err
+	}
 	return buildInactivityPolicySettings(), nil
 }
 
 // SaveInactivityPolicySettings persists policy thresholds to config.ini.
 func (a *App) SaveInactivityPolicySettings(inactivityDays, deletionDays int) (InactivityPolicySettings, error) {
+	session, err := a.requireRole("SaveInactivityPolicySettings")
+	if err != nil {
+		return InactivityPolicySettings{}, err
+	}
+	previous := buildInactivityPolicySettings()
 	if err := SavePolicyThresholds(inactivityDays, deletionDays); err != nil {
 		return InactivityPolicySettings{}, err
 	}
 
-	return buildInactivityPolicySettings(), nil
+	updated := buildInactivityPolicySettings()
+	a.reloadIdleLogoutPolicy()
+	a.audit(session, "save_inactivity_policy", "settings", "inactivity_policy",
+		auditValues{"inactivity_days": previous.ConfiguredInactivityDeactivationDays, "deletion_days": previous.ConfiguredDeactivatedDeletionDays},
+		auditValues{"inactivity_days": updated.ConfiguredInactivityDeactivationDays, "deletion_days": updated.ConfiguredDeactivatedDeletionDays})
+	return updated, nil
 }
 
 // ==============================================================================
@@ -55,6 +68,9 @@ func (a *App) SaveInactivityPolicySettings(inactivityDays, deletionDays int) (In
 
 // GetUsers returns all users with complete details
 func (a *App) GetUsers() ([]User, error) {
+	if _, err := a.requireRole("GetUsers"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -99,6 +115,9 @@ func (a *App) GetUsers() ([]User, error) {
 
 // GetUsersByType returns users filtered by type with complete details
 func (a *App) GetUsersByType(userType string) ([]User, error) {
+	if _, err := a.requireRole("GetUsersByType"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -143,6 +162,9 @@ func (a *App) GetUsersByType(userType string) ([]User, error) {
 
 // SearchUsers searches users by name, ID, gender, or date with complete details
 func (a *App) SearchUsers(searchTerm, userType string) ([]User, error) {
+	if _, err := a.requireRole("SearchUsers"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -231,6 +253,10 @@ func (a *App) SearchUsers(searchTerm, userType string) ([]User, error) {
 
 // CreateUser creates a new user
 func (a *App) CreateUser(password, name, firstName, middleName, lastName, role, employeeID, studentID, email, contactNumber string, departmentCode string) error {
+	session, err := a.requireRole("CreateUser")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -320,13 +346,17 @@ func (a *App) CreateUser(password, name, firstName, middleName, lastName, role,
 		return err
 	}
 
-	// Allow temporary password equal to login ID for admin-created accounts.
-	// Any other password must satisfy strong password policy.
+	// Allow temporary password equal to login ID for admin-created accounts; it still has
+	// to meet the minimum length and stay off the denylist. Any other password must
+	// satisfy the whole password policy. Either way the user has to choose their own
+	// password at the first login.
 	isTemporaryIDPassword := password == strings.TrimSpace(username)
-	if !isTemporaryIDPassword {
-		if err := ValidateStrongPassword(password); err != nil {
-			return err
-		}
+	validate := a.validateNewPassword
+	if isTemporaryIDPassword {
+		validate = a.validateTemporaryIDPassword
+	}
+	if err := validate(0, password); err != nil {
+		return err
 	}
 
 	// Hash password before storing
@@ -336,7 +366,7 @@ func (a *App) CreateUser(password, name, firstName, middleName, lastName, role,
 	}
 
 	// Insert into users table
-	query := `INSERT INTO users (username, password, user_type) VALUES (?, ?, ?)`
+	query := `INSERT INTO users (username, password, user_type, must_change_password) VALUES (?, ?, ?, 1)`
 	result, err := a.db.Exec(query, username, string(hashedPassword), role)
 	if err != nil {
 		log.Printf("Failed to insert into users table: %v", err)
@@ -351,7 +381,7 @@ func (a *App) CreateUser(password, name, firstName, middleName, lastName, role,
 	log.Printf("Created user account with ID: %d", userID)
 
 	// Insert into role-specific table
-	if err := a.insertRoleSpecificProfile(userID, role, firstName, middleName, lastName, employeeID, studentID, email, contactNumber, departmentCode); err != nil {
+	if err := a.insertRoleSpecificProfile(a.db, userID, role, firstName, middleName, lastName, employeeID, studentID, email, contactNumber, departmentCode); err != nil {
 		return err
 	}
 
@@ -360,17 +390,31 @@ func (a *App) CreateUser(password, name, firstName, middleName, lastName, role,
 	}
 
 	log.Printf("Successfully created %s: %s %s (ID: %d)", role, firstName, lastName, userID)
+	a.audit(session, "create_user", "user", userID, nil, auditValues{
+		"username": username, "role": role,
+		"first_name": firstName, "middle_name": middleName, "last_name": lastName,
+		"email": email, "contact_number": contactNumber, "department_code": departmentCode,
+		"temporary_id_password": isTemporaryIDPassword,
+	})
 	return nil
 }
 
 // UpdateUser updates an existing user
 func (a *App) UpdateUser(id int, name, firstName, middleName, lastName, role, employeeID, studentID, email, contactNumber string, departmentCode string) error {
+	session, err := a.requireActingUser("UpdateUser", id)
+	if err != nil {
+		return err
+	}
+	if session.Role != "admin" && role != session.Role {
+		return fmt.Errorf("you are not allowed to change your own role")
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
 
+	before := a.userProfileAuditValues(id, role)
+
 	var query string
-	var err error
 
 	switch role {
 	case "admin":
@@ -403,8 +447,59 @@ func (a *App) UpdateUser(id int, name, firstName, middleName, lastName, role, em
 		query = `UPDATE students SET first_name = ?, middle_name = ?, last_name = ?, student_id = ?, email = ?, contact_number = ?, department_code = ? WHERE id = ? AND is_working_student = 1`
 		_, err = a.db.Exec(query, firstName, nullString(middleName), lastName, nullString(studentID), nullString(email), nullString(contactNumber), nullString(departmentCode), id)
 	}
+	if err != nil {
+		return err
+	}
 
-	return err
+	if before != nil {
+		after := auditValues{
+			"first_name": firstName, "middle_name": middleName, "last_name": lastName,
+			"email": email, "contact_number": contactNumber, "department_code": departmentCode,
+		}
+		switch role {
+		case "admin", "teacher":
+			after["employee_id"] = employeeID
+		default:
+			after["student_id"] = studentID
+		}
+		a.audit(session, "update_user", "user", id, before, after)
+	}
+	return nil
+}
+
+// userProfileAuditValues snapshots the editable profile fields of a user for the audit trail.
+// It returns nil when the profile cannot be read.
+func (a *App) userProfileAuditValues(id int, role string) auditValues {
+	user := User{ID: id, Role: role}
+	if err := a.loadUserProfile(&user); err != nil {
+		return nil
+	}
+
+	var departmentCode sql.NullString
+	switch role {
+	case "teacher":
+		_ = a.db.QueryRow(`SELECT department_code FROM teachers WHERE id = ?`, id).Scan(&departmentCode)
+	case "student", "working_student":
+		_ = a.db.QueryRow(`SELECT department_code FROM students WHERE id = ?`, id).Scan(&departmentCode)
+	}
+
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	values := auditValues{
+		"first_name": deref(user.FirstName), "middle_name": deref(user.MiddleName), "last_name": deref(user.LastName),
+		"email": deref(user.Email), "contact_number": deref(user.ContactNumber), "department_code": departmentCode.String,
+	}
+	switch role {
+	case "admin", "teacher":
+		values["employee_id"] = deref(user.EmployeeID)
+	default:
+		values["student_id"] = deref(user.StudentID)
+	}
+	return values
 }
 
 // deleteUserByID removes a user row. Used only for automated system flows (not admin UI).
@@ -419,12 +514,18 @@ func (a *App) deleteUserByID(id int) error {
 
 // DeleteUser is bound for Wails; administrators cannot permanently delete accounts from the app.
 func (a *App) DeleteUser(id int) error {
+	if _, err := a.requireRole("DeleteUser"); err != nil {
+		return err
+	}
 	_ = id
 	return fmt.Errorf("manual account deletion is not supported")
 }
 
 // DeleteExpiredDeactivatedUsers is bound for Wails; bulk deletion from the admin UI is not supported.
 func (a *App) DeleteExpiredDeactivatedUsers(days int) (int, error) {
+	if _, err := a.requireRole("DeleteExpiredDeactivatedUsers"); err != nil {
+		return 0, err
+	}
 	_ = days
 	return 0, fmt.Errorf("bulk account deletion is not supported")
 }
@@ -432,12 +533,16 @@ func (a *App) DeleteExpiredDeactivatedUsers(days int) (int, error) {
 // DeactivateTeacher deactivates a teacher account instead of immediate deletion.
 // The account remains in the system (inactive) for record purposes and can be reactivated.
 func (a *App) DeactivateTeacher(id int) error {
+	session, err := a.requireRole("DeactivateTeacher")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
 
-	var userType string
-	err := a.db.QueryRow(`SELECT user_type FROM users WHERE id = ?`, id).Scan(&userType)
+	var userType, previousStatus string
+	err = a.db.QueryRow(`SELECT user_type, account_status FROM users WHERE id = ?`, id).Scan(&userType, &previousStatus)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("user not found")
@@ -468,17 +573,23 @@ func (a *App) DeactivateTeacher(id int) error {
 		return fmt.Errorf("teacher account is already deactivated or not eligible for deactivation")
 	}
 
+	a.audit(session, "deactivate_teacher", "user", id,
+		auditValues{"account_status": previousStatus}, auditValues{"account_status": "deactivated"})
 	return nil
 }
 
 // ArchiveUser archives a user account (teachers cannot be archived)
 func (a *App) ArchiveUser(id int) error {
+	session, err := a.requireRole("ArchiveUser")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
 
-	var userType string
-	err := a.db.QueryRow(`SELECT user_type FROM users WHERE id = ?`, id).Scan(&userType)
+	var userType, previousStatus string
+	err = a.db.QueryRow(`SELECT user_type, account_status FROM users WHERE id = ?`, id).Scan(&userType, &previousStatus)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("user not found")
@@ -501,20 +612,23 @@ func (a *App) ArchiveUser(id int) error {
 		return err
 	}
 
+	// SQL Server ODBC driver can return RowsAffected errors even when UPDATE succeeded
 	rows, rowsErr := result.RowsAffected()
-	if rowsErr != nil {
-		// SQL Server ODBC driver can return RowsAffected errors even when UPDATE succeeded
-		return nil
-	}
-	if rows == 0 {
+	if rowsErr == nil && rows == 0 {
 		return fmt.Errorf("user is already archived or not eligible for archiving")
 	}
 
+	a.audit(session, "archive_user", "user", id,
+		auditValues{"account_status": previousStatus}, auditValues{"account_status": "archived"})
 	return nil
 }
 
 // UnarchiveUser restores an archived user account back to active state
 func (a *App) UnarchiveUser(id int) error {
+	session, err := a.requireRole("UnarchiveUser")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -530,20 +644,22 @@ func (a *App) UnarchiveUser(id int) error {
 		return err
 	}
 
+	// SQL Server ODBC driver can return RowsAffected errors even when UPDATE succeeded
 	rows, rowsErr := result.RowsAffected()
-	if rowsErr != nil {
-		// SQL Server ODBC driver can return RowsAffected errors even when UPDATE succeeded
-		return nil
-	}
-	if rows == 0 {
+	if rowsErr == nil && rows == 0 {
 		return fmt.Errorf("user is not archived")
 	}
 
+	a.audit(session, "unarchive_user", "user", id,
+		auditValues{"account_status": "archived"}, auditValues{"account_status": "active"})
 	return nil
 }
 
 // GetArchivedUsers returns manually archived accounts for admin review.
 func (a *App) GetArchivedUsers() ([]User, error) {
+	if _, err := a.requireRole("GetArchivedUsers"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -690,24 +806,24 @@ func (a *App) validateActiveDepartmentCode(departmentCode string) (string, error
 }
 
 // insertRoleSpecificProfile inserts user profile into role-specific table
-func (a *App) insertRoleSpecificProfile(userID int64, role, firstName, middleName, lastName, employeeID, studentID, email, contactNumber, departmentCode string) error {
+func (a *App) insertRoleSpecificProfile(exec dbExecutor, userID int64, role, firstName, middleName, lastName, employeeID, studentID, email, contactNumber, departmentCode string) error {
 	var query string
 	var err error
 
 	switch role {
 	case "admin":
 		query = `INSERT INTO admins (id, admin_id, first_name, middle_name, last_name, email) VALUES (?, ?, ?, ?, ?, ?)`
-		_, err = a.db.Exec(query, userID, nullString(employeeID), firstName, nullString(middleName), lastName, nullString(email))
+		_, err = exec.Exec(query, userID, nullString(employeeID), firstName, nullString(middleName), lastName, nullString(email))
 	case "teacher":
 		query = `INSERT INTO teachers (id, teacher_id, first_name, middle_name, last_name, email, contact_number, department_code) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-		_, err = a.db.Exec(query, userID, nullString(employeeID), firstName, nullString(middleName), lastName, nullString(email), nullString(contactNumber), nullString(departmentCode))
+		_, err = exec.Exec(query, userID, nullString(employeeID), firstName, nullString(middleName), lastName, nullString(email), nullString(contactNumber), nullString(departmentCode))
 	case "student":
 		query = `INSERT INTO students (id, student_id, first_name, middle_name, last_name, email, contact_number, department_code, is_working_student) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-		_, err = a.db.Exec(query, userID, nullString(studentID), firstName, nullString(middleName), lastName, nullString(email), nullString(contactNumber), nullString(departmentCode), 0)
+		_, err = exec.Exec(query, userID, nullString(studentID), firstName, nullString(middleName), lastName, nullString(email), nullString(contactNumber), nullString(departmentCode), 0)
 	case "working_student":
 		query = `INSERT INTO students (id, student_id, first_name, middle_name, last_name, email, contact_number, department_code, is_working_student) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		log.Printf("Inserting working student - id: %d, student_id: %s, name: %s %s, email: %s", userID, studentID, firstName, lastName, email)
-		_, err = a.db.Exec(query, userID, nullString(studentID), firstName, nullString(middleName), lastName, nullString(email), nullString(contactNumber), nullString(departmentCode), 1)
+		_, err = exec.Exec(query, userID, nullString(studentID), firstName, nullString(middleName), lastName, nullString(email), nullString(contactNumber), nullString(departmentCode), 1)
 	}
 
 	if err != nil {
@@ -808,11 +924,15 @@ type ArchivedStudent struct {
 // ArchiveStudent archives a graduated student account
 // The account will be scheduled for deletion after 360 days
 func (a *App) ArchiveStudent(studentUserID int) error {
+	session, err := a.requireRole("ArchiveStudent")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
 
-	now := time.Now()
+	now := a.now()
 	deletionDate := now.AddDate(0, 0, 360) // 360 days from now
 
 	query := `
@@ -851,11 +971,17 @@ func (a *App) ArchiveStudent(studentUserID int) error {
 	log.Printf("Student archived: user_id=%d, deletion_scheduled=%s",
 		studentUserID, deletionDate.Format("2006-01-02"))
 
+	a.audit(session, "archive_student", "user", studentUserID, nil,
+		auditValues{"archived_at": formatTime(now), "deletion_scheduled_at": formatTime(deletionDate)})
 	return nil
 }
 
 // UnarchiveStudent restores an archived student account
 func (a *App) UnarchiveStudent(studentUserID int) error {
+	session, err := a.requireRole("UnarchiveStudent")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
@@ -894,11 +1020,15 @@ func (a *App) UnarchiveStudent(studentUserID int) error {
 	}
 
 	log.Printf("Student unarchived: user_id=%d", studentUserID)
+	a.audit(session, "unarchive_student", "user", studentUserID, auditValues{"archived": true}, auditValues{"archived": false})
 	return nil
 }
 
 // GetArchivedStudents returns all archived students with deletion schedule info
 func (a *App) GetArchivedStudents() ([]ArchivedStudent, error) {
+	if _, err := a.requireRole("GetArchivedStudents"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -959,6 +1089,9 @@ func (a *App) GetArchivedStudents() ([]ArchivedStudent, error) {
 // DeleteExpiredStudents permanently deletes student accounts that have passed their deletion date
 // This should be called periodically (e.g., daily cron job)
 func (a *App) DeleteExpiredStudents() (int, error) {
+	if _, err := a.requireRole("DeleteExpiredStudents"); err != nil {
+		return 0, err
+	}
 	if err := a.checkDB(); err != nil {
 		return 0, err
 	}
@@ -1021,82 +1154,6 @@ func (a *App) DeleteExpiredStudents() (int, error) {
 // ACCOUNT ACTIVITY STATUS MANAGEMENT
 // ==============================================================================
 
-// ensureActivityTrackingColumns ensures the archived_at, deactivated_at and deleted_at columns
-// exist in the users table. Called at startup as a lightweight auto-migration.
-func (a *App) ensureActivityTrackingColumns() error {
-	var exists int
-
-	err := a.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM INFORMATION_SCHEMA.COLUMNS
-		WHERE TABLE_SCHEMA = DATABASE()
-		  AND TABLE_NAME = 'users'
-		  AND COLUMN_NAME = 'archived_at'
-	`).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check archived_at column: %w", err)
-	}
-	if exists == 0 {
-		if _, err := a.db.Exec(`ALTER TABLE users ADD COLUMN archived_at DATETIME NULL`); err != nil {
-			return fmt.Errorf("failed to ensure archived_at column: %w", err)
-		}
-	}
-
-	err = a.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM INFORMATION_SCHEMA.COLUMNS
-		WHERE TABLE_SCHEMA = DATABASE()
-		  AND TABLE_NAME = 'users'
-		  AND COLUMN_NAME = 'deactivated_at'
-	`).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check deactivated_at column: %w", err)
-	}
-	if exists == 0 {
-		if _, err := a.db.Exec(`ALTER TABLE users ADD COLUMN deactivated_at DATETIME NULL`); err != nil {
-			return fmt.Errorf("failed to ensure deactivated_at column: %w", err)
-		}
-	}
-
-	err = a.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM INFORMATION_SCHEMA.COLUMNS
-		WHERE TABLE_SCHEMA = DATABASE()
-		  AND TABLE_NAME = 'users'
-		  AND COLUMN_NAME = 'deleted_at'
-	`).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check deleted_at column: %w", err)
-	}
-	if exists == 0 {
-		if _, err := a.db.Exec(`ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL`); err != nil {
-			return fmt.Errorf("failed to ensure deleted_at column: %w", err)
-		}
-	}
-
-	return nil
-}
-
-// ensureAccountStatusConstraint ensures users.account_status supports full lifecycle states.
-func (a *App) ensureAccountStatusConstraint() error {
-	_, err := a.db.Exec(`
-		UPDATE users
-		SET archived_at = updated_at
-		WHERE account_status = 'archived'
-		  AND archived_at IS NULL;
-
-		UPDATE users
-		SET account_status = 'deleted', updated_at = NOW()
-		WHERE deleted_at IS NOT NULL
-		  AND account_status <> 'deleted';
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to ensure account status lifecycle fields: %w", err)
-	}
-
-	return nil
-}
-
 // GetUsersByActivityStatus returns users filtered by activity status with last-login info.
 //
 // statusFilter values:
@@ -1106,6 +1163,9 @@ func (a *App) ensureAccountStatusConstraint() error {
 //	"deactivated" – auto-deactivated after configured inactivity threshold (default: 183 days)
 //	"deleted"     – soft-deleted (account_status = 'deleted')
 func (a *App) GetUsersByActivityStatus(statusFilter string) ([]User, error) {
+	if _, err := a.requireRole("GetUsersByActivityStatus"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -1315,6 +1375,10 @@ func (a *App) scanUsersWithActivity(rows *sql.Rows) ([]User, error) {
 // Returns a map with keys "deactivated" and "deleted" reporting counts,
 // and an error if either step fails.
 func (a *App) RunInactivityCheck() (map[string]int, error) {
+	session, err := a.requireRole("RunInactivityCheck")
+	if err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
@@ -1322,12 +1386,14 @@ func (a *App) RunInactivityCheck() (map[string]int, error) {
 	inactivityDays := LoadInactivityDeactivationDays()
 	deactivatedDeletionDays := LoadDeactivatedDeletionDays()
 
+	now := a.now()
+
 	// Step 1: auto-deactivate accounts inactive for configured inactivity days
 	deactivateResult, err := a.db.Exec(`
 		UPDATE users
 		SET account_status = 'deactivated',
-		    deactivated_at = NOW(),
-		    updated_at     = NOW()
+		    deactivated_at = ?,
+		    updated_at     = ?
 		WHERE id IN (
 			SELECT u.id
 			FROM users u
@@ -1340,11 +1406,11 @@ func (a *App) RunInactivityCheck() (map[string]int, error) {
 			  AND u.account_status = 'active'
 			  AND u.deleted_at  IS NULL
 			  AND (
-			      (ll.last_login_at IS NOT NULL AND TIMESTAMPDIFF(DAY, ll.last_login_at, NOW()) >= ?)
-			   OR (ll.last_login_at IS NULL      AND TIMESTAMPDIFF(DAY, u.created_at, NOW()) >= ?)
+			      (ll.last_login_at IS NOT NULL AND TIMESTAMPDIFF(DAY, ll.last_login_at, ?) >= ?)
+			   OR (ll.last_login_at IS NULL      AND TIMESTAMPDIFF(DAY, u.created_at, ?) >= ?)
 			  )
 		)
-	`, inactivityDays, inactivityDays)
+	`, now, now, now, inactivityDays, now, inactivityDays)
 	if err != nil {
 		return nil, fmt.Errorf("failed to deactivate inactive accounts: %w", err)
 	}
@@ -1354,13 +1420,13 @@ func (a *App) RunInactivityCheck() (map[string]int, error) {
 	softDeleteResult, err := a.db.Exec(`
 		UPDATE users
 		SET account_status = 'deleted',
-		    deleted_at  = NOW(),
-		    updated_at  = NOW()
+		    deleted_at  = ?,
+		    updated_at  = ?
 		WHERE account_status = 'deactivated'
 		  AND deactivated_at IS NOT NULL
 		  AND deleted_at     IS NULL
-		  AND TIMESTAMPDIFF(DAY, deactivated_at, NOW()) >= ?
-	`, deactivatedDeletionDays)
+		  AND TIMESTAMPDIFF(DAY, deactivated_at, ?) >= ?
+	`, now, now, now, deactivatedDeletionDays)
 	if err != nil {
 		return nil, fmt.Errorf("failed to soft-delete expired accounts: %w", err)
 	}
@@ -1368,6 +1434,10 @@ func (a *App) RunInactivityCheck() (map[string]int, error) {
 
 	log.Printf("Inactivity check complete (deactivate=%d day(s), delete=%d day(s)): %d account(s) deactivated, %d account(s) flagged for deletion",
 		inactivityDays, deactivatedDeletionDays, deactivated, softDeleted)
+	a.audit(session, "run_inactivity_check", "user", "", nil, auditValues{
+		"inactivity_days": inactivityDays, "deletion_days": deactivatedDeletionDays,
+		"deactivated": deactivated, "deleted": softDeleted,
+	})
 
 	return map[string]int{
 		"deactivated": int(deactivated),
@@ -1378,10 +1448,19 @@ func (a *App) RunInactivityCheck() (map[string]int, error) {
 // ReactivateUser restores a deactivated (or soft-deleted) account back to active.
 // Only admins should be allowed to call this.
 func (a *App) ReactivateUser(id int) error {
+	session, err := a.requireRole("ReactivateUser")
+	if err != nil {
+		return err
+	}
 	if err := a.checkDB(); err != nil {
 		return err
 	}
 
+	var previousStatus string
+	if err := a.db.QueryRow(`SELECT account_status FROM users WHERE id = ?`, id).Scan(&previousStatus); err == sql.ErrNoRows {
+		return fmt.Errorf("user not found or already active")
+	}
+
 	result, err := a.db.Exec(`
 		UPDATE users
 		SET account_status = 'active',
@@ -1404,11 +1483,16 @@ func (a *App) ReactivateUser(id int) error {
 	}
 
 	log.Printf("Admin reactivated user with ID: %d", id)
+	a.audit(session, "reactivate_user", "user", id,
+		auditValues{"account_status": previousStatus}, auditValues{"account_status": "active"})
 	return nil
 }
 
 // GetActiveStudentsForArchiving returns active students that can be archived
 func (a *App) GetActiveStudentsForArchiving() ([]User, error) {
+	if _, err := a.requireRole("GetActiveStudentsForArchiving"); err != nil {
+		return nil, err
+	}
 	if err := a.checkDB(); err != nil {
 		return nil, err
 	}
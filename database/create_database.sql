@@ -0,0 +1,7 @@
+-- Creates the empty logbookdb database.
+-- Tables are created by the app itself on first launch (versioned schema migrations).
+SET NAMES utf8mb4;
+
+CREATE DATABASE IF NOT EXISTS logbookdb
+    CHARACTER SET utf8mb4
+    COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,159 @@
+import { useCallback, useEffect, useState } from 'react';
+import Modal, { MODAL_BODY_MIN_HEIGHT_CLASS } from './Modal';
+import Button from './Button';
+import LoadingDots from './LoadingDots';
+import { useAppUi } from '../contexts/AppUiContext';
+import { ExcuseRequest, excuseStatusBadge, formatExcuseDates } from './StudentExcuseRequestsModal';
+
+interface ClassExcuseRequestsModalProps {
+  isOpen: boolean;
+  onClose: () => void;
+  classId: number;
+  teacherUserId?: number;
+}
+
+function ClassExcuseRequestsModal({ isOpen, onClose, classId, teacherUserId }: ClassExcuseRequestsModalProps) {
+  const { toast } = useAppUi();
+  const [requests, setRequests] = useState<ExcuseRequest[]>([]);
+  const [statusFilter, setStatusFilter] = useState('pending');
+  const [loading, setLoading] = useState(false);
+  const [reviewing, setReviewing] = useState<number | null>(null);
+  const [notes, setNotes] = useState<Record<number, string>>({});
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      const data = await (window as any).go.backend.App.GetClassExcuseRequests(classId, statusFilter === 'all' ? '' : statusFilter);
+      setRequests(data || []);
+    } catch (error) {
+      console.error('Failed to load excuse requests:', error);
+      toast('Unable to load excuse requests.', 'error');
+    } finally {
+      setLoading(false);
+    }
+  }, [classId, statusFilter, toast]);
+
+  useEffect(() => {
+    if (isOpen) load();
+  }, [isOpen, load]);
+
+  const handleReview = async (request: ExcuseRequest, approve: boolean) => {
+    if (!teacherUserId) return;
+    const note = (notes[request.id] || '').trim();
+    if (!approve && !note) {
+      toast('Add a note explaining why the request is denied.', 'error');
+      return;
+    }
+    setReviewing(request.id);
+    try {
+      await (window as any).go.backend.App.ReviewExcuseRequest(request.id, teacherUserId, approve, note);
+      toast(approve ? 'Excuse approved; the student\'s attendance is marked Excused.' : 'Excuse denied.', 'success');
+      await load();
+    } catch (error: any) {
+      console.error('Failed to review excuse request:', error);
+      toast(error?.message || 'Failed to review excuse request.', 'error');
+    } finally {
+      setReviewing(null);
+    }
+  };
+
+  const handleViewDocument = async (request: ExcuseRequest) => {
+    try {
+      const document: string = await (window as any).go.backend.App.GetExcuseRequestDocument(request.id);
+      if (!document) {
+        toast('This request has no document.', 'error');
+        return;
+      }
+      const link = window.document.createElement('a');
+      link.href = document;
+      link.download = request.document_name || 'document';
+      link.click();
+    } catch (error: any) {
+      console.error('Failed to open excuse document:', error);
+      toast(error?.message || 'Failed to open the document.', 'error');
+    }
+  };
+
+  return (
+    <Modal
+      isOpen={isOpen}
+      onClose={onClose}
+      title="Excuse Requests"
+      size="xl"
+      showVariantIcon={false}
+      contentMinHeightClassName={MODAL_BODY_MIN_HEIGHT_CLASS}
+    >
+      <div className="space-y-3">
+        <div className="flex items-center justify-between">
+          <div className="text-sm text-gray-600">Approving a request marks the student Excused for the covered sessions.</div>
+          <select
+            value={statusFilter}
+            onChange={e => setStatusFilter(e.target.value)}
+            className="border border-gray-300 rounded-lg px-3 py-2 text-sm focus:outline-none focus:ring-2 focus:ring-primary-500"
+          >
+            <option value="pending">Pending</option>
+            <option value="approved">Approved</option>
+            <option value="denied">Denied</option>
+            <option value="cancelled">Cancelled</option>
+            <option value="all">All</option>
+          </select>
+        </div>
+
+        {loading ? (
+          <div className="flex items-center justify-center h-24">
+            <LoadingDots className="justify-center" dotClassName="h-2.5 w-2.5" />
+          </div>
+        ) : requests.length === 0 ? (
+          <div className="bg-white border border-gray-100 rounded-lg p-6 text-center">
+            <p className="text-sm text-gray-500">No excuse requests.</p>
+          </div>
+        ) : (
+          <div className="bg-white border border-gray-100 rounded-lg divide-y divide-gray-100 overflow-hidden">
+            {requests.map(request => (
+              <div key={request.id} className="px-4 py-3 space-y-2">
+                <div className="flex items-start justify-between gap-3">
+                  <div className="min-w-0 flex-1">
+                    <p className="text-sm font-medium text-gray-900">{request.student_name} <span className="text-gray-500 font-normal">({request.student_code})</span></p>
+                    <p className="text-xs text-gray-500">
+                      {formatExcuseDates(request)}{request.session_name ? ` - ${request.session_name}` : ''} - submitted {request.submitted_at}
+                    </p>
+                    <p className="text-xs text-gray-700 mt-1 whitespace-pre-wrap">{request.reason}</p>
+                    {request.has_document && (
+                      <button onClick={() => handleViewDocument(request)} className="text-xs text-primary-600 hover:underline mt-1">
+                        {request.document_name || 'View document'}
+                      </button>
+                    )}
+                    {request.review_note && (
+                      <p className="text-xs text-gray-500 mt-1">Note: {request.review_note}</p>
+                    )}
+                  </div>
+                  <div className="flex-shrink-0">{excuseStatusBadge(request.status)}</div>
+                </div>
+                {request.status === 'pending' && (
+                  <div className="flex items-center gap-2">
+                    <input
+                      type="text"
+                      placeholder="Note to the student (required to deny)"
+                      maxLength={500}
+                      value={notes[request.id] || ''}
+                      onChange={e => setNotes(prev => ({ ...prev, [request.id]: e.target.value }))}
+                      className="flex-1 border border-gray-300 rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-2 focus:ring-primary-500"
+                    />
+                    <Button onClick={() => handleReview(request, false)} variant="outline" size="sm" disabled={reviewing === request.id}>
+                      Deny
+                    </Button>
+                    <Button onClick={() => handleReview(request, true)} variant="primary" size="sm" disabled={reviewing === request.id}>
+                      Approve
+                    </Button>
+                  </div>
+                )}
+              </div>
+            ))}
+          </div>
+        )}
+      </div>
+    </Modal>
+  );
+}
+
+export default ClassExcuseRequestsModal;
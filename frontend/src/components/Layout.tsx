@@ -1,9 +1,11 @@
 import React, { useState, useEffect, useMemo, useRef } from 'react';
 import { Link, useNavigate } from 'react-router-dom';
-import { useAuth } from '../contexts/AuthContext';
+import { IDLE_WARNING_EVENT, useAuth } from '../contexts/AuthContext';
 import { useNotifications } from '../contexts/NotificationContext';
 import { useAppUi } from '../contexts/AppUiContext';
 import { UpdateUserPhoto, ChangePassword, SaveEquipmentFeedback, UpdateUser, GetPendingFeedback, GetConfirmedFeedback, GetPendingRegistrations } from '../../wailsjs/go/backend/App';
+import { EventsOn } from '../../wailsjs/runtime/runtime';
+import { formatBackendError } from '../utils/actionErrors';
 import { compressImage, isImageFile, isValidFileSize } from '../utils/imageUtils';
 import {
   User,
@@ -375,6 +377,9 @@ function Layout({ children, navigationItems, title, subtitle }: LayoutProps) {
       setOldPassword('');
       setNewPassword('');
       setConfirmPassword('');
+      if (user.must_change_password) {
+        updateUser({ must_change_password: false, password_expired: false });
+      }
 
       setTimeout(() => {
         setShowAccountModal(false);
@@ -382,7 +387,7 @@ function Layout({ children, navigationItems, title, subtitle }: LayoutProps) {
       }, 2000);
     } catch (error) {
       console.error('Failed to change password:', error);
-      setPasswordError('Failed to change password. Please check your old password.');
+      setPasswordError(formatBackendError(error, 'Failed to change password. Please check your old password.'));
     }
   };
 
@@ -399,6 +404,24 @@ function Layout({ children, navigationItems, title, subtitle }: LayoutProps) {
     }
   }, [user]);
 
+  // Admin-chosen and expired passwords must be replaced before the app can be used.
+  useEffect(() => {
+    if (user?.must_change_password) {
+      setActiveTab('password');
+      setShowAccountModal(true);
+    }
+  }, [user?.must_change_password]);
+
+  // Lock-mode stations warn before logging an idle user out; any input cancels it.
+  useEffect(() => {
+    if (!user || !window.go?.backend?.App) return;
+    return EventsOn(IDLE_WARNING_EVENT, (warning: { user_id: number; seconds_remaining: number }) => {
+      if (warning.user_id === user.id) {
+        toast(`You will be logged out in ${warning.seconds_remaining} seconds because this PC has been idle. Move the mouse or press a key to stay logged in.`, 'warning');
+      }
+    });
+  }, [toast, user]);
+
   const handleEditProfile = () => {
     setEditingProfile(true);
     setProfileError('');
@@ -477,6 +500,7 @@ function Layout({ children, navigationItems, title, subtitle }: LayoutProps) {
   };
 
   const handleCloseAccountModal = () => {
+    if (user?.must_change_password) return;
     setShowAccountModal(false);
     setActiveTab('profile');
     setPasswordError('');
@@ -1343,8 +1367,24 @@ function Layout({ children, navigationItems, title, subtitle }: LayoutProps) {
                     </div>
                   </form>
                 </div>
+              ) : user?.auth_source === 'ldap' ? (
+                <div className="bg-primary-50 border-l-4 border-primary-500 p-4 rounded-xl">
+                  <p className="text-sm text-primary-700">
+                    Your account signs in with your school directory password. Change it through your school's account portal.
+                  </p>
+                </div>
               ) : (
                 <form onSubmit={handlePasswordChange} className="space-y-6" noValidate>
+                  {user?.must_change_password && (
+                    <div className="bg-warning-50 border-l-4 border-warning-500 p-4 rounded-xl">
+                      <p className="text-sm text-warning-700">
+                        {user.password_expired
+                          ? 'Your password has expired. Choose a new password to continue.'
+                          : 'Your password was set by an administrator. Choose your own password to continue.'}
+                      </p>
+                    </div>
+                  )}
+
                   {passwordError && (
                     <div className="bg-danger-50 border-l-4 border-danger-500 p-4 rounded-xl">
                       <p className="text-sm text-danger-700">{passwordError}</p>
@@ -0,0 +1,273 @@
+import { useCallback, useEffect, useState } from 'react';
+import Modal, { MODAL_BODY_MIN_HEIGHT_CLASS } from './Modal';
+import Button from './Button';
+import LoadingDots from './LoadingDots';
+import { useAppUi } from '../contexts/AppUiContext';
+import { GetStudentClasses } from '../../wailsjs/go/backend/App';
+
+const MAX_DOCUMENT_BYTES = 5 * 1024 * 1024;
+
+export interface ExcuseRequest {
+  id: number;
+  student_user_id: number;
+  student_code: string;
+  student_name: string;
+  class_id: number;
+  subject_code: string;
+  subject_name: string;
+  session_id?: number;
+  session_name?: string;
+  start_date: string;
+  end_date: string;
+  reason: string;
+  document_name?: string;
+  has_document: boolean;
+  status: string;
+  submitted_at: string;
+  reviewed_by_name?: string;
+  reviewed_at?: string;
+  review_note?: string;
+}
+
+interface ClassOption {
+  class_id: number;
+  subject_code: string;
+  subject_name: string;
+  section?: string;
+}
+
+interface StudentExcuseRequestsModalProps {
+  isOpen: boolean;
+  onClose: () => void;
+  userId?: number;
+}
+
+export const excuseStatusBadge = (status: string) => {
+  const classes: Record<string, string> = {
+    pending: 'bg-yellow-100 text-yellow-800',
+    approved: 'bg-green-100 text-green-800',
+    denied: 'bg-red-100 text-red-800',
+    cancelled: 'bg-gray-100 text-gray-700',
+  };
+  const label = status ? status.charAt(0).toUpperCase() + status.slice(1) : '-';
+  return (
+    <span className={`inline-flex items-center px-2 py-0.5 rounded text-xs font-medium ${classes[status] || classes.cancelled}`}>
+      {label}
+    </span>
+  );
+};
+
+export const formatExcuseDates = (request: ExcuseRequest) =>
+  request.start_date === request.end_date ? request.start_date : `${request.start_date} to ${request.end_date}`;
+
+function StudentExcuseRequestsModal({ isOpen, onClose, userId }: StudentExcuseRequestsModalProps) {
+  const { toast } = useAppUi();
+  const [requests, setRequests] = useState<ExcuseRequest[]>([]);
+  const [classes, setClasses] = useState<ClassOption[]>([]);
+  const [loading, setLoading] = useState(false);
+  const [submitting, setSubmitting] = useState(false);
+  const [classId, setClassId] = useState('');
+  const [startDate, setStartDate] = useState('');
+  const [endDate, setEndDate] = useState('');
+  const [reason, setReason] = useState('');
+  const [documentName, setDocumentName] = useState('');
+  const [documentData, setDocumentData] = useState('');
+
+  const load = useCallback(async () => {
+    if (!userId) return;
+    setLoading(true);
+    try {
+      const [requestData, classData] = await Promise.all([
+        (window as any).go.backend.App.GetStudentExcuseRequests(userId),
+        GetStudentClasses(userId),
+      ]);
+      setRequests(requestData || []);
+      setClasses((classData || []).filter((cls: any) => !cls.is_archived));
+    } catch (error) {
+      console.error('Failed to load excuse requests:', error);
+      toast('Unable to load excuse requests.', 'error');
+    } finally {
+      setLoading(false);
+    }
+  }, [toast, userId]);
+
+  useEffect(() => {
+    if (isOpen) load();
+  }, [isOpen, load]);
+
+  const resetForm = () => {
+    setClassId('');
+    setStartDate('');
+    setEndDate('');
+    setReason('');
+    setDocumentName('');
+    setDocumentData('');
+  };
+
+  const handleDocument = (file?: File) => {
+    if (!file) {
+      setDocumentName('');
+      setDocumentData('');
+      return;
+    }
+    if (file.size > MAX_DOCUMENT_BYTES) {
+      toast('The document must be 5MB or smaller.', 'error');
+      return;
+    }
+    const reader = new FileReader();
+    reader.onload = () => {
+      setDocumentName(file.name);
+      setDocumentData(String(reader.result || ''));
+    };
+    reader.onerror = () => toast('Failed to read the document.', 'error');
+    reader.readAsDataURL(file);
+  };
+
+  const handleSubmit = async () => {
+    if (!userId) return;
+    setSubmitting(true);
+    try {
+      await (window as any).go.backend.App.SubmitExcuseRequest(userId, {
+        class_id: Number(classId),
+        session_id: 0,
+        start_date: startDate,
+        end_date: endDate || startDate,
+        reason,
+        document_name: documentName,
+        document_data: documentData,
+      });
+      toast('Excuse request submitted to your teacher.', 'success');
+      resetForm();
+      await load();
+    } catch (error: any) {
+      console.error('Failed to submit excuse request:', error);
+      toast(error?.message || 'Failed to submit excuse request.', 'error');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleCancel = async (requestId: number) => {
+    if (!userId) return;
+    try {
+      await (window as any).go.backend.App.CancelExcuseRequest(requestId, userId);
+      toast('Excuse request cancelled.', 'success');
+      await load();
+    } catch (error: any) {
+      console.error('Failed to cancel excuse request:', error);
+      toast(error?.message || 'Failed to cancel excuse request.', 'error');
+    }
+  };
+
+  const canSubmit = !!classId && !!startDate && reason.trim().length > 0 && !submitting;
+
+  return (
+    <Modal
+      isOpen={isOpen}
+      onClose={onClose}
+      title="Excuse Requests"
+      size="xl"
+      showVariantIcon={false}
+      contentMinHeightClassName={MODAL_BODY_MIN_HEIGHT_CLASS}
+    >
+      <div className="space-y-5">
+        <div className="border border-gray-200 rounded-lg p-4 space-y-3">
+          <p className="text-sm text-gray-600">Ask to be excused from a class for one day or a leave of up to 31 days.</p>
+          <div className="grid grid-cols-1 sm:grid-cols-3 gap-3">
+            <div>
+              <label className="block text-xs font-medium text-gray-600 mb-1">Class</label>
+              <select
+                value={classId}
+                onChange={e => setClassId(e.target.value)}
+                className="w-full border border-gray-300 rounded-lg px-3 py-2 text-sm focus:outline-none focus:ring-2 focus:ring-primary-500"
+              >
+                <option value="">Select a class</option>
+                {classes.map(cls => (
+                  <option key={cls.class_id} value={String(cls.class_id)}>
+                    {cls.subject_code} - {cls.subject_name}{cls.section ? ` (${cls.section})` : ''}
+                  </option>
+                ))}
+              </select>
+            </div>
+            <div>
+              <label className="block text-xs font-medium text-gray-600 mb-1">From</label>
+              <input
+                type="date"
+                value={startDate}
+                onChange={e => setStartDate(e.target.value)}
+                className="w-full border border-gray-300 rounded-lg px-3 py-2 text-sm focus:outline-none focus:ring-2 focus:ring-primary-500"
+              />
+            </div>
+            <div>
+              <label className="block text-xs font-medium text-gray-600 mb-1">To (optional)</label>
+              <input
+                type="date"
+                value={endDate}
+                min={startDate || undefined}
+                onChange={e => setEndDate(e.target.value)}
+                className="w-full border border-gray-300 rounded-lg px-3 py-2 text-sm focus:outline-none focus:ring-2 focus:ring-primary-500"
+              />
+            </div>
+          </div>
+          <div>
+            <label className="block text-xs font-medium text-gray-600 mb-1">Reason</label>
+            <textarea
+              value={reason}
+              maxLength={1000}
+              rows={3}
+              onChange={e => setReason(e.target.value)}
+              className="w-full border border-gray-300 rounded-lg px-3 py-2 text-sm focus:outline-none focus:ring-2 focus:ring-primary-500"
+            />
+          </div>
+          <div className="flex items-center justify-between gap-3">
+            <div className="text-xs text-gray-600">
+              <label className="block font-medium mb-1">Supporting document (image or PDF, optional)</label>
+              <input type="file" accept="image/*,application/pdf" onChange={e => handleDocument(e.target.files?.[0])} />
+            </div>
+            <Button onClick={handleSubmit} variant="primary" size="sm" disabled={!canSubmit}>
+              {submitting ? 'Submitting...' : 'Submit Request'}
+            </Button>
+          </div>
+        </div>
+
+        {loading ? (
+          <div className="flex items-center justify-center h-24">
+            <LoadingDots className="justify-center" dotClassName="h-2.5 w-2.5" />
+          </div>
+        ) : requests.length === 0 ? (
+          <div className="bg-white border border-gray-100 rounded-lg p-6 text-center">
+            <p className="text-sm text-gray-500">No excuse requests yet.</p>
+          </div>
+        ) : (
+          <div className="bg-white border border-gray-100 rounded-lg divide-y divide-gray-100 overflow-hidden">
+            {requests.map(request => (
+              <div key={request.id} className="flex items-start justify-between px-4 py-3 gap-3">
+                <div className="min-w-0 flex-1">
+                  <p className="text-sm font-medium text-gray-900 truncate">{request.subject_code} - {request.subject_name}</p>
+                  <p className="text-xs text-gray-500">
+                    {formatExcuseDates(request)}{request.session_name ? ` - ${request.session_name}` : ''}
+                    {request.document_name ? ` - ${request.document_name}` : ''}
+                  </p>
+                  <p className="text-xs text-gray-600 mt-1 whitespace-pre-wrap">{request.reason}</p>
+                  {request.review_note && (
+                    <p className="text-xs text-gray-500 mt-1">Note from {request.reviewed_by_name || 'teacher'}: {request.review_note}</p>
+                  )}
+                </div>
+                <div className="flex-shrink-0 flex flex-col items-end gap-2">
+                  {excuseStatusBadge(request.status)}
+                  {request.status === 'pending' && (
+                    <button onClick={() => handleCancel(request.id)} className="text-xs text-gray-500 hover:text-red-600">
+                      Cancel
+                    </button>
+                  )}
+                </div>
+              </div>
+            ))}
+          </div>
+        )}
+      </div>
+    </Modal>
+  );
+}
+
+export default StudentExcuseRequestsModal;
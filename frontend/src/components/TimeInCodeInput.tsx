@@ -0,0 +1,39 @@
+import React from 'react';
+
+/* ===== TIME-IN CODE INPUT ===== */
+// The rotating code shown on the teacher's screen; StudentTimeIn requires it.
+export const TIME_IN_CODE_LENGTH = 6;
+
+interface TimeInCodeInputProps {
+  value: string;
+  onChange: (value: string) => void;
+  onSubmit?: () => void;
+  disabled?: boolean;
+  className?: string;
+}
+
+export const TimeInCodeInput: React.FC<TimeInCodeInputProps> = ({
+  value,
+  onChange,
+  onSubmit,
+  disabled = false,
+  className = ''
+}) => (
+  <input
+    type="text"
+    inputMode="numeric"
+    autoComplete="off"
+    placeholder="Code"
+    aria-label="Time-in code from the teacher's screen"
+    maxLength={TIME_IN_CODE_LENGTH}
+    value={value}
+    disabled={disabled}
+    onChange={(e) => onChange(e.target.value.replace(/\D/g, '').slice(0, TIME_IN_CODE_LENGTH))}
+    onKeyDown={(e) => {
+      if (e.key === 'Enter' && value.length === TIME_IN_CODE_LENGTH) onSubmit?.();
+    }}
+    className={`w-24 px-2 py-1.5 border border-gray-300 rounded-lg text-sm text-center tracking-widest font-mono focus:outline-none focus:ring-2 focus:ring-primary-500 focus:border-transparent disabled:bg-gray-100 ${className}`}
+  />
+);
+
+export default TimeInCodeInput;
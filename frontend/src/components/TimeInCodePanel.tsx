@@ -0,0 +1,125 @@
+import React, { useCallback, useEffect, useState } from 'react';
+import { KeyRound, ShieldAlert } from 'lucide-react';
+
+/* ===== TIME-IN CODE PANEL ===== */
+// Shows an open session's rotating time-in code for students to type in, and the
+// wrong or replayed codes entered so far.
+
+interface SessionCode {
+  session_id: number;
+  code: string;
+  rotation_seconds: number;
+  seconds_remaining: number;
+  expires_at: string;
+}
+
+interface CodeAttempt {
+  id: number;
+  student_user_id: number;
+  student_code: string;
+  first_name: string;
+  last_name: string;
+  pc_number?: string;
+  code: string;
+  result: 'invalid' | 'replayed';
+  attempted_at: string;
+}
+
+interface TimeInCodePanelProps {
+  sessionId: number;
+  teacherUserId: number;
+}
+
+const ATTEMPTS_POLL_INTERVAL_MS = 10000;
+
+export const TimeInCodePanel: React.FC<TimeInCodePanelProps> = ({ sessionId, teacherUserId }) => {
+  const [sessionCode, setSessionCode] = useState<SessionCode | null>(null);
+  const [secondsLeft, setSecondsLeft] = useState(0);
+  const [attempts, setAttempts] = useState<CodeAttempt[]>([]);
+  const [error, setError] = useState('');
+
+  const loadCode = useCallback(async () => {
+    try {
+      const next: SessionCode = await (window as any).go.backend.App.GetAttendanceSessionCode(sessionId, teacherUserId);
+      setSessionCode(next);
+      setSecondsLeft(next.seconds_remaining);
+      setError('');
+    } catch (err: any) {
+      setSessionCode(null);
+      setError(err?.message || String(err || 'Unable to load the time-in code.'));
+    }
+  }, [sessionId, teacherUserId]);
+
+  const loadAttempts = useCallback(async () => {
+    try {
+      const list: CodeAttempt[] = await (window as any).go.backend.App.GetAttendanceCodeAttempts(sessionId, teacherUserId);
+      setAttempts(list || []);
+    } catch (err) {
+      console.error('Failed to load time-in code attempts:', err);
+    }
+  }, [sessionId, teacherUserId]);
+
+  useEffect(() => {
+    loadCode();
+    loadAttempts();
+    const poll = window.setInterval(loadAttempts, ATTEMPTS_POLL_INTERVAL_MS);
+    return () => window.clearInterval(poll);
+  }, [loadCode, loadAttempts]);
+
+  // Count down locally and fetch the next code when this one runs out.
+  useEffect(() => {
+    if (!sessionCode) return;
+    const ticker = window.setInterval(() => {
+      setSecondsLeft((prev) => {
+        if (prev <= 1) {
+          loadCode();
+          return 0;
+        }
+        return prev - 1;
+      });
+    }, 1000);
+    return () => window.clearInterval(ticker);
+  }, [sessionCode, loadCode]);
+
+  return (
+    <div className="mb-4 border border-primary-200 bg-primary-50 rounded-lg px-4 py-3">
+      <div className="flex flex-wrap items-center justify-between gap-3">
+        <div className="flex items-center gap-2">
+          <KeyRound className="h-5 w-5 text-primary-600" />
+          <span className="text-sm font-medium text-gray-700">Time-in code</span>
+        </div>
+        {sessionCode ? (
+          <div className="flex items-center gap-3">
+            <span className="font-mono text-3xl font-bold tracking-[0.3em] text-gray-900">{sessionCode.code}</span>
+            <span className="text-xs text-gray-500">changes in {secondsLeft}s</span>
+          </div>
+        ) : (
+          <span className="text-xs text-gray-500">{error || 'Loading...'}</span>
+        )}
+      </div>
+
+      {attempts.length > 0 && (
+        <div className="mt-3 pt-3 border-t border-primary-200">
+          <div className="flex items-center gap-1 text-xs font-medium text-warning-700 mb-1">
+            <ShieldAlert className="h-4 w-4" />
+            {attempts.length} wrong or reused {attempts.length === 1 ? 'code' : 'codes'}
+          </div>
+          <ul className="max-h-32 overflow-y-auto text-xs text-gray-600 space-y-0.5">
+            {attempts.map((attempt) => (
+              <li key={attempt.id}>
+                {attempt.attempted_at.slice(11)} · {attempt.last_name}, {attempt.first_name} ({attempt.student_code})
+                {attempt.pc_number ? ` on ${attempt.pc_number}` : ''} ·{' '}
+                <span className={attempt.result === 'replayed' ? 'text-danger-600 font-medium' : ''}>
+                  {attempt.result === 'replayed' ? 'expired code reused' : 'wrong code'}
+                </span>
+                {attempt.code ? ` "${attempt.code}"` : ''}
+              </li>
+            ))}
+          </ul>
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default TimeInCodePanel;
@@ -1,7 +1,8 @@
 import React, { createContext, useContext, useState, useEffect, useCallback, useRef } from 'react';
-import { Login, Logout, UnlockScreen, LockScreen } from '../../wailsjs/go/backend/App';
+import { Login, Logout, UnlockScreen, LockScreen, ResumeSession, CompleteTwoFactorLogin } from '../../wailsjs/go/backend/App';
 import type { User } from '../types';
-import { useWindowUnload } from '../hooks/useInactivity';
+import { EventsOn } from '../../wailsjs/runtime/runtime';
+import { ACTIVITY_EVENTS, useWindowUnload } from '../hooks/useInactivity';
 
 // Extend Window interface to include Wails runtime
 declare global {
@@ -20,6 +21,7 @@ declare global {
 interface AuthContextType {
   user: User | null;
   login: (username: string, password: string, rememberMe: boolean) => Promise<User>;
+  completeTwoFactorLogin: (token: string, code: string, rememberMe: boolean) => Promise<User>;
   logout: () => Promise<void>;
   updateUser: (updatedUser: Partial<User>) => void;
   isAuthenticated: boolean;
@@ -29,6 +31,11 @@ const AuthContext = createContext<AuthContextType | undefined>(undefined);
 const AUTH_STATUS_CHANGED_EVENT = 'auth-status-changed';
 const REMEMBER_ME_KEY = 'rememberMe';
 const HEARTBEAT_INTERVAL_MS = 30 * 1000;
+const ACTIVITY_REPORT_INTERVAL_MS = 15 * 1000;
+export const IDLE_WARNING_EVENT = 'session:idle-warning';
+const AUTO_LOGOUT_EVENT = 'session:auto-logout';
+const SESSION_TAKEN_OVER_EVENT = 'session:taken-over';
+export const SESSION_ENDED_NOTICE_KEY = 'sessionEndedNotice';
 const RUNTIME_WAIT_TIMEOUT_MS = 5000;
 const RUNTIME_WAIT_INTERVAL_MS = 50;
 
@@ -70,6 +77,32 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     };
   }, [user]);
 
+  // Lock-mode stations log idle users out from the backend, so report keyboard, mouse
+  // and touch input (throttled). Unlike the heartbeat, this only fires when someone is
+  // actually using the PC.
+  useEffect(() => {
+    if (!user) return;
+
+    let lastReportedAt = 0;
+    const reportActivity = () => {
+      const now = Date.now();
+      if (now - lastReportedAt < ACTIVITY_REPORT_INTERVAL_MS) return;
+      lastReportedAt = now;
+      if (!window.go?.backend?.App?.ReportActivity) return;
+      window.go.backend.App.ReportActivity(user.id).catch(() => {});
+    };
+
+    ACTIVITY_EVENTS.forEach((event) => {
+      document.addEventListener(event, reportActivity, true);
+    });
+
+    return () => {
+      ACTIVITY_EVENTS.forEach((event) => {
+        document.removeEventListener(event, reportActivity, true);
+      });
+    };
+  }, [user]);
+
   const clearLocalSession = useCallback(() => {
     setUser(null);
     setIsAuthenticated(false);
@@ -79,16 +112,35 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     window.dispatchEvent(new CustomEvent(AUTH_STATUS_CHANGED_EVENT));
   }, []);
 
-  // Check for saved user session on mount
+  // Check for saved user session on mount.
+  // The backend only honours the session token minted by Login in this process,
+  // so a stored user is restored only when ResumeSession accepts its token.
   useEffect(() => {
+    const restoreUser = async (storedUser: User, remembered: boolean) => {
+      const runtimeReady = await waitForWailsRuntime();
+      if (!runtimeReady || !storedUser.session_token) {
+        clearLocalSession();
+        return;
+      }
+      try {
+        await ResumeSession(storedUser.session_token);
+      } catch (error) {
+        console.warn('Stored session is no longer valid:', error);
+        clearLocalSession();
+        return;
+      }
+      setUser(storedUser);
+      setIsAuthenticated(true);
+      setIsRememberedSession(remembered);
+      // If user session exists, unlock screen (lock mode - user was already logged in)
+      UnlockScreen().catch(() => {});
+    };
+
     const sessionUser = sessionStorage.getItem('user');
     if (sessionUser) {
       try {
         const parsedSessionUser = JSON.parse(sessionUser);
-        setUser(parsedSessionUser);
-        setIsAuthenticated(true);
-        setIsRememberedSession(false);
-        UnlockScreen().catch(() => {});
+        void restoreUser(parsedSessionUser, false);
         return;
       } catch (error) {
         console.error('Failed to parse session user:', error);
@@ -100,17 +152,13 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     if (savedUser) {
       try {
         const parsedUser = JSON.parse(savedUser);
-        setUser(parsedUser);
-        setIsAuthenticated(true);
-        setIsRememberedSession(true);
-        // If user session exists, unlock screen (lock mode - user was already logged in)
-        UnlockScreen().catch(() => {});
+        void restoreUser(parsedUser, true);
       } catch (error) {
         console.error('Failed to parse saved user:', error);
         localStorage.removeItem('user');
       }
     }
-  }, []);
+  }, [clearLocalSession]);
 
   // Handle inactivity timeout — calls backend to record logout, then clears local session
   const handleAutoLogout = useCallback(async () => {
@@ -139,6 +187,26 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     }
   }, [clearLocalSession, user]);
 
+  // The backend has already closed the login log and locked the screen after an idle
+  // logout or a takeover from another station; only the local session is left to clear.
+  // The notice survives clearLocalSession so the login page can explain what happened.
+  useEffect(() => {
+    if (!user || !isWailsRuntimeReady()) return;
+    const endSession = (notice: string) => (userID: number) => {
+      if (userID !== user.id) return;
+      clearLocalSession();
+      sessionStorage.setItem(SESSION_ENDED_NOTICE_KEY, notice);
+    };
+    const offAutoLogout = EventsOn(AUTO_LOGOUT_EVENT,
+      endSession('You were logged out because this PC was idle.'));
+    const offTakenOver = EventsOn(SESSION_TAKEN_OVER_EVENT,
+      endSession('You were logged out because your account was used to log in on another PC. If that was not you, change your password.'));
+    return () => {
+      offAutoLogout();
+      offTakenOver();
+    };
+  }, [clearLocalSession, user]);
+
   // Handle window/app close — only clears local session.
   // The Go OnBeforeClose + OnShutdown hooks reliably handle DB session cleanup
   // on the backend side, so no async backend call is needed here.
@@ -170,36 +238,58 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
         throw new Error('Invalid credentials');
       }
 
-      // Update state and persist
-      setUser(userData);
-      setIsAuthenticated(true);
-      setIsRememberedSession(rememberMe);
-
-      if (rememberMe) {
-        localStorage.setItem('user', JSON.stringify(userData));
-        sessionStorage.removeItem('user');
-      } else {
-        sessionStorage.setItem('user', JSON.stringify(userData));
-        localStorage.removeItem('user');
+      // Admins and teachers with two-factor authentication finish in completeTwoFactorLogin.
+      if (userData.two_factor_required) {
+        return userData;
       }
 
-      localStorage.setItem(REMEMBER_ME_KEY, String(rememberMe));
-      window.dispatchEvent(new CustomEvent(AUTH_STATUS_CHANGED_EVENT));
+      return await establishSession(userData, rememberMe);
+    } catch (error) {
+      console.error('Login failed:', error);
+      throw error;
+    }
+  };
 
-      // Unlock screen in lock mode so user can freely use Windows
-      try {
-        await UnlockScreen();
-      } catch (e) {
-        // Silently ignore if not in lock mode
+  const completeTwoFactorLogin = async (token: string, code: string, rememberMe: boolean): Promise<User> => {
+    try {
+      const userData = await CompleteTwoFactorLogin(token, code);
+      if (!userData) {
+        throw new Error('Invalid verification code');
       }
-      
-      return userData;
+      return await establishSession(userData, rememberMe);
     } catch (error) {
-      console.error('Login failed:', error);
+      console.error('Two-factor verification failed:', error);
       throw error;
     }
   };
 
+  const establishSession = async (userData: User, rememberMe: boolean): Promise<User> => {
+    // Update state and persist
+    setUser(userData);
+    setIsAuthenticated(true);
+    setIsRememberedSession(rememberMe);
+
+    if (rememberMe) {
+      localStorage.setItem('user', JSON.stringify(userData));
+      sessionStorage.removeItem('user');
+    } else {
+      sessionStorage.setItem('user', JSON.stringify(userData));
+      localStorage.removeItem('user');
+    }
+
+    localStorage.setItem(REMEMBER_ME_KEY, String(rememberMe));
+    window.dispatchEvent(new CustomEvent(AUTH_STATUS_CHANGED_EVENT));
+
+    // Unlock screen in lock mode so user can freely use Windows
+    try {
+      await UnlockScreen();
+    } catch (e) {
+      // Silently ignore if not in lock mode
+    }
+    
+    return userData;
+  };
+
   const logout = async () => {
     if (logoutInProgressRef.current) {
       return;
@@ -242,6 +332,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     <AuthContext.Provider value={{ 
       user, 
       login, 
+      completeTwoFactorLogin,
       logout, 
       updateUser,
       isAuthenticated 
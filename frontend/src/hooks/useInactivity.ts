@@ -1,7 +1,7 @@
 import { useEffect } from 'react';
 
 const INACTIVITY_TIMEOUT = 30 * 60 * 1000; // 30 minutes
-const ACTIVITY_EVENTS = ['mousedown', 'mousemove', 'keypress', 'scroll', 'touchstart', 'click'];
+export const ACTIVITY_EVENTS = ['mousedown', 'mousemove', 'keypress', 'scroll', 'touchstart', 'click'];
 
 /**
  * Hook to handle user inactivity and automatic logout
@@ -1,6 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import { useNavigate } from 'react-router-dom';
-import { useAuth } from '../contexts/AuthContext';
+import { SESSION_ENDED_NOTICE_KEY, useAuth } from '../contexts/AuthContext';
 import { User, Lock, Eye, EyeOff, UserPlus, KeyRound, Check, X, CornerUpLeft, Settings } from 'lucide-react';
 import { CreateUser, GetDepartments } from '../../wailsjs/go/backend/App';
 import { backend } from '../../wailsjs/go/models';
@@ -200,17 +200,40 @@ function LoginPage() {
   const [dbPort, setDbPort] = useState('3306');
   const [dbUsername, setDbUsername] = useState('');
   const [dbPassword, setDbPassword] = useState('');
+  const [dbPasswordSet, setDbPasswordSet] = useState(false);
   const [dbConfigPath, setDbConfigPath] = useState('');
   const [dbConfigured, setDbConfigured] = useState(false);
   const [dbStatusMessage, setDbStatusMessage] = useState('');
   const [dbErrorMessage, setDbErrorMessage] = useState('');
   const [showDbPassword, setShowDbPassword] = useState(false);
+  const [stationAdminUsername, setStationAdminUsername] = useState('');
+  const [stationAdminPassword, setStationAdminPassword] = useState('');
+  const [stationAdminCode, setStationAdminCode] = useState('');
+  const [stationPIN, setStationPIN] = useState('');
+  const [stationPINSet, setStationPINSet] = useState(false);
+  const [twoFactorToken, setTwoFactorToken] = useState('');
+  const [twoFactorCode, setTwoFactorCode] = useState('');
+  const [twoFactorEnrollment, setTwoFactorEnrollment] = useState<TwoFactorEnrollment | null>(null);
 
   type LockSettings = {
     lock_mode: boolean;
     computer_lab: string;
     pc_number: string;
     station_label: string;
+    maintenance_pin_set: boolean;
+  };
+
+  type TwoFactorEnrollment = {
+    secret: string;
+    provisioning_uri: string;
+    backup_codes: string[];
+  };
+
+  type StationAuthorization = {
+    admin_username: string;
+    admin_password: string;
+    admin_code: string;
+    maintenance_pin: string;
   };
 
   type DatabaseSetupSettings = {
@@ -218,7 +241,7 @@ function LoginPage() {
     port: string;
     dbname: string;
     username: string;
-    password: string;
+    password_set: boolean;
     source_path: string;
     write_path: string;
     is_configured: boolean;
@@ -270,6 +293,7 @@ function LoginPage() {
   };
 
   const setLockSettingsFromInputBridge = async (
+    auth: StationAuthorization,
     input: string,
     computerLab: string,
     pcNumber: string
@@ -279,7 +303,7 @@ function LoginPage() {
       throw new Error('This app build does not support lock mode updates yet. Please restart after updating.');
     }
 
-    return appBridge.SetLockSettingsFromInput(input, computerLab, pcNumber);
+    return appBridge.SetLockSettingsFromInput(auth, input, computerLab, pcNumber);
   };
 
   const loadDatabaseSetupBridge = async (): Promise<DatabaseSetupSettings> => {
@@ -292,6 +316,7 @@ function LoginPage() {
   };
 
   const saveDatabaseSetupBridge = async (
+    auth: StationAuthorization,
     host: string,
     port: string,
     username: string,
@@ -302,7 +327,7 @@ function LoginPage() {
       throw new Error('This app build does not support database configuration updates yet. Please restart after updating.');
     }
 
-    return appBridge.SaveDatabaseSetupSettings(host, port, FIXED_DATABASE_NAME, username, passwordValue);
+    return appBridge.SaveDatabaseSetupSettings(auth, host, port, FIXED_DATABASE_NAME, username, passwordValue);
   };
 
   const handleForgotVerifyIdentity = async () => {
@@ -465,6 +490,7 @@ function LoginPage() {
       setLockComputerLab(settings.computer_lab ?? '');
       setLockPCNumber(settings.pc_number ?? '');
       setLockStationLabel(settings.station_label ?? '');
+      setStationPINSet(Boolean(settings.maintenance_pin_set));
     } catch (err) {
       setLockErrorMessage(getThrowableMessage(err, 'Unable to load lock mode status.'));
     }
@@ -474,7 +500,8 @@ function LoginPage() {
       setDbHost(dbSettings.host ?? '');
       setDbPort(dbSettings.port ?? '3306');
       setDbUsername(dbSettings.username ?? '');
-      setDbPassword(dbSettings.password ?? '');
+      setDbPassword('');
+      setDbPasswordSet(Boolean(dbSettings.password_set));
       setDbConfigured(Boolean(dbSettings.is_configured));
       setDbConfigPath(dbSettings.source_path || dbSettings.write_path || '');
     } catch (err) {
@@ -484,6 +511,10 @@ function LoginPage() {
 
   const closeLockSettingsModal = () => {
     setShowLockSettingsModal(false);
+    setStationAdminUsername('');
+    setStationAdminPassword('');
+    setStationAdminCode('');
+    setStationPIN('');
     setLockErrorMessage('');
     setLockStatusMessage('');
     setDbErrorMessage('');
@@ -524,6 +555,13 @@ function LoginPage() {
       return;
     }
 
+    const auth: StationAuthorization = {
+      admin_username: stationAdminUsername.trim(),
+      admin_password: stationAdminPassword,
+      admin_code: stationAdminCode.trim(),
+      maintenance_pin: stationPIN.trim()
+    };
+
     setLockSaving(true);
     setLockErrorMessage('');
     setLockStatusMessage('');
@@ -533,6 +571,7 @@ function LoginPage() {
     let lockUpdated = false;
     try {
       const updatedSettings = await setLockSettingsFromInputBridge(
+        auth,
         lockExpression.trim(),
         lockComputerLab.trim(),
         lockPCNumber.trim()
@@ -543,6 +582,7 @@ function LoginPage() {
       setLockComputerLab(updatedSettings.computer_lab ?? '');
       setLockPCNumber(updatedSettings.pc_number ?? '');
       setLockStationLabel(updatedSettings.station_label ?? '');
+      setStationPINSet(Boolean(updatedSettings.maintenance_pin_set));
       setLockStatusMessage(`Lock mode is now ${updatedMode ? 'enabled' : 'disabled'}.`);
       lockUpdated = true;
     } catch (err) {
@@ -551,6 +591,7 @@ function LoginPage() {
 
     try {
       const updated = await saveDatabaseSetupBridge(
+        auth,
         dbHost.trim(),
         dbPort.trim(),
         dbUsername.trim(),
@@ -560,7 +601,8 @@ function LoginPage() {
       setDbHost(updated.host ?? '');
       setDbPort(updated.port ?? '3306');
       setDbUsername(updated.username ?? '');
-      setDbPassword(updated.password ?? '');
+      setDbPassword('');
+      setDbPasswordSet(Boolean(updated.password_set));
       setDbConfigured(Boolean(updated.is_configured));
       const resolvedPath = updated.source_path || updated.write_path || '';
       setDbConfigPath(resolvedPath);
@@ -587,9 +629,18 @@ function LoginPage() {
     lastName: ''
   });
   
-  const { login } = useAuth();
+  const { login, completeTwoFactorLogin } = useAuth();
   const navigate = useNavigate();
 
+  // Explain a session the backend ended on its own (idle logout or a login elsewhere).
+  useEffect(() => {
+    const notice = sessionStorage.getItem(SESSION_ENDED_NOTICE_KEY);
+    if (notice) {
+      sessionStorage.removeItem(SESSION_ENDED_NOTICE_KEY);
+      setError(notice);
+    }
+  }, []);
+
   // Load departments when registration mode is activated
   useEffect(() => {
     if (isRegistering) {
@@ -619,8 +670,16 @@ function LoginPage() {
 
     try {
       const userData = await login(username, password, rememberMe);
-      
-      if (userData) {
+
+      if (userData?.two_factor_required && userData.two_factor_token) {
+        setTwoFactorToken(userData.two_factor_token);
+        setTwoFactorCode('');
+        setTwoFactorEnrollment(
+          userData.two_factor_setup_required
+            ? await loadTwoFactorLoginEnrollmentBridge(userData.two_factor_token)
+            : null
+        );
+      } else if (userData) {
         navigate(roleRoutes[userData.role]);
       } else {
         setError('Incorrect ID or password. Please check your details and try again.');
@@ -634,6 +693,49 @@ function LoginPage() {
     }
   };
 
+  const loadTwoFactorLoginEnrollmentBridge = async (token: string): Promise<TwoFactorEnrollment> => {
+    const appBridge = (window as any)?.go?.backend?.App;
+    if (!appBridge || typeof appBridge.GetTwoFactorLoginEnrollment !== 'function') {
+      throw new Error('This app build does not support two-factor authentication yet. Please restart after updating.');
+    }
+
+    return appBridge.GetTwoFactorLoginEnrollment(token);
+  };
+
+  const cancelTwoFactorLogin = () => {
+    setTwoFactorToken('');
+    setTwoFactorCode('');
+    setTwoFactorEnrollment(null);
+    setPassword('');
+    setError('');
+  };
+
+  const handleTwoFactorLogin = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!twoFactorCode.trim()) {
+      setError('Please enter the code from your authenticator app.');
+      return;
+    }
+
+    setLoading(true);
+    setError('');
+
+    try {
+      const userData = await completeTwoFactorLogin(twoFactorToken, twoFactorCode.trim(), rememberMe);
+      setTwoFactorToken('');
+      setTwoFactorEnrollment(null);
+      navigate(roleRoutes[userData.role]);
+    } catch (err) {
+      const message = getErrorText(err);
+      if (message.toLowerCase().includes('log in again')) {
+        cancelTwoFactorLogin();
+      }
+      setError(message || 'Verification failed. Please try again.');
+    } finally {
+      setLoading(false);
+    }
+  };
+
   const handleRegister = async (e: React.FormEvent) => {
     e.preventDefault();
     if (!registrationData.studentCode || !registrationData.firstName || !registrationData.lastName) {
@@ -751,6 +853,68 @@ function LoginPage() {
             </div>
           )}
 
+          {twoFactorToken ? (
+            <form onSubmit={handleTwoFactorLogin} className="space-y-5" noValidate autoComplete="off">
+              {twoFactorEnrollment ? (
+                <div className="space-y-3 text-sm text-gray-700">
+                  <p>
+                    Two-factor authentication is required for your account. Add it to an authenticator app by
+                    scanning the setup link as a QR code or entering the key, then enter the 6-digit code it shows.
+                  </p>
+                  <div className="rounded-lg border border-gray-200 bg-gray-50 px-3 py-2 text-xs break-all">
+                    Key: <span className="font-mono font-semibold">{twoFactorEnrollment.secret}</span>
+                    <br />
+                    Setup link: <span className="font-mono">{twoFactorEnrollment.provisioning_uri}</span>
+                  </div>
+                  <div className="rounded-lg border border-amber-200 bg-amber-50 px-3 py-2 text-xs text-amber-800">
+                    Keep these backup codes somewhere safe. Each one can be used once if you lose your device:
+                    <div className="mt-1 grid grid-cols-2 gap-1 font-mono font-semibold">
+                      {twoFactorEnrollment.backup_codes.map((code) => (
+                        <span key={code}>{code}</span>
+                      ))}
+                    </div>
+                  </div>
+                </div>
+              ) : (
+                <p className="text-sm text-gray-700">
+                  Enter the 6-digit code from your authenticator app, or one of your backup codes.
+                </p>
+              )}
+
+              <div>
+                <label htmlFor="two-factor-code" className="block text-sm font-semibold text-gray-700 mb-1.5">
+                  Verification Code
+                </label>
+                <input
+                  id="two-factor-code"
+                  type="text"
+                  inputMode="numeric"
+                  value={twoFactorCode}
+                  onChange={(e) => setTwoFactorCode(e.target.value)}
+                  className="w-full px-3 py-2.5 border border-gray-300 rounded-lg text-sm focus:outline-none focus:ring-2 focus:ring-teal-500"
+                  autoComplete="one-time-code"
+                  autoFocus
+                />
+              </div>
+
+              <div className="flex gap-3">
+                <button
+                  type="button"
+                  onClick={cancelTwoFactorLogin}
+                  className="flex-1 py-2.5 border border-gray-300 rounded-lg text-sm font-medium text-gray-700 hover:bg-gray-50 transition-colors"
+                >
+                  Back
+                </button>
+                <button
+                  type="submit"
+                  disabled={loading}
+                  className="flex-1 bg-teal-600 text-white py-2.5 rounded-lg hover:bg-teal-700 disabled:opacity-50 disabled:cursor-not-allowed transition-all font-semibold text-sm"
+                >
+                  {loading ? 'Verifying...' : 'Verify'}
+                </button>
+              </div>
+            </form>
+          ) : (
           <form onSubmit={handleLogin} className="space-y-5" noValidate autoComplete="off">
             {/* Username/ID Field */}
             <div>
@@ -857,6 +1021,7 @@ function LoginPage() {
               </p>
             </div>
           </form>
+          )}
         </div>
       </div>
 
@@ -900,6 +1065,76 @@ function LoginPage() {
             </div>
 
             <form onSubmit={applyLockSettings} className="px-6 py-5 space-y-4" noValidate>
+              <div className="space-y-3">
+                <div className="rounded-lg border border-amber-200 bg-amber-50 px-3 py-2 text-xs text-amber-800">
+                  Changes need an admin account{stationPINSet ? ' or the lab maintenance PIN' : ''}.
+                </div>
+
+                <div>
+                  <label htmlFor="station-admin-username" className="block text-sm font-semibold text-gray-700 mb-1.5">
+                    Admin ID
+                  </label>
+                  <input
+                    id="station-admin-username"
+                    type="text"
+                    value={stationAdminUsername}
+                    onChange={(e) => setStationAdminUsername(e.target.value)}
+                    placeholder=""
+                    className="w-full px-3 py-2.5 border border-gray-300 rounded-lg text-sm focus:outline-none focus:ring-2 focus:ring-teal-500"
+                    autoComplete="off"
+                  />
+                </div>
+
+                <div>
+                  <label htmlFor="station-admin-password" className="block text-sm font-semibold text-gray-700 mb-1.5">
+                    Admin Password
+                  </label>
+                  <input
+                    id="station-admin-password"
+                    type="password"
+                    value={stationAdminPassword}
+                    onChange={(e) => setStationAdminPassword(e.target.value)}
+                    placeholder=""
+                    className="w-full px-3 py-2.5 border border-gray-300 rounded-lg text-sm focus:outline-none focus:ring-2 focus:ring-teal-500"
+                    autoComplete="new-password"
+                  />
+                </div>
+
+                <div>
+                  <label htmlFor="station-admin-code" className="block text-sm font-semibold text-gray-700 mb-1.5">
+                    Verification Code
+                  </label>
+                  <input
+                    id="station-admin-code"
+                    type="text"
+                    inputMode="numeric"
+                    value={stationAdminCode}
+                    onChange={(e) => setStationAdminCode(e.target.value)}
+                    placeholder="Only if the admin uses an authenticator app"
+                    className="w-full px-3 py-2.5 border border-gray-300 rounded-lg text-sm focus:outline-none focus:ring-2 focus:ring-teal-500"
+                    autoComplete="one-time-code"
+                  />
+                </div>
+
+                {stationPINSet && (
+                  <div>
+                    <label htmlFor="station-pin" className="block text-sm font-semibold text-gray-700 mb-1.5">
+                      Or Maintenance PIN
+                    </label>
+                    <input
+                      id="station-pin"
+                      type="password"
+                      inputMode="numeric"
+                      value={stationPIN}
+                      onChange={(e) => setStationPIN(e.target.value)}
+                      placeholder=""
+                      className="w-full px-3 py-2.5 border border-gray-300 rounded-lg text-sm focus:outline-none focus:ring-2 focus:ring-teal-500"
+                      autoComplete="off"
+                    />
+                  </div>
+                )}
+              </div>
+
               <div className="rounded-lg border border-gray-200 bg-gray-50 px-3 py-2 text-sm text-gray-700">
                 Current status: <span className={`font-semibold ${lockModeEnabled ? 'text-emerald-700' : 'text-slate-700'}`}>
                   {lockModeEnabled ? 'Enabled' : 'Disabled'}
@@ -1024,7 +1259,7 @@ function LoginPage() {
                       type={showDbPassword ? 'text' : 'password'}
                       value={dbPassword}
                       onChange={(e) => setDbPassword(e.target.value)}
-                      placeholder=""
+                      placeholder={dbPasswordSet ? 'Leave blank to keep the saved password (same host, port and username only)' : ''}
                       className="w-full px-3 py-2.5 pr-10 border border-gray-300 rounded-lg text-sm focus:outline-none focus:ring-2 focus:ring-teal-500"
                       autoComplete="new-password"
                     />
@@ -12,10 +12,12 @@ import {
   FileText,
   UserPlus,
   BarChart3,
+  Database,
 } from 'lucide-react';
 import {
   GetAdminDashboard,
   GetStudentLoginLogs,
+  GetSchemaVersion,
 } from '../../../../wailsjs/go/backend/App';
 import { DashboardStats, LoginLog } from './types';
 import { useAuth } from '../../../contexts/AuthContext';
@@ -23,6 +25,12 @@ import { useAuth } from '../../../contexts/AuthContext';
 const AUTH_STATUS_CHANGED_EVENT = 'auth-status-changed';
 const DASHBOARD_POLL_INTERVAL_MS = 10000;
 
+interface SchemaVersionInfo {
+  current_version: number;
+  latest_version: number;
+  pending: { version: number; name: string }[];
+}
+
 function DashboardOverview() {
   const { user } = useAuth();
   const [lastLogin, setLastLogin] = useState<LoginLog | null>(null);
@@ -51,6 +59,16 @@ function DashboardOverview() {
   });
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
+  const [schemaVersion, setSchemaVersion] = useState<SchemaVersionInfo | null>(null);
+
+  useEffect(() => {
+    GetSchemaVersion()
+      .then((report) => setSchemaVersion(report as SchemaVersionInfo))
+      .catch((error) => {
+        console.error('Failed to load schema version:', error);
+        setSchemaVersion(null);
+      });
+  }, []);
 
   useEffect(() => {
     const loadStats = async () => {
@@ -478,6 +496,24 @@ function DashboardOverview() {
             </CardBody>
           </Card>
 
+          {schemaVersion && (
+            <Card className="h-fit">
+              <CardHeader title="Database Schema" />
+              <CardBody>
+                <InfoCard
+                  icon={<Database className="h-6 w-6" />}
+                  label={`Latest available: v${schemaVersion.latest_version}`}
+                  value={`Version ${schemaVersion.current_version}`}
+                  iconColor={schemaVersion.pending.length > 0 ? 'yellow' : 'green'}
+                />
+                {schemaVersion.pending.length > 0 && (
+                  <p className="mt-2 text-xs text-warning-700">
+                    {schemaVersion.pending.length} migration(s) pending - restart the app to apply them.
+                  </p>
+                )}
+              </CardBody>
+            </Card>
+          )}
         </div>
       </div>
     </div>
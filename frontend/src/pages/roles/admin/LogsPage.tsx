@@ -527,6 +527,12 @@ function ViewLogs() {
               render: (log: LoginLog) => (
                 <span className="text-gray-600 text-xs sm:text-sm break-words">
                   {log.logout_time ? formatTime(log.logout_time) : '—'}
+                  {log.logout_reason === 'auto_logout' && (
+                    <span className="ml-1 text-xs text-amber-600">(idle)</span>
+                  )}
+                  {log.logout_reason === 'taken_over' && (
+                    <span className="ml-1 text-xs text-red-600">(taken over)</span>
+                  )}
                 </span>
               )
             }
@@ -55,6 +55,7 @@ export interface LoginLog {
   pc_number?: string;
   login_time: string;
   logout_time?: string;
+  logout_reason?: 'manual' | 'auto_logout' | 'taken_over';
 }
 
 // Use the generated Feedback model from main
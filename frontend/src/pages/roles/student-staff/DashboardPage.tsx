@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { Link } from 'react-router-dom';
 import { Card, CardHeader, CardBody, StatCard, InfoCard } from '../../../components/Card';
 import Button from '../../../components/Button';
+import TimeInCodeInput, { TIME_IN_CODE_LENGTH } from '../../../components/TimeInCodeInput';
 import LoadingDots from '../../../components/LoadingDots';
 import {
   Users,
@@ -24,6 +25,8 @@ import { DashboardStats } from './types';
 
 interface AttendanceSession {
   session_id: number;
+  // Set while the student is timed in; the session then offers Time Out.
+  timed_in?: boolean;
   class_id: number;
   attendance_date: string;
   session_name: string;
@@ -78,6 +81,7 @@ function DashboardOverview() {
   const [openSessions, setOpenSessions] = useState<AttendanceSession[]>([]);
   const [lastLogin, setLastLogin] = useState<LoginLog | null>(null);
   const [timingInSession, setTimingInSession] = useState<number | null>(null);
+  const [timeInCodes, setTimeInCodes] = useState<Record<number, string>>({});
   const [nowTimestamp, setNowTimestamp] = useState<number>(Date.now());
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
@@ -149,7 +153,8 @@ function DashboardOverview() {
     const expectedStatus = getExpectedTimeInStatus(targetSession);
     setTimingInSession(sessionId);
     try {
-      await StudentTimeIn(sessionId, user.id);
+      await StudentTimeIn(sessionId, user.id, timeInCodes[sessionId] || '');
+      setTimeInCodes((prev) => ({ ...prev, [sessionId]: '' }));
       toast(
         expectedStatus === 'late' ? 'Time In recorded successfully as Late.' : 'Time In recorded successfully as Present.',
         'success'
@@ -166,6 +171,23 @@ function DashboardOverview() {
     }
   };
 
+  const handleTimeOut = async (sessionId: number) => {
+    if (!user?.id) return;
+
+    setTimingInSession(sessionId);
+    try {
+      await (window as any).go.backend.App.StudentTimeOut(sessionId, user.id);
+      toast('Time Out recorded. Enter a new code if you come back to class.', 'success');
+      const next = await GetStudentOpenAttendanceSessions(user.id);
+      setOpenSessions(next || []);
+    } catch (error: any) {
+      console.error('Failed to time out:', error);
+      toast(error?.message || 'Failed to time out. Please try again.', 'error');
+    } finally {
+      setTimingInSession(null);
+    }
+  };
+
   useEffect(() => {
     const loadStats = async () => {
       try {
@@ -375,19 +397,40 @@ function DashboardOverview() {
                               <p className="text-[11px] text-warning-700 mt-1">Session is paused. Time In is temporarily disabled.</p>
                             )}
                           </div>
-                          <Button
-                            onClick={() => handleTimeIn(session.session_id)}
-                            variant="primary"
-                            size="sm"
-                            disabled={timingInSession === session.session_id || !!session.paused_at}
-                            className="flex-shrink-0"
-                          >
-                            {timingInSession === session.session_id
-                              ? 'Submitting...'
-                              : session.paused_at
-                                ? 'Paused'
-                                : 'Time In'}
-                          </Button>
+                          {session.timed_in ? (
+                            <Button
+                              onClick={() => handleTimeOut(session.session_id)}
+                              variant="outline"
+                              size="sm"
+                              disabled={timingInSession === session.session_id}
+                              className="flex-shrink-0"
+                            >
+                              {timingInSession === session.session_id ? 'Submitting...' : 'Time Out'}
+                            </Button>
+                          ) : (
+                            <>
+                              <TimeInCodeInput
+                                value={timeInCodes[session.session_id] || ''}
+                                onChange={(value) => setTimeInCodes((prev) => ({ ...prev, [session.session_id]: value }))}
+                                onSubmit={() => handleTimeIn(session.session_id)}
+                                disabled={timingInSession === session.session_id || !!session.paused_at}
+                                className="flex-shrink-0"
+                              />
+                              <Button
+                                onClick={() => handleTimeIn(session.session_id)}
+                                variant="primary"
+                                size="sm"
+                                disabled={timingInSession === session.session_id || !!session.paused_at || (timeInCodes[session.session_id] || '').length !== TIME_IN_CODE_LENGTH}
+                                className="flex-shrink-0"
+                              >
+                                {timingInSession === session.session_id
+                                  ? 'Submitting...'
+                                  : session.paused_at
+                                    ? 'Paused'
+                                    : 'Time In'}
+                              </Button>
+                            </>
+                          )}
                         </div>
 
                         {openedAt && (
@@ -510,7 +553,9 @@ function DashboardOverview() {
                                 ? 'text-warning-700 bg-warning-50'
                                 : status.toLowerCase() === 'absent'
                                   ? 'text-danger-700 bg-danger-50'
-                                  : 'text-gray-600 bg-gray-100';
+                                  : status.toLowerCase() === 'excused'
+                                    ? 'text-blue-700 bg-blue-50'
+                                    : 'text-gray-600 bg-gray-100';
                           const displayDate = record.date
                             ? (() => {
                                 const d = new Date(record.date + 'T12:00:00');
@@ -1,14 +1,18 @@
 import { useEffect, useState, useRef, useCallback } from 'react';
 import Button from '../../../components/Button';
+import TimeInCodeInput, { TIME_IN_CODE_LENGTH } from '../../../components/TimeInCodeInput';
 import Modal, { MODAL_BODY_MIN_HEIGHT_CLASS } from '../../../components/Modal';
-import { CheckCircle2, History, Filter, X, Search, RefreshCw } from 'lucide-react';
+import { CheckCircle2, History, Filter, X, Search, RefreshCw, FileText } from 'lucide-react';
 import { useAuth } from '../../../contexts/AuthContext';
 import { useAppUi } from '../../../contexts/AppUiContext';
 import { GetStudentOpenAttendanceSessions, StudentTimeIn, GetStudentAttendanceHistory } from '../../../../wailsjs/go/backend/App';
 import LoadingDots from '../../../components/LoadingDots';
+import StudentExcuseRequestsModal from '../../../components/StudentExcuseRequestsModal';
 
 interface AttendanceSession {
   session_id: number;
+  // Set while the student is timed in; the session then offers Time Out.
+  timed_in?: boolean;
   class_id: number;
   attendance_date: string;
   session_name: string;
@@ -40,6 +44,7 @@ function StudentAttendance() {
   const [sessions, setSessions] = useState<AttendanceSession[]>([]);
   const [loading, setLoading] = useState(true);
   const [timingInSession, setTimingInSession] = useState<number | null>(null);
+  const [timeInCodes, setTimeInCodes] = useState<Record<number, string>>({});
   const [nowTimestamp, setNowTimestamp] = useState<number>(Date.now());
   const previousSessionCountRef = useRef<number | null>(null);
 
@@ -52,6 +57,7 @@ function StudentAttendance() {
   const [pendingClassFilter, setPendingClassFilter] = useState<string>('all');
   const [pendingStatusFilter, setPendingStatusFilter] = useState<string>('all');
   const [showHistoryModal, setShowHistoryModal] = useState(false);
+  const [showExcuseModal, setShowExcuseModal] = useState(false);
 
   const parseSessionDateTime = (value?: string): Date | null => {
     if (!value) return null;
@@ -146,7 +152,8 @@ function StudentAttendance() {
     setTimingInSession(sessionId);
 
     try {
-      await StudentTimeIn(sessionId, user.id);
+      await StudentTimeIn(sessionId, user.id, timeInCodes[sessionId] || '');
+      setTimeInCodes((prev) => ({ ...prev, [sessionId]: '' }));
       toast(
         expectedStatus === 'late' ? 'Time In recorded successfully as Late.' : 'Time In recorded successfully as Present.',
         'success'
@@ -160,6 +167,22 @@ function StudentAttendance() {
     }
   };
 
+  const handleTimeOut = async (sessionId: number) => {
+    if (!user?.id) return;
+
+    setTimingInSession(sessionId);
+    try {
+      await (window as any).go.backend.App.StudentTimeOut(sessionId, user.id);
+      toast('Time Out recorded. Enter a new code if you come back to class.', 'success');
+      await Promise.all([loadSessions(), loadHistory()]);
+    } catch (error: any) {
+      console.error('Failed to time out:', error);
+      toast(error?.message || 'Failed to time out. Please try again.', 'error');
+    } finally {
+      setTimingInSession(null);
+    }
+  };
+
   // Unique classes for filter dropdown
   const uniqueClasses = Array.from(
     new Map(history.map(r => [`${r.class_id}`, { class_id: r.class_id, label: `${r.subject_code} - ${r.subject_name}${r.section ? ` (${r.section})` : ''}` }])).values()
@@ -181,11 +204,13 @@ function StudentAttendance() {
   const presentCount = history.filter(r => r.status.toLowerCase() === 'present').length;
   const lateCount = history.filter(r => r.status.toLowerCase() === 'late').length;
   const absentCount = history.filter(r => r.status.toLowerCase() === 'absent').length;
+  const excusedCount = history.filter(r => r.status.toLowerCase() === 'excused').length;
 
   const statusBadge = (status: string) => {
     const s = status.toLowerCase();
     if (s === 'present') return <span className="inline-flex items-center px-2 py-0.5 rounded text-xs font-medium bg-green-100 text-green-800">Present</span>;
     if (s === 'late') return <span className="inline-flex items-center px-2 py-0.5 rounded text-xs font-medium bg-yellow-100 text-yellow-800">Late</span>;
+    if (s === 'excused') return <span className="inline-flex items-center px-2 py-0.5 rounded text-xs font-medium bg-blue-100 text-blue-800">Excused</span>;
     return <span className="inline-flex items-center px-2 py-0.5 rounded text-xs font-medium bg-red-100 text-red-800">Absent</span>;
   };
 
@@ -208,6 +233,14 @@ function StudentAttendance() {
       <div className="flex items-center justify-between">
         <h2 className="text-2xl font-bold text-gray-900">Attendance</h2>
         <div className="flex items-center gap-2">
+          <Button
+            onClick={() => setShowExcuseModal(true)}
+            variant="outline"
+            size="sm"
+            icon={<FileText className="h-4 w-4" />}
+          >
+            Excuses
+          </Button>
           <Button
             onClick={() => setShowHistoryModal(true)}
             variant="outline"
@@ -279,19 +312,40 @@ function StudentAttendance() {
                     );
                   })()}
                 </div>
-                <Button
-                  onClick={() => handleTimeIn(session.session_id)}
-                  variant="primary"
-                  size="sm"
-                  disabled={timingInSession === session.session_id || !!session.paused_at}
-                  icon={<CheckCircle2 className="h-4 w-4" />}
-                >
-                  {timingInSession === session.session_id
-                    ? 'Submitting...'
-                    : session.paused_at
-                      ? 'Paused'
-                      : 'Time In'}
-                </Button>
+                {session.timed_in ? (
+                  <Button
+                    onClick={() => handleTimeOut(session.session_id)}
+                    variant="outline"
+                    size="sm"
+                    disabled={timingInSession === session.session_id}
+                    className="flex-shrink-0"
+                  >
+                    {timingInSession === session.session_id ? 'Submitting...' : 'Time Out'}
+                  </Button>
+                ) : (
+                  <>
+                    <TimeInCodeInput
+                      value={timeInCodes[session.session_id] || ''}
+                      onChange={(value) => setTimeInCodes((prev) => ({ ...prev, [session.session_id]: value }))}
+                      onSubmit={() => handleTimeIn(session.session_id)}
+                      disabled={timingInSession === session.session_id || !!session.paused_at}
+                      className="flex-shrink-0"
+                    />
+                    <Button
+                      onClick={() => handleTimeIn(session.session_id)}
+                      variant="primary"
+                      size="sm"
+                      disabled={timingInSession === session.session_id || !!session.paused_at || (timeInCodes[session.session_id] || '').length !== TIME_IN_CODE_LENGTH}
+                      icon={<CheckCircle2 className="h-4 w-4" />}
+                    >
+                      {timingInSession === session.session_id
+                        ? 'Submitting...'
+                        : session.paused_at
+                          ? 'Paused'
+                          : 'Time In'}
+                    </Button>
+                  </>
+                )}
               </div>
             ))}
           </div>
@@ -397,6 +451,7 @@ function StudentAttendance() {
                             <option value="present">Present</option>
                             <option value="late">Late</option>
                             <option value="absent">Absent</option>
+                            <option value="excused">Excused</option>
                           </select>
                           {pendingStatusFilter !== 'all' && (
                             <button onClick={() => setPendingStatusFilter('all')} className="text-gray-400 hover:text-gray-600">
@@ -441,7 +496,7 @@ function StudentAttendance() {
           </div>
 
           {history.length > 0 && (
-            <div className="grid grid-cols-3 gap-3 mt-3 mb-4">
+            <div className="grid grid-cols-4 gap-3 mt-3 mb-4">
               <div className="bg-green-50 border border-green-200 rounded-lg p-3 text-center">
                 <p className="text-xl font-bold text-green-700">{presentCount}</p>
                 <p className="text-xs text-green-600 font-medium">Present</p>
@@ -454,6 +509,10 @@ function StudentAttendance() {
                 <p className="text-xl font-bold text-red-700">{absentCount}</p>
                 <p className="text-xs text-red-600 font-medium">Absent</p>
               </div>
+              <div className="bg-blue-50 border border-blue-200 rounded-lg p-3 text-center">
+                <p className="text-xl font-bold text-blue-700">{excusedCount}</p>
+                <p className="text-xs text-blue-600 font-medium">Excused</p>
+              </div>
             </div>
           )}
 
@@ -485,6 +544,12 @@ function StudentAttendance() {
           )}
         </div>
       </Modal>
+
+      <StudentExcuseRequestsModal
+        isOpen={showExcuseModal}
+        onClose={() => { setShowExcuseModal(false); loadHistory(); }}
+        userId={user?.id}
+      />
     </div>
   );
 }
@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { useNavigate, useParams, useSearchParams, useLocation } from 'react-router-dom';
 import Button from '../../../components/Button';
 import LoadingDots from '../../../components/LoadingDots';
+import TimeInCodePanel from '../../../components/TimeInCodePanel';
 import { ArchiveIcon } from '../../../components/icons/ArchiveIcons';
 import {
   Calendar,
@@ -27,7 +28,7 @@ import {
 import { openExportSaveDialog, defaultAttendanceFilename, type ExportFormat } from '../../../utils/exportSaveDialog';
 import { useAuth } from '../../../contexts/AuthContext';
 import { useAppUi } from '../../../contexts/AppUiContext';
-import { Class, Attendance } from './types';
+import { Class, Attendance, AttendanceFinalizationSummary } from './types';
 
 function AttendanceManagementDetail() {
   const navigate = useNavigate();
@@ -42,6 +43,8 @@ function AttendanceManagementDetail() {
   const [selectedDate, setSelectedDate] = useState<string>(initialDate);
   const [loading, setLoading] = useState(true);
   const [loadingAttendance, setLoadingAttendance] = useState(false);
+  const [finalization, setFinalization] = useState<AttendanceFinalizationSummary | null>(null);
+  const [finalizing, setFinalizing] = useState(false);
   const [error, setError] = useState<string>('');
   const [hasSelectedDate, setHasSelectedDate] = useState(!!initialDate);
   const [sessionId, setSessionId] = useState<number | null>(null);
@@ -124,7 +127,7 @@ function AttendanceManagementDetail() {
         if (format === 'csv') {
           await ExportAttendanceCSVBySession(classId, selectedDate, sessionId, savePath);
         } else if (format === 'pdf') {
-          await ExportAttendancePDFBySession(classId, selectedDate, sessionId, savePath);
+          await ExportAttendancePDFBySession(classId, selectedDate, sessionId, true, savePath);
         } else {
           await ExportAttendanceDOCXBySession(classId, selectedDate, sessionId, savePath);
         }
@@ -165,6 +168,22 @@ function AttendanceManagementDetail() {
       return;
     }
 
+    const reason = window.prompt(`Reason for changing ${record.last_name}, ${record.first_name} to ${newStatus}:`)?.trim();
+    if (!reason) {
+      toast('A reason is required to change attendance.', 'error');
+      return;
+    }
+
+    // Offer the teacher's own remarks for editing; a remark that only repeats the old status is
+    // left blank so the new status label is used.
+    const currentRemarks = record.remarks && record.remarks.toLowerCase() !== (record.status || '').toLowerCase()
+      ? record.remarks
+      : '';
+    const remarks = window.prompt('Remarks (optional):', currentRemarks);
+    if (remarks === null) {
+      return;
+    }
+
     const key = `${record.class_id}-${record.student_user_id}-${record.date}`;
     setUpdatingStatus(prev => ({ ...prev, [key]: true }));
     
@@ -175,7 +194,8 @@ function AttendanceManagementDetail() {
           record.student_user_id,
           user.id,
           newStatus,
-          record.remarks || ''
+          remarks.trim(),
+          reason
         );
       } else {
         await UpdateAttendanceRecord(
@@ -183,7 +203,8 @@ function AttendanceManagementDetail() {
           record.student_user_id,
           record.date,
           newStatus,
-          record.remarks || ''
+          remarks.trim(),
+          reason
         );
       }
       
@@ -193,7 +214,7 @@ function AttendanceManagementDetail() {
           r.class_id === record.class_id && 
           r.student_user_id === record.student_user_id && 
           r.date === record.date
-            ? { ...r, status: newStatus }
+            ? { ...r, status: newStatus, remarks: remarks.trim() || newStatus.charAt(0).toUpperCase() + newStatus.slice(1) }
             : r
         )
       );
@@ -261,6 +282,11 @@ function AttendanceManagementDetail() {
         records = await GetClassAttendance(selectedClass.class_id, selectedDate);
       }
       setAttendanceRecords(records || []);
+      setFinalization(
+        meta.sessionId && meta.status === 'closed'
+          ? await (window as any).go.backend.App.GetAttendanceSessionFinalization(meta.sessionId)
+          : null
+      );
     } catch (error) {
       console.error('Failed to load attendance:', error);
       setError('Unable to load attendance records. Please try again.');
@@ -272,6 +298,21 @@ function AttendanceManagementDetail() {
     }
   };
 
+  const handleFinalize = async () => {
+    if (!sessionId || !user?.id) return;
+    setFinalizing(true);
+    try {
+      const summary = await (window as any).go.backend.App.FinalizeAttendanceSession(sessionId, user.id);
+      toast(`Session finalized; ${summary?.marked_absent?.length || 0} unmarked student(s) marked absent.`, 'success');
+      await loadAttendance();
+    } catch (error) {
+      console.error('Failed to finalize session:', error);
+      toast('Failed to finalize session. ' + (error instanceof Error ? error.message : 'Please try again.'), 'error');
+    } finally {
+      setFinalizing(false);
+    }
+  };
+
   const handleCancelClick = () => {
     const state = location.state as { fromArchiveModal?: boolean; returnToArchiveTab?: 'attendance' | 'classes' } | null;
     if (state?.fromArchiveModal && state.returnToArchiveTab === 'attendance') {
@@ -376,6 +417,34 @@ function AttendanceManagementDetail() {
               </div>
             )}
 
+            {sessionId && sessionStatus === 'open' && user?.id && (
+              <TimeInCodePanel sessionId={sessionId} teacherUserId={user.id} />
+            )}
+
+            {finalization && finalization.status !== 'finalized' && (
+              <div className="mb-4 bg-amber-50 border border-amber-200 rounded-md px-4 py-3 flex items-start justify-between gap-4">
+                <div className="text-sm text-amber-800">
+                  <p className="font-medium">
+                    {finalization.unmarked_count} unmarked student(s)
+                    {finalization.status === 'pending' && finalization.due_at
+                      ? ` will be marked absent at ${finalization.due_at} unless corrected.`
+                      : ' are waiting for your review.'}
+                  </p>
+                  {finalization.unmarked.length > 0 && (
+                    <p className="text-xs mt-1">{finalization.unmarked.join('; ')}</p>
+                  )}
+                </div>
+                <Button onClick={handleFinalize} variant="outline" size="sm" disabled={finalizing}>
+                  {finalizing ? 'Finalizing...' : 'Mark Absent & Finalize'}
+                </Button>
+              </div>
+            )}
+            {finalization && finalization.status === 'finalized' && finalization.marked_absent.length > 0 && (
+              <div className="mb-4 bg-gray-50 border border-gray-200 rounded-md px-4 py-2 text-xs text-gray-600">
+                Finalized {finalization.finalized_at || ''}: marked absent as unmarked - {finalization.marked_absent.join('; ')}
+              </div>
+            )}
+
             <div className="mb-6 pb-4 border-b border-gray-200">
               <div className="text-center mb-4">
                 <div className="flex items-center justify-center gap-2">
@@ -511,6 +580,21 @@ function AttendanceManagementDetail() {
                               </td>
                               <td className="px-3 py-1.5 text-center text-gray-900 whitespace-nowrap">
                                 {record.time_in || '—'}
+                                {record.time_in && record.time_in_station && (
+                                  <div className="text-[11px] text-gray-500">{record.time_in_station}</div>
+                                )}
+                                {record.minutes_late != null && record.status !== 'present' && (
+                                  <div className="text-[11px] text-warning-700">{record.minutes_late} min late</div>
+                                )}
+                                {record.time_out && (
+                                  <div className="text-[11px] text-gray-500">
+                                    Out {record.time_out}
+                                    {record.minutes_attended != null ? ` · ${record.minutes_attended} min` : ''}
+                                  </div>
+                                )}
+                                {record.presence_status && (
+                                  <div className="text-[11px] text-warning-700 capitalize">{record.presence_status} presence</div>
+                                )}
                               </td>
                               <td className="px-3 py-1.5 text-gray-900">
                                 {record.remarks || '—'}
@@ -542,6 +626,14 @@ function AttendanceManagementDetail() {
                               <span className="text-red-700">
                                 Absent: {attendanceRecords.filter(r => r.status === 'absent').length}
                               </span>
+                              <span className="text-blue-700">
+                                Excused: {attendanceRecords.filter(r => r.status === 'excused').length}
+                              </span>
+                              {attendanceRecords.some(r => !r.status) && (
+                                <span className="text-gray-600">
+                                  Unmarked: {attendanceRecords.filter(r => !r.status).length}
+                                </span>
+                              )}
                             </div>
                             <div className="font-bold text-gray-900">
                               Total: {attendanceRecords.length}
@@ -530,7 +530,7 @@ function AttendanceClassSelection() {
       let filename = '';
       if (sessionId && sessionId > 0) {
         if (format === 'csv') filename = await ExportAttendanceCSVBySession(classId, date, sessionId, savePath);
-        else if (format === 'pdf') filename = await ExportAttendancePDFBySession(classId, date, sessionId, savePath);
+        else if (format === 'pdf') filename = await ExportAttendancePDFBySession(classId, date, sessionId, false, savePath);
         else filename = await ExportAttendanceDOCXBySession(classId, date, sessionId, savePath);
       } else {
         if (format === 'csv') filename = await ExportAttendanceCSVByDate(classId, date, savePath);
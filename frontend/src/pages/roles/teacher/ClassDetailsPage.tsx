@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { useNavigate, useParams, useSearchParams, useLocation } from 'react-router-dom';
 import Button from '../../../components/Button';
 import LoadingDots from '../../../components/LoadingDots';
+import ClassExcuseRequestsModal from '../../../components/ClassExcuseRequestsModal';
 import {
   Edit,
   Trash2,
@@ -9,6 +10,7 @@ import {
   CornerUpLeft,
   Printer,
   Eye,
+  FileText,
 } from 'lucide-react';
 import {
   GetClassStudents,
@@ -22,7 +24,7 @@ import {
 import { openExportSaveDialog, defaultClasslistFilename, type ExportFormat } from '../../../utils/exportSaveDialog';
 import { useAuth } from '../../../contexts/AuthContext';
 import { useAppUi } from '../../../contexts/AppUiContext';
-import { Class, ClasslistEntry, ClassStudent } from './types';
+import { AttendanceFinalizationPolicy, Class, ClasslistEntry, ClassStudent, LatenessRule, LatenessStatus, LatenessTier, TimeInLocationRule } from './types';
 
 function ClassManagementDetail() {
   const navigate = useNavigate();
@@ -39,6 +41,7 @@ function ClassManagementDetail() {
   const [error, setError] = useState<string>('');
   const [showAddModal, setShowAddModal] = useState(false);
   const [showEditModal, setShowEditModal] = useState(false);
+  const [showExcuseModal, setShowExcuseModal] = useState(false);
   const [selectedStudents, setSelectedStudents] = useState<Set<number>>(new Set());
   const [searchTerm, setSearchTerm] = useState('');
   const [studentSearchTerm, setStudentSearchTerm] = useState('');
@@ -51,6 +54,11 @@ function ClassManagementDetail() {
     schoolYear: ''
   });
   const [saving, setSaving] = useState(false);
+  const [timeInRestricted, setTimeInRestricted] = useState(false);
+  const [allowedLabsInput, setAllowedLabsInput] = useState('');
+  const [finalizationPolicy, setFinalizationPolicy] = useState<AttendanceFinalizationPolicy>({ unmarked_policy: 'absent', pending_hours: 24 });
+  const [latenessRule, setLatenessRule] = useState<LatenessRule>({ tiers: [], after_status: 'late' });
+  const [recomputePastLateness, setRecomputePastLateness] = useState(false);
   const [exportingClasslist, setExportingClasslist] = useState(false);
   const [exportDropdown, setExportDropdown] = useState<{ top: number; left: number } | null>(null);
 
@@ -222,9 +230,49 @@ function ClassManagementDetail() {
     }
   };
 
-  const handleEditClass = () => {
+  const handleEditClass = async () => {
     if (!classInfo) return;
     setShowEditModal(true);
+    try {
+      const rule: TimeInLocationRule = await (window as any).go.backend.App.GetClassTimeInLocationRule(classInfo.class_id);
+      setTimeInRestricted(!!rule?.restricted);
+      setAllowedLabsInput((rule?.allowed_labs || []).join(', '));
+    } catch (error) {
+      console.error('Failed to load time-in location rule:', error);
+    }
+    try {
+      const policy: AttendanceFinalizationPolicy = await (window as any).go.backend.App.GetClassFinalizationPolicy(classInfo.class_id);
+      if (policy) setFinalizationPolicy(policy);
+    } catch (error) {
+      console.error('Failed to load finalization policy:', error);
+    }
+    try {
+      const rule: LatenessRule = await (window as any).go.backend.App.GetClassLatenessRule(classInfo.class_id);
+      setLatenessRule({ tiers: rule?.tiers || [], after_status: rule?.after_status || 'late' });
+      setRecomputePastLateness(false);
+    } catch (error) {
+      console.error('Failed to load lateness rule:', error);
+    }
+  };
+
+  const updateLatenessTier = (index: number, changes: Partial<LatenessTier>) => {
+    setLatenessRule({
+      ...latenessRule,
+      tiers: latenessRule.tiers.map((tier, i) => (i === index ? { ...tier, ...changes } : tier)),
+    });
+  };
+
+  const addLatenessTier = () => {
+    const last = latenessRule.tiers[latenessRule.tiers.length - 1];
+    setLatenessRule({
+      ...latenessRule,
+      tiers: [...latenessRule.tiers, { up_to_minutes: last ? last.up_to_minutes + 15 : 15, status: last ? 'late' : 'present' }],
+      after_status: last ? 'absent' : latenessRule.after_status,
+    });
+  };
+
+  const removeLatenessTier = (index: number) => {
+    setLatenessRule({ ...latenessRule, tiers: latenessRule.tiers.filter((_, i) => i !== index) });
   };
 
   const handleSaveEdit = async () => {
@@ -241,12 +289,29 @@ function ClassManagementDetail() {
         editFormData.schoolYear,
         classInfo.is_active
       );
+      await (window as any).go.backend.App.SaveClassTimeInLocationRule(parseInt(id), user?.id || 0, {
+        restricted: timeInRestricted,
+        room: '',
+        allowed_labs: allowedLabsInput.split(',').map((lab) => lab.trim()).filter(Boolean),
+      });
+      await (window as any).go.backend.App.SaveClassFinalizationPolicy(parseInt(id), user?.id || 0, finalizationPolicy);
+      const recomputed: number = await (window as any).go.backend.App.SaveClassLatenessRule(
+        parseInt(id),
+        user?.id || 0,
+        latenessRule,
+        recomputePastLateness
+      );
       setShowEditModal(false);
       await loadClassDetails();
-      toast('Class updated successfully!', 'success');
-    } catch (error) {
+      toast(
+        recomputed > 0
+          ? `Class updated successfully! ${recomputed} attendance record(s) recomputed with the new lateness tiers.`
+          : 'Class updated successfully!',
+        'success'
+      );
+    } catch (error: any) {
       console.error('Failed to update class:', error);
-      toast('Failed to update class. Please try again.', 'error');
+      toast(error?.message || 'Failed to update class. Please try again.', 'error');
     } finally {
       setSaving(false);
     }
@@ -387,6 +452,16 @@ function ClassManagementDetail() {
                   Export
                 </Button>
               )}
+              {!classInfo.is_archived && (
+                <Button
+                  onClick={() => setShowExcuseModal(true)}
+                  variant="outline"
+                  size="sm"
+                  icon={<FileText className="h-4 w-4" />}
+                >
+                  Excuses
+                </Button>
+              )}
               {isEditMode && classInfo.is_active && !classInfo.is_archived && (
                 <>
                   <Button
@@ -682,6 +757,13 @@ function ClassManagementDetail() {
         </div>
       )}
 
+      <ClassExcuseRequestsModal
+        isOpen={showExcuseModal}
+        onClose={() => setShowExcuseModal(false)}
+        classId={classInfo.class_id}
+        teacherUserId={user?.id}
+      />
+
       {/* Edit Class Modal */}
       {showEditModal && (
         <div
@@ -749,6 +831,112 @@ function ClassManagementDetail() {
                     className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
                   />
                 </div>
+                <div>
+                  <label className="flex items-center gap-2 text-sm font-medium text-gray-700">
+                    <input
+                      type="checkbox"
+                      checked={timeInRestricted}
+                      onChange={(e) => setTimeInRestricted(e.target.checked)}
+                      className="h-4 w-4 text-blue-600 focus:ring-blue-500 border-gray-300 rounded"
+                    />
+                    Only accept time-in from this room's computer lab
+                  </label>
+                  {timeInRestricted && (
+                    <input
+                      type="text"
+                      value={allowedLabsInput}
+                      onChange={(e) => setAllowedLabsInput(e.target.value)}
+                      placeholder="Other allowed labs, comma separated"
+                      className="mt-2 w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    />
+                  )}
+                </div>
+                <div>
+                  <label className="block text-sm font-medium text-gray-700 mb-1">When a session closes, students nobody marked are</label>
+                  <select
+                    value={finalizationPolicy.unmarked_policy}
+                    onChange={(e) => setFinalizationPolicy({ ...finalizationPolicy, unmarked_policy: e.target.value as AttendanceFinalizationPolicy['unmarked_policy'] })}
+                    className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                  >
+                    <option value="absent">Marked absent</option>
+                    <option value="pending">Left pending, then marked absent</option>
+                    <option value="review">Left for my review</option>
+                  </select>
+                  {finalizationPolicy.unmarked_policy === 'pending' && (
+                    <div className="mt-2 flex items-center gap-2 text-sm text-gray-700">
+                      <input
+                        type="number"
+                        min={1}
+                        max={168}
+                        value={finalizationPolicy.pending_hours}
+                        onChange={(e) => setFinalizationPolicy({ ...finalizationPolicy, pending_hours: parseInt(e.target.value) || 0 })}
+                        className="w-20 px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                      />
+                      hours after the session closes
+                    </div>
+                  )}
+                </div>
+                <div>
+                  <label className="block text-sm font-medium text-gray-700 mb-1">Lateness tiers</label>
+                  {latenessRule.tiers.length === 0 ? (
+                    <p className="text-xs text-gray-500">Each session's grace period decides present or late.</p>
+                  ) : (
+                    <div className="space-y-2">
+                      {latenessRule.tiers.map((tier, index) => (
+                        <div key={index} className="flex items-center gap-2 text-sm text-gray-700">
+                          Up to
+                          <input
+                            type="number"
+                            min={0}
+                            max={600}
+                            value={tier.up_to_minutes}
+                            onChange={(e) => updateLatenessTier(index, { up_to_minutes: parseInt(e.target.value) || 0 })}
+                            className="w-20 px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                          />
+                          minutes:
+                          <select
+                            value={tier.status}
+                            onChange={(e) => updateLatenessTier(index, { status: e.target.value as LatenessStatus })}
+                            className="px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                          >
+                            <option value="present">Present</option>
+                            <option value="late">Late</option>
+                            <option value="absent">Absent</option>
+                          </select>
+                          <button type="button" onClick={() => removeLatenessTier(index)} className="text-xs text-gray-500 hover:text-red-600">
+                            Remove
+                          </button>
+                        </div>
+                      ))}
+                      <div className="flex items-center gap-2 text-sm text-gray-700">
+                        After that:
+                        <select
+                          value={latenessRule.after_status}
+                          onChange={(e) => setLatenessRule({ ...latenessRule, after_status: e.target.value as LatenessStatus })}
+                          className="px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                        >
+                          <option value="present">Present</option>
+                          <option value="late">Late</option>
+                          <option value="absent">Absent</option>
+                        </select>
+                      </div>
+                    </div>
+                  )}
+                  {latenessRule.tiers.length < 5 && (
+                    <button type="button" onClick={addLatenessTier} className="mt-2 text-xs text-blue-600 hover:underline">
+                      Add tier
+                    </button>
+                  )}
+                  <label className="mt-2 flex items-center gap-2 text-sm text-gray-700">
+                    <input
+                      type="checkbox"
+                      checked={recomputePastLateness}
+                      onChange={(e) => setRecomputePastLateness(e.target.checked)}
+                      className="h-4 w-4 text-blue-600 focus:ring-blue-500 border-gray-300 rounded"
+                    />
+                    Also recompute closed sessions with these tiers
+                  </label>
+                </div>
                 {/* Section removed as requested */}
               </div>
 
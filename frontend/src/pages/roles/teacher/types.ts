@@ -10,7 +10,56 @@ import { backend } from '../../../../wailsjs/go/models';
 // Use the generated models from the backend
 export type Class = backend.CourseClass;
 export type ClasslistEntry = backend.ClasslistEntry;
-export type Attendance = backend.Attendance;
+export type Attendance = backend.Attendance & {
+  // Station the student timed in from; set by StudentTimeIn.
+  time_in_station?: string;
+  // Set once the student has timed out (StudentTimeOut, logout or session close).
+  time_out?: string;
+  minutes_attended?: number;
+  // partial or absent when the minimum-presence rule downgrades the status.
+  presence_status?: string;
+  // Minutes after the session opened that the student timed in.
+  minutes_late?: number;
+};
+
+// A class's time-in location rule (GetClassTimeInLocationRule).
+export interface TimeInLocationRule {
+  restricted: boolean;
+  room: string;
+  allowed_labs: string[];
+}
+// What happens to unmarked students when a session closes (GetClassFinalizationPolicy).
+export interface AttendanceFinalizationPolicy {
+  unmarked_policy: 'absent' | 'pending' | 'review';
+  pending_hours: number;
+}
+// How a closed session was finalized (GetAttendanceSessionFinalization).
+export interface AttendanceFinalizationSummary {
+  session_id: number;
+  policy: string;
+  status: 'finalized' | 'pending' | 'review';
+  present_count: number;
+  late_count: number;
+  absent_count: number;
+  excused_count: number;
+  unmarked_count: number;
+  marked_absent: string[];
+  unmarked: string[];
+  due_at?: string;
+  finalized_at?: string;
+  finalized_by?: string;
+}
+// Ordered lateness tiers of a class or session (GetClassLatenessRule, GetSessionLatenessRule).
+export type LatenessStatus = 'present' | 'late' | 'absent';
+export interface LatenessTier {
+  up_to_minutes: number;
+  status: LatenessStatus;
+}
+export interface LatenessRule {
+  tiers: LatenessTier[];
+  after_status: LatenessStatus;
+  source?: 'session' | 'class' | 'grace';
+}
 export type ClassStudent = backend.ClassStudent;
 export type User = backend.User;
 
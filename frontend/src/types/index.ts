@@ -16,4 +16,11 @@ export interface User {
   department_code?: string;
   created?: string;
   login_log_id?: number;
+  session_token?: string;
+  two_factor_required?: boolean;
+  two_factor_setup_required?: boolean;
+  two_factor_token?: string;
+  must_change_password?: boolean;
+  password_expired?: boolean;
+  auth_source?: 'local' | 'ldap';
 }
@@ -413,7 +413,7 @@ export namespace backend {
 	    port: string;
 	    dbname: string;
 	    username: string;
-	    password: string;
+	    password_set: boolean;
 	    source_path: string;
 	    write_path: string;
 	    is_configured: boolean;
@@ -428,7 +428,7 @@ export namespace backend {
 	        this.port = source["port"];
 	        this.dbname = source["dbname"];
 	        this.username = source["username"];
-	        this.password = source["password"];
+	        this.password_set = source["password_set"];
 	        this.source_path = source["source_path"];
 	        this.write_path = source["write_path"];
 	        this.is_configured = source["is_configured"];